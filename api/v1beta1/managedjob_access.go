@@ -0,0 +1,131 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+/*
+Runtime changes - approving the groups, recording the outcome of the manual jobs and asking for the retry
+or the abort with the action annotation run the workflow instead of defining it. The validating webhook
+lets only the users who may update the approval and the action subresources of the workflow make them,
+so updating the ManagedJob is not enough. The subresources are virtual, they exist only for the
+authorization and are granted with the managedjobs-operator role.
+*/
+
+const (
+	// ActionAnnotation asks the operator to take the action on the workflow, e.g. abort or retry
+	ActionAnnotation = "jobsmanager.raczylo.com/action"
+
+	// ApprovalSubresource guards the approvals of the groups and the outcomes of the manual jobs
+	ApprovalSubresource = "approval"
+	// ActionSubresource guards the action annotation
+	ActionSubresource = "action"
+	// RuntimeChangeVerb is the verb of the approval and the action subresources
+	RuntimeChangeVerb = "update"
+)
+
+// RuntimeAuthorizer decides if the user may make the runtime changes of the workflow guarded by the subresource
+// +kubebuilder:object:generate=false
+type RuntimeAuthorizer func(ctx context.Context, user authenticationv1.UserInfo, mj *ManagedJob, subresource string) (bool, error)
+
+// approvals returns the approved groups and the outcomes of the manual jobs, keyed by their names
+func (r *ManagedJob) approvals() map[string]string {
+	approvals := map[string]string{}
+	for _, group := range r.Spec.Groups {
+		if group == nil {
+			continue
+		}
+		if group.Approved {
+			approvals[group.Name] = "approved"
+		}
+		for _, job := range group.Jobs {
+			if job != nil && job.Outcome != "" {
+				approvals[group.Name+"/"+job.Name] = job.Outcome
+			}
+		}
+	}
+	return approvals
+}
+
+// ApprovalConsumed tells if the approval of the group was used by a previous run, a new one is given by
+// withdrawing it from the spec and approving the group once the operator forgot the used one
+func (r *ManagedJob) ApprovalConsumed(group string) bool {
+	for _, state := range r.Status.Groups {
+		if state.Name == group {
+			return state.ApprovalConsumed
+		}
+	}
+	return false
+}
+
+// OutcomeConsumed tells if the outcome of the manual job was set for a previous run, like ApprovalConsumed
+func (r *ManagedJob) OutcomeConsumed(group string, job string) bool {
+	for _, state := range r.Status.Groups {
+		if state.Name != group {
+			continue
+		}
+		for _, jobState := range state.Jobs {
+			if jobState.Name == job {
+				return jobState.OutcomeConsumed
+			}
+		}
+	}
+	return false
+}
+
+// runtimeSubresources lists the subresources guarding the changes of the workflow, old is nil on create
+func (r *ManagedJob) runtimeSubresources(old *ManagedJob) []string {
+	oldApprovals, oldAction := map[string]string{}, ""
+	if old != nil {
+		oldApprovals, oldAction = old.approvals(), old.Annotations[ActionAnnotation]
+	}
+	subresources := []string{}
+	if !equality.Semantic.DeepEqual(r.approvals(), oldApprovals) {
+		subresources = append(subresources, ApprovalSubresource)
+	}
+	if r.Annotations[ActionAnnotation] != oldAction {
+		subresources = append(subresources, ActionSubresource)
+	}
+	return subresources
+}
+
+// authorizeRuntimeChanges checks the user may make the runtime changes of the workflow, old is nil on create
+func authorizeRuntimeChanges(ctx context.Context, authorize RuntimeAuthorizer, user authenticationv1.UserInfo, mj, old *ManagedJob) error {
+	for _, subresource := range mj.runtimeSubresources(old) {
+		allowed, err := authorize(ctx, user, mj, subresource)
+		if err != nil {
+			return apierrors.NewInternalError(fmt.Errorf("unable to authorize the change of the workflow: %w", err))
+		}
+		if !allowed {
+			return apierrors.NewForbidden(GroupVersion.WithResource("managedjobs").GroupResource(), mj.Name,
+				fmt.Errorf("%s may not %s the managedjobs/%s subresource, needed to change the %s", user.Username, RuntimeChangeVerb, subresource, runtimeChangeNames[subresource]))
+		}
+	}
+	return nil
+}
+
+var runtimeChangeNames = map[string]string{
+	ApprovalSubresource: "approvals of the groups and the outcomes of the manual jobs",
+	ActionSubresource:   ActionAnnotation + " annotation",
+}
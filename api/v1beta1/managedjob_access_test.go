@@ -0,0 +1,69 @@
+package v1beta1
+
+import (
+	"context"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestValidatorRuntimeChanges(t *testing.T) {
+	// jane authors the workflows, bob may approve them and take the actions
+	checked := []string{}
+	v := &managedJobValidator{operator: "system:serviceaccount:jobs-manager:operator", authorize: func(ctx context.Context, user authenticationv1.UserInfo, mj *ManagedJob, subresource string) (bool, error) {
+		checked = append(checked, subresource)
+		return user.Username == "bob", nil
+	}}
+	request := func(user string) context.Context {
+		return admission.NewContextWithRequest(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Update,
+			UserInfo:  authenticationv1.UserInfo{Username: user},
+		}})
+	}
+	old := &ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly"},
+		Spec: ManagedJobSpec{Image: "busybox", Groups: []*ManagedJobGroup{{Name: "publish", PauseBefore: true, Jobs: []*ManagedJobDefinition{
+			{Name: "sign-off", Type: JobTypeManual},
+		}}}},
+	}
+
+	edited := old.DeepCopy()
+	edited.Spec.Image = "alpine"
+	if _, err := v.ValidateUpdate(request("jane"), old, edited); err != nil || len(checked) != 0 {
+		t.Errorf("expected the edit of the author allowed without the runtime checks, got %v after %v", err, checked)
+	}
+
+	approved := old.DeepCopy()
+	approved.Spec.Groups[0].Approved = true
+	if _, err := v.ValidateUpdate(request("jane"), old, approved); !apierrors.IsForbidden(err) {
+		t.Errorf("expected the approval of the author refused, got %v", err)
+	}
+	if _, err := v.ValidateUpdate(request("bob"), old, approved); err != nil {
+		t.Errorf("expected the approval allowed, got %v", err)
+	}
+
+	signed := old.DeepCopy()
+	signed.Spec.Groups[0].Jobs[0].Outcome = "succeeded"
+	if _, err := v.ValidateUpdate(request("jane"), old, signed); !apierrors.IsForbidden(err) {
+		t.Errorf("expected the outcome of the manual job refused, got %v", err)
+	}
+
+	aborted := old.DeepCopy()
+	aborted.Annotations = map[string]string{ActionAnnotation: "abort"}
+	if _, err := v.ValidateUpdate(request("jane"), old, aborted); !apierrors.IsForbidden(err) {
+		t.Errorf("expected the action of the author refused, got %v", err)
+	}
+	if _, err := v.ValidateCreate(request("jane"), aborted); !apierrors.IsForbidden(err) {
+		t.Errorf("expected the workflow created with the action refused, got %v", err)
+	}
+
+	// the operator removes the annotation once the action is taken
+	checked = checked[:0]
+	if _, err := v.ValidateUpdate(request("system:serviceaccount:jobs-manager:operator"), aborted, old); err != nil || len(checked) != 0 {
+		t.Errorf("expected the operator not checked, got %v after %v", err, checked)
+	}
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"strconv"
+)
+
+// FormatCost formats the estimated cost the way it's kept in the status
+func FormatCost(cost float64) string {
+	return strconv.FormatFloat(cost, 'f', 4, 64)
+}
+
+// SumCosts adds up the estimated costs, empty when none of them is set
+func SumCosts(costs ...string) string {
+	total := 0.0
+	found := false
+	for _, cost := range costs {
+		value, err := strconv.ParseFloat(cost, 64)
+		if err != nil {
+			continue
+		}
+		total += value
+		found = true
+	}
+	if !found {
+		return ""
+	}
+	return FormatCost(total)
+}
+
+// EstimatedCost returns the sum of the estimated costs of the jobs in the group
+func (g *ManagedJobGroup) EstimatedCost() string {
+	costs := []string{}
+	for _, job := range g.Jobs {
+		costs = append(costs, job.EstimatedCost)
+	}
+	return SumCosts(costs...)
+}
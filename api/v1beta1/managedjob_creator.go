@@ -0,0 +1,183 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+/*
+Creator of the workflow - the mutating webhook records the user creating the workflow in its annotations,
+the operator authorizes the privileged jobs of the workflow against them and impersonates them. The
+annotations can not be set or changed by the clients, the webhook rejects the workflows setting them to
+anything else than their own user on create or changing the recorded ones on update.
+
+Sub-workflows are authorized and impersonated as the creator of the workflow running them, so only the
+operator may create them - the webhook rejects the workflows of the other users which set the ManagedJob
+controller owner reference or the sub-workflow-of annotation, or change them on update.
+*/
+
+const (
+	CreatedByAnnotation       = "jobsmanager.raczylo.com/created-by"
+	CreatedByGroupsAnnotation = "jobsmanager.raczylo.com/created-by-groups"
+	// SubWorkflowOfAnnotation holds the UID of the workflow running the sub-workflow created by the operator
+	SubWorkflowOfAnnotation = "jobsmanager.raczylo.com/sub-workflow-of"
+)
+
+// Creator returns the user recorded as the creator of the workflow
+func (r *ManagedJob) Creator() (authenticationv1.UserInfo, bool) {
+	username, recorded := r.Annotations[CreatedByAnnotation]
+	if !recorded || username == "" {
+		return authenticationv1.UserInfo{}, false
+	}
+	user := authenticationv1.UserInfo{Username: username}
+	if groups := r.Annotations[CreatedByGroupsAnnotation]; groups != "" {
+		user.Groups = strings.Split(groups, ",")
+	}
+	return user, true
+}
+
+// setCreator records the user in the annotations, the empty user removes them
+func (r *ManagedJob) setCreator(user authenticationv1.UserInfo) {
+	if r.Annotations == nil {
+		r.Annotations = map[string]string{}
+	}
+	delete(r.Annotations, CreatedByAnnotation)
+	delete(r.Annotations, CreatedByGroupsAnnotation)
+	if user.Username == "" {
+		return
+	}
+	r.Annotations[CreatedByAnnotation] = user.Username
+	if len(user.Groups) > 0 {
+		r.Annotations[CreatedByGroupsAnnotation] = strings.Join(user.Groups, ",")
+	}
+}
+
+// forgedCreator tells if the annotations of the creator set on the workflow differ from the ones recorded
+// for the user, the missing ones are recorded again
+func (r *ManagedJob) forgedCreator(user authenticationv1.UserInfo) bool {
+	recorded := &ManagedJob{}
+	recorded.setCreator(user)
+	for _, key := range []string{CreatedByAnnotation, CreatedByGroupsAnnotation} {
+		if value, set := r.Annotations[key]; set && value != recorded.Annotations[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// errForgedCreator rejects the workflow which sets the creator annotations to another user
+func errForgedCreator(mj *ManagedJob) error {
+	return apierrors.NewForbidden(GroupVersion.WithResource("managedjobs").GroupResource(), mj.Name,
+		fmt.Errorf("annotations %s and %s are recorded by the operator and can not be set by the clients", CreatedByAnnotation, CreatedByGroupsAnnotation))
+}
+
+// WorkflowOwner returns the controller owner reference of the workflow when it's a ManagedJob
+func (r *ManagedJob) WorkflowOwner() *metav1.OwnerReference {
+	owner := metav1.GetControllerOf(r)
+	if owner == nil || owner.Kind != "ManagedJob" {
+		return nil
+	}
+	if gv, err := schema.ParseGroupVersion(owner.APIVersion); err != nil || gv.Group != GroupVersion.Group {
+		return nil
+	}
+	return owner
+}
+
+// forgedSubWorkflow tells if the workflow sets the owner workflow or the sub-workflow-of annotation, old is
+// nil on create. The ones kept since the old workflow are not reported again.
+func (r *ManagedJob) forgedSubWorkflow(old *ManagedJob) bool {
+	ownerUID := func(mj *ManagedJob) types.UID {
+		if owner := mj.WorkflowOwner(); owner != nil {
+			return owner.UID
+		}
+		return ""
+	}
+	uid, annotation := ownerUID(r), r.Annotations[SubWorkflowOfAnnotation]
+	if old == nil {
+		return uid != "" || annotation != ""
+	}
+	return (uid != "" && uid != ownerUID(old)) || (annotation != "" && annotation != old.Annotations[SubWorkflowOfAnnotation])
+}
+
+// errForgedSubWorkflow rejects the workflow of a client claiming to be a sub-workflow
+func errForgedSubWorkflow(mj *ManagedJob) error {
+	return apierrors.NewForbidden(GroupVersion.WithResource("managedjobs").GroupResource(), mj.Name,
+		fmt.Errorf("only the operator creates the sub-workflows, the ManagedJob controller owner reference and the %s annotation can not be set by the clients", SubWorkflowOfAnnotation))
+}
+
+//+kubebuilder:webhook:path=/mutate-jobsmanager-raczylo-com-v1beta1-managedjob,mutating=true,failurePolicy=fail,sideEffects=None,groups=jobsmanager.raczylo.com,resources=managedjobs,verbs=create;update,versions=v1beta1,name=mmanagedjob.kb.io,admissionReviewVersions=v1
+
+// managedJobDefaulter records the creator of the workflow, guards the owner of the sub-workflows and sets
+// the conditions of the dependencies from their deprecated status on create. Operator is the username of
+// the operator creating the sub-workflows.
+type managedJobDefaulter struct {
+	operator string
+}
+
+var _ admission.CustomDefaulter = &managedJobDefaulter{}
+
+func (d *managedJobDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	mj, err := asManagedJob(obj)
+	if err != nil {
+		return err
+	}
+	request, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	byOperator := d.operator != "" && request.UserInfo.Username == d.operator
+	switch request.Operation {
+	case admissionv1.Create:
+		user := authenticationv1.UserInfo{Username: request.UserInfo.Username, Groups: request.UserInfo.Groups}
+		if mj.forgedCreator(user) {
+			return errForgedCreator(mj)
+		}
+		if !byOperator && mj.forgedSubWorkflow(nil) {
+			return errForgedSubWorkflow(mj)
+		}
+		mj.setCreator(user)
+		// the status is kept, the validation warns about it
+		mj.MigrateDependencyConditions()
+	case admissionv1.Update:
+		old := &ManagedJob{}
+		if err := json.Unmarshal(request.OldObject.Raw, old); err != nil {
+			return err
+		}
+		creator, _ := old.Creator()
+		if mj.forgedCreator(creator) {
+			return errForgedCreator(mj)
+		}
+		if !byOperator && mj.forgedSubWorkflow(old) {
+			return errForgedSubWorkflow(mj)
+		}
+		mj.setCreator(creator)
+	}
+	return nil
+}
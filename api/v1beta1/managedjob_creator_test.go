@@ -0,0 +1,81 @@
+package v1beta1
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestDefaultRecordsCreator(t *testing.T) {
+	defaulter := &managedJobDefaulter{}
+	request := func(operation admissionv1.Operation, user string, old *ManagedJob) context.Context {
+		req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: operation,
+			UserInfo:  authenticationv1.UserInfo{Username: user, Groups: []string{"developers", "system:authenticated"}},
+		}}
+		if old != nil {
+			raw, _ := json.Marshal(old)
+			req.OldObject = runtime.RawExtension{Raw: raw}
+		}
+		return admission.NewContextWithRequest(context.Background(), req)
+	}
+
+	// the annotations naming another user are rejected
+	forged := &ManagedJob{ObjectMeta: metav1.ObjectMeta{Name: "release", Annotations: map[string]string{CreatedByAnnotation: "admin", CreatedByGroupsAnnotation: "system:masters"}}}
+	if err := defaulter.Default(request(admissionv1.Create, "jane", nil), forged); !apierrors.IsForbidden(err) {
+		t.Fatalf("expected the forged creator rejected, got %v", err)
+	}
+
+	created := &ManagedJob{ObjectMeta: metav1.ObjectMeta{Name: "release", Annotations: map[string]string{CreatedByAnnotation: "jane"}}}
+	if err := defaulter.Default(request(admissionv1.Create, "jane", nil), created); err != nil {
+		t.Fatal(err)
+	}
+	creator, recorded := created.Creator()
+	if !recorded || creator.Username != "jane" || len(creator.Groups) != 2 || creator.Groups[0] != "developers" {
+		t.Fatalf("expected jane recorded as the creator, got %+v", creator)
+	}
+
+	// the removed annotations are recorded again, the changed ones are rejected
+	updated := created.DeepCopy()
+	delete(updated.Annotations, CreatedByGroupsAnnotation)
+	if err := defaulter.Default(request(admissionv1.Update, "bob", created), updated); err != nil {
+		t.Fatal(err)
+	}
+	if creator, _ := updated.Creator(); creator.Username != "jane" || len(creator.Groups) != 2 {
+		t.Errorf("expected the creator kept on update, got %+v", creator)
+	}
+	updated.Annotations[CreatedByAnnotation] = "admin"
+	if err := defaulter.Default(request(admissionv1.Update, "bob", created), updated); !apierrors.IsForbidden(err) {
+		t.Errorf("expected the changed creator rejected, got %v", err)
+	}
+
+	// only the operator creates the sub-workflows owned by other workflows
+	defaulter.operator = "system:serviceaccount:jobs-manager:controller-manager"
+	owner := metav1.OwnerReference{APIVersion: GroupVersion.String(), Kind: "ManagedJob", Name: "release", UID: "root-uid", Controller: pointer.Bool(true)}
+	child := &ManagedJob{ObjectMeta: metav1.ObjectMeta{Name: "release-images-build", OwnerReferences: []metav1.OwnerReference{owner},
+		Annotations: map[string]string{SubWorkflowOfAnnotation: "root-uid"}}}
+	if err := defaulter.Default(request(admissionv1.Create, "jane", nil), child.DeepCopy()); !apierrors.IsForbidden(err) {
+		t.Errorf("expected the sub-workflow of a client rejected, got %v", err)
+	}
+	if err := defaulter.Default(request(admissionv1.Create, defaulter.operator, nil), child); err != nil {
+		t.Errorf("expected the sub-workflow of the operator accepted, got %v", err)
+	}
+	// the clients keep the owner set by the operator but can't point it elsewhere
+	kept := child.DeepCopy()
+	if err := defaulter.Default(request(admissionv1.Update, "jane", child), kept); err != nil {
+		t.Errorf("expected the kept owner accepted, got %v", err)
+	}
+	moved := child.DeepCopy()
+	moved.OwnerReferences[0].UID = "other-uid"
+	if err := defaulter.Default(request(admissionv1.Update, "jane", child), moved); !apierrors.IsForbidden(err) {
+		t.Errorf("expected the changed owner rejected, got %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+/*
+Dependency conditions - the condition of the dependency says when the dependent runs, the status of the
+dependency is written by the operator and mirrors the group or job it points to. Workflows written before
+the condition was added set the status to failed or finished expecting the dependent to run on failure.
+The status stays in the schema as a deprecated field, the webhook converts it to the condition on create
+and warns about it, the status of the stored workflows was migrated to the condition once.
+*/
+
+const (
+	DependencyConditionSucceeded = "Succeeded"
+	DependencyConditionFailed    = "Failed"
+	DependencyConditionFinished  = "Finished"
+)
+
+// dependencyConditionOfStatus maps the statuses set by the clients before the condition was added
+var dependencyConditionOfStatus = map[string]string{
+	"succeeded": DependencyConditionSucceeded,
+	"failed":    DependencyConditionFailed,
+	"finished":  DependencyConditionFinished,
+}
+
+// DependencyCondition returns the condition of the dependency, Succeeded when not set
+func (d *ManagedJobDependencies) DependencyCondition() string {
+	if d.Condition == "" {
+		return DependencyConditionSucceeded
+	}
+	return d.Condition
+}
+
+// dependencyAt is the dependency of a group or job with its path
+type dependencyAt struct {
+	path       *field.Path
+	dependency *ManagedJobDependencies
+}
+
+// dependencies returns the dependencies of all the groups and jobs
+func (r *ManagedJob) dependencies() []dependencyAt {
+	dependencies := []dependencyAt{}
+	add := func(path *field.Path, list []*ManagedJobDependencies) {
+		for i, dependency := range list {
+			if dependency != nil {
+				dependencies = append(dependencies, dependencyAt{path: path.Index(i), dependency: dependency})
+			}
+		}
+	}
+	for i, group := range r.Spec.Groups {
+		groupPath := field.NewPath("spec", "groups").Index(i)
+		add(groupPath.Child("dependencies"), group.Dependencies)
+		for j, job := range group.Jobs {
+			add(groupPath.Child("jobs").Index(j).Child("dependencies"), job.Dependencies)
+		}
+	}
+	return dependencies
+}
+
+// MigrateDependencyConditions sets the condition of the dependencies from the deprecated status failed or
+// finished, set by the clients before the condition was added expecting the dependent to run on failure.
+// The condition set by the client is kept. The status is left as it is, the operator ignores it.
+func (r *ManagedJob) MigrateDependencyConditions() {
+	for _, at := range r.dependencies() {
+		dependency := at.dependency
+		if condition, found := dependencyConditionOfStatus[strings.ToLower(dependency.DeprecatedStatus)]; found && dependency.Condition == "" {
+			dependency.Condition = condition
+		}
+	}
+}
+
+// ClearDependencyStatuses clears the deprecated status of the dependencies
+func (r *ManagedJob) ClearDependencyStatuses() {
+	for _, at := range r.dependencies() {
+		at.dependency.DeprecatedStatus = ""
+	}
+}
+
+// dependencyStatusWarnings reports the deprecated status of the dependencies set by the client, it's ignored
+func (r *ManagedJob) dependencyStatusWarnings() admission.Warnings {
+	warnings := admission.Warnings{}
+	for _, at := range r.dependencies() {
+		status := at.dependency.DeprecatedStatus
+		if normalizeStatus(status) == statusPending {
+			continue
+		}
+		message := at.path.Child("status").String() + " is set by the operator, the value is ignored"
+		if condition, found := dependencyConditionOfStatus[strings.ToLower(status)]; found {
+			message += ", use condition: " + condition + " to run the dependent when the dependency " + strings.ToLower(status)
+		}
+		warnings = append(warnings, message)
+	}
+	if len(warnings) == 0 {
+		return nil
+	}
+	return warnings
+}
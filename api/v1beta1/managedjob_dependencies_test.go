@@ -0,0 +1,61 @@
+package v1beta1
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestMigrateDependencyConditions(t *testing.T) {
+	mj := &ManagedJob{Spec: ManagedJobSpec{Groups: []*ManagedJobGroup{{
+		Name:         "notify",
+		Dependencies: []*ManagedJobDependencies{{Name: "build", DeprecatedStatus: "Failed"}},
+		Jobs: []*ManagedJobDefinition{{Name: "j", Dependencies: []*ManagedJobDependencies{
+			{Name: "a", DeprecatedStatus: "finished"},
+			{Name: "b", DeprecatedStatus: "failed", Condition: DependencyConditionSucceeded},
+			{Name: "c"},
+		}}},
+	}}}}
+	mj.MigrateDependencyConditions()
+	mj.ClearDependencyStatuses()
+	got := []string{}
+	for _, at := range mj.dependencies() {
+		got = append(got, at.dependency.Name+"="+at.dependency.DependencyCondition()+"/"+at.dependency.DeprecatedStatus)
+	}
+	expected := []string{"build=Failed/", "a=Finished/", "b=Succeeded/", "c=Succeeded/"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("migrated dependencies = %v, expected %v", got, expected)
+	}
+}
+
+func TestDefaultDependencyConditions(t *testing.T) {
+	mj := &ManagedJob{}
+	if err := json.Unmarshal([]byte(`{"metadata":{"name":"release"},"spec":{"groups":[{"name":"notify",
+		"dependencies":[{"name":"build","status":"failed"}],
+		"jobs":[{"name":"j","image":"busybox","dependencies":[{"name":"a","status":"finished"},{"name":"b","status":"pending"}]}]}]}}`), mj); err != nil {
+		t.Fatal(err)
+	}
+	ctx := admission.NewContextWithRequest(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{Operation: admissionv1.Create}})
+	if err := (&managedJobDefaulter{}).Default(ctx, mj); err != nil {
+		t.Fatal(err)
+	}
+	group := mj.Spec.Groups[0]
+	if group.Dependencies[0].Condition != DependencyConditionFailed || group.Jobs[0].Dependencies[0].Condition != DependencyConditionFinished || group.Jobs[0].Dependencies[1].Condition != "" {
+		t.Errorf("unexpected conditions %+v %+v", group.Dependencies, group.Jobs[0].Dependencies)
+	}
+	if warnings, _ := mj.ValidateCreate(); !reflect.DeepEqual(warnings, admission.Warnings{
+		"spec.groups[0].dependencies[0].status is set by the operator, the value is ignored, use condition: Failed to run the dependent when the dependency failed",
+		"spec.groups[0].jobs[0].dependencies[0].status is set by the operator, the value is ignored, use condition: Finished to run the dependent when the dependency finished",
+	}) {
+		t.Errorf("warnings on create = %v", warnings)
+	}
+
+	mj.ClearDependencyStatuses()
+	if warnings, _ := mj.ValidateCreate(); len(warnings) != 0 {
+		t.Errorf("unexpected warnings without the status: %v", warnings)
+	}
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+/*
+Deprecated spec fields - the previous versions wrote the runtime state of the workflow into its spec. The
+fields stay in the schema, deprecated, so the API server keeps them in the stored workflows and passes them
+to the webhooks instead of pruning them: the 0001-dependency-conditions and 0002-status-subresource
+migrations read them from the stored workflows, the webhooks convert and warn about the ones set by the
+clients. The operator never reads them otherwise, its runtime state lives in the status, see MergeStatus.
+*/
+
+// DeprecatedWorkflowState is the runtime state of the workflow the previous versions wrote into the spec
+type DeprecatedWorkflowState struct {
+	// Deprecated: kept in status.aggregatedResources
+	// +optional
+	AggregatedResources *ManagedJobResourcesSummary `json:"aggregatedResources,omitempty"`
+	// Deprecated: kept in status.observedTriggers
+	// +optional
+	ObservedTriggers map[string]string `json:"observedTriggers,omitempty"`
+	// Deprecated: kept in status.strayJobs
+	// +optional
+	StrayJobs []string `json:"strayJobs,omitempty"`
+	// Deprecated: kept in status.runHistory
+	// +optional
+	RunHistory []ManagedJobRunRecord `json:"runHistory,omitempty"`
+	// Deprecated: kept in status.estimatedCompletion
+	// +optional
+	EstimatedCompletion *metav1.Time `json:"estimatedCompletion,omitempty"`
+	// Deprecated: kept in status.progress
+	// +optional
+	Progress string `json:"progress,omitempty"`
+	// Deprecated: kept in status.failedGroup
+	// +optional
+	FailedGroup string `json:"failedGroup,omitempty"`
+	// Deprecated: kept in status.failureDigest
+	// +optional
+	FailureDigest string `json:"failureDigest,omitempty"`
+	// Deprecated: kept in status.duration
+	// +optional
+	Duration string `json:"duration,omitempty"`
+	// Deprecated: kept in status.estimatedCost
+	// +optional
+	EstimatedCost string `json:"estimatedCost,omitempty"`
+	// Deprecated: kept in status.queuePosition
+	// +optional
+	QueuePosition int `json:"queuePosition,omitempty"`
+	// Deprecated: kept in status.reconcileErrors
+	// +optional
+	ReconcileErrors int `json:"reconcileErrors,omitempty"`
+	// Deprecated: kept in status.conditions
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Deprecated: kept in status.graph
+	// +optional
+	Graph []ManagedJobGraphNode `json:"graph,omitempty"`
+	// Deprecated: kept in status.warnings
+	// +optional
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// DeprecatedGroupState is the runtime state of the group the previous versions wrote into the spec
+type DeprecatedGroupState struct {
+	// Deprecated: kept in status.groups[].status, the value set by the clients is ignored
+	// +optional
+	Status string `json:"status,omitempty"`
+	// Deprecated: kept in status.groups[].readyAt
+	// +optional
+	ReadyAt *metav1.Time `json:"readyAt,omitempty"`
+	// Deprecated: kept in status.groups[].completedAt
+	// +optional
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+	// Deprecated: kept in status.groups[].reason
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Deprecated: kept in status.groups[].statusPage
+	// +optional
+	StatusPage string `json:"statusPage,omitempty"`
+}
+
+// DeprecatedJobState is the runtime state of the job the previous versions wrote into the spec
+type DeprecatedJobState struct {
+	// Deprecated: kept in status.groups[].jobs[].status, the value set by the clients is ignored
+	// +optional
+	Status string `json:"status,omitempty"`
+	// Deprecated: kept in status.groups[].jobs[].archivedLogs
+	// +optional
+	ArchivedLogs string `json:"archivedLogs,omitempty"`
+	// Deprecated: kept in status.groups[].jobs[].resolvedSpecHash
+	// +optional
+	ResolvedSpecHash string `json:"resolvedSpecHash,omitempty"`
+	// Deprecated: kept in status.groups[].jobs[].fanOutSummary
+	// +optional
+	FanOutSummary string `json:"fanOutSummary,omitempty"`
+	// Deprecated: kept in status.groups[].jobs[].drift
+	// +optional
+	Drift string `json:"drift,omitempty"`
+	// Deprecated: kept in status.groups[].jobs[].imagePullRefreshedAt
+	// +optional
+	ImagePullRefreshedAt *metav1.Time `json:"imagePullRefreshedAt,omitempty"`
+	// Deprecated: kept in status.groups[].jobs[].schedulingLatency
+	// +optional
+	SchedulingLatency *metav1.Duration `json:"schedulingLatency,omitempty"`
+	// Deprecated: kept in status.groups[].jobs[].slowScheduling
+	// +optional
+	SlowScheduling string `json:"slowScheduling,omitempty"`
+	// Deprecated: kept in status.groups[].jobs[].attempt
+	// +optional
+	Attempt int32 `json:"attempt,omitempty"`
+	// Deprecated: kept in status.groups[].jobs[].attemptSnapshots
+	// +optional
+	AttemptSnapshots []ManagedJobAttemptSnapshot `json:"attemptSnapshots,omitempty"`
+	// Deprecated: kept in status.groups[].jobs[].attemptChanges
+	// +optional
+	AttemptChanges string `json:"attemptChanges,omitempty"`
+	// Deprecated: kept in status.groups[].jobs[].reason
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Deprecated: kept in status.groups[].jobs[].estimatedCost
+	// +optional
+	EstimatedCost string `json:"estimatedCost,omitempty"`
+}
+
+// MoveDeprecatedState moves the runtime state the previous versions wrote into the spec to the runtime
+// fields of the spec and clears it, the statuses of the dependencies included. It's meant for the
+// 0002-status-subresource migration only, the runtime state of the workflows written by this version
+// is the one of the status.
+func (r *ManagedJob) MoveDeprecatedState() {
+	state := &r.Spec.DeprecatedWorkflowState
+	if state.AggregatedResources != nil && r.Status.AggregatedResources == nil {
+		r.Status.AggregatedResources = state.AggregatedResources
+	}
+	applyWorkflowState(&r.Spec, &ManagedJobStatus{
+		ObservedTriggers:    state.ObservedTriggers,
+		StrayJobs:           state.StrayJobs,
+		RunHistory:          state.RunHistory,
+		EstimatedCompletion: state.EstimatedCompletion,
+		Progress:            state.Progress,
+		FailedGroup:         state.FailedGroup,
+		FailureDigest:       state.FailureDigest,
+		Duration:            state.Duration,
+		EstimatedCost:       state.EstimatedCost,
+		QueuePosition:       state.QueuePosition,
+		ReconcileErrors:     state.ReconcileErrors,
+		Conditions:          state.Conditions,
+		Graph:               state.Graph,
+		Warnings:            state.Warnings,
+	})
+	r.Spec.DeprecatedWorkflowState = DeprecatedWorkflowState{}
+	for _, group := range r.Spec.Groups {
+		groupState := &group.DeprecatedGroupState
+		applyGroupState(group, &ManagedJobGroupStatus{
+			Status:      groupState.Status,
+			ReadyAt:     groupState.ReadyAt,
+			CompletedAt: groupState.CompletedAt,
+			Reason:      groupState.Reason,
+			StatusPage:  groupState.StatusPage,
+		})
+		group.DeprecatedGroupState = DeprecatedGroupState{}
+		moveDeprecatedDependencyStatuses(group.Dependencies)
+		for _, job := range group.Jobs {
+			jobState := &job.DeprecatedJobState
+			applyJobState(job, &ManagedJobJobStatus{
+				Status:               jobState.Status,
+				Reason:               jobState.Reason,
+				Attempt:              jobState.Attempt,
+				AttemptSnapshots:     jobState.AttemptSnapshots,
+				AttemptChanges:       jobState.AttemptChanges,
+				ArchivedLogs:         jobState.ArchivedLogs,
+				ResolvedSpecHash:     jobState.ResolvedSpecHash,
+				FanOutSummary:        jobState.FanOutSummary,
+				Drift:                jobState.Drift,
+				ImagePullRefreshedAt: jobState.ImagePullRefreshedAt,
+				SchedulingLatency:    jobState.SchedulingLatency,
+				SlowScheduling:       jobState.SlowScheduling,
+				EstimatedCost:        jobState.EstimatedCost,
+			})
+			job.DeprecatedJobState = DeprecatedJobState{}
+			moveDeprecatedDependencyStatuses(job.Dependencies)
+		}
+	}
+}
+
+func moveDeprecatedDependencyStatuses(dependencies []*ManagedJobDependencies) {
+	for _, dependency := range dependencies {
+		if dependency != nil {
+			dependency.Status, dependency.DeprecatedStatus = dependency.DeprecatedStatus, ""
+		}
+	}
+}
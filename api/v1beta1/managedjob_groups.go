@@ -0,0 +1,66 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+// GroupEnabled checks if the group runs, all of them do when enabledGroups is empty
+func (s *ManagedJobSpec) GroupEnabled(name string) bool {
+	if len(s.EnabledGroups) == 0 {
+		return true
+	}
+	for _, enabled := range s.EnabledGroups {
+		if enabled == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *ManagedJobSpec) group(name string) *ManagedJobGroup {
+	for _, group := range s.Groups {
+		if group.Name == name {
+			return group
+		}
+	}
+	return nil
+}
+
+// JobImage returns the image of the job, the one of its group or the workflow when the job has none
+func (s *ManagedJobSpec) JobImage(group *ManagedJobGroup, job *ManagedJobDefinition) string {
+	if job.Image != "" {
+		return job.Image
+	}
+	if group != nil && group.Image != "" {
+		return group.Image
+	}
+	return s.Image
+}
+
+// JobTypeManual is the type of the jobs the operator creates nothing for, they are done by hand and
+// completed by setting their outcome
+const JobTypeManual = "manual"
+
+// JobTypeWorkflow is the type of the jobs running a sub-workflow created from the referenced template
+const JobTypeWorkflow = "workflow"
+
+// runsImage tells if the job runs a container of its image, the custom executors may not need one
+func runsImage(job *ManagedJobDefinition) bool {
+	switch job.Type {
+	case "", "container", "script":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"time"
+)
+
+// MaxMaintenanceWindowDuration keeps the weekly windows from overlapping with their next occurrence
+const MaxMaintenanceWindowDuration = 7 * 24 * time.Hour
+
+var maintenanceWindowDays = map[MaintenanceWindowDay]time.Weekday{
+	"Mon": time.Monday,
+	"Tue": time.Tuesday,
+	"Wed": time.Wednesday,
+	"Thu": time.Thursday,
+	"Fri": time.Friday,
+	"Sat": time.Saturday,
+	"Sun": time.Sunday,
+}
+
+// Weekday returns the day of the week, false for an unknown day
+func (d MaintenanceWindowDay) Weekday() (time.Weekday, bool) {
+	weekday, found := maintenanceWindowDays[d]
+	return weekday, found
+}
+
+// StartsOn checks if the window starts on the day of the week
+func (w ManagedJobMaintenanceWindow) StartsOn(weekday time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, day := range w.Days {
+		if startDay, _ := day.Weekday(); startDay == weekday {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// clusterLocalSuffixes of the names resolved inside the cluster or the node, the names without any dot are
+// resolved with the search domains of the operator pod, e.g. to the services of its namespace
+var clusterLocalSuffixes = []string{".localhost", ".local", ".svc", ".internal"}
+
+// NotificationTargets decide which hosts the notification webhooks are sent to. The webhooks are sent by the
+// operator from inside the cluster, so the cluster-local names and the loopback, link-local, private and
+// unspecified addresses, e.g. the instance metadata at 169.254.169.254 or the services of the cluster, are
+// denied unless Allowed lists them.
+type NotificationTargets struct {
+	// Allowed hosts, *.example.com allows the subdomains, and CIDRs of the allowed addresses
+	Allowed []string
+}
+
+// Allows tells if the host is listed in Allowed by its name or address
+func (t NotificationTargets) Allows(host string) bool {
+	host = normalizeHost(host)
+	ip := net.ParseIP(host)
+	for _, allowed := range t.Allowed {
+		allowed = normalizeHost(allowed)
+		if _, network, err := net.ParseCIDR(allowed); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if allowed == host || strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return true
+		}
+		if allowedIP := net.ParseIP(allowed); allowedIP != nil && allowedIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckHost returns the error when the host of the webhook URL, a name or an address, is denied
+func (t NotificationTargets) CheckHost(host string) error {
+	if t.Allows(host) {
+		return nil
+	}
+	host = normalizeHost(host)
+	if ip := net.ParseIP(host); ip != nil {
+		return t.CheckAddress(ip)
+	}
+	if host == "localhost" || !strings.Contains(host, ".") {
+		return fmt.Errorf("host %s is local to the cluster and not allowed for the notifications", host)
+	}
+	for _, suffix := range clusterLocalSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return fmt.Errorf("host %s is local to the cluster and not allowed for the notifications", host)
+		}
+	}
+	return nil
+}
+
+// CheckAddress returns the error when the address, e.g. the one the host of the webhook resolved to, is denied
+func (t NotificationTargets) CheckAddress(ip net.IP) error {
+	if t.Allows(ip.String()) {
+		return nil
+	}
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() {
+		return fmt.Errorf("address %s is local to the cluster and not allowed for the notifications", ip)
+	}
+	return nil
+}
+
+// ValidateWebhooks checks the hosts of the notification webhooks of the workflow, the ones kept from old are
+// not checked again, so the workflows created before the host was denied can still be changed
+func (t NotificationTargets) ValidateWebhooks(mj, old *ManagedJob) field.ErrorList {
+	errs := field.ErrorList{}
+	if mj.Spec.Notifications == nil {
+		return errs
+	}
+	kept := map[string]bool{}
+	if old != nil && old.Spec.Notifications != nil {
+		for _, webhook := range old.Spec.Notifications.Webhooks {
+			kept[webhook.URL] = true
+		}
+	}
+	for i, webhook := range mj.Spec.Notifications.Webhooks {
+		endpoint, err := url.Parse(webhook.URL)
+		if err != nil || kept[webhook.URL] {
+			continue
+		}
+		if err := t.CheckHost(endpoint.Hostname()); err != nil {
+			errs = append(errs, field.Forbidden(field.NewPath("spec", "notifications", "webhooks").Index(i).Child("url"), err.Error()))
+		}
+	}
+	return errs
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.Trim(host, "[]")), ".")
+}
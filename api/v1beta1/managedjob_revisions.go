@@ -0,0 +1,79 @@
+package v1beta1
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+)
+
+// Revisions of the workflow definition - the operator keeps the last definitions of the workflow in the
+// revision ConfigMaps, so a bad edit can be rolled back. The definition is the spec without the runtime
+// state written by the operator and the operational switches like suspend.
+const (
+	// RevisionAnnotation holds the revision of the current definition of the workflow
+	RevisionAnnotation = "jobsmanager.raczylo.com/revision"
+	// RevisionHashAnnotation holds the hash of the current definition, a new revision is recorded once it changes
+	RevisionHashAnnotation = "jobsmanager.raczylo.com/revision-hash"
+	// RevisionLabel of the revision ConfigMaps holds their revision
+	RevisionLabel = "jobsmanager.raczylo.com/revision"
+	// RevisionDataKey of the revision ConfigMaps holds the definition
+	RevisionDataKey = "definition"
+)
+
+// RevisionConfigMapName returns the name of the ConfigMap keeping the revision of the workflow
+func RevisionConfigMapName(workflow string, revision int64) string {
+	return fmt.Sprintf("%s-revision-%d", workflow, revision)
+}
+
+// Definition returns the copy of the spec without the runtime state
+func (r *ManagedJob) Definition() *ManagedJobSpec {
+	definition := r.Spec.DeepCopy()
+	keepRuntimeState(definition, &ManagedJobSpec{})
+	return definition
+}
+
+// DefinitionHash returns the hash of the definition of the workflow
+func (r *ManagedJob) DefinitionHash() (string, error) {
+	encoded, err := json.Marshal(r.Definition())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(encoded))[:16], nil
+}
+
+// RollbackTo replaces the definition of the workflow, the runtime state of the groups and jobs which are
+// in both the definitions is kept and the new ones start pending
+func (r *ManagedJob) RollbackTo(definition *ManagedJobSpec) {
+	spec := definition.DeepCopy()
+	keepRuntimeState(spec, &r.Spec)
+	r.Spec = *spec
+}
+
+// keepRuntimeState copies the runtime state and the operational switches of the current spec onto the
+// definition, matching the groups, jobs and dependencies by their names. Runtime state of the ones missing
+// in the current spec is cleared.
+func keepRuntimeState(definition *ManagedJobSpec, current *ManagedJobSpec) {
+	status := collectStatus(current)
+	applyStatus(definition, &status)
+	definition.Suspend = current.Suspend
+
+	groups := map[string]*ManagedJobGroup{}
+	for _, group := range current.Groups {
+		groups[group.Name] = group
+	}
+	for _, group := range definition.Groups {
+		state, found := groups[group.Name]
+		if !found {
+			state = &ManagedJobGroup{}
+		}
+		group.Approved = state.Approved
+
+		outcomes := map[string]string{}
+		for _, job := range state.Jobs {
+			outcomes[job.Name] = job.Outcome
+		}
+		for _, job := range group.Jobs {
+			job.Outcome = outcomes[job.Name]
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package v1beta1
+
+import (
+	"testing"
+)
+
+func TestRollbackTo(t *testing.T) {
+	mj := &ManagedJob{Spec: ManagedJobSpec{
+		Retries: 1,
+		Suspend: true,
+		Groups: []*ManagedJobGroup{{
+			Name: "extract", Status: "running", Approved: true,
+			Jobs: []*ManagedJobDefinition{
+				{Name: "download", Image: "busybox:1.37", Status: "running", Attempt: 3, ResolvedSpecHash: "1a2b"},
+			},
+		}},
+		Progress: "0/1",
+	}}
+	before, _ := mj.DefinitionHash()
+	mj.Spec.Groups[0].Jobs[0].Status = "succeeded"
+	mj.Spec.Progress = "1/1"
+	if after, _ := mj.DefinitionHash(); after != before {
+		t.Error("runtime state changed the hash of the definition")
+	}
+
+	definition := mj.Definition()
+	if definition.Suspend || definition.Progress != "" || definition.Groups[0].Status != "" || definition.Groups[0].Jobs[0].Attempt != 0 {
+		t.Errorf("definition keeps the runtime state: %+v", definition)
+	}
+	definition.Groups[0].Jobs[0].Image = "busybox:1.36"
+	definition.Groups[0].Jobs = append(definition.Groups[0].Jobs, &ManagedJobDefinition{Name: "unpack", Status: "failed"})
+
+	mj.RollbackTo(definition)
+	download, unpack := mj.Spec.Groups[0].Jobs[0], mj.Spec.Groups[0].Jobs[1]
+	if download.Image != "busybox:1.36" || download.Status != "succeeded" || download.Attempt != 3 || download.ResolvedSpecHash != "1a2b" {
+		t.Errorf("rolled back job = %+v", download)
+	}
+	if unpack.Status != "" {
+		t.Errorf("job added by the rollback has the status %q", unpack.Status)
+	}
+	if !mj.Spec.Suspend || !mj.Spec.Groups[0].Approved || mj.Spec.Progress != "1/1" {
+		t.Errorf("runtime state of the workflow not kept: %+v", mj.Spec)
+	}
+}
@@ -0,0 +1,251 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"encoding/json"
+)
+
+/*
+Runtime state - the statuses and the details of the groups and jobs, the run history, the conditions and
+the rest of the state written by the operator live in the status subresource, so the spec stays the way the
+clients wrote it and the GitOps tools see no drift. The operator schedules the workflow on the spec with
+the runtime state merged in from the status, see MergeStatus, and splits it back out when it writes the
+workflow, see SplitStatus. The runtime fields of the spec are not serialized, so they're not part of its
+schema. The previous versions wrote them into the spec, such workflows are migrated once, see
+MoveDeprecatedState.
+*/
+
+// RecordedStatusesAnnotation kept the group and job statuses written into the spec by the previous versions
+// of the operator, it's removed by the migration to the status subresource
+const RecordedStatusesAnnotation = "jobsmanager.raczylo.com/recorded-statuses"
+
+// statusPending is the status of the groups and jobs which have not started yet
+const statusPending = "pending"
+
+// UnmarshalJSON reads the status stored by the previous versions as the bare phase of the workflow too
+func (s *ManagedJobStatus) UnmarshalJSON(data []byte) error {
+	var phase string
+	if err := json.Unmarshal(data, &phase); err == nil {
+		*s = ManagedJobStatus{Phase: phase}
+		return nil
+	}
+	type status ManagedJobStatus
+	return json.Unmarshal(data, (*status)(s))
+}
+
+// MergeStatus fills the runtime state of the spec from the status. Runtime state set in the spec by the
+// clients is replaced, the groups, jobs and dependencies missing in the status are pending.
+func (r *ManagedJob) MergeStatus() {
+	applyStatus(&r.Spec, r.Status.DeepCopy())
+	for _, group := range r.Spec.Groups {
+		group.Status = normalizeStatus(group.Status)
+		normalizeDependencyStatuses(group.Dependencies)
+		for _, job := range group.Jobs {
+			job.Status = normalizeStatus(job.Status)
+			normalizeDependencyStatuses(job.Dependencies)
+		}
+	}
+}
+
+// SplitStatus moves the runtime state of the spec into the status, keeping its phase and the aggregated
+// resources written there directly, and leaves the spec with the fields written by the clients only
+func (r *ManagedJob) SplitStatus() {
+	status := collectStatus(&r.Spec)
+	status.Phase = r.Status.Phase
+	status.AggregatedResources = r.Status.AggregatedResources
+	r.Status = status
+	applyStatus(&r.Spec, &ManagedJobStatus{})
+}
+
+// ClearRuntimeState clears the runtime state of the spec and the status, e.g. of the copy of the workflow
+// created as a new one
+func (r *ManagedJob) ClearRuntimeState() {
+	r.MoveDeprecatedState()
+	applyStatus(&r.Spec, &ManagedJobStatus{})
+	r.Status = ManagedJobStatus{}
+}
+
+// collectStatus returns the runtime state of the spec
+func collectStatus(spec *ManagedJobSpec) ManagedJobStatus {
+	status := ManagedJobStatus{
+		ObservedTriggers:    spec.ObservedTriggers,
+		StrayJobs:           spec.StrayJobs,
+		RunHistory:          spec.RunHistory,
+		EstimatedCompletion: spec.EstimatedCompletion,
+		Progress:            spec.Progress,
+		FailedGroup:         spec.FailedGroup,
+		FailureDigest:       spec.FailureDigest,
+		Duration:            spec.Duration,
+		EstimatedCost:       spec.EstimatedCost,
+		QueuePosition:       spec.QueuePosition,
+		ReconcileErrors:     spec.ReconcileErrors,
+		Conditions:          spec.Conditions,
+		Graph:               spec.Graph,
+		Warnings:            spec.Warnings,
+		MetricsDeletedAt:    spec.MetricsDeletedAt,
+		StartReleased:       spec.StartReleased,
+	}
+	for _, group := range spec.Groups {
+		groupStatus := ManagedJobGroupStatus{
+			Name:             group.Name,
+			Status:           group.Status,
+			ReadyAt:          group.ReadyAt,
+			CompletedAt:      group.CompletedAt,
+			Reason:           group.Reason,
+			StatusPage:       group.StatusPage,
+			ApprovalConsumed: group.ApprovalConsumed,
+			Dependencies:     dependencyStatuses(group.Dependencies),
+		}
+		for _, job := range group.Jobs {
+			groupStatus.Jobs = append(groupStatus.Jobs, ManagedJobJobStatus{
+				Name:                 job.Name,
+				Status:               job.Status,
+				Reason:               job.Reason,
+				Attempt:              job.Attempt,
+				AttemptSnapshots:     job.AttemptSnapshots,
+				AttemptChanges:       job.AttemptChanges,
+				ArchivedLogs:         job.ArchivedLogs,
+				ArchivePending:       job.ArchivePending,
+				ResolvedSpecHash:     job.ResolvedSpecHash,
+				FanOutSummary:        job.FanOutSummary,
+				Drift:                job.Drift,
+				ImagePullRefreshedAt: job.ImagePullRefreshedAt,
+				SchedulingLatency:    job.SchedulingLatency,
+				SlowScheduling:       job.SlowScheduling,
+				EstimatedCost:        job.EstimatedCost,
+				OutcomeConsumed:      job.OutcomeConsumed,
+				Dependencies:         dependencyStatuses(job.Dependencies),
+			})
+		}
+		status.Groups = append(status.Groups, groupStatus)
+	}
+	return status
+}
+
+// applyStatus sets the runtime state of the spec, matching the groups, jobs and dependencies by their names.
+// Runtime state of the ones missing in the status is cleared.
+func applyStatus(spec *ManagedJobSpec, status *ManagedJobStatus) {
+	applyWorkflowState(spec, status)
+	groups := map[string]*ManagedJobGroupStatus{}
+	for i := range status.Groups {
+		groups[status.Groups[i].Name] = &status.Groups[i]
+	}
+	for _, group := range spec.Groups {
+		state, found := groups[group.Name]
+		if !found {
+			state = &ManagedJobGroupStatus{}
+		}
+		applyGroupState(group, state)
+		applyDependencyStatuses(group.Dependencies, state.Dependencies)
+
+		jobs := map[string]*ManagedJobJobStatus{}
+		for i := range state.Jobs {
+			jobs[state.Jobs[i].Name] = &state.Jobs[i]
+		}
+		for _, job := range group.Jobs {
+			jobState, found := jobs[job.Name]
+			if !found {
+				jobState = &ManagedJobJobStatus{}
+			}
+			applyJobState(job, jobState)
+			applyDependencyStatuses(job.Dependencies, jobState.Dependencies)
+		}
+	}
+}
+
+// applyWorkflowState sets the runtime state of the workflow itself, the groups are left as they are
+func applyWorkflowState(spec *ManagedJobSpec, status *ManagedJobStatus) {
+	spec.ObservedTriggers = status.ObservedTriggers
+	spec.StrayJobs = status.StrayJobs
+	spec.RunHistory = status.RunHistory
+	spec.EstimatedCompletion = status.EstimatedCompletion
+	spec.Progress = status.Progress
+	spec.FailedGroup = status.FailedGroup
+	spec.FailureDigest = status.FailureDigest
+	spec.Duration = status.Duration
+	spec.EstimatedCost = status.EstimatedCost
+	spec.QueuePosition = status.QueuePosition
+	spec.ReconcileErrors = status.ReconcileErrors
+	spec.Conditions = status.Conditions
+	spec.Graph = status.Graph
+	spec.Warnings = status.Warnings
+	spec.MetricsDeletedAt = status.MetricsDeletedAt
+	spec.StartReleased = status.StartReleased
+}
+
+// applyGroupState sets the runtime state of the group, its jobs and dependencies are left as they are
+func applyGroupState(group *ManagedJobGroup, state *ManagedJobGroupStatus) {
+	group.Status = state.Status
+	group.ReadyAt = state.ReadyAt
+	group.CompletedAt = state.CompletedAt
+	group.Reason = state.Reason
+	group.StatusPage = state.StatusPage
+	group.ApprovalConsumed = state.ApprovalConsumed
+}
+
+// applyJobState sets the runtime state of the job, its dependencies are left as they are
+func applyJobState(job *ManagedJobDefinition, state *ManagedJobJobStatus) {
+	job.Status = state.Status
+	job.Reason = state.Reason
+	job.Attempt = state.Attempt
+	job.AttemptSnapshots = state.AttemptSnapshots
+	job.AttemptChanges = state.AttemptChanges
+	job.ArchivedLogs = state.ArchivedLogs
+	job.ArchivePending = state.ArchivePending
+	job.ResolvedSpecHash = state.ResolvedSpecHash
+	job.FanOutSummary = state.FanOutSummary
+	job.Drift = state.Drift
+	job.ImagePullRefreshedAt = state.ImagePullRefreshedAt
+	job.SchedulingLatency = state.SchedulingLatency
+	job.SlowScheduling = state.SlowScheduling
+	job.EstimatedCost = state.EstimatedCost
+	job.OutcomeConsumed = state.OutcomeConsumed
+}
+
+func dependencyStatuses(dependencies []*ManagedJobDependencies) map[string]string {
+	var statuses map[string]string
+	for _, dependency := range dependencies {
+		if dependency.Status == "" {
+			continue
+		}
+		if statuses == nil {
+			statuses = map[string]string{}
+		}
+		statuses[dependency.Name] = dependency.Status
+	}
+	return statuses
+}
+
+func applyDependencyStatuses(dependencies []*ManagedJobDependencies, statuses map[string]string) {
+	for _, dependency := range dependencies {
+		dependency.Status = statuses[dependency.Name]
+	}
+}
+
+func normalizeDependencyStatuses(dependencies []*ManagedJobDependencies) {
+	for _, dependency := range dependencies {
+		dependency.Status = normalizeStatus(dependency.Status)
+	}
+}
+
+func normalizeStatus(status string) string {
+	if status == "" {
+		return statusPending
+	}
+	return status
+}
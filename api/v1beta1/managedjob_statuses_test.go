@@ -0,0 +1,83 @@
+package v1beta1
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSplitStatus(t *testing.T) {
+	mj := &ManagedJob{
+		Spec: ManagedJobSpec{
+			Groups: []*ManagedJobGroup{{
+				Name: "extract", Status: "running", Dependencies: []*ManagedJobDependencies{{Name: "prepare", Status: "succeeded"}},
+				Jobs: []*ManagedJobDefinition{
+					{Name: "download", Image: "busybox", Status: "failed", Attempt: 2, Reason: "BackoffLimitExceeded"},
+					{Name: "unpack", Image: "busybox", Status: "pending"},
+				},
+			}},
+			Progress: "0/2",
+		},
+		Status: ManagedJobStatus{Phase: "running", AggregatedResources: &ManagedJobResourcesSummary{}},
+	}
+	mj.SplitStatus()
+	group, download := mj.Spec.Groups[0], mj.Spec.Groups[0].Jobs[0]
+	if mj.Spec.Progress != "" || group.Status != "" || group.Dependencies[0].Status != "" || download.Status != "" || download.Attempt != 0 || download.Image != "busybox" {
+		t.Errorf("spec keeps the runtime state: %+v %+v", group, download)
+	}
+	status := mj.Status
+	if status.Phase != "running" || status.AggregatedResources == nil || status.Progress != "0/2" || status.Groups[0].Dependencies["prepare"] != "succeeded" || status.Groups[0].Jobs[0].Attempt != 2 || status.Groups[0].Jobs[1].Status != "pending" {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	// the job added since is pending, the client's statuses in the spec are replaced
+	mj.Spec.Groups[0].Jobs = append(mj.Spec.Groups[0].Jobs, &ManagedJobDefinition{Name: "verify", Image: "busybox", Status: "succeeded"})
+	mj.Spec.Groups[0].Status = "succeeded"
+	mj.MergeStatus()
+	jobs := mj.Spec.Groups[0].Jobs
+	if mj.Spec.Groups[0].Status != "running" || jobs[0].Status != "failed" || jobs[0].Reason != "BackoffLimitExceeded" || jobs[2].Status != "pending" || mj.Spec.Progress != "0/2" {
+		t.Errorf("unexpected merged spec %+v %+v %+v", mj.Spec.Groups[0], jobs[0], jobs[2])
+	}
+	if mj.Status.Groups[0].Jobs[0].Attempt != 2 {
+		t.Error("merging changed the status")
+	}
+}
+
+func TestLegacyStatus(t *testing.T) {
+	var mj ManagedJob
+	if err := json.Unmarshal([]byte(`{"spec":{"groups":[]},"status":"succeeded"}`), &mj); err != nil || mj.Status.Phase != "succeeded" {
+		t.Errorf("legacy status not read as the phase: %+v, %v", mj.Status, err)
+	}
+	if err := json.Unmarshal([]byte(`{"status":{"phase":"failed","failedGroup":"load"}}`), &mj); err != nil || mj.Status.Phase != "failed" || mj.Status.FailedGroup != "load" {
+		t.Errorf("unexpected status %+v, %v", mj.Status, err)
+	}
+}
+
+func TestMoveDeprecatedState(t *testing.T) {
+	stored := []byte(`{"spec":{"progress":"1/2","conditions":[{"type":"Ready","status":"False","reason":"Running","message":"","lastTransitionTime":null}],
+		"groups":[{"name":"load","status":"running","dependencies":[{"name":"extract","status":"succeeded"}],
+		"jobs":[{"name":"copy","image":"busybox","status":"failed","attempt":2,"dependencies":[{"name":"prepare","status":"failed"}]}]}]}}`)
+	mj := &ManagedJob{}
+	if err := json.Unmarshal(stored, mj); err != nil {
+		t.Fatal(err)
+	}
+	// the runtime state of the spec is decoded into the deprecated fields only
+	if group := mj.Spec.Groups[0]; mj.Spec.Progress != "" || group.Status != "" || group.Dependencies[0].Status != "" || group.Jobs[0].Attempt != 0 {
+		t.Fatalf("runtime state decoded from the spec: %+v", group)
+	}
+	if group := mj.Spec.Groups[0]; mj.Spec.DeprecatedWorkflowState.Progress != "1/2" || group.DeprecatedGroupState.Status != "running" || group.Jobs[0].DeprecatedJobState.Attempt != 2 {
+		t.Fatalf("deprecated state not decoded: %+v", group)
+	}
+
+	mj.MoveDeprecatedState()
+	group, job := mj.Spec.Groups[0], mj.Spec.Groups[0].Jobs[0]
+	if mj.Spec.Progress != "1/2" || len(mj.Spec.Conditions) != 1 || group.Status != "running" || group.Dependencies[0].Status != "succeeded" {
+		t.Errorf("unexpected runtime state of the workflow %+v", group)
+	}
+	if job.Status != "failed" || job.Attempt != 2 || job.Image != "busybox" || job.Dependencies[0].Status != "failed" {
+		t.Errorf("unexpected runtime state of the job %+v", job)
+	}
+	if data, _ := json.Marshal(mj.Spec); strings.Contains(string(data), "status") || strings.Contains(string(data), "progress") {
+		t.Errorf("deprecated state kept in the spec: %s", data)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+/*
+Success criteria - the CEL expression deciding the outcome of the finished run, for the workflows where
+all the groups having to succeed is too strict. The expression has to return a bool and gets two variables:
+status - the status object of the workflow, e.g. status.groups[0].jobs[1].status, and groups - the groups by
+their names with their status, the statuses of their jobs by the job names and the total, succeeded and
+failed (the aborted included) counts of their jobs, e.g.
+groups.shards.succeeded * 10 >= groups.shards.total * 9 && groups.report.status == 'succeeded'
+*/
+
+// successCriteriaCostLimit caps the work of a single evaluation, the expression runs on every reconcile
+const successCriteriaCostLimit = 1000000
+
+var (
+	successCriteriaEnvOnce sync.Once
+	successCriteriaEnv     *cel.Env
+	successCriteriaEnvErr  error
+	// compiled programs by their expressions, the workflows are evaluated over and over
+	successCriteriaPrograms sync.Map
+)
+
+// SuccessCriteriaProgram compiles the success criteria, the expression has to return a bool
+func SuccessCriteriaProgram(expression string) (cel.Program, error) {
+	if program, found := successCriteriaPrograms.Load(expression); found {
+		return program.(cel.Program), nil
+	}
+	successCriteriaEnvOnce.Do(func() {
+		successCriteriaEnv, successCriteriaEnvErr = cel.NewEnv(
+			cel.Variable("status", cel.DynType),
+			cel.Variable("groups", cel.MapType(cel.StringType, cel.DynType)),
+		)
+	})
+	if successCriteriaEnvErr != nil {
+		return nil, successCriteriaEnvErr
+	}
+	ast, issues := successCriteriaEnv.Compile(expression)
+	if issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if outputType := ast.OutputType(); outputType != cel.BoolType && outputType != cel.DynType {
+		return nil, fmt.Errorf("expression returns %s instead of bool", outputType)
+	}
+	program, err := successCriteriaEnv.Program(ast, cel.CostLimit(successCriteriaCostLimit))
+	if err != nil {
+		return nil, err
+	}
+	successCriteriaPrograms.Store(expression, program)
+	return program, nil
+}
+
+// ValidateSuccessCriteria checks the expression compiles, whether the groups it refers to exist is only
+// known once it's evaluated
+func ValidateSuccessCriteria(expression string, path *field.Path) field.ErrorList {
+	if expression == "" {
+		return nil
+	}
+	if _, err := SuccessCriteriaProgram(expression); err != nil {
+		return field.ErrorList{field.Invalid(path, expression, err.Error())}
+	}
+	return nil
+}
+
+// SuccessCriteriaMet evaluates the success criteria over the runtime state of the workflow
+func (spec *ManagedJobSpec) SuccessCriteriaMet() (bool, error) {
+	program, err := SuccessCriteriaProgram(spec.SuccessCriteria)
+	if err != nil {
+		return false, err
+	}
+	// the status goes in as its JSON, the same object the clients see
+	data, err := json.Marshal(collectStatus(spec))
+	if err != nil {
+		return false, err
+	}
+	status := map[string]interface{}{}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return false, err
+	}
+	groups := map[string]interface{}{}
+	for _, group := range spec.Groups {
+		jobs := map[string]interface{}{}
+		var succeeded, failed int64
+		for _, job := range group.Jobs {
+			jobs[job.Name] = normalizeStatus(job.Status)
+			switch job.Status {
+			case "succeeded":
+				succeeded++
+			case "failed", "aborted":
+				failed++
+			}
+		}
+		groups[group.Name] = map[string]interface{}{
+			"status":    normalizeStatus(group.Status),
+			"jobs":      jobs,
+			"total":     int64(len(group.Jobs)),
+			"succeeded": succeeded,
+			"failed":    failed,
+		}
+	}
+	result, _, err := program.Eval(map[string]interface{}{"status": status, "groups": groups})
+	if err != nil {
+		return false, err
+	}
+	met, isBool := result.Value().(bool)
+	if !isBool {
+		return false, fmt.Errorf("expression returned %v instead of bool", result.Value())
+	}
+	return met, nil
+}
@@ -0,0 +1,51 @@
+package v1beta1
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestSuccessCriteria(t *testing.T) {
+	path := field.NewPath("spec", "successCriteria")
+	for expression, valid := range map[string]bool{
+		"":                                    true,
+		"groups.report.status == 'succeeded'": true,
+		"groups.report.ok":                    true,
+		"status.progress == '3/4'":            true,
+		"groups.shards.succeeded + 1":         false,
+		"1 + 1":                               false,
+		"groups.report.status ==":             false,
+		"unknown.report == 'succeeded'":       false,
+	} {
+		if errs := ValidateSuccessCriteria(expression, path); (len(errs) == 0) != valid {
+			t.Errorf("%q: expected valid %v, got %v", expression, valid, errs)
+		}
+	}
+
+	spec := &ManagedJobSpec{
+		SuccessCriteria: "groups.shards.succeeded * 10 >= groups.shards.total * 9 && groups.report.status == 'succeeded' && status.groups[1].jobs[0].status == 'succeeded'",
+		Groups: []*ManagedJobGroup{
+			{Name: "shards", Status: "failed", Jobs: []*ManagedJobDefinition{}},
+			{Name: "report", Status: "succeeded", Jobs: []*ManagedJobDefinition{{Name: "publish", Status: "succeeded"}}},
+		},
+	}
+	for i := 0; i < 10; i++ {
+		status := "succeeded"
+		if i == 0 {
+			status = "failed"
+		}
+		spec.Groups[0].Jobs = append(spec.Groups[0].Jobs, &ManagedJobDefinition{Name: string(rune('a' + i)), Status: status})
+	}
+	if met, err := spec.SuccessCriteriaMet(); err != nil || !met {
+		t.Errorf("expected 9 of 10 shards to meet the criteria, got %v, %v", met, err)
+	}
+	spec.Groups[0].Jobs[1].Status = "aborted"
+	if met, err := spec.SuccessCriteriaMet(); err != nil || met {
+		t.Errorf("expected 8 of 10 shards to miss the criteria, got %v, %v", met, err)
+	}
+	spec.SuccessCriteria = "groups.missing.status == 'succeeded'"
+	if _, err := spec.SuccessCriteriaMet(); err == nil {
+		t.Error("expected the missing group to fail the evaluation")
+	}
+}
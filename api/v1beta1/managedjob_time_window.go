@@ -0,0 +1,68 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ResolveTimeConstraint returns the time of the notBefore or notAfter value, either the RFC3339 time
+// or the duration after the start of the run, e.g. 2h30m
+func ResolveTimeConstraint(value string, runStartedAt time.Time) (time.Time, error) {
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, nil
+	}
+	after, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("must be the RFC3339 time or the duration after the start of the run")
+	}
+	if after < 0 {
+		return time.Time{}, fmt.Errorf("duration after the start of the run can not be negative")
+	}
+	return runStartedAt.Add(after), nil
+}
+
+// ValidateTimeConstraints checks the notBefore and notAfter of the group or the job, the window
+// they set has to be open for a while when both are the times or both are the durations
+func ValidateTimeConstraints(notBefore string, notAfter string, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	var start time.Time
+	resolve := func(value string, name string) (time.Time, bool) {
+		if value == "" {
+			return time.Time{}, false
+		}
+		at, err := ResolveTimeConstraint(value, start)
+		if err != nil {
+			errs = append(errs, field.Invalid(path.Child(name), value, err.Error()))
+			return time.Time{}, false
+		}
+		return at, true
+	}
+	notBeforeAt, withNotBefore := resolve(notBefore, "notBefore")
+	notAfterAt, withNotAfter := resolve(notAfter, "notAfter")
+	absolute := func(value string) bool {
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	}
+	if withNotBefore && withNotAfter && absolute(notBefore) == absolute(notAfter) && !notAfterAt.After(notBeforeAt) {
+		errs = append(errs, field.Invalid(path.Child("notAfter"), notAfter, "has to be after notBefore"))
+	}
+	return errs
+}
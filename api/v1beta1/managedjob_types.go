@@ -18,14 +18,65 @@ package v1beta1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 type ManagedJobDependencies struct {
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default=""
-	Name   string `json:"name"`
-	Status string `json:"status"`
+	Name string `json:"name"`
+	// Condition of the dependency the dependent waits for, Succeeded when not set. The dependents of
+	// Failed run only when the dependency failed, the ones of Finished once it's done either way.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Succeeded;Failed;Finished
+	Condition string `json:"condition,omitempty"`
+	// Status of the group or job the dependency points to, kept in the status of the workflow by the operator
+	// and filled from there by MergeStatus, it's not part of the spec
+	Status string `json:"-"`
+	// Deprecated: the status of the dependency is kept in the status of the workflow, failed and finished
+	// set by the clients before the condition was added are converted to the condition on create
+	// +optional
+	DeprecatedStatus string `json:"status,omitempty"`
+}
+
+// ManagedJobWorkflowReference points to the ManagedJob used as a sub-workflow
+type ManagedJobWorkflowReference struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// ManagedJobFanOut runs the job as an indexed Job with the given number of completions
+type ManagedJobFanOut struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=1
+	Completions int32 `json:"completions"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Parallelism *int32 `json:"parallelism,omitempty"`
+	// Retries of every index counted separately, requires Kubernetes 1.29+ (or the JobBackoffLimitPerIndex feature gate)
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	BackoffLimitPerIndex *int32 `json:"backoffLimitPerIndex,omitempty"`
+	// Number of failed indexes tolerated before the whole job fails, requires backoffLimitPerIndex
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxFailedIndexes *int32 `json:"maxFailedIndexes,omitempty"`
+}
+
+// ManagedJobScript is the inline source executed by the interpreter from the job image
+type ManagedJobScript struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default={"/bin/sh"}
+	Interpreter []string `json:"interpreter,omitempty"`
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Source string `json:"source"`
 }
 
 type ManagedJobDefinition struct {
@@ -33,24 +84,128 @@ type ManagedJobDefinition struct {
 	// +kubebuilder:validation:MaxLength=40
 	// +kubebuilder:validation:Pattern=[a-z0-9-]+
 	Name string `json:"name"`
+	// Human readable description of the job, shown by the kubectl plugin
+	// +kubebuilder:validation:Optional
+	// +optional
+	Description string `json:"description,omitempty"`
+	// Executor of the job - container, workflow, script, manual or a custom type registered in the controller
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=[a-z0-9-]+
+	// +kubebuilder:default=container
+	Type string `json:"type,omitempty"`
+	// Outcome of the manual job, set by the person or the system which did the step: succeeded or failed
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=succeeded;failed
+	// +optional
+	Outcome string `json:"outcome,omitempty"`
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default=false
 	Parallel bool `json:"parallel"`
-	// +kubebuilder:validation:Required
+	// Image of the job container, inherited from the group and the workflow when not set
+	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:MinLength=5
-	Image string `json:"image"`
+	Image string `json:"image,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +optional
+	Workflow *ManagedJobWorkflowReference `json:"workflow,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +optional
+	FanOut *ManagedJobFanOut `json:"fanOut,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +optional
+	Script *ManagedJobScript `json:"script,omitempty"`
 	// +kubebuilder:validation:Optional
 	Args []string `json:"args,omitempty"`
+	// Exit codes counted as success, e.g. [0, 2] for the tools exiting with 2 when there is nothing to do.
+	// Jobs listing the non-zero codes run the pods with the restart policy Never, not supported by fan-out jobs.
 	// +kubebuilder:validation:Optional
-	Params ManagedJobParameters `json:"params"`
+	// +optional
+	SuccessExitCodes []int32 `json:"successExitCodes,omitempty"`
+	// Earliest start of the job, the RFC3339 time or the duration after the start of the run, e.g. 2h
+	// +kubebuilder:validation:Optional
+	// +optional
+	NotBefore string `json:"notBefore,omitempty"`
+	// The job which has not started by then is skipped, the RFC3339 time or the duration after the start of the run
+	// +kubebuilder:validation:Optional
+	// +optional
+	NotAfter string `json:"notAfter,omitempty"`
+	// Mutex the job holds while it runs, the job waits until no other group or job holds it
+	// +kubebuilder:validation:Optional
+	// +optional
+	Synchronization *ManagedJobSynchronization `json:"synchronization,omitempty"`
+	// Retries of the job, the backoffLimit of its Job. Overrides the retries of the group and the workflow.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Retries *int32 `json:"retries,omitempty"`
+	// Seconds the job may run, the activeDeadlineSeconds of its Job or of the sub-workflow. The job still
+	// running after it fails with the DeadlineExceeded reason like on any other failure.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TimeoutSeconds *int64 `json:"timeoutSeconds,omitempty"`
 	// +kubebuilder:validation:Optional
-	// +kubebuilder:default=pending
-	Status string `json:"status"`
+	Params ManagedJobParameters `json:"params"`
 	// +kubebuilder:validation:Optional
 	// +optional
 	Dependencies []*ManagedJobDependencies `json:"dependencies"`
+	// JSON patch operations applied to the compiled params of the job, for overlays changing a single nested field
+	// +kubebuilder:validation:Optional
+	// +optional
+	ParamsPatches []ManagedJobParamsPatch `json:"paramsPatches,omitempty"`
+
+	// Runtime state of the job, kept in status.groups[].jobs[] by the operator and filled from there by
+	// MergeStatus. The fields are not part of the spec, see ManagedJobJobStatus for their meaning.
+	Status               string                      `json:"-"`
+	ArchivedLogs         string                      `json:"-"`
+	ArchivePending       bool                        `json:"-"`
+	ResolvedSpecHash     string                      `json:"-"`
+	FanOutSummary        string                      `json:"-"`
+	Drift                string                      `json:"-"`
+	ImagePullRefreshedAt *metav1.Time                `json:"-"`
+	SchedulingLatency    *metav1.Duration            `json:"-"`
+	SlowScheduling       string                      `json:"-"`
+	Attempt              int32                       `json:"-"`
+	AttemptSnapshots     []ManagedJobAttemptSnapshot `json:"-"`
+	AttemptChanges       string                      `json:"-"`
+	Reason               string                      `json:"-"`
+	EstimatedCost        string                      `json:"-"`
+	OutcomeConsumed      bool                        `json:"-"`
+
+	DeprecatedJobState `json:",inline"`
+}
+
+// ManagedJobAttemptSnapshot is what the Job of the attempt ran with, the values of the environment are hashed
+type ManagedJobAttemptSnapshot struct {
+	Attempt int32 `json:"attempt"`
+	// +optional
+	Image string `json:"image,omitempty"`
+	// Digest of the image the pods of the attempt ran, known once they started unless the image is pinned
+	// +optional
+	ImageDigest string `json:"imageDigest,omitempty"`
+	// Hashes of the values of the environment variables by their names
+	// +optional
+	Env map[string]string `json:"env,omitempty"`
+	// Hash of the pod spec without the image and the environment
+	// +optional
+	ParamsHash string `json:"paramsHash,omitempty"`
+}
+
+// ManagedJobParamsPatch is a single RFC 6902 operation, paths point into the compiled params, e.g. /env/0/value
+type ManagedJobParamsPatch struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=add;remove;replace;move;copy;test
+	Op string `json:"op"`
+	// +kubebuilder:validation:Required
+	Path string `json:"path"`
+	// Source of the move and copy operations
+	// +optional
+	From string `json:"from,omitempty"`
+	// Value of the add, replace and test operations
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:pruning:PreserveUnknownFields
 	// +optional
-	CompiledParams ManagedJobParameters `json:"compiledParams"`
+	Value *apiextensionsv1.JSON `json:"value,omitempty"`
 }
 
 type ManagedJobGroup struct {
@@ -58,9 +213,31 @@ type ManagedJobGroup struct {
 	// +kubebuilder:validation:MaxLength=40
 	// +kubebuilder:validation:Pattern=[a-z0-9-]+
 	Name string `json:"name"`
+	// Human readable description of the group, shown by the kubectl plugin
+	// +kubebuilder:validation:Optional
+	// +optional
+	Description string `json:"description,omitempty"`
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default=false
 	Parallel bool `json:"parallel"`
+	// Implicit dependencies of the group and its jobs, supersedes parallel of the group and its jobs when set.
+	// Serial - waits for the previous groups, jobs run one after another.
+	// Parallel - waits for the previous groups, jobs run all at once.
+	// ExplicitOnly - only the listed dependencies are followed, for the group and its jobs.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Serial;Parallel;ExplicitOnly
+	// +optional
+	Ordering string `json:"ordering,omitempty"`
+	// Weight of the group among the groups ready to start at the same time, the heavier ones get their
+	// jobs created first. Groups of the same weight start in the declaration order.
+	// +kubebuilder:validation:Optional
+	// +optional
+	Weight int32 `json:"weight,omitempty"`
+	// Image of the group jobs which do not set their own, inherited from the workflow when not set
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MinLength=5
+	// +optional
+	Image string `json:"image,omitempty"`
 	// +kubebuilder:validation:Required
 	// +kubebuilder:validation:MinItems=1
 	Jobs []*ManagedJobDefinition `json:"jobs"`
@@ -69,9 +246,101 @@ type ManagedJobGroup struct {
 	// +kubebuilder:validation:Optional
 	// +optional
 	Dependencies []*ManagedJobDependencies `json:"dependencies"`
+	// Runs the jobs in ordered batches of the given size, every batch waits for the previous one
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PartitionSize int `json:"partitionSize,omitempty"`
+	// Earliest start of the group, the RFC3339 time or the duration after the start of the run, e.g. 2h
+	// +kubebuilder:validation:Optional
+	// +optional
+	NotBefore string `json:"notBefore,omitempty"`
+	// The group which has not started by then is skipped with all its jobs, the RFC3339 time
+	// or the duration after the start of the run
+	// +kubebuilder:validation:Optional
+	// +optional
+	NotAfter string `json:"notAfter,omitempty"`
+	// Mutex the group holds from the start of its first job until it completes
+	// +kubebuilder:validation:Optional
+	// +optional
+	Synchronization *ManagedJobSynchronization `json:"synchronization,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +optional
+	FailurePolicy *ManagedJobFailurePolicy `json:"failurePolicy,omitempty"`
+	// Retries of the group jobs which do not set their own, overrides the retries of the workflow
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Retries *int32 `json:"retries,omitempty"`
+	// Creates the jobs of the group impersonating the creator of the workflow or the ServiceAccount, so
+	// the RBAC of the impersonated user decides if they may be created. Needs the impersonation enabled
+	// for the operator.
+	// +kubebuilder:validation:Optional
+	// +optional
+	Impersonate *ManagedJobImpersonation `json:"impersonate,omitempty"`
+	// Time to wait after the dependencies of the group are met before starting it
+	// +kubebuilder:validation:Optional
+	// +optional
+	DelayBefore *metav1.Duration `json:"delayBefore,omitempty"`
+	// Time the dependent groups wait after this group succeeded
+	// +kubebuilder:validation:Optional
+	// +optional
+	DelayAfter *metav1.Duration `json:"delayAfter,omitempty"`
+	// Group waits for the manual approval before starting
+	// +kubebuilder:validation:Optional
+	// +optional
+	PauseBefore bool `json:"pauseBefore,omitempty"`
+	// Approves the start of the paused group
+	// +optional
+	Approved bool `json:"approved,omitempty"`
+
+	// Runtime state of the group, kept in status.groups[] by the operator and filled from there by
+	// MergeStatus. The fields are not part of the spec, see ManagedJobGroupStatus for their meaning.
+	Status           string       `json:"-"`
+	ReadyAt          *metav1.Time `json:"-"`
+	CompletedAt      *metav1.Time `json:"-"`
+	Reason           string       `json:"-"`
+	StatusPage       string       `json:"-"`
+	ApprovalConsumed bool         `json:"-"`
+
+	DeprecatedGroupState `json:",inline"`
+}
+
+// ManagedJobImpersonation is the user the jobs of the group are created as
+type ManagedJobImpersonation struct {
+	// ServiceAccount of the workflow namespace to impersonate, the creator of the workflow recorded by the
+	// webhook when empty
+	// +kubebuilder:validation:Optional
+	// +optional
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+}
+
+// ManagedJobMaintenanceWindow is a recurring period during which no new jobs are started
+type ManagedJobMaintenanceWindow struct {
+	// Days of the week the window starts on, every day when empty
 	// +kubebuilder:validation:Optional
-	// +kubebuilder:default=pending
-	Status string `json:"status"`
+	// +optional
+	Days []MaintenanceWindowDay `json:"days,omitempty"`
+	// Start of the window as HH:MM
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	Start string `json:"start"`
+	// How long the window lasts, up to a week
+	Duration metav1.Duration `json:"duration"`
+	// IANA time zone of the start, e.g. Europe/Warsaw, UTC when empty
+	// +kubebuilder:validation:Optional
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=Mon;Tue;Wed;Thu;Fri;Sat;Sun
+type MaintenanceWindowDay string
+
+// ManagedJobFailurePolicy defines how many failed jobs the group tolerates
+type ManagedJobFailurePolicy struct {
+	// Number or percentage (e.g. "10%") of the group jobs which may fail with the group still succeeding
+	// +kubebuilder:validation:XIntOrString
+	// +kubebuilder:validation:Pattern=`^([0-9]+|[0-9]+%)$`
+	MaxFailed intstr.IntOrString `json:"maxFailed"`
 }
 
 type ManagedJobParameters struct {
@@ -85,8 +354,8 @@ type ManagedJobParameters struct {
 	VolumeMounts []corev1.VolumeMount `json:"volumeMount,omitempty"`
 	// +kubebuilder:validation:Optional
 	ServiceAccount string `json:"serviceAccount,omitempty"`
+	// Restart policy of the job pods, inherited from the upper levels, OnFailure when not set at any level
 	// +kubebuilder:validation:Optional
-	// +kubebuilder:default=OnFailure
 	RestartPolicy string `json:"restartPolicy,omitempty"`
 	// +kubebuilder:validation:Optional
 	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
@@ -96,6 +365,159 @@ type ManagedJobParameters struct {
 	Labels map[string]string `json:"labels,omitempty"`
 	// +kubebuilder:validation:Optional
 	Annotations map[string]string `json:"annotations,omitempty"`
+	// +kubebuilder:validation:Optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// Working directory of the job container, the image one when not set
+	// +kubebuilder:validation:Optional
+	// +optional
+	WorkingDir string `json:"workingDir,omitempty"`
+	// User the job container runs as, the image one when not set
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	RunAsUser *int64 `json:"runAsUser,omitempty"`
+	// Group the job container runs as, the image one when not set
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	RunAsGroup *int64 `json:"runAsGroup,omitempty"`
+	// Short-lived credentials issued for every run of the job and revoked once it completes
+	// +kubebuilder:validation:Optional
+	// +optional
+	SecretsFrom *ManagedJobSecretsFrom `json:"secretsFrom,omitempty"`
+}
+
+// ManagedJobWorkflowDependency references another workflow
+type ManagedJobWorkflowDependency struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Namespace of the workflow, the namespace of the dependent one when not set
+	// +kubebuilder:validation:Optional
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// ManagedJobSecretsFrom is the source of the credentials issued per run of the job
+type ManagedJobSecretsFrom struct {
+	// +kubebuilder:validation:Required
+	Vault *ManagedJobVaultSecret `json:"vault"`
+}
+
+// ManagedJobVaultSecret is read from Vault logged in with the kubernetes auth method as the service account
+// of the job, the lease of the dynamic secret is revoked when the job completes
+type ManagedJobVaultSecret struct {
+	// Role of the kubernetes auth method bound to the service account of the job
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Role string `json:"role"`
+	// Path of the secret, e.g. database/creds/etl
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Path string `json:"path"`
+	// Directory the fields of the secret are mounted in as files, /vault/secrets when not set
+	// +kubebuilder:validation:Optional
+	// +optional
+	MountPath string `json:"mountPath,omitempty"`
+}
+
+// ManagedJobResourcesSummary aggregates the resource requests of the workflow jobs
+type ManagedJobResourcesSummary struct {
+	// Requests of the currently running jobs
+	// +optional
+	Active corev1.ResourceList `json:"active,omitempty"`
+	// Requests of all the jobs in the workflow
+	// +optional
+	Total corev1.ResourceList `json:"total,omitempty"`
+}
+
+// ManagedJobRestartTrigger restarts the workflow when the referenced object changes
+type ManagedJobRestartTrigger struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=ConfigMap;Secret
+	Kind string `json:"kind"`
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Groups to restart, whole workflow is restarted when empty
+	// +kubebuilder:validation:Optional
+	// +optional
+	Groups []string `json:"groups,omitempty"`
+}
+
+// ManagedJobNotifications of the workflow status changes
+type ManagedJobNotifications struct {
+	// +kubebuilder:validation:Optional
+	// +optional
+	Webhooks []ManagedJobWebhook `json:"webhooks,omitempty"`
+}
+
+// ManagedJobWebhook receives the status changes of the workflow as JSON POST requests
+type ManagedJobWebhook struct {
+	// URL of the http or https endpoint
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	URL string `json:"url"`
+	// Key of the secret in the namespace of the workflow signing the requests with HMAC-SHA256,
+	// the requests are not signed when it's not set
+	// +kubebuilder:validation:Optional
+	// +optional
+	SigningSecret *corev1.SecretKeySelector `json:"signingSecret,omitempty"`
+	// Statuses of the workflow sent to the endpoint, all the changes are sent when empty
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:items:Enum=pending;queued;suspended;running;succeeded;failed
+	// +optional
+	Statuses []string `json:"statuses,omitempty"`
+	// Retries of the failed delivery, the last failure is recorded as the NotificationFailed event
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=10
+	// +kubebuilder:default=3
+	// +optional
+	Retries int32 `json:"retries,omitempty"`
+}
+
+// ManagedJobGraphNode is a group or a job of the resolved dependency graph of the workflow
+type ManagedJobGraphNode struct {
+	// Name of the group or the generated name of the job, the dependencies refer to the nodes by it
+	Name string `json:"name"`
+	// +kubebuilder:validation:Enum=Group;Job
+	Kind string `json:"kind"`
+	// Group of the job
+	// +optional
+	Group string `json:"group,omitempty"`
+	// Name of the job within its group
+	// +optional
+	Job string `json:"job,omitempty"`
+	// Nodes which have to succeed before this one starts, the implicit dependencies included
+	// +optional
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// ManagedJobRunRecord describes a single run of the workflow
+type ManagedJobRunRecord struct {
+	// Identifier of the run, the Jobs and pods created in the run carry it in the run-id label
+	// +optional
+	ID        string      `json:"id,omitempty"`
+	StartedAt metav1.Time `json:"startedAt"`
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Object which triggered the run, e.g. ConfigMap/app-config
+	// +optional
+	TriggeredBy string `json:"triggeredBy,omitempty"`
+	// +optional
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	// +optional
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+	// Final status of the run, empty while it is running
+	// +optional
+	Status string `json:"status,omitempty"`
+	// Location of the run report, ConfigMap/<name> or the object storage URL
+	// +optional
+	Report string `json:"report,omitempty"`
+	// Approximate cost of the run, the sum of the estimated costs of its jobs
+	// +optional
+	EstimatedCost string `json:"estimatedCost,omitempty"`
 }
 
 // ManagedJobSpec defines the desired state of ManagedJob
@@ -104,24 +526,259 @@ type ManagedJobSpec struct {
 	// +kubebuilder:default=1
 	// +kubebuilder:validation:Minimum=1
 	Retries int `json:"retries"`
+	// Human readable description of the workflow, shown by the kubectl plugin
+	// +kubebuilder:validation:Optional
+	// +optional
+	Description string `json:"description,omitempty"`
+	// Image of the workflow jobs which do not set their own or inherit the one of their group
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MinLength=5
+	// +optional
+	Image string `json:"image,omitempty"`
 	// +kubebuilder:validation:Required
 	// +kubebuilder:validation:MinItems=1
 	Groups []*ManagedJobGroup `json:"groups"`
+	// Names of the groups to run, the other groups are skipped and count as succeeded for their dependents.
+	// All the groups run when empty.
+	// +kubebuilder:validation:Optional
+	// +optional
+	EnabledGroups []string `json:"enabledGroups,omitempty"`
+	// No new jobs are started while the workflow is suspended, the running ones finish
+	// +kubebuilder:validation:Optional
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+	// Holds the workflow before its first run until the start action of the action annotation releases it
+	// +kubebuilder:validation:Optional
+	// +optional
+	StartSuspended bool `json:"startSuspended,omitempty"`
+	// Marks the workflow as the template of the sub-workflows, the operator never runs it on its own and
+	// the jobs of type workflow may reference the templates only
+	// +kubebuilder:validation:Optional
+	// +optional
+	Template bool `json:"template,omitempty"`
+	// Workflows which have to succeed before the run of this one starts
+	// +kubebuilder:validation:Optional
+	// +optional
+	DependsOn []ManagedJobWorkflowDependency `json:"dependsOn,omitempty"`
 	// +kubebuilder:validation:Optional
 	Params ManagedJobParameters `json:"params"`
+	// +kubebuilder:validation:Optional
+	// +optional
+	RestartOn []ManagedJobRestartTrigger `json:"restartOn,omitempty"`
+	// No new jobs are started during the windows, on top of the windows configured for the whole operator
+	// +kubebuilder:validation:Optional
+	// +optional
+	MaintenanceWindows []ManagedJobMaintenanceWindow `json:"maintenanceWindows,omitempty"`
+	// Endpoints notified about the status changes of the workflow
+	// +kubebuilder:validation:Optional
+	// +optional
+	Notifications *ManagedJobNotifications `json:"notifications,omitempty"`
+	// What to do with the Jobs labelled as children of the workflow which do not match any of its jobs,
+	// e.g. left over from a renamed group or copied by hand: Report, Delete or Adopt
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Report;Delete;Adopt
+	// +kubebuilder:default=Report
+	// +optional
+	StrayJobPolicy string `json:"strayJobPolicy,omitempty"`
+	// Seconds the run may take from its start, the jobs of the run still going after the deadline are
+	// aborted and the workflow gets the TimedOut condition. The runs are not limited when it's not set.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+	// CEL expression deciding if the finished run succeeded instead of all the groups having to succeed,
+	// e.g. groups.shards.succeeded * 10 >= groups.shards.total * 9 && groups.report.status == 'succeeded'
+	// +kubebuilder:validation:Optional
+	// +optional
+	SuccessCriteria string `json:"successCriteria,omitempty"`
+
+	// Runtime state of the workflow, kept in the status by the operator and filled from there by
+	// MergeStatus. The fields are not part of the spec, see ManagedJobStatus for their meaning.
+	ObservedTriggers    map[string]string     `json:"-"`
+	StrayJobs           []string              `json:"-"`
+	RunHistory          []ManagedJobRunRecord `json:"-"`
+	EstimatedCompletion *metav1.Time          `json:"-"`
+	Progress            string                `json:"-"`
+	FailedGroup         string                `json:"-"`
+	FailureDigest       string                `json:"-"`
+	Duration            string                `json:"-"`
+	EstimatedCost       string                `json:"-"`
+	QueuePosition       int                   `json:"-"`
+	ReconcileErrors     int                   `json:"-"`
+	Conditions          []metav1.Condition    `json:"-"`
+	Graph               []ManagedJobGraphNode `json:"-"`
+	Warnings            []string              `json:"-"`
+	MetricsDeletedAt    *metav1.Time          `json:"-"`
+	StartReleased       bool                  `json:"-"`
+
+	DeprecatedWorkflowState `json:",inline"`
+}
+
+// ManagedJobStatus is the runtime state of the workflow, written by the operator only
+type ManagedJobStatus struct {
+	// Status of the workflow: pending, queued, suspended, running, succeeded, failed or invalid
+	// +optional
+	Phase string `json:"phase,omitempty"`
+	// Runtime state of the groups and their jobs
+	// +optional
+	Groups []ManagedJobGroupStatus `json:"groups,omitempty"`
+	// Requests of the running and of all the jobs of the workflow, written by the operator into the status
+	// directly like the phase
+	// +optional
+	AggregatedResources *ManagedJobResourcesSummary `json:"aggregatedResources,omitempty"`
+	// Checksums of the objects referenced by restartOn, keyed by kind/name
+	// +optional
+	ObservedTriggers map[string]string `json:"observedTriggers,omitempty"`
+	// Names of the stray Jobs found by the operator
+	// +optional
+	StrayJobs []string `json:"strayJobs,omitempty"`
+	// Records of the last runs of the workflow
+	// +optional
+	RunHistory []ManagedJobRunRecord `json:"runHistory,omitempty"`
+	// Estimated completion of the running workflow, based on the durations of the previous successful runs
+	// +optional
+	EstimatedCompletion *metav1.Time `json:"estimatedCompletion,omitempty"`
+	// Finished jobs of the current run out of all the jobs, e.g. 3/7
+	// +optional
+	Progress string `json:"progress,omitempty"`
+	// First group which failed in the current run
+	// +optional
+	FailedGroup string `json:"failedGroup,omitempty"`
+	// Failed and aborted jobs of the failed run with their reasons, capped at 1024 characters
+	// +optional
+	FailureDigest string `json:"failureDigest,omitempty"`
+	// Duration of the completed run, empty while it's running
+	// +optional
+	Duration string `json:"duration,omitempty"`
+	// Approximate cost of the current run, the sum of the estimated costs of the finished jobs
+	// +optional
+	EstimatedCost string `json:"estimatedCost,omitempty"`
+	// Position of the workflow waiting for a free slot of the namespace, empty when it's not queued
+	// +optional
+	QueuePosition int `json:"queuePosition,omitempty"`
+	// Consecutive failed reconciles of the workflow, reset by the first successful one
+	// +optional
+	ReconcileErrors int `json:"reconcileErrors,omitempty"`
+	// Conditions of the workflow, ReconcileDegraded is true while the reconcile errors exceed the operator's budget
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Resolved dependency graph of the workflow in the topological order, the jobs start only once their
+	// group does on top of their own dependencies
+	// +optional
+	Graph []ManagedJobGraphNode `json:"graph,omitempty"`
+	// Warnings of the params compiled for the jobs, e.g. the values of the groups overridden by their jobs
+	// or the mounts of the missing volumes
+	// +optional
+	Warnings []string `json:"warnings,omitempty"`
+	// When the series pushed to the pushgateway by the jobs of the finished run were deleted, they are not
+	// deleted again until the next run finishes
+	// +optional
+	MetricsDeletedAt *metav1.Time `json:"metricsDeletedAt,omitempty"`
+	// The workflow created with startSuspended was released by the start action, it's not held anymore
+	// +optional
+	StartReleased bool `json:"startReleased,omitempty"`
+}
+
+// ManagedJobGroupStatus is the runtime state of the group
+type ManagedJobGroupStatus struct {
+	Name string `json:"name"`
+	// +optional
+	Status string `json:"status,omitempty"`
+	// When the dependencies of the group were met
+	// +optional
+	ReadyAt *metav1.Time `json:"readyAt,omitempty"`
+	// When the group reached its final status
+	// +optional
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+	// Why the group is kept in its current status
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// ConfigMap keeping the runtime details of the group jobs once the workflow grew too large
+	// +optional
+	StatusPage string `json:"statusPage,omitempty"`
+	// The approval in the spec was used by a previous run, the group waits for a new one. The operator
+	// forgets it once the approval is withdrawn from the spec.
+	// +optional
+	ApprovalConsumed bool `json:"approvalConsumed,omitempty"`
+	// Statuses of the dependencies of the group by their names, the implicit ones included
+	// +optional
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+	// +optional
+	Jobs []ManagedJobJobStatus `json:"jobs,omitempty"`
+}
+
+// ManagedJobJobStatus is the runtime state of the job
+type ManagedJobJobStatus struct {
+	Name string `json:"name"`
+	// +optional
+	Status string `json:"status,omitempty"`
+	// Why the pending job has not started: Blocked by its dependencies or the ones of its group,
+	// Queued when it waits for the capacity, quota, approval, delay or the maintenance window.
+	// For the finished jobs why they failed, were aborted or skipped, e.g. BackoffLimitExceeded.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Number of the Jobs created for the job over the runs of the workflow, the Job created last carries
+	// it in its attempt annotation
+	// +optional
+	Attempt int32 `json:"attempt,omitempty"`
+	// What the Jobs of the previous and the current attempt ran with, the last two attempts are kept
+	// +optional
+	AttemptSnapshots []ManagedJobAttemptSnapshot `json:"attemptSnapshots,omitempty"`
+	// Changes of the current attempt since the previous one, e.g. "image digest sha256:1a2b -> sha256:3c4d,
+	// env DB_HOST changed", none when the retry ran with the same image digest, environment and params
+	// +optional
+	AttemptChanges string `json:"attemptChanges,omitempty"`
+	// Location of the logs of the finished job in the object storage of the operator
+	// +optional
+	ArchivedLogs string `json:"archivedLogs,omitempty"`
+	// Logs of the finished job are being uploaded, the upload is started again after a restart of the
+	// operator until it succeeds or runs out of retries
+	// +optional
+	ArchivePending bool `json:"archivePending,omitempty"`
+	// Hash of the pod spec the job was created with
+	// +optional
+	ResolvedSpecHash string `json:"resolvedSpecHash,omitempty"`
+	// Summary of the fan-out indexes, e.g. "8/10 succeeded, failed: 3,7"
+	// +optional
+	FanOutSummary string `json:"fanOutSummary,omitempty"`
+	// Fields of the live Job which were changed outside of the operator, e.g. "suspend, parallelism"
+	// +optional
+	Drift string `json:"drift,omitempty"`
+	// When the registry credentials were refreshed after the image pull of the job was denied
+	// +optional
+	ImagePullRefreshedAt *metav1.Time `json:"imagePullRefreshedAt,omitempty"`
+	// Time from the creation of the Job to its first running pod
+	// +optional
+	SchedulingLatency *metav1.Duration `json:"schedulingLatency,omitempty"`
+	// Why the pods of the job were not running within the slow scheduling threshold of the operator
+	// +optional
+	SlowScheduling string `json:"slowScheduling,omitempty"`
+	// Approximate cost of the job run, from its requests, duration and the prices configured for the operator
+	// +optional
+	EstimatedCost string `json:"estimatedCost,omitempty"`
+	// The outcome in the spec was set for a previous run, the manual job waits for a new one. The operator
+	// forgets it once the outcome is withdrawn from the spec.
+	// +optional
+	OutcomeConsumed bool `json:"outcomeConsumed,omitempty"`
+	// Statuses of the dependencies of the job by their names, the implicit ones included
+	// +optional
+	Dependencies map[string]string `json:"dependencies,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Progress",type=string,JSONPath=`.status.progress`
+// +kubebuilder:printcolumn:name="Failed-Group",type=string,JSONPath=`.status.failedGroup`
+// +kubebuilder:printcolumn:name="Duration",type=string,JSONPath=`.status.duration`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 // ManagedJob is the Schema for the managedjobs API
 type ManagedJob struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec ManagedJobSpec `json:"spec,omitempty"`
-	// +kubebuilder:validation:Optional
-	// +kubebuilder:default=pending
-	Status string `json:"status"`
+	Spec   ManagedJobSpec   `json:"spec,omitempty"`
+	Status ManagedJobStatus `json:"status,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -0,0 +1,411 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// DefaultRestartPolicy is used for the jobs with the restart policy not set at any level
+const DefaultRestartPolicy = corev1.RestartPolicyOnFailure
+
+// MaxWorkflowSize is the largest serialized workflow accepted on create, etcd stores objects up to 1.5MiB
+// and the rest is left for the runtime state written by the operator
+const MaxWorkflowSize = 1024 * 1024
+
+// SetupWebhookWithManager registers the webhooks of the ManagedJob, the mutating one records its creator
+// and lets only the operator, authenticated as operatorUsername, create the sub-workflows. The validating
+// one lets the users approve and take the actions on the workflows only when authorize allows them, nil
+// skips the check. The hosts of the new notification webhooks have to be allowed by targets. The checks run
+// once the workflow passes its own validation, e.g. the ones of the dependency graph which can not live in
+// this package.
+func (r *ManagedJob) SetupWebhookWithManager(mgr ctrl.Manager, operatorUsername string, authorize RuntimeAuthorizer, targets NotificationTargets, checks ...func(*ManagedJob) field.ErrorList) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithDefaulter(&managedJobDefaulter{operator: operatorUsername}).
+		WithValidator(&managedJobValidator{operator: operatorUsername, authorize: authorize, targets: targets, checks: checks}).
+		Complete()
+}
+
+// managedJobValidator runs the validation of the ManagedJob followed by the checks given on setup, the
+// runtime changes of the users other than the operator are authorized with authorize
+type managedJobValidator struct {
+	operator  string
+	authorize RuntimeAuthorizer
+	targets   NotificationTargets
+	checks    []func(*ManagedJob) field.ErrorList
+}
+
+var _ admission.CustomValidator = &managedJobValidator{}
+
+func asManagedJob(obj runtime.Object) (*ManagedJob, error) {
+	mj, ok := obj.(*ManagedJob)
+	if !ok {
+		return nil, fmt.Errorf("expected a ManagedJob, got %T", obj)
+	}
+	return mj, nil
+}
+
+func (v *managedJobValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	mj, err := asManagedJob(obj)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.authorizeRuntimeChanges(ctx, mj, nil); err != nil {
+		return nil, err
+	}
+	warnings, err := mj.ValidateCreate()
+	return warnings, v.check(mj, nil, err)
+}
+
+func (v *managedJobValidator) ValidateUpdate(ctx context.Context, oldObj runtime.Object, newObj runtime.Object) (admission.Warnings, error) {
+	mj, err := asManagedJob(newObj)
+	if err != nil {
+		return nil, err
+	}
+	old, err := asManagedJob(oldObj)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.authorizeRuntimeChanges(ctx, mj, old); err != nil {
+		return nil, err
+	}
+	warnings, err := mj.ValidateUpdate(oldObj)
+	return warnings, v.check(mj, old, err)
+}
+
+func (v *managedJobValidator) ValidateDelete(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	mj, err := asManagedJob(obj)
+	if err != nil {
+		return nil, err
+	}
+	return mj.ValidateDelete()
+}
+
+// authorizeRuntimeChanges checks the approvals and the actions of the request, old is nil on create
+func (v *managedJobValidator) authorizeRuntimeChanges(ctx context.Context, mj, old *ManagedJob) error {
+	if v.authorize == nil {
+		return nil
+	}
+	request, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	if v.operator != "" && request.UserInfo.Username == v.operator {
+		return nil
+	}
+	return authorizeRuntimeChanges(ctx, v.authorize, request.UserInfo, mj, old)
+}
+
+// check runs the extra checks of the workflow which passed its own validation, old is nil on create
+func (v *managedJobValidator) check(mj, old *ManagedJob, err error) error {
+	if err != nil {
+		return err
+	}
+	errs := v.targets.ValidateWebhooks(mj, old)
+	for _, check := range v.checks {
+		errs = append(errs, check(mj)...)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("ManagedJob").GroupKind(), mj.Name, errs)
+}
+
+//+kubebuilder:webhook:path=/validate-jobsmanager-raczylo-com-v1beta1-managedjob,mutating=false,failurePolicy=fail,sideEffects=None,groups=jobsmanager.raczylo.com,resources=managedjobs,verbs=create;update,versions=v1beta1,name=vmanagedjob.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &ManagedJob{}
+
+// ValidateCreate implements webhook.Validator
+func (r *ManagedJob) ValidateCreate() (admission.Warnings, error) {
+	if errs := r.ValidateSize(); len(errs) > 0 {
+		return nil, apierrors.NewInvalid(GroupVersion.WithKind("ManagedJob").GroupKind(), r.Name, errs)
+	}
+	return append(r.specStatusWarnings(nil), r.dependencyStatusWarnings()...), r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator
+func (r *ManagedJob) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
+	oldWorkflow, _ := old.(*ManagedJob)
+	return r.specStatusWarnings(oldWorkflow), r.validate()
+}
+
+// ValidateDelete implements webhook.Validator
+func (r *ManagedJob) ValidateDelete() (admission.Warnings, error) {
+	return nil, nil
+}
+
+// specStatusWarnings reports the deprecated statuses of the groups and jobs set in the spec by the client,
+// they're kept in the schema so the API server passes them on. Only the ones changed from the old workflow
+// are reported on update, old is nil on create.
+func (r *ManagedJob) specStatusWarnings(old *ManagedJob) admission.Warnings {
+	previous := map[string]string{}
+	if old != nil {
+		for _, group := range old.Spec.Groups {
+			previous[group.Name] = group.DeprecatedGroupState.Status
+			for _, job := range group.Jobs {
+				previous[group.Name+"/"+job.Name] = job.DeprecatedJobState.Status
+			}
+		}
+	}
+	warnings := admission.Warnings{}
+	warn := func(path *field.Path, key string, status string) {
+		if normalizeStatus(status) != statusPending && status != previous[key] {
+			warnings = append(warnings, path.String()+" is deprecated and set by the operator only, the value is ignored")
+		}
+	}
+	for i, group := range r.Spec.Groups {
+		groupPath := field.NewPath("spec", "groups").Index(i)
+		warn(groupPath.Child("status"), group.Name, group.DeprecatedGroupState.Status)
+		for j, job := range group.Jobs {
+			warn(groupPath.Child("jobs").Index(j).Child("status"), group.Name+"/"+job.Name, job.DeprecatedJobState.Status)
+		}
+	}
+	if len(warnings) == 0 {
+		return nil
+	}
+	return warnings
+}
+
+func (r *ManagedJob) validate() error {
+	errs := r.Validate()
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("ManagedJob").GroupKind(), r.Name, errs)
+}
+
+// ValidateSize rejects the workflow too large to be stored together with its runtime state. It's only checked
+// on create, the updates of the operator grow the workflow and the API server rejects the ones over its limit.
+func (r *ManagedJob) ValidateSize() field.ErrorList {
+	data, err := json.Marshal(r)
+	if err != nil || len(data) <= MaxWorkflowSize {
+		return nil
+	}
+	return field.ErrorList{field.Forbidden(field.NewPath("spec"),
+		fmt.Sprintf("workflow takes %d bytes, more than the limit of %d bytes - split it into sub-workflows", len(data), MaxWorkflowSize))}
+}
+
+// Validate returns all the problems the webhook rejects the workflow for
+func (r *ManagedJob) Validate() field.ErrorList {
+	errs := ValidateParameters(r.Spec.Params, field.NewPath("spec", "params"))
+	for i, window := range r.Spec.MaintenanceWindows {
+		errs = append(errs, ValidateMaintenanceWindow(window, field.NewPath("spec", "maintenanceWindows").Index(i))...)
+	}
+	for i, name := range r.Spec.EnabledGroups {
+		if r.Spec.group(name) == nil {
+			errs = append(errs, field.NotFound(field.NewPath("spec", "enabledGroups").Index(i), name))
+		}
+	}
+	dependsOn := map[string]bool{}
+	for i, dependency := range r.Spec.DependsOn {
+		key := dependency.Namespace + "/" + dependency.Name
+		if dependency.Namespace == "" {
+			key = r.Namespace + "/" + dependency.Name
+		}
+		switch {
+		case key == r.Namespace+"/"+r.Name:
+			errs = append(errs, field.Invalid(field.NewPath("spec", "dependsOn").Index(i), dependency.Name, "workflow can not depend on itself"))
+		case dependsOn[key]:
+			errs = append(errs, field.Duplicate(field.NewPath("spec", "dependsOn").Index(i), key))
+		}
+		dependsOn[key] = true
+	}
+	errs = append(errs, ValidateSuccessCriteria(r.Spec.SuccessCriteria, field.NewPath("spec", "successCriteria"))...)
+	if r.Spec.Notifications != nil {
+		for i, webhook := range r.Spec.Notifications.Webhooks {
+			errs = append(errs, validateWebhook(webhook, field.NewPath("spec", "notifications", "webhooks").Index(i))...)
+		}
+	}
+	for i, group := range r.Spec.Groups {
+		groupPath := field.NewPath("spec", "groups").Index(i)
+		errs = append(errs, ValidateParameters(group.Params, groupPath.Child("params"))...)
+		errs = append(errs, validateInheritedParameters(group.Params, groupPath.Child("params"), r.Spec.Params)...)
+		errs = append(errs, ValidateTimeConstraints(group.NotBefore, group.NotAfter, groupPath)...)
+		for j, job := range group.Jobs {
+			jobPath := groupPath.Child("jobs").Index(j)
+			errs = append(errs, ValidateParameters(job.Params, jobPath.Child("params"))...)
+			errs = append(errs, validateInheritedParameters(job.Params, jobPath.Child("params"), r.Spec.Params, group.Params)...)
+			errs = append(errs, ValidateParamsPatches(job.ParamsPatches, jobPath.Child("paramsPatches"))...)
+			errs = append(errs, validateSuccessExitCodes(job, jobPath.Child("successExitCodes"))...)
+			errs = append(errs, ValidateTimeConstraints(job.NotBefore, job.NotAfter, jobPath)...)
+			if job.Synchronization != nil && group.Synchronization != nil &&
+				job.Synchronization.MutexObjectName(r.Namespace) == group.Synchronization.MutexObjectName(r.Namespace) {
+				errs = append(errs, field.Forbidden(jobPath.Child("synchronization"), "the group already holds the mutex while the job runs"))
+			}
+			if job.Outcome != "" && job.Type != JobTypeManual {
+				errs = append(errs, field.Forbidden(jobPath.Child("outcome"), "only the manual jobs are completed by hand"))
+			}
+			if job.Type == JobTypeWorkflow && (job.Workflow == nil || job.Workflow.Name == "") {
+				errs = append(errs, field.Required(jobPath.Child("workflow", "name"), "jobs of type workflow reference the template they run"))
+			}
+			if job.Type == JobTypeWorkflow && job.Workflow != nil && job.Workflow.Name == r.Name {
+				errs = append(errs, field.Invalid(jobPath.Child("workflow", "name"), job.Workflow.Name, "workflow can not run itself"))
+			}
+			if runsImage(job) && r.Spec.JobImage(group, job) == "" {
+				errs = append(errs, field.Required(jobPath.Child("image"), "image has to be set on the job, its group or the workflow"))
+			}
+		}
+	}
+	return errs
+}
+
+func validateWebhook(webhook ManagedJobWebhook, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	endpoint, err := url.Parse(webhook.URL)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		errs = append(errs, field.Invalid(path.Child("url"), webhook.URL, "has to be an absolute http or https URL"))
+	}
+	if webhook.SigningSecret != nil && (webhook.SigningSecret.Name == "" || webhook.SigningSecret.Key == "") {
+		errs = append(errs, field.Required(path.Child("signingSecret"), "name and key of the secret are required"))
+	}
+	return errs
+}
+
+func validateSuccessExitCodes(job *ManagedJobDefinition, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if len(job.SuccessExitCodes) > 0 && job.FanOut != nil {
+		errs = append(errs, field.Forbidden(path, "not supported by the fan-out jobs"))
+	}
+	for i, code := range job.SuccessExitCodes {
+		if code < 0 || code > 255 {
+			errs = append(errs, field.Invalid(path.Index(i), code, "exit codes are between 0 and 255"))
+		}
+	}
+	return errs
+}
+
+// ValidateParameters checks the parameters of a single level, empty values are inherited from the upper levels
+func ValidateParameters(params ManagedJobParameters, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	switch corev1.RestartPolicy(params.RestartPolicy) {
+	case "", corev1.RestartPolicyOnFailure, corev1.RestartPolicyNever:
+	default:
+		// Jobs do not accept Always
+		errs = append(errs, field.NotSupported(path.Child("restartPolicy"), params.RestartPolicy,
+			[]string{string(corev1.RestartPolicyOnFailure), string(corev1.RestartPolicyNever)}))
+	}
+	if params.WorkingDir != "" && !strings.HasPrefix(params.WorkingDir, "/") {
+		errs = append(errs, field.Invalid(path.Child("workingDir"), params.WorkingDir, "has to be an absolute path"))
+	}
+	if params.SecretsFrom != nil && params.SecretsFrom.Vault != nil {
+		if mountPath := params.SecretsFrom.Vault.MountPath; mountPath != "" && !strings.HasPrefix(mountPath, "/") {
+			errs = append(errs, field.Invalid(path.Child("secretsFrom", "vault", "mountPath"), mountPath, "has to be an absolute path"))
+		}
+	}
+
+	volumeNames := map[string]bool{}
+	for i, volume := range params.Volumes {
+		if volumeNames[volume.Name] {
+			errs = append(errs, field.Duplicate(path.Child("volumes").Index(i).Child("name"), volume.Name))
+		}
+		volumeNames[volume.Name] = true
+	}
+	mountPaths := map[string]bool{}
+	for i, mount := range params.VolumeMounts {
+		if mountPaths[mount.MountPath] {
+			errs = append(errs, field.Duplicate(path.Child("volumeMount").Index(i).Child("mountPath"), mount.MountPath))
+		}
+		mountPaths[mount.MountPath] = true
+	}
+	return errs
+}
+
+// validateInheritedParameters rejects the volumes and mounts which reuse the name or the path
+// from the upper levels for something else, identical ones are merged when compiling the parameters
+func validateInheritedParameters(params ManagedJobParameters, path *field.Path, inherited ...ManagedJobParameters) field.ErrorList {
+	errs := field.ErrorList{}
+	for i, volume := range params.Volumes {
+		for _, upper := range inherited {
+			for _, upperVolume := range upper.Volumes {
+				if upperVolume.Name == volume.Name && !equality.Semantic.DeepEqual(upperVolume.VolumeSource, volume.VolumeSource) {
+					errs = append(errs, field.Invalid(path.Child("volumes").Index(i), volume.Name, "volume with the same name and a different source is defined on the upper level"))
+				}
+			}
+		}
+	}
+	for i, mount := range params.VolumeMounts {
+		for _, upper := range inherited {
+			for _, upperMount := range upper.VolumeMounts {
+				if upperMount.MountPath == mount.MountPath && !equality.Semantic.DeepEqual(upperMount, mount) {
+					errs = append(errs, field.Invalid(path.Child("volumeMount").Index(i), mount.MountPath, "path is mounted differently on the upper level"))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// ValidateParamsPatches checks the syntax of the patch operations, whether they apply
+// is only known once the params are compiled
+func ValidateParamsPatches(patches []ManagedJobParamsPatch, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	for i, patch := range patches {
+		patchPath := path.Index(i)
+		if !strings.HasPrefix(patch.Path, "/") {
+			errs = append(errs, field.Invalid(patchPath.Child("path"), patch.Path, "must be a JSON pointer starting with /"))
+		}
+		switch patch.Op {
+		case "move", "copy":
+			if !strings.HasPrefix(patch.From, "/") {
+				errs = append(errs, field.Invalid(patchPath.Child("from"), patch.From, "must be a JSON pointer starting with /"))
+			}
+		case "add", "replace", "test":
+			if patch.Value == nil {
+				errs = append(errs, field.Required(patchPath.Child("value"), "required by the "+patch.Op+" operation"))
+			}
+		case "remove":
+		default:
+			errs = append(errs, field.NotSupported(patchPath.Child("op"), patch.Op, []string{"add", "remove", "replace", "move", "copy", "test"}))
+		}
+	}
+	return errs
+}
+
+// ValidateMaintenanceWindow checks the days, the start, the duration and the time zone of the window
+func ValidateMaintenanceWindow(window ManagedJobMaintenanceWindow, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	for i, day := range window.Days {
+		if _, found := day.Weekday(); !found {
+			errs = append(errs, field.NotSupported(path.Child("days").Index(i), day, []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}))
+		}
+	}
+	if _, err := time.Parse("15:04", window.Start); err != nil {
+		errs = append(errs, field.Invalid(path.Child("start"), window.Start, "must be HH:MM"))
+	}
+	if window.Duration.Duration <= 0 || window.Duration.Duration > MaxMaintenanceWindowDuration {
+		errs = append(errs, field.Invalid(path.Child("duration"), window.Duration.Duration.String(), "must be positive and at most 168h"))
+	}
+	if _, err := time.LoadLocation(window.TimeZone); err != nil {
+		errs = append(errs, field.Invalid(path.Child("timeZone"), window.TimeZone, err.Error()))
+	}
+	return errs
+}
@@ -0,0 +1,437 @@
+package v1beta1
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestValidateRestartPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		workflow ManagedJob
+		errors   []string
+	}{
+		{
+			name:     "not set",
+			workflow: ManagedJob{Spec: ManagedJobSpec{Groups: []*ManagedJobGroup{{Name: "g", Jobs: []*ManagedJobDefinition{{Name: "j", Image: "busybox"}}}}}},
+		},
+		{
+			name: "valid on all levels",
+			workflow: ManagedJob{Spec: ManagedJobSpec{
+				Image:  "busybox",
+				Params: ManagedJobParameters{RestartPolicy: "Never"},
+				Groups: []*ManagedJobGroup{{
+					Name:   "g",
+					Params: ManagedJobParameters{RestartPolicy: "OnFailure"},
+					Jobs:   []*ManagedJobDefinition{{Name: "j", Params: ManagedJobParameters{RestartPolicy: "Never"}}},
+				}},
+			}},
+		},
+		{
+			name: "invalid on each level",
+			workflow: ManagedJob{Spec: ManagedJobSpec{
+				Params: ManagedJobParameters{RestartPolicy: "Always"},
+				Groups: []*ManagedJobGroup{{
+					Name:   "g",
+					Params: ManagedJobParameters{RestartPolicy: "onfailure"},
+					Jobs:   []*ManagedJobDefinition{{Name: "j"}, {Name: "k", Params: ManagedJobParameters{RestartPolicy: "Sometimes"}}},
+				}},
+			}},
+			errors: []string{"spec.params.restartPolicy", "spec.groups[0].params.restartPolicy", "spec.groups[0].jobs[1].params.restartPolicy"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.workflow.ValidateCreate()
+			if len(tt.errors) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected the validation to fail")
+			}
+			for _, path := range tt.errors {
+				if !strings.Contains(err.Error(), path) {
+					t.Errorf("error %q does not mention %s", err.Error(), path)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateVolumes(t *testing.T) {
+	configVolume := func(name, configMap string) corev1.Volume {
+		return corev1.Volume{Name: name, VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: configMap}}}}
+	}
+	tests := []struct {
+		name     string
+		workflow ManagedJob
+		errors   []string
+	}{
+		{
+			name: "same volume repeated on the lower levels",
+			workflow: ManagedJob{Spec: ManagedJobSpec{
+				Image: "busybox",
+				Params: ManagedJobParameters{
+					Volumes:      []corev1.Volume{configVolume("config", "app")},
+					VolumeMounts: []corev1.VolumeMount{{Name: "config", MountPath: "/etc/app"}},
+				},
+				Groups: []*ManagedJobGroup{{
+					Name:   "g",
+					Params: ManagedJobParameters{Volumes: []corev1.Volume{configVolume("config", "app")}},
+					Jobs: []*ManagedJobDefinition{{Name: "j", Params: ManagedJobParameters{
+						Volumes:      []corev1.Volume{configVolume("config", "app")},
+						VolumeMounts: []corev1.VolumeMount{{Name: "config", MountPath: "/etc/app"}},
+					}}},
+				}},
+			}},
+		},
+		{
+			name: "duplicates on the same level",
+			workflow: ManagedJob{Spec: ManagedJobSpec{
+				Params: ManagedJobParameters{
+					Volumes:      []corev1.Volume{configVolume("config", "app"), configVolume("config", "app")},
+					VolumeMounts: []corev1.VolumeMount{{Name: "config", MountPath: "/etc/app"}, {Name: "other", MountPath: "/etc/app"}},
+				},
+			}},
+			errors: []string{"spec.params.volumes[1].name", "spec.params.volumeMount[1].mountPath"},
+		},
+		{
+			name: "conflicts with the upper levels",
+			workflow: ManagedJob{Spec: ManagedJobSpec{
+				Params: ManagedJobParameters{
+					Volumes:      []corev1.Volume{configVolume("config", "app")},
+					VolumeMounts: []corev1.VolumeMount{{Name: "config", MountPath: "/etc/app"}},
+				},
+				Groups: []*ManagedJobGroup{{
+					Name:   "g",
+					Params: ManagedJobParameters{Volumes: []corev1.Volume{configVolume("config", "other")}},
+					Jobs: []*ManagedJobDefinition{{Name: "j", Params: ManagedJobParameters{
+						VolumeMounts: []corev1.VolumeMount{{Name: "config", MountPath: "/etc/app", ReadOnly: true}},
+					}}},
+				}},
+			}},
+			errors: []string{"spec.groups[0].params.volumes[0]", "spec.groups[0].jobs[0].params.volumeMount[0]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.workflow.ValidateCreate()
+			if len(tt.errors) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected the validation to fail")
+			}
+			for _, path := range tt.errors {
+				if !strings.Contains(err.Error(), path) {
+					t.Errorf("error %q does not mention %s", err.Error(), path)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateParamsPatches(t *testing.T) {
+	value := &apiextensionsv1.JSON{Raw: []byte(`"us"`)}
+	errs := ValidateParamsPatches([]ManagedJobParamsPatch{
+		{Op: "replace", Path: "/env/0/value", Value: value},
+		{Op: "remove", Path: "/env/1"},
+		{Op: "copy", From: "/env/0", Path: "/env/-"},
+		{Op: "replace", Path: "env/0/value", Value: value},
+		{Op: "add", Path: "/env/-"},
+		{Op: "move", Path: "/env/0"},
+		{Op: "merge", Path: "/env"},
+	}, field.NewPath("paramsPatches"))
+	got := []string{}
+	for _, err := range errs {
+		got = append(got, err.Field)
+	}
+	expected := []string{"paramsPatches[3].path", "paramsPatches[4].value", "paramsPatches[5].from", "paramsPatches[6].op"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("errors on %v, expected %v", got, expected)
+	}
+}
+
+func TestValidateEnabledGroups(t *testing.T) {
+	mj := &ManagedJob{Spec: ManagedJobSpec{
+		Groups:        []*ManagedJobGroup{{Name: "build"}, {Name: "test"}},
+		EnabledGroups: []string{"test"},
+	}}
+	if err := mj.validate(); err != nil {
+		t.Errorf("expected the known group to be accepted, got %v", err)
+	}
+	mj.Spec.EnabledGroups = append(mj.Spec.EnabledGroups, "deploy")
+	err := mj.validate()
+	if err == nil || !strings.Contains(err.Error(), "spec.enabledGroups[1]") {
+		t.Errorf("expected the unknown group to be rejected, got %v", err)
+	}
+}
+
+func TestValidateSuccessExitCodes(t *testing.T) {
+	job := &ManagedJobDefinition{Name: "sync", Image: "busybox", SuccessExitCodes: []int32{0, 2}}
+	mj := &ManagedJob{Spec: ManagedJobSpec{Groups: []*ManagedJobGroup{{Name: "extract", Jobs: []*ManagedJobDefinition{job}}}}}
+	if errs := mj.Validate(); len(errs) != 0 {
+		t.Errorf("expected the exit codes to be accepted, got %v", errs)
+	}
+	job.SuccessExitCodes = append(job.SuccessExitCodes, 256)
+	job.FanOut = &ManagedJobFanOut{Completions: 2}
+	errs := mj.Validate()
+	if len(errs) != 2 || errs[0].Field != "spec.groups[0].jobs[0].successExitCodes" || errs[1].Field != "spec.groups[0].jobs[0].successExitCodes[2]" {
+		t.Errorf("expected the fan-out and the out of range code rejected, got %v", errs)
+	}
+}
+
+func TestValidateImage(t *testing.T) {
+	mj := &ManagedJob{Spec: ManagedJobSpec{Groups: []*ManagedJobGroup{
+		{Name: "build", Jobs: []*ManagedJobDefinition{{Name: "compile"}, {Name: "notify", Type: "slack"}}},
+		{Name: "test", Image: "golang:1.21", Jobs: []*ManagedJobDefinition{{Name: "unit"}, {Name: "lint", Image: "golangci/golangci-lint"}}},
+	}}}
+	errs := mj.Validate()
+	if len(errs) != 1 || errs[0].Field != "spec.groups[0].jobs[0].image" {
+		t.Errorf("expected only the job without any image rejected, got %v", errs)
+	}
+	mj.Spec.Image = "alpine:3.18"
+	if errs := mj.Validate(); len(errs) != 0 {
+		t.Errorf("expected the workflow image inherited, got %v", errs)
+	}
+	groups := mj.Spec.Groups
+	for _, tt := range []struct {
+		group *ManagedJobGroup
+		job   *ManagedJobDefinition
+		image string
+	}{
+		{groups[0], groups[0].Jobs[0], "alpine:3.18"},
+		{groups[1], groups[1].Jobs[0], "golang:1.21"},
+		{groups[1], groups[1].Jobs[1], "golangci/golangci-lint"},
+	} {
+		if got := mj.Spec.JobImage(tt.group, tt.job); got != tt.image {
+			t.Errorf("JobImage(%s) = %s, want %s", tt.job.Name, got, tt.image)
+		}
+	}
+}
+
+func TestValidateWorkingDir(t *testing.T) {
+	if errs := ValidateParameters(ManagedJobParameters{WorkingDir: "/srv/data"}, field.NewPath("spec", "params")); len(errs) != 0 {
+		t.Errorf("expected the absolute path accepted, got %v", errs)
+	}
+	errs := ValidateParameters(ManagedJobParameters{WorkingDir: "data"}, field.NewPath("spec", "params"))
+	if len(errs) != 1 || errs[0].Field != "spec.params.workingDir" {
+		t.Errorf("expected the relative path rejected, got %v", errs)
+	}
+}
+
+func TestValidateSynchronization(t *testing.T) {
+	job := &ManagedJobDefinition{Name: "schema", Image: "migrate/migrate", Synchronization: &ManagedJobSynchronization{Mutex: "db", Scope: MutexScopeNamespace}}
+	mj := &ManagedJob{Spec: ManagedJobSpec{Groups: []*ManagedJobGroup{
+		{Name: "migrate", Synchronization: &ManagedJobSynchronization{Mutex: "db"}, Jobs: []*ManagedJobDefinition{job}},
+	}}}
+	errs := mj.Validate()
+	if len(errs) != 1 || errs[0].Field != "spec.groups[0].jobs[0].synchronization" {
+		t.Errorf("expected the job waiting for the mutex of its group rejected, got %v", errs)
+	}
+	job.Synchronization.Scope = MutexScopeCluster
+	if errs := mj.Validate(); len(errs) != 0 {
+		t.Errorf("expected the cluster mutex of the job accepted, got %v", errs)
+	}
+}
+
+func TestValidatorChecks(t *testing.T) {
+	called := 0
+	v := &managedJobValidator{checks: []func(*ManagedJob) field.ErrorList{func(mj *ManagedJob) field.ErrorList {
+		called++
+		return field.ErrorList{field.Forbidden(field.NewPath("spec", "groups"), "dependency cycle")}
+	}}}
+	valid := &ManagedJob{Spec: ManagedJobSpec{Image: "busybox", Groups: []*ManagedJobGroup{{Name: "a", Jobs: []*ManagedJobDefinition{{Name: "a"}}}}}}
+	if _, err := v.ValidateCreate(context.Background(), valid); err == nil || !strings.Contains(err.Error(), "dependency cycle") {
+		t.Errorf("expected the error of the check, got %v", err)
+	}
+	invalid := valid.DeepCopy()
+	invalid.Spec.Image = ""
+	if _, err := v.ValidateUpdate(context.Background(), valid, invalid); err == nil || strings.Contains(err.Error(), "dependency cycle") {
+		t.Errorf("expected only the own validation of the workflow reported, got %v", err)
+	}
+	if called != 1 {
+		t.Errorf("expected the checks to run for the valid workflow only, called %d times", called)
+	}
+}
+
+func TestValidateNotificationWebhooks(t *testing.T) {
+	mj := &ManagedJob{Spec: ManagedJobSpec{Image: "busybox", Notifications: &ManagedJobNotifications{Webhooks: []ManagedJobWebhook{
+		{URL: "https://hooks.example.com/jobs"},
+		{URL: "hooks.example.com/jobs"},
+		{URL: "ftp://hooks.example.com"},
+		{URL: "http://hooks.example.com", SigningSecret: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "hooks"}}},
+	}}}}
+	fields := []string{}
+	for _, err := range mj.Validate() {
+		fields = append(fields, err.Field)
+	}
+	expected := []string{"spec.notifications.webhooks[1].url", "spec.notifications.webhooks[2].url", "spec.notifications.webhooks[3].signingSecret"}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected %v, got %v", expected, fields)
+	}
+}
+
+func TestNotificationTargets(t *testing.T) {
+	targets := NotificationTargets{Allowed: []string{"alertmanager.monitoring.svc", "*.hooks.corp", "10.20.0.0/16"}}
+	for host, allowed := range map[string]bool{
+		"hooks.example.com":             true,
+		"203.0.113.10":                  true,
+		"169.254.169.254":               false,
+		"127.0.0.1":                     false,
+		"::1":                           false,
+		"[fe80::1]":                     false,
+		"10.0.0.1":                      false,
+		"0.0.0.0":                       false,
+		"localhost":                     false,
+		"kubernetes":                    false,
+		"api.default.svc":               false,
+		"api.default.svc.cluster.local": false,
+		"metadata.google.internal":      false,
+		"alertmanager.monitoring.svc.":  true,
+		"jobs.hooks.corp":               true,
+		"10.20.1.1":                     true,
+	} {
+		if err := targets.CheckHost(host); (err == nil) != allowed {
+			t.Errorf("expected %s allowed %v, got %v", host, allowed, err)
+		}
+	}
+}
+
+func TestValidatorNotificationTargets(t *testing.T) {
+	v := &managedJobValidator{}
+	old := &ManagedJob{Spec: ManagedJobSpec{Image: "busybox", Groups: []*ManagedJobGroup{{Name: "a", Jobs: []*ManagedJobDefinition{{Name: "a"}}}},
+		Notifications: &ManagedJobNotifications{Webhooks: []ManagedJobWebhook{{URL: "http://hooks.default.svc/jobs"}}}}}
+	if _, err := v.ValidateCreate(context.Background(), old); err == nil || !strings.Contains(err.Error(), "spec.notifications.webhooks[0].url") {
+		t.Errorf("expected the cluster-local webhook rejected, got %v", err)
+	}
+	// the webhooks kept by the update are not checked again
+	mj := old.DeepCopy()
+	mj.Spec.Notifications.Webhooks = append(mj.Spec.Notifications.Webhooks, ManagedJobWebhook{URL: "https://hooks.example.com/jobs"})
+	if _, err := v.ValidateUpdate(context.Background(), old, mj); err != nil {
+		t.Errorf("expected the kept webhook accepted, got %v", err)
+	}
+	mj.Spec.Notifications.Webhooks[1].URL = "http://169.254.169.254/latest/meta-data"
+	if _, err := v.ValidateUpdate(context.Background(), old, mj); err == nil || !strings.Contains(err.Error(), "spec.notifications.webhooks[1].url") {
+		t.Errorf("expected the metadata address rejected, got %v", err)
+	}
+}
+
+func TestValidateOutcome(t *testing.T) {
+	manual := &ManagedJobDefinition{Name: "sign-off", Type: JobTypeManual, Outcome: "succeeded"}
+	container := &ManagedJobDefinition{Name: "build", Image: "busybox", Outcome: "succeeded"}
+	mj := &ManagedJob{Spec: ManagedJobSpec{Groups: []*ManagedJobGroup{{Name: "release", Jobs: []*ManagedJobDefinition{manual, container}}}}}
+	errs := mj.Validate()
+	if len(errs) != 1 || errs[0].Field != "spec.groups[0].jobs[1].outcome" {
+		t.Errorf("expected the outcome of the container job rejected only, got %v", errs)
+	}
+}
+
+func TestValidateTimeConstraints(t *testing.T) {
+	for _, tt := range []struct {
+		notBefore, notAfter string
+		invalid             []string
+	}{
+		{"2h", "3h", nil},
+		{"2024-06-01T02:00:00Z", "2024-06-01T04:00:00Z", nil},
+		{"2024-06-01T02:00:00Z", "1h", nil},
+		{"3h", "2h", []string{"spec.groups[0].notAfter"}},
+		{"2024-06-01T02:00:00Z", "2024-06-01T02:00:00Z", []string{"spec.groups[0].notAfter"}},
+		{"02:00", "-1h", []string{"spec.groups[0].notBefore", "spec.groups[0].notAfter"}},
+	} {
+		errs := ValidateTimeConstraints(tt.notBefore, tt.notAfter, field.NewPath("spec", "groups").Index(0))
+		fields := []string{}
+		for _, err := range errs {
+			fields = append(fields, err.Field)
+		}
+		if len(fields) != len(tt.invalid) || (len(fields) > 0 && !reflect.DeepEqual(fields, tt.invalid)) {
+			t.Errorf("notBefore %q, notAfter %q: got %v, expected %v", tt.notBefore, tt.notAfter, fields, tt.invalid)
+		}
+	}
+
+	startedAt := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	if at, err := ResolveTimeConstraint("90m", startedAt); err != nil || !at.Equal(startedAt.Add(90*time.Minute)) {
+		t.Errorf("duration resolved to %s, %v", at, err)
+	}
+}
+
+func TestValidateSize(t *testing.T) {
+	job := &ManagedJobDefinition{Name: "sync", Image: "busybox", Args: []string{"small"}}
+	mj := &ManagedJob{Spec: ManagedJobSpec{Groups: []*ManagedJobGroup{{Name: "extract", Jobs: []*ManagedJobDefinition{job}}}}}
+	if _, err := mj.ValidateCreate(); err != nil {
+		t.Errorf("expected the small workflow accepted, got %v", err)
+	}
+	job.Args = []string{strings.Repeat("x", MaxWorkflowSize)}
+	_, err := mj.ValidateCreate()
+	if err == nil || !strings.Contains(err.Error(), "split it into sub-workflows") {
+		t.Errorf("expected the large workflow rejected, got %v", err)
+	}
+	// the operator grows the workflow with its runtime state, the updates are left to the API server
+	if _, err := mj.ValidateUpdate(mj); err != nil {
+		t.Errorf("expected the update accepted, got %v", err)
+	}
+}
+
+func TestValidateWorkflowJobs(t *testing.T) {
+	mj := &ManagedJob{ObjectMeta: metav1.ObjectMeta{Name: "nightly"}, Spec: ManagedJobSpec{Groups: []*ManagedJobGroup{{Name: "release", Jobs: []*ManagedJobDefinition{
+		{Name: "pipeline", Type: JobTypeWorkflow, Workflow: &ManagedJobWorkflowReference{Name: "build-pipeline"}},
+		{Name: "missing", Type: JobTypeWorkflow},
+		{Name: "itself", Type: JobTypeWorkflow, Workflow: &ManagedJobWorkflowReference{Name: "nightly"}},
+		{Name: "compile"},
+	}}}}}
+	fields := []string{}
+	for _, err := range mj.Validate() {
+		fields = append(fields, err.Field)
+	}
+	// the sub-workflows need no image, the container job has none to inherit
+	expected := []string{"spec.groups[0].jobs[1].workflow.name", "spec.groups[0].jobs[2].workflow.name", "spec.groups[0].jobs[3].image"}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected %v, got %v", expected, fields)
+	}
+}
+
+func TestSpecStatusWarnings(t *testing.T) {
+	// decoded from the object of the admission request, the deprecated statuses are kept by the API server
+	workflow := func(groupStatus, jobStatus string) *ManagedJob {
+		mj := &ManagedJob{}
+		if err := json.Unmarshal([]byte(`{"spec":{"groups":[{"name":"g","status":"`+groupStatus+`","jobs":[{"name":"j","image":"busybox","status":"`+jobStatus+`"}]}]}}`), mj); err != nil {
+			t.Fatal(err)
+		}
+		return mj
+	}
+	if warnings, _ := workflow("pending", "").ValidateCreate(); len(warnings) != 0 {
+		t.Errorf("unexpected warnings on create: %v", warnings)
+	}
+	if warnings, _ := workflow("pending", "succeeded").ValidateCreate(); !reflect.DeepEqual(warnings, admission.Warnings{
+		"spec.groups[0].jobs[0].status is deprecated and set by the operator only, the value is ignored",
+	}) {
+		t.Errorf("warnings on create = %v", warnings)
+	}
+
+	// the statuses kept from the old workflow are reported once
+	old := workflow("running", "succeeded")
+	if warnings, _ := workflow("running", "succeeded").ValidateUpdate(old); len(warnings) != 0 {
+		t.Errorf("unexpected warnings on the update keeping the statuses: %v", warnings)
+	}
+	if warnings, _ := workflow("failed", "succeeded").ValidateUpdate(old); !reflect.DeepEqual(warnings, admission.Warnings{
+		"spec.groups[0].status is deprecated and set by the operator only, the value is ignored",
+	}) {
+		t.Errorf("warnings on the client update = %v", warnings)
+	}
+}
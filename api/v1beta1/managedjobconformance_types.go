@@ -0,0 +1,123 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	ConformancePhaseRunning = "Running"
+	ConformancePhasePassed  = "Passed"
+	ConformancePhaseFailed  = "Failed"
+)
+
+// ConformanceScenario is the behaviour of the operator checked by a canary workflow
+// +kubebuilder:validation:Enum=serial;parallel;failure;retry;abort
+type ConformanceScenario string
+
+const (
+	// ConformanceSerial checks the groups run one after another and the dependent group waits for its dependency
+	ConformanceSerial ConformanceScenario = "serial"
+	// ConformanceParallel checks the jobs of the parallel group run all at once
+	ConformanceParallel ConformanceScenario = "parallel"
+	// ConformanceFailure checks the failed job fails the workflow and aborts its dependents
+	ConformanceFailure ConformanceScenario = "failure"
+	// ConformanceRetry checks retry-failed runs the failed job again and keeps the succeeded one
+	ConformanceRetry ConformanceScenario = "retry"
+	// ConformanceAbort checks abort stops the running job and deletes its Job
+	ConformanceAbort ConformanceScenario = "abort"
+)
+
+// ConformanceScenarios are all the scenarios, in the order they are reported
+var ConformanceScenarios = []ConformanceScenario{ConformanceSerial, ConformanceParallel, ConformanceFailure, ConformanceRetry, ConformanceAbort}
+
+// ManagedJobConformanceSpec selects the canary workflows run against the cluster
+type ManagedJobConformanceSpec struct {
+	// Scenarios to run, all of them when empty
+	// +kubebuilder:validation:Optional
+	// +optional
+	Scenarios []ConformanceScenario `json:"scenarios,omitempty"`
+	// Image of the canary jobs, it needs a shell with sleep
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MinLength=5
+	// +kubebuilder:default="busybox:1.36"
+	// +optional
+	Image string `json:"image,omitempty"`
+	// Time the scenarios have to finish in, the ones still running then fail
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default="10m"
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// ManagedJobConformanceResult is the outcome of a single scenario
+type ManagedJobConformanceResult struct {
+	Scenario ConformanceScenario `json:"scenario"`
+	// Canary workflow of the scenario, owned by the conformance run
+	Workflow string `json:"workflow"`
+	// Running, Passed or Failed
+	Phase string `json:"phase"`
+	// What was checked, or what went wrong
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ManagedJobConformanceStatus reports the results of the scenarios
+type ManagedJobConformanceStatus struct {
+	// Running until all the scenarios finished, Passed when all of them passed, Failed otherwise
+	// +optional
+	Phase string `json:"phase,omitempty"`
+	// Passed scenarios out of all, e.g. 4/5
+	// +optional
+	Summary string `json:"summary,omitempty"`
+	// +optional
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+	// +optional
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+	// +optional
+	Results []ManagedJobConformanceResult `json:"results,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Passed",type=string,JSONPath=`.status.summary`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// ManagedJobConformance runs the canary workflows of its scenarios in its namespace and reports if the
+// operator handled them as expected, e.g. to validate the upgrade of the operator. It runs once, recreate
+// it to run the scenarios again.
+type ManagedJobConformance struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ManagedJobConformanceSpec   `json:"spec,omitempty"`
+	Status ManagedJobConformanceStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ManagedJobConformanceList contains a list of ManagedJobConformance
+type ManagedJobConformanceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ManagedJobConformance `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ManagedJobConformance{}, &ManagedJobConformanceList{})
+}
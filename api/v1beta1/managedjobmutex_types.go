@@ -0,0 +1,103 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	MutexScopeNamespace = "Namespace"
+	MutexScopeCluster   = "Cluster"
+)
+
+// ManagedJobSynchronization lets a single group or job holding the mutex run at a time, the others wait for it
+type ManagedJobSynchronization struct {
+	// Name of the mutex, e.g. db-migrations
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Mutex string `json:"mutex"`
+	// Namespace - the mutex is shared by the workflows of the namespace, Cluster - by all the workflows
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Namespace;Cluster
+	// +kubebuilder:default=Namespace
+	// +optional
+	Scope string `json:"scope,omitempty"`
+}
+
+// MutexObjectName is the name of the ManagedJobMutex, the namespaced mutexes are prefixed with their namespace
+func (s *ManagedJobSynchronization) MutexObjectName(namespace string) string {
+	if s.Scope == MutexScopeCluster {
+		return s.Mutex
+	}
+	return namespace + "." + s.Mutex
+}
+
+// ManagedJobMutexHolder is the group, or the job when set, of the workflow holding or waiting for the mutex
+type ManagedJobMutexHolder struct {
+	Namespace string    `json:"namespace"`
+	Workflow  string    `json:"workflow"`
+	UID       types.UID `json:"uid"`
+	Group     string    `json:"group"`
+	// +optional
+	Job string `json:"job,omitempty"`
+	// When the mutex was acquired or the waiting started
+	Since metav1.Time `json:"since"`
+}
+
+// ManagedJobMutexSpec is the state of the mutex kept by the controller
+type ManagedJobMutexSpec struct {
+	Mutex string `json:"mutex"`
+	// Namespace sharing the mutex, empty for the cluster wide mutexes
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// +optional
+	Holder *ManagedJobMutexHolder `json:"holder,omitempty"`
+	// Groups and jobs waiting for the mutex, in the order they asked for it
+	// +optional
+	Queue []ManagedJobMutexHolder `json:"queue,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Mutex",type=string,JSONPath=`.spec.mutex`
+// +kubebuilder:printcolumn:name="Namespace",type=string,JSONPath=`.spec.namespace`
+// +kubebuilder:printcolumn:name="Holder",type=string,JSONPath=`.spec.holder.workflow`
+// +kubebuilder:printcolumn:name="Since",type=date,JSONPath=`.spec.holder.since`
+// ManagedJobMutex is created by the controller for the mutexes of the synchronized groups and jobs,
+// deleting it releases the mutex
+type ManagedJobMutex struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ManagedJobMutexSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ManagedJobMutexList contains a list of ManagedJobMutex
+type ManagedJobMutexList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ManagedJobMutex `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ManagedJobMutex{}, &ManagedJobMutexList{})
+}
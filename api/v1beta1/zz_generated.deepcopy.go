@@ -22,16 +22,135 @@ limitations under the License.
 package v1beta1
 
 import (
-	"k8s.io/api/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeprecatedGroupState) DeepCopyInto(out *DeprecatedGroupState) {
+	*out = *in
+	if in.ReadyAt != nil {
+		in, out := &in.ReadyAt, &out.ReadyAt
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletedAt != nil {
+		in, out := &in.CompletedAt, &out.CompletedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeprecatedGroupState.
+func (in *DeprecatedGroupState) DeepCopy() *DeprecatedGroupState {
+	if in == nil {
+		return nil
+	}
+	out := new(DeprecatedGroupState)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeprecatedJobState) DeepCopyInto(out *DeprecatedJobState) {
+	*out = *in
+	if in.ImagePullRefreshedAt != nil {
+		in, out := &in.ImagePullRefreshedAt, &out.ImagePullRefreshedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.SchedulingLatency != nil {
+		in, out := &in.SchedulingLatency, &out.SchedulingLatency
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.AttemptSnapshots != nil {
+		in, out := &in.AttemptSnapshots, &out.AttemptSnapshots
+		*out = make([]ManagedJobAttemptSnapshot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeprecatedJobState.
+func (in *DeprecatedJobState) DeepCopy() *DeprecatedJobState {
+	if in == nil {
+		return nil
+	}
+	out := new(DeprecatedJobState)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeprecatedWorkflowState) DeepCopyInto(out *DeprecatedWorkflowState) {
+	*out = *in
+	if in.AggregatedResources != nil {
+		in, out := &in.AggregatedResources, &out.AggregatedResources
+		*out = new(ManagedJobResourcesSummary)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ObservedTriggers != nil {
+		in, out := &in.ObservedTriggers, &out.ObservedTriggers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.StrayJobs != nil {
+		in, out := &in.StrayJobs, &out.StrayJobs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RunHistory != nil {
+		in, out := &in.RunHistory, &out.RunHistory
+		*out = make([]ManagedJobRunRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.EstimatedCompletion != nil {
+		in, out := &in.EstimatedCompletion, &out.EstimatedCompletion
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Graph != nil {
+		in, out := &in.Graph, &out.Graph
+		*out = make([]ManagedJobGraphNode, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Warnings != nil {
+		in, out := &in.Warnings, &out.Warnings
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeprecatedWorkflowState.
+func (in *DeprecatedWorkflowState) DeepCopy() *DeprecatedWorkflowState {
+	if in == nil {
+		return nil
+	}
+	out := new(DeprecatedWorkflowState)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ManagedJob) DeepCopyInto(out *ManagedJob) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJob.
@@ -52,14 +171,198 @@ func (in *ManagedJob) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobAttemptSnapshot) DeepCopyInto(out *ManagedJobAttemptSnapshot) {
+	*out = *in
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobAttemptSnapshot.
+func (in *ManagedJobAttemptSnapshot) DeepCopy() *ManagedJobAttemptSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobAttemptSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobConformance) DeepCopyInto(out *ManagedJobConformance) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobConformance.
+func (in *ManagedJobConformance) DeepCopy() *ManagedJobConformance {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobConformance)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ManagedJobConformance) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobConformanceList) DeepCopyInto(out *ManagedJobConformanceList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ManagedJobConformance, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobConformanceList.
+func (in *ManagedJobConformanceList) DeepCopy() *ManagedJobConformanceList {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobConformanceList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ManagedJobConformanceList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobConformanceResult) DeepCopyInto(out *ManagedJobConformanceResult) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobConformanceResult.
+func (in *ManagedJobConformanceResult) DeepCopy() *ManagedJobConformanceResult {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobConformanceResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobConformanceSpec) DeepCopyInto(out *ManagedJobConformanceSpec) {
+	*out = *in
+	if in.Scenarios != nil {
+		in, out := &in.Scenarios, &out.Scenarios
+		*out = make([]ConformanceScenario, len(*in))
+		copy(*out, *in)
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobConformanceSpec.
+func (in *ManagedJobConformanceSpec) DeepCopy() *ManagedJobConformanceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobConformanceSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobConformanceStatus) DeepCopyInto(out *ManagedJobConformanceStatus) {
+	*out = *in
+	if in.StartedAt != nil {
+		in, out := &in.StartedAt, &out.StartedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletedAt != nil {
+		in, out := &in.CompletedAt, &out.CompletedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Results != nil {
+		in, out := &in.Results, &out.Results
+		*out = make([]ManagedJobConformanceResult, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobConformanceStatus.
+func (in *ManagedJobConformanceStatus) DeepCopy() *ManagedJobConformanceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobConformanceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ManagedJobDefinition) DeepCopyInto(out *ManagedJobDefinition) {
 	*out = *in
+	if in.Workflow != nil {
+		in, out := &in.Workflow, &out.Workflow
+		*out = new(ManagedJobWorkflowReference)
+		**out = **in
+	}
+	if in.FanOut != nil {
+		in, out := &in.FanOut, &out.FanOut
+		*out = new(ManagedJobFanOut)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Script != nil {
+		in, out := &in.Script, &out.Script
+		*out = new(ManagedJobScript)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Args != nil {
 		in, out := &in.Args, &out.Args
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.SuccessExitCodes != nil {
+		in, out := &in.SuccessExitCodes, &out.SuccessExitCodes
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
+	if in.Synchronization != nil {
+		in, out := &in.Synchronization, &out.Synchronization
+		*out = new(ManagedJobSynchronization)
+		**out = **in
+	}
+	if in.Retries != nil {
+		in, out := &in.Retries, &out.Retries
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int64)
+		**out = **in
+	}
 	in.Params.DeepCopyInto(&out.Params)
 	if in.Dependencies != nil {
 		in, out := &in.Dependencies, &out.Dependencies
@@ -72,7 +375,30 @@ func (in *ManagedJobDefinition) DeepCopyInto(out *ManagedJobDefinition) {
 			}
 		}
 	}
-	in.CompiledParams.DeepCopyInto(&out.CompiledParams)
+	if in.ParamsPatches != nil {
+		in, out := &in.ParamsPatches, &out.ParamsPatches
+		*out = make([]ManagedJobParamsPatch, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ImagePullRefreshedAt != nil {
+		in, out := &in.ImagePullRefreshedAt, &out.ImagePullRefreshedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.SchedulingLatency != nil {
+		in, out := &in.SchedulingLatency, &out.SchedulingLatency
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.AttemptSnapshots != nil {
+		in, out := &in.AttemptSnapshots, &out.AttemptSnapshots
+		*out = make([]ManagedJobAttemptSnapshot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.DeprecatedJobState.DeepCopyInto(&out.DeprecatedJobState)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobDefinition.
@@ -100,6 +426,72 @@ func (in *ManagedJobDependencies) DeepCopy() *ManagedJobDependencies {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobFailurePolicy) DeepCopyInto(out *ManagedJobFailurePolicy) {
+	*out = *in
+	out.MaxFailed = in.MaxFailed
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobFailurePolicy.
+func (in *ManagedJobFailurePolicy) DeepCopy() *ManagedJobFailurePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobFailurePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobFanOut) DeepCopyInto(out *ManagedJobFanOut) {
+	*out = *in
+	if in.Parallelism != nil {
+		in, out := &in.Parallelism, &out.Parallelism
+		*out = new(int32)
+		**out = **in
+	}
+	if in.BackoffLimitPerIndex != nil {
+		in, out := &in.BackoffLimitPerIndex, &out.BackoffLimitPerIndex
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxFailedIndexes != nil {
+		in, out := &in.MaxFailedIndexes, &out.MaxFailedIndexes
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobFanOut.
+func (in *ManagedJobFanOut) DeepCopy() *ManagedJobFanOut {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobFanOut)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobGraphNode) DeepCopyInto(out *ManagedJobGraphNode) {
+	*out = *in
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobGraphNode.
+func (in *ManagedJobGraphNode) DeepCopy() *ManagedJobGraphNode {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobGraphNode)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ManagedJobGroup) DeepCopyInto(out *ManagedJobGroup) {
 	*out = *in
@@ -126,6 +518,45 @@ func (in *ManagedJobGroup) DeepCopyInto(out *ManagedJobGroup) {
 			}
 		}
 	}
+	if in.Synchronization != nil {
+		in, out := &in.Synchronization, &out.Synchronization
+		*out = new(ManagedJobSynchronization)
+		**out = **in
+	}
+	if in.FailurePolicy != nil {
+		in, out := &in.FailurePolicy, &out.FailurePolicy
+		*out = new(ManagedJobFailurePolicy)
+		**out = **in
+	}
+	if in.Retries != nil {
+		in, out := &in.Retries, &out.Retries
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Impersonate != nil {
+		in, out := &in.Impersonate, &out.Impersonate
+		*out = new(ManagedJobImpersonation)
+		**out = **in
+	}
+	if in.DelayBefore != nil {
+		in, out := &in.DelayBefore, &out.DelayBefore
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.DelayAfter != nil {
+		in, out := &in.DelayAfter, &out.DelayAfter
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ReadyAt != nil {
+		in, out := &in.ReadyAt, &out.ReadyAt
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletedAt != nil {
+		in, out := &in.CompletedAt, &out.CompletedAt
+		*out = (*in).DeepCopy()
+	}
+	in.DeprecatedGroupState.DeepCopyInto(&out.DeprecatedGroupState)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobGroup.
@@ -139,25 +570,115 @@ func (in *ManagedJobGroup) DeepCopy() *ManagedJobGroup {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ManagedJobList) DeepCopyInto(out *ManagedJobList) {
+func (in *ManagedJobGroupStatus) DeepCopyInto(out *ManagedJobGroupStatus) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]ManagedJob, len(*in))
+	if in.ReadyAt != nil {
+		in, out := &in.ReadyAt, &out.ReadyAt
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletedAt != nil {
+		in, out := &in.CompletedAt, &out.CompletedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Dependencies != nil {
+		in, out := &in.Dependencies, &out.Dependencies
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Jobs != nil {
+		in, out := &in.Jobs, &out.Jobs
+		*out = make([]ManagedJobJobStatus, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobList.
-func (in *ManagedJobList) DeepCopy() *ManagedJobList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobGroupStatus.
+func (in *ManagedJobGroupStatus) DeepCopy() *ManagedJobGroupStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ManagedJobList)
+	out := new(ManagedJobGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobImpersonation) DeepCopyInto(out *ManagedJobImpersonation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobImpersonation.
+func (in *ManagedJobImpersonation) DeepCopy() *ManagedJobImpersonation {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobImpersonation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobJobStatus) DeepCopyInto(out *ManagedJobJobStatus) {
+	*out = *in
+	if in.AttemptSnapshots != nil {
+		in, out := &in.AttemptSnapshots, &out.AttemptSnapshots
+		*out = make([]ManagedJobAttemptSnapshot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ImagePullRefreshedAt != nil {
+		in, out := &in.ImagePullRefreshedAt, &out.ImagePullRefreshedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.SchedulingLatency != nil {
+		in, out := &in.SchedulingLatency, &out.SchedulingLatency
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Dependencies != nil {
+		in, out := &in.Dependencies, &out.Dependencies
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobJobStatus.
+func (in *ManagedJobJobStatus) DeepCopy() *ManagedJobJobStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobJobStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobList) DeepCopyInto(out *ManagedJobList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ManagedJob, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobList.
+func (in *ManagedJobList) DeepCopy() *ManagedJobList {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobList)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -170,40 +691,184 @@ func (in *ManagedJobList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobMaintenanceWindow) DeepCopyInto(out *ManagedJobMaintenanceWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]MaintenanceWindowDay, len(*in))
+		copy(*out, *in)
+	}
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobMaintenanceWindow.
+func (in *ManagedJobMaintenanceWindow) DeepCopy() *ManagedJobMaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobMaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobMutex) DeepCopyInto(out *ManagedJobMutex) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobMutex.
+func (in *ManagedJobMutex) DeepCopy() *ManagedJobMutex {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobMutex)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ManagedJobMutex) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobMutexHolder) DeepCopyInto(out *ManagedJobMutexHolder) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobMutexHolder.
+func (in *ManagedJobMutexHolder) DeepCopy() *ManagedJobMutexHolder {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobMutexHolder)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobMutexList) DeepCopyInto(out *ManagedJobMutexList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ManagedJobMutex, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobMutexList.
+func (in *ManagedJobMutexList) DeepCopy() *ManagedJobMutexList {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobMutexList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ManagedJobMutexList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobMutexSpec) DeepCopyInto(out *ManagedJobMutexSpec) {
+	*out = *in
+	if in.Holder != nil {
+		in, out := &in.Holder, &out.Holder
+		*out = new(ManagedJobMutexHolder)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Queue != nil {
+		in, out := &in.Queue, &out.Queue
+		*out = make([]ManagedJobMutexHolder, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobMutexSpec.
+func (in *ManagedJobMutexSpec) DeepCopy() *ManagedJobMutexSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobMutexSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobNotifications) DeepCopyInto(out *ManagedJobNotifications) {
+	*out = *in
+	if in.Webhooks != nil {
+		in, out := &in.Webhooks, &out.Webhooks
+		*out = make([]ManagedJobWebhook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobNotifications.
+func (in *ManagedJobNotifications) DeepCopy() *ManagedJobNotifications {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobNotifications)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ManagedJobParameters) DeepCopyInto(out *ManagedJobParameters) {
 	*out = *in
 	if in.FromEnv != nil {
 		in, out := &in.FromEnv, &out.FromEnv
-		*out = make([]v1.EnvFromSource, len(*in))
+		*out = make([]corev1.EnvFromSource, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 	if in.Env != nil {
 		in, out := &in.Env, &out.Env
-		*out = make([]v1.EnvVar, len(*in))
+		*out = make([]corev1.EnvVar, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 	if in.Volumes != nil {
 		in, out := &in.Volumes, &out.Volumes
-		*out = make([]v1.Volume, len(*in))
+		*out = make([]corev1.Volume, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 	if in.VolumeMounts != nil {
 		in, out := &in.VolumeMounts, &out.VolumeMounts
-		*out = make([]v1.VolumeMount, len(*in))
+		*out = make([]corev1.VolumeMount, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 	if in.ImagePullSecrets != nil {
 		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
-		*out = make([]v1.LocalObjectReference, len(*in))
+		*out = make([]corev1.LocalObjectReference, len(*in))
 		copy(*out, *in)
 	}
 	if in.Labels != nil {
@@ -220,6 +885,26 @@ func (in *ManagedJobParameters) DeepCopyInto(out *ManagedJobParameters) {
 			(*out)[key] = val
 		}
 	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RunAsUser != nil {
+		in, out := &in.RunAsUser, &out.RunAsUser
+		*out = new(int64)
+		**out = **in
+	}
+	if in.RunAsGroup != nil {
+		in, out := &in.RunAsGroup, &out.RunAsGroup
+		*out = new(int64)
+		**out = **in
+	}
+	if in.SecretsFrom != nil {
+		in, out := &in.SecretsFrom, &out.SecretsFrom
+		*out = new(ManagedJobSecretsFrom)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobParameters.
@@ -232,6 +917,135 @@ func (in *ManagedJobParameters) DeepCopy() *ManagedJobParameters {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobParamsPatch) DeepCopyInto(out *ManagedJobParamsPatch) {
+	*out = *in
+	if in.Value != nil {
+		in, out := &in.Value, &out.Value
+		*out = new(apiextensionsv1.JSON)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobParamsPatch.
+func (in *ManagedJobParamsPatch) DeepCopy() *ManagedJobParamsPatch {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobParamsPatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobResourcesSummary) DeepCopyInto(out *ManagedJobResourcesSummary) {
+	*out = *in
+	if in.Active != nil {
+		in, out := &in.Active, &out.Active
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.Total != nil {
+		in, out := &in.Total, &out.Total
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobResourcesSummary.
+func (in *ManagedJobResourcesSummary) DeepCopy() *ManagedJobResourcesSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobResourcesSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobRestartTrigger) DeepCopyInto(out *ManagedJobRestartTrigger) {
+	*out = *in
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobRestartTrigger.
+func (in *ManagedJobRestartTrigger) DeepCopy() *ManagedJobRestartTrigger {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobRestartTrigger)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobRunRecord) DeepCopyInto(out *ManagedJobRunRecord) {
+	*out = *in
+	in.StartedAt.DeepCopyInto(&out.StartedAt)
+	if in.CompletedAt != nil {
+		in, out := &in.CompletedAt, &out.CompletedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobRunRecord.
+func (in *ManagedJobRunRecord) DeepCopy() *ManagedJobRunRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobRunRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobScript) DeepCopyInto(out *ManagedJobScript) {
+	*out = *in
+	if in.Interpreter != nil {
+		in, out := &in.Interpreter, &out.Interpreter
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobScript.
+func (in *ManagedJobScript) DeepCopy() *ManagedJobScript {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobScript)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobSecretsFrom) DeepCopyInto(out *ManagedJobSecretsFrom) {
+	*out = *in
+	if in.Vault != nil {
+		in, out := &in.Vault, &out.Vault
+		*out = new(ManagedJobVaultSecret)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobSecretsFrom.
+func (in *ManagedJobSecretsFrom) DeepCopy() *ManagedJobSecretsFrom {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobSecretsFrom)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ManagedJobSpec) DeepCopyInto(out *ManagedJobSpec) {
 	*out = *in
@@ -246,7 +1060,88 @@ func (in *ManagedJobSpec) DeepCopyInto(out *ManagedJobSpec) {
 			}
 		}
 	}
+	if in.EnabledGroups != nil {
+		in, out := &in.EnabledGroups, &out.EnabledGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]ManagedJobWorkflowDependency, len(*in))
+		copy(*out, *in)
+	}
 	in.Params.DeepCopyInto(&out.Params)
+	if in.RestartOn != nil {
+		in, out := &in.RestartOn, &out.RestartOn
+		*out = make([]ManagedJobRestartTrigger, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]ManagedJobMaintenanceWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Notifications != nil {
+		in, out := &in.Notifications, &out.Notifications
+		*out = new(ManagedJobNotifications)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ActiveDeadlineSeconds != nil {
+		in, out := &in.ActiveDeadlineSeconds, &out.ActiveDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.ObservedTriggers != nil {
+		in, out := &in.ObservedTriggers, &out.ObservedTriggers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.StrayJobs != nil {
+		in, out := &in.StrayJobs, &out.StrayJobs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RunHistory != nil {
+		in, out := &in.RunHistory, &out.RunHistory
+		*out = make([]ManagedJobRunRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.EstimatedCompletion != nil {
+		in, out := &in.EstimatedCompletion, &out.EstimatedCompletion
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Graph != nil {
+		in, out := &in.Graph, &out.Graph
+		*out = make([]ManagedJobGraphNode, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Warnings != nil {
+		in, out := &in.Warnings, &out.Warnings
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MetricsDeletedAt != nil {
+		in, out := &in.MetricsDeletedAt, &out.MetricsDeletedAt
+		*out = (*in).DeepCopy()
+	}
+	in.DeprecatedWorkflowState.DeepCopyInto(&out.DeprecatedWorkflowState)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobSpec.
@@ -258,3 +1153,181 @@ func (in *ManagedJobSpec) DeepCopy() *ManagedJobSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobStatus) DeepCopyInto(out *ManagedJobStatus) {
+	*out = *in
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]ManagedJobGroupStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AggregatedResources != nil {
+		in, out := &in.AggregatedResources, &out.AggregatedResources
+		*out = new(ManagedJobResourcesSummary)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ObservedTriggers != nil {
+		in, out := &in.ObservedTriggers, &out.ObservedTriggers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.StrayJobs != nil {
+		in, out := &in.StrayJobs, &out.StrayJobs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RunHistory != nil {
+		in, out := &in.RunHistory, &out.RunHistory
+		*out = make([]ManagedJobRunRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.EstimatedCompletion != nil {
+		in, out := &in.EstimatedCompletion, &out.EstimatedCompletion
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Graph != nil {
+		in, out := &in.Graph, &out.Graph
+		*out = make([]ManagedJobGraphNode, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Warnings != nil {
+		in, out := &in.Warnings, &out.Warnings
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MetricsDeletedAt != nil {
+		in, out := &in.MetricsDeletedAt, &out.MetricsDeletedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobStatus.
+func (in *ManagedJobStatus) DeepCopy() *ManagedJobStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobSynchronization) DeepCopyInto(out *ManagedJobSynchronization) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobSynchronization.
+func (in *ManagedJobSynchronization) DeepCopy() *ManagedJobSynchronization {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobSynchronization)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobVaultSecret) DeepCopyInto(out *ManagedJobVaultSecret) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobVaultSecret.
+func (in *ManagedJobVaultSecret) DeepCopy() *ManagedJobVaultSecret {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobVaultSecret)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobWebhook) DeepCopyInto(out *ManagedJobWebhook) {
+	*out = *in
+	if in.SigningSecret != nil {
+		in, out := &in.SigningSecret, &out.SigningSecret
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Statuses != nil {
+		in, out := &in.Statuses, &out.Statuses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobWebhook.
+func (in *ManagedJobWebhook) DeepCopy() *ManagedJobWebhook {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobWebhook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobWorkflowDependency) DeepCopyInto(out *ManagedJobWorkflowDependency) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobWorkflowDependency.
+func (in *ManagedJobWorkflowDependency) DeepCopy() *ManagedJobWorkflowDependency {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobWorkflowDependency)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedJobWorkflowReference) DeepCopyInto(out *ManagedJobWorkflowReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedJobWorkflowReference.
+func (in *ManagedJobWorkflowReference) DeepCopy() *ManagedJobWorkflowReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedJobWorkflowReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationTargets) DeepCopyInto(out *NotificationTargets) {
+	*out = *in
+	if in.Allowed != nil {
+		in, out := &in.Allowed, &out.Allowed
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationTargets.
+func (in *NotificationTargets) DeepCopy() *NotificationTargets {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationTargets)
+	in.DeepCopyInto(out)
+	return out
+}
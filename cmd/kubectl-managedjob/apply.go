@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+// fieldManager owns the fields applied by the plugin, pruning only touches the workflows it applied
+const fieldManager = "kubectl-managedjob"
+
+var managedJobGVK = jobsmanagerv1beta1.GroupVersion.WithKind("ManagedJob")
+
+type applyResult struct {
+	file      string
+	namespace string
+	name      string
+	result    string
+	failed    bool
+}
+
+type manifest struct {
+	file   string
+	object *unstructured.Unstructured
+	err    error
+}
+
+func runApply(args []string) error {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	cf := &clusterFlags{}
+	cf.bind(fs)
+	path := fs.String("f", "", "Manifest file or directory with the workflow manifests.")
+	recursive := fs.Bool("recursive", false, "Read the manifests from the subdirectories as well.")
+	prune := fs.Bool("prune", false, "Delete the workflows matching the selector which are no longer in the manifests, requires -l.")
+	selector := fs.String("l", "", "Label selector, only the workflows matching it are applied and pruned.")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: kubectl managedjob apply -f <file|dir> [--recursive] [--prune -l <selector>] [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(reorderArgs(fs, args)); err != nil {
+		return err
+	}
+	if *path == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("expected the manifest file or directory")
+	}
+	if *prune && *selector == "" {
+		return fmt.Errorf("--prune requires the label selector, otherwise it would delete all the workflows of the namespace")
+	}
+	labelSelector, err := labels.Parse(*selector)
+	if err != nil {
+		return err
+	}
+
+	files, err := manifestFiles(*path, *recursive)
+	if err != nil {
+		return err
+	}
+	manifests := []manifest{}
+	for _, file := range files {
+		manifests = append(manifests, readManifests(file)...)
+	}
+
+	c, namespace, err := cf.client()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	results := []applyResult{}
+	applied := map[client.ObjectKey]bool{}
+	namespaces := map[string]bool{namespace: true}
+	for _, m := range manifests {
+		if m.err != nil {
+			results = append(results, applyResult{file: m.file, result: m.err.Error(), failed: true})
+			continue
+		}
+		obj := m.object
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(namespace)
+		}
+		namespaces[obj.GetNamespace()] = true
+		res := applyResult{file: m.file, namespace: obj.GetNamespace(), name: obj.GetName()}
+		if !labelSelector.Matches(labels.Set(obj.GetLabels())) {
+			res.result = "skipped, labels do not match the selector"
+			results = append(results, res)
+			continue
+		}
+		applied[client.ObjectKeyFromObject(obj)] = true
+		res.result, err = applyWorkflow(ctx, c, obj)
+		if err != nil {
+			res.result, res.failed = err.Error(), true
+		}
+		results = append(results, res)
+	}
+
+	if *prune {
+		if countFailed(results) > 0 {
+			// a manifest which failed to parse would look like a removed workflow
+			results = append(results, applyResult{file: "-", result: "prune skipped because of the failed manifests"})
+		} else {
+			pruned, err := pruneWorkflows(ctx, c, namespaces, labelSelector, applied)
+			results = append(results, pruned...)
+			if err != nil {
+				printApplyResults(os.Stdout, results)
+				return err
+			}
+		}
+	}
+
+	printApplyResults(os.Stdout, results)
+	if failed := countFailed(results); failed > 0 {
+		return fmt.Errorf("%d of the manifests failed", failed)
+	}
+	return nil
+}
+
+func countFailed(results []applyResult) int {
+	failed := 0
+	for _, res := range results {
+		if res.failed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// manifestFiles lists the yaml and json files, the path may point to a single file as well
+func manifestFiles(path string, recursive bool) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	files := []string{}
+	err = filepath.WalkDir(path, func(file string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if file != path && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".yaml", ".yml", ".json":
+			files = append(files, file)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// readManifests splits the multi-document file, every document must be a valid ManagedJob
+func readManifests(file string) []manifest {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return []manifest{{file: file, err: err}}
+	}
+	manifests := []manifest{}
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	for {
+		document, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return append(manifests, manifest{file: file, err: err})
+		}
+		if len(bytes.TrimSpace(document)) == 0 {
+			continue
+		}
+		obj, err := parseManifest(document)
+		if err != nil {
+			err = fmt.Errorf("unable to parse: %w", err)
+		}
+		manifests = append(manifests, manifest{file: file, object: obj, err: err})
+	}
+	return manifests
+}
+
+func parseManifest(document []byte) (*unstructured.Unstructured, error) {
+	jsonDocument, err := yaml.YAMLToJSON(document)
+	if err != nil {
+		return nil, err
+	}
+	obj := &unstructured.Unstructured{}
+	if err := json.Unmarshal(jsonDocument, &obj.Object); err != nil {
+		return nil, err
+	}
+	if obj.GroupVersionKind() != managedJobGVK {
+		return nil, fmt.Errorf("expected %s, got %s", managedJobGVK, obj.GroupVersionKind())
+	}
+	if obj.GetName() == "" {
+		return nil, fmt.Errorf("workflow has no name")
+	}
+	// strict decoding catches the misspelled fields, the object is applied as written
+	// so the fields of the spec the operator owns are not overwritten
+	if err := yaml.UnmarshalStrict(document, &jobsmanagerv1beta1.ManagedJob{}); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func applyWorkflow(ctx context.Context, c client.Client, obj *unstructured.Unstructured) (string, error) {
+	existing := &jobsmanagerv1beta1.ManagedJob{}
+	err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return "", err
+	}
+	found := err == nil
+
+	if err := c.Patch(ctx, obj, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership); err != nil {
+		return "", err
+	}
+	switch {
+	case !found:
+		return "created", nil
+	case existing.ResourceVersion == obj.GetResourceVersion():
+		return "unchanged", nil
+	}
+	return "configured", nil
+}
+
+// pruneWorkflows deletes the workflows applied by the plugin before which are no longer in the manifests
+func pruneWorkflows(ctx context.Context, c client.Client, namespaces map[string]bool, selector labels.Selector, applied map[client.ObjectKey]bool) ([]applyResult, error) {
+	results := []applyResult{}
+	names := []string{}
+	for namespace := range namespaces {
+		names = append(names, namespace)
+	}
+	sort.Strings(names)
+	for _, namespace := range names {
+		var workflows jobsmanagerv1beta1.ManagedJobList
+		if err := c.List(ctx, &workflows, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return results, err
+		}
+		for i := range workflows.Items {
+			workflow := &workflows.Items[i]
+			if applied[client.ObjectKeyFromObject(workflow)] || len(workflow.OwnerReferences) > 0 || !appliedByPlugin(workflow) {
+				continue
+			}
+			res := applyResult{file: "-", namespace: workflow.Namespace, name: workflow.Name, result: "pruned"}
+			if err := c.Delete(ctx, workflow); client.IgnoreNotFound(err) != nil {
+				res.result, res.failed = err.Error(), true
+			}
+			results = append(results, res)
+		}
+	}
+	return results, nil
+}
+
+func appliedByPlugin(workflow *jobsmanagerv1beta1.ManagedJob) bool {
+	for _, entry := range workflow.ManagedFields {
+		if entry.Manager == fieldManager {
+			return true
+		}
+	}
+	return false
+}
+
+func printApplyResults(out io.Writer, results []applyResult) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tNAMESPACE\tWORKFLOW\tRESULT")
+	for _, res := range results {
+		namespace, name := res.namespace, res.name
+		if namespace == "" {
+			namespace = "-"
+		}
+		if name == "" {
+			name = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", res.file, namespace, name, res.result)
+	}
+	w.Flush()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/controllers"
+)
+
+func runApprove(args []string) error {
+	fs := flag.NewFlagSet("approve", flag.ExitOnError)
+	cf := &clusterFlags{}
+	cf.bind(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: kubectl managedjob approve <name> <group> [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(reorderArgs(fs, args)); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected the workflow and the group name")
+	}
+	name, groupName := fs.Arg(0), fs.Arg(1)
+
+	c, namespace, err := cf.client()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	mj := &jobsmanagerv1beta1.ManagedJob{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, mj); err != nil {
+		return err
+	}
+
+	for i, group := range mj.Spec.Groups {
+		if group.Name != groupName {
+			continue
+		}
+		if !group.PauseBefore {
+			return fmt.Errorf("group %s does not wait for the approval", groupName)
+		}
+		// the approval used by the previous run counts once the operator forgot it
+		if group.Approved && mj.ApprovalConsumed(groupName) {
+			withdraw, err := json.Marshal([]map[string]interface{}{
+				{"op": "test", "path": fmt.Sprintf("/spec/groups/%d/name", i), "value": groupName},
+				{"op": "remove", "path": fmt.Sprintf("/spec/groups/%d/approved", i)},
+			})
+			if err != nil {
+				return err
+			}
+			if err := controllers.WithdrawConsumed(ctx, c, mj, withdraw, func(mj *jobsmanagerv1beta1.ManagedJob) bool {
+				return mj.ApprovalConsumed(groupName)
+			}); err != nil {
+				return err
+			}
+		}
+		// the test guards against the groups being reordered in the meantime
+		patch, err := json.Marshal([]map[string]interface{}{
+			{"op": "test", "path": fmt.Sprintf("/spec/groups/%d/name", i), "value": groupName},
+			{"op": "add", "path": fmt.Sprintf("/spec/groups/%d/approved", i), "value": true},
+		})
+		if err != nil {
+			return err
+		}
+		if err := c.Patch(ctx, mj, client.RawPatch(types.JSONPatchType, patch)); err != nil {
+			return err
+		}
+		fmt.Printf("Group %s of workflow %s approved\n", groupName, name)
+		return nil
+	}
+	return fmt.Errorf("workflow %s has no group %s", name, groupName)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/controllers"
+)
+
+// bulkResult is the outcome of the action requested on one workflow
+type bulkResult struct {
+	namespace string
+	name      string
+	status    string
+	result    string
+	failed    bool
+}
+
+func runAbort(args []string) error {
+	fs := flag.NewFlagSet("abort", flag.ExitOnError)
+	return runBulk(fs, args, "abort", func() string { return controllers.ActionAbort })
+}
+
+func runRetry(args []string) error {
+	fs := flag.NewFlagSet("retry", flag.ExitOnError)
+	failed := fs.Bool("failed", false, "Retry only the failed and aborted jobs of the failed workflows, the succeeded jobs are kept.")
+	return runBulk(fs, args, "retry", func() string {
+		if *failed {
+			return controllers.ActionRetryFailed
+		}
+		return controllers.ActionRetry
+	})
+}
+
+func runStart(args []string) error {
+	fs := flag.NewFlagSet("start", flag.ExitOnError)
+	return runBulk(fs, args, "start", func() string { return controllers.ActionStart })
+}
+
+// runBulk requests the action on the named workflows or on all the workflows matching the selector,
+// after printing them and asking for the confirmation
+func runBulk(fs *flag.FlagSet, args []string, verb string, actionOf func() string) error {
+	cf := &clusterFlags{}
+	cf.bind(fs)
+	selector := fs.String("l", "", "Label selector of the workflows.")
+	allNamespaces := fs.Bool("A", false, "Select the workflows in all the namespaces.")
+	yes := fs.Bool("y", false, "Do not ask for the confirmation.")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: kubectl managedjob %s (<name>... | -l <selector> [-A]) [flags]\n", verb)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(reorderArgs(fs, args)); err != nil {
+		return err
+	}
+	if (*selector == "") == (fs.NArg() == 0) {
+		fs.Usage()
+		return fmt.Errorf("expected either the workflow names or the label selector")
+	}
+	if *allNamespaces && *selector == "" {
+		return fmt.Errorf("-A requires the label selector")
+	}
+	labelSelector, err := labels.Parse(*selector)
+	if err != nil {
+		return err
+	}
+	action := actionOf()
+
+	c, namespace, err := cf.client()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	workflows := []jobsmanagerv1beta1.ManagedJob{}
+	if *selector != "" {
+		list := &jobsmanagerv1beta1.ManagedJobList{}
+		options := []client.ListOption{client.MatchingLabelsSelector{Selector: labelSelector}}
+		if !*allNamespaces {
+			options = append(options, client.InNamespace(namespace))
+		}
+		if err := c.List(ctx, list, options...); err != nil {
+			return err
+		}
+		workflows = list.Items
+	} else {
+		for _, name := range fs.Args() {
+			mj := jobsmanagerv1beta1.ManagedJob{}
+			if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &mj); err != nil {
+				return err
+			}
+			workflows = append(workflows, mj)
+		}
+	}
+
+	targets := []bulkResult{}
+	skipped := 0
+	for _, mj := range workflows {
+		if !controllers.ActionApplies(action, mj.Status.Phase) {
+			skipped++
+			continue
+		}
+		targets = append(targets, bulkResult{namespace: mj.Namespace, name: mj.Name, status: mj.Status.Phase})
+	}
+	if len(targets) == 0 {
+		fmt.Printf("No workflows to %s, %d matching ones skipped\n", verb, skipped)
+		return nil
+	}
+
+	printBulkResults(os.Stdout, targets, false)
+	if skipped > 0 {
+		fmt.Printf("%d matching workflows skipped, %s does not apply to their status\n", skipped, action)
+	}
+	if !*yes && !confirm(os.Stdin, os.Stdout, fmt.Sprintf("Request %s on %d workflows?", action, len(targets))) {
+		return fmt.Errorf("cancelled, no workflow was changed")
+	}
+
+	failed := 0
+	for i := range targets {
+		target := &targets[i]
+		target.result = action + " requested"
+		if err := requestAction(ctx, c, target.namespace, target.name, action); err != nil {
+			target.result, target.failed = err.Error(), true
+			failed++
+		}
+	}
+	fmt.Println()
+	printBulkResults(os.Stdout, targets, true)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d workflows were not changed", failed, len(targets))
+	}
+	return nil
+}
+
+// requestAction sets the action annotation, the controller takes the action and removes it
+func requestAction(ctx context.Context, c client.Client, namespace string, name string, action string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": map[string]string{controllers.AnnotationAction: action}},
+	})
+	if err != nil {
+		return err
+	}
+	mj := &jobsmanagerv1beta1.ManagedJob{}
+	mj.SetNamespace(namespace)
+	mj.SetName(name)
+	return c.Patch(ctx, mj, client.RawPatch(types.MergePatchType, patch))
+}
+
+func confirm(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "%s [y/N]: ", question)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func printBulkResults(out io.Writer, results []bulkResult, withResult bool) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	if withResult {
+		fmt.Fprintln(w, "NAMESPACE\tWORKFLOW\tSTATUS\tRESULT")
+	} else {
+		fmt.Fprintln(w, "NAMESPACE\tWORKFLOW\tSTATUS")
+	}
+	for _, res := range results {
+		status := res.status
+		if status == "" {
+			status = "-"
+		}
+		if withResult {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", res.namespace, res.name, status, res.result)
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", res.namespace, res.name, status)
+		}
+	}
+	w.Flush()
+}
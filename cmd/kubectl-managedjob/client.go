@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(jobsmanagerv1beta1.AddToScheme(scheme))
+}
+
+// clusterFlags are the connection flags shared by all the commands
+type clusterFlags struct {
+	kubeconfig string
+	context    string
+	namespace  string
+}
+
+func (cf *clusterFlags) bind(fs *flag.FlagSet) {
+	fs.StringVar(&cf.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file.")
+	fs.StringVar(&cf.context, "context", "", "Name of the kubeconfig context to use.")
+	fs.StringVar(&cf.namespace, "n", "", "Namespace of the workflow, defaults to the kubeconfig namespace.")
+	fs.StringVar(&cf.namespace, "namespace", "", "Namespace of the workflow, defaults to the kubeconfig namespace.")
+}
+
+func (cf *clusterFlags) clientConfig() clientcmd.ClientConfig {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = cf.kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: cf.context}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
+}
+
+func (cf *clusterFlags) restConfig() (*rest.Config, error) {
+	return cf.clientConfig().ClientConfig()
+}
+
+// client returns the API client and the namespace to work in
+func (cf *clusterFlags) client() (client.Client, string, error) {
+	config, err := cf.restConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	namespace := cf.namespace
+	if namespace == "" {
+		namespace, _, err = cf.clientConfig().Namespace()
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	return c, namespace, err
+}
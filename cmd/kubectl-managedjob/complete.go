@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/controllers"
+)
+
+func runComplete(args []string) error {
+	fs := flag.NewFlagSet("complete", flag.ExitOnError)
+	cf := &clusterFlags{}
+	cf.bind(fs)
+	failed := fs.Bool("failed", false, "mark the step as failed instead of succeeded")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: kubectl managedjob complete <name> <group> <job> [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(reorderArgs(fs, args)); err != nil {
+		return err
+	}
+	if fs.NArg() != 3 {
+		fs.Usage()
+		return fmt.Errorf("expected the workflow, the group and the job name")
+	}
+	name, groupName, jobName := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+	outcome := "succeeded"
+	if *failed {
+		outcome = "failed"
+	}
+
+	c, namespace, err := cf.client()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	mj := &jobsmanagerv1beta1.ManagedJob{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, mj); err != nil {
+		return err
+	}
+
+	for i, group := range mj.Spec.Groups {
+		if group.Name != groupName {
+			continue
+		}
+		for j, job := range group.Jobs {
+			if job.Name != jobName {
+				continue
+			}
+			if job.Type != jobsmanagerv1beta1.JobTypeManual {
+				return fmt.Errorf("job %s of group %s is not a manual step", jobName, groupName)
+			}
+			// the outcome set for the previous run counts once the operator forgot it
+			if job.Outcome != "" && mj.OutcomeConsumed(groupName, jobName) {
+				withdraw, err := json.Marshal([]map[string]interface{}{
+					{"op": "test", "path": fmt.Sprintf("/spec/groups/%d/name", i), "value": groupName},
+					{"op": "test", "path": fmt.Sprintf("/spec/groups/%d/jobs/%d/name", i, j), "value": jobName},
+					{"op": "remove", "path": fmt.Sprintf("/spec/groups/%d/jobs/%d/outcome", i, j)},
+				})
+				if err != nil {
+					return err
+				}
+				if err := controllers.WithdrawConsumed(ctx, c, mj, withdraw, func(mj *jobsmanagerv1beta1.ManagedJob) bool {
+					return mj.OutcomeConsumed(groupName, jobName)
+				}); err != nil {
+					return err
+				}
+			}
+			// the tests guard against the groups and jobs being reordered in the meantime
+			patch, err := json.Marshal([]map[string]interface{}{
+				{"op": "test", "path": fmt.Sprintf("/spec/groups/%d/name", i), "value": groupName},
+				{"op": "test", "path": fmt.Sprintf("/spec/groups/%d/jobs/%d/name", i, j), "value": jobName},
+				{"op": "add", "path": fmt.Sprintf("/spec/groups/%d/jobs/%d/outcome", i, j), "value": outcome},
+			})
+			if err != nil {
+				return err
+			}
+			if err := c.Patch(ctx, mj, client.RawPatch(types.JSONPatchType, patch)); err != nil {
+				return err
+			}
+			fmt.Printf("Job %s of group %s of workflow %s marked as %s\n", jobName, groupName, name, outcome)
+			return nil
+		}
+		return fmt.Errorf("group %s of workflow %s has no job %s", groupName, name, jobName)
+	}
+	return fmt.Errorf("workflow %s has no group %s", name, groupName)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"raczylo.com/jobs-manager-operator/pkg/dashboard"
+)
+
+func runDashboard(args []string) error {
+	fs := flag.NewFlagSet("dashboard", flag.ExitOnError)
+	format := fs.String("format", "grafana", "Format of the dashboard, only grafana is supported.")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: kubectl managedjob dashboard [--format grafana] > dashboard.json")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	if *format != "grafana" {
+		return fmt.Errorf("unsupported format %s, expected grafana", *format)
+	}
+
+	grafana, err := dashboard.Grafana()
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(grafana)
+}
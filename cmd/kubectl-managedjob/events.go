@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/pkg/dependencies"
+)
+
+func runEvents(args []string) error {
+	fs := flag.NewFlagSet("events", flag.ExitOnError)
+	cf := &clusterFlags{}
+	cf.bind(fs)
+	var watch bool
+	fs.BoolVar(&watch, "w", false, "Follow the new events after listing the existing ones.")
+	fs.BoolVar(&watch, "watch", false, "Follow the new events after listing the existing ones.")
+	interval := fs.Duration("interval", 2*time.Second, "Polling interval of the new events in the watch mode.")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: kubectl managedjob events <name> [-w] [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(reorderArgs(fs, args)); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one workflow name")
+	}
+
+	c, namespace, err := cf.client()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	// events already printed by their UID and resource version, a repeated event is printed again
+	printed := map[string]bool{}
+	for {
+		mj := &jobsmanagerv1beta1.ManagedJob{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: fs.Arg(0)}, mj); err != nil {
+			return err
+		}
+		var events corev1.EventList
+		if err := c.List(ctx, &events, client.InNamespace(namespace)); err != nil {
+			return err
+		}
+		fresh := []corev1.Event{}
+		for _, event := range workflowEvents(mj, events.Items) {
+			key := string(event.UID) + "/" + event.ResourceVersion
+			if !printed[key] {
+				printed[key] = true
+				fresh = append(fresh, event)
+			}
+		}
+		printEvents(os.Stdout, fresh)
+		if !watch {
+			return nil
+		}
+		time.Sleep(*interval)
+	}
+}
+
+// workflowEvents picks the events of the workflow, its Jobs, their pods and the sub-workflows, deleted
+// ones included, in the chronological order
+func workflowEvents(mj *jobsmanagerv1beta1.ManagedJob, events []corev1.Event) []corev1.Event {
+	// Jobs and sub-workflows are named after the jobs of the workflow, the pods after their Jobs
+	children := map[string]bool{}
+	for _, group := range mj.Spec.Groups {
+		for _, job := range group.Jobs {
+			children[dependencies.JobName(mj.Name, group.Name, job.Name)] = true
+		}
+	}
+	related := []corev1.Event{}
+	for _, event := range events {
+		object := event.InvolvedObject
+		switch object.Kind {
+		case "ManagedJob":
+			if object.Name != mj.Name && !children[object.Name] {
+				continue
+			}
+		case "Job":
+			if !children[object.Name] {
+				continue
+			}
+		case "Pod":
+			// pods are named <job>-<suffix>, the suffix of the indexed ones carries the index too
+			owned := false
+			for name := range children {
+				if strings.HasPrefix(object.Name, name+"-") {
+					owned = true
+					break
+				}
+			}
+			if !owned {
+				continue
+			}
+		default:
+			continue
+		}
+		related = append(related, event)
+	}
+	sort.SliceStable(related, func(i, j int) bool {
+		return eventTime(&related[i]).Before(eventTime(&related[j]))
+	})
+	return related
+}
+
+// eventTime is the last time the event was seen, whichever of the fields the reporter set
+func eventTime(event *corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// printEvents writes one event per line as time, type, reason, kind/name and the message
+func printEvents(w io.Writer, events []corev1.Event) {
+	for i := range events {
+		event := &events[i]
+		message := strings.Join(strings.Fields(event.Message), " ")
+		if event.Count > 1 {
+			message += fmt.Sprintf(" (x%d)", event.Count)
+		}
+		object := strings.ToLower(event.InvolvedObject.Kind) + "/" + event.InvolvedObject.Name
+		fmt.Fprintf(w, "%s  %-7s  %-20s  %s  %s\n", eventTime(event).Local().Format(time.RFC3339), event.Type, event.Reason, object, message)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"raczylo.com/jobs-manager-operator/pkg/lint"
+)
+
+func runLint(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	path := fs.String("f", "", "Manifest file or directory with the workflow manifests.")
+	recursive := fs.Bool("recursive", false, "Read the manifests from the subdirectories as well.")
+	strict := fs.Bool("strict", false, "Fail on the warnings as well.")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: kubectl managedjob lint -f <file|dir> [--recursive] [--strict]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(reorderArgs(fs, args)); err != nil {
+		return err
+	}
+	if *path == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("expected the manifest file or directory")
+	}
+	log.SetLogger(logr.Discard())
+
+	files, err := manifestFiles(*path, *recursive)
+	if err != nil {
+		return err
+	}
+	manifests := []manifest{}
+	for _, file := range files {
+		manifests = append(manifests, readManifests(file)...)
+	}
+	errors, warnings := printLintResults(os.Stdout, manifests)
+	if errors > 0 || (*strict && warnings > 0) {
+		return fmt.Errorf("%d errors and %d warnings in %d manifests", errors, warnings, len(manifests))
+	}
+	return nil
+}
+
+// printLintResults prints the problems prefixed with the file and the workflow, the way compilers do
+func printLintResults(w io.Writer, manifests []manifest) (errorCount, warningCount int) {
+	for _, m := range manifests {
+		if m.err != nil {
+			fmt.Fprintf(w, "%s: error: %v\n", m.file, m.err)
+			errorCount++
+			continue
+		}
+		result := lint.Workflow(m.object.Object)
+		for _, err := range result.Errors {
+			fmt.Fprintf(w, "%s: %s: error: %v\n", m.file, result.Name, err)
+		}
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(w, "%s: %s: warning: %s\n", m.file, result.Name, warning)
+		}
+		errorCount += len(result.Errors)
+		warningCount += len(result.Warnings)
+	}
+	return errorCount, warningCount
+}
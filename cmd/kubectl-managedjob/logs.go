@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/controllers"
+	"raczylo.com/jobs-manager-operator/pkg/dependencies"
+)
+
+func runLogs(args []string) error {
+	fs := flag.NewFlagSet("logs", flag.ExitOnError)
+	cf := &clusterFlags{}
+	cf.bind(fs)
+	jobRef := fs.String("job", "", "Show only the job given as group/job")
+	archived := fs.Bool("archived", false, "List where the logs of the finished jobs were archived instead of printing the logs of their pods.")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: kubectl managedjob logs <name> [--job group/job] [--archived] [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(reorderArgs(fs, args)); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one workflow name")
+	}
+	groupName, jobName := "", ""
+	if *jobRef != "" {
+		var found bool
+		if groupName, jobName, found = strings.Cut(*jobRef, "/"); !found || groupName == "" || jobName == "" {
+			return fmt.Errorf("expected --job as group/job, got %q", *jobRef)
+		}
+	}
+
+	c, namespace, err := cf.client()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	mj := &jobsmanagerv1beta1.ManagedJob{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: fs.Arg(0)}, mj); err != nil {
+		return err
+	}
+	if err := controllers.LoadStatus(ctx, c, mj); err != nil {
+		return err
+	}
+	if *archived {
+		return printArchivedLogs(os.Stdout, mj, groupName, jobName)
+	}
+
+	config, err := cf.restConfig()
+	if err != nil {
+		return err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	return printPodLogs(ctx, os.Stdout, c, clientset, mj, groupName, jobName)
+}
+
+// printArchivedLogs lists the locations of the archived logs of the jobs, the ones still being uploaded are
+// shown as pending
+func printArchivedLogs(out io.Writer, mj *jobsmanagerv1beta1.ManagedJob, groupName, jobName string) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "JOB\tSTATUS\tLOGS")
+	found := false
+	for _, group := range mj.Spec.Groups {
+		for _, job := range group.Jobs {
+			if groupName != "" && (group.Name != groupName || job.Name != jobName) {
+				continue
+			}
+			found = true
+			location := job.ArchivedLogs
+			switch {
+			case job.ArchivePending:
+				location = "<upload pending>"
+			case location == "":
+				location = "<not archived>"
+			}
+			fmt.Fprintf(w, "%s/%s\t%s\t%s\n", group.Name, job.Name, job.Status, location)
+		}
+	}
+	if !found {
+		return fmt.Errorf("job %s/%s not found in the workflow %s", groupName, jobName, mj.Name)
+	}
+	return w.Flush()
+}
+
+// printPodLogs prints the logs of the pods of the jobs which still exist, oldest pods first
+func printPodLogs(ctx context.Context, out io.Writer, c client.Client, clientset kubernetes.Interface, mj *jobsmanagerv1beta1.ManagedJob, groupName, jobName string) error {
+	selector := client.MatchingLabels{"jobmanager.raczylo.com/workflow-name": mj.Name}
+	if groupName != "" {
+		selector = client.MatchingLabels{"jobmanager.raczylo.com/job-name": dependencies.JobName(mj.Name, groupName, jobName)}
+	}
+	var pods corev1.PodList
+	if err := c.List(ctx, &pods, client.InNamespace(mj.Namespace), selector); err != nil {
+		return err
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].CreationTimestamp.Before(&pods.Items[j].CreationTimestamp)
+	})
+	for _, pod := range pods.Items {
+		// the container is named after the Job
+		container := pod.Labels["jobmanager.raczylo.com/job-name"]
+		logs, err := clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{Container: container}).DoRaw(ctx)
+		if err != nil {
+			fmt.Fprintf(out, "==> %s <==\nunable to read the logs: %s\n", pod.Name, err.Error())
+			continue
+		}
+		fmt.Fprintf(out, "==> %s <==\n%s\n", pod.Name, strings.TrimRight(string(logs), "\n"))
+	}
+	if len(pods.Items) == 0 {
+		fmt.Fprintln(out, "No pods left, use --archived to find the archived logs.")
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-managedjob is the kubectl plugin for inspecting and managing the ManagedJob workflows
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+type command struct {
+	description string
+	run         func(args []string) error
+}
+
+var commands = map[string]command{
+	"abort":     {description: "Abort the runs of the named workflows or all the ones matching a selector", run: runAbort},
+	"apply":     {description: "Apply the workflow manifests from a directory and prune the removed ones", run: runApply},
+	"approve":   {description: "Approve the start of a paused group", run: runApprove},
+	"complete":  {description: "Set the outcome of a manual step", run: runComplete},
+	"dashboard": {description: "Print the Grafana dashboard of the operator metrics", run: runDashboard},
+	"events":    {description: "List and follow the events of the workflow, its Jobs and pods", run: runEvents},
+	"lint":      {description: "Validate the workflow manifests offline, the way the webhook and the controller would", run: runLint},
+	"logs":      {description: "Print the logs of the workflow jobs or where they were archived", run: runLogs},
+	"retry":     {description: "Run the named workflows or all the ones matching a selector again", run: runRetry},
+	"rollback":  {description: "Restore a previous definition of the workflow", run: runRollback},
+	"run":       {description: "Apply the workflow manifest running only the selected groups", run: runRun},
+	"simulate":  {description: "Replay the workflow scheduling offline with simulated outcomes", run: runSimulate},
+	"start":     {description: "Start the named workflows created suspended or all the ones matching a selector", run: runStart},
+	"status":    {description: "Show the workflow progress and the estimated completion", run: runStatus},
+	"top":       {description: "Show live resource usage of the workflow jobs", run: runTop},
+	"visualize": {description: "Draw the tree of the workflow groups and jobs", run: runVisualize},
+	"why":       {description: "Explain why a job is in its current state", run: runWhy},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: kubectl managedjob <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, commands[name].description)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, found := commands[os.Args[1]]
+	if !found {
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// reorderArgs moves the flags in front of the positional arguments, so `top name -w` works like `top -w name`
+func reorderArgs(fs *flag.FlagSet, args []string) []string {
+	flags := []string{}
+	positional := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if len(arg) > 1 && arg[0] == '-' {
+			flags = append(flags, arg)
+			name := arg[1:]
+			if len(name) > 0 && name[0] == '-' {
+				name = name[1:]
+			}
+			if f := fs.Lookup(name); f != nil && !isBoolFlag(f) && i+1 < len(args) {
+				flags = append(flags, args[i+1])
+				i++
+			}
+			continue
+		}
+		positional = append(positional, arg)
+	}
+	return append(flags, positional...)
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && boolFlag.IsBoolFlag()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func runRollback(args []string) error {
+	fs := flag.NewFlagSet("rollback", flag.ExitOnError)
+	cf := &clusterFlags{}
+	cf.bind(fs)
+	toRevision := fs.Int64("to-revision", 0, "revision to restore, the one before the current revision when 0")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: kubectl managedjob rollback <name> [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(reorderArgs(fs, args)); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected the workflow name")
+	}
+	name := fs.Arg(0)
+
+	c, namespace, err := cf.client()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	revision := *toRevision
+	// the operator updates the workflow all the time, the rollback is retried on the conflicts
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		mj := &jobsmanagerv1beta1.ManagedJob{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, mj); err != nil {
+			return err
+		}
+		current, err := strconv.ParseInt(mj.Annotations[jobsmanagerv1beta1.RevisionAnnotation], 10, 64)
+		if err != nil {
+			return fmt.Errorf("workflow %s has no revisions recorded, is --revision-history-limit of the operator 0?", name)
+		}
+		if revision == 0 {
+			revision = current - 1
+		}
+		if revision == current {
+			return fmt.Errorf("revision %d is the current revision of workflow %s", revision, name)
+		}
+		configMap := &corev1.ConfigMap{}
+		key := client.ObjectKey{Namespace: namespace, Name: jobsmanagerv1beta1.RevisionConfigMapName(name, revision)}
+		if err := c.Get(ctx, key, configMap); err != nil {
+			return fmt.Errorf("revision %d of workflow %s: %w", revision, name, err)
+		}
+		definition := &jobsmanagerv1beta1.ManagedJobSpec{}
+		if err := yaml.Unmarshal([]byte(configMap.Data[jobsmanagerv1beta1.RevisionDataKey]), definition); err != nil {
+			return fmt.Errorf("revision %d of workflow %s: %w", revision, name, err)
+		}
+		mj.RollbackTo(definition)
+		return c.Update(ctx, mj)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Workflow %s rolled back to revision %d\n", name, revision)
+	return nil
+}
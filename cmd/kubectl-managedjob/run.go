@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func runRun(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	cf := &clusterFlags{}
+	cf.bind(fs)
+	file := fs.String("f", "", "Manifest file with the workflow.")
+	onlyGroups := fs.String("only-groups", "", "Comma separated names of the groups to run, the other ones are skipped. All the groups run when empty.")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: kubectl managedjob run -f <file> [--only-groups group1,group2] [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(reorderArgs(fs, args)); err != nil {
+		return err
+	}
+	if *file == "" || fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("expected the manifest file")
+	}
+
+	manifests := readManifests(*file)
+	if len(manifests) != 1 {
+		return fmt.Errorf("expected a single workflow in %s, found %d", *file, len(manifests))
+	}
+	if manifests[0].err != nil {
+		return manifests[0].err
+	}
+	obj := manifests[0].object
+	groups := []string{}
+	for _, group := range strings.Split(*onlyGroups, ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	if err := enableGroups(obj, groups); err != nil {
+		return err
+	}
+
+	c, namespace, err := cf.client()
+	if err != nil {
+		return err
+	}
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(namespace)
+	}
+	result, err := applyWorkflow(context.Background(), c, obj)
+	if err != nil {
+		return err
+	}
+	running := "all the groups"
+	if len(groups) > 0 {
+		running = "groups " + strings.Join(groups, ", ")
+	}
+	fmt.Fprintf(os.Stdout, "%s/%s %s, running %s\n", obj.GetNamespace(), obj.GetName(), result, running)
+	return nil
+}
+
+// enableGroups sets spec.enabledGroups of the manifest, no groups enable all of them
+func enableGroups(obj *unstructured.Unstructured, groups []string) error {
+	if len(groups) == 0 {
+		unstructured.RemoveNestedField(obj.Object, "spec", "enabledGroups")
+		return nil
+	}
+	mj := &jobsmanagerv1beta1.ManagedJob{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, mj); err != nil {
+		return err
+	}
+	known := map[string]bool{}
+	for _, group := range mj.Spec.Groups {
+		known[group.Name] = true
+	}
+	for _, group := range groups {
+		if !known[group] {
+			return fmt.Errorf("workflow %s has no group %s", mj.Name, group)
+		}
+	}
+	return unstructured.SetNestedStringSlice(obj.Object, groups, "spec", "enabledGroups")
+}
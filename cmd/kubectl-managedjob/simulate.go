@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/controllers"
+	"raczylo.com/jobs-manager-operator/pkg/simulator"
+)
+
+// stringList collects the values of a repeated flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func runSimulate(args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	file := fs.String("f", "", "Path to the ManagedJob manifest, - reads from the standard input.")
+	failures := stringList{}
+	fs.Var(&failures, "fail", "Job failing during the simulation as group/job, can be repeated.")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: kubectl managedjob simulate -f <file> [--fail group/job]...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(reorderArgs(fs, args)); err != nil {
+		return err
+	}
+	if *file == "" {
+		fs.Usage()
+		return fmt.Errorf("expected the workflow manifest")
+	}
+	log.SetLogger(logr.Discard())
+
+	mj, err := readWorkflow(*file)
+	if err != nil {
+		return err
+	}
+	outcomes := simulator.Outcomes{}
+	for _, failure := range failures {
+		outcomes[failure] = controllers.ExecutionStatusFailed
+	}
+
+	result, err := simulator.Simulate(mj, outcomes)
+	if result != nil {
+		printTimeline(os.Stdout, mj.Name, result)
+	}
+	return err
+}
+
+func readWorkflow(file string) (*jobsmanagerv1beta1.ManagedJob, error) {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	mj := &jobsmanagerv1beta1.ManagedJob{}
+	if err := yaml.UnmarshalStrict(data, mj); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", file, err)
+	}
+	if mj.Name == "" {
+		return nil, fmt.Errorf("workflow in %s has no name", file)
+	}
+	return mj, nil
+}
+
+func printTimeline(out io.Writer, name string, result *simulator.Result) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STEP\tGROUP\tJOB\tSTATUS")
+	for _, event := range result.Timeline {
+		job := event.Job
+		if job == "" {
+			job = "-"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", event.Step, event.Group, job, event.Status)
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\nWorkflow %s: %s\n", name, result.Status)
+}
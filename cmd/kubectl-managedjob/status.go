@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/controllers"
+	"raczylo.com/jobs-manager-operator/pkg/visualization"
+)
+
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	cf := &clusterFlags{}
+	cf.bind(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: kubectl managedjob status <name> [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(reorderArgs(fs, args)); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one workflow name")
+	}
+
+	c, namespace, err := cf.client()
+	if err != nil {
+		return err
+	}
+	mj := &jobsmanagerv1beta1.ManagedJob{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: fs.Arg(0)}, mj); err != nil {
+		return err
+	}
+	if err := controllers.LoadStatus(context.Background(), c, mj); err != nil {
+		return err
+	}
+	printStatus(os.Stdout, mj, time.Now())
+	return nil
+}
+
+func printStatus(w io.Writer, mj *jobsmanagerv1beta1.ManagedJob, now time.Time) {
+	fmt.Fprintf(w, "Workflow:  %s\n", mj.Name)
+	fmt.Fprintf(w, "Status:    %s\n", mj.Status.Phase)
+	if len(mj.Spec.RunHistory) > 0 {
+		run := mj.Spec.RunHistory[len(mj.Spec.RunHistory)-1]
+		if run.ID != "" {
+			fmt.Fprintf(w, "Run:       %s\n", run.ID)
+		}
+		fmt.Fprintf(w, "Started:   %s (%s ago)\n", run.StartedAt.Format(time.RFC3339), now.Sub(run.StartedAt.Time).Truncate(time.Second))
+		if run.CompletedAt != nil {
+			fmt.Fprintf(w, "Completed: %s (took %s)\n", run.CompletedAt.Format(time.RFC3339), run.CompletedAt.Sub(run.StartedAt.Time).Truncate(time.Second))
+		}
+	}
+	if mj.Spec.QueuePosition > 0 {
+		fmt.Fprintf(w, "Queue:     position %d\n", mj.Spec.QueuePosition)
+	}
+	if eta := mj.Spec.EstimatedCompletion; eta != nil {
+		remaining := eta.Sub(now).Truncate(time.Second)
+		if remaining > 0 {
+			fmt.Fprintf(w, "ETA:       %s (in %s)\n", eta.Format(time.RFC3339), remaining)
+		} else {
+			fmt.Fprintf(w, "ETA:       %s (overdue by %s)\n", eta.Format(time.RFC3339), -remaining)
+		}
+	}
+	if mj.Spec.EstimatedCost != "" {
+		fmt.Fprintf(w, "Cost:      %s (estimated)\n", mj.Spec.EstimatedCost)
+	}
+	if invalid := meta.FindStatusCondition(mj.Spec.Conditions, controllers.ConditionInvalidSpec); invalid != nil && invalid.Status == metav1.ConditionTrue {
+		fmt.Fprintf(w, "Invalid:   %s\n", invalid.Message)
+	}
+	if degraded := meta.FindStatusCondition(mj.Spec.Conditions, controllers.ConditionReconcileDegraded); degraded != nil && degraded.Status == metav1.ConditionTrue {
+		fmt.Fprintf(w, "Degraded:  %s\n", degraded.Message)
+	}
+	fmt.Fprintln(w)
+
+	// pending is shown as blocked or queued, the reason keeps the details
+	withCost := mj.Spec.EstimatedCost != ""
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	header := "GROUP\tJOB\tSTATUS\tREASON"
+	if withCost {
+		header += "\tCOST"
+	}
+	fmt.Fprintln(tw, header)
+	row := func(group, job, status, reason, cost string) {
+		line := fmt.Sprintf("%s\t%s\t%s\t%s", group, job, visualization.DisplayStatus(status, reason), reason)
+		if withCost {
+			line += "\t" + orDash(cost)
+		}
+		fmt.Fprintln(tw, line)
+	}
+	for _, group := range mj.Spec.Groups {
+		row(group.Name, "-", group.Status, group.Reason, group.EstimatedCost())
+		for _, job := range group.Jobs {
+			row(group.Name, job.Name, job.Status, job.Reason, job.EstimatedCost)
+		}
+	}
+	tw.Flush()
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"os"
+)
+
+// ciVariables are set by the common CI systems, which keep the escape sequences in the collected logs
+var ciVariables = []string{"CI", "BUILD_NUMBER", "GITHUB_ACTIONS", "GITLAB_CI", "JENKINS_URL", "TEAMCITY_VERSION", "BUILDKITE", "TF_BUILD"}
+
+// ansiEnabled tells if the escape sequences may be written to the output - not with --no-color, NO_COLOR
+// set to any value (https://no-color.org), TERM=dumb, in CI or when the output is not a terminal
+func ansiEnabled(out *os.File, noColor bool) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	for _, variable := range ciVariables {
+		if os.Getenv(variable) != "" {
+			return false
+		}
+	}
+	info, err := out.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
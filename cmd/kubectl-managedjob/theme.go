@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"raczylo.com/jobs-manager-operator/pkg/visualization"
+)
+
+// statusColors collects the repeated --status-color flags
+type statusColors map[string]string
+
+func (c statusColors) String() string {
+	values := []string{}
+	for status, color := range c {
+		values = append(values, status+"="+color)
+	}
+	sort.Strings(values)
+	return strings.Join(values, ",")
+}
+
+func (c statusColors) Set(value string) error {
+	status, color, found := strings.Cut(value, "=")
+	if !found || status == "" {
+		return fmt.Errorf("expected status=color, got %q", value)
+	}
+	c[status] = color
+	return nil
+}
+
+// themeFlags select the theme of the trees drawn by the plugin, on top of the theme file
+type themeFlags struct {
+	file    string
+	ascii   bool
+	noColor bool
+	colors  statusColors
+}
+
+func (tf *themeFlags) bind(fs *flag.FlagSet) {
+	tf.colors = statusColors{}
+	fs.StringVar(&tf.file, "theme", "", "Theme file with the tree characters and the status colors, $XDG_CONFIG_HOME/kubectl-managedjob/theme.yaml is used when it exists.")
+	fs.BoolVar(&tf.ascii, "ascii", false, "Draw the tree with the ASCII characters.")
+	fs.BoolVar(&tf.noColor, "no-color", false, "Print the statuses without colors and the watch refreshes one after another instead of redrawing the screen, the default with NO_COLOR, in CI and when the output is not a terminal.")
+	fs.Var(tf.colors, "status-color", "Color of the status as status=color, e.g. skipped=gray, can be repeated. Colors: "+strings.Join(visualization.ColorNames(), ", ")+".")
+}
+
+// theme returns the theme of the output, the colors are dropped when the output can't show them
+func (tf *themeFlags) theme(out *os.File) (visualization.Theme, error) {
+	theme := visualization.Theme{Colors: visualization.DefaultColors()}
+	path, explicit := tf.file, tf.file != ""
+	if !explicit {
+		if configDir, err := os.UserConfigDir(); err == nil {
+			path = filepath.Join(configDir, "kubectl-managedjob", "theme.yaml")
+		}
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist) && !explicit:
+		case err != nil:
+			return theme, err
+		default:
+			file := visualization.Theme{}
+			if err := yaml.UnmarshalStrict(data, &file); err != nil {
+				return theme, fmt.Errorf("theme %s: %w", path, err)
+			}
+			theme.ASCII = file.ASCII
+			for status, color := range file.Colors {
+				theme.Colors[status] = color
+			}
+		}
+	}
+	theme.ASCII = theme.ASCII || tf.ascii
+	for status, color := range tf.colors {
+		theme.Colors[status] = color
+	}
+	if err := theme.Validate(); err != nil {
+		return theme, err
+	}
+	if !ansiEnabled(out, tf.noColor) {
+		theme.Colors = nil
+	}
+	return theme, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+var podMetricsListGVK = schema.GroupVersionKind{Group: "metrics.k8s.io", Version: "v1beta1", Kind: "PodMetricsList"}
+
+type resourceUsage struct {
+	cpu    resource.Quantity
+	memory resource.Quantity
+}
+
+func (u *resourceUsage) add(other resourceUsage) {
+	u.cpu.Add(other.cpu)
+	u.memory.Add(other.memory)
+}
+
+func runTop(args []string) error {
+	fs := flag.NewFlagSet("top", flag.ExitOnError)
+	cf := &clusterFlags{}
+	cf.bind(fs)
+	watch := fs.Bool("w", false, "Refresh the usage continuously.")
+	interval := fs.Duration("interval", 5*time.Second, "Refresh interval in the watch mode.")
+	noColor := fs.Bool("no-color", false, "Print the refreshes one after another instead of redrawing the screen, the default with NO_COLOR, in CI and when the output is not a terminal.")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: kubectl managedjob top <name> [-w] [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(reorderArgs(fs, args)); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one workflow name")
+	}
+	name := fs.Arg(0)
+
+	c, namespace, err := cf.client()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	redraw := ansiEnabled(os.Stdout, *noColor)
+
+	for {
+		mj := &jobsmanagerv1beta1.ManagedJob{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, mj); err != nil {
+			return err
+		}
+		mj.MergeStatus()
+		top, err := collectUsage(ctx, c, mj)
+		if err != nil {
+			return err
+		}
+		switch {
+		case *watch && redraw:
+			fmt.Print("\033[H\033[2J")
+		case *watch:
+			fmt.Printf("--- %s\n", time.Now().Format(time.RFC3339))
+		}
+		printTop(os.Stdout, top)
+		if !*watch {
+			return nil
+		}
+		time.Sleep(*interval)
+	}
+}
+
+// workflowTop holds the usage of the workflow pods keyed by group and job name
+type workflowTop struct {
+	workflow *jobsmanagerv1beta1.ManagedJob
+	jobs     map[string]map[string]resourceUsage
+}
+
+func collectUsage(ctx context.Context, c client.Client, mj *jobsmanagerv1beta1.ManagedJob) (*workflowTop, error) {
+	selector := client.MatchingLabels{"jobmanager.raczylo.com/workflow-name": mj.Name}
+
+	var pods corev1.PodList
+	if err := c.List(ctx, &pods, client.InNamespace(mj.Namespace), selector); err != nil {
+		return nil, err
+	}
+	podMetrics := &unstructured.UnstructuredList{}
+	podMetrics.SetGroupVersionKind(podMetricsListGVK)
+	if err := c.List(ctx, podMetrics, client.InNamespace(mj.Namespace), selector); err != nil {
+		return nil, fmt.Errorf("unable to get pod metrics, is metrics-server installed? %w", err)
+	}
+
+	podUsage := map[string]resourceUsage{}
+	for _, item := range podMetrics.Items {
+		containers, _, _ := unstructured.NestedSlice(item.Object, "containers")
+		usage := resourceUsage{}
+		for _, container := range containers {
+			containerUsage, _, _ := unstructured.NestedStringMap(container.(map[string]interface{}), "usage")
+			if cpu, err := resource.ParseQuantity(containerUsage["cpu"]); err == nil {
+				usage.cpu.Add(cpu)
+			}
+			if memory, err := resource.ParseQuantity(containerUsage["memory"]); err == nil {
+				usage.memory.Add(memory)
+			}
+		}
+		podUsage[item.GetName()] = usage
+	}
+
+	top := &workflowTop{workflow: mj, jobs: map[string]map[string]resourceUsage{}}
+	for _, pod := range pods.Items {
+		groupName := pod.Labels["jobmanager.raczylo.com/group-name"]
+		jobName := pod.Labels["jobmanager.raczylo.com/job-id"]
+		if top.jobs[groupName] == nil {
+			top.jobs[groupName] = map[string]resourceUsage{}
+		}
+		usage := top.jobs[groupName][jobName]
+		usage.add(podUsage[pod.Name])
+		top.jobs[groupName][jobName] = usage
+	}
+	return top, nil
+}
+
+func printTop(w io.Writer, top *workflowTop) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "GROUP\tJOB\tSTATUS\tCPU\tMEMORY")
+
+	workflowTotal := resourceUsage{}
+	for _, group := range top.workflow.Spec.Groups {
+		groupTotal := resourceUsage{}
+		for _, job := range group.Jobs {
+			usage := top.jobs[group.Name][job.Name]
+			groupTotal.add(usage)
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", group.Name, job.Name, job.Status, formatCPU(usage.cpu), formatMemory(usage.memory))
+		}
+		workflowTotal.add(groupTotal)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", group.Name, "(total)", group.Status, formatCPU(groupTotal.cpu), formatMemory(groupTotal.memory))
+	}
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", top.workflow.Name, "(total)", top.workflow.Status.Phase, formatCPU(workflowTotal.cpu), formatMemory(workflowTotal.memory))
+	tw.Flush()
+}
+
+func formatCPU(q resource.Quantity) string {
+	return fmt.Sprintf("%dm", q.MilliValue())
+}
+
+func formatMemory(q resource.Quantity) string {
+	return fmt.Sprintf("%dMi", q.Value()/(1024*1024))
+}
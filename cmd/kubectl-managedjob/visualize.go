@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/controllers"
+	"raczylo.com/jobs-manager-operator/pkg/dependencies"
+	"raczylo.com/jobs-manager-operator/pkg/visualization"
+	"raczylo.com/jobs-manager-operator/pkg/visualization/v1alpha1"
+)
+
+// visualizeFlags are the flags of the visualize command
+type visualizeFlags struct {
+	cluster    clusterFlags
+	theme      themeFlags
+	file       string
+	verbose    bool
+	columns    bool
+	output     string
+	watch      bool
+	interval   time.Duration
+	tailFailed bool
+}
+
+func newVisualizeFlagSet(vf *visualizeFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet("visualize", flag.ExitOnError)
+	vf.cluster.bind(fs)
+	fs.StringVar(&vf.file, "f", "", "Path to the ManagedJob manifest to draw instead of the workflow from the cluster, - reads from the standard input.")
+	fs.BoolVar(&vf.verbose, "verbose", false, "Show the descriptions and dependencies of the groups and jobs.")
+	fs.BoolVar(&vf.columns, "columns", false, "Align the statuses and durations in columns.")
+	fs.StringVar(&vf.output, "o", "text", "Output format, text or json (schema "+v1alpha1.SchemaVersion+").")
+	fs.BoolVar(&vf.watch, "w", false, "Redraw the tree of the workflow from the cluster continuously.")
+	fs.BoolVar(&vf.watch, "watch", false, "Redraw the tree of the workflow from the cluster continuously.")
+	fs.DurationVar(&vf.interval, "interval", 5*time.Second, "Refresh interval in the watch mode.")
+	fs.BoolVar(&vf.tailFailed, "tail-failed", false, fmt.Sprintf("Show the last %d log lines of the failed jobs under their nodes, needs --watch.", failedTailLines))
+	// --no-color of the theme also prints the refreshes one after another instead of redrawing the screen
+	vf.theme.bind(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: kubectl managedjob visualize (<name> | -f <file>) [--verbose] [--columns] [--ascii] [-o text|json] [-w [--tail-failed]] [flags]")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func runVisualize(args []string) error {
+	vf := &visualizeFlags{}
+	fs := newVisualizeFlagSet(vf)
+	if err := fs.Parse(reorderArgs(fs, args)); err != nil {
+		return err
+	}
+	if vf.output != "text" && vf.output != "json" {
+		return fmt.Errorf("unsupported output format %q, expected text or json", vf.output)
+	}
+	if vf.watch && (vf.file != "" || vf.output != "text") {
+		return fmt.Errorf("--watch draws the workflow from the cluster as text only")
+	}
+	if vf.tailFailed && !vf.watch {
+		return fmt.Errorf("--tail-failed needs --watch")
+	}
+	theme, err := vf.theme.theme(os.Stdout)
+	if err != nil {
+		return err
+	}
+	renderer := visualization.Renderer{Verbose: vf.verbose, Columns: vf.columns, Theme: theme}
+
+	switch {
+	case vf.file != "" && fs.NArg() == 0:
+		mj, err := readWorkflow(vf.file)
+		if err != nil {
+			return err
+		}
+		// implicit dependencies are added by the operator, resolve them the same way
+		if err := controllers.ResolveDependencies(mj); err != nil {
+			return err
+		}
+		return printTree(renderer, vf.output, visualization.FromManagedJob(mj))
+	case vf.file == "" && fs.NArg() == 1:
+	default:
+		fs.Usage()
+		return fmt.Errorf("expected either the workflow name or the manifest file")
+	}
+
+	c, namespace, err := vf.cluster.client()
+	if err != nil {
+		return err
+	}
+	var clientset kubernetes.Interface
+	if vf.tailFailed {
+		config, err := vf.cluster.restConfig()
+		if err != nil {
+			return err
+		}
+		if clientset, err = kubernetes.NewForConfig(config); err != nil {
+			return err
+		}
+	}
+	ctx := context.Background()
+	redraw := ansiEnabled(os.Stdout, vf.theme.noColor)
+	// logs of the pods already read, the pods of the failed jobs don't log anymore
+	tails := map[string][]string{}
+	for {
+		mj := &jobsmanagerv1beta1.ManagedJob{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: fs.Arg(0)}, mj); err != nil {
+			return err
+		}
+		if err := controllers.LoadStatus(ctx, c, mj); err != nil {
+			return err
+		}
+		var jobs kbatch.JobList
+		if err := c.List(ctx, &jobs, client.InNamespace(namespace), client.MatchingLabels{"jobmanager.raczylo.com/workflow-name": mj.Name}); err != nil {
+			return err
+		}
+		root := visualization.FromManagedJob(mj)
+		visualization.AddDurations(root, jobs.Items, time.Now())
+		if clientset != nil {
+			if err := tailFailedJobs(ctx, c, clientset, mj, root, tails); err != nil {
+				return err
+			}
+		}
+		switch {
+		case vf.watch && redraw:
+			fmt.Print("\033[H\033[2J")
+		case vf.watch:
+			fmt.Printf("--- %s\n", time.Now().Format(time.RFC3339))
+		}
+		if err := printTree(renderer, vf.output, root); err != nil || !vf.watch {
+			return err
+		}
+		time.Sleep(vf.interval)
+	}
+}
+
+// failedTailLines of the failed jobs shown with --tail-failed
+const failedTailLines = 20
+
+func printTree(renderer visualization.Renderer, output string, root *visualization.Node) error {
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(visualization.Export(root))
+	}
+	return renderer.Render(os.Stdout, root)
+}
+
+// tailFailedJobs adds the last log lines of the latest pod of every failed job to its node, the logs of
+// the pods already read are taken from tails
+func tailFailedJobs(ctx context.Context, c client.Client, clientset kubernetes.Interface, mj *jobsmanagerv1beta1.ManagedJob, root *visualization.Node, tails map[string][]string) error {
+	var pods corev1.PodList
+	if err := c.List(ctx, &pods, client.InNamespace(mj.Namespace), client.MatchingLabels{"jobmanager.raczylo.com/workflow-name": mj.Name}); err != nil {
+		return err
+	}
+	latest := map[string]*corev1.Pod{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		jobName := pod.Labels["jobmanager.raczylo.com/job-name"]
+		if current, found := latest[jobName]; !found || current.CreationTimestamp.Before(&pod.CreationTimestamp) {
+			latest[jobName] = pod
+		}
+	}
+
+	tailLines := int64(failedTailLines)
+	for _, group := range root.Children {
+		for _, job := range group.Children {
+			if job.Status != controllers.ExecutionStatusFailed {
+				continue
+			}
+			// the container is named after the Job
+			jobName := dependencies.JobName(mj.Name, group.Name, job.Name)
+			pod := latest[jobName]
+			if pod == nil {
+				continue
+			}
+			if _, read := tails[pod.Name]; !read {
+				logs, err := clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{Container: jobName, TailLines: &tailLines}).DoRaw(ctx)
+				if err != nil {
+					logs = []byte("unable to read the logs: " + err.Error())
+				}
+				tails[pod.Name] = strings.Split(strings.TrimRight(string(logs), "\n"), "\n")
+			}
+			job.Logs = tails[pod.Name]
+		}
+	}
+	return nil
+}
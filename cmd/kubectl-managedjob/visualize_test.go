@@ -0,0 +1,14 @@
+package main
+
+import "testing"
+
+func TestVisualizeFlags(t *testing.T) {
+	vf := &visualizeFlags{}
+	fs := newVisualizeFlagSet(vf)
+	if err := fs.Parse(reorderArgs(fs, []string{"nightly", "--no-color", "--watch"})); err != nil {
+		t.Fatal(err)
+	}
+	if !vf.theme.noColor || !vf.watch || fs.Arg(0) != "nightly" {
+		t.Errorf("expected --no-color and --watch set for nightly, got %+v %v", vf, fs.Args())
+	}
+}
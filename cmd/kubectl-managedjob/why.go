@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/controllers"
+)
+
+func runWhy(args []string) error {
+	fs := flag.NewFlagSet("why", flag.ExitOnError)
+	cf := &clusterFlags{}
+	cf.bind(fs)
+	jobRef := fs.String("job", "", "Job to explain as group/job")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: kubectl managedjob why <name> --job group/job [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(reorderArgs(fs, args)); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one workflow name")
+	}
+	groupName, jobName, found := strings.Cut(*jobRef, "/")
+	if !found || groupName == "" || jobName == "" {
+		return fmt.Errorf("expected --job as group/job, got %q", *jobRef)
+	}
+
+	c, namespace, err := cf.client()
+	if err != nil {
+		return err
+	}
+	mj := &jobsmanagerv1beta1.ManagedJob{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: fs.Arg(0)}, mj); err != nil {
+		return err
+	}
+	if err := controllers.LoadStatus(context.Background(), c, mj); err != nil {
+		return err
+	}
+	return printWhy(os.Stdout, mj, groupName, jobName)
+}
+
+func printWhy(w io.Writer, mj *jobsmanagerv1beta1.ManagedJob, groupName, jobName string) error {
+	lines, err := controllers.ExplainJob(mj, groupName, jobName)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+const (
+	DefaultConformanceImage   = "busybox:1.36"
+	DefaultConformanceTimeout = 10 * time.Minute
+
+	conformanceRequeue = 10 * time.Second
+)
+
+// ManagedJobConformanceReconciler runs the canary workflows of the conformance scenarios and reports
+// their results, the workflows themselves are run by the ManagedJob controller like any other
+type ManagedJobConformanceReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Clock stamps the results and drives the timeout, the real clock when nil
+	Clock clock.Clock
+}
+
+//+kubebuilder:rbac:groups=jobsmanager.raczylo.com,resources=managedjobconformances,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=jobsmanager.raczylo.com,resources=managedjobconformances/status,verbs=get;update;patch
+
+func (r *ManagedJobConformanceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var conformance jobsmanagerv1beta1.ManagedJobConformance
+	if err := r.Get(ctx, req.NamespacedName, &conformance); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	status := &conformance.Status
+	if status.Phase == jobsmanagerv1beta1.ConformancePhasePassed || status.Phase == jobsmanagerv1beta1.ConformancePhaseFailed {
+		return ctrl.Result{}, nil
+	}
+
+	now := r.now()
+	if status.StartedAt == nil {
+		started := metav1.NewTime(now)
+		status.StartedAt = &started
+		status.Phase = jobsmanagerv1beta1.ConformancePhaseRunning
+		status.Results = conformanceResults(&conformance)
+		r.Recorder.Eventf(&conformance, corev1.EventTypeNormal, "Started", "Running %d conformance scenarios", len(status.Results))
+	}
+	timeout := DefaultConformanceTimeout
+	if conformance.Spec.Timeout != nil && conformance.Spec.Timeout.Duration > 0 {
+		timeout = conformance.Spec.Timeout.Duration
+	}
+	timedOut := now.After(status.StartedAt.Add(timeout))
+
+	var errs []error
+	for i := range status.Results {
+		result := &status.Results[i]
+		if result.Phase != jobsmanagerv1beta1.ConformancePhaseRunning {
+			continue
+		}
+		if err := r.checkScenario(ctx, &conformance, result); err != nil {
+			log.Log.Info("Unable to check the conformance scenario", "conformance", conformance.Name, "scenario", result.Scenario, "error", err.Error())
+			errs = append(errs, err)
+		}
+		if result.Phase == jobsmanagerv1beta1.ConformancePhaseRunning && timedOut {
+			result.Phase = jobsmanagerv1beta1.ConformancePhaseFailed
+			result.Message = fmt.Sprintf("timed out after %s", timeout)
+		}
+	}
+
+	passed, finished := 0, 0
+	for _, result := range status.Results {
+		switch result.Phase {
+		case jobsmanagerv1beta1.ConformancePhasePassed:
+			passed++
+			finished++
+		case jobsmanagerv1beta1.ConformancePhaseFailed:
+			finished++
+		}
+	}
+	status.Summary = fmt.Sprintf("%d/%d", passed, len(status.Results))
+	if finished == len(status.Results) {
+		completed := metav1.NewTime(now)
+		status.CompletedAt = &completed
+		if passed == len(status.Results) {
+			status.Phase = jobsmanagerv1beta1.ConformancePhasePassed
+			r.Recorder.Eventf(&conformance, corev1.EventTypeNormal, "Passed", "All %d conformance scenarios passed", passed)
+		} else {
+			status.Phase = jobsmanagerv1beta1.ConformancePhaseFailed
+			r.Recorder.Eventf(&conformance, corev1.EventTypeWarning, "Failed", "%d of %d conformance scenarios failed", len(status.Results)-passed, len(status.Results))
+		}
+	}
+	if err := r.Status().Update(ctx, &conformance); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if status.Phase != jobsmanagerv1beta1.ConformancePhaseRunning {
+		return ctrl.Result{}, nil
+	}
+	// the owned workflows wake the run up, the requeue catches the timeout
+	return ctrl.Result{RequeueAfter: conformanceRequeue}, nil
+}
+
+// conformanceResults returns the running results of the selected scenarios, all of them when none is selected
+func conformanceResults(conformance *jobsmanagerv1beta1.ManagedJobConformance) []jobsmanagerv1beta1.ManagedJobConformanceResult {
+	scenarios := conformance.Spec.Scenarios
+	if len(scenarios) == 0 {
+		scenarios = jobsmanagerv1beta1.ConformanceScenarios
+	}
+	results := []jobsmanagerv1beta1.ManagedJobConformanceResult{}
+	seen := map[jobsmanagerv1beta1.ConformanceScenario]bool{}
+	for _, scenario := range scenarios {
+		if seen[scenario] {
+			continue
+		}
+		seen[scenario] = true
+		results = append(results, jobsmanagerv1beta1.ManagedJobConformanceResult{
+			Scenario: scenario,
+			Workflow: jobNameGenerator(conformance.Name, string(scenario)),
+			Phase:    jobsmanagerv1beta1.ConformancePhaseRunning,
+		})
+	}
+	return results
+}
+
+// checkScenario creates the canary workflow of the scenario, or checks the existing one
+func (r *ManagedJobConformanceReconciler) checkScenario(ctx context.Context, conformance *jobsmanagerv1beta1.ManagedJobConformance, result *jobsmanagerv1beta1.ManagedJobConformanceResult) error {
+	scenario, known := conformanceScenarios[result.Scenario]
+	if !known {
+		result.Phase = jobsmanagerv1beta1.ConformancePhaseFailed
+		result.Message = "unknown scenario"
+		return nil
+	}
+
+	var mj jobsmanagerv1beta1.ManagedJob
+	err := r.Get(ctx, types.NamespacedName{Namespace: conformance.Namespace, Name: result.Workflow}, &mj)
+	if apierrors.IsNotFound(err) {
+		return r.createCanary(ctx, conformance, result, scenario)
+	}
+	if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(&mj, conformance) {
+		result.Phase = jobsmanagerv1beta1.ConformancePhaseFailed
+		result.Message = fmt.Sprintf("workflow %s exists already and is not owned by the conformance run", result.Workflow)
+		return nil
+	}
+	mj.MergeStatus()
+	phase, message, err := scenario.check(ctx, r.Client, &mj)
+	if err != nil {
+		return err
+	}
+	if phase != "" {
+		result.Phase = phase
+	}
+	result.Message = message
+	return nil
+}
+
+func (r *ManagedJobConformanceReconciler) createCanary(ctx context.Context, conformance *jobsmanagerv1beta1.ManagedJobConformance, result *jobsmanagerv1beta1.ManagedJobConformanceResult, scenario conformanceScenario) error {
+	image := conformance.Spec.Image
+	if image == "" {
+		image = DefaultConformanceImage
+	}
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      result.Workflow,
+			Namespace: conformance.Namespace,
+			// the shard label of the conformance run makes its workflows reconciled by the same shard
+			Labels: conformance.Labels,
+		},
+		Spec: scenario.workflow(image),
+	}
+	if err := controllerutil.SetControllerReference(conformance, mj, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Create(ctx, mj); err != nil {
+		if apierrors.IsInvalid(err) || apierrors.IsForbidden(err) {
+			result.Phase = jobsmanagerv1beta1.ConformancePhaseFailed
+			result.Message = "canary workflow rejected: " + err.Error()
+			return nil
+		}
+		return err
+	}
+	result.Message = "canary workflow created"
+	return nil
+}
+
+func (r *ManagedJobConformanceReconciler) now() time.Time {
+	if r.Clock == nil {
+		return time.Now()
+	}
+	return r.Clock.Now()
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ManagedJobConformanceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&jobsmanagerv1beta1.ManagedJobConformance{}).
+		Owns(&jobsmanagerv1beta1.ManagedJob{}).
+		Complete(r)
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestConformanceRun(t *testing.T) {
+	conformance := &jobsmanagerv1beta1.ManagedJobConformance{
+		ObjectMeta: metav1.ObjectMeta{Name: "upgrade", Namespace: "canary", UID: "c0f1", Labels: map[string]string{"shard": "a"}},
+		Spec:       jobsmanagerv1beta1.ManagedJobConformanceSpec{Scenarios: []jobsmanagerv1beta1.ConformanceScenario{jobsmanagerv1beta1.ConformanceFailure, jobsmanagerv1beta1.ConformanceAbort}},
+	}
+	c := newTestClientBuilder(conformance).WithStatusSubresource(conformance).Build()
+	clock := clocktesting.NewFakeClock(time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC))
+	r := &ManagedJobConformanceReconciler{Client: c, Scheme: c.Scheme(), Recorder: record.NewFakeRecorder(10), Clock: clock}
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "upgrade", Namespace: "canary"}
+	reconcile := func() *jobsmanagerv1beta1.ManagedJobConformance {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatal(err)
+		}
+		current := &jobsmanagerv1beta1.ManagedJobConformance{}
+		if err := c.Get(ctx, key, current); err != nil {
+			t.Fatal(err)
+		}
+		return current
+	}
+	canary := func(name string) *jobsmanagerv1beta1.ManagedJob {
+		t.Helper()
+		mj := &jobsmanagerv1beta1.ManagedJob{}
+		if err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: "canary"}, mj); err != nil {
+			t.Fatal(err)
+		}
+		return mj
+	}
+
+	current := reconcile()
+	if current.Status.Phase != jobsmanagerv1beta1.ConformancePhaseRunning || len(current.Status.Results) != 2 || current.Status.Summary != "0/2" {
+		t.Fatalf("unexpected status %+v", current.Status)
+	}
+	failure, abort := canary("upgrade-failure"), canary("upgrade-abort")
+	if !metav1.IsControlledBy(failure, current) || failure.Labels["shard"] != "a" || failure.Spec.Groups[0].Jobs[0].Image != DefaultConformanceImage {
+		t.Errorf("unexpected canary workflow %+v", failure.ObjectMeta)
+	}
+
+	// the operator fails the workflow and starts the job to abort
+	failure.Status.Phase = ExecutionStatusFailed
+	failure.Spec.Groups[0].Jobs[0].Status = ExecutionStatusFailed
+	failure.Spec.Groups[0].Jobs[1].Status, failure.Spec.Groups[0].Jobs[1].Reason = ExecutionStatusAborted, ReasonDependencyFailed
+	failure.Spec.FailureDigest = "checks/broken failed: BackoffLimitExceeded, checks/after aborted: DependencyFailed"
+	abort.Spec.Groups[0].Jobs[0].Status = ExecutionStatusRunning
+	for _, mj := range []*jobsmanagerv1beta1.ManagedJob{failure, abort} {
+		// the operator keeps the runtime state in the status
+		mj.SplitStatus()
+		if err := c.Update(ctx, mj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	current = reconcile()
+	if result := current.Status.Results[0]; result.Phase != jobsmanagerv1beta1.ConformancePhasePassed || result.Message != "run failed: "+failure.Status.FailureDigest {
+		t.Errorf("unexpected failure result %+v", result)
+	}
+	abort = canary("upgrade-abort")
+	if abort.Annotations[AnnotationAction] != ActionAbort || current.Status.Results[1].Phase != jobsmanagerv1beta1.ConformancePhaseRunning {
+		t.Fatalf("expected the abort requested, got %v and %+v", abort.Annotations, current.Status.Results[1])
+	}
+
+	delete(abort.Annotations, AnnotationAction)
+	abort.Spec.Groups[0].Jobs[0].Status, abort.Spec.Groups[0].Jobs[0].Reason = ExecutionStatusAborted, ReasonAborted
+	abort.SplitStatus()
+	if err := c.Update(ctx, abort); err != nil {
+		t.Fatal(err)
+	}
+	current = reconcile()
+	if current.Status.Phase != jobsmanagerv1beta1.ConformancePhasePassed || current.Status.Summary != "2/2" || current.Status.CompletedAt == nil {
+		t.Errorf("expected the run passed, got %+v", current.Status)
+	}
+}
+
+func TestConformanceTimeout(t *testing.T) {
+	conformance := &jobsmanagerv1beta1.ManagedJobConformance{
+		ObjectMeta: metav1.ObjectMeta{Name: "upgrade", Namespace: "canary", UID: "c0f1"},
+		Spec: jobsmanagerv1beta1.ManagedJobConformanceSpec{
+			Scenarios: []jobsmanagerv1beta1.ConformanceScenario{jobsmanagerv1beta1.ConformanceSerial},
+			Timeout:   &metav1.Duration{Duration: 5 * time.Minute},
+		},
+	}
+	c := newTestClientBuilder(conformance).WithStatusSubresource(conformance).Build()
+	clock := clocktesting.NewFakeClock(time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC))
+	r := &ManagedJobConformanceReconciler{Client: c, Scheme: c.Scheme(), Recorder: record.NewFakeRecorder(10), Clock: clock}
+	key := types.NamespacedName{Name: "upgrade", Namespace: "canary"}
+
+	for _, step := range []time.Duration{0, 6 * time.Minute} {
+		clock.Step(step)
+		if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	current := &jobsmanagerv1beta1.ManagedJobConformance{}
+	_ = c.Get(context.Background(), key, current)
+	if current.Status.Phase != jobsmanagerv1beta1.ConformancePhaseFailed || current.Status.Results[0].Message != "timed out after 5m0s" {
+		t.Errorf("expected the run timed out, got %+v", current.Status)
+	}
+}
@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	kbatch "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+/*
+Conformance scenarios - every scenario is a small canary workflow and the check of what the operator did
+with it. The checks look at the workflow and its Jobs only, the way a user would, and take the actions of
+the scenario (retry, abort) through the action annotation like `kubectl managedjob` does.
+*/
+
+// conformanceScenario builds the canary workflow and checks it, the check returns no phase while the
+// scenario is still running
+type conformanceScenario struct {
+	workflow func(image string) jobsmanagerv1beta1.ManagedJobSpec
+	check    func(ctx context.Context, c client.Client, mj *jobsmanagerv1beta1.ManagedJob) (string, string, error)
+}
+
+var conformanceScenarios = map[jobsmanagerv1beta1.ConformanceScenario]conformanceScenario{
+	jobsmanagerv1beta1.ConformanceSerial: {
+		workflow: func(image string) jobsmanagerv1beta1.ManagedJobSpec {
+			return canaryWorkflow(
+				canaryGroup("first", false, canaryJob("one", image, "sleep 2"), canaryJob("two", image, "sleep 2")),
+				canaryGroup("second", false, canaryJob("three", image, "true")),
+			)
+		},
+		check: func(ctx context.Context, c client.Client, mj *jobsmanagerv1beta1.ManagedJob) (string, string, error) {
+			if phase, message := expectSucceeded(mj); phase != jobsmanagerv1beta1.ConformancePhasePassed {
+				return phase, message, nil
+			}
+			jobs, err := canaryJobs(ctx, c, mj, "first/one", "first/two", "second/three")
+			if err != nil {
+				return "", "", err
+			}
+			for i := 1; i < len(jobs); i++ {
+				previous, next := jobs[i-1], jobs[i]
+				if previous.Status.CompletionTime == nil || next.Status.StartTime == nil || next.Status.StartTime.Before(previous.Status.CompletionTime) {
+					return jobsmanagerv1beta1.ConformancePhaseFailed, fmt.Sprintf("Job %s started before %s completed", next.Name, previous.Name), nil
+				}
+			}
+			return jobsmanagerv1beta1.ConformancePhasePassed, "jobs and groups ran one after another", nil
+		},
+	},
+	jobsmanagerv1beta1.ConformanceParallel: {
+		workflow: func(image string) jobsmanagerv1beta1.ManagedJobSpec {
+			return canaryWorkflow(
+				canaryGroup("fan", true, canaryJob("one", image, "sleep 5"), canaryJob("two", image, "sleep 5"), canaryJob("three", image, "sleep 5")),
+			)
+		},
+		check: func(ctx context.Context, c client.Client, mj *jobsmanagerv1beta1.ManagedJob) (string, string, error) {
+			if phase, message := expectSucceeded(mj); phase != jobsmanagerv1beta1.ConformancePhasePassed {
+				return phase, message, nil
+			}
+			jobs, err := canaryJobs(ctx, c, mj, "fan/one", "fan/two", "fan/three")
+			if err != nil {
+				return "", "", err
+			}
+			// all the Jobs started before the first one completed
+			for _, started := range jobs {
+				for _, completed := range jobs {
+					if started.Status.StartTime == nil || completed.Status.CompletionTime == nil || completed.Status.CompletionTime.Before(started.Status.StartTime) {
+						return jobsmanagerv1beta1.ConformancePhaseFailed, fmt.Sprintf("Job %s started after %s completed", started.Name, completed.Name), nil
+					}
+				}
+			}
+			return jobsmanagerv1beta1.ConformancePhasePassed, "jobs of the parallel group ran at once", nil
+		},
+	},
+	jobsmanagerv1beta1.ConformanceFailure: {
+		workflow: func(image string) jobsmanagerv1beta1.ManagedJobSpec {
+			return canaryWorkflow(
+				canaryGroup("checks", false, canaryJob("broken", image, "exit 1"), canaryJob("after", image, "true")),
+			)
+		},
+		check: func(ctx context.Context, c client.Client, mj *jobsmanagerv1beta1.ManagedJob) (string, string, error) {
+			switch mj.Status.Phase {
+			case ExecutionStatusSucceeded:
+				return jobsmanagerv1beta1.ConformancePhaseFailed, "run with the failing job succeeded", nil
+			case ExecutionStatusFailed:
+			default:
+				return "", "", nil
+			}
+			broken, after := canaryJobState(mj, "checks", "broken"), canaryJobState(mj, "checks", "after")
+			if broken.Status != ExecutionStatusFailed || after.Status != ExecutionStatusAborted || after.Reason != ReasonDependencyFailed {
+				return jobsmanagerv1beta1.ConformancePhaseFailed, fmt.Sprintf("failing job %s, its dependent %s (%s)", broken.Status, after.Status, after.Reason), nil
+			}
+			return jobsmanagerv1beta1.ConformancePhasePassed, "run failed: " + mj.Spec.FailureDigest, nil
+		},
+	},
+	jobsmanagerv1beta1.ConformanceRetry: {
+		workflow: func(image string) jobsmanagerv1beta1.ManagedJobSpec {
+			return canaryWorkflow(
+				canaryGroup("steady", false, canaryJob("ok", image, "true")),
+				canaryGroup("flaky", false, canaryJob("broken", image, "exit 1")),
+			)
+		},
+		check: func(ctx context.Context, c client.Client, mj *jobsmanagerv1beta1.ManagedJob) (string, string, error) {
+			if mj.Status.Phase == ExecutionStatusSucceeded {
+				return jobsmanagerv1beta1.ConformancePhaseFailed, "run with the failing job succeeded", nil
+			}
+			runs := len(mj.Spec.RunHistory)
+			if mj.Status.Phase != ExecutionStatusFailed || runs == 0 || mj.Spec.RunHistory[runs-1].CompletedAt == nil {
+				return "", "", nil
+			}
+			if runs == 1 {
+				return "", "", requestAction(ctx, c, mj, ActionRetryFailed)
+			}
+			ok, broken := canaryJobState(mj, "steady", "ok"), canaryJobState(mj, "flaky", "broken")
+			if ok.Status != ExecutionStatusSucceeded || ok.Attempt != 1 || broken.Attempt != 2 {
+				return jobsmanagerv1beta1.ConformancePhaseFailed, fmt.Sprintf("after the retry the succeeded job is %s at attempt %d, the failed one at attempt %d", ok.Status, ok.Attempt, broken.Attempt), nil
+			}
+			return jobsmanagerv1beta1.ConformancePhasePassed, "failed job ran again, the succeeded one was kept", nil
+		},
+	},
+	jobsmanagerv1beta1.ConformanceAbort: {
+		workflow: func(image string) jobsmanagerv1beta1.ManagedJobSpec {
+			return canaryWorkflow(
+				canaryGroup("long", false, canaryJob("sleeper", image, "sleep 3600")),
+			)
+		},
+		check: func(ctx context.Context, c client.Client, mj *jobsmanagerv1beta1.ManagedJob) (string, string, error) {
+			sleeper := canaryJobState(mj, "long", "sleeper")
+			switch sleeper.Status {
+			case ExecutionStatusRunning:
+				return "", "", requestAction(ctx, c, mj, ActionAbort)
+			case ExecutionStatusAborted:
+			case ExecutionStatusPending, "":
+				return "", "", nil
+			default:
+				return jobsmanagerv1beta1.ConformancePhaseFailed, "job to abort is " + sleeper.Status, nil
+			}
+			if sleeper.Reason != ReasonAborted {
+				return jobsmanagerv1beta1.ConformancePhaseFailed, "job aborted with the reason " + sleeper.Reason, nil
+			}
+			var childJob kbatch.Job
+			err := c.Get(ctx, types.NamespacedName{Namespace: mj.Namespace, Name: jobNameGenerator(mj.Name, "long", "sleeper")}, &childJob)
+			if err == nil && childJob.DeletionTimestamp == nil {
+				return jobsmanagerv1beta1.ConformancePhaseFailed, "Job of the aborted job was not deleted", nil
+			}
+			if client.IgnoreNotFound(err) != nil {
+				return "", "", err
+			}
+			return jobsmanagerv1beta1.ConformancePhasePassed, "running job was aborted and its Job deleted", nil
+		},
+	},
+}
+
+func canaryWorkflow(groups ...*jobsmanagerv1beta1.ManagedJobGroup) jobsmanagerv1beta1.ManagedJobSpec {
+	return jobsmanagerv1beta1.ManagedJobSpec{
+		Retries: 1,
+		Params:  jobsmanagerv1beta1.ManagedJobParameters{RestartPolicy: "Never"},
+		Groups:  groups,
+	}
+}
+
+func canaryGroup(name string, parallel bool, jobs ...*jobsmanagerv1beta1.ManagedJobDefinition) *jobsmanagerv1beta1.ManagedJobGroup {
+	return &jobsmanagerv1beta1.ManagedJobGroup{Name: name, Parallel: parallel, Status: ExecutionStatusPending, Jobs: jobs}
+}
+
+func canaryJob(name string, image string, script string) *jobsmanagerv1beta1.ManagedJobDefinition {
+	return &jobsmanagerv1beta1.ManagedJobDefinition{Name: name, Image: image, Args: []string{"sh", "-c", script}, Status: ExecutionStatusPending}
+}
+
+// canaryJobState returns the job of the canary workflow, an empty one when it's missing
+func canaryJobState(mj *jobsmanagerv1beta1.ManagedJob, group string, job string) *jobsmanagerv1beta1.ManagedJobDefinition {
+	for _, g := range mj.Spec.Groups {
+		for _, j := range g.Jobs {
+			if g.Name == group && j.Name == job {
+				return j
+			}
+		}
+	}
+	return &jobsmanagerv1beta1.ManagedJobDefinition{}
+}
+
+// canaryJobs returns the Jobs of the group/job paths of the canary workflow in their order
+func canaryJobs(ctx context.Context, c client.Client, mj *jobsmanagerv1beta1.ManagedJob, paths ...string) ([]*kbatch.Job, error) {
+	jobs := []*kbatch.Job{}
+	for _, path := range paths {
+		group, job, _ := strings.Cut(path, "/")
+		childJob := &kbatch.Job{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: mj.Namespace, Name: jobNameGenerator(mj.Name, group, job)}, childJob); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("no Job of %s found", path)
+			}
+			return nil, err
+		}
+		jobs = append(jobs, childJob)
+	}
+	return jobs, nil
+}
+
+// expectSucceeded passes the scenario once the canary workflow succeeded
+func expectSucceeded(mj *jobsmanagerv1beta1.ManagedJob) (string, string) {
+	switch mj.Status.Phase {
+	case ExecutionStatusSucceeded:
+		return jobsmanagerv1beta1.ConformancePhasePassed, "run succeeded in " + mj.Spec.Duration
+	case ExecutionStatusFailed:
+		return jobsmanagerv1beta1.ConformancePhaseFailed, "run failed: " + mj.Spec.FailureDigest
+	}
+	return "", ""
+}
+
+// requestAction asks the controller to take the action on the canary workflow, once
+func requestAction(ctx context.Context, c client.Client, mj *jobsmanagerv1beta1.ManagedJob, action string) error {
+	if _, requested := mj.Annotations[AnnotationAction]; requested {
+		return nil
+	}
+	patch := client.MergeFrom(mj.DeepCopy())
+	if mj.Annotations == nil {
+		mj.Annotations = map[string]string{}
+	}
+	mj.Annotations[AnnotationAction] = action
+	return c.Patch(ctx, mj, patch)
+}
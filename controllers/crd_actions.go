@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+/*
+Actions requested on the workflow - the AnnotationAction annotation asks the controller to abort the run,
+to retry it or to start the workflow created with startSuspended, `kubectl managedjob abort` and `retry`
+set it on all the workflows matching a selector.
+The statuses in the spec are owned by the controller, the annotation is removed once the action is taken.
+The webhook lets only the users who may update the managedjobs/action subresource set it.
+*/
+
+const (
+	AnnotationAction = jobsmanagerv1beta1.ActionAnnotation
+	// ActionAbort stops the running jobs and aborts the ones which have not started yet
+	ActionAbort = "abort"
+	// ActionRetry runs the whole workflow again
+	ActionRetry = "retry"
+	// ActionRetryFailed runs the failed and aborted jobs again, the succeeded ones are kept
+	ActionRetryFailed = "retry-failed"
+	// ActionStart releases the workflow created with startSuspended
+	ActionStart = "start"
+
+	ReasonAborted  = "Aborted"
+	runReasonRetry = "Retry"
+)
+
+// ActionApplies tells if the action does anything for the workflow of the status
+func ActionApplies(action string, status string) bool {
+	switch action {
+	case ActionAbort:
+		return status != ExecutionStatusSucceeded && status != ExecutionStatusFailed && status != ExecutionStatusInvalid
+	case ActionRetryFailed:
+		return status == ExecutionStatusFailed
+	case ActionStart:
+		return status == ExecutionStatusSuspended
+	}
+	return status != ExecutionStatusInvalid
+}
+
+// checkRequestedAction takes the action requested with the annotation of the workflow
+func (cp *connPackage) checkRequestedAction() {
+	action, requested := cp.mj.Annotations[AnnotationAction]
+	if !requested {
+		return
+	}
+	delete(cp.mj.Annotations, AnnotationAction)
+
+	switch action {
+	case ActionAbort:
+		cp.abortRun(ReasonAborted, "run aborted on request")
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, ReasonAborted, "Run aborted on request")
+	case ActionRetry:
+		cp.restartGroups(nil)
+		cp.recordRun(jobsmanagerv1beta1.ManagedJobRunRecord{StartedAt: metav1.NewTime(cp.now()), Reason: runReasonRetry, TriggeredBy: ActionRetry})
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Restarted", "Workflow restarted on request")
+	case ActionRetryFailed:
+		if cp.retryFailedJobs() {
+			cp.recordRun(jobsmanagerv1beta1.ManagedJobRunRecord{StartedAt: metav1.NewTime(cp.now()), Reason: runReasonRetry, TriggeredBy: ActionRetryFailed})
+			cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Restarted", "Failed jobs restarted on request")
+		}
+	case ActionStart:
+		if heldForStart(&cp.mj.Spec) {
+			cp.mj.Spec.StartReleased = true
+			cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Released", "Workflow created suspended released on request")
+		}
+	default:
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "UnknownAction", "Ignoring the unknown action %q, expected %s, %s, %s or %s", action, ActionAbort, ActionRetry, ActionRetryFailed, ActionStart)
+	}
+}
+
+// abortRun deletes the Jobs of the running jobs and aborts them together with the jobs which have not started,
+// the reason is set on the aborted groups and jobs, the description explains the decisions
+func (cp *connPackage) abortRun(reason string, description string) {
+	for _, group := range cp.mj.Spec.Groups {
+		if group.Status != ExecutionStatusPending && group.Status != ExecutionStatusRunning && group.Status != "" {
+			continue
+		}
+		for _, job := range group.Jobs {
+			switch job.Status {
+			case ExecutionStatusRunning:
+				cp.deletePreviousJob(group, job)
+			case ExecutionStatusPending, "":
+			default:
+				continue
+			}
+			cp.recordDecision(decision{Action: DecisionAbort, Group: group.Name, Job: job.Name, Reason: description})
+			job.Status = ExecutionStatusAborted
+			job.Reason = reason
+		}
+		group.Status = ExecutionStatusAborted
+		group.Reason = reason
+	}
+}
+
+// retryFailedJobs resets the failed and aborted jobs with their groups, reporting if there was any
+func (cp *connPackage) retryFailedJobs() bool {
+	retried := false
+	for _, group := range cp.mj.Spec.Groups {
+		groupRetried := false
+		for _, job := range group.Jobs {
+			if job.Status != ExecutionStatusFailed && job.Status != ExecutionStatusAborted {
+				continue
+			}
+			cp.recordDecision(decision{Action: DecisionRetry, Group: group.Name, Job: job.Name, Reason: "failed jobs retried on request"})
+			cp.deletePreviousJob(group, job)
+			resetJobState(job)
+			groupRetried = true
+		}
+		if !groupRetried && group.Status != ExecutionStatusFailed && group.Status != ExecutionStatusAborted {
+			continue
+		}
+		group.Status = ExecutionStatusPending
+		group.Reason = ""
+		group.CompletedAt = nil
+		for _, dependency := range group.Dependencies {
+			dependency.Status = ExecutionStatusPending
+		}
+		retried = true
+	}
+	if retried {
+		cp.propagateStatuses()
+		cp.mj.Status.Phase = ExecutionStatusRunning
+	}
+	return retried
+}
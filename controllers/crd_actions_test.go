@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	kbatch "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRequestedActions(t *testing.T) {
+	running := &kbatch.Job{ObjectMeta: metav1.ObjectMeta{Name: "nightly-load-orders", Namespace: "apps"}}
+	c := newTestClientBuilder(running).Build()
+
+	extract := &jobsmanagerv1beta1.ManagedJobGroup{Name: "extract", Status: ExecutionStatusSucceeded, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "orders", Status: ExecutionStatusSucceeded}}}
+	load := &jobsmanagerv1beta1.ManagedJobGroup{Name: "load", Status: ExecutionStatusRunning, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+		{Name: "customers", Status: ExecutionStatusSucceeded},
+		{Name: "orders", Status: ExecutionStatusRunning},
+		{Name: "invoices", Status: ExecutionStatusPending},
+	}}
+	report := &jobsmanagerv1beta1.ManagedJobGroup{Name: "report", Status: ExecutionStatusPending, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "daily", Status: ExecutionStatusPending}}}
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "apps", Annotations: map[string]string{AnnotationAction: ActionAbort}},
+		Spec:       jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{extract, load, report}},
+		Status:     jobsmanagerv1beta1.ManagedJobStatus{Phase: ExecutionStatusRunning},
+	}
+	recorder := record.NewFakeRecorder(10)
+	cp := newTestConnPackageWithClient(&ManagedJobReconciler{Recorder: recorder}, mj, c)
+	cp.generateDependencyTree()
+
+	cp.checkRequestedAction()
+	if _, found := mj.Annotations[AnnotationAction]; found {
+		t.Error("expected the action annotation removed")
+	}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(running), &kbatch.Job{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the running Job deleted, got %v", err)
+	}
+	for _, job := range append(load.Jobs[1:], report.Jobs...) {
+		if job.Status != ExecutionStatusAborted || job.Reason != ReasonAborted {
+			t.Errorf("expected job %s aborted, got %s %s", job.Name, job.Status, job.Reason)
+		}
+	}
+	if load.Jobs[0].Status != ExecutionStatusSucceeded || extract.Status != ExecutionStatusSucceeded {
+		t.Error("expected the finished jobs kept")
+	}
+	cp.propagateStatuses()
+	if status := workflowStatus(&mj.Spec); status != ExecutionStatusFailed {
+		t.Errorf("expected the aborted run finished as failed, got %s", status)
+	}
+
+	mj.Annotations[AnnotationAction] = ActionRetryFailed
+	cp.checkRequestedAction()
+	if load.Jobs[0].Status != ExecutionStatusSucceeded || load.Jobs[1].Status != ExecutionStatusPending || report.Jobs[0].Status != ExecutionStatusPending {
+		t.Errorf("expected only the aborted jobs retried, got %s %s %s", load.Jobs[0].Status, load.Jobs[1].Status, report.Jobs[0].Status)
+	}
+	if load.Status == ExecutionStatusAborted || report.Status == ExecutionStatusAborted || mj.Status.Phase != ExecutionStatusRunning {
+		t.Errorf("expected the groups and the workflow running again, got %s %s %s", load.Status, report.Status, mj.Status.Phase)
+	}
+	if len(mj.Spec.RunHistory) != 1 || mj.Spec.RunHistory[0].TriggeredBy != ActionRetryFailed {
+		t.Errorf("expected the retry recorded as a run, got %+v", mj.Spec.RunHistory)
+	}
+
+	mj.Annotations[AnnotationAction] = "pause"
+	cp.checkRequestedAction()
+	found := false
+	for len(recorder.Events) > 0 {
+		if event := <-recorder.Events; strings.Contains(event, "UnknownAction") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected the unknown action reported")
+	}
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+/*
+Consumed approvals - the approvals of the groups and the outcomes of the manual jobs are given in the spec,
+once for the run. Restarting the workflow does not clear them from the spec, the operator records them as
+consumed in the status and the new run waits for new ones. The consumed approval or outcome is forgotten
+once it's withdrawn from the spec, giving it again counts for the next run. The spec is never changed by
+the operator, so the GitOps tools see no drift.
+*/
+
+// consumedReleaseTimeout is how long the approval commands wait for the operator to forget the withdrawn approval
+const consumedReleaseTimeout = 30 * time.Second
+
+// consumeApproval records the approval of the group as used by the run being reset
+func consumeApproval(group *jobsmanagerv1beta1.ManagedJobGroup) {
+	group.ApprovalConsumed = group.Approved
+}
+
+// consumeOutcome records the outcome of the manual job as set for the run being reset
+func consumeOutcome(job *jobsmanagerv1beta1.ManagedJobDefinition) {
+	job.OutcomeConsumed = job.Outcome != ""
+}
+
+// groupApproved tells if the approval of the group in the spec counts for the current run
+func groupApproved(group *jobsmanagerv1beta1.ManagedJobGroup) bool {
+	return group.Approved && !group.ApprovalConsumed
+}
+
+// jobOutcome returns the outcome of the manual job set for the current run
+func jobOutcome(job *jobsmanagerv1beta1.ManagedJobDefinition) string {
+	if job.OutcomeConsumed {
+		return ""
+	}
+	return job.Outcome
+}
+
+// releaseWithdrawnApprovals forgets the consumed approvals and outcomes withdrawn from the spec
+func (cp *connPackage) releaseWithdrawnApprovals() {
+	for _, group := range cp.mj.Spec.Groups {
+		if !group.Approved {
+			group.ApprovalConsumed = false
+		}
+		for _, job := range group.Jobs {
+			if job.Outcome == "" {
+				job.OutcomeConsumed = false
+			}
+		}
+	}
+}
+
+// WithdrawConsumed removes the consumed approval or outcome from the spec of the workflow with the JSON patch
+// and waits until the operator forgets it, so the approval given next counts, e.g. for
+// `kubectl managedjob approve` of the restarted workflow
+func WithdrawConsumed(ctx context.Context, c client.Client, mj *jobsmanagerv1beta1.ManagedJob, withdraw []byte, consumed func(*jobsmanagerv1beta1.ManagedJob) bool) error {
+	if err := c.Patch(ctx, mj, client.RawPatch(types.JSONPatchType, withdraw)); err != nil {
+		return err
+	}
+	err := wait.PollUntilContextTimeout(ctx, time.Second, consumedReleaseTimeout, true, func(ctx context.Context) (bool, error) {
+		if err := c.Get(ctx, client.ObjectKeyFromObject(mj), mj); err != nil {
+			return false, err
+		}
+		return !consumed(mj), nil
+	})
+	if err != nil {
+		return fmt.Errorf("the operator did not forget the approval used by the previous run of %s: %w", mj.Name, err)
+	}
+	return nil
+}
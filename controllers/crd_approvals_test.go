@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestConsumedApprovals(t *testing.T) {
+	group := &jobsmanagerv1beta1.ManagedJobGroup{Name: "promote", Status: ExecutionStatusPending, PauseBefore: true, Approved: true}
+	cp := &connPackage{
+		r:  &ManagedJobReconciler{Recorder: record.NewFakeRecorder(10)},
+		mj: &jobsmanagerv1beta1.ManagedJob{Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{group}}},
+	}
+	if !cp.groupGatesOpen(group) {
+		t.Fatalf("expected the approved group started, got %s", group.Reason)
+	}
+
+	// the restarted group waits for a new approval, the spec keeps the used one
+	resetGroupState(group)
+	cp.releaseWithdrawnApprovals()
+	if cp.groupGatesOpen(group) || group.Reason != GroupReasonAwaitingApproval || !group.Approved {
+		t.Fatalf("expected the consumed approval not to count, got %s", group.Reason)
+	}
+
+	// withdrawn and given again, the approval counts for the next run
+	group.Approved = false
+	cp.releaseWithdrawnApprovals()
+	group.Approved = true
+	if group.ApprovalConsumed || !cp.groupGatesOpen(group) {
+		t.Errorf("expected the new approval to open the group, got %s", group.Reason)
+	}
+}
+
+func TestWithdrawConsumed(t *testing.T) {
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "release", Namespace: "apps"},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{
+			Name: "promote", PauseBefore: true, Approved: true,
+		}}},
+	}
+	c := newTestClientBuilder(mj).Build()
+	withdraw := []byte(`[{"op": "remove", "path": "/spec/groups/0/approved"}]`)
+	released := func(mj *jobsmanagerv1beta1.ManagedJob) bool { return mj.Spec.Groups[0].Approved }
+	if err := WithdrawConsumed(context.Background(), c, mj, withdraw, released); err != nil {
+		t.Fatal(err)
+	}
+	stored := &jobsmanagerv1beta1.ManagedJob{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(mj), stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Spec.Groups[0].Approved {
+		t.Error("expected the approval withdrawn from the spec")
+	}
+}
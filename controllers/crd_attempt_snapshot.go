@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+/*
+Attempt snapshots - what the Job of every attempt ran with: the image, its digest, the environment and the
+rest of the pod spec. The retried Job is compared with the previous attempt when it's created, the changes
+are emitted in the Retried event and kept in attemptChanges, so a retry which succeeded because something
+changed is told from the one which succeeded on the same spec. The digest of a tagged image is known once
+the pods of the attempt ran, a new digest is added to the changes when the Job finishes.
+*/
+
+const attemptChangesNone = "none"
+
+// attemptSnapshot returns the snapshot of the prepared Job of the attempt
+func attemptSnapshot(job *kbatch.Job, attempt int32) jobsmanagerv1beta1.ManagedJobAttemptSnapshot {
+	snapshot := jobsmanagerv1beta1.ManagedJobAttemptSnapshot{Attempt: attempt}
+	podSpec := job.Spec.Template.Spec.DeepCopy()
+	if len(podSpec.Containers) > 0 {
+		container := &podSpec.Containers[0]
+		snapshot.Image = container.Image
+		if _, digest, pinned := strings.Cut(container.Image, "@"); pinned {
+			snapshot.ImageDigest = digest
+		}
+		for _, env := range container.Env {
+			if snapshot.Env == nil {
+				snapshot.Env = map[string]string{}
+			}
+			snapshot.Env[env.Name] = snapshotHash(env)
+		}
+		container.Image = ""
+		container.Env = nil
+	}
+	snapshot.ParamsHash = snapshotHash(podSpec)
+	return snapshot
+}
+
+func snapshotHash(value interface{}) string {
+	encoded, _ := json.Marshal(value)
+	return fmt.Sprintf("%x", sha256.Sum256(encoded))[:12]
+}
+
+// attemptChanges lists the changes of the current attempt since the previous one
+func attemptChanges(previous jobsmanagerv1beta1.ManagedJobAttemptSnapshot, current jobsmanagerv1beta1.ManagedJobAttemptSnapshot) []string {
+	changes := []string{}
+	if previous.Image != current.Image {
+		changes = append(changes, fmt.Sprintf("image %s -> %s", previous.Image, current.Image))
+	}
+	if previous.ImageDigest != "" && current.ImageDigest != "" && previous.ImageDigest != current.ImageDigest {
+		changes = append(changes, fmt.Sprintf("image digest %s -> %s", previous.ImageDigest, current.ImageDigest))
+	}
+	names := []string{}
+	for name := range previous.Env {
+		names = append(names, name)
+	}
+	for name := range current.Env {
+		if _, found := previous.Env[name]; !found {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		before, existed := previous.Env[name]
+		after, exists := current.Env[name]
+		switch {
+		case !existed:
+			changes = append(changes, "env "+name+" added")
+		case !exists:
+			changes = append(changes, "env "+name+" removed")
+		case before != after:
+			changes = append(changes, "env "+name+" changed")
+		}
+	}
+	if previous.ParamsHash != current.ParamsHash {
+		changes = append(changes, "params changed")
+	}
+	return changes
+}
+
+// recordAttempt keeps the snapshot of the created Job, the changes since the previous attempt are emitted
+// in the Retried event
+func (cp *connPackage) recordAttempt(j *jobsmanagerv1beta1.ManagedJobDefinition, job *kbatch.Job, snapshot jobsmanagerv1beta1.ManagedJobAttemptSnapshot) {
+	if count := len(j.AttemptSnapshots); count > 0 {
+		previous := j.AttemptSnapshots[count-1]
+		changes := attemptChanges(previous, snapshot)
+		j.AttemptChanges = strings.Join(changes, ", ")
+		if len(changes) == 0 {
+			j.AttemptChanges = attemptChangesNone
+		}
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Retried", "Job %s attempt %d, changes since attempt %d: %s", job.Name, snapshot.Attempt, previous.Attempt, j.AttemptChanges)
+		j.AttemptSnapshots = []jobsmanagerv1beta1.ManagedJobAttemptSnapshot{previous}
+	}
+	j.AttemptSnapshots = append(j.AttemptSnapshots, snapshot)
+}
+
+// recordImageDigest keeps the digest of the image the pods of the finished Job ran, a digest different
+// from the one of the previous attempt is added to the changes of the attempt
+func (cp *connPackage) recordImageDigest(j *jobsmanagerv1beta1.ManagedJobDefinition, childJob *kbatch.Job) {
+	count := len(j.AttemptSnapshots)
+	if count == 0 || j.AttemptSnapshots[count-1].Attempt != j.Attempt || j.AttemptSnapshots[count-1].ImageDigest != "" {
+		return
+	}
+	var pods corev1.PodList
+	labelSelector := labels.SelectorFromSet(labels.Set{labelWorkflowName: cp.mj.Name, labelJobName: childJob.Name})
+	if err := cp.client.List(cp.ctx, &pods, &client.ListOptions{LabelSelector: labelSelector, Namespace: cp.mj.Namespace}); err != nil {
+		log.Log.Info("Unable to list job pods", "job", childJob.Name, "error", err.Error())
+		return
+	}
+	digest := ""
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name == childJob.Name && status.ImageID != "" {
+				digest = imageDigest(status.ImageID)
+			}
+		}
+	}
+	if digest == "" {
+		return
+	}
+	current := &j.AttemptSnapshots[count-1]
+	current.ImageDigest = digest
+	if count < 2 {
+		return
+	}
+	previous := j.AttemptSnapshots[count-2]
+	if previous.ImageDigest == "" || previous.ImageDigest == digest || previous.Image != current.Image {
+		return
+	}
+	change := fmt.Sprintf("image digest %s -> %s", previous.ImageDigest, digest)
+	if j.AttemptChanges == attemptChangesNone || j.AttemptChanges == "" {
+		j.AttemptChanges = change
+	} else {
+		j.AttemptChanges = change + ", " + j.AttemptChanges
+	}
+	cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Retried", "Job %s attempt %d ran the image digest %s, attempt %d ran %s", childJob.Name, current.Attempt, digest, previous.Attempt, previous.ImageDigest)
+}
+
+// imageDigest returns the digest of the image ID reported by the container runtime,
+// e.g. docker-pullable://busybox@sha256:1a2b
+func imageDigest(imageID string) string {
+	if at := strings.LastIndex(imageID, "@"); at >= 0 {
+		return imageID[at+1:]
+	}
+	return strings.TrimPrefix(imageID, "docker://")
+}
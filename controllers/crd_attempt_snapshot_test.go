@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestAttemptChanges(t *testing.T) {
+	mj := &jobsmanagerv1beta1.ManagedJob{ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "apps", UID: "3f1c"}}
+	c := newTestClientBuilder(mj).Build()
+	recorder := record.NewFakeRecorder(10)
+	cp := newTestConnPackageWithClient(&ManagedJobReconciler{Recorder: recorder}, mj, c)
+	group := &jobsmanagerv1beta1.ManagedJobGroup{Name: "load"}
+	job := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "extract"}
+	// runAttempt creates the Job of the next attempt and finishes it on the image digest
+	runAttempt := func(dbHost string, imageID string) {
+		t.Helper()
+		_ = c.DeleteAllOf(cp.ctx, &kbatch.Job{}, client.InNamespace("apps"))
+		_ = c.DeleteAllOf(cp.ctx, &corev1.Pod{}, client.InNamespace("apps"))
+		childJob := &kbatch.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "nightly-load-extract", Namespace: "apps"},
+			Spec: kbatch.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name: "nightly-load-extract", Image: "registry.local/extract:stable",
+				Env: []corev1.EnvVar{{Name: "DB_HOST", Value: dbHost}, {Name: "MODE", Value: "full"}},
+			}}}}},
+		}
+		if err := cp.createJob(job, group, childJob); err != nil {
+			t.Fatal(err)
+		}
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "nightly-load-extract-x1", Namespace: "apps", Labels: map[string]string{labelWorkflowName: "nightly", labelJobName: childJob.Name}},
+			Status:     corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{Name: childJob.Name, ImageID: imageID}}},
+		}
+		if err := c.Create(cp.ctx, pod); err != nil {
+			t.Fatal(err)
+		}
+		cp.recordImageDigest(job, childJob)
+	}
+	events := func() []string {
+		received := []string{}
+		for len(recorder.Events) > 0 {
+			if event := <-recorder.Events; !strings.Contains(event, " Created ") {
+				received = append(received, event)
+			}
+		}
+		return received
+	}
+
+	runAttempt("db-1", "docker-pullable://registry.local/extract@sha256:aaa")
+	if len(job.AttemptSnapshots) != 1 || job.AttemptSnapshots[0].ImageDigest != "sha256:aaa" || job.AttemptChanges != "" || len(events()) != 0 {
+		t.Fatalf("unexpected first attempt %+v, changes %q", job.AttemptSnapshots, job.AttemptChanges)
+	}
+
+	resetJobState(job)
+	runAttempt("db-1", "registry.local/extract@sha256:aaa")
+	if job.AttemptChanges != attemptChangesNone {
+		t.Errorf("expected no changes of the retry on the same spec, got %q", job.AttemptChanges)
+	}
+	if received := events(); len(received) != 1 || received[0] != "Normal Retried Job nightly-load-extract attempt 2, changes since attempt 1: none" {
+		t.Errorf("unexpected events %v", received)
+	}
+
+	resetJobState(job)
+	runAttempt("db-2", "registry.local/extract@sha256:bbb")
+	if job.AttemptChanges != "image digest sha256:aaa -> sha256:bbb, env DB_HOST changed" {
+		t.Errorf("unexpected changes %q", job.AttemptChanges)
+	}
+	if received := events(); len(received) != 2 || !strings.HasSuffix(received[0], "changes since attempt 2: env DB_HOST changed") || !strings.Contains(received[1], "ran the image digest sha256:bbb, attempt 2 ran sha256:aaa") {
+		t.Errorf("unexpected events %v", received)
+	}
+	if len(job.AttemptSnapshots) != 2 || job.AttemptSnapshots[0].Attempt != 2 || job.AttemptSnapshots[1].Attempt != 3 {
+		t.Errorf("expected the last two attempts kept, got %+v", job.AttemptSnapshots)
+	}
+}
+
+func TestAttemptSnapshotChanges(t *testing.T) {
+	previous := jobsmanagerv1beta1.ManagedJobAttemptSnapshot{Attempt: 1, Image: "app:1", Env: map[string]string{"A": "1", "B": "2"}, ParamsHash: "p1"}
+	current := jobsmanagerv1beta1.ManagedJobAttemptSnapshot{Attempt: 2, Image: "app:2", Env: map[string]string{"B": "2", "C": "3"}, ParamsHash: "p2"}
+	if changes := strings.Join(attemptChanges(previous, current), ", "); changes != "image app:1 -> app:2, env A removed, env C added, params changed" {
+		t.Errorf("unexpected changes %q", changes)
+	}
+	pinned := attemptSnapshot(&kbatch.Job{Spec: kbatch.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+		Containers: []corev1.Container{{Image: "app@sha256:ccc"}},
+	}}}}, 1)
+	if pinned.ImageDigest != "sha256:ccc" {
+		t.Errorf("digest of the pinned image not recorded: %+v", pinned)
+	}
+}
@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+/*
+Authorization of the privileged jobs - the Job asking for a privileged container or a hostPath volume is
+created only once the JobAuthorizer allows the creator of the workflow, recorded by the mutating webhook,
+to run it. Sub-workflows are authorized against the creator of the root workflow running them, they are
+created by the operator itself. Refused jobs stay pending and the Unauthorized condition of the workflow names them.
+*/
+
+const (
+	ConditionUnauthorized = "Unauthorized"
+
+	// privileged jobs are authorized against the privileged subresource of the workflow
+	privilegedSubresource = "privileged"
+	privilegedVerb        = "use"
+
+	unauthorizedRequeue  = time.Minute
+	workflowNestingLimit = 10
+)
+
+var errJobUnauthorized = errors.New("job not authorized")
+
+// JobAuthorizer decides if the creator of the workflow may run the privileged job, the reasons name what
+// makes the job privileged. The message explains the refusal.
+type JobAuthorizer interface {
+	AuthorizeJob(ctx context.Context, workflow *jobsmanagerv1beta1.ManagedJob, job *kbatch.Job, reasons []string) (bool, string, error)
+}
+
+// SubjectAccessReviewAuthorizer allows the privileged jobs to the creators who may use the privileged
+// subresource of the workflow, e.g. granted with the managedjobs-privileged role
+type SubjectAccessReviewAuthorizer struct {
+	Client kubernetes.Interface
+}
+
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+func (a SubjectAccessReviewAuthorizer) AuthorizeJob(ctx context.Context, workflow *jobsmanagerv1beta1.ManagedJob, job *kbatch.Job, reasons []string) (bool, string, error) {
+	creator, recorded := workflow.Creator()
+	if !recorded {
+		return false, fmt.Sprintf("creator of the workflow %s is not recorded, it has to be created through the webhook", workflow.Name), nil
+	}
+	allowed, err := a.review(ctx, creator, workflow, privilegedVerb, privilegedSubresource)
+	if err != nil || allowed {
+		return allowed, "", err
+	}
+	return false, fmt.Sprintf("%s may not run %s of the workflow %s", creator.Username, strings.Join(reasons, ", "), workflow.Name), nil
+}
+
+// AuthorizeRuntimeChange allows the user to approve or take the actions on the workflow when they may
+// update its subresource, it's the jobsmanagerv1beta1.RuntimeAuthorizer of the webhook
+func (a SubjectAccessReviewAuthorizer) AuthorizeRuntimeChange(ctx context.Context, user authenticationv1.UserInfo, workflow *jobsmanagerv1beta1.ManagedJob, subresource string) (bool, error) {
+	return a.review(ctx, user, workflow, jobsmanagerv1beta1.RuntimeChangeVerb, subresource)
+}
+
+// review asks the API server if the user may take the verb on the subresource of the workflow
+func (a SubjectAccessReviewAuthorizer) review(ctx context.Context, user authenticationv1.UserInfo, workflow *jobsmanagerv1beta1.ManagedJob, verb, subresource string) (bool, error) {
+	extra := map[string]authorizationv1.ExtraValue{}
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	review := &authorizationv1.SubjectAccessReview{Spec: authorizationv1.SubjectAccessReviewSpec{
+		User:   user.Username,
+		UID:    user.UID,
+		Groups: user.Groups,
+		Extra:  extra,
+		ResourceAttributes: &authorizationv1.ResourceAttributes{
+			Namespace:   workflow.Namespace,
+			Verb:        verb,
+			Group:       jobsmanagerv1beta1.GroupVersion.Group,
+			Resource:    "managedjobs",
+			Subresource: subresource,
+			Name:        workflow.Name,
+		},
+	}}
+	review, err := a.Client.AuthorizationV1().SubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	return review.Status.Allowed, nil
+}
+
+// privilegedReasons lists what makes the pod privileged, nothing for the regular ones
+func privilegedReasons(spec *corev1.PodSpec) []string {
+	reasons := []string{}
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for _, container := range containers {
+			if container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged {
+				reasons = append(reasons, "privileged container "+container.Name)
+			}
+		}
+	}
+	for _, volume := range spec.Volumes {
+		if volume.HostPath != nil {
+			reasons = append(reasons, "hostPath volume "+volume.Name)
+		}
+	}
+	return reasons
+}
+
+// rootWorkflow follows the sub-workflow to the workflow running it, the sub-workflows are created by the
+// operator from the template of the root workflow so its creator is the one authorized. Only the parents
+// the operator created the sub-workflow for are followed, the owner UID has to match the parent and the
+// sub-workflow-of annotation set by the operator, the webhook rejects both from the clients.
+func (cp *connPackage) rootWorkflow() (*jobsmanagerv1beta1.ManagedJob, error) {
+	current := cp.mj
+	for depth := 0; depth < workflowNestingLimit; depth++ {
+		owner := current.WorkflowOwner()
+		if owner == nil {
+			return current, nil
+		}
+		parent := &jobsmanagerv1beta1.ManagedJob{}
+		if err := cp.client.Get(cp.ctx, client.ObjectKey{Namespace: current.Namespace, Name: owner.Name}, parent); err != nil {
+			return nil, err
+		}
+		if parent.UID != owner.UID || current.Annotations[jobsmanagerv1beta1.SubWorkflowOfAnnotation] != string(parent.UID) {
+			return nil, fmt.Errorf("workflow %s is owned by %s but was not created by the operator as its sub-workflow", current.Name, owner.Name)
+		}
+		current = parent
+	}
+	return nil, fmt.Errorf("workflow %s is nested deeper than %d sub-workflows", cp.mj.Name, workflowNestingLimit)
+}
+
+// authorizeJob checks the privileged Job against the authorizer of the operator, the refused job is
+// recorded in the Unauthorized condition and errJobUnauthorized is returned
+func (cp *connPackage) authorizeJob(job *kbatch.Job) error {
+	if cp.r.Authorizer == nil {
+		return nil
+	}
+	reasons := privilegedReasons(&job.Spec.Template.Spec)
+	if len(reasons) == 0 {
+		return nil
+	}
+	workflow, err := cp.rootWorkflow()
+	if err != nil {
+		return err
+	}
+	allowed, message, err := cp.r.Authorizer.AuthorizeJob(cp.ctx, workflow, job, reasons)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		cp.unauthorized = append(cp.unauthorized, fmt.Sprintf("%s: %s", job.Name, message))
+		return fmt.Errorf("%w: %s", errJobUnauthorized, message)
+	}
+	return nil
+}
+
+// updateUnauthorizedCondition reports the jobs refused in this pass, the condition is cleared once
+// no job was refused
+func (cp *connPackage) updateUnauthorizedCondition() {
+	existing := meta.FindStatusCondition(cp.mj.Spec.Conditions, ConditionUnauthorized)
+	if len(cp.unauthorized) == 0 {
+		if existing != nil && existing.Status == metav1.ConditionTrue {
+			meta.SetStatusCondition(&cp.mj.Spec.Conditions, metav1.Condition{Type: ConditionUnauthorized, Status: metav1.ConditionFalse, Reason: "Authorized"})
+		}
+		return
+	}
+	message := strings.Join(cp.unauthorized, "; ")
+	if existing == nil || existing.Status != metav1.ConditionTrue || existing.Message != message {
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, ConditionUnauthorized, "Privileged jobs not started: %s", message)
+	}
+	meta.SetStatusCondition(&cp.mj.Spec.Conditions, metav1.Condition{Type: ConditionUnauthorized, Status: metav1.ConditionTrue, Reason: "PrivilegedJobRefused", Message: message})
+}
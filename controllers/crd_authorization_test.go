@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+// usersAuthorizer allows the privileged jobs to the listed users
+type usersAuthorizer map[string]bool
+
+func (a usersAuthorizer) AuthorizeJob(ctx context.Context, workflow *jobsmanagerv1beta1.ManagedJob, job *kbatch.Job, reasons []string) (bool, string, error) {
+	creator, _ := workflow.Creator()
+	return a[creator.Username], creator.Username + " may not run " + strings.Join(reasons, ", "), nil
+}
+
+func TestPrivilegedJobAuthorization(t *testing.T) {
+	hostPath := corev1.Volume{Name: "docker", VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/run/docker.sock"}}}
+	job := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "build", Image: "docker:24", Status: ExecutionStatusPending,
+		Params: jobsmanagerv1beta1.ManagedJobParameters{Volumes: []corev1.Volume{hostPath}}}
+	group := &jobsmanagerv1beta1.ManagedJobGroup{Name: "images", Status: ExecutionStatusPending, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{job}}
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "release", Namespace: "apps", Annotations: map[string]string{jobsmanagerv1beta1.CreatedByAnnotation: "bob"}},
+		Spec:       jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{group}},
+	}
+	c := newTestClientBuilder(mj).Build()
+	authorizer := usersAuthorizer{"jane": true}
+	recorder := record.NewFakeRecorder(10)
+	cp := newTestConnPackageWithClient(&ManagedJobReconciler{Recorder: recorder, Authorizer: authorizer}, mj, c)
+
+	cp.runPendingJobs()
+	jobs := &kbatch.JobList{}
+	_ = c.List(cp.ctx, jobs)
+	condition := meta.FindStatusCondition(mj.Spec.Conditions, ConditionUnauthorized)
+	if job.Status != ExecutionStatusPending || group.Status == ExecutionStatusFailed || len(jobs.Items) != 0 {
+		t.Fatalf("expected the refused job pending and not created, got %s with %d Jobs", job.Status, len(jobs.Items))
+	}
+	if condition == nil || condition.Status != metav1.ConditionTrue || !strings.Contains(condition.Message, "bob may not run hostPath volume docker") {
+		t.Fatalf("unexpected condition %+v", condition)
+	}
+	if cp.requeueAfter != unauthorizedRequeue {
+		t.Errorf("expected the refused job retried, got the requeue in %s", cp.requeueAfter)
+	}
+
+	authorizer["bob"] = true
+	cp.unauthorized = nil
+	cp.runPendingJobs()
+	condition = meta.FindStatusCondition(mj.Spec.Conditions, ConditionUnauthorized)
+	if job.Status != ExecutionStatusRunning || condition.Status != metav1.ConditionFalse {
+		t.Errorf("expected the authorized job started, got %s and the condition %s", job.Status, condition.Status)
+	}
+}
+
+func TestPrivilegedReasons(t *testing.T) {
+	privileged := true
+	spec := &corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "setup", SecurityContext: &corev1.SecurityContext{Privileged: &privileged}}},
+		Containers:     []corev1.Container{{Name: "main", SecurityContext: &corev1.SecurityContext{}}},
+		Volumes:        []corev1.Volume{{Name: "data", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}},
+	}
+	if reasons := privilegedReasons(spec); len(reasons) != 1 || reasons[0] != "privileged container setup" {
+		t.Errorf("unexpected reasons %v", reasons)
+	}
+}
+
+func TestRootWorkflow(t *testing.T) {
+	root := &jobsmanagerv1beta1.ManagedJob{ObjectMeta: metav1.ObjectMeta{Name: "release", Namespace: "apps", UID: "root-uid",
+		Annotations: map[string]string{jobsmanagerv1beta1.CreatedByAnnotation: "jane"}}}
+	owner := metav1.OwnerReference{APIVersion: jobsmanagerv1beta1.GroupVersion.String(), Kind: "ManagedJob", Name: "release", UID: "root-uid", Controller: pointer.Bool(true)}
+	child := &jobsmanagerv1beta1.ManagedJob{ObjectMeta: metav1.ObjectMeta{Name: "release-images-build", Namespace: "apps",
+		OwnerReferences: []metav1.OwnerReference{owner},
+		Annotations:     map[string]string{jobsmanagerv1beta1.SubWorkflowOfAnnotation: "root-uid"}}}
+	c := newTestClientBuilder(root).Build()
+	cp := newTestConnPackageWithClient(nil, child, c)
+
+	workflow, err := cp.rootWorkflow()
+	if err != nil || workflow.Name != "release" {
+		t.Fatalf("expected the sub-workflow followed to release, got %v and %v", workflow, err)
+	}
+
+	// the owner named by the client without the annotation of the operator is not followed
+	forged := child.DeepCopy()
+	forged.Annotations = nil
+	cp.mj = forged
+	if _, err := cp.rootWorkflow(); err == nil {
+		t.Error("expected the owner without the sub-workflow-of annotation rejected")
+	}
+	// nor the owner with the UID of another workflow of the same name
+	forged = child.DeepCopy()
+	forged.OwnerReferences[0].UID = "other-uid"
+	forged.Annotations[jobsmanagerv1beta1.SubWorkflowOfAnnotation] = "other-uid"
+	cp.mj = forged
+	if _, err := cp.rootWorkflow(); err == nil {
+		t.Error("expected the owner with a different UID rejected")
+	}
+}
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/pkg/dependencies"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	GroupReasonQuotaWait = "QuotaWait"
+	// ConditionQuotaWait lists the groups of the workflow delayed by the namespace quota
+	ConditionQuotaWait = "QuotaWait"
+	quotaWaitRequeue   = 30 * time.Second
+)
+
+// quotaKeys maps the requested resources to the quota entries limiting them
+var quotaKeys = map[corev1.ResourceName][]corev1.ResourceName{
+	corev1.ResourceCPU:    {corev1.ResourceRequestsCPU, corev1.ResourceCPU},
+	corev1.ResourceMemory: {corev1.ResourceRequestsMemory, corev1.ResourceMemory},
+}
+
+// peakRequests returns the most the pending jobs of the group request at the same time. The jobs waiting
+// for the previous jobs never run together so only the largest of them counts, the serial group needs
+// the room for its largest job. The partitions run one after another, the largest one counts.
+func (cp *connPackage) peakRequests(group *jobsmanagerv1beta1.ManagedJobGroup) corev1.ResourceList {
+	peak, parallel, serial, partition := corev1.ResourceList{}, corev1.ResourceList{}, corev1.ResourceList{}, corev1.ResourceList{}
+	for index, job := range group.Jobs {
+		if group.PartitionSize > 0 && index%group.PartitionSize == 0 {
+			maxResourceList(peak, partition)
+			partition = corev1.ResourceList{}
+		}
+		if job.Status != ExecutionStatusPending {
+			continue
+		}
+		params, _ := cp.jobParameters(group, job)
+		requests := compiledResources(params).Requests
+		switch {
+		case group.PartitionSize > 0:
+			addResourceList(partition, requests)
+		case dependencies.JobWaitsForPreviousJobs(group, job):
+			maxResourceList(serial, requests)
+		default:
+			addResourceList(parallel, requests)
+		}
+	}
+	maxResourceList(peak, partition)
+	addResourceList(peak, parallel)
+	addResourceList(peak, serial)
+	return peak
+}
+
+// groupFitsQuota checks if the peak requests of the pending jobs of the group fit into the remaining
+// namespace quota
+func (cp *connPackage) groupFitsQuota(group *jobsmanagerv1beta1.ManagedJobGroup) bool {
+	if !cp.r.CapacityCheck {
+		return true
+	}
+
+	requested := cp.peakRequests(group)
+	if len(requested) == 0 {
+		return true
+	}
+
+	var quotas corev1.ResourceQuotaList
+	err := cp.client.List(cp.ctx, &quotas, &client.ListOptions{Namespace: cp.mj.Namespace})
+	if err != nil {
+		log.Log.Info("Unable to list resource quotas", "error", err.Error())
+		return true
+	}
+
+	for _, quota := range quotas.Items {
+		// the first resource over the quota is reported, always the same one for the same requests
+		for _, resourceName := range resourceNames(requested) {
+			quantity := requested[resourceName]
+			for _, quotaKey := range quotaKeys[resourceName] {
+				hard, limited := quota.Status.Hard[quotaKey]
+				if !limited {
+					continue
+				}
+				remaining := hard.DeepCopy()
+				remaining.Sub(quota.Status.Used[quotaKey])
+				if quantity.Cmp(remaining) > 0 {
+					cp.delayGroupForQuota(group, quota.Name, resourceName, quantity, remaining)
+					return false
+				}
+			}
+		}
+	}
+
+	if group.Reason == GroupReasonQuotaWait {
+		group.Reason = ""
+	}
+	return true
+}
+
+func (cp *connPackage) delayGroupForQuota(group *jobsmanagerv1beta1.ManagedJobGroup, quotaName string, resourceName corev1.ResourceName, requested resource.Quantity, remaining resource.Quantity) {
+	if group.Reason != GroupReasonQuotaWait {
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, GroupReasonQuotaWait, "Group %s delayed, requests %s of %s but quota %s has %s left", group.Name, requested.String(), resourceName, quotaName, remaining.String())
+	}
+	group.Reason = GroupReasonQuotaWait
+	cp.quotaWaits = append(cp.quotaWaits, fmt.Sprintf("%s: requests %s of %s but quota %s has %s left", group.Name, requested.String(), resourceName, quotaName, remaining.String()))
+	cp.requeueIn(quotaWaitRequeue)
+}
+
+// updateQuotaWaitCondition reports the groups delayed by the quota in this pass, the condition is cleared
+// once no group waits
+func (cp *connPackage) updateQuotaWaitCondition() {
+	if len(cp.quotaWaits) == 0 {
+		if meta.IsStatusConditionTrue(cp.mj.Spec.Conditions, ConditionQuotaWait) {
+			meta.SetStatusCondition(&cp.mj.Spec.Conditions, metav1.Condition{Type: ConditionQuotaWait, Status: metav1.ConditionFalse, Reason: "QuotaAvailable"})
+		}
+		return
+	}
+	meta.SetStatusCondition(&cp.mj.Spec.Conditions, metav1.Condition{Type: ConditionQuotaWait, Status: metav1.ConditionTrue, Reason: "QuotaExceeded", Message: strings.Join(cp.quotaWaits, "; ")})
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestGroupFitsQuotaPeak(t *testing.T) {
+	quota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "compute", Namespace: "apps"},
+		Status: corev1.ResourceQuotaStatus{
+			Hard: corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("2")},
+			Used: corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("500m")},
+		},
+	}
+	oneCPU := jobsmanagerv1beta1.ManagedJobParameters{Resources: &corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+	}}
+	jobs := func() []*jobsmanagerv1beta1.ManagedJobDefinition {
+		return []*jobsmanagerv1beta1.ManagedJobDefinition{
+			{Name: "a", Status: ExecutionStatusPending}, {Name: "b", Status: ExecutionStatusPending}, {Name: "c", Status: ExecutionStatusPending},
+		}
+	}
+	serial := &jobsmanagerv1beta1.ManagedJobGroup{Name: "serial", Ordering: GroupOrderingSerial, Params: oneCPU, Jobs: jobs()}
+	partitioned := &jobsmanagerv1beta1.ManagedJobGroup{Name: "partitioned", PartitionSize: 1, Params: oneCPU, Jobs: jobs()}
+	parallel := &jobsmanagerv1beta1.ManagedJobGroup{Name: "parallel", Ordering: GroupOrderingParallel, Params: oneCPU, Jobs: jobs()}
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "apps"},
+		Spec:       jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{serial, partitioned, parallel}},
+	}
+	cp := newTestConnPackageWithClient(&ManagedJobReconciler{Recorder: record.NewFakeRecorder(10), CapacityCheck: true}, mj, newTestClientBuilder(quota).Build())
+
+	// the serial and the partitioned groups run one job at a time, 1 CPU of the 1.5 left
+	if !cp.groupFitsQuota(serial) || !cp.groupFitsQuota(partitioned) {
+		t.Errorf("expected the groups running one job at a time to fit, got %q and %q", serial.Reason, partitioned.Reason)
+	}
+	cp.updateQuotaWaitCondition()
+	if meta.FindStatusCondition(mj.Spec.Conditions, ConditionQuotaWait) != nil {
+		t.Errorf("expected no condition while the groups fit, got %+v", mj.Spec.Conditions)
+	}
+
+	// the parallel group runs all 3 CPUs at once
+	if cp.groupFitsQuota(parallel) || parallel.Reason != GroupReasonQuotaWait {
+		t.Fatalf("expected the parallel group delayed, got %q", parallel.Reason)
+	}
+	cp.updateQuotaWaitCondition()
+	condition := meta.FindStatusCondition(mj.Spec.Conditions, ConditionQuotaWait)
+	if condition == nil || condition.Status != metav1.ConditionTrue || !strings.HasPrefix(condition.Message, "parallel: requests 3 of cpu but quota compute has 1500m left") {
+		t.Fatalf("expected the QuotaWait condition naming the group, got %+v", condition)
+	}
+
+	// the next pass with room for the group clears the condition
+	cp.quotaWaits = nil
+	parallel.Jobs[0].Status = ExecutionStatusRunning
+	parallel.Jobs[1].Status = ExecutionStatusRunning
+	if !cp.groupFitsQuota(parallel) {
+		t.Errorf("expected the last pending job to fit")
+	}
+	cp.updateQuotaWaitCondition()
+	if meta.IsStatusConditionTrue(mj.Spec.Conditions, ConditionQuotaWait) {
+		t.Errorf("expected the condition cleared, got %+v", mj.Spec.Conditions)
+	}
+}
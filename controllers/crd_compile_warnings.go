@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+/*
+Compile warnings - the params of the workflow, its groups and jobs are merged into the params of each job
+and the lower levels silently win. The operator lists in status.warnings what the merge hides: the values
+of the upper levels overridden by the lower ones, the labels and annotations dropped as the lower maps
+replace the upper ones, the mounts of the volumes missing from the compiled params and the volumes none of
+the jobs below them mounts. The new warnings are reported as the CompileWarning events too. Nothing is
+rejected, the workflow runs as it did. Resources are sized per job on purpose, their overrides are not
+reported.
+*/
+
+// paramsLevel is the params of the workflow, a group or a job with the name used in the warnings
+type paramsLevel struct {
+	name   string
+	params *jobsmanagerv1beta1.ManagedJobParameters
+}
+
+// overridableParams are the single values of the params reported when a lower level overrides them,
+// value returns nil for the values not set at the level
+var overridableParams = []struct {
+	name  string
+	value func(params *jobsmanagerv1beta1.ManagedJobParameters) interface{}
+}{
+	{"serviceAccount", func(params *jobsmanagerv1beta1.ManagedJobParameters) interface{} {
+		if params.ServiceAccount == "" {
+			return nil
+		}
+		return params.ServiceAccount
+	}},
+	{"restartPolicy", func(params *jobsmanagerv1beta1.ManagedJobParameters) interface{} {
+		if params.RestartPolicy == "" {
+			return nil
+		}
+		return params.RestartPolicy
+	}},
+	{"imagePullPolicy", func(params *jobsmanagerv1beta1.ManagedJobParameters) interface{} {
+		if params.ImagePullPolicy == "" {
+			return nil
+		}
+		return params.ImagePullPolicy
+	}},
+	{"workingDir", func(params *jobsmanagerv1beta1.ManagedJobParameters) interface{} {
+		if params.WorkingDir == "" {
+			return nil
+		}
+		return params.WorkingDir
+	}},
+	{"runAsUser", func(params *jobsmanagerv1beta1.ManagedJobParameters) interface{} {
+		if params.RunAsUser == nil {
+			return nil
+		}
+		return *params.RunAsUser
+	}},
+	{"runAsGroup", func(params *jobsmanagerv1beta1.ManagedJobParameters) interface{} {
+		if params.RunAsGroup == nil {
+			return nil
+		}
+		return *params.RunAsGroup
+	}},
+	{"secretsFrom", func(params *jobsmanagerv1beta1.ManagedJobParameters) interface{} {
+		if params.SecretsFrom == nil {
+			return nil
+		}
+		return *params.SecretsFrom
+	}},
+}
+
+// checkCompileWarnings records the warnings of the compiled params in the status, the new ones as events
+func (cp *connPackage) checkCompileWarnings() {
+	reported := map[string]bool{}
+	for _, warning := range cp.mj.Spec.Warnings {
+		reported[warning] = true
+	}
+	warnings := cp.compileWarnings()
+	for _, warning := range warnings {
+		if !reported[warning] {
+			cp.r.Recorder.Event(cp.mj, corev1.EventTypeWarning, "CompileWarning", warning)
+		}
+	}
+	cp.mj.Spec.Warnings = warnings
+}
+
+// compileWarnings lists the warnings of the params of all the groups and jobs in the order of the spec
+func (cp *connPackage) compileWarnings() []string {
+	var warnings []string
+	workflow := paramsLevel{"the workflow", &cp.mj.Spec.Params}
+	// volumes of each level in the order of the spec, and the ones mounted by the jobs below the levels
+	declared := []paramsLevel{workflow}
+	mounted := map[string]map[string]bool{}
+	markMounted := func(level string, mounts []corev1.VolumeMount) {
+		if mounted[level] == nil {
+			mounted[level] = map[string]bool{}
+		}
+		for _, mount := range mounts {
+			mounted[level][mount.Name] = true
+		}
+	}
+	for _, group := range cp.mj.Spec.Groups {
+		groupLevel := paramsLevel{"the group " + group.Name, &group.Params}
+		warnings = append(warnings, overriddenParams(workflow, groupLevel)...)
+		declared = append(declared, groupLevel)
+		for _, job := range group.Jobs {
+			jobLevel := paramsLevel{fmt.Sprintf("the job %s/%s", group.Name, job.Name), &job.Params}
+			warnings = append(warnings, overriddenParams(workflow, groupLevel, jobLevel)...)
+			if !cp.runsContainer(job) {
+				continue
+			}
+			declared = append(declared, jobLevel)
+			// the unpatched params of the broken patches are good enough, such jobs are failed anyway
+			params, _ := cp.jobParameters(group, job)
+			warnings = append(warnings, missingVolumes(jobLevel.name, params)...)
+			for _, level := range []string{workflow.name, groupLevel.name, jobLevel.name} {
+				markMounted(level, params.VolumeMounts)
+			}
+		}
+	}
+
+	for _, level := range declared {
+		for _, volume := range level.params.Volumes {
+			if !mounted[level.name][volume.Name] {
+				warnings = append(warnings, fmt.Sprintf("volume %s of %s is not mounted by any job", volume.Name, level.name))
+			}
+		}
+	}
+	return warnings
+}
+
+// overriddenParams reports the values of the last level overriding the ones of the nearest level above it
+// setting them to something else, and the labels and annotations its maps drop
+func overriddenParams(levels ...paramsLevel) []string {
+	var warnings []string
+	last := levels[len(levels)-1]
+	upper := levels[:len(levels)-1]
+	for _, field := range overridableParams {
+		value := field.value(last.params)
+		if value == nil {
+			continue
+		}
+		for i := len(upper) - 1; i >= 0; i-- {
+			if upperValue := field.value(upper[i].params); upperValue != nil {
+				if !reflect.DeepEqual(upperValue, value) {
+					warnings = append(warnings, fmt.Sprintf("%s of %s overridden by %s", field.name, upper[i].name, last.name))
+				}
+				break
+			}
+		}
+	}
+	for _, maps := range []struct {
+		name  string
+		value func(params *jobsmanagerv1beta1.ManagedJobParameters) map[string]string
+	}{
+		{"labels", func(params *jobsmanagerv1beta1.ManagedJobParameters) map[string]string { return params.Labels }},
+		{"annotations", func(params *jobsmanagerv1beta1.ManagedJobParameters) map[string]string { return params.Annotations }},
+	} {
+		values := maps.value(last.params)
+		if values == nil {
+			continue
+		}
+		for i := len(upper) - 1; i >= 0; i-- {
+			upperValues := maps.value(upper[i].params)
+			if upperValues == nil {
+				continue
+			}
+			dropped := []string{}
+			for key := range upperValues {
+				if _, found := values[key]; !found {
+					dropped = append(dropped, key)
+				}
+			}
+			if len(dropped) > 0 {
+				sort.Strings(dropped)
+				warnings = append(warnings, fmt.Sprintf("%s %s of %s dropped by the %s of %s", maps.name, strings.Join(dropped, ", "), upper[i].name, maps.name, last.name))
+			}
+			break
+		}
+	}
+	return warnings
+}
+
+// missingVolumes reports the mounts of the compiled params referring to the volumes they don't have
+func missingVolumes(level string, params jobsmanagerv1beta1.ManagedJobParameters) []string {
+	volumes := map[string]bool{}
+	for _, volume := range params.Volumes {
+		volumes[volume.Name] = true
+	}
+	var warnings []string
+	for _, mount := range params.VolumeMounts {
+		if !volumes[mount.Name] {
+			warnings = append(warnings, fmt.Sprintf("volumeMount %s of %s refers to the missing volume %s", mount.MountPath, level, mount.Name))
+		}
+	}
+	return warnings
+}
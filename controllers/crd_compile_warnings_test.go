@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestCompileWarnings(t *testing.T) {
+	cache := corev1.Volume{Name: "cache", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly"},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{
+			Params: jobsmanagerv1beta1.ManagedJobParameters{ServiceAccount: "etl", Labels: map[string]string{"team": "data", "tier": "batch"}},
+			Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{
+				Name:   "extract",
+				Params: jobsmanagerv1beta1.ManagedJobParameters{ServiceAccount: "etl", WorkingDir: "/srv", Volumes: []corev1.Volume{cache}},
+				Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+					{Name: "download", Params: jobsmanagerv1beta1.ManagedJobParameters{
+						ServiceAccount: "downloader", Labels: map[string]string{"team": "data"},
+						VolumeMounts: []corev1.VolumeMount{{Name: "scratch", MountPath: "/scratch"}},
+					}},
+					{Name: "approve", Type: JobTypeManual, Params: jobsmanagerv1beta1.ManagedJobParameters{WorkingDir: "/srv"}},
+				},
+			}},
+		},
+	}
+	recorder := record.NewFakeRecorder(10)
+	cp := &connPackage{r: &ManagedJobReconciler{Recorder: recorder}, mj: mj}
+
+	cp.checkCompileWarnings()
+	expected := []string{
+		"serviceAccount of the group extract overridden by the job extract/download",
+		"labels tier of the workflow dropped by the labels of the job extract/download",
+		"volumeMount /scratch of the job extract/download refers to the missing volume scratch",
+		"volume cache of the group extract is not mounted by any job",
+	}
+	if !reflect.DeepEqual(mj.Spec.Warnings, expected) {
+		t.Errorf("expected the warnings %q, got %q", expected, mj.Spec.Warnings)
+	}
+	if len(recorder.Events) != len(expected) {
+		t.Errorf("expected %d events, got %d", len(expected), len(recorder.Events))
+	}
+
+	// the warnings already reported are not repeated, the fixed ones are gone
+	mj.Spec.Groups[0].Jobs[0].Params.VolumeMounts[0].Name = "cache"
+	for len(recorder.Events) > 0 {
+		<-recorder.Events
+	}
+	cp.checkCompileWarnings()
+	if !reflect.DeepEqual(mj.Spec.Warnings, expected[:2]) || len(recorder.Events) != 0 {
+		t.Errorf("expected the two remaining warnings without events, got %q and %d events", mj.Spec.Warnings, len(recorder.Events))
+	}
+}
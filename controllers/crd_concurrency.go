@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+/*
+Namespace concurrency caps - with MaxActiveWorkflowsPerNamespace the workflows which have not started
+any job yet wait in the Queued state while the cap is reached, first come first served by the start of
+their run. MaxActiveJobsPerNamespace stops starting new jobs while as many jobs of the namespace run.
+Both are counted from the operator cache, so they are approximate with concurrent reconciles.
+*/
+
+const queuedRequeue = 30 * time.Second
+
+var errJobCapReached = errors.New("maximum of the active jobs in the namespace reached")
+
+// namespaceWorkflows returns the other workflows of the namespace, nil when the caps are disabled
+func (cp *connPackage) namespaceWorkflows() []jobsmanagerv1beta1.ManagedJob {
+	if cp.r.MaxActiveWorkflowsPerNamespace <= 0 && cp.r.MaxActiveJobsPerNamespace <= 0 {
+		return nil
+	}
+	var workflows jobsmanagerv1beta1.ManagedJobList
+	if err := cp.client.List(cp.ctx, &workflows, &client.ListOptions{Namespace: cp.mj.Namespace}); err != nil {
+		log.Log.Info("Unable to list workflows of the namespace", "error", err.Error())
+		return nil
+	}
+	others := []jobsmanagerv1beta1.ManagedJob{}
+	for _, workflow := range workflows.Items {
+		if workflow.Name != cp.mj.Name {
+			workflow.MergeStatus()
+			others = append(others, workflow)
+		}
+	}
+	return others
+}
+
+// workflowStarted checks if any job of the current run has left the pending state
+func workflowStarted(spec *jobsmanagerv1beta1.ManagedJobSpec) bool {
+	for _, group := range spec.Groups {
+		for _, job := range group.Jobs {
+			if job.Status != "" && job.Status != ExecutionStatusPending && job.Status != ExecutionStatusSkipped {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func runningJobs(spec *jobsmanagerv1beta1.ManagedJobSpec) int {
+	running := 0
+	for _, group := range spec.Groups {
+		for _, job := range group.Jobs {
+			if job.Status == ExecutionStatusRunning {
+				running++
+			}
+		}
+	}
+	return running
+}
+
+func runStartedAt(mj *jobsmanagerv1beta1.ManagedJob) time.Time {
+	if len(mj.Spec.RunHistory) == 0 {
+		return mj.CreationTimestamp.Time
+	}
+	return mj.Spec.RunHistory[len(mj.Spec.RunHistory)-1].StartedAt.Time
+}
+
+// queuePosition returns the 1-based position of the workflow waiting for the workflows cap, 0 when it may run.
+// Workflows which have not started yet line up by the start of their run, the first ones fill the free slots.
+func queuePosition(mj *jobsmanagerv1beta1.ManagedJob, others []jobsmanagerv1beta1.ManagedJob, maxActive int) int {
+	if maxActive <= 0 || workflowStarted(&mj.Spec) {
+		return 0
+	}
+	active := 0
+	waiting := []*jobsmanagerv1beta1.ManagedJob{mj}
+	for i := range others {
+		workflow := &others[i]
+		if workflowStatus(&workflow.Spec) != ExecutionStatusRunning || workflow.Status.Phase == ExecutionStatusInvalid || workflow.Spec.Template {
+			continue // completed, waiting for the fix or never running on its own
+		}
+		if workflowStarted(&workflow.Spec) {
+			active++
+		} else {
+			waiting = append(waiting, workflow)
+		}
+	}
+	sort.SliceStable(waiting, func(i, j int) bool {
+		left, right := runStartedAt(waiting[i]), runStartedAt(waiting[j])
+		if !left.Equal(right) {
+			return left.Before(right)
+		}
+		return waiting[i].Name < waiting[j].Name
+	})
+	free := maxActive - active
+	if free < 0 {
+		free = 0
+	}
+	for i, workflow := range waiting {
+		if workflow == mj {
+			if i < free {
+				return 0
+			}
+			return i - free + 1
+		}
+	}
+	return 0
+}
+
+// waitInQueue holds the workflow until one of the active workflows of the namespace completes
+func (cp *connPackage) waitInQueue(position int) {
+	if cp.mj.Spec.QueuePosition == 0 {
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Queued", "Workflow queued at position %d, the namespace runs %d workflows at most", position, cp.r.MaxActiveWorkflowsPerNamespace)
+	}
+	cp.mj.Spec.QueuePosition = position
+	cp.requeueIn(queuedRequeue)
+}
+
+// jobSlots returns how many jobs may still start in the namespace, -1 when unlimited
+func (cp *connPackage) jobSlots(others []jobsmanagerv1beta1.ManagedJob) int {
+	if cp.r.MaxActiveJobsPerNamespace <= 0 {
+		return -1
+	}
+	running := runningJobs(&cp.mj.Spec)
+	for i := range others {
+		running += runningJobs(&others[i].Spec)
+	}
+	if running >= cp.r.MaxActiveJobsPerNamespace {
+		return 0
+	}
+	return cp.r.MaxActiveJobsPerNamespace - running
+}
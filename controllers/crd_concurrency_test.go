@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func testNamespaceWorkflow(name string, created time.Time, jobStatus string) jobsmanagerv1beta1.ManagedJob {
+	return jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "etl", CreationTimestamp: metav1.NewTime(created)},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{
+			Name:   "group",
+			Status: jobStatus,
+			Jobs:   []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "job", Status: jobStatus}},
+		}}},
+	}
+}
+
+func TestQueuePosition(t *testing.T) {
+	start := time.Date(2023, 9, 1, 2, 0, 0, 0, time.UTC)
+	others := []jobsmanagerv1beta1.ManagedJob{
+		testNamespaceWorkflow("active", start, ExecutionStatusRunning),
+		testNamespaceWorkflow("done", start, ExecutionStatusSucceeded),
+		testNamespaceWorkflow("earlier", start.Add(time.Minute), ExecutionStatusPending),
+		testNamespaceWorkflow("later", start.Add(3*time.Minute), ExecutionStatusPending),
+	}
+	mj := testNamespaceWorkflow("nightly", start.Add(2*time.Minute), ExecutionStatusPending)
+
+	tests := []struct {
+		name      string
+		maxActive int
+		expected  int
+	}{
+		{"unlimited", 0, 0},
+		{"cap reached by the active workflow", 1, 2},
+		{"free slot taken by the earlier workflow", 2, 1},
+		{"free slots for both", 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if position := queuePosition(&mj, others, tt.maxActive); position != tt.expected {
+				t.Errorf("expected position %d, got %d", tt.expected, position)
+			}
+		})
+	}
+
+	started := testNamespaceWorkflow("nightly", start.Add(2*time.Minute), ExecutionStatusRunning)
+	if position := queuePosition(&started, others, 1); position != 0 {
+		t.Errorf("expected the started workflow to keep running, got position %d", position)
+	}
+}
+
+func TestJobSlots(t *testing.T) {
+	start := time.Date(2023, 9, 1, 2, 0, 0, 0, time.UTC)
+	others := []jobsmanagerv1beta1.ManagedJob{
+		testNamespaceWorkflow("first", start, ExecutionStatusRunning),
+		testNamespaceWorkflow("second", start, ExecutionStatusRunning),
+	}
+	mj := testNamespaceWorkflow("nightly", start, ExecutionStatusPending)
+	cp := &connPackage{mj: &mj, r: &ManagedJobReconciler{}}
+	if slots := cp.jobSlots(others); slots != -1 {
+		t.Errorf("expected unlimited slots, got %d", slots)
+	}
+	cp.r.MaxActiveJobsPerNamespace = 3
+	if slots := cp.jobSlots(others); slots != 1 {
+		t.Errorf("expected 1 slot, got %d", slots)
+	}
+	cp.r.MaxActiveJobsPerNamespace = 2
+	if slots := cp.jobSlots(others); slots != 0 {
+		t.Errorf("expected no slots, got %d", slots)
+	}
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+/*
+Standard conditions - Ready, Progressing, Failed and InvalidSpec summarize the run of the workflow in
+status.conditions next to the conditions of the operator, so `kubectl wait` and kstatus based tools can
+follow the workflow, e.g. `kubectl wait --for=condition=Ready managedjob/nightly` returns once the run succeeded.
+*/
+
+const (
+	ConditionReady       = "Ready"
+	ConditionProgressing = "Progressing"
+	ConditionFailed      = "Failed"
+	ConditionInvalidSpec = "InvalidSpec"
+
+	ReasonAllGroupsSucceeded      = "AllGroupsSucceeded"
+	ReasonGroupFailed             = "GroupFailed"
+	ReasonJobCreateFailed         = "JobCreateFailed"
+	ReasonDependencyCycle         = "DependencyCycle"
+	ReasonRuntimeValidationFailed = "RuntimeValidationFailed"
+	ReasonValid                   = "Valid"
+)
+
+// setRunConditions sets Ready, Progressing and Failed from the phase of the workflow
+func (cp *connPackage) setRunConditions() {
+	phase := cp.mj.Status.Phase
+	reason := phaseReason(phase)
+	message := ""
+	ready, progressing, failed := metav1.ConditionFalse, metav1.ConditionTrue, metav1.ConditionFalse
+	switch phase {
+	case ExecutionStatusSucceeded:
+		ready, progressing = metav1.ConditionTrue, metav1.ConditionFalse
+		reason, message = ReasonAllGroupsSucceeded, "All groups succeeded"
+		if cp.mj.Spec.SuccessCriteria != "" {
+			reason, message = ReasonSuccessCriteriaMet, "Success criteria met"
+		}
+	case ExecutionStatusFailed, ExecutionStatusAborted:
+		progressing, failed = metav1.ConditionFalse, metav1.ConditionTrue
+		reason = ReasonGroupFailed
+		if cp.mj.Spec.SuccessCriteria != "" && !abortedByOperator(&cp.mj.Spec) {
+			reason = ReasonSuccessCriteriaNotMet
+		}
+		if jobCreateFailed(&cp.mj.Spec) {
+			reason = ReasonJobCreateFailed
+		}
+		if meta.IsStatusConditionTrue(cp.mj.Spec.Conditions, ConditionTimedOut) {
+			reason = ReasonDeadlineExceeded
+		}
+		message = "Run failed"
+		if digest := failureDigest(&cp.mj.Spec); digest != "" {
+			message += ": " + digest
+		}
+	case ExecutionStatusSuspended, ExecutionStatusInvalid:
+		progressing = metav1.ConditionFalse
+	}
+	if message == "" {
+		message = fmt.Sprintf("Workflow is %s", phase)
+	}
+
+	for _, condition := range []metav1.Condition{
+		{Type: ConditionReady, Status: ready},
+		{Type: ConditionProgressing, Status: progressing},
+		{Type: ConditionFailed, Status: failed},
+	} {
+		condition.Reason, condition.Message, condition.ObservedGeneration = reason, message, cp.mj.Generation
+		meta.SetStatusCondition(&cp.mj.Spec.Conditions, condition)
+	}
+}
+
+// phaseReason returns the phase of the workflow as the reason of the condition, e.g. Running
+func phaseReason(phase string) string {
+	if phase == "" {
+		phase = ExecutionStatusPending
+	}
+	return strings.ToUpper(phase[:1]) + phase[1:]
+}
+
+// jobCreateFailed tells if the run failed because a Job could not be created
+func jobCreateFailed(spec *jobsmanagerv1beta1.ManagedJobSpec) bool {
+	for _, group := range spec.Groups {
+		for _, job := range group.Jobs {
+			if job.Status == ExecutionStatusFailed && job.Reason == ReasonCreateFailed {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestSetRunConditions(t *testing.T) {
+	job := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "download", Status: ExecutionStatusRunning}
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Generation: 3},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{
+			Name: "extract", Status: ExecutionStatusRunning, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{job},
+		}}},
+		Status: jobsmanagerv1beta1.ManagedJobStatus{Phase: ExecutionStatusRunning},
+	}
+	cp := &connPackage{mj: mj}
+	conditions := func() map[string]metav1.Condition {
+		cp.setRunConditions()
+		byType := map[string]metav1.Condition{}
+		for _, condition := range mj.Spec.Conditions {
+			byType[condition.Type] = condition
+		}
+		return byType
+	}
+
+	running := conditions()
+	if running[ConditionReady].Status != metav1.ConditionFalse || running[ConditionProgressing].Status != metav1.ConditionTrue || running[ConditionFailed].Status != metav1.ConditionFalse || running[ConditionProgressing].Reason != "Running" {
+		t.Errorf("unexpected conditions of the running workflow %+v", running)
+	}
+	if running[ConditionReady].ObservedGeneration != 3 {
+		t.Errorf("expected the generation observed, got %+v", running[ConditionReady])
+	}
+
+	job.Status, job.Reason = ExecutionStatusFailed, ReasonCreateFailed
+	mj.Spec.Groups[0].Status, mj.Status.Phase = ExecutionStatusFailed, ExecutionStatusFailed
+	failed := conditions()
+	if failed[ConditionFailed].Status != metav1.ConditionTrue || failed[ConditionFailed].Reason != ReasonJobCreateFailed || failed[ConditionProgressing].Status != metav1.ConditionFalse {
+		t.Errorf("unexpected conditions of the failed workflow %+v", failed)
+	}
+
+	job.Status, job.Reason = ExecutionStatusSucceeded, ""
+	mj.Spec.Groups[0].Status, mj.Status.Phase = ExecutionStatusSucceeded, ExecutionStatusSucceeded
+	conditions()
+	if !meta.IsStatusConditionTrue(mj.Spec.Conditions, ConditionReady) || meta.IsStatusConditionTrue(mj.Spec.Conditions, ConditionFailed) || meta.FindStatusCondition(mj.Spec.Conditions, ConditionReady).Reason != ReasonAllGroupsSucceeded {
+		t.Errorf("unexpected conditions of the succeeded workflow %+v", mj.Spec.Conditions)
+	}
+}
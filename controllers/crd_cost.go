@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+/*
+Cost estimation - the finished jobs are priced by the requests of their pods multiplied by the run time,
+with the prices per CPU-hour and per GiB-hour of memory configured for the operator. Workflows labelled
+with jobsmanager.raczylo.com/cost-center pass the label to their Jobs, pods and the cost metric, so the
+costs can be attributed to the teams.
+*/
+
+// CostPrices are the prices the costs of the jobs are estimated with, nothing is estimated when both are 0
+type CostPrices struct {
+	CPUHour      float64
+	MemoryGBHour float64
+}
+
+func (p CostPrices) enabled() bool {
+	return p.CPUHour > 0 || p.MemoryGBHour > 0
+}
+
+// cost prices the requests of the pods running in parallel for the duration
+func (p CostPrices) cost(requests corev1.ResourceList, pods int32, duration time.Duration) float64 {
+	cpu := requests[corev1.ResourceCPU]
+	memory := requests[corev1.ResourceMemory]
+	perHour := cpu.AsApproximateFloat64()*p.CPUHour + memory.AsApproximateFloat64()/(1<<30)*p.MemoryGBHour
+	return perHour * duration.Hours() * float64(pods)
+}
+
+// estimateJobCost prices the finished child Job of the job
+func (cp *connPackage) estimateJobCost(job *jobsmanagerv1beta1.ManagedJobDefinition, childJob *kbatch.Job) {
+	if !cp.r.CostPrices.enabled() || childJob.Status.StartTime == nil {
+		return
+	}
+	finishedAt := childJobFinishedAt(childJob)
+	if finishedAt.IsZero() {
+		return
+	}
+	pods := int32(1)
+	if childJob.Spec.Parallelism != nil && *childJob.Spec.Parallelism > 1 {
+		pods = *childJob.Spec.Parallelism
+	}
+	requests := corev1.ResourceList{}
+	for _, container := range childJob.Spec.Template.Spec.Containers {
+		addResourceList(requests, container.Resources.Requests)
+	}
+	cost := cp.r.CostPrices.cost(requests, pods, finishedAt.Sub(childJob.Status.StartTime.Time))
+	job.EstimatedCost = jobsmanagerv1beta1.FormatCost(cost)
+}
+
+// aggregateCosts sums up the costs of the jobs of the current run and exposes them as the metric
+func (cp *connPackage) aggregateCosts() {
+	costs := []string{}
+	costCenter := cp.mj.Labels[labelCostCenter]
+	for _, group := range cp.mj.Spec.Groups {
+		for _, job := range group.Jobs {
+			costs = append(costs, job.EstimatedCost)
+			jobLabels := prometheus.Labels{"namespace": cp.mj.Namespace, "workflow": cp.mj.Name, "group": group.Name, "job": job.Name}
+			estimatedCostGauge.DeletePartialMatch(jobLabels)
+			cost, err := strconv.ParseFloat(job.EstimatedCost, 64)
+			if err != nil {
+				continue
+			}
+			estimatedCostGauge.WithLabelValues(cp.mj.Namespace, cp.mj.Name, group.Name, job.Name, costCenter).Set(cost)
+		}
+	}
+	cp.mj.Spec.EstimatedCost = jobsmanagerv1beta1.SumCosts(costs...)
+}
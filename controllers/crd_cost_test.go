@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestCostPrices(t *testing.T) {
+	prices := CostPrices{CPUHour: 0.04, MemoryGBHour: 0.005}
+	requests := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("500m"),
+		corev1.ResourceMemory: resource.MustParse("2Gi"),
+	}
+	// (0.5 * 0.04 + 2 * 0.005) * 2h * 3 pods
+	if cost := jobsmanagerv1beta1.FormatCost(prices.cost(requests, 3, 2*time.Hour)); cost != "0.1800" {
+		t.Errorf("expected 0.1800, got %s", cost)
+	}
+	if (CostPrices{}).enabled() {
+		t.Error("expected the estimation disabled without prices")
+	}
+}
+
+func TestEstimateJobCost(t *testing.T) {
+	started := metav1.NewTime(time.Date(2023, 9, 1, 2, 0, 0, 0, time.UTC))
+	completed := metav1.NewTime(started.Add(30 * time.Minute))
+	childJob := &kbatch.Job{
+		Spec: kbatch.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}},
+		}}}}},
+		Status: kbatch.JobStatus{StartTime: &started},
+	}
+	job := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "load"}
+	cp := &connPackage{r: &ManagedJobReconciler{CostPrices: CostPrices{CPUHour: 0.1}}}
+
+	cp.estimateJobCost(job, childJob)
+	if job.EstimatedCost != "" {
+		t.Errorf("expected no cost of the running job, got %s", job.EstimatedCost)
+	}
+	childJob.Status.CompletionTime = &completed
+	cp.estimateJobCost(job, childJob)
+	if job.EstimatedCost != "0.1000" {
+		t.Errorf("expected 0.1000, got %s", job.EstimatedCost)
+	}
+}
+
+func TestAggregateCosts(t *testing.T) {
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "etl", Labels: map[string]string{labelCostCenter: "data"}},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{
+			Name: "extract",
+			Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+				{Name: "download", EstimatedCost: "0.2500"},
+				{Name: "parse", EstimatedCost: "0.1250"},
+				{Name: "upload"},
+			},
+		}}},
+	}
+	cp := &connPackage{mj: mj}
+	cp.aggregateCosts()
+	defer forgetWorkflowMetrics("etl", "nightly")
+
+	if mj.Spec.EstimatedCost != "0.3750" {
+		t.Errorf("expected the workflow cost 0.3750, got %s", mj.Spec.EstimatedCost)
+	}
+	if cost := mj.Spec.Groups[0].EstimatedCost(); cost != "0.3750" {
+		t.Errorf("expected the group cost 0.3750, got %s", cost)
+	}
+	gauge := estimatedCostGauge.WithLabelValues("etl", "nightly", "extract", "download", "data")
+	if value := testutil.ToFloat64(gauge); value != 0.25 {
+		t.Errorf("expected the metric 0.25, got %v", value)
+	}
+
+	mj.Spec.Groups[0].Jobs[0].EstimatedCost = ""
+	mj.Spec.Groups[0].Jobs[1].EstimatedCost = ""
+	cp.aggregateCosts()
+	if mj.Spec.EstimatedCost != "" {
+		t.Errorf("expected no workflow cost after the reset, got %s", mj.Spec.EstimatedCost)
+	}
+}
+
+func TestRunReportCost(t *testing.T) {
+	report := testRunReport()
+	report.Cost = "0.3750"
+	report.Groups[0].Cost = "0.3750"
+	report.Groups[0].Jobs[0].Cost = "0.3750"
+	content, _, err := report.render(runReportFormatMarkdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"Estimated cost 0.3750.",
+		"## extract - failed (estimated cost 0.3750)",
+		"| download | succeeded | 10m0s | 0 | [logs](https://logs.example.com/download.log) | 0.3750 |",
+		"| parse | failed | 1m0s | 3 | - | - |",
+	} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("report is missing %q:\n%s", expected, content)
+		}
+	}
+}
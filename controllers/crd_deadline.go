@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+/*
+Active deadline - the run of the workflow with spec.activeDeadlineSeconds may take that long from its start.
+The run still going after the deadline is aborted like on request, the Jobs of the running jobs are deleted,
+and the TimedOut condition records it until the next run starts, so the workflows stuck e.g. on the images
+which never pull don't hang forever. The jobs with timeoutSeconds are limited the same way, their Jobs get
+it as the activeDeadlineSeconds and the controller fails the ones still running after it.
+*/
+
+const (
+	ConditionTimedOut      = "TimedOut"
+	ReasonDeadlineExceeded = "DeadlineExceeded"
+)
+
+// checkActiveDeadline aborts the run which exceeded the active deadline of the workflow, the run within
+// its deadline is reconciled again once it passes
+func (cp *connPackage) checkActiveDeadline() {
+	if cp.mj.Spec.ActiveDeadlineSeconds == nil || !cp.runActive() {
+		return
+	}
+	deadline := time.Duration(*cp.mj.Spec.ActiveDeadlineSeconds) * time.Second
+	startedAt := cp.currentRunStartedAt()
+	if startedAt.IsZero() {
+		startedAt = cp.mj.CreationTimestamp
+	}
+	if remaining := startedAt.Add(deadline).Sub(cp.now()); remaining > 0 {
+		// the condition of the previous run is cleared once the next one starts
+		if meta.IsStatusConditionTrue(cp.mj.Spec.Conditions, ConditionTimedOut) {
+			meta.SetStatusCondition(&cp.mj.Spec.Conditions, metav1.Condition{Type: ConditionTimedOut, Status: metav1.ConditionFalse, Reason: "WithinDeadline"})
+		}
+		cp.requeueIn(remaining)
+		return
+	}
+
+	message := fmt.Sprintf("Run exceeded its active deadline of %s", deadline)
+	cp.abortRun(ReasonDeadlineExceeded, "run exceeded its active deadline")
+	meta.SetStatusCondition(&cp.mj.Spec.Conditions, metav1.Condition{Type: ConditionTimedOut, Status: metav1.ConditionTrue, Reason: ReasonDeadlineExceeded, Message: message})
+	cp.r.Recorder.Event(cp.mj, corev1.EventTypeWarning, ConditionTimedOut, message)
+}
+
+// runActive tells if any group of the run has not finished yet
+func (cp *connPackage) runActive() bool {
+	for _, group := range cp.mj.Spec.Groups {
+		if group.Status == ExecutionStatusPending || group.Status == ExecutionStatusRunning || group.Status == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkJobTimeout fails the running job which exceeded its timeoutSeconds. The Job is deleted in case
+// its own active deadline did not stop it, the job running within its timeout is reconciled again once
+// it passes.
+func (cp *connPackage) checkJobTimeout(g *jobsmanagerv1beta1.ManagedJobGroup, j *jobsmanagerv1beta1.ManagedJobDefinition, childJob *kbatch.Job) {
+	if j.TimeoutSeconds == nil || j.Status != ExecutionStatusRunning {
+		return
+	}
+	startedAt := childJob.CreationTimestamp
+	if childJob.Status.StartTime != nil {
+		startedAt = *childJob.Status.StartTime
+	}
+	timeout := time.Duration(*j.TimeoutSeconds) * time.Second
+	if remaining := startedAt.Add(timeout).Sub(cp.now()); remaining > 0 {
+		cp.requeueIn(remaining)
+		return
+	}
+
+	cp.archiveJobLogs(j, g)
+	cp.deletePreviousJob(g, j)
+	j.Status = ExecutionStatusFailed
+	j.Reason = ReasonDeadlineExceeded
+	cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, ConditionTimedOut, "Job %s exceeded its timeout of %s", childJob.Name, timeout)
+}
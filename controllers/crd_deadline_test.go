@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestActiveDeadline(t *testing.T) {
+	stuck := &kbatch.Job{ObjectMeta: metav1.ObjectMeta{Name: "nightly-load-orders", Namespace: "apps"}}
+	c := newTestClientBuilder(stuck).Build()
+	clock := clocktesting.NewFakeClock(time.Date(2023, 9, 1, 2, 0, 0, 0, time.UTC))
+
+	deadline := int64(3600)
+	load := &jobsmanagerv1beta1.ManagedJobGroup{Name: "load", Status: ExecutionStatusRunning, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+		{Name: "orders", Status: ExecutionStatusRunning},
+		{Name: "invoices", Status: ExecutionStatusPending},
+	}}
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "apps"},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{
+			ActiveDeadlineSeconds: &deadline,
+			Groups:                []*jobsmanagerv1beta1.ManagedJobGroup{load},
+			RunHistory:            []jobsmanagerv1beta1.ManagedJobRunRecord{{StartedAt: metav1.NewTime(clock.Now())}},
+		},
+		Status: jobsmanagerv1beta1.ManagedJobStatus{Phase: ExecutionStatusRunning},
+	}
+	recorder := record.NewFakeRecorder(10)
+	cp := newTestConnPackageWithClient(&ManagedJobReconciler{Recorder: recorder, Clock: clock}, mj, c)
+
+	clock.Step(50 * time.Minute)
+	cp.checkActiveDeadline()
+	if load.Jobs[0].Status != ExecutionStatusRunning || cp.requeueAfter != 10*time.Minute {
+		t.Fatalf("expected the run within its deadline requeued for it, got %s and the requeue in %s", load.Jobs[0].Status, cp.requeueAfter)
+	}
+
+	clock.Step(10 * time.Minute)
+	cp.checkActiveDeadline()
+	for _, job := range load.Jobs {
+		if job.Status != ExecutionStatusAborted || job.Reason != ReasonDeadlineExceeded {
+			t.Errorf("expected job %s aborted, got %s %s", job.Name, job.Status, job.Reason)
+		}
+	}
+	if err := c.Get(cp.ctx, client.ObjectKeyFromObject(stuck), &kbatch.Job{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the running Job deleted, got %v", err)
+	}
+	if condition := meta.FindStatusCondition(mj.Spec.Conditions, ConditionTimedOut); condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != ReasonDeadlineExceeded {
+		t.Errorf("unexpected condition %+v", condition)
+	}
+	cp.checkOverallStatus()
+	if failed := meta.FindStatusCondition(mj.Spec.Conditions, ConditionFailed); mj.Status.Phase != ExecutionStatusFailed || failed.Reason != ReasonDeadlineExceeded {
+		t.Errorf("expected the run failed on the deadline, got %s %+v", mj.Status.Phase, failed)
+	}
+
+	// the next run gets its own deadline
+	cp.restartGroups(nil)
+	mj.Spec.RunHistory = append(mj.Spec.RunHistory, jobsmanagerv1beta1.ManagedJobRunRecord{StartedAt: metav1.NewTime(clock.Now())})
+	cp.checkActiveDeadline()
+	if load.Jobs[0].Status != ExecutionStatusPending || meta.IsStatusConditionTrue(mj.Spec.Conditions, ConditionTimedOut) {
+		t.Errorf("expected the new run within its deadline, got %s %+v", load.Jobs[0].Status, mj.Spec.Conditions)
+	}
+}
+
+func TestJobTimeout(t *testing.T) {
+	clock := clocktesting.NewFakeClock(time.Date(2023, 9, 1, 2, 0, 0, 0, time.UTC))
+	timeout := int64(600)
+	job := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "orders", Image: "busybox", Status: ExecutionStatusRunning, TimeoutSeconds: &timeout}
+	group := &jobsmanagerv1beta1.ManagedJobGroup{Name: "load", Status: ExecutionStatusRunning, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{job}}
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "apps"},
+		Spec:       jobsmanagerv1beta1.ManagedJobSpec{Retries: 1, Groups: []*jobsmanagerv1beta1.ManagedJobGroup{group}},
+	}
+	// the pods of the stuck job never started, so its own deadline did not fail it
+	stuck := &kbatch.Job{ObjectMeta: metav1.ObjectMeta{
+		Name: "nightly-load-orders", Namespace: "apps", CreationTimestamp: metav1.NewTime(clock.Now()),
+		Labels: map[string]string{labelWorkflowName: "nightly"},
+	}}
+	c := newTestClientBuilder(stuck).Build()
+	cp := newTestConnPackageWithClient(&ManagedJobReconciler{Recorder: record.NewFakeRecorder(10), Clock: clock}, mj, c)
+
+	if deadline := cp.buildJob(job, group).Spec.ActiveDeadlineSeconds; deadline == nil || *deadline != timeout {
+		t.Errorf("expected the timeout as the active deadline of the Job, got %v", deadline)
+	}
+
+	clock.Step(5 * time.Minute)
+	cp.checkRunningJobsStatus()
+	if job.Status != ExecutionStatusRunning || cp.requeueAfter != 5*time.Minute {
+		t.Fatalf("expected the job within its timeout requeued for it, got %s and the requeue in %s", job.Status, cp.requeueAfter)
+	}
+
+	clock.Step(5 * time.Minute)
+	cp.checkRunningJobsStatus()
+	if job.Status != ExecutionStatusFailed || job.Reason != ReasonDeadlineExceeded {
+		t.Errorf("expected the job failed on its timeout, got %s %s", job.Status, job.Reason)
+	}
+	if err := c.Get(cp.ctx, client.ObjectKeyFromObject(stuck), &kbatch.Job{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the stuck Job deleted, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+/* Delays and manual gates between the groups */
+
+const (
+	GroupReasonDelayed          = "Delayed"
+	GroupReasonAwaitingApproval = "AwaitingApproval"
+)
+
+// recordGroupCompletion stamps the completion time of the groups which have just reached their final status
+func (cp *connPackage) recordGroupCompletion() {
+	for _, group := range cp.mj.Spec.Groups {
+		terminal := group.Status == ExecutionStatusSucceeded || group.Status == ExecutionStatusFailed || group.Status == ExecutionStatusAborted
+		if terminal && group.CompletedAt == nil {
+			now := metav1.NewTime(cp.now())
+			group.CompletedAt = &now
+		}
+	}
+}
+
+// groupStartsAt returns the earliest start of the ready group, honouring its delayBefore, notBefore
+// and the delayAfter of the groups it depends on
+func (cp *connPackage) groupStartsAt(group *jobsmanagerv1beta1.ManagedJobGroup) time.Time {
+	startsAt := group.ReadyAt.Time
+	if group.DelayBefore != nil {
+		startsAt = startsAt.Add(group.DelayBefore.Duration)
+	}
+	if notBefore, set := cp.timeConstraint(group.NotBefore); set && notBefore.After(startsAt) {
+		startsAt = notBefore
+	}
+	for _, dependency := range group.Dependencies {
+		for _, dependencyGroup := range cp.mj.Spec.Groups {
+			if dependencyGroup.Name != dependency.Name || dependencyGroup.DelayAfter == nil || dependencyGroup.CompletedAt == nil {
+				continue
+			}
+			if delayedUntil := dependencyGroup.CompletedAt.Add(dependencyGroup.DelayAfter.Duration); delayedUntil.After(startsAt) {
+				startsAt = delayedUntil
+			}
+		}
+	}
+	return startsAt
+}
+
+// groupGatesOpen checks the manual approval and the delays of the group which dependencies are met
+func (cp *connPackage) groupGatesOpen(group *jobsmanagerv1beta1.ManagedJobGroup) bool {
+	if group.ReadyAt == nil {
+		now := metav1.NewTime(cp.now())
+		group.ReadyAt = &now
+	}
+
+	if group.PauseBefore && !groupApproved(group) {
+		if group.Reason != GroupReasonAwaitingApproval {
+			cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, GroupReasonAwaitingApproval, "Group %s is waiting for the approval", group.Name)
+		}
+		group.Reason = GroupReasonAwaitingApproval
+		return false
+	}
+
+	if remaining := cp.groupStartsAt(group).Sub(cp.now()); remaining > 0 {
+		if group.Reason != GroupReasonDelayed {
+			cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, GroupReasonDelayed, "Group %s starts in %s", group.Name, remaining.Truncate(time.Second))
+		}
+		group.Reason = GroupReasonDelayed
+		cp.requeueIn(remaining)
+		return false
+	}
+
+	if group.Reason == GroupReasonAwaitingApproval || group.Reason == GroupReasonDelayed {
+		group.Reason = ""
+	}
+	return true
+}
+
+// groupCanStart decides if the pending group which dependencies are met may be started
+func (cp *connPackage) groupCanStart(group *jobsmanagerv1beta1.ManagedJobGroup) bool {
+	// the mutex is taken last, it's not held while the group waits for anything else
+	return !cp.groupExpired(group) && cp.groupGatesOpen(group) && cp.groupFitsQuota(group) && cp.groupAcquiresMutex(group)
+}
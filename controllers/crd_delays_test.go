@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestGroupGatesOpen(t *testing.T) {
+	clock := clocktesting.NewFakeClock(time.Date(2023, 9, 1, 2, 0, 0, 0, time.UTC))
+	extract := &jobsmanagerv1beta1.ManagedJobGroup{Name: "extract", Status: ExecutionStatusSucceeded, DelayAfter: &metav1.Duration{Duration: 15 * time.Minute}}
+	load := &jobsmanagerv1beta1.ManagedJobGroup{Name: "load", Status: ExecutionStatusPending, DelayBefore: &metav1.Duration{Duration: 5 * time.Minute},
+		Dependencies: []*jobsmanagerv1beta1.ManagedJobDependencies{{Name: "extract", Status: ExecutionStatusSucceeded}}}
+	cp := &connPackage{
+		r: &ManagedJobReconciler{Recorder: record.NewFakeRecorder(10), Clock: clock},
+		mj: &jobsmanagerv1beta1.ManagedJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "nightly"},
+			Spec:       jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{extract, load}},
+		},
+	}
+
+	cp.recordGroupCompletion()
+	if !extract.CompletedAt.Time.Equal(clock.Now()) {
+		t.Fatalf("expected the completion stamped by the clock, got %s", extract.CompletedAt)
+	}
+	if cp.groupGatesOpen(load) || load.Reason != GroupReasonDelayed {
+		t.Fatalf("expected the group delayed, got %s", load.Reason)
+	}
+	// the delayAfter of the dependency outlasts the delayBefore of the group
+	if cp.requeueAfter != 15*time.Minute {
+		t.Errorf("expected the requeue when the delay ends, got %s", cp.requeueAfter)
+	}
+
+	clock.Step(15*time.Minute - time.Second)
+	if cp.groupGatesOpen(load) {
+		t.Fatal("expected the group delayed until the last second")
+	}
+	clock.Step(time.Second)
+	if !cp.groupGatesOpen(load) || load.Reason != "" {
+		t.Errorf("expected the group started once the delay ended, got %s", load.Reason)
+	}
+}
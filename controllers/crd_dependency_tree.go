@@ -1,10 +1,13 @@
 package controllers
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/lukaszraczylo/pandati"
-	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"raczylo.com/jobs-manager-operator/pkg/dependencies"
 )
 
 const (
@@ -100,61 +103,36 @@ func (p *printer) printItems(t []Tree, spaces []bool) string {
 	return result
 }
 
-func (cp *connPackage) checkIfPresentInDependencies(currentDependencies []*jobsmanagerv1beta1.ManagedJobDependencies, dependencyName string) bool {
-	for _, dependency := range currentDependencies {
-		if dependency.Name == dependencyName {
-			return true
-		}
+func (cp *connPackage) generateDependencyTree() {
+	changed, err := cp.resolveDependencies()
+	if !changed {
+		return
+	}
+	if err != nil {
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "InvalidParamsPatch", "Unable to patch the job params: %s", err.Error())
 	}
-	return false
+	cp.updateCRDStatusDirectly()
 }
 
-func (cp *connPackage) generateDependencyTree() {
-	// First pass - initialize the tree and get all the gathered jobs
+// resolveDependencies checks the params patches and adds the implicit dependencies of the
+// sequential groups and jobs, reporting if the workflow definition has changed.
+// Jobs which params patches can not be applied are failed before they start.
+func (cp *connPackage) resolveDependencies() (bool, error) {
 	originalMainJobDefinition := cp.mj.DeepCopy()
-
-	mainTree := New(cp.mj.Name)
+	patchErrors := []error{}
 	for _, group := range cp.mj.Spec.Groups {
-		groupTree := mainTree.Add(group.Name)
 		for _, job := range group.Jobs {
-			jobTree := groupTree.Add(job.Name)
-			job.CompiledParams = cp.compileParameters(cp.mj.Spec.Params, group.Params, job.Params)
-			if job.Parallel {
-				continue
-			} else {
-				// get the groupTree items before this job and add them as dependencies
-				for _, jobTreePrevious := range groupTree.Items() {
-					if jobTreePrevious.Text() == job.Name {
-						break
-					}
-					generatedJobName := jobNameGenerator(cp.mj.Name, group.Name, jobTreePrevious.Text())
-					jobTree.Add("Depends on: " + generatedJobName)
-					if !cp.checkIfPresentInDependencies(job.Dependencies, generatedJobName) {
-						job.Dependencies = append(job.Dependencies, &jobsmanagerv1beta1.ManagedJobDependencies{Name: generatedJobName, Status: ExecutionStatusPending})
-					}
-				}
-			}
-		}
-		if group.Parallel {
-			continue
-		} else {
-			// get the mainTree items before this group and add them as dependencies
-			for _, groupTreePrevious := range mainTree.Items() {
-				if groupTreePrevious.Text() == group.Name {
-					break
-				}
-				groupTree.Add("Depends on group: " + groupTreePrevious.Text())
-				if !cp.checkIfPresentInDependencies(group.Dependencies, groupTreePrevious.Text()) {
-					group.Dependencies = append(group.Dependencies, &jobsmanagerv1beta1.ManagedJobDependencies{Name: groupTreePrevious.Text(), Status: ExecutionStatusPending})
+			if _, err := cp.jobParameters(group, job); err != nil {
+				patchErrors = append(patchErrors, fmt.Errorf("job %s of group %s: %w", job.Name, group.Name, err))
+				if job.Status == "" || job.Status == ExecutionStatusPending {
+					job.Status = ExecutionStatusFailed
 				}
 			}
 		}
 	}
+	dependencies.Resolve(cp.mj.Name, &cp.mj.Spec)
+	cp.mj.Spec.Graph = dependencies.Graph(cp.mj.Name, &cp.mj.Spec)
 
 	_, theSame, _ := pandati.CompareStructsReplaced(originalMainJobDefinition, cp.mj)
-	if !theSame {
-		cp.updateCRDStatusDirectly()
-	}
-	// fmt.Print(mainTree.Print())
-	// fmt.Printf("Dependency tree: %# v", pretty.Formatter(mainTree))
+	return !theSame, utilerrors.NewAggregate(patchErrors)
 }
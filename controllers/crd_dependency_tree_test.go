@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+// the implicit dependencies are resolved once, the next reconciles leave the workflow as it is
+func TestResolveDependenciesStable(t *testing.T) {
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly"},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+			{Name: "extract", Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "a"}, {Name: "b"}, {Name: "c"}}},
+			{Name: "load", PartitionSize: 2, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "a"}, {Name: "b"}, {Name: "c"}}},
+			{Name: "report", Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "a"}}},
+		}},
+	}
+	cp := &connPackage{mj: mj}
+	if changed, err := cp.resolveDependencies(); !changed || err != nil {
+		t.Fatalf("first resolve changed = %v, err = %v", changed, err)
+	}
+	resolved := mj.DeepCopy()
+	for i := 0; i < 5; i++ {
+		if changed, err := cp.resolveDependencies(); changed || err != nil {
+			t.Fatalf("resolve %d changed = %v, err = %v", i, changed, err)
+		}
+	}
+	if !reflect.DeepEqual(resolved, mj) {
+		t.Error("dependencies changed on the next resolve")
+	}
+
+	names := func(dependencies []*jobsmanagerv1beta1.ManagedJobDependencies) []string {
+		result := []string{}
+		for _, dependency := range dependencies {
+			result = append(result, dependency.Name)
+		}
+		return result
+	}
+	if got := names(mj.Spec.Groups[0].Jobs[2].Dependencies); !reflect.DeepEqual(got, []string{"nightly-extract-a", "nightly-extract-b"}) {
+		t.Errorf("extract c depends on %v", got)
+	}
+	if got := names(mj.Spec.Groups[1].Jobs[2].Dependencies); !reflect.DeepEqual(got, []string{"nightly-load-a", "nightly-load-b"}) {
+		t.Errorf("load c depends on %v", got)
+	}
+	if got := names(mj.Spec.Groups[2].Dependencies); !reflect.DeepEqual(got, []string{"extract", "load"}) {
+		t.Errorf("report depends on %v", got)
+	}
+}
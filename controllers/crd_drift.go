@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"strings"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+/* Drift of the child Jobs - Jobs edited or deleted outside of the operator */
+
+const (
+	// annotationDeletedJobs decides what happens to the running step which Job was deleted,
+	// the Job is recreated unless set to fail
+	annotationDeletedJobs = "jobsmanager.raczylo.com/deleted-jobs"
+	deletedJobsFail       = "fail"
+)
+
+// jobDrift lists the fields of the live Job diverging from the expected one. Pod template is immutable,
+// a different hash means the Job was replaced. Fields left for the API server to default are not compared.
+func jobDrift(expected *kbatch.Job, live *kbatch.Job, expectedHash string) []string {
+	drift := []string{}
+	if expectedHash != "" && live.Annotations[annotationResolvedSpecHash] != expectedHash {
+		drift = append(drift, "pod template")
+	}
+	if expected.Spec.Parallelism != nil && (live.Spec.Parallelism == nil || *live.Spec.Parallelism != *expected.Spec.Parallelism) {
+		drift = append(drift, "parallelism")
+	}
+	if expected.Spec.BackoffLimit != nil && (live.Spec.BackoffLimit == nil || *live.Spec.BackoffLimit != *expected.Spec.BackoffLimit) {
+		drift = append(drift, "backoffLimit")
+	}
+	if (live.Spec.Suspend != nil && *live.Spec.Suspend) != (expected.Spec.Suspend != nil && *expected.Spec.Suspend) {
+		drift = append(drift, "suspend")
+	}
+	if (live.Spec.ActiveDeadlineSeconds == nil) != (expected.Spec.ActiveDeadlineSeconds == nil) ||
+		live.Spec.ActiveDeadlineSeconds != nil && *live.Spec.ActiveDeadlineSeconds != *expected.Spec.ActiveDeadlineSeconds {
+		drift = append(drift, "activeDeadlineSeconds")
+	}
+	return drift
+}
+
+// tracksChildJob reports the jobs running as the Job created by the operator, other executors track their own resources
+func (cp *connPackage) tracksChildJob(j *jobsmanagerv1beta1.ManagedJobDefinition) bool {
+	if j.Type == JobTypeWorkflow {
+		return false
+	}
+	executor, err := cp.r.executorFor(j.Type)
+	if err != nil {
+		return false
+	}
+	_, checksStatus := executor.(JobStatusChecker)
+	return !checksStatus
+}
+
+// jobDeleted confirms the Job missing from the cache with the API server, the cache may lag behind a fresh Job
+func (cp *connPackage) jobDeleted(name string) bool {
+	if cp.r.Clientset == nil {
+		return true
+	}
+	_, err := cp.r.Clientset.BatchV1().Jobs(cp.mj.Namespace).Get(cp.ctx, name, metav1.GetOptions{})
+	return apierrors.IsNotFound(err)
+}
+
+// checkJobDrift compares the Jobs of the running steps with their definitions, deleted Jobs are
+// recreated or the step is failed according to the deleted-jobs annotation
+func (cp *connPackage) checkJobDrift() {
+	var childJobs kbatch.JobList
+	labelSelector := labels.SelectorFromSet(labels.Set{
+		labelWorkflowName: cp.mj.Name,
+	})
+	err := cp.client.List(cp.ctx, &childJobs, &client.ListOptions{LabelSelector: labelSelector, Namespace: cp.mj.Namespace})
+	if err != nil {
+		log.Log.Info("Unable to list child jobs", "error", err.Error())
+		return
+	}
+	runStartedAt := cp.currentRunStartedAt()
+	liveJobs := map[string]*kbatch.Job{}
+	for i := range childJobs.Items {
+		if !childJobs.Items[i].CreationTimestamp.Before(&runStartedAt) {
+			liveJobs[childJobs.Items[i].Name] = &childJobs.Items[i]
+		}
+	}
+
+	for _, group := range cp.mj.Spec.Groups {
+		for _, job := range group.Jobs {
+			if job.Status != ExecutionStatusRunning || !cp.tracksChildJob(job) {
+				continue
+			}
+			generatedJobName := jobNameGenerator(cp.mj.Name, group.Name, job.Name)
+			live, found := liveJobs[generatedJobName]
+			if !found {
+				if cp.jobDeleted(generatedJobName) {
+					cp.repairDeletedJob(group, job, generatedJobName)
+				}
+				continue
+			}
+
+			drift := strings.Join(jobDrift(cp.buildJob(job, group), live, job.ResolvedSpecHash), ", ")
+			if drift != "" && drift != job.Drift {
+				cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "Drift", "Job %s was changed outside of the operator: %s", generatedJobName, drift)
+			}
+			job.Drift = drift
+		}
+	}
+}
+
+func (cp *connPackage) repairDeletedJob(group *jobsmanagerv1beta1.ManagedJobGroup, job *jobsmanagerv1beta1.ManagedJobDefinition, generatedJobName string) {
+	if cp.mj.Annotations[annotationDeletedJobs] == deletedJobsFail {
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "Drift", "Job %s of the running step was deleted, failing the step", generatedJobName)
+		job.Status = ExecutionStatusFailed
+		return
+	}
+	cp.recordDecision(decision{Action: DecisionRetry, Group: group.Name, Job: job.Name, Reason: "job " + generatedJobName + " deleted while running"})
+	err := cp.executeJob(job, group)
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "Drift", "Job %s of the running step was deleted and could not be recreated: %s", generatedJobName, err.Error())
+		return
+	}
+	if err == nil {
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "Drift", "Job %s of the running step was deleted, recreated it", generatedJobName)
+	}
+	job.Drift = ""
+}
@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	kbatch "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestJobDrift(t *testing.T) {
+	int32Ptr := func(v int32) *int32 { return &v }
+	int64Ptr := func(v int64) *int64 { return &v }
+	boolPtr := func(v bool) *bool { return &v }
+	expected := &kbatch.Job{Spec: kbatch.JobSpec{BackoffLimit: int32Ptr(3)}}
+	live := func(spec kbatch.JobSpec) *kbatch.Job {
+		return &kbatch.Job{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{annotationResolvedSpecHash: "abc"}}, Spec: spec}
+	}
+
+	tests := []struct {
+		name  string
+		live  *kbatch.Job
+		hash  string
+		drift []string
+	}{
+		{name: "defaults filled by the API server", live: live(kbatch.JobSpec{BackoffLimit: int32Ptr(3), Parallelism: int32Ptr(1), Suspend: boolPtr(false)}), hash: "abc", drift: []string{}},
+		{name: "hash not recorded", live: live(kbatch.JobSpec{BackoffLimit: int32Ptr(3)}), drift: []string{}},
+		{name: "replaced", live: live(kbatch.JobSpec{BackoffLimit: int32Ptr(3)}), hash: "def", drift: []string{"pod template"}},
+		{
+			name:  "edited",
+			live:  live(kbatch.JobSpec{BackoffLimit: int32Ptr(10), Suspend: boolPtr(true), ActiveDeadlineSeconds: int64Ptr(60)}),
+			hash:  "abc",
+			drift: []string{"backoffLimit", "suspend", "activeDeadlineSeconds"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if drift := jobDrift(expected, tt.live, tt.hash); !reflect.DeepEqual(drift, tt.drift) {
+				t.Errorf("drift = %v, expected %v", drift, tt.drift)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+/* Reconcile error budget - the workflow failing to reconcile over and over is reconciled rarely */
+
+const (
+	ConditionReconcileDegraded = "ReconcileDegraded"
+	MetricReconcileErrors      = "managedjob_reconcile_errors"
+
+	// DefaultDegradedRequeue is the interval of the reconciles of the degraded workflows when not configured
+	DefaultDegradedRequeue = 15 * time.Minute
+)
+
+var reconcileErrorsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: MetricReconcileErrors,
+	Help: "Consecutive failed reconciles of the workflow, reset by the first successful one",
+}, MetricLabels[MetricReconcileErrors])
+
+func init() {
+	metrics.Registry.MustRegister(reconcileErrorsGauge)
+}
+
+// reconcileErrors counts the consecutive failed reconciles of every workflow. The count is kept
+// in memory as well, the errors are often the failed updates of the workflow itself.
+type reconcileErrors struct {
+	mtx      sync.Mutex
+	counts   map[types.NamespacedName]int
+	failedAt map[types.NamespacedName]time.Time
+	// generation of the workflow when it failed, the edited workflow gets the next attempt right away
+	generations map[types.NamespacedName]int64
+}
+
+func (e *reconcileErrors) observe(key types.NamespacedName, failed bool, now time.Time) int {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	if e.counts == nil {
+		e.counts = map[types.NamespacedName]int{}
+		e.failedAt = map[types.NamespacedName]time.Time{}
+		e.generations = map[types.NamespacedName]int64{}
+	}
+	if !failed {
+		delete(e.counts, key)
+		delete(e.failedAt, key)
+		delete(e.generations, key)
+		return 0
+	}
+	e.counts[key]++
+	e.failedAt[key] = now
+	return e.counts[key]
+}
+
+// observedGeneration remembers the generation the failure was recorded at, after the workflow was updated
+func (e *reconcileErrors) observedGeneration(key types.NamespacedName, generation int64) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	if _, found := e.counts[key]; found {
+		e.generations[key] = generation
+	}
+}
+
+// backoff returns how long the degraded workflow still waits for its next reconcile, 0 when it may run now
+func (e *reconcileErrors) backoff(key types.NamespacedName, generation int64, budget int, interval time.Duration, now time.Time) time.Duration {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	if budget <= 0 || e.counts[key] < budget || e.generations[key] != generation {
+		return 0
+	}
+	if remaining := e.failedAt[key].Add(interval).Sub(now); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+func (e *reconcileErrors) forget(key types.NamespacedName) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	delete(e.counts, key)
+	delete(e.failedAt, key)
+	delete(e.generations, key)
+}
+
+// reconcileError records the error failing the current reconcile, the step itself carries on best-effort
+func (cp *connPackage) reconcileError(err error) {
+	cp.errs = append(cp.errs, err)
+}
+
+func (r *ManagedJobReconciler) degradedRequeue() time.Duration {
+	if r.DegradedRequeue > 0 {
+		return r.DegradedRequeue
+	}
+	return DefaultDegradedRequeue
+}
+
+// trackReconcileErrors counts the failed reconcile, degrades the workflow once the errors reach the budget
+// and slows its requeues down, the first successful reconcile restores it
+func (cp *connPackage) trackReconcileErrors() {
+	count := cp.r.reconcileErrors.observe(cp.req.NamespacedName, len(cp.errs) > 0, cp.now())
+	reconcileErrorsGauge.WithLabelValues(cp.mj.Namespace, cp.mj.Name).Set(float64(count))
+	if count > 0 {
+		log.Log.Info("Reconcile failed", "workflow", cp.mj.Name, "namespace", cp.mj.Namespace, "errors", count, "error", cp.errs[0].Error())
+	}
+
+	degraded := cp.r.ReconcileErrorBudget > 0 && count >= cp.r.ReconcileErrorBudget
+	condition := metav1.Condition{
+		Type:   ConditionReconcileDegraded,
+		Status: metav1.ConditionFalse,
+		Reason: "ReconcileSucceeded",
+	}
+	if degraded {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "ErrorBudgetExhausted"
+		condition.Message = fmt.Sprintf("%d consecutive reconciles failed, last error: %s", count, cp.errs[0].Error())
+	}
+	wasDegraded := meta.IsStatusConditionTrue(cp.mj.Spec.Conditions, ConditionReconcileDegraded)
+	if degraded && !wasDegraded {
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, ConditionReconcileDegraded, "Reconciled every %s after %d consecutive errors: %s", cp.r.degradedRequeue(), count, cp.errs[0].Error())
+	}
+
+	changed := cp.mj.Spec.ReconcileErrors != count
+	cp.mj.Spec.ReconcileErrors = count
+	// the condition is only kept once the workflow was degraded
+	if existing := meta.FindStatusCondition(cp.mj.Spec.Conditions, ConditionReconcileDegraded); degraded || existing != nil {
+		if existing == nil || existing.Status != condition.Status || existing.Message != condition.Message {
+			meta.SetStatusCondition(&cp.mj.Spec.Conditions, condition)
+			changed = true
+		}
+	}
+	if changed {
+		// a failure here is counted by the next reconcile
+		_ = cp.updateCRDStatusDirectly()
+	}
+	// the spec written by the operator bumps the generation too, only the later edits of the workflow skip the backoff
+	cp.r.reconcileErrors.observedGeneration(cp.req.NamespacedName, cp.mj.Generation)
+	if degraded {
+		cp.requeueAfter = cp.r.degradedRequeue()
+	}
+}
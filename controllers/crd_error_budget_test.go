@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestReconcileErrorsBackoff(t *testing.T) {
+	key := types.NamespacedName{Namespace: "etl", Name: "nightly"}
+	now := time.Now()
+	errs := &reconcileErrors{}
+	errs.observe(key, true, now)
+	errs.observedGeneration(key, 3)
+	if wait := errs.backoff(key, 3, 2, time.Hour, now); wait != 0 {
+		t.Errorf("expected no backoff within the budget, got %s", wait)
+	}
+	errs.observe(key, true, now)
+	if wait := errs.backoff(key, 3, 2, time.Hour, now.Add(time.Minute)); wait != 59*time.Minute {
+		t.Errorf("expected the degraded workflow to wait, got %s", wait)
+	}
+	if wait := errs.backoff(key, 4, 2, time.Hour, now.Add(time.Minute)); wait != 0 {
+		t.Errorf("expected the edited workflow reconciled right away, got %s", wait)
+	}
+	if wait := errs.backoff(key, 3, 0, time.Hour, now.Add(time.Minute)); wait != 0 {
+		t.Errorf("expected no backoff without the budget, got %s", wait)
+	}
+	if count := errs.observe(key, false, now); count != 0 || errs.backoff(key, 3, 2, time.Hour, now) != 0 {
+		t.Errorf("expected the successful reconcile to reset the count, got %d", count)
+	}
+}
+
+func TestTrackReconcileErrors(t *testing.T) {
+	mj := &jobsmanagerv1beta1.ManagedJob{ObjectMeta: metav1.ObjectMeta{Name: "poison", Namespace: "etl"}}
+	c := newTestClientBuilder(mj).WithStatusSubresource(mj).Build()
+	r := &ManagedJobReconciler{Recorder: record.NewFakeRecorder(10), ReconcileErrorBudget: 2, DegradedRequeue: time.Hour}
+
+	reconcile := func(err error) *connPackage {
+		workflow := &jobsmanagerv1beta1.ManagedJob{}
+		if err := c.Get(context.Background(), types.NamespacedName{Namespace: "etl", Name: "poison"}, workflow); err != nil {
+			t.Fatal(err)
+		}
+		workflow.MergeStatus()
+		cp := newTestConnPackageWithClient(r, workflow, c)
+		if err != nil {
+			cp.reconcileError(err)
+		}
+		cp.trackReconcileErrors()
+		return cp
+	}
+
+	cp := reconcile(errors.New("admission webhook denied the request"))
+	if cp.mj.Spec.ReconcileErrors != 1 || cp.mj.Spec.Conditions != nil || cp.requeueAfter != 0 {
+		t.Errorf("expected a single error within the budget, got %d %v %s", cp.mj.Spec.ReconcileErrors, cp.mj.Spec.Conditions, cp.requeueAfter)
+	}
+
+	cp = reconcile(errors.New("admission webhook denied the request"))
+	if !meta.IsStatusConditionTrue(cp.mj.Spec.Conditions, ConditionReconcileDegraded) || cp.mj.Spec.ReconcileErrors != 2 {
+		t.Errorf("expected the workflow degraded, got %d %v", cp.mj.Spec.ReconcileErrors, cp.mj.Spec.Conditions)
+	}
+	if cp.requeueAfter != time.Hour {
+		t.Errorf("expected the slow requeue, got %s", cp.requeueAfter)
+	}
+	if value := testutil.ToFloat64(reconcileErrorsGauge.WithLabelValues("etl", "poison")); value != 2 {
+		t.Errorf("expected the errors metric at 2, got %v", value)
+	}
+
+	cp = reconcile(nil)
+	if cp.mj.Spec.ReconcileErrors != 0 || meta.IsStatusConditionTrue(cp.mj.Spec.Conditions, ConditionReconcileDegraded) || cp.requeueAfter != 0 {
+		t.Errorf("expected the workflow restored, got %d %v %s", cp.mj.Spec.ReconcileErrors, cp.mj.Spec.Conditions, cp.requeueAfter)
+	}
+	forgetWorkflowMetrics("etl", "poison")
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+/* Run tracking and estimation of the remaining work based on the previous runs */
+
+const runReasonCreated = "Created"
+
+// trackRuns keeps the run history up to date - the first run starts with the workflow creation
+// and the latest run is completed once the workflow reaches the terminal state
+func (cp *connPackage) trackRuns() {
+	if len(cp.mj.Spec.RunHistory) == 0 {
+		cp.recordRun(jobsmanagerv1beta1.ManagedJobRunRecord{
+			StartedAt: cp.mj.CreationTimestamp,
+			Reason:    runReasonCreated,
+		})
+	}
+
+	run := &cp.mj.Spec.RunHistory[len(cp.mj.Spec.RunHistory)-1]
+	if run.ID == "" && run.CompletedAt == nil {
+		run.ID = cp.newRunID() // run started before the operator assigned the IDs
+	}
+	status := workflowStatus(&cp.mj.Spec)
+	if run.CompletedAt == nil && (status == ExecutionStatusSucceeded || status == ExecutionStatusFailed) {
+		now := metav1.NewTime(cp.now())
+		run.CompletedAt = &now
+		run.Status = status
+		log.Log.Info("Run completed", "workflow", cp.mj.Name, "namespace", cp.mj.Namespace, "run", run.ID, "status", status)
+		run.EstimatedCost = cp.mj.Spec.EstimatedCost
+		cp.publishRunReport(run)
+		observeRun(cp.mj, run)
+	}
+
+	cp.mj.Spec.EstimatedCompletion = estimateCompletion(cp.mj.Spec.RunHistory)
+	cp.mj.Spec.Progress, cp.mj.Spec.FailedGroup = runProgress(&cp.mj.Spec)
+	cp.mj.Spec.FailureDigest = ""
+	if status == ExecutionStatusFailed {
+		cp.mj.Spec.FailureDigest = failureDigest(&cp.mj.Spec)
+	}
+	cp.mj.Spec.Duration = ""
+	if run.CompletedAt != nil {
+		cp.mj.Spec.Duration = run.CompletedAt.Sub(run.StartedAt.Time).Truncate(time.Second).String()
+	}
+}
+
+// runProgress summarizes the current run for the printer columns - the finished jobs out of all
+// and the first group which failed, the aborted groups only follow the failure
+func runProgress(spec *jobsmanagerv1beta1.ManagedJobSpec) (string, string) {
+	finished, total := 0, 0
+	failedGroup := ""
+	for _, group := range spec.Groups {
+		if group.Status == ExecutionStatusFailed && failedGroup == "" {
+			failedGroup = group.Name
+		}
+		for _, job := range group.Jobs {
+			total++
+			switch job.Status {
+			case ExecutionStatusSucceeded, ExecutionStatusFailed, ExecutionStatusAborted, ExecutionStatusSkipped:
+				finished++
+			}
+		}
+	}
+	return fmt.Sprintf("%d/%d", finished, total), failedGroup
+}
+
+// estimateCompletion returns the expected end of the running run, which is its start shifted
+// by the average duration of the previous successful runs, nil if there is nothing to base it on
+func estimateCompletion(history []jobsmanagerv1beta1.ManagedJobRunRecord) *metav1.Time {
+	if len(history) == 0 {
+		return nil
+	}
+	current := history[len(history)-1]
+	if current.CompletedAt != nil {
+		return nil
+	}
+
+	var total time.Duration
+	runs := 0
+	for _, run := range history[:len(history)-1] {
+		if run.CompletedAt == nil || run.Status != ExecutionStatusSucceeded {
+			continue
+		}
+		total += run.CompletedAt.Sub(run.StartedAt.Time)
+		runs++
+	}
+	if runs == 0 {
+		return nil
+	}
+	eta := metav1.NewTime(current.StartedAt.Add(total / time.Duration(runs)).Truncate(time.Second))
+	return &eta
+}
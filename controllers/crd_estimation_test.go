@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestTrackRunsPrinterColumns(t *testing.T) {
+	started := metav1.NewTime(time.Now().Add(-90 * time.Minute))
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", CreationTimestamp: started},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+			{Name: "extract", Status: ExecutionStatusSucceeded, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "a", Status: ExecutionStatusSucceeded}, {Name: "b", Status: ExecutionStatusSkipped}}},
+			{Name: "load", Status: ExecutionStatusRunning, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "a", Status: ExecutionStatusRunning}, {Name: "b", Status: ExecutionStatusPending}}},
+			{Name: "report", Status: ExecutionStatusPending, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "a", Status: ExecutionStatusPending}}},
+		}},
+	}
+	cp := &connPackage{mj: mj}
+	cp.trackRuns()
+	if mj.Spec.Progress != "2/5" || mj.Spec.FailedGroup != "" || mj.Spec.Duration != "" {
+		t.Errorf("running workflow: progress %q, failed group %q, duration %q", mj.Spec.Progress, mj.Spec.FailedGroup, mj.Spec.Duration)
+	}
+
+	load, report := mj.Spec.Groups[1], mj.Spec.Groups[2]
+	load.Status, load.Jobs[0].Status, load.Jobs[1].Status = ExecutionStatusFailed, ExecutionStatusFailed, ExecutionStatusAborted
+	report.Status, report.Jobs[0].Status = ExecutionStatusAborted, ExecutionStatusAborted
+	cp.trackRuns()
+	if mj.Spec.Progress != "5/5" || mj.Spec.FailedGroup != "load" {
+		t.Errorf("failed workflow: progress %q, failed group %q", mj.Spec.Progress, mj.Spec.FailedGroup)
+	}
+	if duration, err := time.ParseDuration(mj.Spec.Duration); err != nil || duration < 90*time.Minute || duration > 91*time.Minute {
+		t.Errorf("unexpected duration %q", mj.Spec.Duration)
+	}
+}
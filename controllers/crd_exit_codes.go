@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+/* Success exit codes - failed pods exiting with the listed codes count as succeeded */
+
+// applySuccessExitCodes fails the Job right away when the pod exits with one of the non-zero success codes,
+// so it's not retried, the controller then maps it onto success. Pod failure policies need pods never restarted in place.
+func applySuccessExitCodes(j *jobsmanagerv1beta1.ManagedJobDefinition, job *kbatch.Job) {
+	codes := []int32{}
+	for _, code := range j.SuccessExitCodes {
+		if code != 0 {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return
+	}
+	job.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+	if job.Spec.PodFailurePolicy == nil {
+		job.Spec.PodFailurePolicy = &kbatch.PodFailurePolicy{}
+	}
+	job.Spec.PodFailurePolicy.Rules = append(job.Spec.PodFailurePolicy.Rules, kbatch.PodFailurePolicyRule{
+		Action: kbatch.PodFailurePolicyActionFailJob,
+		OnExitCodes: &kbatch.PodFailurePolicyOnExitCodesRequirement{
+			Operator: kbatch.PodFailurePolicyOnExitCodesOpIn,
+			Values:   codes,
+		},
+	})
+}
+
+// successExitCode returns the exit code of the last failed pod of the Job when it's one of the success codes
+func (cp *connPackage) successExitCode(j *jobsmanagerv1beta1.ManagedJobDefinition, childJob *kbatch.Job) (int32, bool) {
+	if len(j.SuccessExitCodes) == 0 {
+		return 0, false
+	}
+	var pods corev1.PodList
+	labelSelector := labels.SelectorFromSet(labels.Set{labelWorkflowName: cp.mj.Name, labelJobName: childJob.Name})
+	if err := cp.client.List(cp.ctx, &pods, &client.ListOptions{LabelSelector: labelSelector, Namespace: cp.mj.Namespace}); err != nil {
+		log.Log.Info("Unable to list job pods", "job", childJob.Name, "error", err.Error())
+		return 0, false
+	}
+	var last *corev1.Pod
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase == corev1.PodFailed && (last == nil || last.CreationTimestamp.Before(&pod.CreationTimestamp)) {
+			last = pod
+		}
+	}
+	if last == nil {
+		return 0, false
+	}
+	exitCode, terminated := podExitCode(last)
+	if !terminated {
+		return 0, false
+	}
+	for _, code := range j.SuccessExitCodes {
+		if code == exitCode {
+			return exitCode, true
+		}
+	}
+	return 0, false
+}
+
+// podExitCode is the first non-zero exit code of the terminated containers, zero when all of them succeeded
+func podExitCode(pod *corev1.Pod) (int32, bool) {
+	terminated := false
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Terminated == nil {
+			continue
+		}
+		terminated = true
+		if status.State.Terminated.ExitCode != 0 {
+			return status.State.Terminated.ExitCode, true
+		}
+	}
+	return 0, terminated
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestApplySuccessExitCodes(t *testing.T) {
+	job := &kbatch.Job{Spec: kbatch.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{RestartPolicy: corev1.RestartPolicyOnFailure}}}}
+	applySuccessExitCodes(&jobsmanagerv1beta1.ManagedJobDefinition{SuccessExitCodes: []int32{0}}, job)
+	if job.Spec.PodFailurePolicy != nil || job.Spec.Template.Spec.RestartPolicy != corev1.RestartPolicyOnFailure {
+		t.Fatalf("expected the job untouched when only 0 is listed, got %+v", job.Spec)
+	}
+
+	applySuccessExitCodes(&jobsmanagerv1beta1.ManagedJobDefinition{SuccessExitCodes: []int32{0, 2, 3}}, job)
+	if job.Spec.Template.Spec.RestartPolicy != corev1.RestartPolicyNever {
+		t.Errorf("expected the restart policy Never, got %s", job.Spec.Template.Spec.RestartPolicy)
+	}
+	rules := job.Spec.PodFailurePolicy.Rules
+	if len(rules) != 1 || rules[0].Action != kbatch.PodFailurePolicyActionFailJob || len(rules[0].OnExitCodes.Values) != 2 {
+		t.Errorf("expected the job failed on exit codes 2 and 3, got %+v", rules)
+	}
+}
+
+func failedPod(name string, created time.Time, exitCode int32) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "etl",
+			CreationTimestamp: metav1.NewTime(created),
+			Labels:            map[string]string{labelWorkflowName: "nightly", labelJobName: "nightly-extract-sync"},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodFailed,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode}},
+			}},
+		},
+	}
+}
+
+func TestCheckRunningJobsStatusSuccessExitCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		pods     []client.Object
+		expected string
+	}{
+		{"nothing to do", []client.Object{failedPod("first", time.Now().Add(-time.Hour), 1), failedPod("second", time.Now(), 2)}, ExecutionStatusSucceeded},
+		{"other exit code", []client.Object{failedPod("first", time.Now().Add(-time.Hour), 2), failedPod("second", time.Now(), 1)}, ExecutionStatusFailed},
+		{"pods gone", nil, ExecutionStatusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			childJob := &kbatch.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "nightly-extract-sync", Namespace: "etl", Labels: map[string]string{labelWorkflowName: "nightly"}},
+				Status:     kbatch.JobStatus{Failed: 1},
+			}
+			c := newTestClientBuilder(append(tt.pods, childJob)...).Build()
+
+			job := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "sync", Status: ExecutionStatusRunning, SuccessExitCodes: []int32{0, 2}}
+			cp := newTestConnPackageWithClient(nil, &jobsmanagerv1beta1.ManagedJob{
+				ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "etl"},
+				Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{
+					Name: "extract", Status: ExecutionStatusRunning, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{job},
+				}}},
+			}, c)
+
+			cp.checkRunningJobsStatus()
+			if job.Status != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, job.Status)
+			}
+			// the mapped status sticks while the Job stays failed
+			cp.checkRunningJobsStatus()
+			if job.Status != tt.expected {
+				t.Errorf("expected %s on the next pass, got %s", tt.expected, job.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+/* Explanations - why the job is in its current state, following the same rules as the scheduler */
+
+// explainJob describes in plain sentences why the job has its current status
+func explainJob(mj *jobsmanagerv1beta1.ManagedJob, group *jobsmanagerv1beta1.ManagedJobGroup, job *jobsmanagerv1beta1.ManagedJobDefinition) []string {
+	generatedJobName := jobNameGenerator(mj.Name, group.Name, job.Name)
+	subject := fmt.Sprintf("Job %s of group %s", job.Name, group.Name)
+	switch job.Status {
+	case ExecutionStatusSucceeded:
+		return []string{subject + " succeeded."}
+	case ExecutionStatusRunning:
+		if job.Type == JobTypeManual {
+			return []string{fmt.Sprintf("%s is a manual step, it waits for its outcome - run `kubectl managedjob complete %s %s %s` once it's done.", subject, mj.Name, group.Name, job.Name)}
+		}
+		lines := []string{fmt.Sprintf("%s is running as %s.", subject, generatedJobName)}
+		if job.FanOutSummary != "" {
+			lines = append(lines, "Progress of the indexes: "+job.FanOutSummary+".")
+		}
+		if job.Drift != "" {
+			lines = append(lines, "Its Job was changed outside of the operator: "+job.Drift+".")
+		}
+		if job.SchedulingLatency == nil && job.SlowScheduling != "" {
+			lines = append(lines, "Its pods are not running yet: "+job.SlowScheduling+".")
+		}
+		return lines
+	case ExecutionStatusFailed:
+		lines := []string{subject + " failed."}
+		if job.Reason == ReasonDeadlineExceeded && job.TimeoutSeconds != nil {
+			lines = append(lines, fmt.Sprintf("It ran longer than its timeoutSeconds %d.", *job.TimeoutSeconds))
+		}
+		if job.ResolvedSpecHash == "" && (job.Type == "" || job.Type == JobTypeContainer) {
+			lines = append(lines, "It failed before its Job was created, e.g. because of an invalid params patch or a rejected Job - check the events of the workflow.")
+		} else {
+			lines = append(lines, fmt.Sprintf("Check the logs of %s.", generatedJobName))
+		}
+		if job.ArchivedLogs != "" {
+			lines = append(lines, "Its logs were archived to "+job.ArchivedLogs+".")
+		}
+		return lines
+	case ExecutionStatusSkipped:
+		switch {
+		case group.Reason == ReasonExpired:
+			return []string{fmt.Sprintf("%s was skipped, its group %s was not able to start before its notAfter %s.", subject, group.Name, group.NotAfter)}
+		case job.Reason == ReasonExpired:
+			return []string{fmt.Sprintf("%s was skipped, it was not able to start before its notAfter %s.", subject, job.NotAfter)}
+		case group.Reason == ReasonConditionNotMet:
+			lines := []string{fmt.Sprintf("%s was skipped, the groups its group %s depends on finished in the state it does not run for:", subject, group.Name)}
+			return append(lines, dependencyLines(group.Dependencies)...)
+		case job.Reason == ReasonConditionNotMet:
+			lines := []string{subject + " was skipped, its dependencies finished in the state it does not run for:"}
+			return append(lines, dependencyLines(job.Dependencies)...)
+		}
+		return []string{fmt.Sprintf("%s was skipped, its group %s is not in spec.enabledGroups.", subject, group.Name)}
+	case ExecutionStatusAborted:
+		if job.Reason == ReasonNamespaceTerminating {
+			return []string{fmt.Sprintf("%s was aborted, its namespace %s is being deleted.", subject, mj.Namespace)}
+		}
+		if job.Reason == ReasonDeadlineExceeded {
+			return []string{subject + " was aborted, the run exceeded its spec.activeDeadlineSeconds."}
+		}
+		lines := []string{subject + " was aborted without starting, its dependencies failed:"}
+		lines = append(lines, dependencyLines(failedDependencies(job.Dependencies))...)
+		return append(lines, rootCauseLines(mj, job.Dependencies)...)
+	}
+
+	lines := []string{fmt.Sprintf("%s is %s.", subject, job.Status)}
+	switch group.Status {
+	case ExecutionStatusSucceeded, ExecutionStatusFailed:
+		return append(lines, fmt.Sprintf("Its group %s has already %s, the job will not start.", group.Name, group.Status))
+	case ExecutionStatusAborted:
+		lines = append(lines, fmt.Sprintf("Its group %s was aborted, the groups it depends on failed:", group.Name))
+		lines = append(lines, dependencyLines(failedDependencies(group.Dependencies))...)
+		return append(lines, rootCauseLines(mj, group.Dependencies)...)
+	}
+	if !dependenciesMet(group.Dependencies) {
+		lines = append(lines, fmt.Sprintf("Its group %s waits for the groups:", group.Name))
+		return append(lines, dependencyLines(unmetDependencies(group.Dependencies))...)
+	}
+	if mj.Spec.Suspend {
+		return append(lines, "The workflow is suspended, no new jobs are started until spec.suspend is set to false.")
+	}
+	if heldForStart(&mj.Spec) {
+		return append(lines, fmt.Sprintf("The workflow was created with startSuspended, its first run waits for the %s action.", ActionStart))
+	}
+	if group.Reason == GroupReasonWorkflowDependency {
+		lines = append(lines, "The run waits for the workflows in spec.dependsOn to succeed:")
+		for _, dependency := range mj.Spec.DependsOn {
+			lines = append(lines, "  - "+workflowDependencyKey(mj, dependency).String())
+		}
+		return lines
+	}
+	if mj.Spec.QueuePosition > 0 {
+		return append(lines, fmt.Sprintf("The workflow is queued at position %d, the namespace runs the maximum of active workflows.", mj.Spec.QueuePosition))
+	}
+	if group.Reason == GroupReasonWaiting {
+		return append(lines, fmt.Sprintf("Its group %s waits for the maintenance window to end, no new jobs are started until then.", group.Name))
+	}
+	if group.Status == ExecutionStatusPending {
+		switch group.Reason {
+		case GroupReasonAwaitingApproval:
+			return append(lines, fmt.Sprintf("Its group %s waits for the approval, run `kubectl managedjob approve %s %s`.", group.Name, mj.Name, group.Name))
+		case GroupReasonDelayed:
+			cp := &connPackage{mj: mj}
+			if group.ReadyAt != nil {
+				return append(lines, fmt.Sprintf("Its group %s is delayed by delayBefore, delayAfter or notBefore until %s.", group.Name, cp.groupStartsAt(group).Format(time.RFC3339)))
+			}
+			return append(lines, fmt.Sprintf("Its group %s is delayed by delayBefore, delayAfter or notBefore.", group.Name))
+		case GroupReasonQuotaWait:
+			return append(lines, fmt.Sprintf("Its group %s waits until the requests of its jobs fit into the namespace resource quota.", group.Name))
+		case GroupReasonMutexWait:
+			return append(lines, fmt.Sprintf("Its group %s waits for the mutex %s held by another group or job, see `kubectl get managedjobmutexes`.", group.Name, group.Synchronization.Mutex))
+		}
+	}
+	if !dependenciesMet(job.Dependencies) {
+		lines = append(lines, "It waits for:")
+		return append(lines, dependencyLines(unmetDependencies(job.Dependencies))...)
+	}
+	if job.NotBefore != "" {
+		lines = append(lines, fmt.Sprintf("It does not start before its notBefore %s.", job.NotBefore))
+	}
+	if job.Synchronization != nil {
+		return append(lines, fmt.Sprintf("All its dependencies are met, it starts once it holds the mutex %s.", job.Synchronization.Mutex))
+	}
+	return append(lines, "All its dependencies are met, it starts on the next reconcile.")
+}
+
+func unmetDependencies(dependencies []*jobsmanagerv1beta1.ManagedJobDependencies) []*jobsmanagerv1beta1.ManagedJobDependencies {
+	unmet := []*jobsmanagerv1beta1.ManagedJobDependencies{}
+	for _, dependency := range dependencies {
+		if !dependencyMet(dependency) {
+			unmet = append(unmet, dependency)
+		}
+	}
+	return unmet
+}
+
+func failedDependencies(dependencies []*jobsmanagerv1beta1.ManagedJobDependencies) []*jobsmanagerv1beta1.ManagedJobDependencies {
+	failed := []*jobsmanagerv1beta1.ManagedJobDependencies{}
+	for _, dependency := range dependencies {
+		if dependency.Status == ExecutionStatusFailed && dependencyUnmet(dependency) {
+			failed = append(failed, dependency)
+		}
+	}
+	return failed
+}
+
+func dependencyLines(dependencies []*jobsmanagerv1beta1.ManagedJobDependencies) []string {
+	lines := []string{}
+	for _, dependency := range dependencies {
+		if condition := dependency.DependencyCondition(); condition != jobsmanagerv1beta1.DependencyConditionSucceeded {
+			lines = append(lines, fmt.Sprintf("  - %s (%s, condition %s)", dependency.Name, dependency.Status, condition))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("  - %s (%s)", dependency.Name, dependency.Status))
+	}
+	return lines
+}
+
+// rootCauseLines follows the failed dependencies through the aborted groups and jobs up to the failed jobs
+func rootCauseLines(mj *jobsmanagerv1beta1.ManagedJob, dependencies []*jobsmanagerv1beta1.ManagedJobDependencies) []string {
+	groups := map[string]*jobsmanagerv1beta1.ManagedJobGroup{}
+	jobs := map[string]*jobsmanagerv1beta1.ManagedJobDefinition{}
+	jobGroups := map[string]string{}
+	for _, group := range mj.Spec.Groups {
+		groups[group.Name] = group
+		for _, job := range group.Jobs {
+			generatedJobName := jobNameGenerator(mj.Name, group.Name, job.Name)
+			jobs[generatedJobName] = job
+			jobGroups[generatedJobName] = group.Name
+		}
+	}
+
+	causes := []string{}
+	seen := map[string]bool{}
+	var follow func(dependencies []*jobsmanagerv1beta1.ManagedJobDependencies)
+	follow = func(dependencies []*jobsmanagerv1beta1.ManagedJobDependencies) {
+		for _, dependency := range failedDependencies(dependencies) {
+			if seen[dependency.Name] {
+				continue
+			}
+			seen[dependency.Name] = true
+			if job, found := jobs[dependency.Name]; found {
+				if job.Status == ExecutionStatusAborted {
+					follow(job.Dependencies)
+				} else {
+					causes = append(causes, fmt.Sprintf("  - job %s of group %s %s", job.Name, jobGroups[dependency.Name], job.Status))
+				}
+				continue
+			}
+			group, found := groups[dependency.Name]
+			if !found {
+				continue
+			}
+			if group.Status == ExecutionStatusAborted {
+				follow(group.Dependencies)
+				continue
+			}
+			// the failed group points at its failed and aborted jobs
+			for _, job := range group.Jobs {
+				if job.Status == ExecutionStatusFailed || job.Status == ExecutionStatusAborted {
+					follow([]*jobsmanagerv1beta1.ManagedJobDependencies{{Name: jobNameGenerator(mj.Name, group.Name, job.Name), Status: ExecutionStatusFailed}})
+				}
+			}
+		}
+	}
+	follow(dependencies)
+	if len(causes) == 0 {
+		return nil
+	}
+	return append([]string{"The failure started at:"}, causes...)
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestExplainJob(t *testing.T) {
+	dependsOn := func(name, status string) []*jobsmanagerv1beta1.ManagedJobDependencies {
+		return []*jobsmanagerv1beta1.ManagedJobDependencies{{Name: name, Status: status}}
+	}
+	timeout := int64(900)
+	mj := &jobsmanagerv1beta1.ManagedJob{}
+	mj.Name = "wf"
+	mj.Spec.Groups = []*jobsmanagerv1beta1.ManagedJobGroup{
+		{Name: "build", Status: ExecutionStatusFailed, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+			{Name: "compile", Status: ExecutionStatusFailed, ResolvedSpecHash: "abc"},
+			{Name: "package", Status: ExecutionStatusAborted, Dependencies: dependsOn("wf-build-compile", ExecutionStatusFailed)},
+			{Name: "publish", Status: ExecutionStatusAborted, Dependencies: dependsOn("wf-build-package", ExecutionStatusFailed)},
+		}},
+		{Name: "deploy", Status: ExecutionStatusAborted, Dependencies: dependsOn("build", ExecutionStatusFailed), Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+			{Name: "rollout", Status: ExecutionStatusPending},
+		}},
+		{Name: "verify", Status: ExecutionStatusPending, Reason: GroupReasonAwaitingApproval, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+			{Name: "smoke", Status: ExecutionStatusPending},
+		}},
+		{Name: "report", Status: ExecutionStatusRunning, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+			{Name: "collect", Status: ExecutionStatusRunning},
+			{Name: "send", Status: ExecutionStatusPending, Dependencies: dependsOn("wf-report-collect", ExecutionStatusRunning)},
+		}},
+		{Name: "export", Status: ExecutionStatusFailed, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+			{Name: "dump", Status: ExecutionStatusFailed, Reason: ReasonDeadlineExceeded, TimeoutSeconds: &timeout, ResolvedSpecHash: "def"},
+		}},
+	}
+
+	tests := []struct {
+		group, job string
+		expected   []string
+	}{
+		{"build", "compile", []string{
+			"Job compile of group build failed.",
+			"Check the logs of wf-build-compile.",
+		}},
+		{"build", "publish", []string{
+			"Job publish of group build was aborted without starting, its dependencies failed:",
+			"  - wf-build-package (failed)",
+			"The failure started at:",
+			"  - job compile of group build failed",
+		}},
+		{"deploy", "rollout", []string{
+			"Job rollout of group deploy is pending.",
+			"Its group deploy was aborted, the groups it depends on failed:",
+			"  - build (failed)",
+			"The failure started at:",
+			"  - job compile of group build failed",
+		}},
+		{"verify", "smoke", []string{
+			"Job smoke of group verify is pending.",
+			"Its group verify waits for the approval, run `kubectl managedjob approve wf verify`.",
+		}},
+		{"report", "send", []string{
+			"Job send of group report is pending.",
+			"It waits for:",
+			"  - wf-report-collect (running)",
+		}},
+		{"export", "dump", []string{
+			"Job dump of group export failed.",
+			"It ran longer than its timeoutSeconds 900.",
+			"Check the logs of wf-export-dump.",
+		}},
+	}
+	for _, tt := range tests {
+		lines, err := ExplainJob(mj, tt.group, tt.job)
+		if err != nil {
+			t.Fatalf("%s/%s: unexpected error: %v", tt.group, tt.job, err)
+		}
+		if !reflect.DeepEqual(lines, tt.expected) {
+			t.Errorf("%s/%s explained as\n%q\nexpected\n%q", tt.group, tt.job, lines, tt.expected)
+		}
+	}
+	if _, err := ExplainJob(mj, "build", "missing"); err == nil {
+		t.Error("expected the missing job to be reported")
+	}
+}
@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"k8s.io/apimachinery/pkg/util/intstr"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+/* Failure budget - best-effort groups succeed as long as the failed jobs stay within group.failurePolicy.maxFailed */
+
+const GroupReasonFailureBudget = "WithinFailureBudget"
+
+// failureBudget returns the number of jobs which may fail with the group still succeeding, percentages are rounded down
+func failureBudget(group *jobsmanagerv1beta1.ManagedJobGroup) int {
+	if group.FailurePolicy == nil {
+		return 0
+	}
+	budget, err := intstr.GetScaledValueFromIntOrPercent(&group.FailurePolicy.MaxFailed, len(group.Jobs), false)
+	if err != nil || budget < 0 {
+		return 0
+	}
+	return budget
+}
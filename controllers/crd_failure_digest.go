@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"fmt"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+/*
+Failure digest - the failed and aborted jobs of the failed run with their reasons, in a single line which
+fits an event and an alert. It's kept in status.failureDigest, sent with the status notifications and emitted
+as the FailureDigest event once the workflow fails, capped at failureDigestLimit characters.
+*/
+
+const (
+	// ReasonDependencyFailed is set on the jobs aborted because a job they depend on failed
+	ReasonDependencyFailed = "DependencyFailed"
+	// ReasonCreateFailed is set on the jobs which Job could not be created
+	ReasonCreateFailed = "CreateFailed"
+	// ReasonJobFailed is set on the failed jobs which Job has no failure condition, e.g. the fan-out ones
+	ReasonJobFailed = "JobFailed"
+
+	failureDigestLimit = 1024
+)
+
+// jobFailureReason returns the reason of the Failed condition of the Job, e.g. BackoffLimitExceeded
+func jobFailureReason(childJob *kbatch.Job) string {
+	for _, condition := range childJob.Status.Conditions {
+		if condition.Type == kbatch.JobFailed && condition.Status == corev1.ConditionTrue && condition.Reason != "" {
+			return condition.Reason
+		}
+	}
+	return ReasonJobFailed
+}
+
+// failureDigest lists the failed and aborted jobs with their reasons, e.g.
+// "load/extract failed: BackoffLimitExceeded, load/transform aborted: DependencyFailed", the jobs beyond
+// the limit are counted at the end
+func failureDigest(spec *jobsmanagerv1beta1.ManagedJobSpec) string {
+	entries := []string{}
+	for _, group := range spec.Groups {
+		for _, job := range group.Jobs {
+			if job.Status != ExecutionStatusFailed && job.Status != ExecutionStatusAborted {
+				continue
+			}
+			entry := fmt.Sprintf("%s/%s %s", group.Name, job.Name, job.Status)
+			if job.Reason != "" {
+				entry += ": " + job.Reason
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	digest := ""
+	for i, entry := range entries {
+		more := ""
+		if remaining := len(entries) - i - 1; remaining > 0 {
+			more = fmt.Sprintf(", and %d more", remaining)
+		}
+		next := entry
+		if digest != "" {
+			next = digest + ", " + entry
+		}
+		if len(next)+len(more) > failureDigestLimit {
+			if digest == "" {
+				return fmt.Sprintf("%d failed and aborted jobs", len(entries))
+			}
+			return digest + fmt.Sprintf(", and %d more", len(entries)-i)
+		}
+		digest = next
+	}
+	return digest
+}
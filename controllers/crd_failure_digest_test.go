@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestFailureDigest(t *testing.T) {
+	spec := &jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+		{Name: "extract", Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "a", Status: ExecutionStatusSucceeded}}},
+		{Name: "load", Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+			{Name: "a", Status: ExecutionStatusFailed, Reason: "BackoffLimitExceeded"},
+			{Name: "b", Status: ExecutionStatusAborted, Reason: ReasonDependencyFailed},
+			{Name: "c", Status: ExecutionStatusFailed},
+		}},
+	}}
+	if digest := failureDigest(spec); digest != "load/a failed: BackoffLimitExceeded, load/b aborted: DependencyFailed, load/c failed" {
+		t.Errorf("unexpected digest %q", digest)
+	}
+
+	wide := &jobsmanagerv1beta1.ManagedJobGroup{Name: "fan"}
+	for i := 0; i < 100; i++ {
+		wide.Jobs = append(wide.Jobs, &jobsmanagerv1beta1.ManagedJobDefinition{Name: fmt.Sprintf("shard-%02d", i), Status: ExecutionStatusFailed, Reason: "DeadlineExceeded"})
+	}
+	digest := failureDigest(&jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{wide}})
+	if len(digest) > failureDigestLimit || !strings.HasPrefix(digest, "fan/shard-00 failed: DeadlineExceeded") || !strings.Contains(digest, " more") {
+		t.Errorf("digest not capped: %d characters, %q", len(digest), digest)
+	}
+
+	if digest := failureDigest(&jobsmanagerv1beta1.ManagedJobSpec{}); digest != "" {
+		t.Errorf("digest of the workflow without failures: %q", digest)
+	}
+}
+
+func TestJobFailureReason(t *testing.T) {
+	childJob := &kbatch.Job{Status: kbatch.JobStatus{Conditions: []kbatch.JobCondition{
+		{Type: kbatch.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded"},
+	}}}
+	if reason := jobFailureReason(childJob); reason != "BackoffLimitExceeded" {
+		t.Errorf("unexpected reason %q", reason)
+	}
+	if reason := jobFailureReason(&kbatch.Job{}); reason != ReasonJobFailed {
+		t.Errorf("unexpected reason of the Job without conditions %q", reason)
+	}
+}
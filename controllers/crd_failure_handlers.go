@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+/*
+Failure handlers - the groups and jobs with a dependency of the Failed condition run only when the dependency
+fails, e.g. to roll back or to collect the diagnostics. They are left out of the outcome of the run: the
+handler failing does not fail its group or the workflow, the run is failed by the failure it handles anyway.
+The containers of the handlers get the failed dependencies in FAILED_DEPENDENCIES.
+*/
+
+const envFailedDependencies = "FAILED_DEPENDENCIES"
+
+// failureHandler tells if the dependencies make the group or job run on the failure of another one
+func failureHandler(dependencies []*jobsmanagerv1beta1.ManagedJobDependencies) bool {
+	for _, dependency := range dependencies {
+		if dependency.DependencyCondition() == jobsmanagerv1beta1.DependencyConditionFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// failedHandlerDependencies returns the failed dependencies handled by the job or its group, comma separated
+func failedHandlerDependencies(g *jobsmanagerv1beta1.ManagedJobGroup, j *jobsmanagerv1beta1.ManagedJobDefinition) string {
+	failed := []string{}
+	for _, dependencies := range [][]*jobsmanagerv1beta1.ManagedJobDependencies{g.Dependencies, j.Dependencies} {
+		for _, dependency := range dependencies {
+			if dependency.DependencyCondition() == jobsmanagerv1beta1.DependencyConditionFailed && dependency.Status == ExecutionStatusFailed {
+				failed = append(failed, dependency.Name)
+			}
+		}
+	}
+	return strings.Join(failed, ",")
+}
+
+// failureHandlerEnv adds the failed dependencies to the environment of the handler
+func failureHandlerEnv(env []corev1.EnvVar, g *jobsmanagerv1beta1.ManagedJobGroup, j *jobsmanagerv1beta1.ManagedJobDefinition) []corev1.EnvVar {
+	if !failureHandler(g.Dependencies) && !failureHandler(j.Dependencies) {
+		return env
+	}
+	return append(env, corev1.EnvVar{Name: envFailedDependencies, Value: failedHandlerDependencies(g, j)})
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestFailureHandlersLeftOutOfTheOutcome(t *testing.T) {
+	onFailure := func(name string) []*jobsmanagerv1beta1.ManagedJobDependencies {
+		return []*jobsmanagerv1beta1.ManagedJobDependencies{{Name: name, Condition: jobsmanagerv1beta1.DependencyConditionFailed, Status: ExecutionStatusPending}}
+	}
+	spec := &jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+		{Name: "deploy", Status: ExecutionStatusRunning, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+			{Name: "apply", Status: ExecutionStatusSucceeded},
+			{Name: "rollback", Status: ExecutionStatusSkipped, Reason: ReasonConditionNotMet, Dependencies: onFailure("wf-deploy-apply")},
+		}},
+		{Name: "diagnostics", Status: ExecutionStatusRunning, Dependencies: onFailure("deploy"), Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+			{Name: "collect", Status: ExecutionStatusFailed},
+		}},
+	}}
+	propagateStatuses("wf", spec, nil)
+	if spec.Groups[0].Status != ExecutionStatusSucceeded {
+		t.Errorf("status of the group with the skipped handler = %s", spec.Groups[0].Status)
+	}
+	// the diagnostics of a succeeded group are never collected, their failure doesn't fail the run either
+	if status := workflowStatus(spec); status != ExecutionStatusSucceeded {
+		t.Errorf("workflow status with the failed handler group = %s", status)
+	}
+
+	spec.Groups[0].Jobs[0].Status = ExecutionStatusFailed
+	spec.Groups[0].Jobs[1].Status = ExecutionStatusFailed
+	spec.Groups[0].Status = ExecutionStatusRunning
+	propagateStatuses("wf", spec, nil)
+	if spec.Groups[0].Status != ExecutionStatusFailed || spec.Groups[0].Reason == GroupReasonFailureBudget {
+		t.Errorf("status of the group with the failed job = %s (%s)", spec.Groups[0].Status, spec.Groups[0].Reason)
+	}
+	if status := workflowStatus(spec); status != ExecutionStatusFailed {
+		t.Errorf("workflow status with the failed job = %s", status)
+	}
+}
+
+func TestFailureHandlerEnv(t *testing.T) {
+	cp := &connPackage{r: &ManagedJobReconciler{}, mj: &jobsmanagerv1beta1.ManagedJob{}}
+	cp.mj.Name, cp.mj.Namespace = "release", "apps"
+	g := &jobsmanagerv1beta1.ManagedJobGroup{Name: "rollback", Dependencies: []*jobsmanagerv1beta1.ManagedJobDependencies{
+		{Name: "deploy", Condition: jobsmanagerv1beta1.DependencyConditionFailed, Status: ExecutionStatusFailed},
+		{Name: "build", Status: ExecutionStatusSucceeded},
+	}}
+	j := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "undo", Image: "kubectl"}
+
+	env := cp.buildJob(j, g).Spec.Template.Spec.Containers[0].Env
+	if !reflect.DeepEqual(env, []corev1.EnvVar{{Name: envFailedDependencies, Value: "deploy"}}) {
+		t.Errorf("env of the handler = %+v", env)
+	}
+	g.Dependencies = g.Dependencies[1:]
+	if env := cp.buildJob(j, g).Spec.Template.Spec.Containers[0].Env; len(env) != 0 {
+		t.Errorf("unexpected env of the regular job: %+v", env)
+	}
+}
@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"fmt"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+/* Fan-out jobs - run as indexed Jobs */
+
+// applyFanOut turns the Job into an indexed one according to the fan-out settings
+func applyFanOut(j *jobsmanagerv1beta1.ManagedJobDefinition, job *kbatch.Job) {
+	if j.FanOut == nil {
+		return
+	}
+	indexed := kbatch.IndexedCompletion
+	completions := j.FanOut.Completions
+	job.Spec.CompletionMode = &indexed
+	job.Spec.Completions = &completions
+	job.Spec.Parallelism = j.FanOut.Parallelism
+	if j.FanOut.BackoffLimitPerIndex != nil {
+		// per index limits replace the job wide one and are only allowed for pods which are never restarted in place
+		job.Spec.BackoffLimit = nil
+		job.Spec.BackoffLimitPerIndex = j.FanOut.BackoffLimitPerIndex
+		job.Spec.MaxFailedIndexes = j.FanOut.MaxFailedIndexes
+		job.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyNever
+	}
+}
+
+// fanOutStatus maps the indexed Job onto the job status, only the Job conditions mark the terminal state
+// as a single succeeded or failed index does not finish the whole fan-out
+func fanOutStatus(j *jobsmanagerv1beta1.ManagedJobDefinition, childJob *kbatch.Job) string {
+	summary := fmt.Sprintf("%d/%d succeeded", childJob.Status.Succeeded, j.FanOut.Completions)
+	if childJob.Status.FailedIndexes != nil && *childJob.Status.FailedIndexes != "" {
+		summary += ", failed: " + *childJob.Status.FailedIndexes
+	}
+	j.FanOutSummary = summary
+
+	for _, condition := range childJob.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case kbatch.JobComplete:
+			return ExecutionStatusSucceeded
+		case kbatch.JobFailed:
+			return ExecutionStatusFailed
+		}
+	}
+	if childJob.Status.Active > 0 {
+		return ExecutionStatusRunning
+	}
+	return j.Status
+}
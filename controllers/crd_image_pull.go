@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+/*
+Image pull credentials refresh - pods of the running jobs which can not pull their image because the
+registry denied the credentials, e.g. an expired ECR token, trigger the refresh hook: a run of the
+refresher CronJob, a POST to the webhook or both. The pods created before the refresh are recreated
+once RetryAfter passes, so the step is retried with the new credentials instead of backing off forever.
+The refresh is triggered once per job run, and at most once per RetryAfter for the whole operator.
+*/
+
+const defaultImagePullRetryAfter = time.Minute
+
+// imagePullDeniedMessages are the registry responses to the missing or expired credentials
+var imagePullDeniedMessages = []string{
+	"unauthorized",
+	"authentication required",
+	"no basic auth credentials",
+	"denied",
+	"403 forbidden",
+}
+
+// ImagePullRefresh refreshes the registry credentials when the image pulls of the jobs are denied
+type ImagePullRefresh struct {
+	// CronJob is run once as a Job, like `kubectl create job --from=cronjob/<name>`
+	CronJob types.NamespacedName
+	// WebhookURL receives a POST with the details of the denied pull
+	WebhookURL string
+	// RetryAfter is how long the refresh has to complete before the stuck pods are recreated
+	RetryAfter time.Duration
+
+	mtx         sync.Mutex
+	lastRefresh time.Time
+}
+
+// imagePullRefreshRequest is the body of the webhook call
+type imagePullRefreshRequest struct {
+	Namespace string `json:"namespace"`
+	Workflow  string `json:"workflow"`
+	Group     string `json:"group"`
+	Job       string `json:"job"`
+	Pod       string `json:"pod"`
+	Image     string `json:"image"`
+	Message   string `json:"message"`
+}
+
+func (refresh *ImagePullRefresh) retryAfter() time.Duration {
+	if refresh.RetryAfter <= 0 {
+		return defaultImagePullRetryAfter
+	}
+	return refresh.RetryAfter
+}
+
+// imagePullDenied returns the image and the message of the container which pull was denied by the registry
+func imagePullDenied(pod *corev1.Pod) (string, string, bool) {
+	for _, status := range append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+		waiting := status.State.Waiting
+		if waiting == nil || (waiting.Reason != "ErrImagePull" && waiting.Reason != "ImagePullBackOff") {
+			continue
+		}
+		message := strings.ToLower(waiting.Message)
+		for _, denied := range imagePullDeniedMessages {
+			if strings.Contains(message, denied) {
+				return status.Image, waiting.Message, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// trigger runs the refresh hooks, unless they already ran within RetryAfter for another job
+func (refresh *ImagePullRefresh) trigger(ctx context.Context, c client.Client, request imagePullRefreshRequest, now time.Time) (bool, error) {
+	refresh.mtx.Lock()
+	defer refresh.mtx.Unlock()
+	if now.Sub(refresh.lastRefresh) < refresh.retryAfter() {
+		return false, nil
+	}
+	refresh.lastRefresh = now
+
+	if refresh.CronJob.Name != "" {
+		if err := runCronJob(ctx, c, refresh.CronJob, now); err != nil {
+			return true, err
+		}
+	}
+	if refresh.WebhookURL != "" {
+		if err := callRefreshWebhook(ctx, refresh.WebhookURL, request); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// runCronJob creates a Job from the template of the CronJob
+func runCronJob(ctx context.Context, c client.Client, name types.NamespacedName, now time.Time) error {
+	cronJob := &kbatch.CronJob{}
+	if err := c.Get(ctx, name, cronJob); err != nil {
+		return err
+	}
+	job := &kbatch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("%s-refresh-%d", cronJob.Name, now.Unix()),
+			Namespace:   cronJob.Namespace,
+			Labels:      cronJob.Spec.JobTemplate.Labels,
+			Annotations: map[string]string{"cronjob.kubernetes.io/instantiate": "manual"},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cronJob, kbatch.SchemeGroupVersion.WithKind("CronJob")),
+			},
+		},
+		Spec: *cronJob.Spec.JobTemplate.Spec.DeepCopy(),
+	}
+	return c.Create(ctx, job)
+}
+
+func callRefreshWebhook(ctx context.Context, webhookURL string, request imagePullRefreshRequest) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("refresh webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// checkImagePulls refreshes the credentials for the running jobs stuck on the denied image pulls
+// and recreates their pods once the refresh had the time to complete
+func (cp *connPackage) checkImagePulls() {
+	refresh := cp.r.ImagePullRefresh
+	if refresh == nil {
+		return
+	}
+	jobs := map[string]*jobsmanagerv1beta1.ManagedJobDefinition{}
+	groups := map[string]string{}
+	for _, group := range cp.mj.Spec.Groups {
+		for _, job := range group.Jobs {
+			if job.Status == ExecutionStatusRunning {
+				generatedJobName := jobNameGenerator(cp.mj.Name, group.Name, job.Name)
+				jobs[generatedJobName] = job
+				groups[generatedJobName] = group.Name
+			}
+		}
+	}
+	if len(jobs) == 0 {
+		return
+	}
+
+	var pods corev1.PodList
+	labelSelector := labels.SelectorFromSet(labels.Set{labelWorkflowName: cp.mj.Name})
+	if err := cp.client.List(cp.ctx, &pods, &client.ListOptions{LabelSelector: labelSelector, Namespace: cp.mj.Namespace}); err != nil {
+		log.Log.Info("Unable to list workflow pods", "error", err.Error())
+		return
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		job, found := jobs[pod.Labels[labelJobName]]
+		if !found || pod.Status.Phase != corev1.PodPending {
+			continue
+		}
+		image, message, denied := imagePullDenied(pod)
+		if !denied {
+			continue
+		}
+		groupName := groups[pod.Labels[labelJobName]]
+
+		if job.ImagePullRefreshedAt == nil {
+			now := metav1.NewTime(cp.now())
+			job.ImagePullRefreshedAt = &now
+			triggered, err := refresh.trigger(cp.ctx, cp.client, imagePullRefreshRequest{
+				Namespace: cp.mj.Namespace, Workflow: cp.mj.Name, Group: groupName, Job: job.Name, Pod: pod.Name, Image: image, Message: message,
+			}, now.Time)
+			switch {
+			case err != nil:
+				cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "ImagePullRefresh", "Unable to refresh the credentials to pull %s for job %s: %s", image, job.Name, err.Error())
+			case triggered:
+				cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "ImagePullRefresh", "Pull of %s denied for job %s, refreshing the credentials", image, job.Name)
+			}
+			cp.requeueIn(refresh.retryAfter())
+			continue
+		}
+
+		// pods created after the refresh pulled with the new credentials already, retrying them would loop
+		if !pod.CreationTimestamp.Before(job.ImagePullRefreshedAt) {
+			continue
+		}
+		if remaining := job.ImagePullRefreshedAt.Add(refresh.retryAfter()).Sub(cp.now()); remaining > 0 {
+			cp.requeueIn(remaining)
+			continue
+		}
+		if err := cp.client.Delete(cp.ctx, pod); client.IgnoreNotFound(err) != nil {
+			log.Log.Info("Unable to delete the pod stuck on the image pull", "pod", pod.Name, "error", err.Error())
+			continue
+		}
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "ImagePullRetry", "Recreating pod %s of job %s with the refreshed credentials", pod.Name, job.Name)
+	}
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func deniedPod(name string, created time.Time, message string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "etl",
+			CreationTimestamp: metav1.NewTime(created),
+			Labels:            map[string]string{labelWorkflowName: "nightly", labelJobName: "nightly-extract-download"},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Image: "123.dkr.ecr.eu-west-1.amazonaws.com/etl:1.0",
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ErrImagePull", Message: message}},
+			}},
+		},
+	}
+}
+
+func TestImagePullDenied(t *testing.T) {
+	tests := []struct {
+		message  string
+		expected bool
+	}{
+		{"failed to authorize: failed to fetch oauth token: unexpected status: 401 Unauthorized", true},
+		{"pull access denied for etl, repository does not exist or may require 'docker login'", true},
+		{"no basic auth credentials", true},
+		{"manifest for etl:1.0 not found: manifest unknown", false},
+	}
+	for _, tt := range tests {
+		if _, _, denied := imagePullDenied(deniedPod("pod", time.Now(), tt.message)); denied != tt.expected {
+			t.Errorf("%q: expected denied %v", tt.message, tt.expected)
+		}
+	}
+}
+
+func TestImagePullRefreshWebhook(t *testing.T) {
+	calls := []imagePullRefreshRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := imagePullRefreshRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		calls = append(calls, request)
+	}))
+	defer server.Close()
+
+	refresh := &ImagePullRefresh{WebhookURL: server.URL, RetryAfter: time.Hour}
+	for _, job := range []string{"download", "parse"} {
+		if _, err := refresh.trigger(context.Background(), nil, imagePullRefreshRequest{Workflow: "nightly", Job: job}, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(calls) != 1 || calls[0].Job != "download" {
+		t.Errorf("expected a single refresh for the first job within RetryAfter, got %v", calls)
+	}
+}
+
+func TestCheckImagePulls(t *testing.T) {
+	cronJob := &kbatch.CronJob{ObjectMeta: metav1.ObjectMeta{Name: "ecr-refresher", Namespace: "infra"}}
+	stuck := deniedPod("stuck", time.Now().Add(-time.Hour), "401 Unauthorized")
+
+	job := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "download", Status: ExecutionStatusRunning}
+	cp := newTestConnPackage(&ManagedJobReconciler{
+		ImagePullRefresh: &ImagePullRefresh{CronJob: types.NamespacedName{Namespace: "infra", Name: "ecr-refresher"}, RetryAfter: time.Minute},
+	}, &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "etl"},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{
+			Name: "extract", Status: ExecutionStatusRunning, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{job},
+		}}},
+	}, cronJob, stuck)
+	c := cp.client
+
+	cp.checkImagePulls()
+	if job.ImagePullRefreshedAt == nil {
+		t.Fatal("expected the refresh recorded on the job")
+	}
+	var refreshJobs kbatch.JobList
+	if err := c.List(cp.ctx, &refreshJobs, client.InNamespace("infra")); err != nil || len(refreshJobs.Items) != 1 {
+		t.Fatalf("expected the refresher CronJob run once, got %d Jobs (%v)", len(refreshJobs.Items), err)
+	}
+	if cp.requeueAfter != time.Minute {
+		t.Errorf("expected the retry in a minute, got %s", cp.requeueAfter)
+	}
+
+	// the refresh had its time, the pod created before it is recreated
+	refreshedAt := metav1.NewTime(time.Now().Add(-2 * time.Minute))
+	job.ImagePullRefreshedAt = &refreshedAt
+	cp.checkImagePulls()
+	if err := c.Get(cp.ctx, client.ObjectKeyFromObject(stuck), &corev1.Pod{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the stuck pod deleted, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+/*
+Impersonation - the jobs of the group with impersonate are created by the operator impersonating the creator
+of the workflow, recorded by the mutating webhook, or the ServiceAccount of the workflow namespace. The API
+server checks the RBAC of the impersonated user, so the workflow can create only the Jobs its owner could
+create. Sub-workflows impersonate the creator of the root workflow running them. The job which can't be
+created as the impersonated user fails with the CreateFailed reason. The operator needs the webhooks, which
+keep the clients from naming another creator, and the impersonate permission of the opt-in
+manager-impersonator-role, it's not part of the manager role.
+*/
+
+// JobClients returns the clients creating the Jobs as the impersonated users
+type JobClients interface {
+	ClientFor(user rest.ImpersonationConfig) (client.Client, error)
+}
+
+// ImpersonatingClients builds the impersonating clients from the config of the operator, one per user
+type ImpersonatingClients struct {
+	Config *rest.Config
+	Scheme *runtime.Scheme
+	Mapper meta.RESTMapper
+
+	mtx     sync.Mutex
+	clients map[string]client.Client
+}
+
+func (c *ImpersonatingClients) ClientFor(user rest.ImpersonationConfig) (client.Client, error) {
+	key := user.UserName + "\n" + strings.Join(user.Groups, ",")
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if existing, found := c.clients[key]; found {
+		return existing, nil
+	}
+	config := rest.CopyConfig(c.Config)
+	config.Impersonate = user
+	impersonating, err := client.New(config, client.Options{Scheme: c.Scheme, Mapper: c.Mapper})
+	if err != nil {
+		return nil, err
+	}
+	if c.clients == nil {
+		c.clients = map[string]client.Client{}
+	}
+	c.clients[key] = impersonating
+	return impersonating, nil
+}
+
+// jobClient returns the client creating the jobs of the group, the operator's own one unless the group
+// impersonates a user
+func (cp *connPackage) jobClient(g *jobsmanagerv1beta1.ManagedJobGroup) (client.Client, error) {
+	if g.Impersonate == nil {
+		return cp.client, nil
+	}
+	if cp.r.JobClients == nil {
+		return nil, fmt.Errorf("group %s impersonates the jobs creator which is not enabled for the operator", g.Name)
+	}
+	user, err := cp.impersonatedUser(g.Impersonate)
+	if err != nil {
+		return nil, err
+	}
+	return cp.r.JobClients.ClientFor(user)
+}
+
+// impersonatedUser is the ServiceAccount of the workflow namespace or the creator of the root workflow
+func (cp *connPackage) impersonatedUser(impersonate *jobsmanagerv1beta1.ManagedJobImpersonation) (rest.ImpersonationConfig, error) {
+	if impersonate.ServiceAccount != "" {
+		// the API server adds the groups of the ServiceAccount, impersonating it needs no groups
+		return rest.ImpersonationConfig{
+			UserName: fmt.Sprintf("system:serviceaccount:%s:%s", cp.mj.Namespace, impersonate.ServiceAccount),
+		}, nil
+	}
+	workflow, err := cp.rootWorkflow()
+	if err != nil {
+		return rest.ImpersonationConfig{}, err
+	}
+	creator, recorded := workflow.Creator()
+	if !recorded {
+		return rest.ImpersonationConfig{}, fmt.Errorf("creator of the workflow %s is not recorded, it has to be created through the webhook", workflow.Name)
+	}
+	return rest.ImpersonationConfig{UserName: creator.Username, Groups: creator.Groups}, nil
+}
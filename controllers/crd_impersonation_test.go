@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	kbatch "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// recordingJobClients hands out the same client and records the impersonated users
+type recordingJobClients struct {
+	client client.Client
+	users  []rest.ImpersonationConfig
+}
+
+func (c *recordingJobClients) ClientFor(user rest.ImpersonationConfig) (client.Client, error) {
+	c.users = append(c.users, user)
+	return c.client, nil
+}
+
+func TestImpersonatedJobCreation(t *testing.T) {
+	newWorkflow := func(annotations map[string]string, impersonate *jobsmanagerv1beta1.ManagedJobImpersonation) (*jobsmanagerv1beta1.ManagedJob, *jobsmanagerv1beta1.ManagedJobDefinition) {
+		job := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "build", Image: "golang:1.21", Status: ExecutionStatusPending}
+		return &jobsmanagerv1beta1.ManagedJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "release", Namespace: "apps", Annotations: annotations},
+			Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{
+				Name: "images", Status: ExecutionStatusPending, Impersonate: impersonate, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{job},
+			}}},
+		}, job
+	}
+	run := func(mj *jobsmanagerv1beta1.ManagedJob, jobClients JobClients) client.Client {
+		c := newTestClientBuilder(mj).Build()
+		if recording, ok := jobClients.(*recordingJobClients); ok {
+			recording.client = c
+		}
+		cp := newTestConnPackageWithClient(&ManagedJobReconciler{Recorder: record.NewFakeRecorder(10), JobClients: jobClients}, mj, c)
+		cp.runPendingJobs()
+		return c
+	}
+
+	creators := map[string]string{jobsmanagerv1beta1.CreatedByAnnotation: "bob", jobsmanagerv1beta1.CreatedByGroupsAnnotation: "developers"}
+	mj, job := newWorkflow(creators, &jobsmanagerv1beta1.ManagedJobImpersonation{})
+	jobClients := &recordingJobClients{}
+	run(mj, jobClients)
+	if job.Status != ExecutionStatusRunning || len(jobClients.users) != 1 || jobClients.users[0].UserName != "bob" || len(jobClients.users[0].Groups) != 1 || jobClients.users[0].Groups[0] != "developers" {
+		t.Errorf("expected the job created as the creator, got %s as %+v", job.Status, jobClients.users)
+	}
+
+	mj, job = newWorkflow(nil, &jobsmanagerv1beta1.ManagedJobImpersonation{ServiceAccount: "builder"})
+	jobClients = &recordingJobClients{}
+	run(mj, jobClients)
+	if job.Status != ExecutionStatusRunning || len(jobClients.users) != 1 || jobClients.users[0].UserName != "system:serviceaccount:apps:builder" {
+		t.Errorf("expected the job created as the ServiceAccount, got %s as %+v", job.Status, jobClients.users)
+	}
+
+	// without the recorded creator or the impersonation enabled the jobs fail, none is created as the operator
+	for name, clients := range map[string]JobClients{"creator not recorded": &recordingJobClients{}, "impersonation disabled": nil} {
+		mj, job = newWorkflow(nil, &jobsmanagerv1beta1.ManagedJobImpersonation{})
+		c := run(mj, clients)
+		jobs := &kbatch.JobList{}
+		_ = c.List(context.Background(), jobs)
+		if job.Status != ExecutionStatusFailed || job.Reason != ReasonCreateFailed || len(jobs.Items) != 0 {
+			t.Errorf("%s: expected the job failed, got %s with %d Jobs", name, job.Status, len(jobs.Items))
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/pkg/dependencies"
+)
+
+/* Invalid workflows - problems found only once the workflow was compiled stop it with the exact reason */
+
+// runtimeProblems lists what keeps the compiled workflow from ever completing, the webhook may be disabled
+// and the compiled params or the generated names are not known to it
+func (cp *connPackage) runtimeProblems() field.ErrorList {
+	errs := field.ErrorList{}
+	known := map[string]bool{}
+	for _, group := range cp.mj.Spec.Groups {
+		known[group.Name] = true
+		for _, job := range group.Jobs {
+			known[jobNameGenerator(cp.mj.Name, group.Name, job.Name)] = true
+		}
+	}
+	unknownDependencies := func(path *field.Path, dependencies []*jobsmanagerv1beta1.ManagedJobDependencies) {
+		for i, dependency := range dependencies {
+			if dependency != nil && !known[dependency.Name] {
+				errs = append(errs, field.NotFound(path.Index(i).Child("name"), dependency.Name))
+			}
+		}
+	}
+
+	for i, group := range cp.mj.Spec.Groups {
+		groupPath := field.NewPath("spec", "groups").Index(i)
+		unknownDependencies(groupPath.Child("dependencies"), group.Dependencies)
+		for j, job := range group.Jobs {
+			jobPath := groupPath.Child("jobs").Index(j)
+			unknownDependencies(jobPath.Child("dependencies"), job.Dependencies)
+			if params, err := cp.jobParameters(group, job); err == nil {
+				errs = append(errs, jobsmanagerv1beta1.ValidateParameters(params, jobPath.Child("compiledParams"))...)
+			}
+			if cp.runsContainer(job) && cp.mj.Spec.JobImage(group, job) == "" {
+				errs = append(errs, field.Required(jobPath.Child("image"), "image has to be set on the job, its group or the workflow"))
+			}
+			generatedJobName := jobNameGenerator(cp.mj.Name, group.Name, job.Name)
+			for _, msg := range validation.IsDNS1123Label(generatedJobName) {
+				errs = append(errs, field.Invalid(jobPath.Child("name"), job.Name, "generated job name "+generatedJobName+": "+msg))
+			}
+		}
+	}
+	return errs
+}
+
+// runsContainer tells if the job runs the image in the Job built by the controller
+func (cp *connPackage) runsContainer(job *jobsmanagerv1beta1.ManagedJobDefinition) bool {
+	if _, custom := cp.r.Executors[job.Type]; custom {
+		return false
+	}
+	return job.Type == "" || job.Type == JobTypeContainer || job.Type == JobTypeScript
+}
+
+// checkValidity marks the workflow invalid with the problems found, nothing runs until it's fixed.
+// It reports if the workflow may run.
+func (cp *connPackage) checkValidity() bool {
+	problems, reason := cp.runtimeProblems(), ReasonRuntimeValidationFailed
+	// cycles through the unknown dependencies are not reported twice
+	if len(problems) == 0 {
+		problems, reason = dependencies.Cycles(cp.mj.Name, &cp.mj.Spec), ReasonDependencyCycle
+	}
+	invalid := len(problems) > 0
+	condition := metav1.Condition{
+		Type:               ConditionInvalidSpec,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonValid,
+		ObservedGeneration: cp.mj.Generation,
+	}
+	if invalid {
+		messages := []string{}
+		for _, problem := range problems {
+			messages = append(messages, problem.Error())
+		}
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = reason
+		condition.Message = strings.Join(messages, "; ")
+	}
+
+	existing := meta.FindStatusCondition(cp.mj.Spec.Conditions, ConditionInvalidSpec)
+	if invalid && (existing == nil || existing.Status != condition.Status || existing.Message != condition.Message) {
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "Invalid", "Workflow can not run: %s", condition.Message)
+	}
+	meta.SetStatusCondition(&cp.mj.Spec.Conditions, condition)
+	if invalid {
+		cp.mj.Status.Phase = ExecutionStatusInvalid
+		cp.setRunConditions()
+	}
+	return !invalid
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestCheckValidity(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	extract := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "extract", Image: "busybox"}
+	load := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "load", Dependencies: []*jobsmanagerv1beta1.ManagedJobDependencies{{Name: "nightly-etl-transform"}}}
+	cp := &connPackage{
+		r: &ManagedJobReconciler{Recorder: recorder},
+		mj: &jobsmanagerv1beta1.ManagedJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "etl"},
+			Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{
+				Name: "etl", Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{extract, load},
+			}}},
+		},
+	}
+
+	if cp.checkValidity() {
+		t.Fatal("expected the workflow with the unknown dependency and no image invalid")
+	}
+	condition := meta.FindStatusCondition(cp.mj.Spec.Conditions, ConditionInvalidSpec)
+	if cp.mj.Status.Phase != ExecutionStatusInvalid || condition == nil || condition.Status != metav1.ConditionTrue {
+		t.Fatalf("expected the invalid status and condition, got %s %+v", cp.mj.Status.Phase, condition)
+	}
+	for _, problem := range []string{"spec.groups[0].jobs[1].dependencies[0].name: Not found: \"nightly-etl-transform\"", "spec.groups[0].jobs[1].image: Required value"} {
+		if !strings.Contains(condition.Message, problem) {
+			t.Errorf("condition message %q does not mention %s", condition.Message, problem)
+		}
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected a single event, got %d", len(recorder.Events))
+	}
+	// the same problems are not reported again
+	cp.checkValidity()
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected no new event for the same problems, got %d", len(recorder.Events))
+	}
+
+	load.Image = "busybox"
+	load.Dependencies[0].Name = "nightly-etl-extract"
+	if !cp.checkValidity() {
+		t.Fatalf("expected the fixed workflow valid, got %+v", cp.mj.Spec.Conditions)
+	}
+	if condition := meta.FindStatusCondition(cp.mj.Spec.Conditions, ConditionInvalidSpec); condition.Status != metav1.ConditionFalse || condition.Reason != ReasonValid {
+		t.Errorf("expected the condition cleared, got %+v", cp.mj.Spec.Conditions)
+	}
+
+	// cycles only show up once the implicit dependencies were added
+	extract.Dependencies = []*jobsmanagerv1beta1.ManagedJobDependencies{{Name: "nightly-etl-load"}}
+	if cp.checkValidity() {
+		t.Error("expected the dependency cycle reported")
+	}
+	if condition := meta.FindStatusCondition(cp.mj.Spec.Conditions, ConditionInvalidSpec); condition.Reason != ReasonDependencyCycle || meta.IsStatusConditionTrue(cp.mj.Spec.Conditions, ConditionProgressing) {
+		t.Errorf("expected the cycle stopping the workflow, got %+v", cp.mj.Spec.Conditions)
+	}
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"strconv"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+/*
+Identity of the created Jobs - every Job carries the UID of its workflow and the attempt of the job it was
+created for, next to the workflow generation label. The attempt grows with every Job created for the job,
+over the runs too, so the Job left over from a previous run or generation never matches the current one
+and its status is not synced. When the Job was created but the workflow update recording it was lost,
+the next creation finds the Job of the same attempt and resumes it instead of failing on the conflict.
+*/
+
+const (
+	annotationWorkflowUID = "jobsmanager.raczylo.com/workflow-uid"
+	annotationJobAttempt  = "jobsmanager.raczylo.com/attempt"
+)
+
+// jobIdentity returns the annotations identifying the Job of the attempt of the job
+func (cp *connPackage) jobIdentity(attempt int32) map[string]string {
+	return map[string]string{
+		annotationWorkflowUID: string(cp.mj.UID),
+		annotationJobAttempt:  strconv.FormatInt(int64(attempt), 10),
+	}
+}
+
+// sameIdentity tells if the Job was created for the workflow and the attempt
+func (cp *connPackage) sameIdentity(childJob *kbatch.Job, attempt int32) bool {
+	for k, v := range cp.jobIdentity(attempt) {
+		if childJob.Annotations[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// staleJob tells if the Job was not created for the current attempt of the job, the Jobs created before
+// the identity annotations are told by their creation time
+func (cp *connPackage) staleJob(j *jobsmanagerv1beta1.ManagedJobDefinition, childJob *kbatch.Job) bool {
+	if _, identified := childJob.Annotations[annotationWorkflowUID]; !identified {
+		runStartedAt := cp.currentRunStartedAt()
+		return childJob.CreationTimestamp.Before(&runStartedAt)
+	}
+	return !cp.sameIdentity(childJob, j.Attempt)
+}
+
+// resumeJob adopts the existing Job of the attempt which creation was not recorded in the workflow,
+// the conflict is returned for the Jobs of the other attempts
+func (cp *connPackage) resumeJob(j *jobsmanagerv1beta1.ManagedJobDefinition, job *kbatch.Job, attempt int32, conflict error) error {
+	existing := &kbatch.Job{}
+	err := cp.client.Get(cp.ctx, client.ObjectKeyFromObject(job), existing)
+	if err != nil || !cp.sameIdentity(existing, attempt) || existing.DeletionTimestamp != nil {
+		return conflict
+	}
+	j.Attempt = attempt
+	j.ResolvedSpecHash = existing.Annotations[annotationResolvedSpecHash]
+	cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Resumed", "Job %s of attempt %d exists already, resuming it", job.Name, attempt)
+	return nil
+}
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestCreateJobIdentity(t *testing.T) {
+	mj := &jobsmanagerv1beta1.ManagedJob{ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "apps", UID: "3f1c"}}
+	recorder := record.NewFakeRecorder(10)
+	cp := newTestConnPackageWithClient(&ManagedJobReconciler{Recorder: recorder}, mj, newTestClientBuilder(mj).Build())
+	newJob := func() *kbatch.Job {
+		return &kbatch.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "nightly-load-extract", Namespace: "apps"},
+			Spec: kbatch.JobSpec{Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "extract", Image: "busybox:1.36"}}},
+			}},
+		}
+	}
+	group := &jobsmanagerv1beta1.ManagedJobGroup{Name: "load"}
+	job := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "extract"}
+
+	created := newJob()
+	if err := cp.createJob(job, group, created); err != nil {
+		t.Fatal(err)
+	}
+	if job.Attempt != 1 || created.Annotations[annotationWorkflowUID] != "3f1c" || created.Annotations[annotationJobAttempt] != "1" {
+		t.Errorf("attempt %d, annotations %v", job.Attempt, created.Annotations)
+	}
+	if cp.staleJob(job, created) {
+		t.Error("the Job of the current attempt reported as stale")
+	}
+	<-recorder.Events
+
+	// the workflow update recording the Job was lost
+	job.Attempt, job.ResolvedSpecHash = 0, ""
+	if err := cp.createJob(job, group, newJob()); err != nil {
+		t.Fatalf("expected the Job of the same attempt resumed, got %v", err)
+	}
+	if job.Attempt != 1 || job.ResolvedSpecHash != created.Annotations[annotationResolvedSpecHash] {
+		t.Errorf("resumed job attempt %d, hash %q", job.Attempt, job.ResolvedSpecHash)
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Normal Resumed Job nightly-load-extract of attempt 1") {
+		t.Errorf("unexpected event %q", event)
+	}
+
+	// the Job of the previous attempt is neither resumed nor synced
+	job.Attempt = 1
+	if err := cp.createJob(job, group, newJob()); err == nil || !strings.Contains(err.Error(), "exists") {
+		t.Errorf("expected the conflict with the Job of the previous attempt, got %v", err)
+	}
+	job.Attempt = 2
+	if !cp.staleJob(job, created) {
+		t.Error("the Job of the previous attempt not reported as stale")
+	}
+	recreated := created.DeepCopy()
+	recreated.Annotations[annotationWorkflowUID] = "9a2e"
+	recreated.Annotations[annotationJobAttempt] = "2"
+	if !cp.staleJob(job, recreated) {
+		t.Error("the Job of another workflow with the same name not reported as stale")
+	}
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"strconv"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+/*
+Label schema of the Jobs and pods created by the operator, documented in the README.
+Workflows annotated with jobsmanager.raczylo.com/log-stream: "true" get logging.raczylo.com/stream
+on their pods as <namespace>/<workflow>/<group>, so the log pipelines can route the logs per group.
+*/
+
+const (
+	labelWorkflowName = "jobmanager.raczylo.com/workflow-name"
+	labelGroupName    = "jobmanager.raczylo.com/group-name"
+	labelJobName      = "jobmanager.raczylo.com/job-name"
+	labelJobID        = "jobmanager.raczylo.com/job-id"
+
+	// labelTemplateHash and labelWorkflowGeneration tell the retries of the same spec from the ones
+	// running the spec changed by an edit of the workflow
+	labelTemplateHash       = "jobmanager.raczylo.com/template-hash"
+	labelWorkflowGeneration = "jobmanager.raczylo.com/workflow-generation"
+	templateHashLength      = 10
+
+	// labelCostCenter of the workflow is copied to its Jobs and pods as labelJobCostCenter
+	labelCostCenter    = "jobsmanager.raczylo.com/cost-center"
+	labelJobCostCenter = "jobmanager.raczylo.com/cost-center"
+
+	annotationLogStream = "jobsmanager.raczylo.com/log-stream"
+	logStreamAnnotation = "logging.raczylo.com/stream"
+
+	managedByOperator = "jobs-manager-operator"
+)
+
+// jobLabels returns the operator labels identifying the job within its workflow
+func (cp *connPackage) jobLabels(g *jobsmanagerv1beta1.ManagedJobGroup, j *jobsmanagerv1beta1.ManagedJobDefinition) map[string]string {
+	labels := map[string]string{
+		labelWorkflowName: cp.mj.Name,
+		labelGroupName:    g.Name,
+		labelJobName:      jobNameGenerator(cp.mj.Name, g.Name, j.Name),
+		labelJobID:        j.Name,
+	}
+	if runID := cp.currentRunID(); runID != "" {
+		labels[labelRunID] = runID
+	}
+	return labels
+}
+
+// podLabels extends the operator labels with the recommended app.kubernetes.io labels
+func (cp *connPackage) podLabels(g *jobsmanagerv1beta1.ManagedJobGroup, j *jobsmanagerv1beta1.ManagedJobDefinition) map[string]string {
+	labels := cp.jobLabels(g, j)
+	labels["app.kubernetes.io/name"] = j.Name
+	labels["app.kubernetes.io/instance"] = jobNameGenerator(cp.mj.Name, g.Name, j.Name)
+	labels["app.kubernetes.io/component"] = g.Name
+	labels["app.kubernetes.io/part-of"] = cp.mj.Name
+	labels["app.kubernetes.io/managed-by"] = managedByOperator
+	if costCenter := cp.mj.Labels[labelCostCenter]; costCenter != "" {
+		labels[labelJobCostCenter] = costCenter
+	}
+	return labels
+}
+
+// specVersionLabels returns the labels of the resolved spec and the workflow generation the Job was created from
+func (cp *connPackage) specVersionLabels(resolvedSpecHash string) map[string]string {
+	if len(resolvedSpecHash) > templateHashLength {
+		resolvedSpecHash = resolvedSpecHash[:templateHashLength]
+	}
+	return map[string]string{
+		labelTemplateHash:       resolvedSpecHash,
+		labelWorkflowGeneration: strconv.FormatInt(cp.mj.Generation, 10),
+	}
+}
+
+// podAnnotations returns the annotations added by the operator to the pods of the job
+func (cp *connPackage) podAnnotations(g *jobsmanagerv1beta1.ManagedJobGroup) map[string]string {
+	annotations := map[string]string{}
+	if cp.mj.Annotations[annotationLogStream] == "true" {
+		annotations[logStreamAnnotation] = cp.mj.Namespace + "/" + cp.mj.Name + "/" + g.Name
+	}
+	return annotations
+}
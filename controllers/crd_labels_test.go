@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"testing"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestCreateJobSpecVersionLabels(t *testing.T) {
+	mj := &jobsmanagerv1beta1.ManagedJob{ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "apps", Generation: 3}}
+	cp := newTestConnPackageWithClient(&ManagedJobReconciler{Recorder: record.NewFakeRecorder(10)}, mj, newTestClientBuilder(mj).Build())
+	newJob := func(name string, image string) *kbatch.Job {
+		return &kbatch.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "apps"},
+			Spec: kbatch.JobSpec{Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{labelJobID: "extract"}},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "extract", Image: image}}},
+			}},
+		}
+	}
+
+	first, retry, edited := newJob("first", "busybox:1.36"), newJob("retry", "busybox:1.36"), newJob("edited", "busybox:1.37")
+	for _, job := range []*kbatch.Job{first, retry} {
+		if err := cp.createJob(&jobsmanagerv1beta1.ManagedJobDefinition{Name: "extract"}, &jobsmanagerv1beta1.ManagedJobGroup{Name: "load"}, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mj.Generation = 4
+	if err := cp.createJob(&jobsmanagerv1beta1.ManagedJobDefinition{Name: "extract"}, &jobsmanagerv1beta1.ManagedJobGroup{Name: "load"}, edited); err != nil {
+		t.Fatal(err)
+	}
+
+	hash := first.Labels[labelTemplateHash]
+	if len(hash) != templateHashLength || first.Spec.Template.Labels[labelTemplateHash] != hash || first.Spec.Template.Labels[labelJobID] != "extract" || first.Labels[labelJobID] != "extract" {
+		t.Fatalf("expected the template hash on the Job and its pods, got %v %v", first.Labels, first.Spec.Template.Labels)
+	}
+	if retry.Labels[labelTemplateHash] != hash || retry.Labels[labelWorkflowGeneration] != "3" {
+		t.Errorf("expected the retry labelled with the same spec, got %v", retry.Labels)
+	}
+	if edited.Labels[labelTemplateHash] == hash || edited.Labels[labelWorkflowGeneration] != "4" {
+		t.Errorf("expected the edited spec labelled apart, got %v", edited.Labels)
+	}
+}
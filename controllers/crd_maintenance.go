@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+/*
+Maintenance windows - no new jobs are started during the windows of the operator and of the workflow,
+the running ones continue. Groups with jobs ready to start wait with the Waiting reason and are
+picked up when the window ends.
+*/
+
+const GroupReasonWaiting = "Waiting"
+
+// ParseMaintenanceWindow reads the window given as `[days] HH:MM duration [time zone]`,
+// e.g. `Sat,Sun 22:00 8h Europe/Warsaw` or `02:00 30m`
+func ParseMaintenanceWindow(value string) (jobsmanagerv1beta1.ManagedJobMaintenanceWindow, error) {
+	window := jobsmanagerv1beta1.ManagedJobMaintenanceWindow{}
+	fields := strings.Fields(value)
+	if len(fields) > 0 && !strings.Contains(fields[0], ":") {
+		for _, day := range strings.Split(fields[0], ",") {
+			window.Days = append(window.Days, jobsmanagerv1beta1.MaintenanceWindowDay(day))
+		}
+		fields = fields[1:]
+	}
+	if len(fields) < 2 || len(fields) > 3 {
+		return window, fmt.Errorf("maintenance window %q is not [days] HH:MM duration [time zone]", value)
+	}
+	window.Start = fields[0]
+	duration, err := time.ParseDuration(fields[1])
+	if err != nil {
+		return window, fmt.Errorf("maintenance window %q: %w", value, err)
+	}
+	window.Duration.Duration = duration
+	if len(fields) == 3 {
+		window.TimeZone = fields[2]
+	}
+	if errs := jobsmanagerv1beta1.ValidateMaintenanceWindow(window, field.NewPath("maintenanceWindow")); len(errs) > 0 {
+		return window, fmt.Errorf("maintenance window %q: %w", value, errs.ToAggregate())
+	}
+	return window, nil
+}
+
+// maintenanceWindowEnd returns the end of the window open at the given time, the latest one when they overlap
+func maintenanceWindowEnd(windows []jobsmanagerv1beta1.ManagedJobMaintenanceWindow, now time.Time) (time.Time, bool) {
+	end := time.Time{}
+	for _, window := range windows {
+		location, err := time.LoadLocation(window.TimeZone)
+		if err != nil {
+			continue
+		}
+		start, err := time.Parse("15:04", window.Start)
+		if err != nil {
+			continue
+		}
+		local := now.In(location)
+		// windows are at most a week long, so only the starts of the past week can still be open
+		for daysAgo := 0; daysAgo <= 7; daysAgo++ {
+			opened := time.Date(local.Year(), local.Month(), local.Day()-daysAgo, start.Hour(), start.Minute(), 0, 0, location)
+			closes := opened.Add(window.Duration.Duration)
+			if !window.StartsOn(opened.Weekday()) || opened.After(now) || !closes.After(now) {
+				continue
+			}
+			if closes.After(end) {
+				end = closes
+			}
+		}
+	}
+	return end, !end.IsZero()
+}
+
+// maintenanceWindows are the windows of the operator followed by the ones of the workflow
+func (cp *connPackage) maintenanceWindows() []jobsmanagerv1beta1.ManagedJobMaintenanceWindow {
+	windows := append([]jobsmanagerv1beta1.ManagedJobMaintenanceWindow{}, cp.r.MaintenanceWindows...)
+	return append(windows, cp.mj.Spec.MaintenanceWindows...)
+}
+
+// waitForMaintenanceWindow holds the groups which would start jobs until the window ends
+func (cp *connPackage) waitForMaintenanceWindow(end time.Time) {
+	for _, group := range cp.mj.Spec.Groups {
+		if group.Status != ExecutionStatusPending && group.Status != ExecutionStatusRunning || !dependenciesMet(group.Dependencies) {
+			continue
+		}
+		// the approval is still needed after the window, keep asking for it
+		if group.Reason == GroupReasonAwaitingApproval {
+			continue
+		}
+		waiting := false
+		for _, job := range group.Jobs {
+			if job.Status == ExecutionStatusPending && dependenciesMet(job.Dependencies) {
+				cp.recordDecision(decision{Action: DecisionSkip, Group: group.Name, Job: job.Name, Reason: "maintenance window until " + end.Format(time.RFC3339), Dependencies: job.Dependencies})
+				waiting = true
+			}
+		}
+		if !waiting {
+			continue
+		}
+		if group.Reason != GroupReasonWaiting {
+			cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, GroupReasonWaiting, "Group %s waits for the maintenance window to end at %s", group.Name, end.Format(time.RFC3339))
+		}
+		group.Reason = GroupReasonWaiting
+	}
+	cp.requeueIn(end.Sub(cp.now()))
+}
+
+// endMaintenanceWait clears the reason of the groups held by the window which has ended
+func (cp *connPackage) endMaintenanceWait() {
+	for _, group := range cp.mj.Spec.Groups {
+		if group.Reason == GroupReasonWaiting {
+			group.Reason = ""
+		}
+	}
+}
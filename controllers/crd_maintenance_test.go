@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestParseMaintenanceWindow(t *testing.T) {
+	window, err := ParseMaintenanceWindow("Sat,Sun 22:00 8h Europe/Warsaw")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := jobsmanagerv1beta1.ManagedJobMaintenanceWindow{
+		Days:     []jobsmanagerv1beta1.MaintenanceWindowDay{"Sat", "Sun"},
+		Start:    "22:00",
+		Duration: metav1.Duration{Duration: 8 * time.Hour},
+		TimeZone: "Europe/Warsaw",
+	}
+	if !reflect.DeepEqual(window, expected) {
+		t.Errorf("window = %+v, expected %+v", window, expected)
+	}
+	if _, err := ParseMaintenanceWindow("02:00 30m"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, value := range []string{"", "22:00", "Sat 22:00", "Sat 25:00 1h", "Funday 22:00 1h", "22:00 200h", "22:00 1h Mars/Olympus", "22:00 1h UTC extra"} {
+		if _, err := ParseMaintenanceWindow(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestMaintenanceWindowEnd(t *testing.T) {
+	weekend, _ := ParseMaintenanceWindow("Sat 22:00 8h Europe/Warsaw")
+	nightly, _ := ParseMaintenanceWindow("02:00 30m")
+	windows := []jobsmanagerv1beta1.ManagedJobMaintenanceWindow{weekend, nightly}
+	warsaw, _ := time.LoadLocation("Europe/Warsaw")
+
+	tests := []struct {
+		now time.Time
+		end time.Time
+	}{
+		// 2023-09-02 is Saturday
+		{now: time.Date(2023, 9, 2, 21, 59, 0, 0, warsaw)},
+		{now: time.Date(2023, 9, 2, 23, 0, 0, 0, warsaw), end: time.Date(2023, 9, 3, 6, 0, 0, 0, warsaw)},
+		// past midnight, the window opened on Saturday
+		{now: time.Date(2023, 9, 3, 3, 0, 0, 0, warsaw), end: time.Date(2023, 9, 3, 6, 0, 0, 0, warsaw)},
+		{now: time.Date(2023, 9, 3, 22, 30, 0, 0, warsaw)},
+		{now: time.Date(2023, 9, 5, 2, 10, 0, 0, time.UTC), end: time.Date(2023, 9, 5, 2, 30, 0, 0, time.UTC)},
+		{now: time.Date(2023, 9, 5, 2, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		end, open := maintenanceWindowEnd(windows, tt.now)
+		if open != !tt.end.IsZero() || !end.Equal(tt.end) {
+			t.Errorf("at %s: end = %s (open %t), expected %s", tt.now, end, open, tt.end)
+		}
+	}
+}
+
+func TestWaitForMaintenanceWindow(t *testing.T) {
+	mj := &jobsmanagerv1beta1.ManagedJob{Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+		{Name: "running", Status: ExecutionStatusRunning, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+			{Name: "a", Status: ExecutionStatusRunning},
+			{Name: "b", Status: ExecutionStatusPending},
+		}},
+		{Name: "blocked", Status: ExecutionStatusPending, Dependencies: []*jobsmanagerv1beta1.ManagedJobDependencies{{Name: "running", Status: ExecutionStatusRunning}}, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+			{Name: "c", Status: ExecutionStatusPending},
+		}},
+	}}}
+	cp := &connPackage{r: &ManagedJobReconciler{Recorder: record.NewFakeRecorder(10)}, mj: mj}
+	cp.waitForMaintenanceWindow(time.Now().Add(time.Hour))
+	if mj.Spec.Groups[0].Reason != GroupReasonWaiting || mj.Spec.Groups[1].Reason != "" {
+		t.Errorf("reasons = %q, %q", mj.Spec.Groups[0].Reason, mj.Spec.Groups[1].Reason)
+	}
+	if cp.requeueAfter <= 59*time.Minute || cp.requeueAfter > time.Hour {
+		t.Errorf("requeue after %s", cp.requeueAfter)
+	}
+
+	cp.endMaintenanceWait()
+	if mj.Spec.Groups[0].Reason != "" {
+		t.Errorf("reason %q left after the window", mj.Spec.Groups[0].Reason)
+	}
+}
@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+/*
+Mutexes - the synchronized groups and jobs hold the mutex while they run, e.g. the database migrations
+of several workflows. The mutex is a ManagedJobMutex created by its first holder, the creation fails for
+everyone else. The waiting ones are queued in the mutex and take it in the order they asked for it once
+it's released. Holders which workflow was deleted or which are no longer running are passed over.
+*/
+
+const (
+	GroupReasonMutexWait = "MutexWait"
+	mutexWaitRequeue     = 15 * time.Second
+)
+
+func (cp *connPackage) mutexHolder(group *jobsmanagerv1beta1.ManagedJobGroup, job *jobsmanagerv1beta1.ManagedJobDefinition) jobsmanagerv1beta1.ManagedJobMutexHolder {
+	holder := jobsmanagerv1beta1.ManagedJobMutexHolder{Namespace: cp.mj.Namespace, Workflow: cp.mj.Name, UID: cp.mj.UID, Group: group.Name, Since: metav1.NewTime(cp.now())}
+	if job != nil {
+		holder.Job = job.Name
+	}
+	return holder
+}
+
+func sameHolder(a jobsmanagerv1beta1.ManagedJobMutexHolder, b jobsmanagerv1beta1.ManagedJobMutexHolder) bool {
+	return a.Namespace == b.Namespace && a.Workflow == b.Workflow && a.UID == b.UID && a.Group == b.Group && a.Job == b.Job
+}
+
+func holderDescription(holder jobsmanagerv1beta1.ManagedJobMutexHolder) string {
+	description := "group " + holder.Group + " of workflow " + holder.Namespace + "/" + holder.Workflow
+	if holder.Job != "" {
+		description = "job " + holder.Job + " of " + description
+	}
+	return description
+}
+
+// holderStatus returns the status of the group or job of the holder, empty when its workflow is gone
+func (cp *connPackage) holderStatus(holder jobsmanagerv1beta1.ManagedJobMutexHolder) (string, error) {
+	mj := cp.mj
+	if holder.Namespace != cp.mj.Namespace || holder.Workflow != cp.mj.Name {
+		mj = &jobsmanagerv1beta1.ManagedJob{}
+		err := cp.client.Get(cp.ctx, types.NamespacedName{Namespace: holder.Namespace, Name: holder.Workflow}, mj)
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		mj.MergeStatus()
+	}
+	if mj.UID != holder.UID {
+		return "", nil
+	}
+	for _, group := range mj.Spec.Groups {
+		if group.Name != holder.Group {
+			continue
+		}
+		if holder.Job == "" {
+			return group.Status, nil
+		}
+		for _, job := range group.Jobs {
+			if job.Name == holder.Job {
+				return job.Status, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// acquireMutex takes the mutex for the holder or queues it, reporting if the holder may run and
+// who holds the mutex otherwise. The pending holders keep the mutex, their start may not be stored yet.
+func (cp *connPackage) acquireMutex(sync *jobsmanagerv1beta1.ManagedJobSynchronization, holder jobsmanagerv1beta1.ManagedJobMutexHolder) (bool, *jobsmanagerv1beta1.ManagedJobMutexHolder, error) {
+	name := sync.MutexObjectName(cp.mj.Namespace)
+	var mutex jobsmanagerv1beta1.ManagedJobMutex
+	err := cp.client.Get(cp.ctx, client.ObjectKey{Name: name}, &mutex)
+	if apierrors.IsNotFound(err) {
+		mutex = jobsmanagerv1beta1.ManagedJobMutex{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       jobsmanagerv1beta1.ManagedJobMutexSpec{Mutex: sync.Mutex, Holder: &holder},
+		}
+		if sync.Scope != jobsmanagerv1beta1.MutexScopeCluster {
+			mutex.Spec.Namespace = cp.mj.Namespace
+		}
+		err = cp.client.Create(cp.ctx, &mutex)
+		if apierrors.IsAlreadyExists(err) {
+			return false, nil, nil // someone else was faster, queued on the next attempt
+		}
+		return err == nil, nil, err
+	}
+	if err != nil {
+		return false, nil, err
+	}
+	if mutex.Spec.Holder != nil && sameHolder(*mutex.Spec.Holder, holder) {
+		return true, nil, nil
+	}
+
+	original := mutex.DeepCopy()
+	held := false
+	if mutex.Spec.Holder != nil {
+		status, err := cp.holderStatus(*mutex.Spec.Holder)
+		if err != nil {
+			return false, mutex.Spec.Holder, err
+		}
+		held = status == ExecutionStatusPending || status == ExecutionStatusRunning
+	}
+
+	// waiters which are no longer pending are passed over
+	queue := []jobsmanagerv1beta1.ManagedJobMutexHolder{}
+	queued := false
+	for _, waiter := range mutex.Spec.Queue {
+		if sameHolder(waiter, holder) {
+			queued = true
+			queue = append(queue, waiter)
+			continue
+		}
+		status, err := cp.holderStatus(waiter)
+		if err != nil {
+			return false, mutex.Spec.Holder, err
+		}
+		if status == ExecutionStatusPending {
+			queue = append(queue, waiter)
+		}
+	}
+	if !queued {
+		queue = append(queue, holder)
+	}
+
+	acquired := !held && sameHolder(queue[0], holder)
+	if acquired {
+		holder.Since = metav1.NewTime(cp.now())
+		mutex.Spec.Holder = &holder
+		queue = queue[1:]
+	}
+	if len(queue) == 0 {
+		queue = nil
+	}
+	mutex.Spec.Queue = queue
+	if !reflect.DeepEqual(original.Spec, mutex.Spec) {
+		if err := cp.client.Update(cp.ctx, &mutex); err != nil {
+			if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+				return false, original.Spec.Holder, nil // changed in the meantime, tried again on the next reconcile
+			}
+			return false, original.Spec.Holder, err
+		}
+	}
+	if acquired {
+		return true, nil, nil
+	}
+	if original.Spec.Holder == nil {
+		return false, &queue[0], nil
+	}
+	return false, original.Spec.Holder, nil
+}
+
+// releaseMutex frees the mutex held by the holder, the mutex nobody waits for is deleted
+func (cp *connPackage) releaseMutex(sync *jobsmanagerv1beta1.ManagedJobSynchronization, holder jobsmanagerv1beta1.ManagedJobMutexHolder) error {
+	var mutex jobsmanagerv1beta1.ManagedJobMutex
+	err := cp.client.Get(cp.ctx, client.ObjectKey{Name: sync.MutexObjectName(cp.mj.Namespace)}, &mutex)
+	if err != nil || mutex.Spec.Holder == nil || !sameHolder(*mutex.Spec.Holder, holder) {
+		return client.IgnoreNotFound(err)
+	}
+	if len(mutex.Spec.Queue) == 0 {
+		return client.IgnoreNotFound(cp.client.Delete(cp.ctx, &mutex, client.Preconditions{ResourceVersion: &mutex.ResourceVersion}))
+	}
+	mutex.Spec.Holder = nil
+	return client.IgnoreNotFound(cp.client.Update(cp.ctx, &mutex))
+}
+
+// groupAcquiresMutex checks the mutex of the group which is about to start
+func (cp *connPackage) groupAcquiresMutex(group *jobsmanagerv1beta1.ManagedJobGroup) bool {
+	if group.Synchronization == nil {
+		return true
+	}
+	acquired, holder, err := cp.acquireMutex(group.Synchronization, cp.mutexHolder(group, nil))
+	if err != nil {
+		log.Log.Info("Unable to acquire the mutex", "workflow", cp.mj.Name, "group", group.Name, "mutex", group.Synchronization.Mutex, "error", err.Error())
+		cp.reconcileError(err)
+	}
+	if acquired {
+		if group.Reason == GroupReasonMutexWait {
+			group.Reason = ""
+		}
+		return true
+	}
+	if group.Reason != GroupReasonMutexWait && holder != nil {
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, GroupReasonMutexWait, "Group %s waits for the mutex %s held by %s", group.Name, group.Synchronization.Mutex, holderDescription(*holder))
+	}
+	group.Reason = GroupReasonMutexWait
+	cp.requeueIn(mutexWaitRequeue)
+	return false
+}
+
+// jobAcquiresMutex checks the mutex of the job which is about to start
+func (cp *connPackage) jobAcquiresMutex(group *jobsmanagerv1beta1.ManagedJobGroup, job *jobsmanagerv1beta1.ManagedJobDefinition) bool {
+	if job.Synchronization == nil {
+		return true
+	}
+	acquired, holder, err := cp.acquireMutex(job.Synchronization, cp.mutexHolder(group, job))
+	if err != nil {
+		log.Log.Info("Unable to acquire the mutex", "workflow", cp.mj.Name, "group", group.Name, "job", job.Name, "mutex", job.Synchronization.Mutex, "error", err.Error())
+		cp.reconcileError(err)
+	}
+	if acquired {
+		return true
+	}
+	reason := "waits for the mutex " + job.Synchronization.Mutex
+	if holder != nil {
+		reason += " held by " + holderDescription(*holder)
+	}
+	cp.recordDecision(decision{Action: DecisionSkip, Group: group.Name, Job: job.Name, Reason: reason})
+	cp.requeueIn(mutexWaitRequeue)
+	return false
+}
+
+// releaseMutexes frees the mutexes of the groups and jobs which are no longer running
+func (cp *connPackage) releaseMutexes() {
+	finished := func(status string) bool {
+		return status != "" && status != ExecutionStatusPending && status != ExecutionStatusRunning
+	}
+	release := func(sync *jobsmanagerv1beta1.ManagedJobSynchronization, holder jobsmanagerv1beta1.ManagedJobMutexHolder) {
+		if err := cp.releaseMutex(sync, holder); err != nil {
+			log.Log.Info("Unable to release the mutex", "workflow", cp.mj.Name, "mutex", sync.Mutex, "error", err.Error())
+			cp.reconcileError(err)
+		}
+	}
+	for _, group := range cp.mj.Spec.Groups {
+		if group.Synchronization != nil && finished(group.Status) {
+			release(group.Synchronization, cp.mutexHolder(group, nil))
+		}
+		for _, job := range group.Jobs {
+			if job.Synchronization != nil && finished(job.Status) {
+				release(job.Synchronization, cp.mutexHolder(group, job))
+			}
+		}
+	}
+}
+
+// workflowsWaitingForMutex maps the changed mutex to the workflows queued for it
+func (r *ManagedJobReconciler) workflowsWaitingForMutex(ctx context.Context, obj client.Object) []reconcile.Request {
+	mutex, ok := obj.(*jobsmanagerv1beta1.ManagedJobMutex)
+	if !ok {
+		return nil
+	}
+	requests := []reconcile.Request{}
+	seen := map[types.NamespacedName]bool{}
+	for _, waiter := range mutex.Spec.Queue {
+		key := types.NamespacedName{Namespace: waiter.Namespace, Name: waiter.Workflow}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		// the mutex is not part of the fingerprint of the workflow
+		r.syncFingerprints.forget(key)
+		requests = append(requests, reconcile.Request{NamespacedName: key})
+	}
+	return requests
+}
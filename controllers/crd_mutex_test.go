@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func migrationWorkflow(name string) *jobsmanagerv1beta1.ManagedJob {
+	return &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "apps", UID: types.UID(name + "-uid")},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{
+			Name:   "migrate",
+			Status: ExecutionStatusPending,
+			Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{
+				Name:            "schema",
+				Status:          ExecutionStatusPending,
+				Synchronization: &jobsmanagerv1beta1.ManagedJobSynchronization{Mutex: "db-migrations", Scope: jobsmanagerv1beta1.MutexScopeNamespace},
+			}},
+		}}},
+	}
+}
+
+func TestJobMutex(t *testing.T) {
+	first, second, third := migrationWorkflow("billing"), migrationWorkflow("orders"), migrationWorkflow("users")
+	c := newTestClientBuilder(first, second, third).Build()
+	r := &ManagedJobReconciler{Recorder: record.NewFakeRecorder(10)}
+	connect := func(mj *jobsmanagerv1beta1.ManagedJob) *connPackage {
+		return newTestConnPackageWithClient(r, mj, c)
+	}
+	job := func(mj *jobsmanagerv1beta1.ManagedJob) *jobsmanagerv1beta1.ManagedJobDefinition {
+		return mj.Spec.Groups[0].Jobs[0]
+	}
+	acquires := func(mj *jobsmanagerv1beta1.ManagedJob) bool {
+		return connect(mj).jobAcquiresMutex(mj.Spec.Groups[0], job(mj))
+	}
+	store := func(mj *jobsmanagerv1beta1.ManagedJob) {
+		if err := c.Update(context.Background(), mj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !acquires(first) {
+		t.Fatal("expected the first job to acquire the free mutex")
+	}
+	job(first).Status = ExecutionStatusRunning
+	store(first)
+	if acquires(second) || acquires(third) {
+		t.Fatal("expected the other jobs to wait for the mutex")
+	}
+	if !acquires(first) {
+		t.Error("expected the holder to keep the mutex")
+	}
+
+	var mutex jobsmanagerv1beta1.ManagedJobMutex
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "apps.db-migrations"}, &mutex); err != nil {
+		t.Fatal(err)
+	}
+	if mutex.Spec.Holder == nil || mutex.Spec.Holder.Workflow != "billing" || len(mutex.Spec.Queue) != 2 || mutex.Spec.Queue[0].Workflow != "orders" {
+		t.Fatalf("unexpected mutex %+v", mutex.Spec)
+	}
+	if requests := r.workflowsWaitingForMutex(context.Background(), &mutex); len(requests) != 2 {
+		t.Errorf("expected the waiting workflows enqueued, got %v", requests)
+	}
+
+	// the holder finished, the waiters take the mutex in the order they asked for it
+	job(first).Status = ExecutionStatusSucceeded
+	store(first)
+	connect(first).releaseMutexes()
+	if acquires(third) {
+		t.Error("expected the later waiter to let the first one go")
+	}
+	if !acquires(second) {
+		t.Fatal("expected the first waiter to acquire the released mutex")
+	}
+
+	// the holder which workflow was deleted is passed over
+	if err := c.Delete(context.Background(), second); err != nil {
+		t.Fatal(err)
+	}
+	if !acquires(third) {
+		t.Fatal("expected the mutex of the deleted workflow taken over")
+	}
+	job(third).Status = ExecutionStatusFailed
+	store(third)
+	connect(third).releaseMutexes()
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "apps.db-migrations"}, &mutex); err == nil {
+		t.Errorf("expected the mutex nobody waits for deleted, got %+v", mutex.Spec)
+	}
+}
+
+func TestMutexObjectName(t *testing.T) {
+	namespaced := &jobsmanagerv1beta1.ManagedJobSynchronization{Mutex: "db-migrations"}
+	cluster := &jobsmanagerv1beta1.ManagedJobSynchronization{Mutex: "db-migrations", Scope: jobsmanagerv1beta1.MutexScopeCluster}
+	if name := namespaced.MutexObjectName("apps"); name != "apps.db-migrations" {
+		t.Errorf("namespaced mutex = %s", name)
+	}
+	if name := cluster.MutexObjectName("apps"); name != "db-migrations" {
+		t.Errorf("cluster mutex = %s", name)
+	}
+}
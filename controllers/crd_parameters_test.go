@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+// every combination of the restart policy set on the workflow, group and job level
+func TestCompileParametersRestartPolicy(t *testing.T) {
+	policies := []string{"", "OnFailure", "Never"}
+	cp := &connPackage{}
+	for _, workflowPolicy := range policies {
+		for _, groupPolicy := range policies {
+			for _, jobPolicy := range policies {
+				expected := string(jobsmanagerv1beta1.DefaultRestartPolicy)
+				for _, policy := range []string{workflowPolicy, groupPolicy, jobPolicy} {
+					if policy != "" {
+						expected = policy
+					}
+				}
+				t.Run(fmt.Sprintf("workflow=%q/group=%q/job=%q", workflowPolicy, groupPolicy, jobPolicy), func(t *testing.T) {
+					compiled := cp.compileParameters(
+						jobsmanagerv1beta1.ManagedJobParameters{RestartPolicy: workflowPolicy},
+						jobsmanagerv1beta1.ManagedJobParameters{RestartPolicy: groupPolicy},
+						jobsmanagerv1beta1.ManagedJobParameters{RestartPolicy: jobPolicy},
+					)
+					if compiled.RestartPolicy != expected {
+						t.Errorf("restart policy = %q, expected %q", compiled.RestartPolicy, expected)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestCompileParametersSubWorkflowRestartPolicy(t *testing.T) {
+	cp := &connPackage{}
+	parent := cp.compileParameters(jobsmanagerv1beta1.ManagedJobParameters{})
+	// defaulted parent parameters are passed down, the sub-workflow levels still override them
+	child := cp.compileParameters(cp.compileParameters(parent, jobsmanagerv1beta1.ManagedJobParameters{}), jobsmanagerv1beta1.ManagedJobParameters{RestartPolicy: "Never"})
+	if child.RestartPolicy != "Never" {
+		t.Errorf("restart policy = %q, expected Never", child.RestartPolicy)
+	}
+}
+
+func TestCompileParametersEnv(t *testing.T) {
+	cp := &connPackage{}
+	compiled := cp.compileParameters(
+		jobsmanagerv1beta1.ManagedJobParameters{Env: []corev1.EnvVar{{Name: "LOG_LEVEL", Value: "info"}, {Name: "REGION", Value: "eu"}}},
+		jobsmanagerv1beta1.ManagedJobParameters{Env: []corev1.EnvVar{{Name: "BATCH", Value: "100"}, {Name: "REGION", Value: "us"}}},
+		jobsmanagerv1beta1.ManagedJobParameters{Env: []corev1.EnvVar{{Name: "LOG_LEVEL", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}}}},
+	)
+	expected := []corev1.EnvVar{
+		{Name: "LOG_LEVEL", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+		{Name: "REGION", Value: "us"},
+		{Name: "BATCH", Value: "100"},
+	}
+	if !reflect.DeepEqual(compiled.Env, expected) {
+		t.Errorf("env = %+v, expected %+v", compiled.Env, expected)
+	}
+}
+
+func TestCompileParametersVolumes(t *testing.T) {
+	cp := &connPackage{}
+	shared := corev1.Volume{Name: "shared", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}
+	data := corev1.Volume{Name: "data", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory}}}
+	compiled := cp.compileParameters(
+		jobsmanagerv1beta1.ManagedJobParameters{
+			Volumes:      []corev1.Volume{shared},
+			VolumeMounts: []corev1.VolumeMount{{Name: "shared", MountPath: "/shared"}},
+		},
+		jobsmanagerv1beta1.ManagedJobParameters{
+			Volumes:      []corev1.Volume{shared, data},
+			VolumeMounts: []corev1.VolumeMount{{Name: "data", MountPath: "/data"}, {Name: "shared", MountPath: "/shared", ReadOnly: true}},
+		},
+	)
+	if !reflect.DeepEqual(compiled.Volumes, []corev1.Volume{shared, data}) {
+		t.Errorf("volumes = %+v", compiled.Volumes)
+	}
+	expectedMounts := []corev1.VolumeMount{{Name: "shared", MountPath: "/shared", ReadOnly: true}, {Name: "data", MountPath: "/data"}}
+	if !reflect.DeepEqual(compiled.VolumeMounts, expectedMounts) {
+		t.Errorf("volume mounts = %+v, expected %+v", compiled.VolumeMounts, expectedMounts)
+	}
+}
+
+func TestBuildJobContainerParams(t *testing.T) {
+	user, group := int64(1000), int64(2000)
+	cp := &connPackage{r: &ManagedJobReconciler{}, mj: &jobsmanagerv1beta1.ManagedJob{}}
+	cp.mj.Name, cp.mj.Namespace = "nightly", "etl"
+	cp.mj.Spec.Params = jobsmanagerv1beta1.ManagedJobParameters{WorkingDir: "/srv", RunAsUser: &user, Env: []corev1.EnvVar{{Name: "TARGET_DIR", Value: "/backup"}}}
+	g := &jobsmanagerv1beta1.ManagedJobGroup{Name: "extract", Params: jobsmanagerv1beta1.ManagedJobParameters{WorkingDir: "/srv/data", RunAsGroup: &group}}
+	j := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "sync", Image: "rsync", Args: []string{"--dest", "$(TARGET_DIR)"}}
+
+	container := cp.buildJob(j, g).Spec.Template.Spec.Containers[0]
+	if container.WorkingDir != "/srv/data" {
+		t.Errorf("working dir = %q, expected the lower level one", container.WorkingDir)
+	}
+	if sc := container.SecurityContext; sc == nil || *sc.RunAsUser != 1000 || *sc.RunAsGroup != 2000 {
+		t.Errorf("security context = %+v", sc)
+	}
+	// the Job does not share the pointers of the workflow
+	if *container.SecurityContext.RunAsUser = 0; user != 1000 {
+		t.Errorf("workflow user changed to %d", user)
+	}
+	// the args reference the container env, Kubernetes expands them when starting the container
+	if !reflect.DeepEqual(container.Args, j.Args) || len(container.Env) != 1 || container.Env[0].Name != "TARGET_DIR" {
+		t.Errorf("args = %v env = %+v", container.Args, container.Env)
+	}
+
+	cp.mj.Spec.Params, g.Params = jobsmanagerv1beta1.ManagedJobParameters{}, jobsmanagerv1beta1.ManagedJobParameters{}
+	if container := cp.buildJob(j, g).Spec.Template.Spec.Containers[0]; container.SecurityContext != nil {
+		t.Errorf("expected the image user kept, got %+v", container.SecurityContext)
+	}
+}
+
+func TestApplyParamsPatches(t *testing.T) {
+	value := func(raw string) *apiextensionsv1.JSON { return &apiextensionsv1.JSON{Raw: []byte(raw)} }
+	compiled := jobsmanagerv1beta1.ManagedJobParameters{
+		Env:           []corev1.EnvVar{{Name: "LOG_LEVEL", Value: "info"}, {Name: "REGION", Value: "eu"}},
+		RestartPolicy: "OnFailure",
+	}
+	patched, err := applyParamsPatches(compiled, []jobsmanagerv1beta1.ManagedJobParamsPatch{
+		{Op: "test", Path: "/env/1/name", Value: value(`"REGION"`)},
+		{Op: "replace", Path: "/env/1/value", Value: value(`"us"`)},
+		{Op: "remove", Path: "/env/0"},
+		{Op: "replace", Path: "/restartPolicy", Value: value(`"Never"`)},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := jobsmanagerv1beta1.ManagedJobParameters{Env: []corev1.EnvVar{{Name: "REGION", Value: "us"}}, RestartPolicy: "Never"}
+	if !reflect.DeepEqual(patched, expected) {
+		t.Errorf("patched = %+v, expected %+v", patched, expected)
+	}
+
+	for name, patches := range map[string][]jobsmanagerv1beta1.ManagedJobParamsPatch{
+		"failed test":   {{Op: "test", Path: "/restartPolicy", Value: value(`"Never"`)}},
+		"missing path":  {{Op: "replace", Path: "/env/5/value", Value: value(`"us"`)}},
+		"unknown field": {{Op: "add", Path: "/restartPolicyy", Value: value(`"Never"`)}},
+		"wrong type":    {{Op: "replace", Path: "/env", Value: value(`"REGION=us"`)}},
+	} {
+		result, err := applyParamsPatches(compiled, patches)
+		if err == nil {
+			t.Errorf("%s: expected the patch to fail", name)
+		}
+		if !reflect.DeepEqual(result, compiled) {
+			t.Errorf("%s: params changed by the failed patch: %+v", name, result)
+		}
+	}
+}
+
+// the job with the invalid patch fails, the other jobs are compiled as usual
+func TestResolveDependenciesInvalidParamsPatch(t *testing.T) {
+	mj := &jobsmanagerv1beta1.ManagedJob{Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{
+		Name: "g",
+		Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+			{Name: "broken", Parallel: true, ParamsPatches: []jobsmanagerv1beta1.ManagedJobParamsPatch{{Op: "remove", Path: "/env/0"}}},
+			{Name: "fine", Parallel: true, Status: ExecutionStatusPending},
+		},
+	}}}}
+	cp := &connPackage{mj: mj}
+	if _, err := cp.resolveDependencies(); err == nil {
+		t.Fatal("expected the patch error to be reported")
+	}
+	jobs := mj.Spec.Groups[0].Jobs
+	if jobs[0].Status != ExecutionStatusFailed {
+		t.Errorf("broken job status = %q, expected failed", jobs[0].Status)
+	}
+	if params, err := cp.jobParameters(mj.Spec.Groups[0], jobs[1]); jobs[1].Status != ExecutionStatusPending || err != nil || params.RestartPolicy != string(jobsmanagerv1beta1.DefaultRestartPolicy) {
+		t.Errorf("fine job = %+v", jobs[1])
+	}
+}
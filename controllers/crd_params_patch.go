@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+/* Params patches - RFC 6902 operations applied to the compiled params of the job */
+
+// applyParamsPatches returns the params with the patches applied, params are returned unchanged
+// if any operation fails or the patched document is not valid params anymore
+func applyParamsPatches(params jobsmanagerv1beta1.ManagedJobParameters, patches []jobsmanagerv1beta1.ManagedJobParamsPatch) (jobsmanagerv1beta1.ManagedJobParameters, error) {
+	if len(patches) == 0 {
+		return params, nil
+	}
+	// field names of the patch type follow RFC 6902, so the list marshals straight into the patch document
+	patchDocument, err := json.Marshal(patches)
+	if err != nil {
+		return params, err
+	}
+	patch, err := jsonpatch.DecodePatch(patchDocument)
+	if err != nil {
+		return params, err
+	}
+	document, err := json.Marshal(params)
+	if err != nil {
+		return params, err
+	}
+	patched, err := patch.Apply(document)
+	if err != nil {
+		return params, err
+	}
+	result := jobsmanagerv1beta1.ManagedJobParameters{}
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&result); err != nil {
+		return params, fmt.Errorf("patched params are invalid: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+/*
+Pending reasons - pending alone does not tell if a job waits for the work before it or for the operator
+to let it start. After every scheduling pass the pending jobs are marked Blocked when their dependencies
+or the ones of their group are not met, Queued when they are ready but held back by the capacity, quota,
+approval, delay or the maintenance window. Pending groups waiting for other groups are Blocked too, the
+groups which are ready keep their specific reason.
+*/
+
+const (
+	ReasonBlocked = "Blocked"
+	ReasonQueued  = "Queued"
+)
+
+// classifyPending sets the reasons of the pending groups and jobs, clearing them once they start
+func classifyPending(spec *jobsmanagerv1beta1.ManagedJobSpec) {
+	for _, group := range spec.Groups {
+		groupBlocked := !dependenciesMet(group.Dependencies)
+		switch {
+		case group.Status == ExecutionStatusPending && groupBlocked && group.Reason == "":
+			group.Reason = ReasonBlocked
+		case group.Reason == ReasonBlocked && (group.Status != ExecutionStatusPending || !groupBlocked):
+			group.Reason = ""
+		}
+		for _, job := range group.Jobs {
+			switch {
+			case job.Status == ExecutionStatusSkipped && (job.Reason == ReasonExpired || job.Reason == ReasonConditionNotMet):
+			case job.Status != ExecutionStatusPending:
+				job.Reason = ""
+			case groupBlocked || !dependenciesMet(job.Dependencies):
+				job.Reason = ReasonBlocked
+			default:
+				job.Reason = ReasonQueued
+			}
+		}
+	}
+}
+
+func (cp *connPackage) classifyPending() {
+	classifyPending(&cp.mj.Spec)
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"testing"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestClassifyPending(t *testing.T) {
+	build := testGroup("build", ExecutionStatusRunning)
+	build.Jobs = append(build.Jobs, &jobsmanagerv1beta1.ManagedJobDefinition{
+		Name:         "package",
+		Status:       ExecutionStatusPending,
+		Dependencies: []*jobsmanagerv1beta1.ManagedJobDependencies{{Name: "wf-build-job", Status: ExecutionStatusRunning}},
+	})
+	approval := testGroup("approval", ExecutionStatusPending)
+	approval.Reason = GroupReasonAwaitingApproval
+	deploy := testGroup("deploy", ExecutionStatusPending, "build")
+	spec := &jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{build, approval, deploy}}
+
+	classifyPending(spec)
+	expected := map[string]string{
+		"build/job":     "",
+		"build/package": ReasonBlocked,
+		"approval/job":  ReasonQueued,
+		"deploy/job":    ReasonBlocked,
+	}
+	for _, group := range spec.Groups {
+		for _, job := range group.Jobs {
+			if reason := job.Reason; reason != expected[group.Name+"/"+job.Name] {
+				t.Errorf("expected %s/%s reason %q, got %q", group.Name, job.Name, expected[group.Name+"/"+job.Name], reason)
+			}
+		}
+	}
+	if approval.Reason != GroupReasonAwaitingApproval {
+		t.Errorf("expected the specific reason of the ready group kept, got %s", approval.Reason)
+	}
+	if deploy.Reason != ReasonBlocked {
+		t.Errorf("expected the group waiting for build blocked, got %s", deploy.Reason)
+	}
+
+	deploy.Dependencies[0].Status = ExecutionStatusSucceeded
+	classifyPending(spec)
+	if deploy.Reason != "" || deploy.Jobs[0].Reason != ReasonQueued {
+		t.Errorf("expected the ready group unblocked and its job queued, got %q and %q", deploy.Reason, deploy.Jobs[0].Reason)
+	}
+}
@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"github.com/lukaszraczylo/pandati"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/pkg/dependencies"
+)
+
+/*
+Status propagation - a single deterministic pass over the workflow in topological order.
+Every dependency gets the status of the group or job it points to, so the result does not
+depend on the order in which the statuses changed during the reconcile. The dependents which
+can no longer run are aborted when their dependency failed and skipped when it finished in the
+state their condition does not wait for, e.g. the failure handlers of the succeeded jobs.
+*/
+
+// ReasonConditionNotMet is the reason of the groups and jobs skipped as their dependency condition can't be met
+const ReasonConditionNotMet = "ConditionNotMet"
+
+// dependencyStatus is the status seen by the dependents, aborted nodes fail their dependents as well
+// and the skipped ones do not hold them
+func dependencyStatus(status string) string {
+	switch status {
+	case ExecutionStatusAborted:
+		return ExecutionStatusFailed
+	case ExecutionStatusSkipped:
+		return ExecutionStatusSucceeded
+	}
+	return status
+}
+
+// dependencyMet tells if the dependency reached the state its condition waits for
+func dependencyMet(dependency *jobsmanagerv1beta1.ManagedJobDependencies) bool {
+	switch dependency.DependencyCondition() {
+	case jobsmanagerv1beta1.DependencyConditionFailed:
+		return dependency.Status == ExecutionStatusFailed
+	case jobsmanagerv1beta1.DependencyConditionFinished:
+		return dependency.Status == ExecutionStatusSucceeded || dependency.Status == ExecutionStatusFailed
+	}
+	return dependency.Status == ExecutionStatusSucceeded
+}
+
+// dependencyUnmet tells if the dependency finished in the state its condition does not wait for
+func dependencyUnmet(dependency *jobsmanagerv1beta1.ManagedJobDependencies) bool {
+	switch dependency.DependencyCondition() {
+	case jobsmanagerv1beta1.DependencyConditionFailed:
+		return dependency.Status == ExecutionStatusSucceeded
+	case jobsmanagerv1beta1.DependencyConditionFinished:
+		return false
+	}
+	return dependency.Status == ExecutionStatusFailed
+}
+
+// dependenciesMet checks if all the dependencies reached the state of their condition
+func dependenciesMet(dependencies []*jobsmanagerv1beta1.ManagedJobDependencies) bool {
+	for _, dependency := range dependencies {
+		if !dependencyMet(dependency) {
+			return false
+		}
+	}
+	return true
+}
+
+// skipDisabledGroup marks the pending group left out of enabledGroups and its jobs as skipped,
+// the group enabled again goes back to pending unless it was skipped for its notAfter
+func skipDisabledGroup(spec *jobsmanagerv1beta1.ManagedJobSpec, group *jobsmanagerv1beta1.ManagedJobGroup, decide decisionRecorder) {
+	enabled := spec.GroupEnabled(group.Name)
+	switch {
+	case !enabled && (group.Status == ExecutionStatusPending || group.Status == ""):
+		group.Status = ExecutionStatusSkipped
+		group.Reason = ""
+		for _, job := range group.Jobs {
+			if job.Status == ExecutionStatusPending || job.Status == "" {
+				job.Status = ExecutionStatusSkipped
+				decide.record(decision{Action: DecisionSkip, Group: group.Name, Job: job.Name, Reason: "group not in enabledGroups"})
+			}
+		}
+	case enabled && group.Status == ExecutionStatusSkipped && group.Reason != ReasonExpired && group.Reason != ReasonConditionNotMet:
+		group.Status = ExecutionStatusPending
+		for _, job := range group.Jobs {
+			if job.Status == ExecutionStatusSkipped {
+				job.Status = ExecutionStatusPending
+			}
+		}
+	}
+}
+
+// propagateStatuses updates all the dependency statuses in a single pass, aborting the pending
+// jobs and groups with failed dependencies, skipping the ones with the conditions which can't be met
+// and finishing the groups which jobs are all done, groups with failures within their failure budget succeed
+func propagateStatuses(workflowName string, spec *jobsmanagerv1beta1.ManagedJobSpec, decide decisionRecorder) {
+	statusOf := map[string]string{}
+	for _, group := range spec.Groups {
+		statusOf[group.Name] = group.Status
+		for _, job := range group.Jobs {
+			statusOf[jobNameGenerator(workflowName, group.Name, job.Name)] = job.Status
+		}
+	}
+
+	// failed is reported for the failed dependencies the dependent waits to succeed, unmet for the rest
+	// of the dependencies which can't reach the state of their condition anymore
+	refresh := func(dependencies []*jobsmanagerv1beta1.ManagedJobDependencies) (failed bool, unmet bool) {
+		for _, dependency := range dependencies {
+			if status, found := statusOf[dependency.Name]; found {
+				dependency.Status = dependencyStatus(status)
+			}
+			if dependencyUnmet(dependency) {
+				if dependency.Status == ExecutionStatusFailed {
+					failed = true
+				} else {
+					unmet = true
+				}
+			}
+		}
+		return failed, unmet
+	}
+
+	groups, _ := dependencies.OrderedGroups(spec)
+	for _, group := range groups {
+		skipDisabledGroup(spec, group, decide)
+		jobs, _ := dependencies.OrderedJobs(workflowName, group)
+		jobsSucceeded, jobsFailed := 0, 0
+		for _, job := range jobs {
+			statusOf[jobNameGenerator(workflowName, group.Name, job.Name)] = job.Status
+			failed, unmet := refresh(job.Dependencies)
+			switch {
+			case job.Status != ExecutionStatusPending:
+			case failed:
+				job.Status = ExecutionStatusAborted
+				job.Reason = ReasonDependencyFailed
+				statusOf[jobNameGenerator(workflowName, group.Name, job.Name)] = job.Status
+				decide.record(decision{Action: DecisionAbort, Group: group.Name, Job: job.Name, Reason: "dependency failed", Dependencies: job.Dependencies})
+			case unmet:
+				job.Status = ExecutionStatusSkipped
+				job.Reason = ReasonConditionNotMet
+				statusOf[jobNameGenerator(workflowName, group.Name, job.Name)] = job.Status
+				decide.record(decision{Action: DecisionSkip, Group: group.Name, Job: job.Name, Reason: "dependency condition can not be met", Dependencies: job.Dependencies})
+			}
+			switch {
+			case job.Status == ExecutionStatusSucceeded || job.Status == ExecutionStatusSkipped:
+				jobsSucceeded++
+			case (job.Status == ExecutionStatusFailed || job.Status == ExecutionStatusAborted) && failureHandler(job.Dependencies):
+				// the failed handler does not fail the group
+				jobsSucceeded++
+			case job.Status == ExecutionStatusFailed || job.Status == ExecutionStatusAborted:
+				jobsFailed++
+			}
+		}
+
+		groupDependencyFailed, groupDependencyUnmet := refresh(group.Dependencies)
+		if groupDependencyUnmet && !groupDependencyFailed && group.Status == ExecutionStatusPending {
+			group.Status = ExecutionStatusSkipped
+			group.Reason = ReasonConditionNotMet
+			for _, job := range group.Jobs {
+				if job.Status == ExecutionStatusPending {
+					job.Status = ExecutionStatusSkipped
+					job.Reason = ReasonConditionNotMet
+					statusOf[jobNameGenerator(workflowName, group.Name, job.Name)] = job.Status
+					decide.record(decision{Action: DecisionSkip, Group: group.Name, Job: job.Name, Reason: "dependency condition of the group can not be met", Dependencies: group.Dependencies})
+				}
+			}
+		}
+		if group.Status == ExecutionStatusSkipped {
+			statusOf[group.Name] = group.Status
+			continue
+		}
+		if jobsSucceeded == len(group.Jobs) {
+			group.Status = ExecutionStatusSucceeded
+		} else if jobsFailed > 0 && jobsSucceeded+jobsFailed == len(group.Jobs) {
+			if jobsFailed <= failureBudget(group) {
+				group.Status = ExecutionStatusSucceeded
+				group.Reason = GroupReasonFailureBudget
+			} else {
+				group.Status = ExecutionStatusFailed
+			}
+		} else if groupDependencyFailed && pandati.ExistsInSlice([]string{ExecutionStatusPending, ExecutionStatusRunning}, group.Status) {
+			group.Status = ExecutionStatusAborted
+		}
+		statusOf[group.Name] = group.Status
+	}
+}
+
+func (cp *connPackage) propagateStatuses() {
+	propagateStatuses(cp.mj.Name, &cp.mj.Spec, cp.recordDecision)
+	cp.recordGroupCompletion()
+}
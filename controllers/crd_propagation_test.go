@@ -0,0 +1,269 @@
+package controllers
+
+import (
+	"fmt"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func testGroup(name string, status string, dependsOn ...string) *jobsmanagerv1beta1.ManagedJobGroup {
+	group := &jobsmanagerv1beta1.ManagedJobGroup{
+		Name:   name,
+		Status: status,
+		Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+			{Name: "job", Status: status},
+		},
+	}
+	for _, dependency := range dependsOn {
+		group.Dependencies = append(group.Dependencies, &jobsmanagerv1beta1.ManagedJobDependencies{Name: dependency, Status: ExecutionStatusPending})
+	}
+	return group
+}
+
+func groupStatuses(spec *jobsmanagerv1beta1.ManagedJobSpec) map[string]string {
+	statuses := map[string]string{}
+	for _, group := range spec.Groups {
+		statuses[group.Name] = group.Status
+	}
+	return statuses
+}
+
+func TestPropagateStatuses(t *testing.T) {
+	tests := []struct {
+		name     string
+		groups   []*jobsmanagerv1beta1.ManagedJobGroup
+		expected map[string]string
+	}{
+		{
+			name: "chained groups abort in a single pass",
+			groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+				testGroup("a", ExecutionStatusFailed),
+				testGroup("b", ExecutionStatusPending, "a"),
+				testGroup("c", ExecutionStatusPending, "b"),
+			},
+			expected: map[string]string{"a": ExecutionStatusFailed, "b": ExecutionStatusAborted, "c": ExecutionStatusAborted},
+		},
+		{
+			name: "chained groups declared in reverse order",
+			groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+				testGroup("c", ExecutionStatusPending, "b"),
+				testGroup("b", ExecutionStatusPending, "a"),
+				testGroup("a", ExecutionStatusFailed),
+			},
+			expected: map[string]string{"a": ExecutionStatusFailed, "b": ExecutionStatusAborted, "c": ExecutionStatusAborted},
+		},
+		{
+			name: "diamond with one failed branch",
+			groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+				testGroup("a", ExecutionStatusSucceeded),
+				testGroup("b", ExecutionStatusSucceeded, "a"),
+				testGroup("c", ExecutionStatusFailed, "a"),
+				testGroup("d", ExecutionStatusPending, "b", "c"),
+			},
+			expected: map[string]string{"a": ExecutionStatusSucceeded, "b": ExecutionStatusSucceeded, "c": ExecutionStatusFailed, "d": ExecutionStatusAborted},
+		},
+		{
+			name: "diamond with both branches succeeded",
+			groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+				testGroup("a", ExecutionStatusSucceeded),
+				testGroup("b", ExecutionStatusSucceeded, "a"),
+				testGroup("c", ExecutionStatusSucceeded, "a"),
+				testGroup("d", ExecutionStatusPending, "b", "c"),
+			},
+			expected: map[string]string{"a": ExecutionStatusSucceeded, "b": ExecutionStatusSucceeded, "c": ExecutionStatusSucceeded, "d": ExecutionStatusPending},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &jobsmanagerv1beta1.ManagedJobSpec{Groups: tt.groups}
+			propagateStatuses("wf", spec, nil)
+			statuses := groupStatuses(spec)
+			for group, expected := range tt.expected {
+				if statuses[group] != expected {
+					t.Errorf("group %s: expected %s, got %s", group, expected, statuses[group])
+				}
+			}
+
+			// second pass must not change anything
+			before := spec.DeepCopy()
+			propagateStatuses("wf", spec, nil)
+			for i, group := range spec.Groups {
+				for j, dependency := range group.Dependencies {
+					if dependency.Status != before.Groups[i].Dependencies[j].Status {
+						t.Errorf("group %s dependency %s changed on the second pass", group.Name, dependency.Name)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPropagateStatusesDependencies(t *testing.T) {
+	spec := &jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+		testGroup("a", ExecutionStatusSucceeded),
+		testGroup("b", ExecutionStatusAborted, "a"),
+		testGroup("c", ExecutionStatusPending, "a", "b"),
+	}}
+	propagateStatuses("wf", spec, nil)
+
+	expected := map[string]string{"a": ExecutionStatusSucceeded, "b": ExecutionStatusFailed}
+	for _, dependency := range spec.Groups[2].Dependencies {
+		if dependency.Status != expected[dependency.Name] {
+			t.Errorf("dependency %s: expected %s, got %s", dependency.Name, expected[dependency.Name], dependency.Status)
+		}
+	}
+}
+
+func TestPropagateStatusesJobs(t *testing.T) {
+	group := &jobsmanagerv1beta1.ManagedJobGroup{
+		Name:   "group",
+		Status: ExecutionStatusRunning,
+		Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+			{Name: "third", Status: ExecutionStatusPending, Dependencies: []*jobsmanagerv1beta1.ManagedJobDependencies{{Name: "wf-group-second"}}},
+			{Name: "second", Status: ExecutionStatusPending, Dependencies: []*jobsmanagerv1beta1.ManagedJobDependencies{{Name: "wf-group-first"}}},
+			{Name: "first", Status: ExecutionStatusFailed},
+		},
+	}
+	spec := &jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{group}}
+	propagateStatuses("wf", spec, nil)
+
+	for _, job := range group.Jobs[:2] {
+		if job.Status != ExecutionStatusAborted {
+			t.Errorf("job %s: expected %s, got %s", job.Name, ExecutionStatusAborted, job.Status)
+		}
+	}
+	if group.Status != ExecutionStatusFailed {
+		t.Errorf("group: expected %s, got %s", ExecutionStatusFailed, group.Status)
+	}
+}
+
+func TestPropagateStatusesFailureBudget(t *testing.T) {
+	shards := func(failed int, maxFailed intstr.IntOrString) *jobsmanagerv1beta1.ManagedJobGroup {
+		group := &jobsmanagerv1beta1.ManagedJobGroup{
+			Name:          "shards",
+			Status:        ExecutionStatusRunning,
+			Parallel:      true,
+			FailurePolicy: &jobsmanagerv1beta1.ManagedJobFailurePolicy{MaxFailed: maxFailed},
+		}
+		for i := 0; i < 10; i++ {
+			status := ExecutionStatusSucceeded
+			if i < failed {
+				status = ExecutionStatusFailed
+			}
+			group.Jobs = append(group.Jobs, &jobsmanagerv1beta1.ManagedJobDefinition{Name: fmt.Sprintf("shard-%d", i), Status: status})
+		}
+		return group
+	}
+
+	tests := []struct {
+		name     string
+		group    *jobsmanagerv1beta1.ManagedJobGroup
+		expected string
+	}{
+		{"absolute within budget", shards(2, intstr.FromInt(2)), ExecutionStatusSucceeded},
+		{"absolute over budget", shards(3, intstr.FromInt(2)), ExecutionStatusFailed},
+		{"percent within budget", shards(2, intstr.FromString("25%")), ExecutionStatusSucceeded},
+		{"percent rounded down", shards(3, intstr.FromString("25%")), ExecutionStatusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dependent := testGroup("next", ExecutionStatusPending, "shards")
+			spec := &jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{tt.group, dependent}}
+			propagateStatuses("wf", spec, nil)
+			if tt.group.Status != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, tt.group.Status)
+			}
+			if dependent.Dependencies[0].Status != tt.expected {
+				t.Errorf("dependent group sees %s, expected %s", dependent.Dependencies[0].Status, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPropagateStatusesEnabledGroups(t *testing.T) {
+	spec := &jobsmanagerv1beta1.ManagedJobSpec{
+		EnabledGroups: []string{"test"},
+		Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+			testGroup("build", ExecutionStatusPending),
+			testGroup("test", ExecutionStatusPending, "build"),
+			testGroup("deploy", ExecutionStatusPending, "test"),
+		},
+	}
+	propagateStatuses("wf", spec, nil)
+	expected := map[string]string{"build": ExecutionStatusSkipped, "test": ExecutionStatusPending, "deploy": ExecutionStatusSkipped}
+	if statuses := groupStatuses(spec); fmt.Sprint(statuses) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, statuses)
+	}
+	if status := spec.Groups[0].Jobs[0].Status; status != ExecutionStatusSkipped {
+		t.Errorf("expected the job of the skipped group skipped, got %s", status)
+	}
+	if !dependenciesMet(spec.Groups[1].Dependencies) {
+		t.Error("expected the skipped group to count as succeeded for its dependents")
+	}
+
+	spec.Groups[1].Status = ExecutionStatusSucceeded
+	spec.Groups[1].Jobs[0].Status = ExecutionStatusSucceeded
+	propagateStatuses("wf", spec, nil)
+	if status := workflowStatus(spec); status != ExecutionStatusSucceeded {
+		t.Errorf("expected the workflow succeeded with the skipped groups, got %s", status)
+	}
+
+	spec.EnabledGroups = nil
+	propagateStatuses("wf", spec, nil)
+	if status := spec.Groups[2].Status; status != ExecutionStatusPending {
+		t.Errorf("expected the enabled group back to pending, got %s", status)
+	}
+	if status := spec.Groups[2].Jobs[0].Status; status != ExecutionStatusPending {
+		t.Errorf("expected the job of the enabled group back to pending, got %s", status)
+	}
+}
+
+func TestPropagateDependencyConditions(t *testing.T) {
+	withCondition := func(group *jobsmanagerv1beta1.ManagedJobGroup, condition string) *jobsmanagerv1beta1.ManagedJobGroup {
+		for _, dependency := range group.Dependencies {
+			dependency.Condition = condition
+		}
+		return group
+	}
+	spec := &jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+		testGroup("build", ExecutionStatusFailed),
+		testGroup("deploy", ExecutionStatusSucceeded),
+		withCondition(testGroup("on-build-failure", ExecutionStatusPending, "build"), jobsmanagerv1beta1.DependencyConditionFailed),
+		withCondition(testGroup("on-deploy-failure", ExecutionStatusPending, "deploy"), jobsmanagerv1beta1.DependencyConditionFailed),
+		withCondition(testGroup("cleanup", ExecutionStatusPending, "build"), jobsmanagerv1beta1.DependencyConditionFinished),
+		testGroup("publish", ExecutionStatusPending, "build"),
+	}}
+	propagateStatuses("wf", spec, nil)
+
+	expected := map[string]string{
+		"build": ExecutionStatusFailed, "deploy": ExecutionStatusSucceeded,
+		"on-build-failure": ExecutionStatusPending, "on-deploy-failure": ExecutionStatusSkipped,
+		"cleanup": ExecutionStatusPending, "publish": ExecutionStatusAborted,
+	}
+	if got := groupStatuses(spec); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("statuses = %v, expected %v", got, expected)
+	}
+	if reason := spec.Groups[3].Reason; reason != ReasonConditionNotMet {
+		t.Errorf("reason of the skipped handler = %q", reason)
+	}
+	if !dependenciesMet(spec.Groups[2].Dependencies) || !dependenciesMet(spec.Groups[4].Dependencies) {
+		t.Error("expected the failure handler and the cleanup to be runnable")
+	}
+	// the failed run is reported once its handlers finished
+	if status := workflowStatus(spec); status != ExecutionStatusRunning {
+		t.Errorf("workflow status with the pending handlers = %s", status)
+	}
+	spec.Groups[2].Status, spec.Groups[4].Status = ExecutionStatusSucceeded, ExecutionStatusSucceeded
+	if status := workflowStatus(spec); status != ExecutionStatusFailed {
+		t.Errorf("workflow status after the handlers = %s", status)
+	}
+
+	// skipping is not undone by the next pass
+	propagateStatuses("wf", spec, nil)
+	if status := spec.Groups[3].Status; status != ExecutionStatusSkipped {
+		t.Errorf("status of the skipped handler after another pass = %s", status)
+	}
+}
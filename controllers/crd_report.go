@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+/*
+End-of-run reports - workflows annotated with jobsmanager.raczylo.com/run-report get a summary of
+every completed run, stored in the <workflow>-report ConfigMap (configmap) or next to the archived
+logs (storage). Location of the report is kept in the run history.
+*/
+
+const (
+	annotationRunReport       = "jobsmanager.raczylo.com/run-report"
+	annotationRunReportFormat = "jobsmanager.raczylo.com/run-report-format"
+
+	runReportConfigMap = "configmap"
+	runReportStorage   = "storage"
+
+	runReportFormatMarkdown = "markdown"
+	runReportFormatHTML     = "html"
+
+	runReportEventsLimit = 20
+)
+
+type runReport struct {
+	Workflow  string
+	Namespace string
+	RunID     string
+	Status    string
+	Reason    string
+	StartedAt time.Time
+	Duration  time.Duration
+	Cost      string
+	Groups    []runReportGroup
+	Events    []runReportEvent
+}
+
+type runReportGroup struct {
+	Name   string
+	Status string
+	Cost   string
+	Jobs   []runReportJob
+}
+
+type runReportJob struct {
+	Name     string
+	Status   string
+	Duration string
+	Retries  int32
+	Logs     string
+	Cost     string
+}
+
+type runReportEvent struct {
+	Time    string
+	Type    string
+	Reason  string
+	Message string
+}
+
+const runReportMarkdown = `# Workflow {{ .Namespace }}/{{ .Workflow }} - {{ .Status }}
+
+Started {{ .StartedAt.Format "2006-01-02 15:04:05 MST" }}{{ with .Reason }} ({{ . }}){{ end }}, took {{ .Duration }}.{{ with .Cost }} Estimated cost {{ . }}.{{ end }}{{ with .RunID }} Run {{ . }}.{{ end }}
+{{ range .Groups }}
+## {{ .Name }} - {{ .Status }}{{ with .Cost }} (estimated cost {{ . }}){{ end }}
+
+| Job | Status | Duration | Retries | Logs |{{ if $.Cost }} Cost |{{ end }}
+|-----|--------|----------|---------|------|{{ if $.Cost }}------|{{ end }}
+{{- range .Jobs }}
+| {{ .Name }} | {{ .Status }} | {{ .Duration }} | {{ .Retries }} | {{ with .Logs }}[logs]({{ . }}){{ else }}-{{ end }} |{{ if $.Cost }} {{ or .Cost "-" }} |{{ end }}
+{{- end }}
+{{ end }}{{ with .Events }}
+## Events
+
+| Time | Type | Reason | Message |
+|------|------|--------|---------|
+{{- range . }}
+| {{ .Time }} | {{ .Type }} | {{ .Reason }} | {{ .Message }} |
+{{- end }}
+{{ end }}`
+
+const runReportHTML = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{ .Namespace }}/{{ .Workflow }}</title></head>
+<body>
+<h1>Workflow {{ .Namespace }}/{{ .Workflow }} - {{ .Status }}</h1>
+<p>Started {{ .StartedAt.Format "2006-01-02 15:04:05 MST" }}{{ with .Reason }} ({{ . }}){{ end }}, took {{ .Duration }}.{{ with .Cost }} Estimated cost {{ . }}.{{ end }}{{ with .RunID }} Run {{ . }}.{{ end }}</p>
+{{ range .Groups }}
+<h2>{{ .Name }} - {{ .Status }}{{ with .Cost }} (estimated cost {{ . }}){{ end }}</h2>
+<table>
+<tr><th>Job</th><th>Status</th><th>Duration</th><th>Retries</th><th>Logs</th>{{ if $.Cost }}<th>Cost</th>{{ end }}</tr>
+{{- range .Jobs }}
+<tr><td>{{ .Name }}</td><td>{{ .Status }}</td><td>{{ .Duration }}</td><td>{{ .Retries }}</td><td>{{ with .Logs }}<a href="{{ . }}">logs</a>{{ else }}-{{ end }}</td>{{ if $.Cost }}<td>{{ or .Cost "-" }}</td>{{ end }}</tr>
+{{- end }}
+</table>
+{{ end }}{{ with .Events }}
+<h2>Events</h2>
+<table>
+<tr><th>Time</th><th>Type</th><th>Reason</th><th>Message</th></tr>
+{{- range . }}
+<tr><td>{{ .Time }}</td><td>{{ .Type }}</td><td>{{ .Reason }}</td><td>{{ .Message }}</td></tr>
+{{- end }}
+</table>
+{{ end }}</body>
+</html>
+`
+
+var (
+	runReportMarkdownTemplate = texttemplate.Must(texttemplate.New("report").Parse(runReportMarkdown))
+	runReportHTMLTemplate     = htmltemplate.Must(htmltemplate.New("report").Parse(runReportHTML))
+)
+
+// render returns the report in the requested format and the file extension
+func (report *runReport) render(format string) ([]byte, string, error) {
+	var out bytes.Buffer
+	if format == runReportFormatHTML {
+		err := runReportHTMLTemplate.Execute(&out, report)
+		return out.Bytes(), "html", err
+	}
+	// markdown table cells can not contain the pipes and new lines
+	escaper := strings.NewReplacer("|", "\\|", "\n", " ")
+	for i := range report.Events {
+		report.Events[i].Message = escaper.Replace(report.Events[i].Message)
+	}
+	err := runReportMarkdownTemplate.Execute(&out, report)
+	return out.Bytes(), "md", err
+}
+
+// childJobDuration returns how long the Job was running, empty when it has not started
+func childJobDuration(childJob *kbatch.Job) string {
+	if childJob.Status.StartTime == nil {
+		return "-"
+	}
+	finishedAt := childJobFinishedAt(childJob)
+	if finishedAt.IsZero() {
+		return "-"
+	}
+	return finishedAt.Sub(childJob.Status.StartTime.Time).Truncate(time.Second).String()
+}
+
+// childJobFinishedAt returns when the Job completed or failed, zero while it's running
+func childJobFinishedAt(childJob *kbatch.Job) time.Time {
+	finishedAt := time.Time{}
+	if childJob.Status.CompletionTime != nil {
+		finishedAt = childJob.Status.CompletionTime.Time
+	}
+	for _, condition := range childJob.Status.Conditions {
+		if condition.Type == kbatch.JobFailed && condition.Status == corev1.ConditionTrue {
+			finishedAt = condition.LastTransitionTime.Time
+		}
+	}
+	return finishedAt
+}
+
+// collectRunReport gathers the statuses, durations and events of the completed run
+func (cp *connPackage) collectRunReport(run *jobsmanagerv1beta1.ManagedJobRunRecord) *runReport {
+	report := &runReport{
+		Workflow:  cp.mj.Name,
+		Namespace: cp.mj.Namespace,
+		RunID:     run.ID,
+		Status:    run.Status,
+		Reason:    run.Reason,
+		StartedAt: run.StartedAt.Time,
+		Duration:  run.CompletedAt.Sub(run.StartedAt.Time).Truncate(time.Second),
+		Cost:      run.EstimatedCost,
+	}
+
+	childJobs := map[string]*kbatch.Job{}
+	var childJobList kbatch.JobList
+	labelSelector := labels.SelectorFromSet(labels.Set{labelWorkflowName: cp.mj.Name})
+	err := cp.client.List(cp.ctx, &childJobList, &client.ListOptions{LabelSelector: labelSelector, Namespace: cp.mj.Namespace})
+	if err != nil {
+		log.Log.Info("Unable to list child jobs", "error", err.Error())
+	}
+	for i := range childJobList.Items {
+		if !childJobList.Items[i].CreationTimestamp.Before(&run.StartedAt) {
+			childJobs[childJobList.Items[i].Name] = &childJobList.Items[i]
+		}
+	}
+
+	for _, group := range cp.mj.Spec.Groups {
+		reportGroup := runReportGroup{Name: group.Name, Status: group.Status, Cost: group.EstimatedCost()}
+		for _, job := range group.Jobs {
+			reportJob := runReportJob{Name: job.Name, Status: job.Status, Duration: "-", Logs: job.ArchivedLogs, Cost: job.EstimatedCost}
+			if childJob, found := childJobs[jobNameGenerator(cp.mj.Name, group.Name, job.Name)]; found {
+				reportJob.Duration = childJobDuration(childJob)
+				reportJob.Retries = childJob.Status.Failed
+			}
+			reportGroup.Jobs = append(reportGroup.Jobs, reportJob)
+		}
+		report.Groups = append(report.Groups, reportGroup)
+	}
+
+	if cp.r.Clientset != nil {
+		events, err := cp.r.Clientset.CoreV1().Events(cp.mj.Namespace).List(cp.ctx, metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("involvedObject.uid", string(cp.mj.UID)).String(),
+		})
+		if err != nil {
+			log.Log.Info("Unable to list workflow events", "error", err.Error())
+		} else {
+			report.Events = runReportEvents(events.Items, run.StartedAt.Time)
+		}
+	}
+	return report
+}
+
+// runReportEvents returns the latest events of the run in the chronological order
+func runReportEvents(events []corev1.Event, startedAt time.Time) []runReportEvent {
+	eventTime := func(event corev1.Event) time.Time {
+		if !event.LastTimestamp.IsZero() {
+			return event.LastTimestamp.Time
+		}
+		return event.EventTime.Time
+	}
+	sort.SliceStable(events, func(i, j int) bool { return eventTime(events[i]).Before(eventTime(events[j])) })
+
+	reportEvents := []runReportEvent{}
+	for _, event := range events {
+		if eventTime(event).Before(startedAt) {
+			continue
+		}
+		reportEvents = append(reportEvents, runReportEvent{
+			Time:    eventTime(event).Format(time.RFC3339),
+			Type:    event.Type,
+			Reason:  event.Reason,
+			Message: event.Message,
+		})
+	}
+	if len(reportEvents) > runReportEventsLimit {
+		reportEvents = reportEvents[len(reportEvents)-runReportEventsLimit:]
+	}
+	return reportEvents
+}
+
+// publishRunReport stores the report of the completed run and records its location in the run history
+func (cp *connPackage) publishRunReport(run *jobsmanagerv1beta1.ManagedJobRunRecord) {
+	target := cp.mj.Annotations[annotationRunReport]
+	if target != runReportConfigMap && target != runReportStorage {
+		return
+	}
+	content, extension, err := cp.collectRunReport(run).render(cp.mj.Annotations[annotationRunReportFormat])
+	if err != nil {
+		log.Log.Info("Unable to render run report", "error", err.Error())
+		return
+	}
+
+	if target == runReportStorage {
+		if cp.r.LogArchiver == nil {
+			cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "ReportFailed", "Run report requires the operator to be started with --log-archive-url")
+			return
+		}
+		key := joinObjectKey(cp.mj.Namespace, cp.mj.Name, string(cp.mj.UID), "reports", run.StartedAt.UTC().Format("20060102T150405Z")+"."+extension)
+		reportURL, err := cp.r.LogArchiver.Archive(cp.ctx, key, content)
+		if err != nil {
+			cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "ReportFailed", "Unable to store run report: %s", err.Error())
+			return
+		}
+		run.Report = reportURL
+		return
+	}
+
+	ownerReference, err := cp.getOwnerReference()
+	if err != nil {
+		log.Log.Info("Unable to get owner reference", "error", err.Error())
+		return
+	}
+	reportConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            cp.mj.Name + "-report",
+			Namespace:       cp.mj.Namespace,
+			Labels:          map[string]string{labelWorkflowName: cp.mj.Name},
+			OwnerReferences: []metav1.OwnerReference{ownerReference},
+		},
+		Data: map[string]string{"report." + extension: string(content)},
+	}
+	err = cp.client.Create(cp.ctx, reportConfigMap)
+	if apierrors.IsAlreadyExists(err) {
+		// only the latest report is kept
+		err = cp.client.Update(cp.ctx, reportConfigMap)
+	}
+	if err != nil {
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "ReportFailed", "Unable to store run report: %s", err.Error())
+		return
+	}
+	run.Report = "ConfigMap/" + reportConfigMap.Name
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRunReport() *runReport {
+	return &runReport{
+		Workflow:  "nightly",
+		Namespace: "etl",
+		Status:    ExecutionStatusFailed,
+		StartedAt: time.Date(2023, 9, 1, 2, 0, 0, 0, time.UTC),
+		Duration:  90 * time.Minute,
+		Groups: []runReportGroup{{
+			Name:   "extract",
+			Status: ExecutionStatusFailed,
+			Jobs: []runReportJob{
+				{Name: "download", Status: ExecutionStatusSucceeded, Duration: "10m0s", Logs: "https://logs.example.com/download.log"},
+				{Name: "parse", Status: ExecutionStatusFailed, Duration: "1m0s", Retries: 3},
+			},
+		}},
+		Events: []runReportEvent{{Time: "2023-09-01T03:30:00Z", Type: "Warning", Reason: "Failed", Message: "Job a|b failed\nbadly"}},
+	}
+}
+
+func TestRunReportMarkdown(t *testing.T) {
+	content, extension, err := testRunReport().render(runReportFormatMarkdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extension != "md" {
+		t.Errorf("expected md extension, got %s", extension)
+	}
+	for _, expected := range []string{
+		"# Workflow etl/nightly - failed",
+		"took 1h30m0s",
+		"## extract - failed",
+		"| download | succeeded | 10m0s | 0 | [logs](https://logs.example.com/download.log) |",
+		"| parse | failed | 1m0s | 3 | - |",
+		"| 2023-09-01T03:30:00Z | Warning | Failed | Job a\\|b failed badly |",
+	} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("report is missing %q:\n%s", expected, content)
+		}
+	}
+}
+
+func TestRunReportHTML(t *testing.T) {
+	report := testRunReport()
+	report.Groups[0].Jobs[0].Name = "<script>"
+	content, extension, err := report.render(runReportFormatHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extension != "html" {
+		t.Errorf("expected html extension, got %s", extension)
+	}
+	if strings.Contains(string(content), "<script>") {
+		t.Error("job names must be escaped in the HTML report")
+	}
+	if !strings.Contains(string(content), `<a href="https://logs.example.com/download.log">logs</a>`) {
+		t.Errorf("report is missing the logs link:\n%s", content)
+	}
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func compiledResources(params jobsmanagerv1beta1.ManagedJobParameters) corev1.ResourceRequirements {
+	if params.Resources == nil {
+		return corev1.ResourceRequirements{}
+	}
+	return *params.Resources.DeepCopy()
+}
+
+func addResourceList(total corev1.ResourceList, add corev1.ResourceList) {
+	for name, quantity := range add {
+		current := total[name]
+		current.Add(quantity)
+		total[name] = current
+	}
+}
+
+// maxResourceList raises the quantities of the total to the ones of the list which are larger
+func maxResourceList(total corev1.ResourceList, list corev1.ResourceList) {
+	for name, quantity := range list {
+		if current, found := total[name]; !found || quantity.Cmp(current) > 0 {
+			total[name] = quantity.DeepCopy()
+		}
+	}
+}
+
+// resourceNames returns the names of the list sorted, the ranges over the list are in random order
+func resourceNames(list corev1.ResourceList) []corev1.ResourceName {
+	names := []corev1.ResourceName{}
+	for name := range list {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// aggregateResources sums up the requests of the active and all the jobs in the workflow into its status
+func (cp *connPackage) aggregateResources() {
+	active := corev1.ResourceList{}
+	total := corev1.ResourceList{}
+	for _, group := range cp.mj.Spec.Groups {
+		for _, job := range group.Jobs {
+			params, _ := cp.jobParameters(group, job)
+			requests := compiledResources(params).Requests
+			addResourceList(total, requests)
+			if job.Status == ExecutionStatusRunning {
+				addResourceList(active, requests)
+			}
+		}
+	}
+	cp.mj.Status.AggregatedResources = &jobsmanagerv1beta1.ManagedJobResourcesSummary{
+		Active: active,
+		Total:  total,
+	}
+
+	for _, resourceName := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		activeQuantity := active[resourceName]
+		totalQuantity := total[resourceName]
+		requestedResourcesGauge.WithLabelValues(cp.mj.Namespace, cp.mj.Name, string(resourceName), "active").Set(activeQuantity.AsApproximateFloat64())
+		requestedResourcesGauge.WithLabelValues(cp.mj.Namespace, cp.mj.Name, string(resourceName), "total").Set(totalQuantity.AsApproximateFloat64())
+	}
+}
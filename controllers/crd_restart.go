@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+
+	"github.com/lukaszraczylo/pandati"
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+/* Restarting the workflow when the referenced ConfigMaps or Secrets change */
+
+const (
+	restartOnIndexKey = ".spec.restartOn"
+	runHistoryLimit   = 10
+)
+
+func restartTriggerKey(kind string, name string) string {
+	return kind + "/" + name
+}
+
+// restartOnIndexer indexes workflows by the objects they restart on
+func restartOnIndexer(obj client.Object) []string {
+	mj := obj.(*jobsmanagerv1beta1.ManagedJob)
+	keys := []string{}
+	for _, trigger := range mj.Spec.RestartOn {
+		keys = append(keys, restartTriggerKey(trigger.Kind, trigger.Name))
+	}
+	return keys
+}
+
+// workflowsForTriggerObject maps the changed ConfigMap or Secret to the workflows restarting on it
+func (r *ManagedJobReconciler) workflowsForTriggerObject(kind string) func(ctx context.Context, obj client.Object) []reconcile.Request {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		var workflows jobsmanagerv1beta1.ManagedJobList
+		err := r.List(ctx, &workflows, client.InNamespace(obj.GetNamespace()), client.MatchingFields{restartOnIndexKey: restartTriggerKey(kind, obj.GetName())})
+		if err != nil {
+			log.Log.Info("Unable to list workflows for restart trigger", "error", err.Error())
+			return nil
+		}
+		requests := []reconcile.Request{}
+		for _, workflow := range workflows.Items {
+			key := types.NamespacedName{Namespace: workflow.Namespace, Name: workflow.Name}
+			// the trigger object is not part of the fingerprint of the workflow
+			r.syncFingerprints.forget(key)
+			requests = append(requests, reconcile.Request{NamespacedName: key})
+		}
+		return requests
+	}
+}
+
+func checksumData(data map[string][]byte) string {
+	keys := []string{}
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%d:", k, len(data[k]))
+		h.Write(data[k])
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// triggerChecksum returns the checksum of the referenced object data and its resource version
+func (cp *connPackage) triggerChecksum(trigger jobsmanagerv1beta1.ManagedJobRestartTrigger) (string, string, error) {
+	key := types.NamespacedName{Namespace: cp.mj.Namespace, Name: trigger.Name}
+	data := map[string][]byte{}
+	switch trigger.Kind {
+	case "ConfigMap":
+		configMap := &corev1.ConfigMap{}
+		if err := cp.client.Get(cp.ctx, key, configMap); err != nil {
+			return "", "", err
+		}
+		for k, v := range configMap.Data {
+			data[k] = []byte(v)
+		}
+		for k, v := range configMap.BinaryData {
+			data[k] = v
+		}
+		return checksumData(data), configMap.ResourceVersion, nil
+	case "Secret":
+		secret := &corev1.Secret{}
+		if err := cp.client.Get(cp.ctx, key, secret); err != nil {
+			return "", "", err
+		}
+		return checksumData(secret.Data), secret.ResourceVersion, nil
+	}
+	return "", "", fmt.Errorf("unsupported restart trigger kind %s", trigger.Kind)
+}
+
+// checkRestartTriggers restarts the workflow (or its groups) when the referenced objects changed since the last check
+func (cp *connPackage) checkRestartTriggers() {
+	if len(cp.mj.Spec.RestartOn) == 0 {
+		return
+	}
+	if cp.mj.Spec.ObservedTriggers == nil {
+		cp.mj.Spec.ObservedTriggers = map[string]string{}
+	}
+	for _, trigger := range cp.mj.Spec.RestartOn {
+		triggerKey := restartTriggerKey(trigger.Kind, trigger.Name)
+		checksum, resourceVersion, err := cp.triggerChecksum(trigger)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				log.Log.Info("Unable to check restart trigger", "trigger", triggerKey, "error", err.Error())
+			}
+			continue
+		}
+		observed, seen := cp.mj.Spec.ObservedTriggers[triggerKey]
+		cp.mj.Spec.ObservedTriggers[triggerKey] = checksum
+		if !seen || observed == checksum {
+			continue
+		}
+
+		cp.restartGroups(trigger.Groups)
+		cp.recordRun(jobsmanagerv1beta1.ManagedJobRunRecord{
+			StartedAt:       metav1.NewTime(cp.now()),
+			Reason:          "RestartTrigger",
+			TriggeredBy:     triggerKey,
+			ResourceVersion: resourceVersion,
+		})
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Restarted", "Workflow restarted as %s changed (resourceVersion %s)", triggerKey, resourceVersion)
+	}
+}
+
+func (cp *connPackage) recordRun(record jobsmanagerv1beta1.ManagedJobRunRecord) {
+	if record.ID == "" {
+		record.ID = cp.newRunID()
+	}
+	log.Log.Info("Run started", "workflow", cp.mj.Name, "namespace", cp.mj.Namespace, "run", record.ID, "reason", record.Reason)
+	cp.mj.Spec.RunHistory = append(cp.mj.Spec.RunHistory, record)
+	if len(cp.mj.Spec.RunHistory) > runHistoryLimit {
+		cp.mj.Spec.RunHistory = cp.mj.Spec.RunHistory[len(cp.mj.Spec.RunHistory)-runHistoryLimit:]
+	}
+}
+
+// currentRunStartedAt returns the start of the latest run, child jobs created before belong to the previous runs
+func (cp *connPackage) currentRunStartedAt() metav1.Time {
+	if len(cp.mj.Spec.RunHistory) == 0 {
+		return metav1.Time{}
+	}
+	return cp.mj.Spec.RunHistory[len(cp.mj.Spec.RunHistory)-1].StartedAt
+}
+
+func resetJobState(job *jobsmanagerv1beta1.ManagedJobDefinition) {
+	job.Status = ExecutionStatusPending
+	job.ArchivedLogs = ""
+	job.ArchivePending = false
+	job.ResolvedSpecHash = ""
+	job.FanOutSummary = ""
+	job.Drift = ""
+	job.EstimatedCost = ""
+	job.Reason = ""
+	job.AttemptChanges = ""
+	consumeOutcome(job)
+	job.ImagePullRefreshedAt = nil
+	job.SchedulingLatency = nil
+	job.SlowScheduling = ""
+	for _, dependency := range job.Dependencies {
+		dependency.Status = ExecutionStatusPending
+	}
+}
+
+func resetGroupState(group *jobsmanagerv1beta1.ManagedJobGroup) {
+	group.Status = ExecutionStatusPending
+	group.Reason = ""
+	consumeApproval(group)
+	group.ReadyAt = nil
+	group.CompletedAt = nil
+	for _, dependency := range group.Dependencies {
+		dependency.Status = ExecutionStatusPending
+	}
+	for _, job := range group.Jobs {
+		resetJobState(job)
+	}
+}
+
+// restartGroups resets the groups to pending and removes the jobs created in the previous run
+func (cp *connPackage) restartGroups(groupNames []string) {
+	for _, group := range cp.mj.Spec.Groups {
+		if len(groupNames) > 0 && !pandati.ExistsInSlice(groupNames, group.Name) {
+			continue
+		}
+		for _, job := range group.Jobs {
+			cp.recordDecision(decision{Action: DecisionRetry, Group: group.Name, Job: job.Name, Reason: "workflow restarted"})
+			cp.deletePreviousJob(group, job)
+		}
+		resetGroupState(group)
+	}
+	cp.propagateStatuses()
+	cp.mj.Status.Phase = ExecutionStatusRunning
+}
+
+// deletePreviousJob deletes the Job or the sub-workflow created for the job, together with its pods
+func (cp *connPackage) deletePreviousJob(group *jobsmanagerv1beta1.ManagedJobGroup, job *jobsmanagerv1beta1.ManagedJobDefinition) {
+	generatedJobName := jobNameGenerator(cp.mj.Name, group.Name, job.Name)
+	var previous client.Object = &kbatch.Job{}
+	if job.Type == JobTypeWorkflow {
+		previous = &jobsmanagerv1beta1.ManagedJob{}
+	}
+	previous.SetName(generatedJobName)
+	previous.SetNamespace(cp.mj.Namespace)
+	err := cp.client.Delete(cp.ctx, previous, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Log.Info("Unable to delete previous job", "job", generatedJobName, "error", err.Error())
+	}
+	if job.Type != JobTypeScript {
+		return
+	}
+	// the next run mounts the script as it is then
+	script := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: generatedJobName + "-script", Namespace: cp.mj.Namespace}}
+	if err := cp.client.Delete(cp.ctx, script); err != nil && !apierrors.IsNotFound(err) {
+		log.Log.Info("Unable to delete previous script", "job", generatedJobName, "error", err.Error())
+	}
+}
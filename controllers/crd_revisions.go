@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+)
+
+/*
+Revision history - every change of the workflow definition is kept in its own ConfigMap,
+<workflow>-revision-<n>, owned by the workflow. The last RevisionHistoryLimit revisions are kept,
+`kubectl managedjob rollback` restores one of them and the rollback is recorded as the next revision.
+*/
+
+const DefaultRevisionHistoryLimit = 10
+
+// recordRevision stores the definition of the workflow once it changed since the last revision
+func (cp *connPackage) recordRevision() {
+	if cp.r.RevisionHistoryLimit <= 0 {
+		return
+	}
+	hash, err := cp.mj.DefinitionHash()
+	if err != nil || cp.mj.Annotations[jobsmanagerv1beta1.RevisionHashAnnotation] == hash {
+		return
+	}
+	definition, err := yaml.Marshal(cp.mj.Definition())
+	if err != nil {
+		cp.reconcileError(err)
+		return
+	}
+	owner, err := cp.getOwnerReference()
+	if err != nil {
+		cp.reconcileError(err)
+		return
+	}
+	previous, _ := strconv.ParseInt(cp.mj.Annotations[jobsmanagerv1beta1.RevisionAnnotation], 10, 64)
+	revision := previous + 1
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobsmanagerv1beta1.RevisionConfigMapName(cp.mj.Name, revision),
+			Namespace: cp.mj.Namespace,
+			Labels: map[string]string{
+				labelWorkflowName:                cp.mj.Name,
+				jobsmanagerv1beta1.RevisionLabel: strconv.FormatInt(revision, 10),
+			},
+			Annotations:     map[string]string{jobsmanagerv1beta1.RevisionHashAnnotation: hash},
+			OwnerReferences: []metav1.OwnerReference{owner},
+		},
+		Data: map[string]string{jobsmanagerv1beta1.RevisionDataKey: string(definition)},
+	}
+	err = cp.client.Create(cp.ctx, configMap)
+	if apierrors.IsAlreadyExists(err) {
+		// left by the revision which update of the workflow was lost
+		existing := &corev1.ConfigMap{}
+		if err = cp.client.Get(cp.ctx, client.ObjectKeyFromObject(configMap), existing); err == nil {
+			configMap.ResourceVersion = existing.ResourceVersion
+			err = cp.client.Update(cp.ctx, configMap)
+		}
+	}
+	if err != nil {
+		log.Log.Info("Unable to record the revision", "workflow", cp.mj.Name, "revision", revision, "error", err.Error())
+		cp.reconcileError(err)
+		return
+	}
+	if cp.mj.Annotations == nil {
+		cp.mj.Annotations = map[string]string{}
+	}
+	cp.mj.Annotations[jobsmanagerv1beta1.RevisionAnnotation] = strconv.FormatInt(revision, 10)
+	cp.mj.Annotations[jobsmanagerv1beta1.RevisionHashAnnotation] = hash
+	log.Log.V(1).Info("Revision recorded", "workflow", cp.mj.Name, "revision", revision)
+	cp.pruneRevisions(revision)
+}
+
+// pruneRevisions deletes the revisions beyond the history limit
+func (cp *connPackage) pruneRevisions(current int64) {
+	recorded, _ := labels.NewRequirement(jobsmanagerv1beta1.RevisionLabel, selection.Exists, nil)
+	selector := labels.SelectorFromSet(labels.Set{labelWorkflowName: cp.mj.Name}).Add(*recorded)
+	// the revisions are pruned by their labels, the ConfigMaps are cached by their metadata only
+	configMaps := &metav1.PartialObjectMetadataList{}
+	configMaps.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMapList"))
+	if err := cp.client.List(cp.ctx, configMaps, &client.ListOptions{Namespace: cp.mj.Namespace, LabelSelector: selector}); err != nil {
+		log.Log.Info("Unable to list the revisions", "workflow", cp.mj.Name, "error", err.Error())
+		cp.reconcileError(err)
+		return
+	}
+	for i := range configMaps.Items {
+		revision, err := strconv.ParseInt(configMaps.Items[i].Labels[jobsmanagerv1beta1.RevisionLabel], 10, 64)
+		if err != nil || revision > current-int64(cp.r.RevisionHistoryLimit) {
+			continue
+		}
+		configMaps.Items[i].SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+		if err := cp.client.Delete(cp.ctx, &configMaps.Items[i]); client.IgnoreNotFound(err) != nil {
+			cp.reconcileError(err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+func TestRecordRevision(t *testing.T) {
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "etl", UID: "3f1c"},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{Retries: 1, Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{
+			Name: "extract", Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "download", Image: "busybox:1.36"}},
+		}}},
+	}
+	c := newTestClientBuilder(mj).Build()
+	cp := newTestConnPackageWithClient(&ManagedJobReconciler{RevisionHistoryLimit: 2}, mj, c)
+	revisions := func() []string {
+		var configMaps corev1.ConfigMapList
+		_ = c.List(context.Background(), &configMaps, client.InNamespace("etl"))
+		names := []string{}
+		for _, configMap := range configMaps.Items {
+			names = append(names, configMap.Name)
+		}
+		return names
+	}
+
+	cp.recordRevision()
+	if mj.Annotations[jobsmanagerv1beta1.RevisionAnnotation] != "1" {
+		t.Fatalf("revision = %q", mj.Annotations[jobsmanagerv1beta1.RevisionAnnotation])
+	}
+	first := &corev1.ConfigMap{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "etl", Name: "nightly-revision-1"}, first); err != nil {
+		t.Fatal(err)
+	}
+	definition := &jobsmanagerv1beta1.ManagedJobSpec{}
+	if err := yaml.Unmarshal([]byte(first.Data[jobsmanagerv1beta1.RevisionDataKey]), definition); err != nil || definition.Groups[0].Jobs[0].Image != "busybox:1.36" {
+		t.Errorf("definition of the revision = %+v, %v", definition, err)
+	}
+
+	// the runtime state is not a new revision
+	mj.Spec.Groups[0].Jobs[0].Status = ExecutionStatusRunning
+	cp.recordRevision()
+	if mj.Annotations[jobsmanagerv1beta1.RevisionAnnotation] != "1" {
+		t.Errorf("status change recorded as the revision %s", mj.Annotations[jobsmanagerv1beta1.RevisionAnnotation])
+	}
+
+	for _, image := range []string{"busybox:1.37", "busybox:1.38"} {
+		mj.Spec.Groups[0].Jobs[0].Image = image
+		cp.recordRevision()
+	}
+	if mj.Annotations[jobsmanagerv1beta1.RevisionAnnotation] != "3" {
+		t.Errorf("revision = %q, expected 3", mj.Annotations[jobsmanagerv1beta1.RevisionAnnotation])
+	}
+	if names := revisions(); len(names) != 2 || names[0] != "nightly-revision-2" || names[1] != "nightly-revision-3" {
+		t.Errorf("revisions kept = %v", names)
+	}
+}
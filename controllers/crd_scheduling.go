@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+/*
+Pod scheduling latency - the time from the creation of the Job to its first running pod is recorded in
+the job and in the histogram of the operator. The job which pods do not run within the threshold of the
+operator gets the SlowScheduling event with the reasons of its pending pods, e.g. the insufficient CPU
+reported by the scheduler, so the workflow which appears stuck tells why.
+*/
+
+const (
+	ReasonSlowScheduling           = "SlowScheduling"
+	DefaultSlowSchedulingThreshold = 5 * time.Minute
+
+	MetricPodSchedulingLatency = "managedjob_pod_scheduling_seconds"
+)
+
+var podSchedulingLatencyHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name: MetricPodSchedulingLatency,
+	Help: "Time from the creation of the Job to its first running pod",
+	// a second to about an hour
+	Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+}, MetricLabels[MetricPodSchedulingLatency])
+
+func init() {
+	metrics.Registry.MustRegister(podSchedulingLatencyHistogram)
+}
+
+// podRunningSince returns when the first container of the pod started, pending pods have not
+func podRunningSince(pod *corev1.Pod) (time.Time, bool) {
+	if pod.Status.Phase == corev1.PodPending || pod.Status.Phase == "" {
+		return time.Time{}, false
+	}
+	since := time.Time{}
+	for _, status := range append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+		started := metav1.Time{}
+		switch {
+		case status.State.Running != nil:
+			started = status.State.Running.StartedAt
+		case status.State.Terminated != nil:
+			started = status.State.Terminated.StartedAt
+		}
+		if !started.IsZero() && (since.IsZero() || started.Time.Before(since)) {
+			since = started.Time
+		}
+	}
+	if since.IsZero() && pod.Status.StartTime != nil {
+		since = pod.Status.StartTime.Time
+	}
+	return since, !since.IsZero()
+}
+
+// podPendingReasons explains why the pod does not run, the scheduler and the kubelet report it differently
+func podPendingReasons(pod *corev1.Pod) []string {
+	reasons := []string{}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse {
+			reasons = append(reasons, strings.TrimSpace(fmt.Sprintf("%s %s", condition.Reason, condition.Message)))
+		}
+	}
+	for _, status := range append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+		if waiting := status.State.Waiting; waiting != nil && waiting.Reason != "" {
+			reasons = append(reasons, strings.TrimSpace(fmt.Sprintf("container %s %s %s", status.Name, waiting.Reason, waiting.Message)))
+		}
+	}
+	if len(reasons) == 0 {
+		reasons = append(reasons, fmt.Sprintf("pod %s is %s", pod.Name, strings.ToLower(string(pod.Status.Phase))))
+	}
+	return reasons
+}
+
+// checkPodScheduling records when the running jobs got their first running pod and reports the ones
+// which pods are not running within the slow scheduling threshold
+func (cp *connPackage) checkPodScheduling() {
+	waiting := map[string]bool{}
+	for _, group := range cp.mj.Spec.Groups {
+		for _, job := range group.Jobs {
+			if job.Status == ExecutionStatusRunning && job.SchedulingLatency == nil {
+				waiting[jobNameGenerator(cp.mj.Name, group.Name, job.Name)] = true
+			}
+		}
+	}
+	if len(waiting) == 0 {
+		return
+	}
+
+	selector := &client.ListOptions{LabelSelector: labels.SelectorFromSet(labels.Set{labelWorkflowName: cp.mj.Name}), Namespace: cp.mj.Namespace}
+	var childJobs kbatch.JobList
+	if err := cp.client.List(cp.ctx, &childJobs, selector); err != nil {
+		log.Log.Info("Unable to list child jobs", "error", err.Error())
+		return
+	}
+	var pods corev1.PodList
+	if err := cp.client.List(cp.ctx, &pods, selector); err != nil {
+		log.Log.Info("Unable to list workflow pods", "error", err.Error())
+		return
+	}
+	podsOf := map[string][]*corev1.Pod{}
+	for i := range pods.Items {
+		podsOf[pods.Items[i].Labels[labelJobName]] = append(podsOf[pods.Items[i].Labels[labelJobName]], &pods.Items[i])
+	}
+	createdAt := map[string]time.Time{}
+	for _, childJob := range childJobs.Items {
+		createdAt[childJob.Name] = childJob.CreationTimestamp.Time
+	}
+
+	threshold := cp.r.SlowSchedulingThreshold
+	for _, group := range cp.mj.Spec.Groups {
+		for _, job := range group.Jobs {
+			generatedJobName := jobNameGenerator(cp.mj.Name, group.Name, job.Name)
+			created, found := createdAt[generatedJobName]
+			if !waiting[generatedJobName] || !found {
+				continue // custom executors and sub-workflows run no Job
+			}
+			running := time.Time{}
+			for _, pod := range podsOf[generatedJobName] {
+				if since, started := podRunningSince(pod); started && (running.IsZero() || since.Before(running)) {
+					running = since
+				}
+			}
+			if !running.IsZero() {
+				latency := running.Sub(created)
+				job.SchedulingLatency = &metav1.Duration{Duration: latency}
+				podSchedulingLatencyHistogram.With(prometheus.Labels{"namespace": cp.mj.Namespace, "workflow": cp.mj.Name}).Observe(latency.Seconds())
+				continue
+			}
+			if threshold <= 0 || job.SlowScheduling != "" {
+				continue
+			}
+			if remaining := created.Add(threshold).Sub(cp.now()); remaining > 0 {
+				cp.requeueIn(remaining)
+				continue
+			}
+			reasons := []string{}
+			for _, pod := range podsOf[generatedJobName] {
+				reasons = append(reasons, podPendingReasons(pod)...)
+			}
+			if len(reasons) == 0 {
+				reasons = append(reasons, "no pod created, check the events of the Job")
+			}
+			job.SlowScheduling = strings.Join(reasons, "; ")
+			cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, ReasonSlowScheduling, "Pods of job %s are not running after %s: %s",
+				generatedJobName, threshold, job.SlowScheduling)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestCheckPodScheduling(t *testing.T) {
+	created := time.Date(2023, 9, 1, 2, 0, 0, 0, time.UTC)
+	clock := clocktesting.NewFakeClock(created.Add(time.Minute))
+	childLabels := func(job string) map[string]string {
+		return map[string]string{labelWorkflowName: "nightly", labelJobName: "nightly-extract-" + job}
+	}
+	childJob := func(job string) *kbatch.Job {
+		return &kbatch.Job{ObjectMeta: metav1.ObjectMeta{Name: "nightly-extract-" + job, Namespace: "etl", CreationTimestamp: metav1.NewTime(created), Labels: childLabels(job)}}
+	}
+	unschedulable := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "download-abc", Namespace: "etl", Labels: childLabels("download")},
+		Status: corev1.PodStatus{Phase: corev1.PodPending, Conditions: []corev1.PodCondition{{
+			Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: "Unschedulable", Message: "0/3 nodes are available: 3 Insufficient cpu.",
+		}}},
+	}
+	running := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "transform-abc", Namespace: "etl", Labels: childLabels("transform")},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{{
+			State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: metav1.NewTime(created.Add(40 * time.Second))}},
+		}}},
+	}
+	c := newTestClientBuilder(childJob("download"), childJob("transform"), unschedulable, running).Build()
+
+	download := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "download", Status: ExecutionStatusRunning}
+	transform := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "transform", Status: ExecutionStatusRunning}
+	recorder := record.NewFakeRecorder(10)
+	cp := newTestConnPackageWithClient(&ManagedJobReconciler{Recorder: recorder, Clock: clock, SlowSchedulingThreshold: 5 * time.Minute}, &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "etl"},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{
+			Name: "extract", Status: ExecutionStatusRunning, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{download, transform},
+		}}},
+	}, c)
+
+	cp.checkPodScheduling()
+	if transform.SchedulingLatency == nil || transform.SchedulingLatency.Duration != 40*time.Second {
+		t.Errorf("scheduling latency of the running job = %v", transform.SchedulingLatency)
+	}
+	if download.SchedulingLatency != nil || download.SlowScheduling != "" || cp.requeueAfter != 4*time.Minute {
+		t.Errorf("expected the pending job checked again at the threshold, got %v %q, requeue in %s", download.SchedulingLatency, download.SlowScheduling, cp.requeueAfter)
+	}
+
+	clock.Step(5 * time.Minute)
+	cp.checkPodScheduling()
+	if download.SlowScheduling != "Unschedulable 0/3 nodes are available: 3 Insufficient cpu." {
+		t.Errorf("slow scheduling of the pending job = %q", download.SlowScheduling)
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Warning SlowScheduling Pods of job nightly-extract-download are not running after 5m0s") {
+		t.Errorf("unexpected event %q", event)
+	}
+	// reported once per run
+	cp.checkPodScheduling()
+	if len(recorder.Events) != 0 {
+		t.Errorf("unexpected events %d", len(recorder.Events))
+	}
+}
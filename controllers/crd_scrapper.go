@@ -1,14 +1,20 @@
 package controllers
 
 import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/lukaszraczylo/pandati"
 	kbatch "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/pkg/dependencies"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -25,6 +31,11 @@ type compiledParams struct {
 	ImagePullPolicy  string
 	Labels           map[string]string
 	Annotations      map[string]string
+	Resources        *corev1.ResourceRequirements
+	WorkingDir       string
+	RunAsUser        *int64
+	RunAsGroup       *int64
+	SecretsFrom      *jobsmanagerv1beta1.ManagedJobSecretsFrom
 }
 
 func (cp *connPackage) compileParameters(params ...jobsmanagerv1beta1.ManagedJobParameters) jobsmanagerv1beta1.ManagedJobParameters {
@@ -35,13 +46,13 @@ func (cp *connPackage) compileParameters(params ...jobsmanagerv1beta1.ManagedJob
 				cparams.FromEnv = append(cparams.FromEnv, params.FromEnv...)
 			}
 			if params.Env != nil {
-				cparams.Env = append(cparams.Env, params.Env...)
+				cparams.Env = mergeEnv(cparams.Env, params.Env)
 			}
 			if params.Volumes != nil {
-				cparams.Volumes = append(cparams.Volumes, params.Volumes...)
+				cparams.Volumes = mergeVolumes(cparams.Volumes, params.Volumes)
 			}
 			if params.VolumeMounts != nil {
-				cparams.VolumeMounts = append(cparams.VolumeMounts, params.VolumeMounts...)
+				cparams.VolumeMounts = mergeVolumeMounts(cparams.VolumeMounts, params.VolumeMounts)
 			}
 			if params.ServiceAccount != "" {
 				cparams.ServiceAccount = params.ServiceAccount
@@ -67,42 +78,104 @@ func (cp *connPackage) compileParameters(params ...jobsmanagerv1beta1.ManagedJob
 					cparams.Annotations[k] = v
 				}
 			}
+			if params.Resources != nil {
+				cparams.Resources = params.Resources.DeepCopy()
+			}
+			if params.WorkingDir != "" {
+				cparams.WorkingDir = params.WorkingDir
+			}
+			if params.RunAsUser != nil {
+				runAsUser := *params.RunAsUser
+				cparams.RunAsUser = &runAsUser
+			}
+			if params.RunAsGroup != nil {
+				runAsGroup := *params.RunAsGroup
+				cparams.RunAsGroup = &runAsGroup
+			}
+			if params.SecretsFrom != nil {
+				cparams.SecretsFrom = params.SecretsFrom.DeepCopy()
+			}
 		}
 	}
+	// empty restart policy produces an invalid Job, it's defaulted only here so the lower levels
+	// can still override whatever was set above them
+	if cparams.RestartPolicy == "" {
+		cparams.RestartPolicy = string(jobsmanagerv1beta1.DefaultRestartPolicy)
+	}
 	return cparams
 }
 
-func (cp *connPackage) updateDependentJobs(completedJob string, jobStatus string) {
-	for _, group := range cp.mj.Spec.Groups {
-		for _, job := range group.Jobs {
-			for _, dependency := range job.Dependencies {
-				if dependency.Name == completedJob && dependency.Status != jobStatus {
-					dependency.Status = jobStatus
-				}
-			}
+// jobParameters compiles the parameters of the job from all the levels and applies its patches. They are
+// compiled whenever needed instead of being stored in the workflow, large workflows would outgrow the object.
+// The unpatched params are returned with the error, such jobs are failed by resolveDependencies.
+func (cp *connPackage) jobParameters(group *jobsmanagerv1beta1.ManagedJobGroup, job *jobsmanagerv1beta1.ManagedJobDefinition) (jobsmanagerv1beta1.ManagedJobParameters, error) {
+	return applyParamsPatches(cp.compileParameters(cp.mj.Spec.Params, group.Params, job.Params), job.ParamsPatches)
+}
+
+// mergeEnv overrides the variables by name, overridden ones keep their position and the new ones are appended
+func mergeEnv(env []corev1.EnvVar, overrides []corev1.EnvVar) []corev1.EnvVar {
+	merged := append([]corev1.EnvVar{}, env...)
+	positions := map[string]int{}
+	for i, envVar := range merged {
+		positions[envVar.Name] = i
+	}
+	for _, envVar := range overrides {
+		if i, found := positions[envVar.Name]; found {
+			merged[i] = envVar
+			continue
 		}
+		positions[envVar.Name] = len(merged)
+		merged = append(merged, envVar)
 	}
+	return merged
 }
 
-func (cp *connPackage) updateDependentGroups(completedGroup string, jobStatus string) {
-	for _, group := range cp.mj.Spec.Groups {
-		for _, dependency := range group.Dependencies {
-			if dependency.Name == completedGroup && dependency.Status != jobStatus {
-				dependency.Status = jobStatus
-			}
+// mergeVolumes overrides the volumes by name the same way as mergeEnv, conflicting sources are rejected by the webhook
+func mergeVolumes(volumes []corev1.Volume, overrides []corev1.Volume) []corev1.Volume {
+	merged := append([]corev1.Volume{}, volumes...)
+	positions := map[string]int{}
+	for i, volume := range merged {
+		positions[volume.Name] = i
+	}
+	for _, volume := range overrides {
+		if i, found := positions[volume.Name]; found {
+			merged[i] = volume
+			continue
 		}
+		positions[volume.Name] = len(merged)
+		merged = append(merged, volume)
 	}
+	return merged
+}
+
+// mergeVolumeMounts overrides the mounts by the mount path, a path can be mounted only once
+func mergeVolumeMounts(mounts []corev1.VolumeMount, overrides []corev1.VolumeMount) []corev1.VolumeMount {
+	merged := append([]corev1.VolumeMount{}, mounts...)
+	positions := map[string]int{}
+	for i, mount := range merged {
+		positions[mount.MountPath] = i
+	}
+	for _, mount := range overrides {
+		if i, found := positions[mount.MountPath]; found {
+			merged[i] = mount
+			continue
+		}
+		positions[mount.MountPath] = len(merged)
+		merged = append(merged, mount)
+	}
+	return merged
 }
 
 func (cp *connPackage) checkRunningJobsStatus() {
 	var childJobs kbatch.JobList
 	labelSelector := labels.SelectorFromSet(labels.Set{
-		"jobmanager.raczylo.com/workflow-name": cp.mj.Name,
+		labelWorkflowName: cp.mj.Name,
 	})
 	listOptions := &client.ListOptions{LabelSelector: labelSelector, Namespace: cp.mj.Namespace}
-	err := cp.r.Client.List(cp.ctx, &childJobs, listOptions)
+	err := cp.client.List(cp.ctx, &childJobs, listOptions)
 	if err != nil {
 		log.Log.Info("Unable to list child jobs", "error", err.Error())
+		cp.reconcileError(err)
 		return
 	}
 
@@ -111,17 +184,43 @@ func (cp *connPackage) checkRunningJobsStatus() {
 			for _, job := range group.Jobs {
 				generatedJobName := jobNameGenerator(cp.mj.Name, group.Name, job.Name)
 				if childJob.Name == generatedJobName {
-					if childJob.Status.Succeeded > 0 && job.Status != ExecutionStatusSucceeded {
-						cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Completed", "Job %s completed [prev: %s]", childJob.Name, job.Status)
-						job.Status = ExecutionStatusSucceeded
-					} else if childJob.Status.Failed > 0 && job.Status != ExecutionStatusFailed {
-						cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "Failed", "Job %s failed [prev: %s]", childJob.Name, job.Status)
-						job.Status = ExecutionStatusFailed
-					} else if childJob.Status.Active > 0 && job.Status != ExecutionStatusRunning {
-						cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Running", "Job %s running [prev: %s]", childJob.Name, job.Status)
-						job.Status = ExecutionStatusRunning
+					if cp.staleJob(job, &childJob) {
+						continue // left over from the previous run or attempt
 					}
-					cp.updateDependentJobs(generatedJobName, job.Status)
+					childStatus := childJobStatus(job, &childJob)
+					if childStatus == ExecutionStatusFailed && len(job.SuccessExitCodes) > 0 {
+						switch job.Status {
+						case ExecutionStatusSucceeded:
+							childStatus = job.Status // exit code mapped already
+						case ExecutionStatusFailed:
+						default:
+							if exitCode, success := cp.successExitCode(job, &childJob); success {
+								cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "SuccessExitCode", "Job %s exited with %d, counted as succeeded", childJob.Name, exitCode)
+								childStatus = ExecutionStatusSucceeded
+							}
+						}
+					}
+					if childStatus != job.Status {
+						switch childStatus {
+						case ExecutionStatusSucceeded:
+							cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Completed", "Job %s completed [prev: %s]", childJob.Name, job.Status)
+							job.Status = ExecutionStatusSucceeded
+							cp.estimateJobCost(job, &childJob)
+							cp.recordImageDigest(job, &childJob)
+							cp.archiveJobLogs(job, group)
+						case ExecutionStatusFailed:
+							cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "Failed", "Job %s failed [prev: %s]", childJob.Name, job.Status)
+							job.Status = ExecutionStatusFailed
+							job.Reason = jobFailureReason(&childJob)
+							cp.estimateJobCost(job, &childJob)
+							cp.recordImageDigest(job, &childJob)
+							cp.archiveJobLogs(job, group)
+						case ExecutionStatusRunning:
+							cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Running", "Job %s running [prev: %s]", childJob.Name, job.Status)
+							job.Status = ExecutionStatusRunning
+						}
+					}
+					cp.checkJobTimeout(group, job, &childJob)
 					continue
 				}
 			}
@@ -129,136 +228,189 @@ func (cp *connPackage) checkRunningJobsStatus() {
 	}
 }
 
-func (cp *connPackage) runPendingJobs() {
-	// originalMainJobDefinition := cp.mj.DeepCopy()
-	for _, group := range cp.mj.Spec.Groups {
-		run_group := false
+// childJobStatus maps the state of the created Job onto the execution status
+func childJobStatus(j *jobsmanagerv1beta1.ManagedJobDefinition, childJob *kbatch.Job) string {
+	if j.FanOut != nil {
+		return fanOutStatus(j, childJob)
+	}
+	if childJob.Status.Succeeded > 0 {
+		return ExecutionStatusSucceeded
+	} else if childJob.Status.Failed > 0 {
+		return ExecutionStatusFailed
+	} else if childJob.Status.Active > 0 {
+		return ExecutionStatusRunning
+	}
+	return j.Status
+}
+
+// errJobNotStarted returned by start leaves the job as it is, the scheduling pass goes on with the other jobs
+var errJobNotStarted = errors.New("job not started")
+
+// scheduleRunnableJobs walks the workflow in the topological order and starts every runnable job
+// in a single pass, so the statuses propagated before unlock the dependents regardless of where
+// they were declared. canStartGroup decides if a pending group may be started, an error returned
+// by start stops the pass unless it's errJobNotStarted. Pending jobs which are not started
+// are reported as skipped.
+func scheduleRunnableJobs(workflowName string, spec *jobsmanagerv1beta1.ManagedJobSpec, canStartGroup func(*jobsmanagerv1beta1.ManagedJobGroup) bool, start func(*jobsmanagerv1beta1.ManagedJobGroup, *jobsmanagerv1beta1.ManagedJobDefinition) error, decide decisionRecorder) error {
+	groups, err := dependencies.OrderedGroups(spec)
+	if err != nil {
+		log.Log.Info("Unable to order groups", "workflow", workflowName, "error", err.Error())
+	}
 
-		groupJobsCompleted := 0
+	skipGroup := func(group *jobsmanagerv1beta1.ManagedJobGroup, reason string) {
 		for _, job := range group.Jobs {
-			if job.Status == ExecutionStatusSucceeded {
-				groupJobsCompleted++
+			if job.Status == ExecutionStatusPending {
+				decide.record(decision{Action: DecisionSkip, Group: group.Name, Job: job.Name, Reason: reason, Dependencies: group.Dependencies})
 			}
 		}
-		if groupJobsCompleted == len(group.Jobs) {
-			group.Status = ExecutionStatusSucceeded
-			cp.updateDependentGroups(group.Name, group.Status)
+	}
+
+	for _, group := range groups {
+		approvedStatuses := []string{ExecutionStatusPending, ExecutionStatusRunning}
+		if !pandati.ExistsInSlice(approvedStatuses, group.Status) {
 			continue
 		}
-
-		approvedStatuses := []string{ExecutionStatusSucceeded, ExecutionStatusFailed, ExecutionStatusAborted}
-		if pandati.ExistsInSlice(approvedStatuses, group.Status) {
-			cp.updateDependentGroups(group.Name, group.Status)
+		if !dependenciesMet(group.Dependencies) {
+			skipGroup(group, "dependencies of the group not met")
+			continue // not running the group as dependencies were not met
 		}
-
-		approvedStatuses = []string{ExecutionStatusPending, ExecutionStatusRunning}
-		if pandati.ExistsInSlice(approvedStatuses, group.Status) {
-			if len(group.Dependencies) > 0 {
-				groupsCompleted := 0
-				for _, group_dependency := range group.Dependencies {
-					if group_dependency.Status == ExecutionStatusSucceeded {
-						groupsCompleted++
-					}
-					if group_dependency.Status == ExecutionStatusFailed {
-						group.Status = ExecutionStatusAborted
-						cp.updateDependentGroups(group.Name, ExecutionStatusFailed)
-					}
-				}
-				if groupsCompleted == len(group.Dependencies) {
-					run_group = true
-				}
-			} else {
-				run_group = true
+		if group.Status == ExecutionStatusPending && !canStartGroup(group) {
+			reason := "group can not start yet"
+			if group.Reason != "" {
+				reason = "group " + group.Reason
 			}
+			skipGroup(group, reason)
+			continue
+		}
 
-			if !run_group {
-				// fmt.Println("Group "+group.Name+" is not running as dependencies were not met", group.Dependencies)
-				continue // not running the group as dependencies were not met
-			} else {
-				group.Status = ExecutionStatusRunning
-				cp.updateDependentGroups(group.Name, ExecutionStatusRunning)
-
-				for _, job := range group.Jobs {
-					run_job := false
-					if job.Status == ExecutionStatusPending {
-						if len(job.Dependencies) > 0 {
-							jobsCompleted := 0
-							for _, job_dependency := range job.Dependencies {
-								if job_dependency.Status == ExecutionStatusSucceeded {
-									jobsCompleted++
-								}
-								if job_dependency.Status == ExecutionStatusFailed {
-									job.Status = ExecutionStatusAborted
-									cp.updateDependentJobs(job.Name, ExecutionStatusFailed)
-								}
-							}
-							if jobsCompleted == len(job.Dependencies) {
-								run_job = true
-							}
-						} else {
-							run_job = true
-						}
-					}
+		group.Status = ExecutionStatusRunning
 
-					if !run_job {
-						continue // job is not ready as dependencies were not met
-					} else {
-						approvedStatuses = []string{ExecutionStatusRunning, ExecutionStatusFailed, ExecutionStatusAborted}
-						if !pandati.ExistsInSlice(approvedStatuses, job.Status) {
-							err := cp.executeJob(job, group)
-							if err != nil {
-								log.Log.Info("Unable to execute job", "error", err.Error())
-								if !strings.Contains(err.Error(), "exists") {
-									job.Status = ExecutionStatusFailed
-									group.Status = ExecutionStatusFailed
-									cp.updateDependentJobs(job.Name, ExecutionStatusFailed)
-									cp.updateDependentGroups(group.Name, ExecutionStatusFailed)
-									cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "Failed", "Job %s from group %s failed", job.Name, group.Name)
-								}
-								return
-							}
-							job.Status = ExecutionStatusRunning
-							cp.updateDependentJobs(job.Name, ExecutionStatusRunning)
-							cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Running", "Job %s from group %s running", job.Name, group.Name)
-						}
-					}
-				}
+		jobs, err := dependencies.OrderedJobs(workflowName, group)
+		if err != nil {
+			log.Log.Info("Unable to order jobs", "workflow", workflowName, "group", group.Name, "error", err.Error())
+		}
+		for _, job := range jobs {
+			if job.Status != ExecutionStatusPending {
+				continue
 			}
-
-			// fmt.Println("Running group: ", group.Name, " with status: ", group.Status, " accepted: ", run_group)
+			if !dependenciesMet(job.Dependencies) {
+				decide.record(decision{Action: DecisionSkip, Group: group.Name, Job: job.Name, Reason: "dependencies not met", Dependencies: job.Dependencies})
+				continue // job is not ready as dependencies were not met
+			}
+			decide.record(decision{Action: DecisionStart, Group: group.Name, Job: job.Name, Reason: "dependencies met", Dependencies: job.Dependencies})
+			if err := start(group, job); errors.Is(err, errJobNotStarted) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			job.Status = ExecutionStatusRunning
 		}
 	}
+	return nil
 }
 
-func (cp *connPackage) executeJob(j *jobsmanagerv1beta1.ManagedJobDefinition, g *jobsmanagerv1beta1.ManagedJobGroup) (err error) {
-	generatedJobName := jobNameGenerator(cp.mj.Name, g.Name, j.Name)
-	convertRetries := func(retries int) *int32 {
-		if retries == 0 {
-			return nil
-		}
-		retries32 := int32(retries)
-		return &retries32
+func (cp *connPackage) runPendingJobs() {
+	// dependents see the jobs started in this pass and the failures of job creation
+	defer cp.propagateStatuses()
+	defer cp.updateUnauthorizedCondition()
+	defer cp.updateQuotaWaitCondition()
+
+	if cp.suspended() {
+		cp.holdSuspendedGroups()
+		return
 	}
+	cp.endSuspension()
 
-	// compile labels
-	labels := map[string]string{
-		"jobmanager.raczylo.com/workflow-name": cp.mj.Name,
-		"jobmanager.raczylo.com/group-name":    g.Name,
-		"jobmanager.raczylo.com/job-name":      generatedJobName,
-		"jobmanager.raczylo.com/job-id":        j.Name,
+	if cp.waitsForWorkflows() {
+		return
 	}
 
+	if end, open := maintenanceWindowEnd(cp.maintenanceWindows(), cp.now()); open {
+		cp.waitForMaintenanceWindow(end)
+		return
+	}
+	cp.endMaintenanceWait()
+
+	others := cp.namespaceWorkflows()
+	if position := queuePosition(cp.mj, others, cp.r.MaxActiveWorkflowsPerNamespace); position > 0 {
+		cp.waitInQueue(position)
+		return
+	}
+	cp.mj.Spec.QueuePosition = 0
+	slots := cp.jobSlots(others)
+
+	scheduleRunnableJobs(cp.mj.Name, &cp.mj.Spec,
+		// not starting the group until its delays pass, it gets approved and its jobs fit into the namespace quota,
+		// the group which is too late is skipped
+		cp.groupCanStart,
+		func(group *jobsmanagerv1beta1.ManagedJobGroup, job *jobsmanagerv1beta1.ManagedJobDefinition) error {
+			if !cp.jobWithinTimeWindow(group, job) {
+				return errJobNotStarted
+			}
+			if slots == 0 {
+				cp.recordDecision(decision{Action: DecisionSkip, Group: group.Name, Job: job.Name, Reason: "namespace runs the maximum of active jobs"})
+				cp.requeueIn(queuedRequeue)
+				return errJobCapReached
+			}
+			if !cp.jobAcquiresMutex(group, job) {
+				return errJobNotStarted
+			}
+			err := cp.executeJob(job, group)
+			if errors.Is(err, errJobUnauthorized) {
+				cp.recordDecision(decision{Action: DecisionSkip, Group: group.Name, Job: job.Name, Reason: err.Error()})
+				cp.requeueIn(unauthorizedRequeue)
+				return errJobNotStarted
+			}
+			if cp.apiThrottled(err) {
+				cp.recordDecision(decision{Action: DecisionSkip, Group: group.Name, Job: job.Name, Reason: "API server is throttling"})
+				return errJobNotStarted
+			}
+			if err != nil {
+				log.Log.Info("Unable to execute job", "error", err.Error())
+				if !strings.Contains(err.Error(), "exists") {
+					cp.reconcileError(err)
+					job.Status = ExecutionStatusFailed
+					job.Reason = ReasonCreateFailed
+					group.Status = ExecutionStatusFailed
+					cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "Failed", "Job %s from group %s failed", job.Name, group.Name)
+				}
+				return err
+			}
+			cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Running", "Job %s from group %s running", job.Name, group.Name)
+			if slots > 0 {
+				slots--
+			}
+			return nil
+		}, cp.recordDecision)
+}
+
+// buildJob prepares the Job running the container of the job definition
+func (cp *connPackage) buildJob(j *jobsmanagerv1beta1.ManagedJobDefinition, g *jobsmanagerv1beta1.ManagedJobGroup) *kbatch.Job {
+	generatedJobName := jobNameGenerator(cp.mj.Name, g.Name, j.Name)
+
+	// jobs with the broken params patches never get here
+	params, _ := cp.jobParameters(g, j)
+
+	// compile labels
+	labels := cp.podLabels(g, j)
+
 	// merge labels with j.Parameters.Labels
-	for k, v := range j.CompiledParams.Labels {
+	for k, v := range params.Labels {
 		labels[k] = v
 	}
 
-	annotations := map[string]string{}
+	annotations := cp.podAnnotations(g)
 
-	for k, v := range j.CompiledParams.Annotations {
+	for k, v := range params.Annotations {
 		annotations[k] = v
 	}
 
+	env := append([]corev1.EnvVar{}, params.Env...)
+	if cp.pushMetricsEnabled() {
+		env = append(env, corev1.EnvVar{Name: "PUSHGATEWAY_URL", Value: cp.pushgatewayGroupingURL(g.Name, j.Name)})
+	}
+	env = failureHandlerEnv(env, g, j)
+
 	job_handler := kbatch.Job{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      generatedJobName,
@@ -273,26 +425,68 @@ func (cp *connPackage) executeJob(j *jobsmanagerv1beta1.ManagedJobDefinition, g
 					Annotations: annotations,
 				},
 				Spec: corev1.PodSpec{
-					Volumes:            j.CompiledParams.Volumes,
-					ImagePullSecrets:   j.CompiledParams.ImagePullSecrets,
-					ServiceAccountName: j.CompiledParams.ServiceAccount,
+					Volumes:            params.Volumes,
+					ImagePullSecrets:   params.ImagePullSecrets,
+					ServiceAccountName: params.ServiceAccount,
 					Containers: []corev1.Container{
 						{
 							Name:            generatedJobName,
-							Image:           j.Image,
+							Image:           cp.mj.Spec.JobImage(g, j),
 							Args:            j.Args,
-							ImagePullPolicy: corev1.PullPolicy(j.CompiledParams.ImagePullPolicy),
-							EnvFrom:         j.CompiledParams.FromEnv,
-							Env:             j.CompiledParams.Env,
-							VolumeMounts:    j.CompiledParams.VolumeMounts,
+							WorkingDir:      params.WorkingDir,
+							SecurityContext: containerSecurityContext(params),
+							ImagePullPolicy: corev1.PullPolicy(params.ImagePullPolicy),
+							EnvFrom:         params.FromEnv,
+							Env:             env,
+							VolumeMounts:    params.VolumeMounts,
+							Resources:       compiledResources(params),
 						},
 					},
-					RestartPolicy: corev1.RestartPolicy(j.CompiledParams.RestartPolicy),
+					RestartPolicy: corev1.RestartPolicy(params.RestartPolicy),
 				},
 			},
-			BackoffLimit: convertRetries(cp.mj.Spec.Retries),
+			BackoffLimit:          jobRetries(&cp.mj.Spec, g, j),
+			ActiveDeadlineSeconds: j.TimeoutSeconds,
 		},
 	}
+	applyFanOut(j, &job_handler)
+	applySuccessExitCodes(j, &job_handler)
+	return &job_handler
+}
+
+// containerSecurityContext runs the container as the configured user and group, nil keeps the ones of the image
+func containerSecurityContext(params jobsmanagerv1beta1.ManagedJobParameters) *corev1.SecurityContext {
+	if params.RunAsUser == nil && params.RunAsGroup == nil {
+		return nil
+	}
+	return &corev1.SecurityContext{RunAsUser: params.RunAsUser, RunAsGroup: params.RunAsGroup}
+}
+
+// createJob creates the prepared Job owned by the workflow
+func (cp *connPackage) createJob(j *jobsmanagerv1beta1.ManagedJobDefinition, g *jobsmanagerv1beta1.ManagedJobGroup, job_handler *kbatch.Job) error {
+	resolvedSpec, err := json.Marshal(job_handler.Spec.Template.Spec)
+	if err != nil {
+		return err
+	}
+	resolvedSpecHash := fmt.Sprintf("%x", sha256.Sum256(resolvedSpec))
+	attempt := j.Attempt + 1
+	snapshot := attemptSnapshot(job_handler, attempt)
+	annotations := cp.jobIdentity(attempt)
+	annotations[annotationResolvedSpecHash] = resolvedSpecHash
+	job_handler.SetAnnotations(annotations)
+	if cp.r.RecordResolvedSpec {
+		job_handler.Annotations[annotationResolvedSpec] = string(resolvedSpec)
+	}
+	// the API server defaults the labels of the Job to the ones of its pods only when it has none
+	if len(job_handler.Labels) == 0 {
+		for k, v := range job_handler.Spec.Template.Labels {
+			metav1.SetMetaDataLabel(&job_handler.ObjectMeta, k, v)
+		}
+	}
+	for k, v := range cp.specVersionLabels(resolvedSpecHash) {
+		metav1.SetMetaDataLabel(&job_handler.ObjectMeta, k, v)
+		metav1.SetMetaDataLabel(&job_handler.Spec.Template.ObjectMeta, k, v)
+	}
 
 	getMetaRefForWorkflowData, err := cp.getOwnerReference()
 	if err != nil {
@@ -301,36 +495,133 @@ func (cp *connPackage) executeJob(j *jobsmanagerv1beta1.ManagedJobDefinition, g
 
 	job_handler.SetOwnerReferences([]metav1.OwnerReference{getMetaRefForWorkflowData})
 
-	err = cp.r.Client.Create(cp.ctx, &job_handler)
-	if err != nil || pandati.IsZero(job_handler) {
+	if err := cp.authorizeJob(job_handler); err != nil {
+		return err
+	}
+	jobClient, err := cp.jobClient(g)
+	if err != nil {
+		return err
+	}
+	if err := cp.issueVaultSecret(j, g, job_handler); err != nil {
+		return err
+	}
+	err = jobClient.Create(cp.ctx, job_handler)
+	if apierrors.IsAlreadyExists(err) {
+		return cp.resumeJob(j, job_handler, attempt, err)
+	}
+	if err != nil || pandati.IsZero(*job_handler) {
 		return err
 	}
 
+	j.Attempt = attempt
+	j.ResolvedSpecHash = resolvedSpecHash
 	cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Created", "Created job %s", job_handler.Name)
+	cp.recordAttempt(j, job_handler, snapshot)
 	return nil
 }
 
-func (cp *connPackage) checkOverallStatus() {
+// jobRetries is the backoff limit of the Job, the retries of the job override the ones of its group
+// and the group ones the retries of the workflow. The workflow retries are at least 1 in the schema, the
+// unset ones of the workflows built without the API server defaults get the schema default instead of the
+// backoffLimit default of Kubernetes.
+func jobRetries(spec *jobsmanagerv1beta1.ManagedJobSpec, g *jobsmanagerv1beta1.ManagedJobGroup, j *jobsmanagerv1beta1.ManagedJobDefinition) *int32 {
+	switch {
+	case j.Retries != nil:
+		return j.Retries
+	case g.Retries != nil:
+		return g.Retries
+	}
+	retries := int32(spec.Retries)
+	if retries < 1 {
+		retries = defaultWorkflowRetries
+	}
+	return &retries
+}
+
+// workflowStatus derives the workflow status from the statuses of its groups
+func workflowStatus(spec *jobsmanagerv1beta1.ManagedJobSpec) string {
+	if spec.SuccessCriteria != "" {
+		return successCriteriaStatus(spec)
+	}
 	groupsCompleted := 0
+	groupsFailed := 0
 	negativeStatuses := []string{ExecutionStatusFailed, ExecutionStatusAborted}
-	for _, group := range cp.mj.Spec.Groups {
-		if group.Status == ExecutionStatusSucceeded {
+	for _, group := range spec.Groups {
+		if group.Status == ExecutionStatusSucceeded || group.Status == ExecutionStatusSkipped {
+			groupsCompleted++
+		} else if pandati.ExistsInSlice(negativeStatuses, group.Status) && failureHandler(group.Dependencies) {
+			// the failed handler does not change the outcome of the run
 			groupsCompleted++
 		} else if pandati.ExistsInSlice(negativeStatuses, group.Status) {
-			cp.mj.Status = ExecutionStatusFailed
-			cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "Failure", "Run failed in group %s", group.Name)
-		} else {
+			groupsFailed++
+		}
+	}
+
+	if groupsFailed > 0 && !failureHandlersPending(spec) {
+		// parent workflows rely on the terminal state to map sub-workflow results
+		return ExecutionStatusFailed
+	} else if groupsCompleted == len(spec.Groups) {
+		return ExecutionStatusSucceeded
+	}
+	return ExecutionStatusRunning
+}
+
+// failureHandlersPending tells if a group or job which may run on the failure of another one has not finished yet
+func failureHandlersPending(spec *jobsmanagerv1beta1.ManagedJobSpec) bool {
+	runsOnFailure := func(dependencies []*jobsmanagerv1beta1.ManagedJobDependencies) bool {
+		for _, dependency := range dependencies {
+			if dependency.DependencyCondition() != jobsmanagerv1beta1.DependencyConditionSucceeded {
+				return true
+			}
+		}
+		return false
+	}
+	for _, group := range spec.Groups {
+		if group.Status != ExecutionStatusPending && group.Status != ExecutionStatusRunning {
 			continue
 		}
+		if runsOnFailure(group.Dependencies) {
+			return true
+		}
+		for _, job := range group.Jobs {
+			if (job.Status == ExecutionStatusPending || job.Status == ExecutionStatusRunning) && runsOnFailure(job.Dependencies) {
+				return true
+			}
+		}
 	}
+	return false
+}
 
-	if groupsCompleted == len(cp.mj.Spec.Groups) {
-		if cp.mj.Status != ExecutionStatusSucceeded {
-			cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Success", "Run completed successfuly")
+// checkOverallStatus sets the status of the workflow from the statuses of its groups and saves it, it returns
+// the error of the save
+func (cp *connPackage) checkOverallStatus() error {
+	status := workflowStatus(&cp.mj.Spec)
+	cp.reportSuccessCriteria(status)
+	if status == ExecutionStatusFailed && cp.mj.Status.Phase != ExecutionStatusFailed {
+		for _, group := range cp.mj.Spec.Groups {
+			if (group.Status == ExecutionStatusFailed || group.Status == ExecutionStatusAborted) && !failureHandler(group.Dependencies) {
+				cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "Failure", "Run failed in group %s", group.Name)
+			}
+		}
+		if digest := failureDigest(&cp.mj.Spec); digest != "" {
+			cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "FailureDigest", "Run failed: %s", digest)
 		}
-		cp.mj.Status = ExecutionStatusSucceeded
-	} else {
-		cp.mj.Status = ExecutionStatusRunning
 	}
-	cp.r.Status().Update(cp.ctx, cp.mj)
+	if status == ExecutionStatusSucceeded && cp.mj.Status.Phase != ExecutionStatusSucceeded {
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Success", "Run completed successfuly")
+	}
+	if status == ExecutionStatusRunning && cp.suspended() {
+		status = ExecutionStatusSuspended
+	}
+	if status == ExecutionStatusRunning && cp.mj.Spec.QueuePosition > 0 {
+		status = ExecutionStatusQueued
+	}
+	cp.mj.Status.Phase = status
+	cp.setRunConditions()
+	err := cp.writeStatus()
+	if err != nil && !apierrors.IsConflict(err) && !cp.apiThrottled(err) {
+		log.Log.Info("Unable to update the status", "workflow", cp.mj.Name, "error", err.Error())
+		cp.reconcileError(err)
+	}
+	return err
 }
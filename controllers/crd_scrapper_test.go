@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"testing"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestJobRetries(t *testing.T) {
+	groupRetries, jobRetries := int32(5), int32(0)
+	cp := &connPackage{r: &ManagedJobReconciler{}, mj: &jobsmanagerv1beta1.ManagedJob{}}
+	cp.mj.Name = "nightly"
+	g := &jobsmanagerv1beta1.ManagedJobGroup{Name: "extract"}
+	j := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "sync", Image: "rsync"}
+
+	for _, tt := range []struct {
+		name            string
+		workflow        int
+		group, job      *int32
+		expectedBackoff int32
+	}{
+		{name: "workflow", workflow: 2, expectedBackoff: 2},
+		{name: "group", workflow: 2, group: &groupRetries, expectedBackoff: 5},
+		{name: "job without retries", workflow: 2, group: &groupRetries, job: &jobRetries, expectedBackoff: 0},
+		// not the backoffLimit default of Kubernetes
+		{name: "workflow built without the schema default", expectedBackoff: defaultWorkflowRetries},
+	} {
+		cp.mj.Spec.Retries, g.Retries, j.Retries = tt.workflow, tt.group, tt.job
+		if backoff := cp.buildJob(j, g).Spec.BackoffLimit; backoff == nil || *backoff != tt.expectedBackoff {
+			t.Errorf("%s: backoff limit = %v, expected %d", tt.name, backoff, tt.expectedBackoff)
+		}
+	}
+}
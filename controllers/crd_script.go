@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"fmt"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+/* Inline script steps - source stored in the ConfigMap mounted into the job */
+
+const (
+	scriptVolumeName = "managedjob-script"
+	scriptMountPath  = "/opt/managedjob"
+	scriptFileName   = "script"
+)
+
+func (cp *connPackage) executeScript(j *jobsmanagerv1beta1.ManagedJobDefinition, g *jobsmanagerv1beta1.ManagedJobGroup) (err error) {
+	if j.Script == nil {
+		return fmt.Errorf("job %s is of type %s but has no script", j.Name, JobTypeScript)
+	}
+	generatedJobName := jobNameGenerator(cp.mj.Name, g.Name, j.Name)
+
+	getMetaRefForWorkflowData, err := cp.getOwnerReference()
+	if err != nil {
+		return err
+	}
+	scriptConfigMap := corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      generatedJobName + "-script",
+			Namespace: cp.mj.Namespace,
+			Labels: map[string]string{
+				labelWorkflowName: cp.mj.Name,
+				labelGroupName:    g.Name,
+				labelJobName:      generatedJobName,
+			},
+			OwnerReferences: []metav1.OwnerReference{getMetaRefForWorkflowData},
+		},
+		Data: map[string]string{scriptFileName: j.Script.Source},
+	}
+	err = cp.client.Create(cp.ctx, &scriptConfigMap)
+	if apierrors.IsAlreadyExists(err) {
+		// left over from the previous run, the script may have been edited since
+		err = cp.updateScript(&scriptConfigMap)
+	}
+	if err != nil {
+		return err
+	}
+
+	interpreter := j.Script.Interpreter
+	if len(interpreter) == 0 {
+		interpreter = []string{"/bin/sh"}
+	}
+
+	job := cp.buildJob(j, g)
+	executable := int32(0755)
+	podSpec := &job.Spec.Template.Spec
+	podSpec.Volumes = append(append([]corev1.Volume{}, podSpec.Volumes...), corev1.Volume{
+		Name: scriptVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: scriptConfigMap.Name},
+				DefaultMode:          &executable,
+			},
+		},
+	})
+	container := &podSpec.Containers[0]
+	container.VolumeMounts = append(append([]corev1.VolumeMount{}, container.VolumeMounts...), corev1.VolumeMount{
+		Name:      scriptVolumeName,
+		MountPath: scriptMountPath,
+		ReadOnly:  true,
+	})
+	// job args are passed to the script
+	container.Command = append(append([]string{}, interpreter...), scriptMountPath+"/"+scriptFileName)
+
+	return cp.createJob(j, g, job)
+}
+
+// updateScript replaces the source of the existing script ConfigMap with the current one
+func (cp *connPackage) updateScript(scriptConfigMap *corev1.ConfigMap) error {
+	existing := &corev1.ConfigMap{}
+	if err := cp.client.Get(cp.ctx, client.ObjectKeyFromObject(scriptConfigMap), existing); err != nil {
+		return err
+	}
+	if reflect.DeepEqual(existing.Data, scriptConfigMap.Data) {
+		return nil
+	}
+	existing.Data = scriptConfigMap.Data
+	return cp.client.Update(cp.ctx, existing)
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestScriptUpdatedOnRerun(t *testing.T) {
+	job := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "migrate", Type: JobTypeScript, Image: "alpine:3.18",
+		Script: &jobsmanagerv1beta1.ManagedJobScript{Source: "echo v1"}}
+	group := &jobsmanagerv1beta1.ManagedJobGroup{Name: "db", Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{job}}
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "release", Namespace: "apps"},
+		Spec:       jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{group}},
+	}
+	c := newTestClientBuilder(mj).Build()
+	cp := newTestConnPackageWithClient(&ManagedJobReconciler{Recorder: record.NewFakeRecorder(10)}, mj, c)
+	scriptKey := client.ObjectKey{Namespace: "apps", Name: jobNameGenerator("release", "db", "migrate") + "-script"}
+
+	if err := cp.executeScript(job, group); err != nil {
+		t.Fatal(err)
+	}
+	// the ConfigMap left over from the previous run gets the edited script
+	cp.deletePreviousJob(group, &jobsmanagerv1beta1.ManagedJobDefinition{Name: "migrate"})
+	job.Script.Source = "echo v2"
+	if err := cp.executeScript(job, group); err != nil {
+		t.Fatal(err)
+	}
+	script := &corev1.ConfigMap{}
+	if err := c.Get(cp.ctx, scriptKey, script); err != nil || script.Data[scriptFileName] != "echo v2" {
+		t.Fatalf("expected the updated script, got %v and %v", script.Data, err)
+	}
+
+	// the restarted job drops its script with the Job
+	cp.deletePreviousJob(group, job)
+	if err := c.Get(cp.ctx, scriptKey, script); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the script deleted with the job, got %v", err)
+	}
+}
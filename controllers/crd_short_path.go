@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+/*
+Short path - the workflow which reconcile changed nothing is not synced again until the workflow
+or one of its child Jobs or sub-workflows changes. Most of the resync reconciles of the long running
+workflows find nothing to do, they are answered from the fingerprint kept in memory.
+*/
+
+// DefaultFullSyncInterval bounds how long the quiescent workflows skip the sync, e.g. the image pulls
+// of the pods are not reflected in the Job
+const DefaultFullSyncInterval = time.Hour
+
+type syncFingerprint struct {
+	value    string
+	syncedAt time.Time
+}
+
+// syncFingerprints remembers the quiescent workflows with the fingerprint they were synced at
+type syncFingerprints struct {
+	mtx     sync.Mutex
+	entries map[types.NamespacedName]syncFingerprint
+}
+
+// unchanged tells if the workflow was synced at the same fingerprint within maxAge, 0 disables the short path
+func (f *syncFingerprints) unchanged(key types.NamespacedName, value string, maxAge time.Duration, now time.Time) bool {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	entry, found := f.entries[key]
+	return maxAge > 0 && found && entry.value == value && now.Sub(entry.syncedAt) < maxAge
+}
+
+func (f *syncFingerprints) observe(key types.NamespacedName, value string, now time.Time) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if f.entries == nil {
+		f.entries = map[types.NamespacedName]syncFingerprint{}
+	}
+	f.entries[key] = syncFingerprint{value: value, syncedAt: now}
+}
+
+func (f *syncFingerprints) forget(key types.NamespacedName) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	delete(f.entries, key)
+}
+
+// syncFingerprint hashes the resource versions of the workflow and its children, the workflow one
+// changes with the spec generation as well as the annotations, e.g. the approvals
+func (cp *connPackage) syncFingerprint() (string, error) {
+	listOptions := &client.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{labelWorkflowName: cp.mj.Name}),
+		Namespace:     cp.mj.Namespace,
+	}
+	var childJobs kbatch.JobList
+	if err := cp.client.List(cp.ctx, &childJobs, listOptions); err != nil {
+		return "", err
+	}
+	var childWorkflows jobsmanagerv1beta1.ManagedJobList
+	if err := cp.client.List(cp.ctx, &childWorkflows, listOptions); err != nil {
+		return "", err
+	}
+
+	versions := []string{}
+	for _, job := range childJobs.Items {
+		versions = append(versions, "Job/"+job.Name+"@"+job.ResourceVersion)
+	}
+	for _, workflow := range childWorkflows.Items {
+		versions = append(versions, "ManagedJob/"+workflow.Name+"@"+workflow.ResourceVersion)
+	}
+	sort.Strings(versions)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s@%s:", cp.mj.UID, cp.mj.ResourceVersion)
+	for _, version := range versions {
+		fmt.Fprintf(h, "%s:", version)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// watchesExternalJobs tells if a running job is tracked by its executor, its status changes outside of the cluster
+func (cp *connPackage) watchesExternalJobs() bool {
+	for _, group := range cp.mj.Spec.Groups {
+		for _, job := range group.Jobs {
+			if job.Status != ExecutionStatusRunning {
+				continue
+			}
+			if executor, err := cp.r.executorFor(job.Type); err == nil {
+				if _, checked := executor.(JobStatusChecker); checked {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// recordQuiescence keeps the fingerprint of the workflow which sync changed nothing and waits for no requeue
+func (cp *connPackage) recordQuiescence(fingerprint string, changed bool) {
+	if cp.r.FullSyncInterval <= 0 {
+		return
+	}
+	if fingerprint == "" || changed || cp.requeueAfter > 0 || len(cp.errs) > 0 || cp.watchesExternalJobs() {
+		cp.r.syncFingerprints.forget(cp.req.NamespacedName)
+		return
+	}
+	cp.r.syncFingerprints.observe(cp.req.NamespacedName, fingerprint, cp.now())
+}
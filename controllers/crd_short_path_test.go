@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestSyncFingerprints(t *testing.T) {
+	key := types.NamespacedName{Namespace: "etl", Name: "nightly"}
+	now := time.Now()
+	fingerprints := &syncFingerprints{}
+	if fingerprints.unchanged(key, "a", time.Hour, now) {
+		t.Error("expected the unknown workflow synced")
+	}
+	fingerprints.observe(key, "a", now)
+	if !fingerprints.unchanged(key, "a", time.Hour, now.Add(time.Minute)) {
+		t.Error("expected the quiescent workflow skipped")
+	}
+	if fingerprints.unchanged(key, "b", time.Hour, now.Add(time.Minute)) {
+		t.Error("expected the changed workflow synced")
+	}
+	if fingerprints.unchanged(key, "a", time.Hour, now.Add(time.Hour)) {
+		t.Error("expected the full sync once the interval passed")
+	}
+	if fingerprints.unchanged(key, "a", 0, now) {
+		t.Error("expected no short path when disabled")
+	}
+	fingerprints.forget(key)
+	if fingerprints.unchanged(key, "a", time.Hour, now) {
+		t.Error("expected the forgotten workflow synced")
+	}
+}
+
+func TestSyncFingerprint(t *testing.T) {
+	childJob := &kbatch.Job{ObjectMeta: metav1.ObjectMeta{Name: "nightly-extract-sync", Namespace: "etl", Labels: map[string]string{labelWorkflowName: "nightly"}}}
+	otherJob := &kbatch.Job{ObjectMeta: metav1.ObjectMeta{Name: "weekly-extract-sync", Namespace: "etl", Labels: map[string]string{labelWorkflowName: "weekly"}}}
+	cp := newTestConnPackage(&ManagedJobReconciler{FullSyncInterval: time.Hour},
+		&jobsmanagerv1beta1.ManagedJob{ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "etl", ResourceVersion: "10"}},
+		childJob, otherJob)
+	c := cp.client
+
+	fingerprint, err := cp.syncFingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp.recordQuiescence(fingerprint, false)
+	if !cp.r.syncFingerprints.unchanged(cp.req.NamespacedName, fingerprint, time.Hour, time.Now()) {
+		t.Fatal("expected the quiescent workflow recorded")
+	}
+
+	otherJob.Status.Active = 1
+	if err := c.Status().Update(context.Background(), otherJob); err != nil {
+		t.Fatal(err)
+	}
+	if unrelated, _ := cp.syncFingerprint(); unrelated != fingerprint {
+		t.Error("expected the jobs of the other workflows ignored")
+	}
+	childJob.Status.Active = 1
+	if err := c.Status().Update(context.Background(), childJob); err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := cp.syncFingerprint(); changed == fingerprint {
+		t.Error("expected the updated child job to change the fingerprint")
+	}
+	cp.mj.ResourceVersion = "11"
+	if edited, _ := cp.syncFingerprint(); edited == fingerprint {
+		t.Error("expected the updated workflow to change the fingerprint")
+	}
+
+	// the workflow waiting for the requeue keeps being synced
+	cp.requeueAfter = time.Minute
+	cp.recordQuiescence(fingerprint, false)
+	if cp.r.syncFingerprints.unchanged(cp.req.NamespacedName, fingerprint, time.Hour, time.Now()) {
+		t.Error("expected the workflow waiting for the requeue forgotten")
+	}
+}
@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/pkg/dependencies"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+/*
+Status pages - once the workflow grows close to the object size limit, the runtime details of its jobs
+are stored in a ConfigMap per group instead. The status keeps the statuses and the dependencies the
+scheduling works on, the details are loaded back at the start of every reconcile.
+*/
+
+// statusPageThreshold is the serialized size of the workflow above which its job details are paged out
+const statusPageThreshold = jobsmanagerv1beta1.MaxWorkflowSize * 3 / 4
+
+const statusPageKey = "jobs.json"
+
+// jobStatusDetails are the fields of the job written by the operator which the scheduling does not depend on
+type jobStatusDetails struct {
+	ArchivedLogs         string           `json:"archivedLogs,omitempty"`
+	ResolvedSpecHash     string           `json:"resolvedSpecHash,omitempty"`
+	FanOutSummary        string           `json:"fanOutSummary,omitempty"`
+	Drift                string           `json:"drift,omitempty"`
+	ImagePullRefreshedAt *metav1.Time     `json:"imagePullRefreshedAt,omitempty"`
+	SchedulingLatency    *metav1.Duration `json:"schedulingLatency,omitempty"`
+	SlowScheduling       string           `json:"slowScheduling,omitempty"`
+	Reason               string           `json:"reason,omitempty"`
+	EstimatedCost        string           `json:"estimatedCost,omitempty"`
+}
+
+// statusPages are the job details by the group and the job name
+type statusPages map[string]map[string]jobStatusDetails
+
+func statusPageName(workflow string, group string) string {
+	return jobNameGenerator(workflow, group, "status")
+}
+
+// takeStatusDetails collects the job details of the workflow, clearing them from the jobs when asked to
+func takeStatusDetails(mj *jobsmanagerv1beta1.ManagedJob, clear bool) statusPages {
+	pages := statusPages{}
+	for _, group := range mj.Spec.Groups {
+		page := map[string]jobStatusDetails{}
+		for _, job := range group.Jobs {
+			details := jobStatusDetails{
+				ArchivedLogs:         job.ArchivedLogs,
+				ResolvedSpecHash:     job.ResolvedSpecHash,
+				FanOutSummary:        job.FanOutSummary,
+				Drift:                job.Drift,
+				ImagePullRefreshedAt: job.ImagePullRefreshedAt,
+				SchedulingLatency:    job.SchedulingLatency,
+				SlowScheduling:       job.SlowScheduling,
+				Reason:               job.Reason,
+				EstimatedCost:        job.EstimatedCost,
+			}
+			if details != (jobStatusDetails{}) {
+				page[job.Name] = details
+			}
+			if clear {
+				job.ArchivedLogs, job.ResolvedSpecHash, job.FanOutSummary, job.Drift = "", "", "", ""
+				job.ImagePullRefreshedAt, job.Reason, job.EstimatedCost = nil, "", ""
+				job.SchedulingLatency, job.SlowScheduling = nil, ""
+			}
+		}
+		pages[group.Name] = page
+	}
+	return pages
+}
+
+// restore puts the details back into the jobs, the ones set on the jobs are kept
+func (pages statusPages) restore(mj *jobsmanagerv1beta1.ManagedJob) {
+	for _, group := range mj.Spec.Groups {
+		for _, job := range group.Jobs {
+			details, found := pages[group.Name][job.Name]
+			if !found {
+				continue
+			}
+			setIfEmpty(&job.ArchivedLogs, details.ArchivedLogs)
+			setIfEmpty(&job.ResolvedSpecHash, details.ResolvedSpecHash)
+			setIfEmpty(&job.FanOutSummary, details.FanOutSummary)
+			setIfEmpty(&job.Drift, details.Drift)
+			setIfEmpty(&job.Reason, details.Reason)
+			setIfEmpty(&job.EstimatedCost, details.EstimatedCost)
+			setIfEmpty(&job.SlowScheduling, details.SlowScheduling)
+			if job.ImagePullRefreshedAt == nil {
+				job.ImagePullRefreshedAt = details.ImagePullRefreshedAt
+			}
+			if job.SchedulingLatency == nil {
+				job.SchedulingLatency = details.SchedulingLatency
+			}
+		}
+	}
+}
+
+func setIfEmpty(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+func workflowPaged(mj *jobsmanagerv1beta1.ManagedJob) bool {
+	for _, group := range mj.Spec.Groups {
+		if group.StatusPage != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadStatus fills the runtime state of the workflow from its status, and the job details of the paged
+// workflow from its ConfigMaps, the workflows which were never paged are left as they are
+func LoadStatus(ctx context.Context, c client.Reader, mj *jobsmanagerv1beta1.ManagedJob) error {
+	loadRuntimeState(mj)
+	pages := statusPages{}
+	for _, group := range mj.Spec.Groups {
+		if group.StatusPage == "" {
+			continue
+		}
+		var configMap corev1.ConfigMap
+		if err := c.Get(ctx, client.ObjectKey{Namespace: mj.Namespace, Name: group.StatusPage}, &configMap); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue // details of the page are lost, the next ones are written again
+			}
+			return err
+		}
+		page := map[string]jobStatusDetails{}
+		if err := json.Unmarshal([]byte(configMap.Data[statusPageKey]), &page); err != nil {
+			return err
+		}
+		pages[group.Name] = page
+	}
+	pages.restore(mj)
+	return nil
+}
+
+// loadRuntimeState merges the status into the spec, the implicit dependencies are added first so their
+// statuses are filled in too
+func loadRuntimeState(mj *jobsmanagerv1beta1.ManagedJob) {
+	dependencies.Resolve(mj.Name, &mj.Spec)
+	mj.MergeStatus()
+}
+
+// pageOutStatuses moves the job details into the pages when the workflow is too large to keep them,
+// the workflow stays paged once it was. The returned details are restored after the workflow was written.
+func (cp *connPackage) pageOutStatuses() (statusPages, error) {
+	if !workflowPaged(cp.mj) {
+		data, err := json.Marshal(cp.mj)
+		if err != nil || len(data) <= statusPageThreshold {
+			return nil, err
+		}
+	}
+
+	pages := takeStatusDetails(cp.mj, false)
+	ownerReference, err := cp.getOwnerReference()
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range cp.mj.Spec.Groups {
+		data, err := json.Marshal(pages[group.Name])
+		if err != nil {
+			return nil, err
+		}
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            statusPageName(cp.mj.Name, group.Name),
+				Namespace:       cp.mj.Namespace,
+				Labels:          map[string]string{labelWorkflowName: cp.mj.Name},
+				OwnerReferences: []metav1.OwnerReference{ownerReference},
+			},
+			Data: map[string]string{statusPageKey: string(data)},
+		}
+		if err := cp.writeStatusPage(configMap); err != nil {
+			return nil, err
+		}
+		group.StatusPage = configMap.Name
+	}
+	return takeStatusDetails(cp.mj, true), nil
+}
+
+// writeStatusPage creates or updates the page, unchanged pages are not written
+func (cp *connPackage) writeStatusPage(configMap *corev1.ConfigMap) error {
+	var existing corev1.ConfigMap
+	err := cp.client.Get(cp.ctx, client.ObjectKeyFromObject(configMap), &existing)
+	if apierrors.IsNotFound(err) {
+		return cp.client.Create(cp.ctx, configMap)
+	}
+	if err != nil || reflect.DeepEqual(existing.Data, configMap.Data) {
+		return err
+	}
+	existing.Data = configMap.Data
+	return cp.client.Update(cp.ctx, &existing)
+}
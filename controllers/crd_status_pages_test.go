@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestStatusPages(t *testing.T) {
+	key := types.NamespacedName{Namespace: "etl", Name: "nightly"}
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{
+			Name: "extract",
+			Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+				{Name: "sync", Image: "busybox", Args: []string{"small"}},
+				{Name: "dump", Image: "busybox"},
+			},
+		}}},
+	}
+	c := newTestClientBuilder(mj).WithStatusSubresource(mj).Build()
+	cp := newTestConnPackageWithClient(nil, mj, c)
+	stored := func() *jobsmanagerv1beta1.ManagedJob {
+		workflow := &jobsmanagerv1beta1.ManagedJob{}
+		if err := c.Get(context.Background(), key, workflow); err != nil {
+			t.Fatal(err)
+		}
+		return workflow
+	}
+
+	sync := cp.mj.Spec.Groups[0].Jobs[0]
+	sync.ArchivedLogs, sync.Drift = "s3://logs/sync.log", "suspend"
+	if err := cp.updateCRDStatusDirectly(); err != nil {
+		t.Fatal(err)
+	}
+	if workflow := stored(); workflow.Status.Groups[0].StatusPage != "" || workflow.Status.Groups[0].Jobs[0].ArchivedLogs == "" {
+		t.Fatalf("expected the small workflow to keep its details, got %+v", workflow.Status.Groups[0])
+	}
+
+	cp.mj.Spec.Groups[0].Jobs[0].Args = []string{strings.Repeat("x", statusPageThreshold)}
+	if err := cp.updateCRDStatusDirectly(); err != nil {
+		t.Fatal(err)
+	}
+	sync = cp.mj.Spec.Groups[0].Jobs[0]
+	if sync.ArchivedLogs != "s3://logs/sync.log" || sync.Drift != "suspend" {
+		t.Errorf("expected the details kept in memory, got %+v", sync)
+	}
+	workflow := stored()
+	if workflow.Status.Groups[0].StatusPage != "nightly-extract-status" || workflow.Status.Groups[0].Jobs[0].ArchivedLogs != "" {
+		t.Fatalf("expected the details paged out, got %+v", workflow.Status.Groups[0])
+	}
+	var page corev1.ConfigMap
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "etl", Name: "nightly-extract-status"}, &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Labels[labelWorkflowName] != "nightly" || len(page.OwnerReferences) != 1 {
+		t.Errorf("page metadata = %+v", page.ObjectMeta)
+	}
+
+	if err := LoadStatus(context.Background(), c, workflow); err != nil {
+		t.Fatal(err)
+	}
+	if loaded := workflow.Spec.Groups[0].Jobs[0]; loaded.ArchivedLogs != "s3://logs/sync.log" || loaded.Drift != "suspend" {
+		t.Errorf("expected the details loaded from the page, got %+v", loaded)
+	}
+
+	// the cleared details are cleared in the page too
+	sync.Drift = ""
+	if err := cp.updateCRDStatusDirectly(); err != nil {
+		t.Fatal(err)
+	}
+	workflow = stored()
+	if err := LoadStatus(context.Background(), c, workflow); err != nil {
+		t.Fatal(err)
+	}
+	if loaded := workflow.Spec.Groups[0].Jobs[0]; loaded.ArchivedLogs != "s3://logs/sync.log" || loaded.Drift != "" {
+		t.Errorf("expected the drift cleared, got %+v", loaded)
+	}
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"sort"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+/* Stray Jobs - labelled as children of the workflow but not matching any of its jobs */
+
+const (
+	StrayJobPolicyReport = "Report"
+	StrayJobPolicyDelete = "Delete"
+	StrayJobPolicyAdopt  = "Adopt"
+)
+
+// strayJobs returns the Jobs which names do not match any job of the workflow, sorted by the name.
+// The cache lists them in no particular order, the reported names would change on every reconcile.
+func strayJobs(mj *jobsmanagerv1beta1.ManagedJob, childJobs []kbatch.Job) []*kbatch.Job {
+	expected := map[string]bool{}
+	for _, group := range mj.Spec.Groups {
+		for _, job := range group.Jobs {
+			expected[jobNameGenerator(mj.Name, group.Name, job.Name)] = true
+		}
+	}
+	stray := []*kbatch.Job{}
+	for i := range childJobs {
+		if !expected[childJobs[i].Name] {
+			stray = append(stray, &childJobs[i])
+		}
+	}
+	sort.Slice(stray, func(i, j int) bool { return stray[i].Name < stray[j].Name })
+	return stray
+}
+
+func ownedBy(obj metav1.Object, mj *jobsmanagerv1beta1.ManagedJob) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == mj.UID {
+			return true
+		}
+	}
+	return false
+}
+
+// checkStrayJobs reports, deletes or adopts the stray Jobs according to the strayJobPolicy
+func (cp *connPackage) checkStrayJobs() {
+	var childJobs kbatch.JobList
+	labelSelector := labels.SelectorFromSet(labels.Set{
+		labelWorkflowName: cp.mj.Name,
+	})
+	err := cp.client.List(cp.ctx, &childJobs, &client.ListOptions{LabelSelector: labelSelector, Namespace: cp.mj.Namespace})
+	if err != nil {
+		log.Log.Info("Unable to list child jobs", "error", err.Error())
+		return
+	}
+
+	reported := map[string]bool{}
+	for _, name := range cp.mj.Spec.StrayJobs {
+		reported[name] = true
+	}
+	found := []string{}
+	for _, stray := range strayJobs(cp.mj, childJobs.Items) {
+		switch cp.mj.Spec.StrayJobPolicy {
+		case StrayJobPolicyDelete:
+			err := cp.client.Delete(cp.ctx, stray, client.PropagationPolicy(metav1.DeletePropagationBackground))
+			if client.IgnoreNotFound(err) != nil {
+				cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "StrayJob", "Unable to delete stray job %s: %s", stray.Name, err.Error())
+				found = append(found, stray.Name)
+				continue
+			}
+			cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "StrayJob", "Deleted stray job %s", stray.Name)
+			continue
+		case StrayJobPolicyAdopt:
+			if !ownedBy(stray, cp.mj) && metav1.GetControllerOf(stray) == nil {
+				ownerReference, err := cp.getOwnerReference()
+				if err == nil {
+					stray.OwnerReferences = append(stray.OwnerReferences, ownerReference)
+					err = cp.client.Update(cp.ctx, stray)
+				}
+				if err != nil {
+					cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "StrayJob", "Unable to adopt stray job %s: %s", stray.Name, err.Error())
+				} else {
+					cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "StrayJob", "Adopted stray job %s", stray.Name)
+				}
+			}
+		default:
+			if !reported[stray.Name] {
+				cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "StrayJob", "Job %s is labelled as part of the workflow but does not match any of its jobs", stray.Name)
+			}
+		}
+		found = append(found, stray.Name)
+	}
+	if len(found) == 0 {
+		found = nil
+	}
+	cp.mj.Spec.StrayJobs = found
+}
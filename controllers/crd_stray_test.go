@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"testing"
+
+	kbatch "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestStrayJobs(t *testing.T) {
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly"},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+			{Name: "extract", Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "download"}}},
+		}},
+	}
+	childJobs := []kbatch.Job{
+		{ObjectMeta: metav1.ObjectMeta{Name: "nightly-fetch-download"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "nightly-extract-download"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "nightly-extract-download-copy"}},
+	}
+
+	stray := strayJobs(mj, childJobs)
+	if len(stray) != 2 || stray[0].Name != "nightly-extract-download-copy" || stray[1].Name != "nightly-fetch-download" {
+		t.Errorf("unexpected stray jobs %v", stray)
+	}
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+/* Sub-workflows - a job which runs another ManagedJob */
+
+// resetWorkflow clears the runtime state, the approvals and the template marker copied over from the
+// referenced ManagedJob. It prepares the spec of the sub-workflow the operator creates, the template itself
+// is left as it is.
+func resetWorkflow(mj *jobsmanagerv1beta1.ManagedJob) {
+	mj.Spec.Template = false
+	for _, group := range mj.Spec.Groups {
+		group.Approved = false
+		for _, job := range group.Jobs {
+			job.Outcome = ""
+		}
+	}
+	mj.ClearRuntimeState()
+}
+
+func (cp *connPackage) executeWorkflow(j *jobsmanagerv1beta1.ManagedJobDefinition, g *jobsmanagerv1beta1.ManagedJobGroup) (err error) {
+	if j.Workflow == nil || j.Workflow.Name == "" {
+		return fmt.Errorf("job %s is of type %s but has no workflow reference", j.Name, JobTypeWorkflow)
+	}
+	if j.Workflow.Name == cp.mj.Name {
+		return fmt.Errorf("job %s references its own workflow %s", j.Name, cp.mj.Name)
+	}
+
+	template := &jobsmanagerv1beta1.ManagedJob{}
+	err = cp.client.Get(cp.ctx, types.NamespacedName{Namespace: cp.mj.Namespace, Name: j.Workflow.Name}, template)
+	if err != nil {
+		return err
+	}
+	// the workflows which are not templates run on their own, running them as sub-workflows too would run them twice
+	if !template.Spec.Template {
+		return fmt.Errorf("job %s references the workflow %s which is not marked as a template", j.Name, j.Workflow.Name)
+	}
+
+	// labels of the parent are inherited so the sub-workflow is reconciled by the same shard
+	childLabels := map[string]string{}
+	for k, v := range cp.mj.Labels {
+		childLabels[k] = v
+	}
+	for k, v := range cp.jobLabels(g, j) {
+		childLabels[k] = v
+	}
+
+	generatedJobName := jobNameGenerator(cp.mj.Name, g.Name, j.Name)
+	childWorkflow := jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      generatedJobName,
+			Namespace: cp.mj.Namespace,
+			Labels:    childLabels,
+		},
+		Spec: *template.Spec.DeepCopy(),
+	}
+	resetWorkflow(&childWorkflow)
+	// parent level parameters are passed down to the sub-workflow
+	params, _ := cp.jobParameters(g, j)
+	childWorkflow.Spec.Params = cp.compileParameters(params, childWorkflow.Spec.Params)
+	if j.TimeoutSeconds != nil {
+		childWorkflow.Spec.ActiveDeadlineSeconds = j.TimeoutSeconds
+	}
+
+	getMetaRefForWorkflowData, err := cp.getOwnerReference()
+	if err != nil {
+		return err
+	}
+	childWorkflow.SetOwnerReferences([]metav1.OwnerReference{getMetaRefForWorkflowData})
+	// the annotation tells rootWorkflow the owner was set by the operator, the webhook rejects it from the clients
+	childWorkflow.SetAnnotations(map[string]string{jobsmanagerv1beta1.SubWorkflowOfAnnotation: string(getMetaRefForWorkflowData.UID)})
+
+	err = cp.client.Create(cp.ctx, &childWorkflow)
+	if err != nil {
+		return err
+	}
+
+	cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Created", "Created sub-workflow %s from %s", childWorkflow.Name, j.Workflow.Name)
+	return nil
+}
+
+func (cp *connPackage) checkRunningWorkflowsStatus() {
+	var childWorkflows jobsmanagerv1beta1.ManagedJobList
+	labelSelector := labels.SelectorFromSet(labels.Set{
+		labelWorkflowName: cp.mj.Name,
+	})
+	listOptions := &client.ListOptions{LabelSelector: labelSelector, Namespace: cp.mj.Namespace}
+	err := cp.client.List(cp.ctx, &childWorkflows, listOptions)
+	if err != nil {
+		log.Log.Info("Unable to list child workflows", "error", err.Error())
+		return
+	}
+
+	runStartedAt := cp.currentRunStartedAt()
+	for _, childWorkflow := range childWorkflows.Items {
+		if childWorkflow.CreationTimestamp.Before(&runStartedAt) {
+			continue // left over from the previous run
+		}
+		for _, group := range cp.mj.Spec.Groups {
+			for _, job := range group.Jobs {
+				generatedJobName := jobNameGenerator(cp.mj.Name, group.Name, job.Name)
+				if job.Type != JobTypeWorkflow || childWorkflow.Name != generatedJobName {
+					continue
+				}
+				switch childWorkflow.Status.Phase {
+				case ExecutionStatusSucceeded:
+					if job.Status != ExecutionStatusSucceeded {
+						cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Completed", "Sub-workflow %s completed [prev: %s]", childWorkflow.Name, job.Status)
+						job.Status = ExecutionStatusSucceeded
+					}
+				case ExecutionStatusFailed, ExecutionStatusAborted:
+					if job.Status != ExecutionStatusFailed {
+						cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "Failed", "Sub-workflow %s failed [prev: %s]", childWorkflow.Name, job.Status)
+						job.Status = ExecutionStatusFailed
+					}
+				case ExecutionStatusRunning:
+					if job.Status != ExecutionStatusRunning {
+						cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Running", "Sub-workflow %s running [prev: %s]", childWorkflow.Name, job.Status)
+						job.Status = ExecutionStatusRunning
+					}
+				}
+			}
+		}
+	}
+}
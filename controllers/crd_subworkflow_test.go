@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestExecuteWorkflowFromTemplate(t *testing.T) {
+	build := func(name string, template bool) *jobsmanagerv1beta1.ManagedJob {
+		return &jobsmanagerv1beta1.ManagedJob{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "apps", UID: types.UID(name + "-uid")},
+			Spec: jobsmanagerv1beta1.ManagedJobSpec{Image: "busybox", Template: template, Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+				{Name: "build", Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "compile"}}},
+			}},
+		}
+	}
+	job := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "pipeline", Type: JobTypeWorkflow, Workflow: &jobsmanagerv1beta1.ManagedJobWorkflowReference{Name: "build-pipeline"}}
+	group := &jobsmanagerv1beta1.ManagedJobGroup{Name: "release", Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{job}}
+	parent := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "apps", UID: "nightly-uid"},
+		Spec:       jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{group}},
+	}
+	c := newTestClientBuilder(parent, build("build-pipeline", false)).Build()
+	cp := newTestConnPackageWithClient(&ManagedJobReconciler{Recorder: record.NewFakeRecorder(10)}, parent, c)
+
+	// a regular workflow runs on its own, it's not run as a sub-workflow too
+	if err := cp.executeWorkflow(job, group); err == nil || !strings.Contains(err.Error(), "not marked as a template") {
+		t.Fatalf("expected the workflow which is not a template refused, got %v", err)
+	}
+
+	template := build("build-pipeline", true)
+	stored := &jobsmanagerv1beta1.ManagedJob{}
+	if err := c.Get(cp.ctx, client.ObjectKeyFromObject(template), stored); err != nil {
+		t.Fatal(err)
+	}
+	stored.Spec.Template = true
+	if err := c.Update(cp.ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.executeWorkflow(job, group); err != nil {
+		t.Fatalf("expected the sub-workflow created from the template, got %v", err)
+	}
+	child := &jobsmanagerv1beta1.ManagedJob{}
+	if err := c.Get(cp.ctx, client.ObjectKey{Namespace: "apps", Name: jobNameGenerator("nightly", "release", "pipeline")}, child); err != nil {
+		t.Fatal(err)
+	}
+	if child.Spec.Template || child.Spec.Groups[0].Jobs[0].Name != "compile" {
+		t.Errorf("expected the sub-workflow to run the jobs of the template, got %+v", child.Spec)
+	}
+}
+
+func TestQueuePositionSkipsTemplates(t *testing.T) {
+	pending := func(name string, template bool) jobsmanagerv1beta1.ManagedJob {
+		return jobsmanagerv1beta1.ManagedJob{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "apps"},
+			Spec: jobsmanagerv1beta1.ManagedJobSpec{Template: template, Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+				{Name: "build", Status: ExecutionStatusPending, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "compile", Status: ExecutionStatusPending}}},
+			}},
+		}
+	}
+	mj := pending("nightly", false)
+	if position := queuePosition(&mj, []jobsmanagerv1beta1.ManagedJob{pending("a-template", true)}, 1); position != 0 {
+		t.Errorf("expected the template not to hold the slot, got position %d", position)
+	}
+}
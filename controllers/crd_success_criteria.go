@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+/*
+Success criteria - spec.successCriteria replaces the all groups have to succeed rule with a CEL expression,
+see the v1beta1 package for its variables. The run goes on until every group finished, the failed ones
+included, and succeeds or fails by the result of the expression. The expression failing to evaluate, e.g.
+referring to a group which does not exist, fails the run. The runs aborted by the operator, on request,
+past the deadline or in the namespace being deleted, fail whatever the criteria say.
+*/
+
+const (
+	ReasonSuccessCriteriaMet    = "SuccessCriteriaMet"
+	ReasonSuccessCriteriaNotMet = "SuccessCriteriaNotMet"
+)
+
+// successCriteriaStatus is the status of the workflow judged by its success criteria
+func successCriteriaStatus(spec *jobsmanagerv1beta1.ManagedJobSpec) string {
+	for _, group := range spec.Groups {
+		switch group.Status {
+		case ExecutionStatusSucceeded, ExecutionStatusFailed, ExecutionStatusAborted, ExecutionStatusSkipped:
+		default:
+			return ExecutionStatusRunning
+		}
+	}
+	if abortedByOperator(spec) {
+		return ExecutionStatusFailed
+	}
+	if met, err := spec.SuccessCriteriaMet(); err != nil || !met {
+		return ExecutionStatusFailed
+	}
+	return ExecutionStatusSucceeded
+}
+
+// abortedByOperator tells if the operator aborted the run, the groups aborted after the failed
+// dependencies don't count
+func abortedByOperator(spec *jobsmanagerv1beta1.ManagedJobSpec) bool {
+	for _, group := range spec.Groups {
+		if group.Status != ExecutionStatusAborted {
+			continue
+		}
+		switch group.Reason {
+		case ReasonAborted, ReasonDeadlineExceeded, ReasonNamespaceTerminating:
+			return true
+		}
+	}
+	return false
+}
+
+// reportSuccessCriteria emits the outcome of the success criteria once the run finished
+func (cp *connPackage) reportSuccessCriteria(status string) {
+	spec := &cp.mj.Spec
+	if spec.SuccessCriteria == "" || status == cp.mj.Status.Phase || abortedByOperator(spec) {
+		return
+	}
+	if status != ExecutionStatusSucceeded && status != ExecutionStatusFailed {
+		return
+	}
+	met, err := spec.SuccessCriteriaMet()
+	switch {
+	case err != nil:
+		cp.r.Recorder.Event(cp.mj, corev1.EventTypeWarning, ReasonSuccessCriteriaNotMet, "Unable to evaluate the success criteria: "+err.Error())
+	case met:
+		cp.r.Recorder.Event(cp.mj, corev1.EventTypeNormal, ReasonSuccessCriteriaMet, "Success criteria met")
+	default:
+		cp.r.Recorder.Event(cp.mj, corev1.EventTypeWarning, ReasonSuccessCriteriaNotMet, "Success criteria not met")
+	}
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestSuccessCriteriaStatus(t *testing.T) {
+	shard := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "shard-1", Status: ExecutionStatusFailed}
+	report := &jobsmanagerv1beta1.ManagedJobGroup{Name: "report", Status: ExecutionStatusRunning, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+		{Name: "publish", Status: ExecutionStatusRunning},
+	}}
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly"},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{
+			SuccessCriteria: "groups.shards.succeeded >= 1 && groups.report.status == 'succeeded'",
+			Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+				{Name: "shards", Status: ExecutionStatusFailed, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+					shard, {Name: "shard-2", Status: ExecutionStatusSucceeded},
+				}},
+				report,
+			},
+		},
+		Status: jobsmanagerv1beta1.ManagedJobStatus{Phase: ExecutionStatusRunning},
+	}
+	recorder := record.NewFakeRecorder(10)
+	cp := &connPackage{r: &ManagedJobReconciler{Recorder: recorder}, mj: mj}
+
+	// the failed group does not end the run, the criteria wait for all the groups
+	if status := workflowStatus(&mj.Spec); status != ExecutionStatusRunning {
+		t.Errorf("expected the run going on, got %s", status)
+	}
+
+	report.Status, report.Jobs[0].Status = ExecutionStatusSucceeded, ExecutionStatusSucceeded
+	status := workflowStatus(&mj.Spec)
+	cp.reportSuccessCriteria(status)
+	mj.Status.Phase = status
+	cp.setRunConditions()
+	if status != ExecutionStatusSucceeded || meta.FindStatusCondition(mj.Spec.Conditions, ConditionReady).Reason != ReasonSuccessCriteriaMet {
+		t.Errorf("expected the run succeeded by the criteria, got %s with %+v", status, mj.Spec.Conditions)
+	}
+	if event := <-recorder.Events; !strings.Contains(event, ReasonSuccessCriteriaMet) {
+		t.Errorf("unexpected event %q", event)
+	}
+
+	mj.Spec.SuccessCriteria = "groups.shards.failed == 0"
+	mj.Status.Phase = ExecutionStatusRunning
+	status = workflowStatus(&mj.Spec)
+	cp.reportSuccessCriteria(status)
+	mj.Status.Phase = status
+	cp.setRunConditions()
+	if status != ExecutionStatusFailed || meta.FindStatusCondition(mj.Spec.Conditions, ConditionFailed).Reason != ReasonSuccessCriteriaNotMet {
+		t.Errorf("expected the run failed by the criteria, got %s with %+v", status, mj.Spec.Conditions)
+	}
+	if event := <-recorder.Events; !strings.Contains(event, ReasonSuccessCriteriaNotMet) {
+		t.Errorf("unexpected event %q", event)
+	}
+
+	// the run aborted on request fails whatever the criteria say
+	mj.Spec.SuccessCriteria = "true"
+	report.Status, report.Reason = ExecutionStatusAborted, ReasonAborted
+	if status := workflowStatus(&mj.Spec); status != ExecutionStatusFailed {
+		t.Errorf("expected the aborted run failed, got %s", status)
+	}
+}
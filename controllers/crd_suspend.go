@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+/*
+Suspended workflows - no new jobs are started while spec.suspend is set, the running ones finish. Workflows
+created with startSuspended are held before their first run starts, so the CI pipelines can deliver them
+and leave the start to an approver or another system requesting the start action. The hold is the state of
+the operator, recorded in the status once released - the spec is never changed, so the GitOps tools see no
+drift and don't revert the release.
+*/
+
+const GroupReasonSuspended = "Suspended"
+
+// suspended tells if the workflow starts no new jobs
+func (cp *connPackage) suspended() bool {
+	return cp.mj.Spec.Suspend || heldForStart(&cp.mj.Spec)
+}
+
+// heldForStart tells if the workflow created with startSuspended waits for the start action before its first
+// run, the one recorded when the workflow was created
+func heldForStart(spec *jobsmanagerv1beta1.ManagedJobSpec) bool {
+	return spec.StartSuspended && !spec.StartReleased && len(spec.RunHistory) <= 1 && !workflowStarted(spec)
+}
+
+// holdSuspendedGroups marks the groups which would start jobs as suspended
+func (cp *connPackage) holdSuspendedGroups() {
+	if !cp.holdGroups(GroupReasonSuspended, "workflow suspended") {
+		return
+	}
+	if cp.mj.Spec.Suspend {
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, GroupReasonSuspended, "Workflow suspended, no new jobs are started until spec.suspend is set to false")
+		return
+	}
+	cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, GroupReasonSuspended, "Workflow created suspended, its first run waits for the %s action", ActionStart)
+}
+
+// endSuspension clears the reason of the groups held while the workflow was suspended
+func (cp *connPackage) endSuspension() {
+	if cp.releaseGroups(GroupReasonSuspended) {
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, "Resumed", "Workflow resumed")
+	}
+}
+
+// holdGroups marks the groups which would start jobs with the reason, reporting if any was not held before
+func (cp *connPackage) holdGroups(reason string, decisionReason string) bool {
+	held := false
+	for _, group := range cp.mj.Spec.Groups {
+		if group.Status != ExecutionStatusPending && group.Status != ExecutionStatusRunning || !dependenciesMet(group.Dependencies) {
+			continue
+		}
+		waiting := false
+		for _, job := range group.Jobs {
+			if job.Status == ExecutionStatusPending && dependenciesMet(job.Dependencies) {
+				cp.recordDecision(decision{Action: DecisionSkip, Group: group.Name, Job: job.Name, Reason: decisionReason, Dependencies: job.Dependencies})
+				waiting = true
+			}
+		}
+		if !waiting {
+			continue
+		}
+		if group.Reason != reason {
+			held = true
+		}
+		group.Reason = reason
+	}
+	return held
+}
+
+// releaseGroups clears the reason of the held groups, reporting if any was held
+func (cp *connPackage) releaseGroups(reason string) bool {
+	released := false
+	for _, group := range cp.mj.Spec.Groups {
+		if group.Reason == reason {
+			group.Reason = ""
+			released = true
+		}
+	}
+	return released
+}
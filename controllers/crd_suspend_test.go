@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestStartSuspended(t *testing.T) {
+	job := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "sign-off", Type: JobTypeManual, Status: ExecutionStatusPending}
+	group := &jobsmanagerv1beta1.ManagedJobGroup{Name: "release", Status: ExecutionStatusPending, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{job}}
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "deploy"},
+		Spec:       jobsmanagerv1beta1.ManagedJobSpec{StartSuspended: true, Groups: []*jobsmanagerv1beta1.ManagedJobGroup{group}},
+	}
+	cp := &connPackage{r: &ManagedJobReconciler{Recorder: record.NewFakeRecorder(10)}, ctx: context.Background(), mj: mj}
+
+	cp.runPendingJobs()
+	cp.trackRuns()
+	if !cp.suspended() || mj.Spec.Suspend || job.Status != ExecutionStatusPending || group.Reason != GroupReasonSuspended {
+		t.Fatalf("expected the workflow held without changing the spec, got suspend %t, job %s, reason %s", mj.Spec.Suspend, job.Status, group.Reason)
+	}
+	if status := workflowStatus(&mj.Spec); status != ExecutionStatusRunning {
+		t.Fatalf("unexpected status %s", status)
+	}
+
+	// released by the start action, startSuspended applies to the first run only
+	mj.Annotations = map[string]string{AnnotationAction: ActionStart}
+	cp.checkRequestedAction()
+	cp.runPendingJobs()
+	if !mj.Spec.StartReleased || cp.suspended() || job.Status != ExecutionStatusRunning || group.Reason != "" {
+		t.Errorf("expected the released workflow started, got released %t, job %s, reason %s", mj.Spec.StartReleased, job.Status, group.Reason)
+	}
+	split := mj.DeepCopy()
+	split.SplitStatus()
+	if !split.Status.StartReleased || !split.Spec.StartSuspended {
+		t.Errorf("expected the release recorded in the status, got %+v", split.Status)
+	}
+}
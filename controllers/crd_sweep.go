@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/lukaszraczylo/pandati"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+/*
+Sweep of the non-terminal workflows - jobs finishing while the operator was down leave their
+workflows stale until the next requeue, so all of them are enqueued right after the start
+and then periodically every ResyncInterval as a safety net.
+*/
+
+// sweepRunnable sends the non-terminal workflows to the sweep channel until the manager stops
+func sweepRunnable(c client.Reader, interval time.Duration, sweep chan<- event.GenericEvent) manager.Runnable {
+	return manager.RunnableFunc(func(ctx context.Context) error {
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			if err := sweepWorkflows(ctx, c, sweep); err != nil {
+				log.Log.Info("Unable to sweep workflows", "error", err.Error())
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-tick:
+			}
+		}
+	})
+}
+
+func sweepWorkflows(ctx context.Context, c client.Reader, sweep chan<- event.GenericEvent) error {
+	var workflows jobsmanagerv1beta1.ManagedJobList
+	if err := c.List(ctx, &workflows); err != nil {
+		return err
+	}
+	// invalid workflows are reconciled once they are edited
+	terminalStatuses := []string{ExecutionStatusSucceeded, ExecutionStatusFailed, ExecutionStatusInvalid}
+	for i := range workflows.Items {
+		workflow := &workflows.Items[i]
+		if pandati.ExistsInSlice(terminalStatuses, workflow.Status.Phase) {
+			continue
+		}
+		select {
+		case sweep <- event.GenericEvent{Object: workflow}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+/*
+API server pressure - 429 responses and the 5xx ones say nothing about the job, so the job which could
+not be created stays pending and the workflow is requeued after the Retry-After of the response, or
+throttledRequeue without it, instead of being failed. The responses are counted per kind.
+*/
+
+const (
+	MetricAPIThrottled = "managedjob_api_throttled_total"
+
+	throttledRequeue = 10 * time.Second
+	// throttledRequeueLimit caps the Retry-After of the server, a longer one is not waited for in full
+	throttledRequeueLimit = 5 * time.Minute
+
+	throttledTooManyRequests = "too_many_requests"
+	throttledServerError     = "server_error"
+)
+
+var apiThrottledCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: MetricAPIThrottled,
+	Help: "API responses which made the operator back off, too_many_requests (429) or server_error (5xx)",
+}, MetricLabels[MetricAPIThrottled])
+
+func init() {
+	metrics.Registry.MustRegister(apiThrottledCounter)
+}
+
+// transientAPIError tells if the error is a response of the overloaded or failing API server, returning
+// the kind of the response and the delay it asked for
+func transientAPIError(err error) (string, time.Duration, bool) {
+	var status apierrors.APIStatus
+	if err == nil || !errors.As(err, &status) {
+		return "", 0, false
+	}
+	kind := throttledServerError
+	switch code := status.Status().Code; {
+	case code == http.StatusTooManyRequests:
+		kind = throttledTooManyRequests
+	case code < http.StatusInternalServerError:
+		return "", 0, false
+	}
+	delay := throttledRequeue
+	if seconds, found := apierrors.SuggestsClientDelay(err); found && seconds > 0 {
+		delay = time.Duration(seconds) * time.Second
+	}
+	if delay > throttledRequeueLimit {
+		delay = throttledRequeueLimit
+	}
+	return kind, delay, true
+}
+
+// apiThrottled counts the transient error of the API server and requeues the workflow after the delay
+// the server asked for, reporting if the error was transient
+func (cp *connPackage) apiThrottled(err error) bool {
+	kind, delay, transient := transientAPIError(err)
+	if !transient {
+		return false
+	}
+	apiThrottledCounter.WithLabelValues(kind).Inc()
+	log.Log.Info("API server is throttling, backing off", "workflow", cp.mj.Name, "namespace", cp.mj.Namespace, "after", delay.String(), "error", err.Error())
+	cp.requeueIn(delay)
+	return true
+}
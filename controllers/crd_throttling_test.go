@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestTransientAPIError(t *testing.T) {
+	jobs := schema.GroupResource{Group: "batch", Resource: "jobs"}
+	for _, tc := range []struct {
+		err       error
+		kind      string
+		delay     time.Duration
+		transient bool
+	}{
+		{err: apierrors.NewTooManyRequests("slow down", 7), kind: throttledTooManyRequests, delay: 7 * time.Second, transient: true},
+		{err: apierrors.NewTooManyRequests("slow down", 0), kind: throttledTooManyRequests, delay: throttledRequeue, transient: true},
+		{err: apierrors.NewTooManyRequests("slow down", 3600), kind: throttledTooManyRequests, delay: throttledRequeueLimit, transient: true},
+		{err: apierrors.NewInternalError(errors.New("etcd leader changed")), kind: throttledServerError, delay: throttledRequeue, transient: true},
+		{err: apierrors.NewServiceUnavailable("unavailable"), kind: throttledServerError, delay: throttledRequeue, transient: true},
+		{err: apierrors.NewServerTimeout(jobs, "create", 2), kind: throttledServerError, delay: 2 * time.Second, transient: true},
+		{err: apierrors.NewAlreadyExists(jobs, "nightly-load-extract")},
+		{err: apierrors.NewForbidden(jobs, "nightly-load-extract", errors.New("quota exceeded"))},
+		{err: errors.New("invalid params patch")},
+		{},
+	} {
+		kind, delay, transient := transientAPIError(tc.err)
+		if kind != tc.kind || delay != tc.delay || transient != tc.transient {
+			t.Errorf("%v: got %q, %s, %v", tc.err, kind, delay, transient)
+		}
+	}
+}
+
+func TestThrottledJobStaysPending(t *testing.T) {
+	job := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "extract", Image: "busybox:1.36", Status: ExecutionStatusPending}
+	group := &jobsmanagerv1beta1.ManagedJobGroup{Name: "load", Status: ExecutionStatusPending, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{job}}
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "apps"},
+		Spec:       jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{group}},
+	}
+	throttled := true
+	c := newTestClientBuilder(mj).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if _, isJob := obj.(*kbatch.Job); isJob && throttled {
+				return apierrors.NewTooManyRequests("too many requests, please try again later", 20)
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	}).Build()
+	cp := newTestConnPackageWithClient(&ManagedJobReconciler{Recorder: record.NewFakeRecorder(10)}, mj, c)
+
+	cp.runPendingJobs()
+	if job.Status != ExecutionStatusPending || group.Status == ExecutionStatusFailed || job.Reason == ReasonCreateFailed {
+		t.Fatalf("expected the throttled job pending, got %s (%s) in the %s group", job.Status, job.Reason, group.Status)
+	}
+	if cp.requeueAfter != 20*time.Second {
+		t.Errorf("expected the requeue after the Retry-After, got %s", cp.requeueAfter)
+	}
+
+	throttled = false
+	cp.runPendingJobs()
+	if job.Status != ExecutionStatusRunning {
+		t.Errorf("expected the job started once the API server recovered, got %s", job.Status)
+	}
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+/*
+Time windows of the steps - notBefore holds the ready group or job until the given time, notAfter skips
+the one which could not start by then. Both are evaluated only once the dependencies are met, skipping
+the blocked step would let its dependents start before the work it waits for.
+*/
+
+// ReasonExpired marks the groups and jobs skipped because they were ready only after their notAfter
+const ReasonExpired = "Expired"
+
+// timeConstraint resolves the notBefore or notAfter of the current run, the invalid values are ignored
+func (cp *connPackage) timeConstraint(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	at, err := jobsmanagerv1beta1.ResolveTimeConstraint(value, runStartedAt(cp.mj))
+	if err != nil {
+		log.Log.Info("Ignoring the invalid time constraint", "workflow", cp.mj.Name, "value", value, "error", err.Error())
+		return time.Time{}, false
+	}
+	return at, true
+}
+
+// expired tells if the notAfter of the step has passed
+func (cp *connPackage) expired(notAfter string, now time.Time) bool {
+	at, set := cp.timeConstraint(notAfter)
+	return set && now.After(at)
+}
+
+// groupExpired skips the ready group which notAfter has passed together with its jobs
+func (cp *connPackage) groupExpired(group *jobsmanagerv1beta1.ManagedJobGroup) bool {
+	if !cp.expired(group.NotAfter, cp.now()) {
+		return false
+	}
+	cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, ReasonExpired, "Group %s skipped, it was not able to start before %s", group.Name, group.NotAfter)
+	group.Status = ExecutionStatusSkipped
+	group.Reason = ReasonExpired
+	for _, job := range group.Jobs {
+		if job.Status == ExecutionStatusPending || job.Status == "" {
+			cp.recordDecision(decision{Action: DecisionSkip, Group: group.Name, Job: job.Name, Reason: "group not started before its notAfter"})
+			job.Status = ExecutionStatusSkipped
+			job.Reason = ReasonExpired
+		}
+	}
+	return true
+}
+
+// jobWithinTimeWindow checks the ready job against its notBefore and notAfter, the late job is skipped
+// and the early one waits for its time
+func (cp *connPackage) jobWithinTimeWindow(group *jobsmanagerv1beta1.ManagedJobGroup, job *jobsmanagerv1beta1.ManagedJobDefinition) bool {
+	now := cp.now()
+	if cp.expired(job.NotAfter, now) {
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, ReasonExpired, "Job %s of group %s skipped, it was not able to start before %s", job.Name, group.Name, job.NotAfter)
+		cp.recordDecision(decision{Action: DecisionSkip, Group: group.Name, Job: job.Name, Reason: "not started before its notAfter"})
+		job.Status = ExecutionStatusSkipped
+		job.Reason = ReasonExpired
+		return false
+	}
+	if notBefore, set := cp.timeConstraint(job.NotBefore); set && now.Before(notBefore) {
+		cp.recordDecision(decision{Action: DecisionSkip, Group: group.Name, Job: job.Name, Reason: "waits for its notBefore " + notBefore.Format(time.RFC3339)})
+		cp.requeueIn(notBefore.Sub(now))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestJobWithinTimeWindow(t *testing.T) {
+	startedAt := time.Now().Add(-time.Hour)
+	late := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "load-test", Status: ExecutionStatusPending, NotAfter: "30m"}
+	early := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "report", Status: ExecutionStatusPending, NotBefore: "90m"}
+	onTime := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "cleanup", Status: ExecutionStatusPending, NotBefore: "30m", NotAfter: startedAt.Add(2 * time.Hour).Format(time.RFC3339)}
+	group := &jobsmanagerv1beta1.ManagedJobGroup{Name: "nightly", Status: ExecutionStatusRunning, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{late, early, onTime}}
+	cp := &connPackage{
+		r: &ManagedJobReconciler{Recorder: record.NewFakeRecorder(10)},
+		mj: &jobsmanagerv1beta1.ManagedJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "perf"},
+			Spec: jobsmanagerv1beta1.ManagedJobSpec{
+				Groups:     []*jobsmanagerv1beta1.ManagedJobGroup{group},
+				RunHistory: []jobsmanagerv1beta1.ManagedJobRunRecord{{StartedAt: metav1.NewTime(startedAt)}},
+			},
+		},
+	}
+
+	if cp.jobWithinTimeWindow(group, late) || late.Status != ExecutionStatusSkipped || late.Reason != ReasonExpired {
+		t.Errorf("expected the late job skipped, got %s %s", late.Status, late.Reason)
+	}
+	if cp.jobWithinTimeWindow(group, early) || early.Status != ExecutionStatusPending {
+		t.Errorf("expected the early job to wait, got %s", early.Status)
+	}
+	if cp.requeueAfter <= 0 || cp.requeueAfter > 30*time.Minute {
+		t.Errorf("expected the requeue at the notBefore of the early job, got %s", cp.requeueAfter)
+	}
+	if !cp.jobWithinTimeWindow(group, onTime) {
+		t.Error("expected the job within its window to start")
+	}
+
+	// the skipped job does not hold its group
+	early.Status, onTime.Status = ExecutionStatusSucceeded, ExecutionStatusSucceeded
+	cp.propagateStatuses()
+	classifyPending(&cp.mj.Spec)
+	if group.Status != ExecutionStatusSucceeded || late.Reason != ReasonExpired {
+		t.Errorf("expected the group succeeded with the expired job, got %s %s", group.Status, late.Reason)
+	}
+}
+
+func TestGroupExpired(t *testing.T) {
+	migrate := &jobsmanagerv1beta1.ManagedJobGroup{Name: "migrate", Status: ExecutionStatusPending, NotAfter: time.Now().Add(-time.Minute).Format(time.RFC3339),
+		Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "schema", Status: ExecutionStatusPending}}}
+	deploy := &jobsmanagerv1beta1.ManagedJobGroup{Name: "deploy", Status: ExecutionStatusPending, NotAfter: "24h",
+		Dependencies: []*jobsmanagerv1beta1.ManagedJobDependencies{{Name: "migrate", Status: ExecutionStatusPending}},
+		Jobs:         []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "rollout", Status: ExecutionStatusPending}}}
+	cp := &connPackage{
+		r: &ManagedJobReconciler{Recorder: record.NewFakeRecorder(10)},
+		mj: &jobsmanagerv1beta1.ManagedJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "release", CreationTimestamp: metav1.Now()},
+			Spec:       jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{migrate, deploy}},
+		},
+	}
+
+	if !cp.groupExpired(migrate) || cp.groupExpired(deploy) {
+		t.Fatal("expected only the group past its notAfter expired")
+	}
+	cp.propagateStatuses()
+	if migrate.Status != ExecutionStatusSkipped || migrate.Jobs[0].Status != ExecutionStatusSkipped {
+		t.Errorf("expected the expired group kept skipped, got %s %s", migrate.Status, migrate.Jobs[0].Status)
+	}
+	if deploy.Dependencies[0].Status != ExecutionStatusSucceeded {
+		t.Errorf("expected the dependents of the skipped group unblocked, got %s", deploy.Dependencies[0].Status)
+	}
+}
@@ -0,0 +1,408 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+/*
+Vault secrets - the jobs with secretsFrom.vault get short-lived credentials for every run. The operator logs
+in to Vault with the kubernetes auth method as the service account of the job, so the Vault role decides
+which jobs may read the secret, and keeps the fields of the secret in a Secret mounted into the job pods.
+Once the job completes the lease of the dynamic secret is revoked and the Secret deleted. The revocation
+logs in with the same role, its policy needs update on sys/leases/revoke/<path>/*. The workflow issuing the
+secrets gets the vault-secrets finalizer, deleting it mid-run revokes the leases of all its secrets before
+it's gone. The Secrets are only ever read by their metadata, the operator does not cache their data.
+*/
+
+const (
+	ReasonVaultSecretFailed = "VaultSecretFailed"
+
+	annotationVaultRole           = "jobsmanager.raczylo.com/vault-role"
+	annotationVaultLease          = "jobsmanager.raczylo.com/vault-lease"
+	annotationVaultServiceAccount = "jobsmanager.raczylo.com/vault-service-account"
+
+	// vaultSecretsFinalizer keeps the workflow until the leases of its secrets are revoked
+	vaultSecretsFinalizer = "jobsmanager.raczylo.com/vault-secrets"
+	// vaultReleaseRequeue retries the revocation of the deleted workflow's leases
+	vaultReleaseRequeue = 30 * time.Second
+
+	vaultVolumeName       = "vault-secrets"
+	defaultVaultMountPath = "/vault/secrets"
+	defaultVaultAuthMount = "kubernetes"
+	// tokens of the service accounts are used for the login only
+	vaultTokenExpiration = int64(600)
+)
+
+// VaultSecrets issues the per run credentials of the jobs from Vault
+type VaultSecrets struct {
+	// Address of Vault, e.g. https://vault.vault.svc:8200
+	Address string
+	// AuthMount is the path of the kubernetes auth method, kubernetes when empty
+	AuthMount string
+	// Audiences of the service account tokens the operator logs in with, the API server ones when empty
+	Audiences []string
+	// Client sends the requests to Vault, http.DefaultClient when nil
+	Client *http.Client
+}
+
+// vaultResponse is the part of the Vault API responses used by the operator
+type vaultResponse struct {
+	LeaseID string                 `json:"lease_id"`
+	Data    map[string]interface{} `json:"data"`
+	Auth    *struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=create;delete
+//+kubebuilder:rbac:groups="",resources=serviceaccounts/token,verbs=create
+
+func (v *VaultSecrets) do(ctx context.Context, method string, path string, token string, body interface{}) (*vaultResponse, error) {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(v.Address, "/")+"/v1/"+strings.TrimPrefix(path, "/"), reader)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		request.Header.Set("X-Vault-Token", token)
+	}
+	httpClient := v.Client
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	result := &vaultResponse{}
+	if response.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(response.Body).Decode(result); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("unexpected response of %s: %w", path, err)
+		}
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, fmt.Errorf("%s %s: %s %s", method, path, response.Status, strings.Join(result.Errors, ", "))
+	}
+	return result, nil
+}
+
+// login returns the Vault token of the role for the service account token
+func (v *VaultSecrets) login(ctx context.Context, role string, jwt string) (string, error) {
+	mount := v.AuthMount
+	if mount == "" {
+		mount = defaultVaultAuthMount
+	}
+	response, err := v.do(ctx, http.MethodPost, "auth/"+strings.Trim(mount, "/")+"/login", "", map[string]string{"role": role, "jwt": jwt})
+	if err != nil {
+		return "", err
+	}
+	if response.Auth == nil || response.Auth.ClientToken == "" {
+		return "", fmt.Errorf("login of the role %s returned no token", role)
+	}
+	return response.Auth.ClientToken, nil
+}
+
+func (v *VaultSecrets) revoke(ctx context.Context, token string, lease string) error {
+	_, err := v.do(ctx, http.MethodPut, "sys/leases/revoke/"+lease, token, nil)
+	return err
+}
+
+// vaultSecretData converts the fields of the secret, the versioned KV secrets are unwrapped
+func vaultSecretData(data map[string]interface{}) (map[string][]byte, error) {
+	if nested, versioned := data["data"].(map[string]interface{}); versioned {
+		if _, found := data["metadata"]; found {
+			data = nested
+		}
+	}
+	secretData := map[string][]byte{}
+	for key, value := range data {
+		if text, isString := value.(string); isString {
+			secretData[key] = []byte(text)
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		secretData[key] = encoded
+	}
+	return secretData, nil
+}
+
+// vaultLogin logs in as the service account of the job
+func (cp *connPackage) vaultLogin(serviceAccount string, role string) (string, error) {
+	if cp.r.Clientset == nil {
+		return "", fmt.Errorf("service account tokens can not be requested without the clientset")
+	}
+	expiration := vaultTokenExpiration
+	tokenRequest, err := cp.r.Clientset.CoreV1().ServiceAccounts(cp.mj.Namespace).CreateToken(cp.ctx, serviceAccount, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{Audiences: cp.r.Vault.Audiences, ExpirationSeconds: &expiration},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+	return cp.r.Vault.login(cp.ctx, role, tokenRequest.Status.Token)
+}
+
+// issueVaultSecret reads the secret of the job run into its Secret and mounts it into the pods of the Job,
+// the Secret left by the previous run is released first
+func (cp *connPackage) issueVaultSecret(j *jobsmanagerv1beta1.ManagedJobDefinition, g *jobsmanagerv1beta1.ManagedJobGroup, job *kbatch.Job) error {
+	params, _ := cp.jobParameters(g, j)
+	if params.SecretsFrom == nil || params.SecretsFrom.Vault == nil {
+		return nil
+	}
+	source := params.SecretsFrom.Vault
+	if cp.r.Vault == nil {
+		return fmt.Errorf("job %s reads secrets from Vault which is not configured for the operator", j.Name)
+	}
+	secretName := job.Name + "-vault"
+	previous := secretMetadata()
+	err := cp.client.Get(cp.ctx, client.ObjectKey{Namespace: cp.mj.Namespace, Name: secretName}, previous)
+	if err == nil {
+		err = cp.releaseVaultSecret(previous)
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	// the lease can't outlive the workflow deleted before the job completes
+	if err := cp.addVaultFinalizer(); err != nil {
+		return err
+	}
+
+	serviceAccount := job.Spec.Template.Spec.ServiceAccountName
+	if serviceAccount == "" {
+		serviceAccount = "default"
+	}
+	token, err := cp.vaultLogin(serviceAccount, source.Role)
+	if err != nil {
+		return fmt.Errorf("unable to log in to Vault as %s: %w", serviceAccount, err)
+	}
+	response, err := cp.r.Vault.do(cp.ctx, http.MethodGet, source.Path, token, nil)
+	if err != nil {
+		return fmt.Errorf("unable to read the Vault secret %s: %w", source.Path, err)
+	}
+	data, err := vaultSecretData(response.Data)
+	if err != nil {
+		return err
+	}
+	owner, err := cp.getOwnerReference()
+	if err != nil {
+		return err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: cp.mj.Namespace,
+			Labels:    cp.jobLabels(g, j),
+			Annotations: map[string]string{
+				annotationVaultRole:           source.Role,
+				annotationVaultLease:          response.LeaseID,
+				annotationVaultServiceAccount: serviceAccount,
+			},
+			OwnerReferences: []metav1.OwnerReference{owner},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+	if err := cp.client.Create(cp.ctx, secret); err != nil {
+		// the lease is not tracked without the Secret
+		if response.LeaseID != "" {
+			_ = cp.r.Vault.revoke(cp.ctx, token, response.LeaseID)
+		}
+		return err
+	}
+
+	mountPath := source.MountPath
+	if mountPath == "" {
+		mountPath = defaultVaultMountPath
+	}
+	mountVaultSecret(&job.Spec.Template.Spec, secretName, mountPath)
+	return nil
+}
+
+// mountVaultSecret mounts the Secret into all the containers of the pod
+func mountVaultSecret(spec *corev1.PodSpec, secretName string, mountPath string) {
+	spec.Volumes = append(append([]corev1.Volume{}, spec.Volumes...), corev1.Volume{
+		Name:         vaultVolumeName,
+		VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: secretName}},
+	})
+	mount := corev1.VolumeMount{Name: vaultVolumeName, MountPath: mountPath, ReadOnly: true}
+	for i := range spec.InitContainers {
+		spec.InitContainers[i].VolumeMounts = append(append([]corev1.VolumeMount{}, spec.InitContainers[i].VolumeMounts...), mount)
+	}
+	for i := range spec.Containers {
+		spec.Containers[i].VolumeMounts = append(append([]corev1.VolumeMount{}, spec.Containers[i].VolumeMounts...), mount)
+	}
+}
+
+// secretMetadata returns the metadata only Secret, the cache of the operator keeps no Secret data
+func secretMetadata() *metav1.PartialObjectMetadata {
+	secret := &metav1.PartialObjectMetadata{}
+	secret.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+	return secret
+}
+
+// issuedVaultSecrets lists the metadata of the Secrets issued from Vault for the jobs of the workflow
+func (cp *connPackage) issuedVaultSecrets() ([]metav1.PartialObjectMetadata, error) {
+	secrets := &metav1.PartialObjectMetadataList{}
+	secrets.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("SecretList"))
+	selector := labels.SelectorFromSet(labels.Set{labelWorkflowName: cp.mj.Name})
+	if err := cp.client.List(cp.ctx, secrets, &client.ListOptions{Namespace: cp.mj.Namespace, LabelSelector: selector}); err != nil {
+		return nil, err
+	}
+	issued := []metav1.PartialObjectMetadata{}
+	for _, secret := range secrets.Items {
+		if _, found := secret.Annotations[annotationVaultRole]; found {
+			// the items of the list may come without their kind, the deletes need it
+			secret.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+			issued = append(issued, secret)
+		}
+	}
+	return issued, nil
+}
+
+// addVaultFinalizer adds the vault-secrets finalizer to the workflow
+func (cp *connPackage) addVaultFinalizer() error {
+	if !controllerutil.AddFinalizer(cp.mj, vaultSecretsFinalizer) {
+		return nil
+	}
+	return cp.patchFinalizers()
+}
+
+// patchFinalizers saves the finalizers of the workflow alone, the runtime state merged into the spec of
+// cp.mj is saved by the status update
+func (cp *connPackage) patchFinalizers() error {
+	patch, err := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{
+		"finalizers":      cp.mj.Finalizers,
+		"resourceVersion": cp.mj.ResourceVersion,
+	}})
+	if err != nil {
+		return err
+	}
+	workflow := &metav1.PartialObjectMetadata{}
+	workflow.SetGroupVersionKind(jobsmanagerv1beta1.GroupVersion.WithKind("ManagedJob"))
+	workflow.SetNamespace(cp.mj.Namespace)
+	workflow.SetName(cp.mj.Name)
+	if err := cp.client.Patch(cp.ctx, workflow, client.RawPatch(types.MergePatchType, patch)); err != nil {
+		return err
+	}
+	cp.mj.ResourceVersion = workflow.ResourceVersion
+	return nil
+}
+
+// finalizeVaultSecrets revokes the leases of all the secrets of the deleted workflow and removes its
+// finalizer once they're gone, it returns false while the leases are not revoked yet
+func (cp *connPackage) finalizeVaultSecrets() bool {
+	if !controllerutil.ContainsFinalizer(cp.mj, vaultSecretsFinalizer) {
+		return true
+	}
+	if cp.r.Vault == nil {
+		// nothing can be revoked without Vault, the Secrets are removed with the workflow by their owner
+		cp.r.Recorder.Event(cp.mj, corev1.EventTypeWarning, ReasonVaultSecretFailed, "Leases of the secrets not revoked, Vault is not configured for the operator")
+	} else {
+		secrets, err := cp.issuedVaultSecrets()
+		if err != nil {
+			cp.reconcileError(err)
+			return false
+		}
+		released := true
+		for i := range secrets {
+			if err := cp.releaseVaultSecret(&secrets[i]); err != nil {
+				cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, ReasonVaultSecretFailed, "Lease of the secret %s not revoked: %s", secrets[i].Name, err.Error())
+				cp.reconcileError(err)
+				released = false
+			}
+		}
+		if !released {
+			return false
+		}
+	}
+	controllerutil.RemoveFinalizer(cp.mj, vaultSecretsFinalizer)
+	if err := cp.patchFinalizers(); err != nil {
+		cp.reconcileError(err)
+		return false
+	}
+	return true
+}
+
+// releaseVaultSecret revokes the lease of the secret and deletes its Secret
+func (cp *connPackage) releaseVaultSecret(secret *metav1.PartialObjectMetadata) error {
+	if lease := secret.Annotations[annotationVaultLease]; lease != "" {
+		token, err := cp.vaultLogin(secret.Annotations[annotationVaultServiceAccount], secret.Annotations[annotationVaultRole])
+		if err != nil {
+			return err
+		}
+		if err := cp.r.Vault.revoke(cp.ctx, token, lease); err != nil {
+			return err
+		}
+	}
+	return client.IgnoreNotFound(cp.client.Delete(cp.ctx, secret))
+}
+
+// revokeVaultSecrets releases the secrets of the jobs which are no longer pending or running
+func (cp *connPackage) revokeVaultSecrets() {
+	if cp.r.Vault == nil {
+		return
+	}
+	secrets, err := cp.issuedVaultSecrets()
+	if err != nil {
+		log.Log.Info("Unable to list the Vault secrets", "workflow", cp.mj.Name, "error", err.Error())
+		cp.reconcileError(err)
+		return
+	}
+	for i := range secrets {
+		secret := &secrets[i]
+		if cp.vaultSecretInUse(secret) {
+			continue
+		}
+		if err := cp.releaseVaultSecret(secret); err != nil {
+			cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, ReasonVaultSecretFailed, "Lease of the secret %s not revoked: %s", secret.Name, err.Error())
+			cp.reconcileError(err)
+			continue
+		}
+		log.Log.V(1).Info("Vault secret released", "workflow", cp.mj.Name, "secret", secret.Name)
+	}
+}
+
+// vaultSecretInUse tells if the job of the secret may still run with it
+func (cp *connPackage) vaultSecretInUse(secret *metav1.PartialObjectMetadata) bool {
+	for _, group := range cp.mj.Spec.Groups {
+		if group.Name != secret.Labels[labelGroupName] {
+			continue
+		}
+		for _, job := range group.Jobs {
+			if job.Name == secret.Labels[labelJobID] {
+				return job.Status == ExecutionStatusPending || job.Status == ExecutionStatusRunning
+			}
+		}
+	}
+	return false
+}
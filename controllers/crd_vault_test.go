@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestVaultSecrets(t *testing.T) {
+	revoked := []string{}
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/kubernetes/login":
+			var login map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&login)
+			if login["role"] != "etl" || login["jwt"] != "token-of-loader" {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"auth":{"client_token":"s.etl"}}`))
+		case "/v1/database/creds/etl":
+			if r.Header.Get("X-Vault-Token") != "s.etl" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			_, _ = w.Write([]byte(`{"lease_id":"database/creds/etl/abc","data":{"username":"v-etl","password":"p4ss","ttl":3600}}`))
+		case "/v1/sys/leases/revoke/database/creds/etl/abc":
+			revoked = append(revoked, r.Method+" "+r.Header.Get("X-Vault-Token"))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer vault.Close()
+
+	clientset := kubefake.NewSimpleClientset()
+	clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		create := action.(k8stesting.CreateAction)
+		if create.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		request := create.GetObject().(*authenticationv1.TokenRequest)
+		request.Status.Token = "token-of-" + action.(k8stesting.CreateActionImpl).Name
+		return true, request, nil
+	})
+
+	job := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "load", Image: "postgres:16", Status: ExecutionStatusPending,
+		Params: jobsmanagerv1beta1.ManagedJobParameters{ServiceAccount: "loader"}}
+	group := &jobsmanagerv1beta1.ManagedJobGroup{Name: "db", Status: ExecutionStatusPending, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{job},
+		Params: jobsmanagerv1beta1.ManagedJobParameters{SecretsFrom: &jobsmanagerv1beta1.ManagedJobSecretsFrom{
+			Vault: &jobsmanagerv1beta1.ManagedJobVaultSecret{Role: "etl", Path: "database/creds/etl"}}}}
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "apps"},
+		Spec:       jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{group}},
+	}
+	c := newTestClientBuilder(mj).Build()
+	cp := newTestConnPackageWithClient(&ManagedJobReconciler{Recorder: record.NewFakeRecorder(10), Clientset: clientset, Vault: &VaultSecrets{Address: vault.URL}}, mj, c)
+
+	cp.runPendingJobs()
+	if job.Status != ExecutionStatusRunning {
+		t.Fatalf("expected the job started with its secret, got %s", job.Status)
+	}
+	jobName := jobNameGenerator("nightly", "db", "load")
+	secret := &corev1.Secret{}
+	if err := c.Get(cp.ctx, client.ObjectKey{Namespace: "apps", Name: jobName + "-vault"}, secret); err != nil {
+		t.Fatal(err)
+	}
+	if string(secret.Data["username"]) != "v-etl" || string(secret.Data["ttl"]) != "3600" || secret.Annotations[annotationVaultLease] != "database/creds/etl/abc" {
+		t.Errorf("unexpected secret %v %v", secret.Data, secret.Annotations)
+	}
+	childJob := &kbatch.Job{}
+	if err := c.Get(cp.ctx, client.ObjectKey{Namespace: "apps", Name: jobName}, childJob); err != nil {
+		t.Fatal(err)
+	}
+	mounts := childJob.Spec.Template.Spec.Containers[0].VolumeMounts
+	if len(mounts) != 1 || mounts[0].Name != vaultVolumeName || mounts[0].MountPath != defaultVaultMountPath {
+		t.Errorf("expected the secret mounted, got %v", mounts)
+	}
+
+	cp.revokeVaultSecrets()
+	if len(revoked) != 0 {
+		t.Fatalf("expected the lease of the running job kept, got %v", revoked)
+	}
+	job.Status = ExecutionStatusSucceeded
+	cp.revokeVaultSecrets()
+	if len(revoked) != 1 || revoked[0] != "PUT s.etl" {
+		t.Errorf("expected the lease revoked once, got %v", revoked)
+	}
+	if err := c.Get(cp.ctx, client.ObjectKey{Namespace: "apps", Name: jobName + "-vault"}, secret); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the secret deleted, got %v", err)
+	}
+
+	// the workflow deleted mid-run revokes the lease before it's gone
+	stored := &jobsmanagerv1beta1.ManagedJob{}
+	if err := c.Get(cp.ctx, cp.req.NamespacedName, stored); err != nil || !controllerutil.ContainsFinalizer(stored, vaultSecretsFinalizer) {
+		t.Fatalf("expected the vault-secrets finalizer, got %v and %v", stored.Finalizers, err)
+	}
+	_ = c.Delete(cp.ctx, &kbatch.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: jobName}})
+	job.Status = ExecutionStatusPending
+	cp.mj.ResourceVersion = stored.ResourceVersion
+	cp.runPendingJobs()
+	if err := c.Delete(cp.ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(cp.ctx, cp.req.NamespacedName, stored); err != nil || stored.DeletionTimestamp.IsZero() {
+		t.Fatalf("expected the workflow kept by the finalizer, got %v", err)
+	}
+	cp.mj = stored
+	if !cp.finalizeVaultSecrets() || len(revoked) != 2 {
+		t.Errorf("expected the lease of the running job revoked, got %v", revoked)
+	}
+	if err := c.Get(cp.ctx, cp.req.NamespacedName, stored); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the workflow deleted once the lease is revoked, got %v", err)
+	}
+}
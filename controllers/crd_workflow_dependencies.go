@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+/*
+Workflow dependencies - the run of the workflow with spec.dependsOn starts once all the referenced
+workflows succeeded, in any namespace. The dependent workflows are found through the index of the
+references and reconciled whenever the referenced workflow changes, so nothing is polled. The run which
+has already started is not held again when the referenced workflow is restarted.
+*/
+
+const (
+	GroupReasonWorkflowDependency = "WorkflowDependency"
+	dependsOnIndexKey             = ".spec.dependsOn"
+)
+
+// workflowDependencyKey returns the namespace/name of the referenced workflow
+func workflowDependencyKey(mj *jobsmanagerv1beta1.ManagedJob, dependency jobsmanagerv1beta1.ManagedJobWorkflowDependency) types.NamespacedName {
+	if dependency.Namespace == "" {
+		return types.NamespacedName{Namespace: mj.Namespace, Name: dependency.Name}
+	}
+	return types.NamespacedName{Namespace: dependency.Namespace, Name: dependency.Name}
+}
+
+// dependsOnIndexer indexes workflows by the workflows they depend on
+func dependsOnIndexer(obj client.Object) []string {
+	mj := obj.(*jobsmanagerv1beta1.ManagedJob)
+	keys := []string{}
+	for _, dependency := range mj.Spec.DependsOn {
+		keys = append(keys, workflowDependencyKey(mj, dependency).String())
+	}
+	return keys
+}
+
+// workflowsDependingOn maps the changed workflow to the workflows depending on it
+func (r *ManagedJobReconciler) workflowsDependingOn(ctx context.Context, obj client.Object) []reconcile.Request {
+	var workflows jobsmanagerv1beta1.ManagedJobList
+	key := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+	if err := r.List(ctx, &workflows, client.MatchingFields{dependsOnIndexKey: key.String()}); err != nil {
+		log.Log.Info("Unable to list workflows depending on the workflow", "workflow", key.String(), "error", err.Error())
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, workflow := range workflows.Items {
+		dependent := types.NamespacedName{Namespace: workflow.Namespace, Name: workflow.Name}
+		// the referenced workflow is not part of the fingerprint of the dependent one
+		r.syncFingerprints.forget(dependent)
+		requests = append(requests, reconcile.Request{NamespacedName: dependent})
+	}
+	return requests
+}
+
+// unmetWorkflowDependencies describes the referenced workflows which have not succeeded yet
+func (cp *connPackage) unmetWorkflowDependencies() ([]string, error) {
+	unmet := []string{}
+	for _, dependency := range cp.mj.Spec.DependsOn {
+		key := workflowDependencyKey(cp.mj, dependency)
+		var workflow jobsmanagerv1beta1.ManagedJob
+		err := cp.client.Get(cp.ctx, key, &workflow)
+		switch {
+		case apierrors.IsNotFound(err):
+			unmet = append(unmet, fmt.Sprintf("%s (not found)", key))
+		case err != nil:
+			return nil, err
+		case workflow.Status.Phase != ExecutionStatusSucceeded:
+			status := workflow.Status.Phase
+			if status == "" {
+				status = ExecutionStatusPending
+			}
+			unmet = append(unmet, fmt.Sprintf("%s (%s)", key, status))
+		}
+	}
+	return unmet, nil
+}
+
+// waitsForWorkflows holds the run which has not started until the referenced workflows succeed
+func (cp *connPackage) waitsForWorkflows() bool {
+	if len(cp.mj.Spec.DependsOn) == 0 || workflowStarted(&cp.mj.Spec) {
+		cp.releaseGroups(GroupReasonWorkflowDependency)
+		return false
+	}
+	unmet, err := cp.unmetWorkflowDependencies()
+	if err != nil {
+		log.Log.Info("Unable to check the workflow dependencies", "workflow", cp.mj.Name, "error", err.Error())
+		cp.reconcileError(err)
+		return true
+	}
+	if len(unmet) == 0 {
+		if cp.releaseGroups(GroupReasonWorkflowDependency) {
+			cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, GroupReasonWorkflowDependency, "Workflows in spec.dependsOn succeeded, starting the run")
+		}
+		return false
+	}
+	if cp.holdGroups(GroupReasonWorkflowDependency, "waiting for the workflows "+strings.Join(unmet, ", ")) {
+		cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeNormal, GroupReasonWorkflowDependency, "Run waits for the workflows %s", strings.Join(unmet, ", "))
+	}
+	return true
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestWorkflowDependencies(t *testing.T) {
+	ingest := &jobsmanagerv1beta1.ManagedJob{ObjectMeta: metav1.ObjectMeta{Name: "ingest", Namespace: "data"}, Status: jobsmanagerv1beta1.ManagedJobStatus{Phase: ExecutionStatusRunning}}
+	job := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "publish", Type: JobTypeManual, Status: ExecutionStatusPending}
+	group := &jobsmanagerv1beta1.ManagedJobGroup{Name: "reports", Status: ExecutionStatusPending, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{job}}
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "reports", Namespace: "apps"},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{
+			DependsOn: []jobsmanagerv1beta1.ManagedJobWorkflowDependency{{Name: "ingest", Namespace: "data"}},
+			Groups:    []*jobsmanagerv1beta1.ManagedJobGroup{group},
+		},
+	}
+	c := newTestClientBuilder(ingest, mj.DeepCopy()).WithIndex(&jobsmanagerv1beta1.ManagedJob{}, dependsOnIndexKey, dependsOnIndexer).Build()
+	r := &ManagedJobReconciler{Client: c, Recorder: record.NewFakeRecorder(10)}
+	cp := newTestConnPackageWithClient(r, mj, c)
+
+	requests := r.workflowsDependingOn(cp.ctx, ingest)
+	if len(requests) != 1 || requests[0].Namespace != "apps" || requests[0].Name != "reports" {
+		t.Fatalf("expected the dependent workflow reconciled on the change, got %v", requests)
+	}
+
+	cp.runPendingJobs()
+	if job.Status != ExecutionStatusPending || group.Reason != GroupReasonWorkflowDependency {
+		t.Fatalf("expected the run held, got %s with the reason %s", job.Status, group.Reason)
+	}
+	if lines := explainJob(mj, group, job); lines[len(lines)-1] != "  - data/ingest" {
+		t.Errorf("unexpected explanation %v", lines)
+	}
+
+	ingest.Status.Phase = ExecutionStatusSucceeded
+	if err := c.Update(cp.ctx, ingest); err != nil {
+		t.Fatal(err)
+	}
+	cp.runPendingJobs()
+	if job.Status != ExecutionStatusRunning || group.Reason != "" {
+		t.Errorf("expected the run started once ingest succeeded, got %s with the reason %s", job.Status, group.Reason)
+	}
+}
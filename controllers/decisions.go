@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+/*
+Decision log - every start, skip, abort and retry of a job is logged at V(1) together with the evaluated
+dependencies, so it's possible to tell why a job did or did not start. With DecisionEvents the decisions
+are recorded as the events of the workflow as well, apart from the skips repeated on every reconcile.
+*/
+
+const (
+	DecisionStart = "start"
+	DecisionSkip  = "skip"
+	DecisionAbort = "abort"
+	DecisionRetry = "retry"
+)
+
+// decision is a single scheduling decision about the job, Dependencies are the ones evaluated for it
+type decision struct {
+	Action       string
+	Group        string
+	Job          string
+	Reason       string
+	Dependencies []*jobsmanagerv1beta1.ManagedJobDependencies
+}
+
+// decisionRecorder receives the decisions of the scheduling passes, nil drops them
+type decisionRecorder func(decision)
+
+func (record decisionRecorder) record(d decision) {
+	if record != nil {
+		record(d)
+	}
+}
+
+// dependencyStatuses formats the dependencies as name=status pairs
+func dependencyStatuses(dependencies []*jobsmanagerv1beta1.ManagedJobDependencies) string {
+	statuses := []string{}
+	for _, dependency := range dependencies {
+		statuses = append(statuses, dependency.Name+"="+dependency.Status)
+	}
+	return strings.Join(statuses, ", ")
+}
+
+func (cp *connPackage) recordDecision(d decision) {
+	log.Log.V(1).Info("Scheduling decision", "workflow", cp.mj.Name, "namespace", cp.mj.Namespace,
+		"action", d.Action, "group", d.Group, "job", d.Job, "reason", d.Reason, "dependencies", dependencyStatuses(d.Dependencies))
+	if !cp.r.DecisionEvents || d.Action == DecisionSkip {
+		return
+	}
+	message := "Decided to " + d.Action + " job " + d.Job + " of group " + d.Group + ": " + d.Reason
+	if len(d.Dependencies) > 0 {
+		message += " (dependencies: " + dependencyStatuses(d.Dependencies) + ")"
+	}
+	cp.r.Recorder.Event(cp.mj, corev1.EventTypeNormal, "Decision", message)
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestSchedulingDecisions(t *testing.T) {
+	dependency := func(name, status string) []*jobsmanagerv1beta1.ManagedJobDependencies {
+		return []*jobsmanagerv1beta1.ManagedJobDependencies{{Name: name, Status: status}}
+	}
+	spec := &jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+		{Name: "a", Status: ExecutionStatusRunning, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+			{Name: "first", Status: ExecutionStatusFailed},
+			{Name: "second", Status: ExecutionStatusPending, Dependencies: dependency("wf-a-first", ExecutionStatusPending)},
+			{Name: "third", Status: ExecutionStatusPending},
+		}},
+		{Name: "b", Status: ExecutionStatusPending, Reason: GroupReasonAwaitingApproval, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+			{Name: "first", Status: ExecutionStatusPending},
+		}},
+	}}
+	decisions := []decision{}
+	decide := func(d decision) {
+		d.Dependencies = nil
+		decisions = append(decisions, d)
+	}
+
+	propagateStatuses("wf", spec, decide)
+	scheduleRunnableJobs("wf", spec, func(*jobsmanagerv1beta1.ManagedJobGroup) bool { return false },
+		func(*jobsmanagerv1beta1.ManagedJobGroup, *jobsmanagerv1beta1.ManagedJobDefinition) error { return nil }, decide)
+
+	expected := []decision{
+		{Action: DecisionAbort, Group: "a", Job: "second", Reason: "dependency failed"},
+		{Action: DecisionStart, Group: "a", Job: "third", Reason: "dependencies met"},
+		{Action: DecisionSkip, Group: "b", Job: "first", Reason: "group " + GroupReasonAwaitingApproval},
+	}
+	if !reflect.DeepEqual(decisions, expected) {
+		t.Errorf("decisions = %+v, expected %+v", decisions, expected)
+	}
+	if formatted := dependencyStatuses(spec.Groups[0].Jobs[1].Dependencies); formatted != "wf-a-first=failed" {
+		t.Errorf("dependencies formatted as %q", formatted)
+	}
+}
@@ -1,5 +1,10 @@
 package controllers
 
+import (
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/pkg/dependencies"
+)
+
 // +kubebuilder:validation:Enum=Allow;Forbid;Replace
 const (
 	ExecutionStatusPending   string = "pending"
@@ -7,9 +12,36 @@ const (
 	ExecutionStatusSucceeded string = "succeeded"
 	ExecutionStatusFailed    string = "failed"
 	ExecutionStatusAborted   string = "aborted"
+	ExecutionStatusSkipped   string = "skipped"
+	ExecutionStatusQueued    string = "queued"
+	// ExecutionStatusSuspended is the status of the workflow which starts no new jobs, see spec.suspend
+	ExecutionStatusSuspended string = "suspended"
 	ExecutionStatusUnknown   string = "unknown"
+	// ExecutionStatusInvalid is the status of the workflow which can not run until it's fixed
+	ExecutionStatusInvalid string = "invalid"
+)
+
+const (
+	JobTypeContainer string = "container"
+	JobTypeWorkflow  string = jobsmanagerv1beta1.JobTypeWorkflow
+	JobTypeScript    string = "script"
+	JobTypeManual    string = jobsmanagerv1beta1.JobTypeManual
+)
+
+const (
+	GroupOrderingSerial       string = dependencies.OrderingSerial
+	GroupOrderingParallel     string = dependencies.OrderingParallel
+	GroupOrderingExplicitOnly string = dependencies.OrderingExplicitOnly
+)
+
+const (
+	annotationResolvedSpecHash = "jobmanager.raczylo.com/resolved-spec-hash"
+	annotationResolvedSpec     = "jobmanager.raczylo.com/resolved-spec"
 )
 
+// defaultWorkflowRetries is the schema default of spec.retries
+const defaultWorkflowRetries int32 = 1
+
 var (
 	jobOwnerKey = ".metadata.controller"
 )
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+/*
+Step executors - every job type is started by its executor, so new step types do not touch the scheduling.
+Executors registered with RegisterExecutor take precedence over the built-in ones.
+Jobs created with ExecutionContext.CreateJob are tracked by the controller, executors managing
+other resources report the job status by implementing JobStatusChecker.
+*/
+
+// JobExecutor starts the jobs of a single type, the job is marked as running once Execute returns without an error
+type JobExecutor interface {
+	Execute(ec *ExecutionContext) error
+}
+
+// JobStatusChecker is implemented by the executors tracking the status of their running jobs on their own
+type JobStatusChecker interface {
+	// CheckStatus returns the current status of the running job, empty status keeps the job running
+	CheckStatus(ec *ExecutionContext) (string, error)
+}
+
+// ExecutionContext describes the job being executed
+type ExecutionContext struct {
+	context.Context
+	Reconciler *ManagedJobReconciler
+	// Client is the API client of the reconcile, the calls made through it are included in the reconcile metrics
+	Client   client.Client
+	Workflow *jobsmanagerv1beta1.ManagedJob
+	Group    *jobsmanagerv1beta1.ManagedJobGroup
+	Job      *jobsmanagerv1beta1.ManagedJobDefinition
+	// JobName is the generated name of the resources created for the job
+	JobName string
+
+	cp *connPackage
+}
+
+// BuildJob returns the Job running the container of the job definition with the compiled parameters
+func (ec *ExecutionContext) BuildJob() *kbatch.Job {
+	return ec.cp.buildJob(ec.Job, ec.Group)
+}
+
+// CreateJob creates the Job owned by the workflow, its status is tracked by the controller
+func (ec *ExecutionContext) CreateJob(job *kbatch.Job) error {
+	return ec.cp.createJob(ec.Job, ec.Group, job)
+}
+
+// OwnerReference returns the reference to the workflow for the resources created by the executor
+func (ec *ExecutionContext) OwnerReference() (metav1.OwnerReference, error) {
+	return ec.cp.getOwnerReference()
+}
+
+// ContainerJobExecutor runs the job image as a Kubernetes Job
+type ContainerJobExecutor struct{}
+
+func (ContainerJobExecutor) Execute(ec *ExecutionContext) error {
+	return ec.CreateJob(ec.BuildJob())
+}
+
+// WorkflowExecutor runs another ManagedJob as a sub-workflow
+type WorkflowExecutor struct{}
+
+func (WorkflowExecutor) Execute(ec *ExecutionContext) error {
+	return ec.cp.executeWorkflow(ec.Job, ec.Group)
+}
+
+// ScriptExecutor runs the inline script mounted from a ConfigMap
+type ScriptExecutor struct{}
+
+func (ScriptExecutor) Execute(ec *ExecutionContext) error {
+	return ec.cp.executeScript(ec.Job, ec.Group)
+}
+
+// ManualExecutor creates nothing, the job runs until someone sets its outcome after doing the step by hand
+type ManualExecutor struct{}
+
+func (ManualExecutor) Execute(ec *ExecutionContext) error {
+	ec.Reconciler.Recorder.Eventf(ec.Workflow, corev1.EventTypeNormal, "AwaitingManualStep", "Job %s from group %s waits for its outcome to be set by hand", ec.Job.Name, ec.Group.Name)
+	return nil
+}
+
+// CheckStatus finishes the job with its outcome once it's set for the current run
+func (ManualExecutor) CheckStatus(ec *ExecutionContext) (string, error) {
+	return jobOutcome(ec.Job), nil
+}
+
+var builtinExecutors = map[string]JobExecutor{
+	JobTypeContainer: ContainerJobExecutor{},
+	JobTypeWorkflow:  WorkflowExecutor{},
+	JobTypeScript:    ScriptExecutor{},
+	JobTypeManual:    ManualExecutor{},
+}
+
+// RegisterExecutor sets the executor of the job type, it has to be called before the manager starts
+func (r *ManagedJobReconciler) RegisterExecutor(jobType string, executor JobExecutor) {
+	if r.Executors == nil {
+		r.Executors = map[string]JobExecutor{}
+	}
+	r.Executors[jobType] = executor
+}
+
+func (r *ManagedJobReconciler) executorFor(jobType string) (JobExecutor, error) {
+	if jobType == "" {
+		jobType = JobTypeContainer
+	}
+	if executor, found := r.Executors[jobType]; found {
+		return executor, nil
+	}
+	if executor, found := builtinExecutors[jobType]; found {
+		return executor, nil
+	}
+	return nil, fmt.Errorf("no executor registered for job type %s", jobType)
+}
+
+func (cp *connPackage) executionContext(j *jobsmanagerv1beta1.ManagedJobDefinition, g *jobsmanagerv1beta1.ManagedJobGroup) *ExecutionContext {
+	return &ExecutionContext{
+		Context:    cp.ctx,
+		Reconciler: cp.r,
+		Client:     cp.client,
+		Workflow:   cp.mj,
+		Group:      g,
+		Job:        j,
+		JobName:    jobNameGenerator(cp.mj.Name, g.Name, j.Name),
+		cp:         cp,
+	}
+}
+
+func (cp *connPackage) executeJob(j *jobsmanagerv1beta1.ManagedJobDefinition, g *jobsmanagerv1beta1.ManagedJobGroup) error {
+	executor, err := cp.r.executorFor(j.Type)
+	if err != nil {
+		return err
+	}
+	return executor.Execute(cp.executionContext(j, g))
+}
+
+// checkExecutorStatuses updates the running jobs which executors track their status on their own
+func (cp *connPackage) checkExecutorStatuses() {
+	for _, group := range cp.mj.Spec.Groups {
+		for _, job := range group.Jobs {
+			if job.Status != ExecutionStatusRunning {
+				continue
+			}
+			executor, err := cp.r.executorFor(job.Type)
+			if err != nil {
+				continue
+			}
+			checker, ok := executor.(JobStatusChecker)
+			if !ok {
+				continue
+			}
+			status, err := checker.CheckStatus(cp.executionContext(job, group))
+			if err != nil {
+				log.Log.Info("Unable to check job status", "job", job.Name, "group", group.Name, "error", err.Error())
+				cp.reconcileError(err)
+				continue
+			}
+			if status != "" {
+				job.Status = status
+			}
+		}
+	}
+}
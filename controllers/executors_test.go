@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+type testExecutor struct{}
+
+func (testExecutor) Execute(ec *ExecutionContext) error { return nil }
+
+func TestExecutorFor(t *testing.T) {
+	r := &ManagedJobReconciler{}
+	r.RegisterExecutor("http", testExecutor{})
+	r.RegisterExecutor(JobTypeScript, testExecutor{})
+
+	tests := []struct {
+		jobType  string
+		expected JobExecutor
+	}{
+		{"", ContainerJobExecutor{}},
+		{JobTypeContainer, ContainerJobExecutor{}},
+		{JobTypeWorkflow, WorkflowExecutor{}},
+		{JobTypeScript, testExecutor{}},
+		{JobTypeManual, ManualExecutor{}},
+		{"http", testExecutor{}},
+	}
+	for _, tt := range tests {
+		executor, err := r.executorFor(tt.jobType)
+		if err != nil {
+			t.Errorf("type %q: unexpected error %v", tt.jobType, err)
+			continue
+		}
+		if executor != tt.expected {
+			t.Errorf("type %q: expected %T, got %T", tt.jobType, tt.expected, executor)
+		}
+	}
+
+	if _, err := r.executorFor("unknown"); err == nil {
+		t.Error("expected an error for the unregistered type")
+	}
+}
+
+func TestManualJob(t *testing.T) {
+	job := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "sign-off", Type: JobTypeManual, Status: ExecutionStatusPending}
+	group := &jobsmanagerv1beta1.ManagedJobGroup{Name: "release", Status: ExecutionStatusPending, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{job}}
+	mj := &jobsmanagerv1beta1.ManagedJob{Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{group}}}
+	cp := &connPackage{r: &ManagedJobReconciler{Recorder: record.NewFakeRecorder(10)}, ctx: context.Background(), mj: mj}
+
+	if err := scheduleRunnableJobs(mj.Name, &mj.Spec, func(*jobsmanagerv1beta1.ManagedJobGroup) bool { return true }, func(group *jobsmanagerv1beta1.ManagedJobGroup, job *jobsmanagerv1beta1.ManagedJobDefinition) error {
+		return cp.executeJob(job, group)
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != ExecutionStatusRunning || cp.tracksChildJob(job) {
+		t.Fatalf("expected the manual job running without a Job, got %s", job.Status)
+	}
+	cp.checkExecutorStatuses()
+	if job.Status != ExecutionStatusRunning {
+		t.Errorf("expected the job running until its outcome is set, got %s", job.Status)
+	}
+	job.Outcome = ExecutionStatusFailed
+	cp.checkExecutorStatuses()
+	if job.Status != ExecutionStatusFailed {
+		t.Errorf("expected the outcome to finish the job, got %s", job.Status)
+	}
+	resetJobState(job)
+	cp.checkExecutorStatuses()
+	if job.Outcome != ExecutionStatusFailed || !job.OutcomeConsumed || job.Status != ExecutionStatusPending {
+		t.Errorf("expected the outcome of the spec kept and consumed by the restart, got %q consumed %t", job.Outcome, job.OutcomeConsumed)
+	}
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newTestScheme returns the scheme of the fake clients, with the built-in and the operator types
+func newTestScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = jobsmanagerv1beta1.AddToScheme(scheme)
+	return scheme
+}
+
+// newTestClientBuilder returns the builder of the fake client serving the objects, the tests add the status
+// subresources and the interceptors they need
+func newTestClientBuilder(objs ...client.Object) *fake.ClientBuilder {
+	return fake.NewClientBuilder().WithScheme(newTestScheme()).WithObjects(objs...)
+}
+
+// newTestConnPackage returns the reconcile of the workflow by the reconciler against the fake client serving
+// the objects. The nil reconciler is the default one, the events of the reconciler without a recorder go
+// to a FakeRecorder.
+func newTestConnPackage(r *ManagedJobReconciler, mj *jobsmanagerv1beta1.ManagedJob, objs ...client.Object) *connPackage {
+	return newTestConnPackageWithClient(r, mj, newTestClientBuilder(objs...).Build())
+}
+
+// newTestConnPackageWithClient is newTestConnPackage for the client built by the test, e.g. with the status
+// subresource
+func newTestConnPackageWithClient(r *ManagedJobReconciler, mj *jobsmanagerv1beta1.ManagedJob, c client.Client) *connPackage {
+	if r == nil {
+		r = &ManagedJobReconciler{}
+	}
+	if r.Recorder == nil {
+		r.Recorder = record.NewFakeRecorder(100)
+	}
+	cp := &connPackage{r: r, client: c, ctx: context.Background(), mj: mj}
+	if mj != nil {
+		cp.req = ctrl.Request{NamespacedName: types.NamespacedName{Namespace: mj.Namespace, Name: mj.Name}}
+	}
+	return cp
+}
@@ -4,11 +4,17 @@ import (
 	"context"
 	"strings"
 	"sync"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/clock"
 	"raczylo.com/jobs-manager-operator/api/v1beta1"
 	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -24,17 +30,47 @@ type jobStatusUpdate struct {
 }
 
 type connPackage struct {
-	r              *ManagedJobReconciler
-	ctx            context.Context
-	req            ctrl.Request
-	mtx            sync.Mutex
-	mj             *jobsmanagerv1beta1.ManagedJob
+	r      *ManagedJobReconciler
+	client client.Client
+	ctx    context.Context
+	req    ctrl.Request
+	mtx    sync.Mutex
+	mj     *jobsmanagerv1beta1.ManagedJob
+	// stored is the workflow as read, before its status was merged into the spec
+	stored         *jobsmanagerv1beta1.ManagedJob
 	dependencyTree Tree
+	requeueAfter   time.Duration
+	// errs failed the reconcile, see trackReconcileErrors
+	errs []error
+	// unauthorized privileged jobs of this pass, see updateUnauthorizedCondition
+	unauthorized []string
+	// groups waiting for the quota in this pass, see updateQuotaWaitCondition
+	quotaWaits []string
+}
+
+// requeueIn schedules the next reconcile, the earliest requested time wins
+func (cp *connPackage) requeueIn(after time.Duration) {
+	if cp.requeueAfter == 0 || after < cp.requeueAfter {
+		cp.requeueAfter = after
+	}
+}
+
+// clock returns the clock of the reconciler, the real one unless it was injected
+func (r *ManagedJobReconciler) clock() clock.Clock {
+	if r == nil || r.Clock == nil {
+		return clock.RealClock{}
+	}
+	return r.Clock
+}
+
+// now is the current time of the reconciler
+func (cp *connPackage) now() time.Time {
+	return cp.r.clock().Now()
 }
 
 func (cp *connPackage) getOwnerReference() (metav1.OwnerReference, error) {
 	mj := &jobsmanagerv1beta1.ManagedJob{}
-	err := cp.r.Client.Get(cp.ctx, cp.req.NamespacedName, mj)
+	err := cp.client.Get(cp.ctx, cp.req.NamespacedName, mj)
 	if err != nil {
 		return metav1.OwnerReference{}, err
 	}
@@ -50,15 +86,92 @@ func (cp *connPackage) getOwnerReference() (metav1.OwnerReference, error) {
 
 func (cp *connPackage) updateCRDStatusDirectly() error {
 	cp.mtx.Lock()
-	err := cp.r.Update(cp.ctx, cp.mj)
+	// large workflows keep the job details in the status pages
+	details, err := cp.pageOutStatuses()
 	if err != nil {
-		// log.Log.Info("Error", err.Error(), "more", "Unable to update ManagedJob status directly")
+		log.Log.Error(err, "Unable to write the status pages")
+		cp.reconcileError(err)
+		cp.mtx.Unlock()
+		return err
+	}
+	err = cp.writeWorkflow()
+	if err != nil && !apierrors.IsConflict(err) && !cp.apiThrottled(err) {
+		// conflicts are resolved by the next reconcile working on the fresh object, throttled updates
+		// by the one after the delay asked for by the API server
+		cp.reconcileError(err)
+		if objectTooLarge(err) {
+			cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, "WorkflowTooLarge", "Workflow can not be saved, it's over the object size limit of the API server - split it into sub-workflows: %s", err.Error())
+		}
 	}
 	// get updated ManagedJob
-	err = cp.r.Client.Get(cp.ctx, cp.req.NamespacedName, cp.mj)
+	err = cp.client.Get(cp.ctx, cp.req.NamespacedName, cp.mj)
 	if err != nil {
 		log.Log.Error(err, "Unable to get updated ManagedJob")
+		cp.reconcileError(err)
+	} else {
+		cp.stored = cp.mj.DeepCopy()
+		loadRuntimeState(cp.mj)
 	}
+	details.restore(cp.mj)
 	cp.mtx.Unlock()
 	return err
 }
+
+// writeWorkflow writes the metadata of the workflow when the operator changed it, and its runtime state into
+// the status subresource
+func (cp *connPackage) writeWorkflow() error {
+	if err := cp.writeMetadata(); err != nil {
+		return err
+	}
+	return cp.writeStatus()
+}
+
+// writeMetadata updates the workflow when its metadata changed, e.g. the action annotation was taken. The
+// spec is written the way it was read, the operator never changes it - its own state is in the status.
+func (cp *connPackage) writeMetadata() error {
+	workflow := cp.mj.DeepCopy()
+	workflow.ClearRuntimeState()
+	if cp.stored != nil {
+		stored := cp.stored.DeepCopy()
+		stored.ClearRuntimeState()
+		if equality.Semantic.DeepEqual(workflow.ObjectMeta, stored.ObjectMeta) {
+			return nil
+		}
+		workflow.Spec = stored.Spec
+	}
+	if err := cp.client.Update(cp.ctx, workflow); err != nil {
+		return err
+	}
+	cp.mj.ResourceVersion = workflow.ResourceVersion
+	if cp.stored != nil {
+		cp.stored = workflow
+	}
+	return nil
+}
+
+// writeStatus writes the runtime state of the workflow into the status subresource when it changed, the job
+// details of the paged workflow are left in its status pages
+func (cp *connPackage) writeStatus() error {
+	workflow := cp.mj.DeepCopy()
+	if workflowPaged(workflow) {
+		takeStatusDetails(workflow, true)
+	}
+	workflow.SplitStatus()
+	if cp.stored != nil && equality.Semantic.DeepEqual(workflow.Status, cp.stored.Status) {
+		return nil
+	}
+	if err := cp.client.Status().Update(cp.ctx, workflow); err != nil {
+		return err
+	}
+	cp.mj.ResourceVersion = workflow.ResourceVersion
+	if cp.stored != nil {
+		cp.stored.ResourceVersion = workflow.ResourceVersion
+		cp.stored.Status = workflow.Status
+	}
+	return nil
+}
+
+// objectTooLarge tells if the write was rejected for the size of the object, by the API server or etcd
+func objectTooLarge(err error) bool {
+	return apierrors.IsRequestEntityTooLargeError(err) || strings.Contains(err.Error(), "request is too large")
+}
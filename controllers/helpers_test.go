@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestUpdateCRDStatusDirectly(t *testing.T) {
+	key := types.NamespacedName{Namespace: "etl", Name: "nightly"}
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{
+			Name: "extract", PauseBefore: true, Approved: true,
+			Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "sync", Image: "busybox"}},
+		}}},
+	}
+	c := newTestClientBuilder(mj).WithStatusSubresource(mj).Build()
+	stored := func() *jobsmanagerv1beta1.ManagedJob {
+		workflow := &jobsmanagerv1beta1.ManagedJob{}
+		if err := c.Get(context.Background(), key, workflow); err != nil {
+			t.Fatal(err)
+		}
+		return workflow
+	}
+	workflow := stored()
+	cp := newTestConnPackageWithClient(nil, workflow, c)
+	cp.stored = workflow.DeepCopy()
+	if err := LoadStatus(cp.ctx, c, cp.mj); err != nil {
+		t.Fatal(err)
+	}
+	generation := stored().Generation
+
+	// the runtime state goes to the status, the spec is left as it was
+	cp.mj.Spec.Groups[0].Jobs[0].Status, cp.mj.Spec.Progress = ExecutionStatusRunning, "0/1"
+	cp.mj.Spec.Groups[0].Jobs[0].Image = "edited-in-memory"
+	if err := cp.updateCRDStatusDirectly(); err != nil {
+		t.Fatal(err)
+	}
+	current := stored()
+	if current.Generation != generation || current.Spec.Groups[0].Jobs[0].Status != "" || current.Spec.Groups[0].Jobs[0].Image != "busybox" {
+		t.Errorf("expected the spec untouched, got %+v", current.Spec.Groups[0].Jobs[0])
+	}
+	if current.Status.Progress != "0/1" || current.Status.Groups[0].Jobs[0].Status != ExecutionStatusRunning {
+		t.Errorf("expected the runtime state in the status, got %+v", current.Status)
+	}
+	if cp.mj.Spec.Groups[0].Jobs[0].Status != ExecutionStatusRunning || cp.mj.Spec.Progress != "0/1" {
+		t.Errorf("expected the status merged into the reloaded workflow, got %+v", cp.mj.Spec.Groups[0].Jobs[0])
+	}
+
+	// the approval used by the restarted run is consumed in the status, the spec keeps it
+	resetGroupState(cp.mj.Spec.Groups[0])
+	if err := cp.updateCRDStatusDirectly(); err != nil {
+		t.Fatal(err)
+	}
+	current = stored()
+	if current.Generation != generation || !current.Spec.Groups[0].Approved || !current.Status.Groups[0].ApprovalConsumed {
+		t.Errorf("expected the approval consumed in the status, got spec %+v status %+v", current.Spec.Groups[0], current.Status.Groups[0])
+	}
+	if !current.ApprovalConsumed("extract") || groupApproved(cp.mj.Spec.Groups[0]) {
+		t.Error("expected the consumed approval not to count")
+	}
+
+	// the metadata changed by the operator is written with the spec it was read with
+	cp.mj.Annotations = map[string]string{"example.com/note": "kept"}
+	if err := cp.updateCRDStatusDirectly(); err != nil {
+		t.Fatal(err)
+	}
+	if current := stored(); current.Annotations["example.com/note"] != "kept" || current.Generation != generation || current.Spec.Groups[0].Jobs[0].Image != "busybox" {
+		t.Errorf("expected the metadata written without the spec, got %+v", current.ObjectMeta)
+	}
+}
@@ -0,0 +1,453 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+/* Archiving logs of the completed jobs to the object storage */
+
+const (
+	logUploadWorkers = 2
+	logUploadQueue   = 100
+	logUploadRetries = 4
+	// logArchiveRequeue checks the uploads which are still in flight or could not be queued
+	logArchiveRequeue = 30 * time.Second
+)
+
+// logUploadRetryDelay is the backoff of the first retry of the failed upload
+var logUploadRetryDelay = 5 * time.Second
+
+// LogArchiver uploads the logs under the given key and returns the URL of the stored object
+type LogArchiver interface {
+	Archive(ctx context.Context, key string, content []byte) (string, error)
+}
+
+// NewLogArchiver creates the archiver from the storage URL, supported schemes:
+// s3://bucket/prefix?region=eu-west-1&endpoint=host, gs://bucket/prefix, azblob://account/container/prefix
+func NewLogArchiver(storageURL string) (LogArchiver, error) {
+	u, err := url.Parse(storageURL)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.Trim(u.Path, "/")
+	httpClient := &http.Client{Timeout: 60 * time.Second}
+
+	switch u.Scheme {
+	case "s3", "gs":
+		archiver := &s3LogArchiver{
+			httpClient: httpClient,
+			bucket:     u.Host,
+			prefix:     prefix,
+			region:     u.Query().Get("region"),
+			endpoint:   u.Query().Get("endpoint"),
+			accessKey:  os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretKey:  os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			// temporary credentials, e.g. of IRSA or an assumed role, come with the session token
+			sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		if u.Scheme == "gs" {
+			// GCS interoperability API accepts SigV4 signed requests made with HMAC keys
+			archiver.accessKey = os.Getenv("GCS_HMAC_ACCESS_KEY")
+			archiver.secretKey = os.Getenv("GCS_HMAC_SECRET")
+			archiver.sessionToken = ""
+			if archiver.endpoint == "" {
+				archiver.endpoint = "storage.googleapis.com"
+			}
+			if archiver.region == "" {
+				archiver.region = "auto"
+			}
+		}
+		if archiver.region == "" {
+			archiver.region = "us-east-1"
+		}
+		if archiver.endpoint == "" {
+			archiver.endpoint = fmt.Sprintf("s3.%s.amazonaws.com", archiver.region)
+		}
+		if archiver.accessKey == "" || archiver.secretKey == "" {
+			return nil, fmt.Errorf("missing credentials for %s log archive", u.Scheme)
+		}
+		return archiver, nil
+	case "azblob":
+		pathParts := strings.SplitN(prefix, "/", 2)
+		if pathParts[0] == "" {
+			return nil, fmt.Errorf("missing container name in %s", storageURL)
+		}
+		archiver := &azureLogArchiver{
+			httpClient: httpClient,
+			baseURL:    fmt.Sprintf("https://%s.blob.core.windows.net/%s", u.Host, pathParts[0]),
+			sasToken:   strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?"),
+		}
+		if len(pathParts) > 1 {
+			archiver.prefix = pathParts[1]
+		}
+		if archiver.sasToken == "" {
+			return nil, fmt.Errorf("missing AZURE_STORAGE_SAS_TOKEN for azblob log archive")
+		}
+		return archiver, nil
+	}
+	return nil, fmt.Errorf("unsupported log archive scheme %q", u.Scheme)
+}
+
+// joinObjectKey joins the parts of the object key with slashes, the empty segments are left out. The
+// archivers escape the key for their URLs.
+func joinObjectKey(parts ...string) string {
+	segments := []string{}
+	for _, part := range parts {
+		for _, segment := range strings.Split(part, "/") {
+			if segment != "" {
+				segments = append(segments, segment)
+			}
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// escapeObjectKey escapes every segment of the object key with escape, keeping the slashes
+func escapeObjectKey(key string, escape func(string) string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3URIEncode encodes the segment of the object key the way SigV4 canonical URIs do, all the bytes but the
+// unreserved characters are percent-encoded, so e.g. "+", "=" and ":" are signed the way S3 reads them
+func s3URIEncode(segment string) string {
+	var encoded strings.Builder
+	for i := 0; i < len(segment); i++ {
+		c := segment[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+			encoded.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&encoded, "%%%02X", c)
+	}
+	return encoded.String()
+}
+
+func putObject(ctx context.Context, httpClient *http.Client, req *http.Request) error {
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("upload to %s failed with status %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
+
+type s3LogArchiver struct {
+	httpClient *http.Client
+	bucket     string
+	prefix     string
+	region     string
+	endpoint   string
+	accessKey  string
+	secretKey  string
+	// sessionToken of the temporary credentials, sent in X-Amz-Security-Token when set
+	sessionToken string
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func (a *s3LogArchiver) Archive(ctx context.Context, key string, content []byte) (string, error) {
+	objectPath := "/" + escapeObjectKey(joinObjectKey(a.bucket, a.prefix, key), s3URIEncode)
+	objectURL := "https://" + a.endpoint + objectPath
+	req, err := http.NewRequest(http.MethodPut, objectURL, bytes.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	shortDate := now.Format("20060102")
+	payloadHash := sha256.Sum256(content)
+	payloadHashHex := hex.EncodeToString(payloadHash[:])
+
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHashHex)
+
+	// AWS Signature Version 4, the canonical headers are sorted by their names
+	canonicalHeaders := []string{
+		"host:" + a.endpoint,
+		"x-amz-content-sha256:" + payloadHashHex,
+		"x-amz-date:" + amzDate,
+	}
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	if a.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", a.sessionToken)
+		canonicalHeaders = append(canonicalHeaders, "x-amz-security-token:"+a.sessionToken)
+		signedHeaders += ";x-amz-security-token"
+	}
+	canonicalRequest := strings.Join([]string{
+		http.MethodPut,
+		objectPath,
+		"",
+		strings.Join(canonicalHeaders, "\n"),
+		"",
+		signedHeaders,
+		payloadHashHex,
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := strings.Join([]string{shortDate, a.region, "s3", "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(canonicalRequestHash[:])}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+a.secretKey), shortDate)
+	signingKey = hmacSHA256(signingKey, a.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", a.accessKey, scope, signedHeaders, signature))
+
+	if err := putObject(ctx, a.httpClient, req); err != nil {
+		return "", err
+	}
+	return objectURL, nil
+}
+
+type azureLogArchiver struct {
+	httpClient *http.Client
+	baseURL    string
+	prefix     string
+	sasToken   string
+}
+
+func (a *azureLogArchiver) Archive(ctx context.Context, key string, content []byte) (string, error) {
+	objectURL := a.baseURL + "/" + escapeObjectKey(joinObjectKey(a.prefix, key), url.PathEscape)
+	req, err := http.NewRequest(http.MethodPut, objectURL+"?"+a.sasToken, bytes.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+
+	if err := putObject(ctx, a.httpClient, req); err != nil {
+		return "", err
+	}
+	return objectURL, nil
+}
+
+// logUpload is the upload of the logs of the job collected by the reconcile
+type logUpload struct {
+	key     string
+	content []byte
+	// workflow is enqueued once the upload is done
+	workflow *jobsmanagerv1beta1.ManagedJob
+}
+
+type logUploadResult struct {
+	url string
+	err error
+}
+
+// logUploads uploads the collected logs in the background with logUploadWorkers workers, retrying the failed
+// uploads with a backoff. The workflow is enqueued once its upload is done and the reconcile records the
+// result, the uploads lost with the stop of the manager are started again by the reconciles of the next leader.
+type logUploads struct {
+	archiver LogArchiver
+	uploads  chan logUpload
+	done     chan event.GenericEvent
+
+	mtx      sync.Mutex
+	inFlight map[string]bool
+	results  map[string]logUploadResult
+}
+
+func newLogUploads(archiver LogArchiver) *logUploads {
+	return &logUploads{
+		archiver: archiver,
+		uploads:  make(chan logUpload, logUploadQueue),
+		done:     make(chan event.GenericEvent),
+		inFlight: map[string]bool{},
+		results:  map[string]logUploadResult{},
+	}
+}
+
+// NeedLeaderElection makes only the leader upload the logs, the reconciles collecting them run on it
+func (u *logUploads) NeedLeaderElection() bool {
+	return true
+}
+
+// Start uploads the queued logs until the context is done
+func (u *logUploads) Start(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for i := 0; i < logUploadWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case upload := <-u.uploads:
+					u.upload(ctx, upload)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func (u *logUploads) upload(ctx context.Context, upload logUpload) {
+	var result logUploadResult
+	delay := logUploadRetryDelay
+	for attempt := 0; attempt < logUploadRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+		result.url, result.err = u.archiver.Archive(ctx, upload.key, upload.content)
+		if result.err == nil || ctx.Err() != nil {
+			break
+		}
+		log.Log.V(1).Info("Unable to upload the logs", "key", upload.key, "attempt", attempt+1, "error", result.err.Error())
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	u.mtx.Lock()
+	delete(u.inFlight, upload.key)
+	u.results[upload.key] = result
+	u.mtx.Unlock()
+	select {
+	case u.done <- event.GenericEvent{Object: upload.workflow}:
+	case <-ctx.Done():
+	}
+}
+
+// queue starts the upload unless the same one is in flight, it reports false when the queue is full
+func (u *logUploads) queue(upload logUpload) bool {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	if u.inFlight[upload.key] {
+		return true
+	}
+	delete(u.results, upload.key)
+	select {
+	case u.uploads <- upload:
+		u.inFlight[upload.key] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// take returns the result of the finished upload, inFlight reports the upload which is not done yet
+func (u *logUploads) take(key string) (result logUploadResult, done bool, inFlight bool) {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	if result, done = u.results[key]; done {
+		delete(u.results, key)
+	}
+	return result, done, u.inFlight[key]
+}
+
+func (cp *connPackage) logArchiveKey(j *jobsmanagerv1beta1.ManagedJobDefinition, g *jobsmanagerv1beta1.ManagedJobGroup) string {
+	return joinObjectKey(cp.mj.Namespace, cp.mj.Name, string(cp.mj.UID), g.Name, j.Name+".log")
+}
+
+// archiveJobLogs collects logs of all the pods of the completed job and queues their upload under the per-run
+// prefix, the job is archivePending until the upload is done
+func (cp *connPackage) archiveJobLogs(j *jobsmanagerv1beta1.ManagedJobDefinition, g *jobsmanagerv1beta1.ManagedJobGroup) {
+	if cp.r.logUploads == nil || cp.r.Clientset == nil || j.ArchivedLogs != "" {
+		return
+	}
+	generatedJobName := jobNameGenerator(cp.mj.Name, g.Name, j.Name)
+	j.ArchivePending = true
+
+	var pods corev1.PodList
+	labelSelector := labels.SelectorFromSet(labels.Set{
+		labelJobName: generatedJobName,
+	})
+	err := cp.client.List(cp.ctx, &pods, &client.ListOptions{LabelSelector: labelSelector, Namespace: cp.mj.Namespace})
+	if err != nil {
+		log.Log.Info("Unable to list job pods", "job", generatedJobName, "error", err.Error())
+		cp.requeueIn(logArchiveRequeue)
+		return
+	}
+
+	var content bytes.Buffer
+	for _, pod := range pods.Items {
+		podLogs, err := cp.r.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{Container: generatedJobName}).DoRaw(cp.ctx)
+		if err != nil {
+			log.Log.Info("Unable to get pod logs", "pod", pod.Name, "error", err.Error())
+			continue
+		}
+		fmt.Fprintf(&content, "==> %s <==\n", pod.Name)
+		content.Write(podLogs)
+		content.WriteString("\n")
+	}
+	if content.Len() == 0 {
+		j.ArchivePending = false
+		return
+	}
+
+	workflow := &jobsmanagerv1beta1.ManagedJob{ObjectMeta: metav1.ObjectMeta{Name: cp.mj.Name, Namespace: cp.mj.Namespace}}
+	if !cp.r.logUploads.queue(logUpload{key: cp.logArchiveKey(j, g), content: content.Bytes(), workflow: workflow}) {
+		// collected again by the next reconcile
+		cp.requeueIn(logArchiveRequeue)
+	}
+}
+
+// recordLogArchives records the finished uploads of the logs of the jobs, the uploads lost with the restart
+// of the operator are started again
+func (cp *connPackage) recordLogArchives() {
+	for _, group := range cp.mj.Spec.Groups {
+		for _, job := range group.Jobs {
+			if !job.ArchivePending {
+				continue
+			}
+			if cp.r.logUploads == nil {
+				// archiving was disabled since
+				job.ArchivePending = false
+				continue
+			}
+			result, done, inFlight := cp.r.logUploads.take(cp.logArchiveKey(job, group))
+			switch {
+			case done && result.err != nil:
+				job.ArchivePending = false
+				cp.r.Recorder.Event(cp.mj, corev1.EventTypeWarning, "LogArchiveFailed", fmt.Sprintf("Unable to archive logs of job %s: %s", jobNameGenerator(cp.mj.Name, group.Name, job.Name), result.err.Error()))
+			case done:
+				job.ArchivePending = false
+				job.ArchivedLogs = result.url
+			case inFlight:
+				// the workflow is enqueued when it's done, the requeue keeps it out of the short path meanwhile
+				cp.requeueIn(logArchiveRequeue)
+			default:
+				cp.archiveJobLogs(job, group)
+			}
+		}
+	}
+}
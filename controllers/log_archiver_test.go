@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+type flakyArchiver struct {
+	failures int32
+	calls    int32
+}
+
+func (a *flakyArchiver) Archive(ctx context.Context, key string, content []byte) (string, error) {
+	if atomic.AddInt32(&a.calls, 1) <= a.failures {
+		return "", errors.New("storage unavailable")
+	}
+	return "s3://logs/" + key, nil
+}
+
+func TestArchiveJobLogsInBackground(t *testing.T) {
+	defer func(delay time.Duration) { logUploadRetryDelay = delay }(logUploadRetryDelay)
+	logUploadRetryDelay = time.Millisecond
+
+	job := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "a", Status: ExecutionStatusSucceeded}
+	group := &jobsmanagerv1beta1.ManagedJobGroup{Name: "load", Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{job}}
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "apps", UID: "nightly-uid"},
+		Spec:       jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{group}},
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "nightly-load-a-x1", Namespace: "apps",
+		Labels: map[string]string{labelJobName: jobNameGenerator("nightly", "load", "a")}}}
+	archiver := &flakyArchiver{failures: 2}
+	recorder := record.NewFakeRecorder(10)
+	r := &ManagedJobReconciler{Recorder: recorder, LogArchiver: archiver, Clientset: kubefake.NewSimpleClientset(pod), logUploads: newLogUploads(archiver)}
+	cp := newTestConnPackage(r, mj, pod)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = r.logUploads.Start(ctx) }()
+
+	// the reconcile only collects the logs, the upload is retried in the background
+	cp.archiveJobLogs(job, group)
+	if !job.ArchivePending || job.ArchivedLogs != "" || cp.requeueAfter != 0 {
+		t.Fatalf("expected the upload pending, got %+v", job)
+	}
+	cp.recordLogArchives()
+	if cp.requeueAfter != logArchiveRequeue {
+		t.Errorf("expected the requeue while the upload is in flight, got %s", cp.requeueAfter)
+	}
+	select {
+	case <-r.logUploads.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the workflow enqueued after the upload")
+	}
+	cp.recordLogArchives()
+	if job.ArchivePending || job.ArchivedLogs != "s3://logs/apps/nightly/nightly-uid/load/a.log" || archiver.calls != 3 {
+		t.Errorf("expected the logs archived on the third attempt, got %+v after %d attempts", job, archiver.calls)
+	}
+
+	// the upload lost with the restart of the operator starts again
+	job.ArchivedLogs, job.ArchivePending = "", true
+	archiver.failures, archiver.calls = 10, 0
+	r.logUploads = newLogUploads(archiver)
+	go func() { _ = r.logUploads.Start(ctx) }()
+	cp.recordLogArchives()
+	select {
+	case <-r.logUploads.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the workflow enqueued after the upload")
+	}
+	cp.recordLogArchives()
+	if job.ArchivePending || job.ArchivedLogs != "" || archiver.calls != logUploadRetries {
+		t.Errorf("expected the upload given up after %d attempts, got %+v after %d", logUploadRetries, job, archiver.calls)
+	}
+	select {
+	case event := <-recorder.Events:
+		if event != "Warning LogArchiveFailed Unable to archive logs of job nightly-load-a: storage unavailable" {
+			t.Errorf("unexpected event %s", event)
+		}
+	default:
+		t.Error("expected the failed upload recorded")
+	}
+}
+
+func TestS3ArchiveSignsKeysAndSessionToken(t *testing.T) {
+	var received *http.Request
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+	}))
+	defer server.Close()
+
+	archiver := &s3LogArchiver{
+		httpClient: server.Client(),
+		bucket:     "logs",
+		prefix:     "runs",
+		region:     "eu-west-1",
+		endpoint:   strings.TrimPrefix(server.URL, "https://"),
+		accessKey:  "AKID", secretKey: "secret", sessionToken: "token",
+	}
+	if _, err := archiver.Archive(context.Background(), "apps/report 2024-01-01T10:00:00+01:00=a~b.log", []byte("logs")); err != nil {
+		t.Fatal(err)
+	}
+	if want := "/logs/runs/apps/report%202024-01-01T10%3A00%3A00%2B01%3A00%3Da~b.log"; received.URL.EscapedPath() != want {
+		t.Errorf("expected the key S3 URI encoded as %s, got %s", want, received.URL.EscapedPath())
+	}
+	if received.Header.Get("X-Amz-Security-Token") != "token" {
+		t.Error("expected the session token sent")
+	}
+	if !strings.Contains(received.Header.Get("Authorization"), "SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,") {
+		t.Errorf("expected the session token signed, got %s", received.Header.Get("Authorization"))
+	}
+}
@@ -18,14 +18,24 @@ package controllers
 
 import (
 	"context"
+	"time"
 
 	"github.com/lukaszraczylo/pandati"
 	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
 )
@@ -35,58 +45,298 @@ type ManagedJobReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
+	// Clientset is used for the API calls not supported by the controller client, like pod logs
+	Clientset   kubernetes.Interface
+	LogArchiver LogArchiver
+	// PushgatewayURL is injected into the jobs of workflows opted in for pushing metrics
+	PushgatewayURL string
+	// CapacityCheck delays groups which requests do not fit into the namespace quota
+	CapacityCheck bool
+	// RecordResolvedSpec stores the full resolved pod spec in the annotation of the created Job
+	RecordResolvedSpec bool
+	// ResyncInterval enqueues all the non-terminal workflows periodically, 0 sweeps only once after the start
+	ResyncInterval time.Duration
+	// ShutdownTimeout bounds how long the in-flight reconciles may run after the shutdown started
+	ShutdownTimeout time.Duration
+	// MaintenanceWindows are the periods during which no new jobs of any workflow are started
+	MaintenanceWindows []jobsmanagerv1beta1.ManagedJobMaintenanceWindow
+	// DecisionEvents records the scheduling decisions as events of the workflow, they are always logged at V(1)
+	DecisionEvents bool
+	// MaxActiveWorkflowsPerNamespace queues the workflows which would start above the cap, 0 is unlimited
+	MaxActiveWorkflowsPerNamespace int
+	// MaxActiveJobsPerNamespace stops starting new jobs while as many jobs of the namespace run, 0 is unlimited
+	MaxActiveJobsPerNamespace int
+	// ImagePullRefresh refreshes the registry credentials when the image pulls are denied, nil disables it
+	ImagePullRefresh *ImagePullRefresh
+	// SlowSchedulingThreshold after which the pods of the job which are not running are reported, 0 disables the reports
+	SlowSchedulingThreshold time.Duration
+	// CostPrices estimate the costs of the finished jobs, nothing is estimated when they are not set
+	CostPrices CostPrices
+	// ReconcileErrorBudget of the consecutive failed reconciles, the workflow is then degraded and reconciled
+	// every DegradedRequeue only, 0 never degrades the workflows
+	ReconcileErrorBudget int
+	DegradedRequeue      time.Duration
+	// FullSyncInterval bounds how long the workflows which last reconcile changed nothing skip the sync
+	// while neither they nor their children change, 0 always syncs them
+	FullSyncInterval time.Duration
+	// Executors start the jobs of custom types and override the built-in ones, see RegisterExecutor
+	Executors map[string]JobExecutor
+	// Clock stamps the runs and drives the delays, timeouts, backoffs and TTLs, the real clock when nil
+	Clock clock.Clock
+	// Authorizer checks the creator of the workflow may run its privileged jobs, nil runs them unchecked
+	Authorizer JobAuthorizer
+	// Vault issues the secrets of the jobs with secretsFrom.vault, such jobs fail when it's nil
+	Vault *VaultSecrets
+	// JobClients create the jobs of the groups with impersonate as the impersonated users, such jobs fail
+	// when it's nil
+	JobClients JobClients
+	// RevisionHistoryLimit of the definitions of the workflow kept for the rollbacks, 0 keeps none
+	RevisionHistoryLimit int
+	// Migrator rewrites the workflows stored by the previous versions, they are not reconciled until it's done
+	Migrator *Migrator
+	// RunIDs generates the IDs of the runs, ULIDs when nil
+	RunIDs RunIDGenerator
+	// Notifier delivers the status notifications, SetupWithManager adds one with the default workers when nil
+	Notifier *Notifier
+	// MetricsCleaner deletes the series pushed by the workflows, SetupWithManager adds one when it's nil and
+	// PushgatewayURL is set
+	MetricsCleaner *MetricsCleaner
+
+	reconcileErrors  reconcileErrors
+	syncFingerprints syncFingerprints
+	workflowLocks    workflowLocks
+	logUploads       *logUploads
 }
 
 //+kubebuilder:rbac:groups=jobsmanager.raczylo.com,resources=managedjobs,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=jobsmanager.raczylo.com,resources=managedjobs/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=jobsmanager.raczylo.com,resources=managedjobs/finalizers,verbs=update
+//+kubebuilder:rbac:groups=jobsmanager.raczylo.com,resources=managedjobmutexes,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;delete
+//+kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get
+//+kubebuilder:rbac:groups="",resources=pods/log,verbs=get
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=resourcequotas,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;update;patch;delete;get;list;watch
 
 func (r *ManagedJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	_ = log.FromContext(ctx)
 
+	// workflows stored by the previous versions may be in a shape the controller does not handle anymore
+	if r.Migrator != nil && !r.Migrator.Done() {
+		return ctrl.Result{RequeueAfter: migrationRequeue}, nil
+	}
+
+	// the finished children lane reconciles the workflows too, see finishedChildren
+	defer r.workflowLocks.lock(req.NamespacedName)()
+
+	// shutdown of the manager must not leave the workflow half-updated
+	ctx, cancel := r.drainContext(ctx)
+	defer cancel()
+
+	// calls are counted per reconcile to spot the regressions
+	apiClient := newCountingClient(r.Client)
+	defer apiClient.observe()
+
 	cp := &connPackage{
 		r:              r,
+		client:         apiClient,
 		ctx:            ctx,
 		req:            req,
 		dependencyTree: nil,
 	}
 
 	var managedJob jobsmanagerv1beta1.ManagedJob
-	if err := r.Get(ctx, req.NamespacedName, &managedJob); err != nil {
+	if err := cp.client.Get(ctx, req.NamespacedName, &managedJob); err != nil {
+		if apierrors.IsNotFound(err) {
+			forgetWorkflowMetrics(req.Namespace, req.Name)
+			r.reconcileErrors.forget(req.NamespacedName)
+			r.syncFingerprints.forget(req.NamespacedName)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// workflows failing over and over are reconciled rarely, unless they were edited
+	if wait := r.reconcileErrors.backoff(req.NamespacedName, managedJob.Generation, r.ReconcileErrorBudget, r.degradedRequeue(), r.clock().Now()); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
 	cp.mj = &managedJob
+	cp.stored = managedJob.DeepCopy()
+
+	// the deleted workflow only releases what would outlive it, its Jobs are removed by their owner
+	if !managedJob.DeletionTimestamp.IsZero() {
+		requeue := time.Duration(0)
+		if !cp.finalizeVaultSecrets() {
+			requeue = vaultReleaseRequeue
+		}
+		cp.trackReconcileErrors()
+		return ctrl.Result{RequeueAfter: requeue}, nil
+	}
+
+	// templates only define the sub-workflows, they never run on their own
+	if managedJob.Spec.Template {
+		return ctrl.Result{}, nil
+	}
+
+	// quiescent workflows are not synced again until they or their children change
+	fingerprint := ""
+	if r.FullSyncInterval > 0 {
+		var err error
+		if fingerprint, err = cp.syncFingerprint(); err != nil {
+			log.Log.Info("Unable to fingerprint the workflow", "workflow", managedJob.Name, "error", err.Error())
+		} else if r.syncFingerprints.unchanged(req.NamespacedName, fingerprint, r.FullSyncInterval, r.clock().Now()) {
+			return ctrl.Result{}, nil
+		}
+	}
+	if err := LoadStatus(ctx, cp.client, cp.mj); err != nil {
+		log.Log.Info("Unable to load the status pages", "workflow", managedJob.Name, "error", err.Error())
+		cp.reconcileError(err)
+	}
 
 	originalMainJobDefinition := cp.mj.DeepCopy()
 	cp.generateDependencyTree()
 	_, theSame, _ := pandati.CompareStructsReplaced(originalMainJobDefinition, cp.mj)
 	if !theSame {
 		cp.updateCRDStatusDirectly()
-		return ctrl.Result{}, nil
+		cp.trackReconcileErrors()
+		return ctrl.Result{RequeueAfter: cp.requeueAfter}, nil
 	}
 	originalMainJobDefinition = cp.mj.DeepCopy()
 
+	// the invalid workflow waits for the fix, editing it triggers the next reconcile
+	if !cp.checkValidity() {
+		_, theSame, _ = pandati.CompareStructsReplaced(originalMainJobDefinition, cp.mj)
+		if !theSame {
+			cp.updateCRDStatusDirectly()
+		}
+		cp.trackReconcileErrors()
+		return ctrl.Result{RequeueAfter: cp.requeueAfter}, nil
+	}
+
+	// no new jobs can be created in the namespace being deleted
+	if cp.checkNamespaceTermination() {
+		_, theSame, _ = pandati.CompareStructsReplaced(originalMainJobDefinition, cp.mj)
+		if !theSame {
+			cp.updateCRDStatusDirectly()
+		}
+		cp.checkOverallStatus()
+		cp.trackReconcileErrors()
+		return ctrl.Result{RequeueAfter: cp.requeueAfter}, nil
+	}
+
+	cp.recordRevision()
+	cp.releaseWithdrawnApprovals()
 	// TODO: Re-enable after testing
+	cp.checkRequestedAction()
+	cp.checkRestartTriggers()
+	cp.checkActiveDeadline()
 	cp.checkRunningJobsStatus()
+	cp.recordLogArchives()
+	cp.checkImagePulls()
+	cp.checkPodScheduling()
+	cp.checkJobDrift()
+	cp.checkStrayJobs()
+	cp.checkCompileWarnings()
+	cp.checkRunningWorkflowsStatus()
+	cp.checkExecutorStatuses()
+	cp.propagateStatuses()
 	cp.runPendingJobs()
+	cp.releaseMutexes()
+	cp.revokeVaultSecrets()
+	cp.classifyPending()
+	cp.aggregateResources()
+	cp.aggregateCosts()
+	cp.trackRuns()
 
 	_, theSame, _ = pandati.CompareStructsReplaced(originalMainJobDefinition, cp.mj)
 	if !theSame {
 		cp.updateCRDStatusDirectly()
 	}
 
-	cp.checkOverallStatus()
+	status := cp.mj.Status.Phase
+	// the change is sent once it's saved, the failed save is notified by the next reconcile
+	if err := cp.checkOverallStatus(); err == nil {
+		cp.notifyStatusChange(status)
+	}
+	cp.cleanupPushedMetrics()
+	cp.trackReconcileErrors()
+	cp.recordQuiescence(fingerprint, !theSame || cp.mj.Status.Phase != status)
 	// fmt.Printf("Reconcile: %# v", pretty.Formatter(r.Updater))
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: cp.requeueAfter}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ManagedJobReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &jobsmanagerv1beta1.ManagedJob{}, restartOnIndexKey, restartOnIndexer)
+	if err != nil {
+		return err
+	}
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &jobsmanagerv1beta1.ManagedJob{}, dependsOnIndexKey, dependsOnIndexer)
+	if err != nil {
+		return err
+	}
+	if err := mgr.Add(objectMetricsRunnable(mgr.GetClient())); err != nil {
+		return err
+	}
+	if r.Notifier == nil {
+		r.Notifier = &Notifier{Client: mgr.GetClient(), Reader: mgr.GetAPIReader(), Recorder: r.Recorder, Clock: r.Clock}
+	}
+	if err := mgr.Add(r.Notifier); err != nil {
+		return err
+	}
+	if r.MetricsCleaner == nil && r.PushgatewayURL != "" {
+		r.MetricsCleaner = &MetricsCleaner{Client: mgr.GetClient(), PushgatewayURL: r.PushgatewayURL, Clock: r.Clock}
+	}
+	if r.MetricsCleaner != nil {
+		if err := mgr.Add(r.MetricsCleaner); err != nil {
+			return err
+		}
+	}
+	// uploads of the logs enqueue their workflows once they are done
+	uploaded := make(chan event.GenericEvent)
+	if r.LogArchiver != nil {
+		r.logUploads = newLogUploads(r.LogArchiver)
+		uploaded = r.logUploads.done
+		if err := mgr.Add(r.logUploads); err != nil {
+			return err
+		}
+	}
+	sweep := make(chan event.GenericEvent)
+	if err := mgr.Add(sweepRunnable(mgr.GetClient(), r.ResyncInterval, sweep)); err != nil {
+		return err
+	}
+
+	handover := newRequeueHandover()
+	if err := mgr.Add(handover); err != nil {
+		return err
+	}
+
+	ownerHandler := handler.EnqueueRequestForOwner(mgr.GetScheme(), mgr.GetRESTMapper(), &jobsmanagerv1beta1.ManagedJob{}, handler.OnlyControllerOwner())
+	err = ctrl.NewControllerManagedBy(mgr).
 		For(&jobsmanagerv1beta1.ManagedJob{}).
-		Owns(&kbatch.Job{}).
+		Watches(&kbatch.Job{}, ownerHandler, builder.WithPredicates(predicate.Not(finishedChildren))).
+		Watches(&jobsmanagerv1beta1.ManagedJob{}, ownerHandler, builder.WithPredicates(predicate.Not(finishedChildren))).
+		Watches(&jobsmanagerv1beta1.ManagedJob{}, handler.EnqueueRequestsFromMapFunc(r.workflowsDependingOn)).
+		// only the names of the trigger objects are needed, their content is read when the workflow is reconciled
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.workflowsForTriggerObject("ConfigMap")), builder.OnlyMetadata).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.workflowsForTriggerObject("Secret")), builder.OnlyMetadata).
+		Watches(&jobsmanagerv1beta1.ManagedJobMutex{}, handler.EnqueueRequestsFromMapFunc(r.workflowsWaitingForMutex)).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.workflowsInTerminatingNamespace)).
+		WatchesRawSource(&source.Channel{Source: sweep}, &handler.EnqueueRequestForObject{}).
+		WatchesRawSource(&source.Channel{Source: uploaded}, &handler.EnqueueRequestForObject{}).
+		WatchesRawSource(&source.Channel{Source: handover.events}, &handler.EnqueueRequestForObject{}).
 		Complete(r)
+	if err != nil {
+		return err
+	}
+
+	// finished children are reconciled ahead of the routine requeues, by the controller of their own queue
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("managedjob-finished-children").
+		Watches(&kbatch.Job{}, ownerHandler, builder.WithPredicates(finishedChildren)).
+		Watches(&jobsmanagerv1beta1.ManagedJob{}, ownerHandler, builder.WithPredicates(finishedChildren)).
+		Complete(handover.reconciler(r))
 }
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	MetricRequestedResources = "managedjob_requested_resources"
+	MetricEstimatedCost      = "managedjob_estimated_cost"
+)
+
+// MetricLabels are the labels of the operator metrics, the dashboards are generated from them
+var MetricLabels = map[string][]string{
+	MetricRequestedResources:   {"namespace", "workflow", "resource", "scope"},
+	MetricEstimatedCost:        {"namespace", "workflow", "group", "job", "cost_center"},
+	MetricReconcileErrors:      {"namespace", "workflow"},
+	MetricReconcileAPICalls:    {"verb"},
+	MetricAPIThrottled:         {"kind"},
+	MetricWorkflows:            {"namespace", "phase"},
+	MetricChildJobs:            {"namespace"},
+	MetricActiveJobs:           {"namespace"},
+	MetricQueueDepth:           {"namespace"},
+	MetricWorkflowRuns:         {"namespace", "workflow", "status"},
+	MetricWorkflowRunDuration:  {"namespace", "workflow", "status"},
+	MetricPodSchedulingLatency: {"namespace", "workflow"},
+}
+
+var (
+	requestedResourcesGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: MetricRequestedResources,
+		Help: "Resource requests of the workflow jobs, scope is either active (running jobs) or total (all jobs)",
+	}, MetricLabels[MetricRequestedResources])
+	estimatedCostGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: MetricEstimatedCost,
+		Help: "Approximate cost of the last run of the job, from its requests, duration and the configured prices",
+	}, MetricLabels[MetricEstimatedCost])
+)
+
+func init() {
+	metrics.Registry.MustRegister(requestedResourcesGauge, estimatedCostGauge)
+}
+
+// forgetWorkflowMetrics removes the series of the deleted workflow
+func forgetWorkflowMetrics(namespace string, name string) {
+	workflowLabels := prometheus.Labels{"namespace": namespace, "workflow": name}
+	requestedResourcesGauge.DeletePartialMatch(workflowLabels)
+	estimatedCostGauge.DeletePartialMatch(workflowLabels)
+	reconcileErrorsGauge.DeletePartialMatch(workflowLabels)
+	workflowRunsCounter.DeletePartialMatch(workflowLabels)
+	workflowRunDurationHistogram.DeletePartialMatch(workflowLabels)
+	podSchedulingLatencyHistogram.DeletePartialMatch(workflowLabels)
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+/* API calls made by a single reconcile - catches the regressions adding calls to the hot path */
+
+const (
+	MetricReconcileAPICalls = "managedjob_reconcile_api_calls"
+)
+
+var (
+	apiCallVerbs = []string{"get", "list", "create", "update", "patch", "delete"}
+
+	reconcileAPICallsHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    MetricReconcileAPICalls,
+		Help:    "Number of the API calls made by a single reconcile per verb, reads are usually served from the cache",
+		Buckets: []float64{0, 1, 2, 4, 8, 16, 32, 64, 128},
+	}, MetricLabels[MetricReconcileAPICalls])
+)
+
+func init() {
+	metrics.Registry.MustRegister(reconcileAPICallsHistogram)
+}
+
+// countingClient counts the calls made through the client, including the status subresource writes
+type countingClient struct {
+	client.Client
+	mtx    sync.Mutex
+	counts map[string]int
+}
+
+func newCountingClient(c client.Client) *countingClient {
+	return &countingClient{Client: c, counts: map[string]int{}}
+}
+
+func (c *countingClient) count(verb string) {
+	c.mtx.Lock()
+	c.counts[verb]++
+	c.mtx.Unlock()
+}
+
+// observe records the calls of the finished reconcile, verbs which were not called count as zero
+func (c *countingClient) observe() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, verb := range apiCallVerbs {
+		reconcileAPICallsHistogram.WithLabelValues(verb).Observe(float64(c.counts[verb]))
+	}
+}
+
+func (c *countingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	c.count("get")
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func (c *countingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	c.count("list")
+	return c.Client.List(ctx, list, opts...)
+}
+
+func (c *countingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.count("create")
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *countingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.count("update")
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *countingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.count("patch")
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *countingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	c.count("delete")
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *countingClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	c.count("delete")
+	return c.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+func (c *countingClient) Status() client.SubResourceWriter {
+	return &countingStatusWriter{SubResourceWriter: c.Client.Status(), c: c}
+}
+
+type countingStatusWriter struct {
+	client.SubResourceWriter
+	c *countingClient
+}
+
+func (w *countingStatusWriter) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	w.c.count("create")
+	return w.SubResourceWriter.Create(ctx, obj, subResource, opts...)
+}
+
+func (w *countingStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	w.c.count("update")
+	return w.SubResourceWriter.Update(ctx, obj, opts...)
+}
+
+func (w *countingStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	w.c.count("patch")
+	return w.SubResourceWriter.Patch(ctx, obj, patch, opts...)
+}
@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestCountingClient(t *testing.T) {
+	workflow := &jobsmanagerv1beta1.ManagedJob{ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "default"}}
+	c := newCountingClient(newTestClientBuilder(workflow).WithStatusSubresource(workflow).Build())
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := c.Get(ctx, client.ObjectKeyFromObject(workflow), workflow); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.List(ctx, &jobsmanagerv1beta1.ManagedJobList{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Update(ctx, workflow); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Status().Update(ctx, workflow); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]int{"get": 2, "list": 1, "update": 2}
+	for verb, count := range expected {
+		if c.counts[verb] != count {
+			t.Errorf("%s calls = %d, expected %d", verb, c.counts[verb], count)
+		}
+	}
+	c.observe()
+	if series := testutil.CollectAndCount(reconcileAPICallsHistogram); series != len(apiCallVerbs) {
+		t.Errorf("histogram has %d series, expected one per verb", series)
+	}
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	kbatch "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+/* Operator self-metrics - counts of the managed objects, refreshed from the informer cache */
+
+const (
+	MetricWorkflows  = "managedjob_workflows"
+	MetricChildJobs  = "managedjob_child_jobs"
+	MetricActiveJobs = "managedjob_active_jobs"
+	MetricQueueDepth = "managedjob_queue_depth"
+
+	objectMetricsInterval = 30 * time.Second
+)
+
+var (
+	workflowsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: MetricWorkflows,
+		Help: "Number of ManagedJobs per namespace and phase",
+	}, MetricLabels[MetricWorkflows])
+	childJobsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: MetricChildJobs,
+		Help: "Number of Jobs owned by ManagedJobs per namespace",
+	}, MetricLabels[MetricChildJobs])
+	activeJobsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: MetricActiveJobs,
+		Help: "Number of Jobs owned by ManagedJobs with running pods per namespace",
+	}, MetricLabels[MetricActiveJobs])
+	queueDepthGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: MetricQueueDepth,
+		Help: "Number of ManagedJobs queued by the per-namespace workflows cap",
+	}, MetricLabels[MetricQueueDepth])
+)
+
+func init() {
+	metrics.Registry.MustRegister(workflowsGauge, childJobsGauge, activeJobsGauge, queueDepthGauge)
+}
+
+// objectMetricsRunnable refreshes the object count gauges until the manager stops
+func objectMetricsRunnable(c client.Reader) manager.Runnable {
+	return manager.RunnableFunc(func(ctx context.Context) error {
+		ticker := time.NewTicker(objectMetricsInterval)
+		defer ticker.Stop()
+		for {
+			if err := updateObjectMetrics(ctx, c); err != nil {
+				log.Log.Info("Unable to update object metrics", "error", err.Error())
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+func updateObjectMetrics(ctx context.Context, c client.Reader) error {
+	var workflows jobsmanagerv1beta1.ManagedJobList
+	if err := c.List(ctx, &workflows); err != nil {
+		return err
+	}
+	var jobs kbatch.JobList
+	if err := c.List(ctx, &jobs); err != nil {
+		return err
+	}
+
+	workflowCounts := map[[2]string]float64{}
+	queueDepths := map[string]float64{}
+	for _, workflow := range workflows.Items {
+		phase := workflow.Status.Phase
+		if phase == "" {
+			phase = ExecutionStatusPending
+		}
+		workflowCounts[[2]string{workflow.Namespace, phase}]++
+		if workflow.Status.QueuePosition > 0 {
+			queueDepths[workflow.Namespace]++
+		}
+	}
+	childJobCounts := map[string]float64{}
+	activeJobCounts := map[string]float64{}
+	for _, job := range jobs.Items {
+		owner := metav1.GetControllerOf(&job)
+		if owner == nil || owner.Kind != "ManagedJob" || owner.APIVersion != jobsmanagerv1beta1.GroupVersion.String() {
+			continue
+		}
+		childJobCounts[job.Namespace]++
+		if job.Status.Active > 0 {
+			activeJobCounts[job.Namespace]++
+		}
+	}
+
+	// series of the namespaces without objects are dropped
+	workflowsGauge.Reset()
+	for key, count := range workflowCounts {
+		workflowsGauge.WithLabelValues(key[0], key[1]).Set(count)
+	}
+	childJobsGauge.Reset()
+	for namespace, count := range childJobCounts {
+		childJobsGauge.WithLabelValues(namespace).Set(count)
+	}
+	activeJobsGauge.Reset()
+	for namespace, count := range activeJobCounts {
+		activeJobsGauge.WithLabelValues(namespace).Set(count)
+	}
+	queueDepthGauge.Reset()
+	for namespace, depth := range queueDepths {
+		queueDepthGauge.WithLabelValues(namespace).Set(depth)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/clock"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+/*
+Pushgateway contract - workflows annotated with jobsmanager.raczylo.com/push-metrics: "true"
+get PUSHGATEWAY_URL injected into every job, already containing the grouping labels of the job.
+Pushed series are deleted when jobsmanager.raczylo.com/push-metrics-ttl passes after the workflow finished,
+once per run - the deletion is recorded in status.metricsDeletedAt. The reconcile only hands the workflow
+over to the MetricsCleaner runnable, its workers send the deletes and retry the failed ones through their
+queue, so a slow pushgateway never holds up the reconciles.
+*/
+
+const (
+	annotationPushMetrics    = "jobsmanager.raczylo.com/push-metrics"
+	annotationPushMetricsTTL = "jobsmanager.raczylo.com/push-metrics-ttl"
+
+	// DefaultMetricsCleanupWorkers delete the pushed series when the MetricsCleaner has no Workers set
+	DefaultMetricsCleanupWorkers = 2
+
+	pushedMetricsRetryDelay = time.Minute
+	// pushedMetricsMaxRetryDelay caps the backoff of the retries
+	pushedMetricsMaxRetryDelay = 30 * time.Minute
+)
+
+var pushgatewayClient = &http.Client{Timeout: 10 * time.Second}
+
+func (cp *connPackage) pushMetricsEnabled() bool {
+	return pushMetricsEnabled(cp.r.PushgatewayURL, cp.mj)
+}
+
+func pushMetricsEnabled(pushgatewayURL string, mj *jobsmanagerv1beta1.ManagedJob) bool {
+	return pushgatewayURL != "" && mj.Annotations[annotationPushMetrics] == "true"
+}
+
+// pushgatewayGroupingURL returns the URL with grouping labels of the job, as defined by the pushgateway API
+func (cp *connPackage) pushgatewayGroupingURL(groupName string, jobName string) string {
+	return pushgatewayGroupingURL(cp.r.PushgatewayURL, cp.mj, groupName, jobName)
+}
+
+func pushgatewayGroupingURL(pushgatewayURL string, mj *jobsmanagerv1beta1.ManagedJob, groupName string, jobName string) string {
+	groupingLabels := []string{
+		"job", jobNameGenerator(mj.Name, groupName, jobName),
+		"namespace", mj.Namespace,
+		"workflow", mj.Name,
+		"group", groupName,
+	}
+	path := "/metrics"
+	for _, label := range groupingLabels {
+		path += "/" + url.PathEscape(label)
+	}
+	return strings.TrimSuffix(pushgatewayURL, "/") + path
+}
+
+func (cp *connPackage) workflowFinishedAt(childJobs []kbatch.Job) time.Time {
+	finishedAt := time.Time{}
+	for _, childJob := range childJobs {
+		if childJob.Status.CompletionTime != nil && childJob.Status.CompletionTime.Time.After(finishedAt) {
+			finishedAt = childJob.Status.CompletionTime.Time
+		}
+		for _, condition := range childJob.Status.Conditions {
+			if condition.Type == kbatch.JobFailed && condition.Status == corev1.ConditionTrue && condition.LastTransitionTime.Time.After(finishedAt) {
+				finishedAt = condition.LastTransitionTime.Time
+			}
+		}
+	}
+	return finishedAt
+}
+
+// metricsPendingDeletion tells if the series pushed by the finished run of the workflow were not deleted yet
+func metricsPendingDeletion(pushgatewayURL string, mj *jobsmanagerv1beta1.ManagedJob) bool {
+	if !pushMetricsEnabled(pushgatewayURL, mj) || (mj.Status.Phase != ExecutionStatusSucceeded && mj.Status.Phase != ExecutionStatusFailed) {
+		return false
+	}
+	deletedAt := mj.Spec.MetricsDeletedAt
+	return deletedAt == nil || deletedAt.Time.Before(runStartedAt(mj))
+}
+
+// cleanupPushedMetrics hands the workflow over to the MetricsCleaner once the TTL of its pushed series expires
+func (cp *connPackage) cleanupPushedMetrics() {
+	if cp.r.MetricsCleaner == nil || !metricsPendingDeletion(cp.r.PushgatewayURL, cp.mj) {
+		return
+	}
+	ttl, err := time.ParseDuration(cp.mj.Annotations[annotationPushMetricsTTL])
+	if err != nil {
+		return
+	}
+
+	var childJobs kbatch.JobList
+	labelSelector := labels.SelectorFromSet(labels.Set{
+		labelWorkflowName: cp.mj.Name,
+	})
+	err = cp.client.List(cp.ctx, &childJobs, &client.ListOptions{LabelSelector: labelSelector, Namespace: cp.mj.Namespace})
+	if err != nil {
+		log.Log.Info("Unable to list child jobs", "error", err.Error())
+		return
+	}
+	finishedAt := cp.workflowFinishedAt(childJobs.Items)
+	if finishedAt.IsZero() {
+		return
+	}
+	if remaining := finishedAt.Add(ttl).Sub(cp.now()); remaining > 0 {
+		cp.requeueIn(remaining)
+		return
+	}
+	cp.r.MetricsCleaner.queue(client.ObjectKeyFromObject(cp.mj))
+}
+
+// MetricsCleaner deletes the series pushed by the finished runs of the workflows with a fixed number of
+// workers until the manager stops, the failed deletions are retried with an exponential backoff
+type MetricsCleaner struct {
+	Client         client.Client
+	PushgatewayURL string
+	Clock          clock.Clock
+	// Workers deleting the series of the workflows at the same time, DefaultMetricsCleanupWorkers when 0
+	Workers int
+
+	once      sync.Once
+	workflows workqueue.RateLimitingInterface
+}
+
+// NeedLeaderElection makes only the leader delete the series
+func (m *MetricsCleaner) NeedLeaderElection() bool {
+	return true
+}
+
+func (m *MetricsCleaner) pending() workqueue.RateLimitingInterface {
+	m.once.Do(func() {
+		m.workflows = workqueue.NewRateLimitingQueueWithConfig(
+			workqueue.NewItemExponentialFailureRateLimiter(pushedMetricsRetryDelay, pushedMetricsMaxRetryDelay),
+			workqueue.RateLimitingQueueConfig{Name: "pushed-metrics"},
+		)
+	})
+	return m.workflows
+}
+
+func (m *MetricsCleaner) clock() clock.Clock {
+	if m.Clock == nil {
+		return clock.RealClock{}
+	}
+	return m.Clock
+}
+
+// queue hands the workflow over to the workers, the one already waiting or retried with the backoff is
+// left as it is
+func (m *MetricsCleaner) queue(key types.NamespacedName) {
+	if m.pending().NumRequeues(key) > 0 {
+		return
+	}
+	m.pending().Add(key)
+}
+
+// Start deletes the series of the queued workflows until the context is done, the workflows left in the
+// queue are handed over again by their next reconcile
+func (m *MetricsCleaner) Start(ctx context.Context) error {
+	workflows := m.pending()
+	go func() {
+		<-ctx.Done()
+		workflows.ShutDown()
+	}()
+	workers := m.Workers
+	if workers <= 0 {
+		workers = DefaultMetricsCleanupWorkers
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m.processNext(ctx) {
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func (m *MetricsCleaner) processNext(ctx context.Context) bool {
+	item, shutdown := m.pending().Get()
+	if shutdown {
+		return false
+	}
+	defer m.pending().Done(item)
+	key := item.(types.NamespacedName)
+	if err := m.cleanup(ctx, key); err != nil {
+		if ctx.Err() != nil {
+			return true
+		}
+		log.Log.Info("Unable to delete the pushed metrics", "workflow", key.String(), "error", err.Error())
+		m.pending().AddRateLimited(key)
+		return true
+	}
+	m.pending().Forget(key)
+	return true
+}
+
+// cleanup deletes the series pushed by the jobs of the finished run of the workflow and records the deletion
+func (m *MetricsCleaner) cleanup(ctx context.Context, key types.NamespacedName) error {
+	mj := &jobsmanagerv1beta1.ManagedJob{}
+	if err := m.Client.Get(ctx, key, mj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	loadRuntimeState(mj)
+	if !metricsPendingDeletion(m.PushgatewayURL, mj) {
+		return nil
+	}
+	failed := 0
+	var lastErr error
+	for _, group := range mj.Spec.Groups {
+		for _, job := range group.Jobs {
+			if err := deletePushedMetrics(ctx, pushgatewayGroupingURL(m.PushgatewayURL, mj, group.Name, job.Name)); err != nil {
+				log.Log.V(1).Info("Unable to delete pushed metrics", "workflow", key.String(), "job", job.Name, "error", err.Error())
+				failed++
+				lastErr = err
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d deletes failed, the last with: %w", failed, lastErr)
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{"metricsDeletedAt": metav1.NewTime(m.clock().Now())},
+	})
+	if err != nil {
+		return err
+	}
+	return client.IgnoreNotFound(m.Client.Status().Patch(ctx, mj, client.RawPatch(types.MergePatchType, patch)))
+}
+
+func deletePushedMetrics(ctx context.Context, groupingURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, groupingURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := pushgatewayClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("pushgateway responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestCleanupPushedMetricsOnce(t *testing.T) {
+	var deletes int32
+	pushgateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			atomic.AddInt32(&deletes, 1)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer pushgateway.Close()
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "apps", CreationTimestamp: metav1.NewTime(now.Add(-3 * time.Hour)),
+			Annotations: map[string]string{annotationPushMetrics: "true", annotationPushMetricsTTL: "1h"}},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{Name: "load", Status: ExecutionStatusSucceeded,
+			Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "a", Status: ExecutionStatusSucceeded}, {Name: "b", Status: ExecutionStatusSucceeded}}}}},
+		Status: jobsmanagerv1beta1.ManagedJobStatus{Phase: ExecutionStatusSucceeded},
+	}
+	finished := metav1.NewTime(now.Add(-2 * time.Hour))
+	job := &kbatch.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: jobNameGenerator("nightly", "load", "a"), Namespace: "apps", Labels: map[string]string{labelWorkflowName: "nightly"}},
+		Status:     kbatch.JobStatus{CompletionTime: &finished},
+	}
+	c := newTestClientBuilder(mj, job).WithStatusSubresource(&jobsmanagerv1beta1.ManagedJob{}).Build()
+	cleaner := &MetricsCleaner{Client: c, PushgatewayURL: pushgateway.URL, Clock: clocktesting.NewFakeClock(now)}
+	cp := newTestConnPackageWithClient(&ManagedJobReconciler{Recorder: record.NewFakeRecorder(10), PushgatewayURL: pushgateway.URL, Clock: clocktesting.NewFakeClock(now), MetricsCleaner: cleaner}, mj, c)
+
+	// the reconcile only hands the workflow over, queued once
+	cp.cleanupPushedMetrics()
+	cp.cleanupPushedMetrics()
+	if deletes != 0 || cleaner.pending().Len() != 1 {
+		t.Fatalf("expected the workflow queued for the cleaner, got %d deletes and %d queued", deletes, cleaner.pending().Len())
+	}
+	cleaner.processNext(cp.ctx)
+	if deletes != 2 {
+		t.Errorf("expected the series of both jobs deleted, got %d deletes", deletes)
+	}
+	stored := &jobsmanagerv1beta1.ManagedJob{}
+	if err := c.Get(cp.ctx, client.ObjectKeyFromObject(mj), stored); err != nil || stored.Status.MetricsDeletedAt == nil {
+		t.Fatalf("expected the deletion recorded in the status, got %+v and %v", stored.Status, err)
+	}
+	// handed over again by the reconcile which has not seen the deletion yet, deleted once per run
+	cp.cleanupPushedMetrics()
+	cleaner.processNext(cp.ctx)
+	if deletes != 2 {
+		t.Errorf("expected the series deleted once, got %d deletes", deletes)
+	}
+	mj.Status = stored.Status
+	mj.Spec.MetricsDeletedAt = stored.Status.MetricsDeletedAt
+
+	// the next run pushes its own series
+	mj.Spec.RunHistory = []jobsmanagerv1beta1.ManagedJobRunRecord{{StartedAt: metav1.NewTime(now.Add(time.Minute))}}
+	cp.r.Clock = clocktesting.NewFakeClock(now.Add(4 * time.Hour))
+	later := metav1.NewTime(now.Add(2 * time.Hour))
+	job.Status.CompletionTime = &later
+	if err := c.Status().Update(cp.ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(cp.ctx, client.ObjectKeyFromObject(mj), stored); err != nil {
+		t.Fatal(err)
+	}
+	stored.Status.RunHistory = mj.Spec.RunHistory
+	if err := c.Status().Update(cp.ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+	cp.cleanupPushedMetrics()
+	cleaner.processNext(cp.ctx)
+	if deletes != 4 {
+		t.Errorf("expected the series of the next run deleted, got %d deletes", deletes)
+	}
+}
+
+func TestMetricsCleanerRetries(t *testing.T) {
+	var deletes int32
+	pushgateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&deletes, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer pushgateway.Close()
+
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "apps", Annotations: map[string]string{annotationPushMetrics: "true", annotationPushMetricsTTL: "1h"}},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{Name: "load",
+			Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "a"}}}}},
+		Status: jobsmanagerv1beta1.ManagedJobStatus{Phase: ExecutionStatusFailed},
+	}
+	c := newTestClientBuilder(mj).WithStatusSubresource(&jobsmanagerv1beta1.ManagedJob{}).Build()
+	cleaner := &MetricsCleaner{Client: c, PushgatewayURL: pushgateway.URL}
+	key := client.ObjectKeyFromObject(mj)
+
+	cleaner.queue(key)
+	cleaner.processNext(context.Background())
+	if deletes != 1 || cleaner.pending().NumRequeues(key) != 1 {
+		t.Fatalf("expected the failed delete retried with the backoff, got %d deletes and %d requeues", deletes, cleaner.pending().NumRequeues(key))
+	}
+	// the reconciles don't skip the backoff
+	cleaner.queue(key)
+	if cleaner.pending().Len() != 0 {
+		t.Errorf("expected the workflow left waiting for its retry, got %d queued", cleaner.pending().Len())
+	}
+	stored := &jobsmanagerv1beta1.ManagedJob{}
+	if err := c.Get(context.Background(), key, stored); err != nil || stored.Status.MetricsDeletedAt != nil {
+		t.Errorf("expected no deletion recorded, got %+v and %v", stored.Status, err)
+	}
+}
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+/*
+Workflow runs - outcomes and durations of the finished runs, the failure rates are derived from them.
+The ID of the run is their exemplar, served in the OpenMetrics format on /openmetrics of the metrics endpoint.
+*/
+
+const (
+	MetricWorkflowRuns        = "managedjob_workflow_runs_total"
+	MetricWorkflowRunDuration = "managedjob_workflow_run_duration_seconds"
+)
+
+var (
+	workflowRunsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: MetricWorkflowRuns,
+		Help: "Number of the finished runs of the workflow per status",
+	}, MetricLabels[MetricWorkflowRuns])
+	workflowRunDurationHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: MetricWorkflowRunDuration,
+		Help: "Duration of the finished runs of the workflow per status",
+		// 30 seconds to about a day
+		Buckets: prometheus.ExponentialBuckets(30, 2, 12),
+	}, MetricLabels[MetricWorkflowRunDuration])
+)
+
+func init() {
+	metrics.Registry.MustRegister(workflowRunsCounter, workflowRunDurationHistogram)
+}
+
+// observeRun records the run which has just finished, its ID is attached as the exemplar
+func observeRun(mj *jobsmanagerv1beta1.ManagedJob, run *jobsmanagerv1beta1.ManagedJobRunRecord) {
+	labels := prometheus.Labels{"namespace": mj.Namespace, "workflow": mj.Name, "status": run.Status}
+	duration := run.CompletedAt.Sub(run.StartedAt.Time).Seconds()
+	if run.ID == "" {
+		workflowRunsCounter.With(labels).Inc()
+		workflowRunDurationHistogram.With(labels).Observe(duration)
+		return
+	}
+	exemplar := prometheus.Labels{"run_id": run.ID}
+	workflowRunsCounter.With(labels).(prometheus.ExemplarAdder).AddWithExemplar(1, exemplar)
+	workflowRunDurationHistogram.With(labels).(prometheus.ExemplarObserver).ObserveWithExemplar(duration, exemplar)
+}
@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+/*
+State migrations - when the meaning or the place of a field changes, the workflows stored by the previous
+versions of the operator are rewritten once by a migration instead of being handled forever by the
+controller. The Migrator runs the migrations which are not recorded in its ConfigMap yet, in their order,
+right after the operator becomes the leader, and records each one once all the workflows were rewritten.
+Workflows are not reconciled until all the migrations are done.
+*/
+
+const (
+	// MigrationsConfigMapSuffix is appended to the leader election ID to name the ConfigMap recording the migrations
+	MigrationsConfigMapSuffix = "-migrations"
+
+	migrationPageSize   = 100
+	migrationRetryDelay = 30 * time.Second
+	migrationRequeue    = 5 * time.Second
+)
+
+// Migration rewrites a stored workflow, it reports if the workflow was changed. Migrations have to be
+// idempotent, the ones interrupted by a restart of the operator run again from the start.
+type Migration struct {
+	// Name records the migration in the ConfigMap, it can't change once released
+	Name string
+	// Description is logged when the migration runs
+	Description string
+	Migrate     func(mj *jobsmanagerv1beta1.ManagedJob) bool
+}
+
+// Migrations of the operator in the order they run, new ones are appended
+var Migrations = []Migration{
+	{
+		Name:        "0001-dependency-conditions",
+		Description: "move the failed and finished statuses set on the dependencies to their condition",
+		Migrate:     migrateDependencyConditions,
+	},
+	{
+		Name:        "0002-status-subresource",
+		Description: "move the runtime state written into the spec by the previous versions to the status",
+		Migrate:     migrateStatusSubresource,
+	},
+	{
+		Name:        "0003-invalid-spec-condition",
+		Description: "rename the Invalid condition to the standard InvalidSpec",
+		Migrate:     migrateInvalidSpecCondition,
+	},
+}
+
+// migrateDependencyConditions moves the statuses of the dependencies set by the clients before the condition
+// was added to the condition, like the webhook does for the new workflows. Only the workflows which have not
+// started are migrated, the statuses of the started ones were written by the operator.
+func migrateDependencyConditions(mj *jobsmanagerv1beta1.ManagedJob) bool {
+	if _, recorded := mj.Annotations[jobsmanagerv1beta1.RecordedStatusesAnnotation]; recorded || (mj.Status.Phase != "" && mj.Status.Phase != ExecutionStatusPending) {
+		return false
+	}
+	original := mj.Spec.DeepCopy()
+	mj.MigrateDependencyConditions()
+	mj.ClearDependencyStatuses()
+	return !equality.Semantic.DeepEqual(original, &mj.Spec)
+}
+
+// migrateStatusSubresource moves the statuses, the job details, the run history and the rest of the runtime
+// state the previous versions wrote into the deprecated fields of the spec to the status subresource, and
+// drops the recorded statuses. The status of the workflow with no runtime state in its spec is left as it is.
+func migrateStatusSubresource(mj *jobsmanagerv1beta1.ManagedJob) bool {
+	_, recorded := mj.Annotations[jobsmanagerv1beta1.RecordedStatusesAnnotation]
+	delete(mj.Annotations, jobsmanagerv1beta1.RecordedStatusesAnnotation)
+	split := mj.DeepCopy()
+	split.MoveDeprecatedState()
+	if equality.Semantic.DeepEqual(split.Spec, mj.Spec) && equality.Semantic.DeepEqual(split.Status, mj.Status) {
+		return recorded
+	}
+	split.SplitStatus()
+	*mj = *split
+	return true
+}
+
+// migrateInvalidSpecCondition renames the Invalid condition set by the previous versions to InvalidSpec
+func migrateInvalidSpecCondition(mj *jobsmanagerv1beta1.ManagedJob) bool {
+	condition := meta.FindStatusCondition(mj.Status.Conditions, "Invalid")
+	if condition == nil {
+		return false
+	}
+	renamed := *condition
+	renamed.Type = ConditionInvalidSpec
+	meta.RemoveStatusCondition(&mj.Status.Conditions, "Invalid")
+	if meta.FindStatusCondition(mj.Status.Conditions, ConditionInvalidSpec) == nil {
+		mj.Status.Conditions = append(mj.Status.Conditions, renamed)
+	}
+	return true
+}
+
+// Migrator runs the pending migrations of the workflows once, see Migrations
+type Migrator struct {
+	Client client.Client
+	// Reader lists the workflows bypassing the cache, all of them are migrated before they are reconciled
+	Reader client.Reader
+	// Namespace and Name of the ConfigMap recording the migrations which are done
+	Namespace string
+	Name      string
+	// Selector of the workflows of the shard, all the workflows when nil
+	Selector   labels.Selector
+	Migrations []Migration
+
+	done atomic.Bool
+}
+
+// Done tells if all the migrations were recorded
+func (m *Migrator) Done() bool {
+	return m.done.Load()
+}
+
+// NeedLeaderElection makes only the leader migrate the workflows
+func (m *Migrator) NeedLeaderElection() bool {
+	return true
+}
+
+// Start runs the pending migrations, retrying the failed ones until they succeed or the manager stops
+func (m *Migrator) Start(ctx context.Context) error {
+	for {
+		err := m.migrate(ctx)
+		if err == nil {
+			m.done.Store(true)
+			return nil
+		}
+		log.Log.Error(err, "Unable to migrate the workflows, retrying", "after", migrationRetryDelay.String())
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(migrationRetryDelay):
+		}
+	}
+}
+
+func (m *Migrator) migrate(ctx context.Context) error {
+	record := &corev1.ConfigMap{}
+	err := m.Reader.Get(ctx, client.ObjectKey{Namespace: m.Namespace, Name: m.Name}, record)
+	if apierrors.IsNotFound(err) {
+		record = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: m.Namespace, Name: m.Name}}
+		err = m.Client.Create(ctx, record)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read the migrations record %s/%s: %w", m.Namespace, m.Name, err)
+	}
+
+	for _, migration := range m.Migrations {
+		if _, done := record.Data[migration.Name]; done {
+			continue
+		}
+		log.Log.Info("Migrating the workflows", "migration", migration.Name, "description", migration.Description)
+		migrated, err := m.migrateWorkflows(ctx, migration)
+		if err != nil {
+			return fmt.Errorf("migration %s failed: %w", migration.Name, err)
+		}
+		if record.Data == nil {
+			record.Data = map[string]string{}
+		}
+		record.Data[migration.Name] = fmt.Sprintf("%s, %d workflows migrated", time.Now().UTC().Format(time.RFC3339), migrated)
+		if err := m.Client.Update(ctx, record); err != nil {
+			return fmt.Errorf("unable to record the migration %s: %w", migration.Name, err)
+		}
+		log.Log.Info("Migration done", "migration", migration.Name, "workflows", migrated)
+	}
+	return nil
+}
+
+// migrateWorkflows rewrites all the workflows changed by the migration, page by page
+func (m *Migrator) migrateWorkflows(ctx context.Context, migration Migration) (int, error) {
+	migrated := 0
+	options := []client.ListOption{client.Limit(migrationPageSize)}
+	if m.Selector != nil && !m.Selector.Empty() {
+		options = append(options, client.MatchingLabelsSelector{Selector: m.Selector})
+	}
+	for page := ""; ; {
+		// the workflows are read as stored, the runtime state the previous versions wrote into the spec is
+		// kept in the deprecated fields of the schema
+		workflows := &unstructured.UnstructuredList{}
+		workflows.SetGroupVersionKind(jobsmanagerv1beta1.GroupVersion.WithKind("ManagedJobList"))
+		if err := m.Reader.List(ctx, workflows, append(options, client.Continue(page))...); err != nil {
+			return migrated, err
+		}
+		for i := range workflows.Items {
+			mj, err := storedWorkflow(&workflows.Items[i])
+			if err == nil {
+				var changed bool
+				if changed, err = m.migrateWorkflow(ctx, migration, mj); changed {
+					migrated++
+				}
+			}
+			if err != nil {
+				return migrated, fmt.Errorf("workflow %s/%s: %w", workflows.Items[i].GetNamespace(), workflows.Items[i].GetName(), err)
+			}
+		}
+		if page = workflows.GetContinue(); page == "" {
+			return migrated, nil
+		}
+	}
+}
+
+// storedWorkflow decodes the stored workflow together with the deprecated fields of its spec
+func storedWorkflow(stored *unstructured.Unstructured) (*jobsmanagerv1beta1.ManagedJob, error) {
+	data, err := stored.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	mj := &jobsmanagerv1beta1.ManagedJob{}
+	return mj, json.Unmarshal(data, mj)
+}
+
+// migrateWorkflow updates the workflow changed by the migration, the conflicts are migrated again on the fresh object
+func (m *Migrator) migrateWorkflow(ctx context.Context, migration Migration, mj *jobsmanagerv1beta1.ManagedJob) (bool, error) {
+	changed := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if changed {
+			stored := &unstructured.Unstructured{}
+			stored.SetGroupVersionKind(jobsmanagerv1beta1.GroupVersion.WithKind("ManagedJob"))
+			if err := m.Reader.Get(ctx, client.ObjectKeyFromObject(mj), stored); err != nil {
+				return err
+			}
+			fresh, err := storedWorkflow(stored)
+			if err != nil {
+				return err
+			}
+			*mj = *fresh
+		}
+		status := mj.Status.DeepCopy()
+		if changed = migration.Migrate(mj); !changed {
+			return nil
+		}
+		// the status is written first, the runtime state is not lost when the spec is cleared
+		if !equality.Semantic.DeepEqual(status, &mj.Status) {
+			workflow := mj.DeepCopy()
+			if err := m.Client.Status().Update(ctx, workflow); err != nil {
+				return err
+			}
+			mj.ResourceVersion = workflow.ResourceVersion
+		}
+		return m.Client.Update(ctx, mj)
+	})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	return changed, err
+}
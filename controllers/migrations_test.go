@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema/pruning"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/yaml"
+)
+
+func TestMigrator(t *testing.T) {
+	// the dependency statuses set by the clients are only found in the stored spec
+	legacySpecs := map[string]string{}
+	workflow := func(name string, status string, shard string) *jobsmanagerv1beta1.ManagedJob {
+		legacySpecs[name] = `{"groups":[{"name":"load","dependencies":[{"name":"extract","status":"failed"}]}]}`
+		return &jobsmanagerv1beta1.ManagedJob{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "apps", Labels: map[string]string{"shard": shard}},
+			Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+				{Name: "load", Dependencies: []*jobsmanagerv1beta1.ManagedJobDependencies{{Name: "extract"}}},
+			}},
+			Status: jobsmanagerv1beta1.ManagedJobStatus{Phase: status},
+		}
+	}
+	objects := []client.Object{workflow("started", ExecutionStatusRunning, "a"), workflow("other-shard", "", "b")}
+	for i := 0; i < migrationPageSize+5; i++ {
+		objects = append(objects, workflow(fmt.Sprintf("pending-%03d", i), ExecutionStatusPending, "a"))
+	}
+	c := legacyClient(t, newTestClientBuilder(objects...).WithStatusSubresource(&jobsmanagerv1beta1.ManagedJob{}), legacySpecs)
+	ctx := context.Background()
+	runs := 0
+	migrations := append([]Migration{}, Migrations...)
+	migrations = append(migrations, Migration{Name: "0002-count", Migrate: func(mj *jobsmanagerv1beta1.ManagedJob) bool {
+		runs++
+		return false
+	}})
+	migrator := &Migrator{Client: c, Reader: c, Namespace: "operator", Name: "b86e0f00.raczylo.com-migrations",
+		Selector: labels.SelectorFromSet(labels.Set{"shard": "a"}), Migrations: migrations}
+
+	r := &ManagedJobReconciler{Client: c, Migrator: migrator}
+	if result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "started", Namespace: "apps"}}); err != nil || result.RequeueAfter != migrationRequeue {
+		t.Errorf("expected the workflows not reconciled before the migrations, got %+v, %v", result, err)
+	}
+
+	if err := migrator.Start(ctx); err != nil || !migrator.Done() {
+		t.Fatalf("migrations not done: %v", err)
+	}
+	migrated := func(name string) bool {
+		mj := &jobsmanagerv1beta1.ManagedJob{}
+		_ = c.Get(ctx, types.NamespacedName{Name: name, Namespace: "apps"}, mj)
+		mj.MergeStatus()
+		dependency := mj.Spec.Groups[0].Dependencies[0]
+		return dependency.Condition == jobsmanagerv1beta1.DependencyConditionFailed && dependency.Status == ExecutionStatusPending
+	}
+	if !migrated("pending-000") || !migrated(fmt.Sprintf("pending-%03d", migrationPageSize+4)) {
+		t.Error("pending workflows not migrated")
+	}
+	if migrated("started") || migrated("other-shard") {
+		t.Error("started workflow or the one of the other shard migrated")
+	}
+	record := &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Name: migrator.Name, Namespace: "operator"}, record); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(record.Data["0001-dependency-conditions"], fmt.Sprintf(", %d workflows migrated", migrationPageSize+5)) || record.Data["0002-count"] == "" {
+		t.Errorf("unexpected record %v", record.Data)
+	}
+
+	// recorded migrations do not run again
+	runs = 0
+	if err := (&Migrator{Client: c, Reader: c, Namespace: "operator", Name: migrator.Name, Migrations: migrations}).Start(ctx); err != nil || runs != 0 {
+		t.Errorf("recorded migration ran %d times again, %v", runs, err)
+	}
+}
+
+func TestMigrateStatusSubresource(t *testing.T) {
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "apps", Annotations: map[string]string{jobsmanagerv1beta1.RecordedStatusesAnnotation: `{"load":"succeeded"}`}},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{
+			Name: "load", Dependencies: []*jobsmanagerv1beta1.ManagedJobDependencies{{Name: "prepare"}},
+			Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "extract", Image: "busybox"}},
+		}}},
+		Status: jobsmanagerv1beta1.ManagedJobStatus{Phase: ExecutionStatusSucceeded},
+	}
+	c := legacyClient(t, newTestClientBuilder(mj).WithStatusSubresource(mj), map[string]string{
+		"nightly": `{"progress":"1/1","groups":[{"name":"load","status":"succeeded","dependencies":[{"name":"prepare","status":"succeeded"}],
+			"jobs":[{"name":"extract","image":"busybox","status":"succeeded","attempt":2}]}]}`,
+	})
+	ctx := context.Background()
+	migrator := &Migrator{Client: c, Reader: c, Namespace: "operator", Name: "b86e0f00.raczylo.com-migrations", Migrations: Migrations}
+	if err := migrator.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := &jobsmanagerv1beta1.ManagedJob{}
+	if err := c.Get(ctx, types.NamespacedName{Name: "nightly", Namespace: "apps"}, stored); err != nil {
+		t.Fatal(err)
+	}
+	if _, recorded := stored.Annotations[jobsmanagerv1beta1.RecordedStatusesAnnotation]; recorded {
+		t.Error("expected the recorded statuses dropped")
+	}
+	if stored.Status.Phase != ExecutionStatusSucceeded || stored.Status.Progress != "1/1" || stored.Status.Groups[0].Status != ExecutionStatusSucceeded || stored.Status.Groups[0].Jobs[0].Attempt != 2 ||
+		stored.Status.Groups[0].Dependencies["prepare"] != ExecutionStatusSucceeded {
+		t.Errorf("expected the runtime state moved to the status, got %+v", stored.Status)
+	}
+	if group := stored.Spec.Groups[0]; group.DeprecatedGroupState.Status != "" || group.Dependencies[0].DeprecatedStatus != "" || group.Jobs[0].DeprecatedJobState.Attempt != 0 {
+		t.Errorf("expected the runtime state cleared from the spec, got %+v", group)
+	}
+	if migrateStatusSubresource(stored) {
+		t.Error("migrated workflow migrated again")
+	}
+}
+
+func TestMigrateInvalidSpecCondition(t *testing.T) {
+	mj := &jobsmanagerv1beta1.ManagedJob{Status: jobsmanagerv1beta1.ManagedJobStatus{Conditions: []metav1.Condition{
+		{Type: ConditionReconcileDegraded, Status: metav1.ConditionFalse, Reason: "ReconcileSucceeded"},
+		{Type: "Invalid", Status: metav1.ConditionTrue, Reason: ReasonRuntimeValidationFailed, Message: "spec.groups[0].jobs[0].image: Required value"},
+	}}}
+	if !migrateInvalidSpecCondition(mj) || migrateInvalidSpecCondition(mj) {
+		t.Fatal("expected the condition renamed once")
+	}
+	if len(mj.Status.Conditions) != 2 || mj.Status.Conditions[1].Type != ConditionInvalidSpec || mj.Status.Conditions[1].Message != "spec.groups[0].jobs[0].image: Required value" {
+		t.Errorf("unexpected conditions %+v", mj.Status.Conditions)
+	}
+}
+
+// legacyClient serves the workflows named in specs with the spec the previous versions stored, runtime state
+// included, until the workflow is updated. The spec is pruned with the schema of the CRD, the way the API
+// server reads it from etcd.
+func legacyClient(t *testing.T, builder *fake.ClientBuilder, specs map[string]string) client.WithWatch {
+	schema := managedJobStructuralSchema(t)
+	legacy := func(stored *unstructured.Unstructured) error {
+		spec, found := specs[stored.GetName()]
+		if !found {
+			return nil
+		}
+		decoded := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(spec), &decoded); err != nil {
+			return err
+		}
+		stored.Object["spec"] = decoded
+		pruning.Prune(stored.Object, schema, true)
+		return nil
+	}
+	return builder.WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if err := c.Get(ctx, key, obj, opts...); err != nil {
+				return err
+			}
+			if stored, isUnstructured := obj.(*unstructured.Unstructured); isUnstructured {
+				return legacy(stored)
+			}
+			return nil
+		},
+		List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			if err := c.List(ctx, list, opts...); err != nil {
+				return err
+			}
+			if stored, isUnstructured := list.(*unstructured.UnstructuredList); isUnstructured {
+				for i := range stored.Items {
+					if err := legacy(&stored.Items[i]); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+		Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			delete(specs, obj.GetName())
+			return c.Update(ctx, obj, opts...)
+		},
+	}).Build()
+}
+
+// managedJobStructuralSchema returns the schema of the ManagedJob CRD the API server prunes the stored objects with
+func managedJobStructuralSchema(t *testing.T) *structuralschema.Structural {
+	data, err := os.ReadFile(filepath.Join("..", "config", "crd", "bases", "jobsmanager.raczylo.com_managedjobs.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := yaml.Unmarshal(data, crd); err != nil {
+		t.Fatal(err)
+	}
+	props := &apiextensions.JSONSchemaProps{}
+	if err := apiextensionsv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(crd.Spec.Versions[0].Schema.OpenAPIV3Schema, props, nil); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := structuralschema.NewStructural(props)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+/*
+Namespace deletion protection - deleting the namespace kills the running jobs of its workflows halfway.
+The validating webhook on the deletion of the namespaces warns about the running workflows, or refuses
+the deletion in the deny mode unless the namespace is annotated with allow-deletion. The workflows of the
+terminating namespace are aborted with the NamespaceTerminating reason, instead of failing on the Jobs
+which can no longer be created there.
+*/
+
+const (
+	NamespaceProtectionOff  = "off"
+	NamespaceProtectionWarn = "warn"
+	NamespaceProtectionDeny = "deny"
+
+	// NamespaceProtectionWebhookPath serves the NamespaceDeletionGuard
+	NamespaceProtectionWebhookPath = "/validate-v1-namespace"
+	// AnnotationAllowDeletion lets the namespace with running workflows be deleted in the deny mode
+	AnnotationAllowDeletion = "jobsmanager.raczylo.com/allow-deletion"
+
+	ReasonNamespaceTerminating = "NamespaceTerminating"
+)
+
+//+kubebuilder:webhook:path=/validate-v1-namespace,mutating=false,failurePolicy=ignore,sideEffects=None,groups="",resources=namespaces,verbs=delete,versions=v1,name=vnamespace.kb.io,admissionReviewVersions=v1
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// NamespaceDeletionGuard warns about or refuses the deletion of the namespaces with running workflows
+type NamespaceDeletionGuard struct {
+	Client client.Reader
+	// Mode is off, warn or deny
+	Mode string
+}
+
+var _ admission.Handler = &NamespaceDeletionGuard{}
+
+func (g *NamespaceDeletionGuard) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if g.Mode == NamespaceProtectionOff || req.Operation != admissionv1.Delete {
+		return admission.Allowed("")
+	}
+	running, err := runningWorkflows(ctx, g.Client, req.Name)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if len(running) == 0 {
+		return admission.Allowed("")
+	}
+	message := fmt.Sprintf("namespace %s has the running workflows %s, their jobs are killed with it", req.Name, strings.Join(running, ", "))
+	if g.Mode != NamespaceProtectionDeny {
+		return admission.Allowed("").WithWarnings(message)
+	}
+	namespace := &corev1.Namespace{}
+	if err := json.Unmarshal(req.OldObject.Raw, namespace); err == nil && namespace.Annotations[AnnotationAllowDeletion] == "true" {
+		return admission.Allowed("").WithWarnings(message)
+	}
+	return admission.Denied(fmt.Sprintf("%s - abort them or annotate the namespace with %s=true", message, AnnotationAllowDeletion))
+}
+
+// runningWorkflows returns the names of the workflows of the namespace with running jobs
+func runningWorkflows(ctx context.Context, c client.Reader, namespace string) ([]string, error) {
+	var workflows jobsmanagerv1beta1.ManagedJobList
+	if err := c.List(ctx, &workflows, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	running := []string{}
+	for _, workflow := range workflows.Items {
+		workflow.MergeStatus()
+		if runningJobs(&workflow.Spec) > 0 {
+			running = append(running, workflow.Name)
+		}
+	}
+	return running, nil
+}
+
+// workflowsInTerminatingNamespace maps the namespace being deleted to its workflows
+func (r *ManagedJobReconciler) workflowsInTerminatingNamespace(ctx context.Context, obj client.Object) []reconcile.Request {
+	namespace, ok := obj.(*corev1.Namespace)
+	if !ok || !namespaceTerminating(namespace) {
+		return nil
+	}
+	var workflows jobsmanagerv1beta1.ManagedJobList
+	if err := r.List(ctx, &workflows, client.InNamespace(namespace.Name)); err != nil {
+		log.Log.Info("Unable to list workflows of the terminating namespace", "namespace", namespace.Name, "error", err.Error())
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, workflow := range workflows.Items {
+		key := client.ObjectKeyFromObject(&workflow)
+		r.syncFingerprints.forget(key)
+		requests = append(requests, reconcile.Request{NamespacedName: key})
+	}
+	return requests
+}
+
+func namespaceTerminating(namespace *corev1.Namespace) bool {
+	return namespace.DeletionTimestamp != nil || namespace.Status.Phase == corev1.NamespaceTerminating
+}
+
+// checkNamespaceTermination aborts the run of the workflow in the namespace being deleted, reporting
+// if the namespace terminates
+func (cp *connPackage) checkNamespaceTermination() bool {
+	namespace := &corev1.Namespace{}
+	if err := cp.client.Get(cp.ctx, client.ObjectKey{Name: cp.mj.Namespace}, namespace); err != nil {
+		// the namespace is checked on the best effort, the run goes on without it
+		return false
+	}
+	if !namespaceTerminating(namespace) {
+		return false
+	}
+	for _, group := range cp.mj.Spec.Groups {
+		if group.Status == ExecutionStatusPending || group.Status == ExecutionStatusRunning || group.Status == "" {
+			cp.abortRun(ReasonNamespaceTerminating, "namespace is being deleted")
+			cp.r.Recorder.Eventf(cp.mj, corev1.EventTypeWarning, ReasonNamespaceTerminating, "Run aborted, namespace %s is being deleted", cp.mj.Namespace)
+			break
+		}
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestNamespaceDeletionGuard(t *testing.T) {
+	running := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "etl"},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{
+			Name: "extract", Status: ExecutionStatusRunning,
+			Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "download", Status: ExecutionStatusRunning}},
+		}}},
+	}
+	finished := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "weekly", Namespace: "etl"},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{
+			Name: "extract", Status: ExecutionStatusSucceeded,
+			Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "download", Status: ExecutionStatusSucceeded}},
+		}}},
+	}
+	// the operator keeps the statuses in the status
+	running.SplitStatus()
+	finished.SplitStatus()
+	c := newTestClientBuilder(running, finished).Build()
+	deletion := func(namespace string, annotations map[string]string) admission.Request {
+		raw, _ := json.Marshal(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace, Annotations: annotations}})
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Delete, Name: namespace, OldObject: runtime.RawExtension{Raw: raw},
+		}}
+	}
+
+	tests := []struct {
+		name     string
+		mode     string
+		request  admission.Request
+		allowed  bool
+		warnings int
+	}{
+		{name: "off", mode: NamespaceProtectionOff, request: deletion("etl", nil), allowed: true},
+		{name: "warn", mode: NamespaceProtectionWarn, request: deletion("etl", nil), allowed: true, warnings: 1},
+		{name: "deny", mode: NamespaceProtectionDeny, request: deletion("etl", nil), allowed: false},
+		{name: "deny with allow-deletion", mode: NamespaceProtectionDeny, request: deletion("etl", map[string]string{AnnotationAllowDeletion: "true"}), allowed: true, warnings: 1},
+		{name: "deny without running workflows", mode: NamespaceProtectionDeny, request: deletion("reports", nil), allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard := &NamespaceDeletionGuard{Client: c, Mode: tt.mode}
+			response := guard.Handle(context.Background(), tt.request)
+			if response.Allowed != tt.allowed || len(response.Warnings) != tt.warnings {
+				t.Errorf("allowed = %t with warnings %v, expected %t with %d", response.Allowed, response.Warnings, tt.allowed, tt.warnings)
+			}
+			if !tt.allowed && !strings.Contains(response.Result.Message, "nightly") {
+				t.Errorf("refusal does not name the running workflow: %q", response.Result.Message)
+			}
+		})
+	}
+}
+
+func TestCheckNamespaceTermination(t *testing.T) {
+	now := metav1.Now()
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "etl", DeletionTimestamp: &now, Finalizers: []string{"kubernetes"}},
+		Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating},
+	}
+	c := newTestClientBuilder(namespace).Build()
+	pending := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "load", Status: ExecutionStatusPending}
+	recorder := record.NewFakeRecorder(10)
+	cp := newTestConnPackageWithClient(&ManagedJobReconciler{Recorder: recorder}, &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "etl"},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+			{Name: "extract", Status: ExecutionStatusSucceeded, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "download", Status: ExecutionStatusSucceeded}}},
+			{Name: "load", Status: ExecutionStatusPending, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{pending}},
+		}},
+	}, c)
+
+	if !cp.checkNamespaceTermination() {
+		t.Fatal("expected the terminating namespace reported")
+	}
+	if pending.Status != ExecutionStatusAborted || pending.Reason != ReasonNamespaceTerminating {
+		t.Errorf("pending job = %s %s, expected aborted with %s", pending.Status, pending.Reason, ReasonNamespaceTerminating)
+	}
+	if cp.mj.Spec.Groups[0].Status != ExecutionStatusSucceeded {
+		t.Errorf("finished group changed to %s", cp.mj.Spec.Groups[0].Status)
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Warning NamespaceTerminating Run aborted") {
+		t.Errorf("unexpected event %q", event)
+	}
+	// nothing left to abort
+	cp.checkNamespaceTermination()
+	if len(recorder.Events) != 0 {
+		t.Errorf("unexpected events %d", len(recorder.Events))
+	}
+}
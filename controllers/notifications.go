@@ -0,0 +1,509 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lukaszraczylo/pandati"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/clock"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+/*
+Notifications - status changes of the workflow are POSTed to its webhooks in the background. The body is
+signed with HMAC-SHA256 using the key from the signing secret and the signature is sent as sha256=<hex> in
+X-Jobsmanager-Signature, so the receivers can verify the request comes from the operator. Failed deliveries
+are retried with an exponential backoff, the last failure is recorded as the NotificationFailed event.
+X-Jobsmanager-Delivery is the same for the repeated notifications of the same status of the run.
+
+The changes are sent once the status is saved. They are queued in the <workflow>-notifications ConfigMap,
+so they survive the restarts of the operator, and delivered by the Notifier runnable. The notifications
+which ran out of retries stay in the ConfigMap as the dead letters, the last notificationDeadLetters of them.
+*/
+
+const (
+	ReasonNotificationFailed = "NotificationFailed"
+
+	headerNotificationSignature = "X-Jobsmanager-Signature"
+	headerNotificationEvent     = "X-Jobsmanager-Event"
+	headerNotificationDelivery  = "X-Jobsmanager-Delivery"
+
+	notificationEventStatus = "workflow.status"
+
+	// DefaultNotificationWorkers deliver the notifications when the Notifier has no Workers set
+	DefaultNotificationWorkers = 4
+
+	notificationsConfigMapSuffix = "-notifications"
+	notificationDeadLetters      = 10
+)
+
+var (
+	notificationTimeout    = 10 * time.Second
+	notificationRetryDelay = 2 * time.Second
+	// notificationMaxRetryDelay caps the backoff of the retries
+	notificationMaxRetryDelay = 5 * time.Minute
+)
+
+type statusNotification struct {
+	Event          string                    `json:"event"`
+	Namespace      string                    `json:"namespace"`
+	Workflow       string                    `json:"workflow"`
+	UID            types.UID                 `json:"uid"`
+	Status         string                    `json:"status"`
+	PreviousStatus string                    `json:"previousStatus,omitempty"`
+	RunID          string                    `json:"runId,omitempty"`
+	RunStartedAt   time.Time                 `json:"runStartedAt"`
+	Time           time.Time                 `json:"time"`
+	FailureDigest  string                    `json:"failureDigest,omitempty"`
+	Groups         []statusNotificationGroup `json:"groups"`
+}
+
+// notificationDelivery of the notification to a webhook, kept in the notifications ConfigMap of the workflow
+// until it's accepted or runs out of retries
+type notificationDelivery struct {
+	URL           string                    `json:"url"`
+	Delivery      string                    `json:"delivery"`
+	Status        string                    `json:"status"`
+	Body          string                    `json:"body"`
+	SigningSecret *corev1.SecretKeySelector `json:"signingSecret,omitempty"`
+	Retries       int32                     `json:"retries"`
+	Attempts      int32                     `json:"attempts"`
+	NextAttempt   time.Time                 `json:"nextAttempt"`
+	LastError     string                    `json:"lastError,omitempty"`
+	FailedAt      *time.Time                `json:"failedAt,omitempty"`
+}
+
+type statusNotificationGroup struct {
+	Name   string                  `json:"name"`
+	Status string                  `json:"status"`
+	Jobs   []statusNotificationJob `json:"jobs"`
+}
+
+type statusNotificationJob struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// signNotification returns the signature of the body sent in X-Jobsmanager-Signature
+func signNotification(key []byte, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyStatusChange queues the changed status of the workflow for the webhooks waiting for it
+func (cp *connPackage) notifyStatusChange(previous string) {
+	if cp.mj.Spec.Notifications == nil || cp.mj.Status.Phase == previous {
+		return
+	}
+	runStarted := runStartedAt(cp.mj)
+	notification := statusNotification{
+		Event:          notificationEventStatus,
+		Namespace:      cp.mj.Namespace,
+		Workflow:       cp.mj.Name,
+		UID:            cp.mj.UID,
+		Status:         cp.mj.Status.Phase,
+		PreviousStatus: previous,
+		RunID:          cp.currentRunID(),
+		RunStartedAt:   runStarted,
+		Time:           cp.now(),
+		FailureDigest:  cp.mj.Spec.FailureDigest,
+		Groups:         []statusNotificationGroup{},
+	}
+	for _, group := range cp.mj.Spec.Groups {
+		notificationGroup := statusNotificationGroup{Name: group.Name, Status: group.Status, Jobs: []statusNotificationJob{}}
+		for _, job := range group.Jobs {
+			notificationGroup.Jobs = append(notificationGroup.Jobs, statusNotificationJob{Name: job.Name, Status: job.Status, Reason: job.Reason})
+		}
+		notification.Groups = append(notification.Groups, notificationGroup)
+	}
+	body, err := json.Marshal(notification)
+	if err != nil {
+		log.Log.Info("Unable to encode the notification", "workflow", cp.mj.Name, "error", err.Error())
+		return
+	}
+	delivery := fmt.Sprintf("%s-%d-%s", cp.mj.UID, runStarted.Unix(), cp.mj.Status.Phase)
+
+	deliveries := map[string]notificationDelivery{}
+	for i, webhook := range cp.mj.Spec.Notifications.Webhooks {
+		if len(webhook.Statuses) > 0 && !pandati.ExistsInSlice(webhook.Statuses, cp.mj.Status.Phase) {
+			continue
+		}
+		deliveries[fmt.Sprintf("%s-%d", delivery, i)] = notificationDelivery{
+			URL:           webhook.URL,
+			Delivery:      delivery,
+			Status:        cp.mj.Status.Phase,
+			Body:          string(body),
+			SigningSecret: webhook.SigningSecret,
+			Retries:       webhook.Retries,
+			NextAttempt:   cp.now(),
+		}
+	}
+	if len(deliveries) == 0 || cp.r.Notifier == nil {
+		return
+	}
+	if err := cp.r.Notifier.queue(cp.ctx, cp.mj, deliveries); err != nil {
+		cp.r.Recorder.Event(cp.mj, corev1.EventTypeWarning, ReasonNotificationFailed, fmt.Sprintf("Notification of the status %s not queued: %s", cp.mj.Status.Phase, err.Error()))
+		cp.reconcileError(err)
+	}
+}
+
+// Notifier delivers the queued notifications of the workflows with a fixed number of workers until the
+// manager stops
+type Notifier struct {
+	Client client.Client
+	// Reader reads the queued notifications and the signing secrets bypassing the cache
+	Reader   client.Reader
+	Recorder record.EventRecorder
+	Clock    clock.Clock
+	// Workers delivering the notifications at the same time, DefaultNotificationWorkers when 0
+	Workers int
+	// Selector of the workflows of the shard which notifications are resumed after the start, all when nil
+	Selector labels.Selector
+	// Targets the notifications are sent to, the hosts local to the cluster are denied unless allowed
+	Targets jobsmanagerv1beta1.NotificationTargets
+
+	once       sync.Once
+	workflow   workqueue.DelayingInterface
+	clientOnce sync.Once
+	client     *http.Client
+}
+
+// NeedLeaderElection makes only the leader deliver the notifications
+func (n *Notifier) NeedLeaderElection() bool {
+	return true
+}
+
+func (n *Notifier) workflows() workqueue.DelayingInterface {
+	n.once.Do(func() {
+		n.workflow = workqueue.NewDelayingQueueWithConfig(workqueue.DelayingQueueConfig{Name: "notifications"})
+	})
+	return n.workflow
+}
+
+// httpClient sends the notifications only to the hosts allowed by the targets. The host of the webhook is
+// checked by its name, then the address it resolves to is checked and dialed, so the name can not resolve
+// to another one in between. The proxy of the environment is dialed when set, it has to be allowed as well
+// when it's local to the cluster.
+func (n *Notifier) httpClient() *http.Client {
+	n.clientOnce.Do(func() {
+		dialer := &net.Dialer{Timeout: notificationTimeout, KeepAlive: 30 * time.Second}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = func(request *http.Request) (*url.URL, error) {
+			if err := n.Targets.CheckHost(request.URL.Hostname()); err != nil {
+				return nil, err
+			}
+			return http.ProxyFromEnvironment(request)
+		}
+		transport.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+			host, port, err := net.SplitHostPort(address)
+			if err != nil {
+				return nil, err
+			}
+			if n.Targets.Allows(host) {
+				return dialer.DialContext(ctx, network, address)
+			}
+			if err := n.Targets.CheckHost(host); err != nil {
+				return nil, err
+			}
+			addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+			if err != nil {
+				return nil, err
+			}
+			for _, resolved := range addresses {
+				if err := n.Targets.CheckAddress(resolved.IP); err != nil {
+					return nil, fmt.Errorf("host %s resolves to the denied address: %w", host, err)
+				}
+			}
+			return dialer.DialContext(ctx, network, net.JoinHostPort(addresses[0].IP.String(), port))
+		}
+		n.client = &http.Client{Timeout: notificationTimeout, Transport: transport}
+	})
+	return n.client
+}
+
+func (n *Notifier) clock() clock.Clock {
+	if n.Clock == nil {
+		return clock.RealClock{}
+	}
+	return n.Clock
+}
+
+// Start resumes the notifications queued before the restart and delivers them until the context is done,
+// the delivery interrupted by the stop is sent again by the next leader
+func (n *Notifier) Start(ctx context.Context) error {
+	workflows := n.workflows()
+	go func() {
+		<-ctx.Done()
+		workflows.ShutDown()
+	}()
+	if err := n.resume(ctx); err != nil {
+		log.Log.Info("Unable to resume the queued notifications", "error", err.Error())
+	}
+	workers := n.Workers
+	if workers <= 0 {
+		workers = DefaultNotificationWorkers
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n.processNext(ctx) {
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// resume queues the workflows with the notifications, the ones without any queued are skipped by deliver
+func (n *Notifier) resume(ctx context.Context) error {
+	options := []client.ListOption{}
+	if n.Selector != nil && !n.Selector.Empty() {
+		options = append(options, client.MatchingLabelsSelector{Selector: n.Selector})
+	}
+	var workflows jobsmanagerv1beta1.ManagedJobList
+	if err := n.Reader.List(ctx, &workflows, options...); err != nil {
+		return err
+	}
+	for _, workflow := range workflows.Items {
+		if workflow.Spec.Notifications != nil {
+			n.workflows().Add(notificationsKey(&workflow))
+		}
+	}
+	return nil
+}
+
+func notificationsKey(mj *jobsmanagerv1beta1.ManagedJob) types.NamespacedName {
+	return types.NamespacedName{Namespace: mj.Namespace, Name: mj.Name + notificationsConfigMapSuffix}
+}
+
+// queue stores the deliveries in the notifications ConfigMap of the workflow and hands it to the workers
+func (n *Notifier) queue(ctx context.Context, mj *jobsmanagerv1beta1.ManagedJob, deliveries map[string]notificationDelivery) error {
+	data := map[string]string{}
+	for name, delivery := range deliveries {
+		encoded, err := json.Marshal(delivery)
+		if err != nil {
+			return err
+		}
+		data[name] = string(encoded)
+	}
+	key := notificationsKey(mj)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		queued := &corev1.ConfigMap{}
+		err := n.Reader.Get(ctx, key, queued)
+		if apierrors.IsNotFound(err) {
+			queued = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            key.Name,
+					Namespace:       key.Namespace,
+					Labels:          map[string]string{labelWorkflowName: mj.Name},
+					OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(mj, jobsmanagerv1beta1.GroupVersion.WithKind("ManagedJob"))},
+				},
+				Data: data,
+			}
+			return n.Client.Create(ctx, queued)
+		}
+		if err != nil {
+			return err
+		}
+		if queued.Data == nil {
+			queued.Data = map[string]string{}
+		}
+		for name, delivery := range data {
+			queued.Data[name] = delivery
+		}
+		return n.Client.Update(ctx, queued)
+	})
+	if err != nil {
+		return err
+	}
+	n.workflows().Add(key)
+	return nil
+}
+
+func (n *Notifier) processNext(ctx context.Context) bool {
+	item, shutdown := n.workflows().Get()
+	if shutdown {
+		return false
+	}
+	defer n.workflows().Done(item)
+	key := item.(types.NamespacedName)
+	next, err := n.deliver(ctx, key)
+	if err != nil {
+		if ctx.Err() != nil {
+			return true
+		}
+		log.Log.Info("Unable to deliver the notifications", "configmap", key.String(), "error", err.Error())
+		next = notificationRetryDelay
+	}
+	if next > 0 {
+		n.workflows().AddAfter(key, next)
+	}
+	return true
+}
+
+// deliver posts the due notifications of the workflow, it returns how long to wait for the next one. The
+// accepted notifications are removed from the ConfigMap, the ones which ran out of retries are kept there
+// as the dead letters.
+func (n *Notifier) deliver(ctx context.Context, key types.NamespacedName) (time.Duration, error) {
+	queued := &corev1.ConfigMap{}
+	if err := n.Reader.Get(ctx, key, queued); err != nil {
+		return 0, client.IgnoreNotFound(err)
+	}
+	now := n.clock().Now()
+	var next time.Duration
+	changed := false
+	deadLetters := []string{}
+	for _, name := range sortedKeys(queued.Data) {
+		var delivery notificationDelivery
+		if err := json.Unmarshal([]byte(queued.Data[name]), &delivery); err != nil {
+			log.Log.Info("Dropping the unreadable notification", "configmap", key.String(), "notification", name, "error", err.Error())
+			delete(queued.Data, name)
+			changed = true
+			continue
+		}
+		if delivery.FailedAt != nil {
+			deadLetters = append(deadLetters, name)
+			continue
+		}
+		if wait := delivery.NextAttempt.Sub(now); wait > 0 {
+			next = earliestWait(next, wait)
+			continue
+		}
+		changed = true
+		err := n.post(ctx, key.Namespace, &delivery)
+		if err == nil {
+			delete(queued.Data, name)
+			continue
+		}
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		delivery.Attempts++
+		delivery.LastError = err.Error()
+		log.Log.V(1).Info("Notification not delivered", "configmap", key.String(), "url", delivery.URL, "attempt", delivery.Attempts, "error", err.Error())
+		if delivery.Attempts > delivery.Retries {
+			failedAt := now
+			delivery.FailedAt = &failedAt
+			deadLetters = append(deadLetters, name)
+			if workflow := notificationsWorkflow(queued); workflow != nil {
+				n.Recorder.Eventf(workflow, corev1.EventTypeWarning, ReasonNotificationFailed, "Notification of the status %s to %s failed after %d attempts: %s", delivery.Status, delivery.URL, delivery.Attempts, err.Error())
+			}
+		} else {
+			wait := notificationBackoff(delivery.Attempts)
+			delivery.NextAttempt = now.Add(wait)
+			next = earliestWait(next, wait)
+		}
+		encoded, err := json.Marshal(delivery)
+		if err != nil {
+			return 0, err
+		}
+		queued.Data[name] = string(encoded)
+	}
+	if !changed {
+		return next, nil
+	}
+	// the oldest dead letters go first, the names of the deliveries start with the start of their run
+	for len(deadLetters) > notificationDeadLetters {
+		delete(queued.Data, deadLetters[0])
+		deadLetters = deadLetters[1:]
+	}
+	return next, n.Client.Update(ctx, queued)
+}
+
+// notificationsWorkflow refers to the workflow owning the notifications ConfigMap, for its events
+func notificationsWorkflow(queued *corev1.ConfigMap) *jobsmanagerv1beta1.ManagedJob {
+	owner := metav1.GetControllerOf(queued)
+	if owner == nil {
+		return nil
+	}
+	return &jobsmanagerv1beta1.ManagedJob{ObjectMeta: metav1.ObjectMeta{Name: owner.Name, Namespace: queued.Namespace, UID: owner.UID}}
+}
+
+func notificationBackoff(attempts int32) time.Duration {
+	wait := notificationRetryDelay
+	for i := int32(1); i < attempts && wait < notificationMaxRetryDelay; i++ {
+		wait *= 2
+	}
+	if wait > notificationMaxRetryDelay {
+		return notificationMaxRetryDelay
+	}
+	return wait
+}
+
+func earliestWait(next time.Duration, wait time.Duration) time.Duration {
+	if next == 0 || wait < next {
+		return wait
+	}
+	return next
+}
+
+func sortedKeys(data map[string]string) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// post signs the notification with the key from the secret in the namespace of the workflow and sends it
+func (n *Notifier) post(ctx context.Context, namespace string, delivery *notificationDelivery) error {
+	var key []byte
+	if selector := delivery.SigningSecret; selector != nil {
+		var secret corev1.Secret
+		if err := n.Reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: selector.Name}, &secret); err != nil {
+			return fmt.Errorf("unable to sign it: %w", err)
+		}
+		if key = secret.Data[selector.Key]; len(key) == 0 {
+			return fmt.Errorf("unable to sign it: key %s not found in the secret %s", selector.Key, selector.Name)
+		}
+	}
+	return postNotification(ctx, n.httpClient(), delivery.URL, delivery.Delivery, []byte(delivery.Body), key)
+}
+
+func postNotification(ctx context.Context, httpClient *http.Client, url string, delivery string, body []byte, key []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(headerNotificationEvent, notificationEventStatus)
+	request.Header.Set(headerNotificationDelivery, delivery)
+	if len(key) > 0 {
+		request.Header.Set(headerNotificationSignature, signNotification(key, body))
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("unexpected response %s", response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestNotifyStatusChange(t *testing.T) {
+	defer func(delay time.Duration) { notificationRetryDelay = delay }(notificationRetryDelay)
+	notificationRetryDelay = time.Millisecond
+
+	received := make(chan *http.Request, 10)
+	bodies := make(chan []byte, 10)
+	var attempts int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer flaky.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "apps", UID: "nightly-uid"},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{
+			Groups: []*jobsmanagerv1beta1.ManagedJobGroup{{Name: "extract", Status: ExecutionStatusFailed, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "a", Status: ExecutionStatusFailed}}}},
+			Notifications: &jobsmanagerv1beta1.ManagedJobNotifications{Webhooks: []jobsmanagerv1beta1.ManagedJobWebhook{
+				{URL: flaky.URL, Retries: 2, SigningSecret: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "hooks"}, Key: "key"}},
+				{URL: down.URL, Retries: 1},
+				{URL: down.URL, Statuses: []string{ExecutionStatusSucceeded}},
+			}},
+		},
+		Status: jobsmanagerv1beta1.ManagedJobStatus{Phase: ExecutionStatusFailed},
+	}
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "hooks", Namespace: "apps"}, Data: map[string][]byte{"key": []byte("s3cret")}}
+	recorder := record.NewFakeRecorder(10)
+	c := newTestClientBuilder(secret).Build()
+	notifier := &Notifier{Client: c, Reader: c, Recorder: recorder, Workers: 2,
+		Targets: jobsmanagerv1beta1.NotificationTargets{Allowed: []string{"127.0.0.1"}}}
+	cp := newTestConnPackageWithClient(&ManagedJobReconciler{Recorder: recorder, Notifier: notifier}, mj, c)
+
+	cp.notifyStatusChange(ExecutionStatusFailed)
+	cp.notifyStatusChange(ExecutionStatusRunning)
+	var queued corev1.ConfigMap
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "apps", Name: "nightly-notifications"}, &queued); err != nil {
+		t.Fatal(err)
+	}
+	if len(queued.Data) != 2 || metav1.GetControllerOf(&queued).UID != mj.UID {
+		t.Fatalf("expected the notifications queued in the ConfigMap of the workflow, got %+v", queued)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		_ = notifier.Start(ctx)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	select {
+	case request := <-received:
+		body := <-bodies
+		if signature := request.Header.Get(headerNotificationSignature); signature != signNotification([]byte("s3cret"), body) {
+			t.Errorf("unexpected signature %s", signature)
+		}
+		var notification statusNotification
+		if err := json.Unmarshal(body, &notification); err != nil {
+			t.Fatal(err)
+		}
+		if notification.Status != ExecutionStatusFailed || notification.PreviousStatus != ExecutionStatusRunning || notification.Groups[0].Jobs[0].Status != ExecutionStatusFailed {
+			t.Errorf("unexpected notification %+v", notification)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the notification delivered on the retry")
+	}
+
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, ReasonNotificationFailed) || !strings.Contains(event, "after 2 attempts") {
+			t.Errorf("unexpected event %s", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the failed delivery recorded")
+	}
+	select {
+	case event := <-recorder.Events:
+		t.Errorf("unexpected event %s", event)
+	case request := <-received:
+		t.Errorf("unexpected notification %s", request.Header.Get(headerNotificationDelivery))
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the delivered notification is removed, the failed one is kept as the dead letter
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if err := c.Get(context.Background(), types.NamespacedName{Namespace: "apps", Name: "nightly-notifications"}, &queued); err != nil {
+			t.Fatal(err)
+		}
+		if len(queued.Data) == 1 && strings.Contains(fmt.Sprint(queued.Data), "failedAt") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected only the dead letter left, got %v", queued.Data)
+		}
+	}
+	for _, data := range queued.Data {
+		var delivery notificationDelivery
+		if err := json.Unmarshal([]byte(data), &delivery); err != nil {
+			t.Fatal(err)
+		}
+		if delivery.URL != down.URL || delivery.FailedAt == nil || delivery.Attempts != 2 || delivery.LastError == "" {
+			t.Errorf("unexpected dead letter %+v", delivery)
+		}
+	}
+}
+
+func TestNotificationTargets(t *testing.T) {
+	var received int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+
+	notifier := &Notifier{}
+	for _, target := range []string{server.URL, "http://localhost:" + port, "http://169.254.169.254/latest/meta-data"} {
+		delivery := &notificationDelivery{URL: target, Delivery: "d1", Body: "{}"}
+		if err := notifier.post(context.Background(), "apps", delivery); err == nil || !strings.Contains(err.Error(), "not allowed") {
+			t.Errorf("expected the delivery to %s denied, got %v", target, err)
+		}
+	}
+	if atomic.LoadInt32(&received) != 0 {
+		t.Fatal("expected the denied notifications not sent")
+	}
+
+	// the name allowed by the operator is dialed even though it resolves to the loopback address
+	notifier = &Notifier{Targets: jobsmanagerv1beta1.NotificationTargets{Allowed: []string{"localhost"}}}
+	if err := notifier.post(context.Background(), "apps", &notificationDelivery{URL: "http://localhost:" + port, Delivery: "d2", Body: "{}"}); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&received) != 1 {
+		t.Errorf("expected the notification sent to the allowed host, got %d", received)
+	}
+}
+
+func TestNotificationBackoff(t *testing.T) {
+	for attempts, expected := range map[int32]time.Duration{1: 2 * time.Second, 2: 4 * time.Second, 4: 16 * time.Second, 10: notificationMaxRetryDelay} {
+		if wait := notificationBackoff(attempts); wait != expected {
+			t.Errorf("attempt %d: expected %s, got %s", attempts, expected, wait)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+/*
+Run IDs - every run of the workflow gets an ID when it starts. It's kept in the record of the run in
+status.runHistory, set as the run-id label of the Jobs, pods, sub-workflows and secrets created in the run,
+attached as the exemplar to the run metrics, logged with the run and sent in the run reports and the status
+notifications, so the logs, traces and metrics of other systems can be joined on a single run.
+*/
+
+const (
+	labelRunID = "jobmanager.raczylo.com/run-id"
+
+	RunIDFormatULID = "ulid"
+	RunIDFormatUUID = "uuid"
+)
+
+// RunIDGenerator returns the IDs of the new runs, they have to be valid label values
+type RunIDGenerator interface {
+	NewRunID(now time.Time) string
+}
+
+// RunIDGeneratorFunc adapts a function to the RunIDGenerator
+type RunIDGeneratorFunc func(now time.Time) string
+
+func (f RunIDGeneratorFunc) NewRunID(now time.Time) string {
+	return f(now)
+}
+
+// RunIDGenerators are the generators of the formats accepted by the operator
+var RunIDGenerators = map[string]RunIDGenerator{
+	RunIDFormatULID: RunIDGeneratorFunc(NewULID),
+	RunIDFormatUUID: RunIDGeneratorFunc(func(time.Time) string { return uuid.NewString() }),
+}
+
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID returns the ULID of the time, the IDs of the later runs sort after the earlier ones
+func NewULID(now time.Time) string {
+	var id [16]byte
+	ms := uint64(now.UnixMilli())
+	for i := 0; i < 6; i++ {
+		id[i] = byte(ms >> (40 - 8*i))
+	}
+	_, _ = rand.Read(id[6:])
+
+	// 128 bits are encoded as 26 characters of 5 bits, the first one carries 2 leading zero bits
+	bit := func(i int) byte {
+		if i < 2 {
+			return 0
+		}
+		i -= 2
+		return id[i/8] >> (7 - i%8) & 1
+	}
+	encoded := make([]byte, 26)
+	for c := range encoded {
+		value := byte(0)
+		for b := 0; b < 5; b++ {
+			value = value<<1 | bit(c*5+b)
+		}
+		encoded[c] = crockfordAlphabet[value]
+	}
+	return string(encoded)
+}
+
+func (r *ManagedJobReconciler) runIDs() RunIDGenerator {
+	if r == nil || r.RunIDs == nil {
+		return RunIDGenerators[RunIDFormatULID]
+	}
+	return r.RunIDs
+}
+
+// newRunID returns the ID of the run starting now
+func (cp *connPackage) newRunID() string {
+	return cp.r.runIDs().NewRunID(cp.now())
+}
+
+// currentRunID returns the ID of the latest run, empty for the runs started before the IDs
+func (cp *connPackage) currentRunID() string {
+	if len(cp.mj.Spec.RunHistory) == 0 {
+		return ""
+	}
+	return cp.mj.Spec.RunHistory[len(cp.mj.Spec.RunHistory)-1].ID
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestNewULID(t *testing.T) {
+	at := time.UnixMilli(1469918176385)
+	first, second := NewULID(at), NewULID(at.Add(time.Millisecond))
+	if len(first) != 26 || !strings.HasPrefix(first, "01ARYZ6S41") {
+		t.Errorf("unexpected ULID %q", first)
+	}
+	if first >= second || first == NewULID(at) {
+		t.Errorf("expected unique ULIDs sorted by the time, got %q and %q", first, second)
+	}
+	for _, id := range []string{first, RunIDGenerators[RunIDFormatUUID].NewRunID(at)} {
+		if errs := validation.IsValidLabelValue(id); len(errs) > 0 {
+			t.Errorf("run ID %q is not a valid label value: %v", id, errs)
+		}
+	}
+}
+
+func TestRunIDLabels(t *testing.T) {
+	mj := &jobsmanagerv1beta1.ManagedJob{ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "apps"}}
+	ids := 0
+	cp := &connPackage{
+		r: &ManagedJobReconciler{RunIDs: RunIDGeneratorFunc(func(time.Time) string {
+			ids++
+			return "run-" + string(rune('0'+ids))
+		})},
+		mj: mj,
+	}
+	group := &jobsmanagerv1beta1.ManagedJobGroup{Name: "load"}
+	job := &jobsmanagerv1beta1.ManagedJobDefinition{Name: "extract"}
+	if _, labelled := cp.jobLabels(group, job)[labelRunID]; labelled {
+		t.Error("run ID label set before the first run")
+	}
+
+	cp.recordRun(jobsmanagerv1beta1.ManagedJobRunRecord{Reason: runReasonCreated})
+	cp.recordRun(jobsmanagerv1beta1.ManagedJobRunRecord{Reason: runReasonRetry})
+	if mj.Spec.RunHistory[0].ID != "run-1" || cp.currentRunID() != "run-2" || cp.podLabels(group, job)[labelRunID] != "run-2" {
+		t.Errorf("unexpected run IDs %+v", mj.Spec.RunHistory)
+	}
+
+	// the run started before the IDs gets one
+	mj.Spec.RunHistory = []jobsmanagerv1beta1.ManagedJobRunRecord{{Reason: runReasonCreated}}
+	cp.trackRuns()
+	if cp.currentRunID() != "run-3" {
+		t.Errorf("expected the running run to get the ID, got %+v", mj.Spec.RunHistory)
+	}
+}
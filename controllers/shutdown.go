@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"context"
+	"time"
+)
+
+/*
+Graceful shutdown - the manager stops handing out new reconciles once the shutdown starts,
+the in-flight ones keep running on a context detached from the manager, so they can persist
+their changes, and are cancelled only when ShutdownTimeout passes.
+*/
+
+// detachedContext keeps the values of the parent context but not its cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+func (c detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (c detachedContext) Done() <-chan struct{}       { return nil }
+func (c detachedContext) Err() error                  { return nil }
+func (c detachedContext) Value(key any) any           { return c.parent.Value(key) }
+
+// drainContext returns the context for a single reconcile, which outlives the manager context
+// by at most ShutdownTimeout
+func (r *ManagedJobReconciler) drainContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	drainCtx, cancel := context.WithCancel(detachedContext{parent: ctx})
+	go func() {
+		select {
+		case <-drainCtx.Done():
+			return
+		case <-ctx.Done():
+		}
+		timer := time.NewTimer(r.ShutdownTimeout)
+		defer timer.Stop()
+		select {
+		case <-drainCtx.Done():
+		case <-timer.C:
+			cancel()
+		}
+	}()
+	return drainCtx, cancel
+}
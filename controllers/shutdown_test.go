@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDrainContext(t *testing.T) {
+	r := &ManagedJobReconciler{ShutdownTimeout: 50 * time.Millisecond}
+	managerCtx, stopManager := context.WithCancel(context.Background())
+	ctx, cancel := r.drainContext(managerCtx)
+	defer cancel()
+
+	stopManager()
+	select {
+	case <-ctx.Done():
+		t.Fatal("in-flight reconcile cancelled together with the manager")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("in-flight reconcile not cancelled after the shutdown timeout")
+	}
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"fmt"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+/*
+Entry points used to replay the scheduling of a workflow without a cluster.
+They run exactly the same code as the reconcile loop, only the side effects are left to the caller.
+*/
+
+// ResolveDependencies compiles the parameters and adds the implicit dependencies of the workflow,
+// the error lists the params patches which could not be applied
+func ResolveDependencies(mj *jobsmanagerv1beta1.ManagedJob) error {
+	cp := &connPackage{mj: mj}
+	_, err := cp.resolveDependencies()
+	return err
+}
+
+// PropagateStatuses refreshes the dependency statuses and aborts the jobs and groups which can not run anymore
+func PropagateStatuses(mj *jobsmanagerv1beta1.ManagedJob) {
+	propagateStatuses(mj.Name, &mj.Spec, nil)
+}
+
+// ScheduleRunnableJobs calls start for every job which would be started by the reconcile loop
+func ScheduleRunnableJobs(mj *jobsmanagerv1beta1.ManagedJob, start func(*jobsmanagerv1beta1.ManagedJobGroup, *jobsmanagerv1beta1.ManagedJobDefinition) error) error {
+	return scheduleRunnableJobs(mj.Name, &mj.Spec, func(*jobsmanagerv1beta1.ManagedJobGroup) bool { return true }, start, nil)
+}
+
+// WorkflowStatus returns the status the reconcile loop would set on the workflow
+func WorkflowStatus(mj *jobsmanagerv1beta1.ManagedJob) string {
+	return workflowStatus(&mj.Spec)
+}
+
+// ExplainJob describes in plain sentences why the job of the group has its current status
+func ExplainJob(mj *jobsmanagerv1beta1.ManagedJob, groupName string, jobName string) ([]string, error) {
+	for _, group := range mj.Spec.Groups {
+		if group.Name != groupName {
+			continue
+		}
+		for _, job := range group.Jobs {
+			if job.Name == jobName {
+				return explainJob(mj, group, job), nil
+			}
+		}
+		return nil, fmt.Errorf("group %s has no job %s", groupName, jobName)
+	}
+	return nil, fmt.Errorf("workflow %s has no group %s", mj.Name, groupName)
+}
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+/*
+Finished children lane - workflows which just had a child finish or fail are reconciled ahead of the routine
+periodic requeues, so the dependent jobs start without waiting for the whole queue. The events of the
+finished children go to a second controller with its own queue, reconciling with the same reconciler, and
+the main controller gets all the other events. The requeues asked for by the reconciles of the lane are
+handed over to the main controller, so the workflows leave the lane once their urgent reconcile is done.
+The reconciles of the same workflow by both controllers are serialized with workflowLocks.
+*/
+
+// finishedChildren passes only the updates of the children which have just finished, see childFinished
+var finishedChildren = predicate.Funcs{
+	CreateFunc:  func(event.CreateEvent) bool { return false },
+	UpdateFunc:  childFinished,
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
+
+// childFinished reports the child Job or sub-workflow which has just finished
+func childFinished(e event.UpdateEvent) bool {
+	return childJobFinished(e) || childWorkflowFinished(e)
+}
+
+// requeueHandover sends the workflows to the main controller once their requeue delay passes
+type requeueHandover struct {
+	delays workqueue.DelayingInterface
+	events chan event.GenericEvent
+}
+
+func newRequeueHandover() *requeueHandover {
+	return &requeueHandover{delays: workqueue.NewDelayingQueue(), events: make(chan event.GenericEvent)}
+}
+
+// reconciler reconciles the workflows of the lane, handing their requeues over
+func (h *requeueHandover) reconciler(r reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		result, err := r.Reconcile(ctx, req)
+		if err == nil && (result.Requeue || result.RequeueAfter > 0) {
+			h.delays.AddAfter(req.NamespacedName, result.RequeueAfter)
+			result = reconcile.Result{}
+		}
+		return result, err
+	})
+}
+
+// Start implements manager.Runnable, sending the workflows to the main controller until the manager stops
+func (h *requeueHandover) Start(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		h.delays.ShutDown()
+	}()
+	for {
+		item, shutdown := h.delays.Get()
+		if shutdown {
+			return nil
+		}
+		h.delays.Done(item)
+		key := item.(types.NamespacedName)
+		select {
+		case h.events <- event.GenericEvent{Object: &jobsmanagerv1beta1.ManagedJob{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// workflowLocks serializes the reconciles of the same workflow by the controllers sharing the reconciler
+type workflowLocks struct {
+	mtx   sync.Mutex
+	locks map[types.NamespacedName]*workflowLock
+}
+
+type workflowLock struct {
+	sync.Mutex
+	holders int
+}
+
+// lock waits for the workflow to be free and returns the function releasing it
+func (l *workflowLocks) lock(key types.NamespacedName) func() {
+	l.mtx.Lock()
+	if l.locks == nil {
+		l.locks = map[types.NamespacedName]*workflowLock{}
+	}
+	lock, found := l.locks[key]
+	if !found {
+		lock = &workflowLock{}
+		l.locks[key] = lock
+	}
+	lock.holders++
+	l.mtx.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		l.mtx.Lock()
+		defer l.mtx.Unlock()
+		if lock.holders--; lock.holders == 0 {
+			delete(l.locks, key)
+		}
+	}
+}
+
+// childJobFinished reports the Job which has just completed, failed or had one of its pods failed
+func childJobFinished(e event.UpdateEvent) bool {
+	oldJob, okOld := e.ObjectOld.(*kbatch.Job)
+	newJob, okNew := e.ObjectNew.(*kbatch.Job)
+	if !okOld || !okNew {
+		return false
+	}
+	return newJob.Status.Failed > oldJob.Status.Failed || jobFinished(newJob) && !jobFinished(oldJob)
+}
+
+func jobFinished(job *kbatch.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == kbatch.JobComplete || condition.Type == kbatch.JobFailed) && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// childWorkflowFinished reports the sub-workflow which has just reached the terminal status
+func childWorkflowFinished(e event.UpdateEvent) bool {
+	oldWorkflow, okOld := e.ObjectOld.(*jobsmanagerv1beta1.ManagedJob)
+	newWorkflow, okNew := e.ObjectNew.(*jobsmanagerv1beta1.ManagedJob)
+	if !okOld || !okNew || oldWorkflow.Status.Phase == newWorkflow.Status.Phase {
+		return false
+	}
+	switch newWorkflow.Status.Phase {
+	case ExecutionStatusSucceeded, ExecutionStatusFailed, ExecutionStatusAborted:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestWorkflowLocks(t *testing.T) {
+	locks := &workflowLocks{}
+	nightly := types.NamespacedName{Namespace: "etl", Name: "nightly"}
+	unlock := locks.lock(nightly)
+
+	// other workflows are not held up
+	locks.lock(types.NamespacedName{Namespace: "etl", Name: "hourly"})()
+
+	acquired := make(chan struct{})
+	go func() {
+		defer locks.lock(nightly)()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("expected the second reconcile of the workflow to wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("expected the workflow released")
+	}
+	locks.mtx.Lock()
+	defer locks.mtx.Unlock()
+	if len(locks.locks) != 0 {
+		t.Errorf("expected the released locks forgotten, got %v", locks.locks)
+	}
+}
+
+func TestRequeueHandover(t *testing.T) {
+	handover := newRequeueHandover()
+	reconciler := handover.reconciler(reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+		return reconcile.Result{RequeueAfter: 10 * time.Millisecond}, nil
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = handover.Start(ctx) }()
+
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "etl", Name: "nightly"}}
+	result, err := reconciler.Reconcile(ctx, req)
+	if err != nil || result != (reconcile.Result{}) {
+		t.Fatalf("expected the requeue handed over, got %+v %v", result, err)
+	}
+	select {
+	case e := <-handover.events:
+		if e.Object.GetNamespace() != "etl" || e.Object.GetName() != "nightly" {
+			t.Errorf("unexpected workflow %s/%s", e.Object.GetNamespace(), e.Object.GetName())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the workflow sent to the main controller")
+	}
+}
+
+func TestChildJobFinished(t *testing.T) {
+	running := &kbatch.Job{Status: kbatch.JobStatus{Active: 1}}
+	podFailed := &kbatch.Job{Status: kbatch.JobStatus{Active: 1, Failed: 1}}
+	completed := &kbatch.Job{Status: kbatch.JobStatus{Conditions: []kbatch.JobCondition{{Type: kbatch.JobComplete, Status: corev1.ConditionTrue}}}}
+
+	tests := []struct {
+		name     string
+		old, new *kbatch.Job
+		urgent   bool
+	}{
+		{name: "still running", old: running, new: running},
+		{name: "pod failed", old: running, new: podFailed, urgent: true},
+		{name: "completed", old: running, new: completed, urgent: true},
+		{name: "already completed", old: completed, new: completed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if urgent := childJobFinished(event.UpdateEvent{ObjectOld: tt.old, ObjectNew: tt.new}); urgent != tt.urgent {
+				t.Errorf("urgent = %v, expected %v", urgent, tt.urgent)
+			}
+		})
+	}
+}
+
+func TestFinishedChildren(t *testing.T) {
+	running := &kbatch.Job{Status: kbatch.JobStatus{Active: 1}}
+	completed := &kbatch.Job{Status: kbatch.JobStatus{Conditions: []kbatch.JobCondition{{Type: kbatch.JobComplete, Status: corev1.ConditionTrue}}}}
+	finished := event.UpdateEvent{ObjectOld: running, ObjectNew: completed}
+	routine := event.UpdateEvent{ObjectOld: running, ObjectNew: running}
+
+	// every event goes to exactly one of the controllers
+	if !finishedChildren.Update(finished) || predicate.Not(finishedChildren).Update(finished) {
+		t.Error("expected the finished child in the finished children lane only")
+	}
+	if finishedChildren.Update(routine) || !predicate.Not(finishedChildren).Update(routine) {
+		t.Error("expected the routine update in the main controller only")
+	}
+	if create := (event.CreateEvent{Object: running}); finishedChildren.Create(create) || !predicate.Not(finishedChildren).Create(create) {
+		t.Error("expected the created child in the main controller only")
+	}
+}
+
+// guards the controller-runtime upgrades, both controllers have to be built with the supported options
+func TestSetupWithManager(t *testing.T) {
+	scheme := newTestScheme()
+	mgr, err := ctrl.NewManager(&rest.Config{Host: "https://127.0.0.1:1"}, ctrl.Options{
+		Scheme:  scheme,
+		Metrics: metricsserver.Options{BindAddress: "0"},
+		MapperProvider: func(*rest.Config, *http.Client) (meta.RESTMapper, error) {
+			return testrestmapper.TestOnlyStaticRESTMapper(scheme), nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &ManagedJobReconciler{Client: mgr.GetClient(), Scheme: mgr.GetScheme(), Recorder: record.NewFakeRecorder(10)}
+	if err := r.SetupWithManager(mgr); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// rbac-gen writes the user facing ClusterRoles defined in pkg/rbac as manifests and as the template of the
+// Helm chart
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/yaml"
+
+	"raczylo.com/jobs-manager-operator/pkg/rbac"
+)
+
+const header = "# Code generated by hack/rbac-gen from pkg/rbac. DO NOT EDIT.\n"
+
+func main() {
+	var outputDir, chartTemplate string
+	flag.StringVar(&outputDir, "output-dir", "config/rbac", "Directory to write the ClusterRole manifests to.")
+	flag.StringVar(&chartTemplate, "chart-template", "", "Helm chart template to write the ClusterRoles to, none when empty.")
+	flag.Parse()
+
+	for _, role := range rbac.ClusterRoles() {
+		content, err := yaml.Marshal(role)
+		if err != nil {
+			fail(err)
+		}
+		fileHeader := fmt.Sprintf("%s# permissions of the %s role.\n", header, role.Name)
+		fileName := filepath.Join(outputDir, strings.ReplaceAll(role.Name, "-", "_")+"_role.yaml")
+		if err := os.WriteFile(fileName, append([]byte(fileHeader), content...), 0o644); err != nil {
+			fail(err)
+		}
+	}
+	if chartTemplate != "" {
+		content, err := chartRoles(rbac.ClusterRoles())
+		if err != nil {
+			fail(err)
+		}
+		if err := os.WriteFile(chartTemplate, content, 0o644); err != nil {
+			fail(err)
+		}
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+// chartRoles renders the roles as the chart template enabled with userRoles.enabled, the name and the
+// instance labels come from the chart labels
+func chartRoles(roles []rbacv1.ClusterRole) ([]byte, error) {
+	out := &bytes.Buffer{}
+	out.WriteString("{{- /* " + strings.TrimSpace(strings.TrimPrefix(header, "#")) + " */ -}}\n")
+	out.WriteString("{{- if .Values.userRoles.enabled }}\n")
+	for i, role := range roles {
+		if i > 0 {
+			out.WriteString("---\n")
+		}
+		fmt.Fprintf(out, "apiVersion: %s\nkind: %s\nmetadata:\n  name: %s\n  labels:\n", role.APIVersion, role.Kind, role.Name)
+		labels := map[string]string{}
+		for key, value := range role.Labels {
+			if key != "app.kubernetes.io/name" && key != "app.kubernetes.io/instance" {
+				labels[key] = value
+			}
+		}
+		content, err := yaml.Marshal(labels)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+			fmt.Fprintf(out, "    %s\n", line)
+		}
+		out.WriteString("  {{- include \"chart.labels\" . | nindent 4 }}\n")
+		rules, err := yaml.Marshal(map[string]interface{}{"rules": role.Rules})
+		if err != nil {
+			return nil, err
+		}
+		out.Write(rules)
+	}
+	out.WriteString("{{- end }}\n")
+	return out.Bytes(), nil
+}
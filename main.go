@@ -19,45 +19,134 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
+	"time"
+	// time zones of the maintenance windows do not depend on the image
+	_ "time/tzdata"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
-	"k8s.io/apimachinery/pkg/runtime"
-	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
-	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
-	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
 	"raczylo.com/jobs-manager-operator/controllers"
-	//+kubebuilder:scaffold:imports
+	"raczylo.com/jobs-manager-operator/pkg/operator"
 )
 
-var (
-	scheme   = runtime.NewScheme()
-	setupLog = ctrl.Log.WithName("setup")
-)
+var setupLog = ctrl.Log.WithName("setup")
 
-func init() {
-	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+// maintenanceWindows collects the repeated --maintenance-window flags
+type maintenanceWindows struct {
+	windows *[]jobsmanagerv1beta1.ManagedJobMaintenanceWindow
+	values  []string
+}
 
-	utilruntime.Must(jobsmanagerv1beta1.AddToScheme(scheme))
-	//+kubebuilder:scaffold:scheme
+func (w *maintenanceWindows) String() string {
+	return strings.Join(w.values, "; ")
+}
+
+func (w *maintenanceWindows) Set(value string) error {
+	window, err := controllers.ParseMaintenanceWindow(value)
+	if err != nil {
+		return err
+	}
+	*w.windows = append(*w.windows, window)
+	w.values = append(w.values, value)
+	return nil
 }
 
 func main() {
-	var metricsAddr string
-	var enableLeaderElection bool
-	var probeAddr string
-	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
-	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
-	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
+	options := operator.DefaultOptions()
+	flag.StringVar(&options.MetricsBindAddress, "metrics-bind-address", options.MetricsBindAddress, "The address the metric endpoint binds to.")
+	flag.StringVar(&options.HealthProbeBindAddress, "health-probe-bind-address", options.HealthProbeBindAddress, "The address the probe endpoint binds to.")
+	flag.BoolVar(&options.LeaderElection, "leader-elect", options.LeaderElection,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&options.LeaderElectionID, "leader-election-id", options.LeaderElectionID,
+		"Name of the leader election lease, every shard started with --watch-label-selector needs its own.")
+	kubeAPIQPS := flag.Float64("kube-api-qps", float64(options.QPS),
+		"Requests per second the manager may send to the API server before its client throttles them.")
+	flag.IntVar(&options.Burst, "kube-api-burst", options.Burst,
+		"Requests the manager may send to the API server in a burst above --kube-api-qps.")
+	flag.StringVar(&options.LogArchiveURL, "log-archive-url", options.LogArchiveURL,
+		"Object storage location for the logs of completed jobs, e.g. s3://bucket/prefix, gs://bucket/prefix "+
+			"or azblob://account/container/prefix. Archiving is disabled when empty.")
+	flag.StringVar(&options.PushgatewayURL, "pushgateway-url", options.PushgatewayURL,
+		"Prometheus Pushgateway address injected as PUSHGATEWAY_URL into jobs of workflows annotated with "+
+			"jobsmanager.raczylo.com/push-metrics.")
+	flag.BoolVar(&options.CapacityCheck, "capacity-check", options.CapacityCheck,
+		"Delay starting a group until requests of its jobs fit into the remaining namespace resource quota.")
+	flag.BoolVar(&options.RecordResolvedSpec, "record-resolved-spec", options.RecordResolvedSpec,
+		"Store the full resolved pod spec in the jobmanager.raczylo.com/resolved-spec annotation of created Jobs.")
+	flag.DurationVar(&options.ResyncInterval, "resync-interval", options.ResyncInterval,
+		"How often all non-terminal workflows are reconciled as a safety net, 0 sweeps them only once after the start.")
+	flag.DurationVar(&options.ShutdownTimeout, "shutdown-timeout", options.ShutdownTimeout,
+		"How long the in-flight reconciles may run to persist their changes after the termination signal.")
+	flag.IntVar(&options.NotificationWorkers, "notification-workers", options.NotificationWorkers,
+		"How many status notifications of the workflows are delivered at the same time.")
+	notificationAllowedHosts := flag.String("notification-allowed-hosts", "",
+		"Comma separated hosts local to the cluster the notification webhooks may be sent to, e.g. alertmanager.monitoring.svc,*.corp.example.com,10.20.0.0/16.")
+	flag.BoolVar(&options.DecisionEvents, "decision-events", options.DecisionEvents,
+		"Record the decisions to start, abort or retry a job as events of the workflow, they are always logged at V(1).")
+	flag.Var(&maintenanceWindows{windows: &options.MaintenanceWindows}, "maintenance-window",
+		"No new jobs are started during the window given as '[days] HH:MM duration [time zone]', e.g. 'Sat,Sun 22:00 8h Europe/Warsaw'. "+
+			"Can be repeated, the running jobs continue.")
+	flag.IntVar(&options.MaxActiveWorkflowsPerNamespace, "max-active-workflows-per-namespace", options.MaxActiveWorkflowsPerNamespace,
+		"Workflows of a namespace which would start above the cap wait in the queued state, 0 is unlimited.")
+	flag.IntVar(&options.MaxActiveJobsPerNamespace, "max-active-jobs-per-namespace", options.MaxActiveJobsPerNamespace,
+		"No new jobs are started while as many jobs of the namespace run, 0 is unlimited.")
+	imagePullRefreshCronJob := flag.String("image-pull-refresh-cronjob", "",
+		"CronJob run once, as namespace/name, when the registry denies the image pull of a job, e.g. the ECR token refresher.")
+	imagePullRefreshWebhook := flag.String("image-pull-refresh-webhook", "",
+		"URL receiving a POST with the details when the registry denies the image pull of a job.")
+	imagePullRetryAfter := flag.Duration("image-pull-retry-after", time.Minute,
+		"How long the credentials refresh has before the pods stuck on the denied image pull are recreated.")
+	flag.DurationVar(&options.SlowSchedulingThreshold, "slow-scheduling-threshold", options.SlowSchedulingThreshold,
+		"How long the pods of a job may take to run before the SlowScheduling event reports why they are pending, 0 disables the reports.")
+	flag.StringVar(&options.NamespaceDeletionProtection, "namespace-deletion-protection", options.NamespaceDeletionProtection,
+		"Deletion of the namespaces with running workflows, off, warn or deny, checked by the webhook when ENABLE_WEBHOOKS is true.")
+	flag.Float64Var(&options.CostPrices.CPUHour, "cpu-hour-price", options.CostPrices.CPUHour,
+		"Price of a CPU-hour the costs of the jobs are estimated with, from their requests and run time.")
+	flag.Float64Var(&options.CostPrices.MemoryGBHour, "memory-gb-hour-price", options.CostPrices.MemoryGBHour,
+		"Price of a GiB-hour of memory the costs of the jobs are estimated with, costs are not estimated when both prices are 0.")
+	flag.IntVar(&options.RevisionHistoryLimit, "revision-history-limit", options.RevisionHistoryLimit,
+		"Definitions of every workflow kept in the revision ConfigMaps for kubectl managedjob rollback, 0 keeps none.")
+	flag.StringVar(&options.MigrationNamespace, "migration-namespace", options.MigrationNamespace,
+		"Namespace of the ConfigMap recording the migrations of the stored workflows, the namespace of the operator pod when empty.")
+	flag.StringVar(&options.RunIDFormat, "run-id-format", options.RunIDFormat,
+		"Format of the IDs of the workflow runs set in the run-id label of the Jobs and pods, ulid or uuid.")
+	flag.StringVar(&options.AdminBindAddress, "admin-bind-address", options.AdminBindAddress,
+		"The address the admin API serving the kubectl managedjob operations binds to, 0 disables it.")
+	flag.StringVar(&options.AdminCertFile, "admin-cert-file", options.AdminCertFile,
+		"Certificate the admin API is served over TLS with, required unless --admin-insecure is set.")
+	flag.StringVar(&options.AdminKeyFile, "admin-key-file", options.AdminKeyFile,
+		"Private key of --admin-cert-file.")
+	flag.BoolVar(&options.AdminInsecure, "admin-insecure", options.AdminInsecure,
+		"Serve the admin API over plain HTTP without --admin-cert-file, the bearer tokens of the callers travel in clear text.")
+	flag.IntVar(&options.ReconcileErrorBudget, "reconcile-error-budget", options.ReconcileErrorBudget,
+		"Consecutive failed reconciles after which the workflow gets the ReconcileDegraded condition and is reconciled rarely, 0 never degrades the workflows.")
+	flag.DurationVar(&options.DegradedRequeue, "degraded-requeue-interval", options.DegradedRequeue,
+		"How often the degraded workflows are reconciled, edits of the workflow are picked up right away.")
+	flag.DurationVar(&options.FullSyncInterval, "full-sync-interval", options.FullSyncInterval,
+		"How long the workflows which last reconcile changed nothing skip the sync while neither they nor their children change, 0 always syncs them.")
+	flag.StringVar(&options.WatchLabelSelector, "watch-label-selector", options.WatchLabelSelector,
+		"Reconcile only the ManagedJobs matching the label selector, e.g. team=data, to shard the workflows "+
+			"between multiple operator deployments. All the ManagedJobs are reconciled when empty.")
+	flag.StringVar(&options.OperatorUsername, "operator-username", options.OperatorUsername,
+		"Username the operator is authenticated as, the webhook lets only it create the sub-workflows. "+
+			"The ServiceAccount named by OPERATOR_SERVICE_ACCOUNT in the namespace of the operator pod when empty.")
+	flag.BoolVar(&options.AuthorizePrivilegedJobs, "authorize-privileged-jobs", options.AuthorizePrivilegedJobs,
+		"Start the jobs with privileged containers or hostPath volumes only when the creator of the workflow may use managedjobs/privileged, needs the webhooks.")
+	flag.BoolVar(&options.ImpersonateJobCreators, "impersonate-job-creators", options.ImpersonateJobCreators,
+		"Create the jobs of the groups with impersonate as the creator of the workflow or the ServiceAccount, needs the webhooks to record the creators.")
+	vaultAddress := flag.String("vault-address", "",
+		"Address of Vault issuing the secrets of the jobs with secretsFrom.vault, e.g. https://vault.vault.svc:8200.")
+	vaultAuthMount := flag.String("vault-auth-mount", "kubernetes",
+		"Path of the Vault kubernetes auth method the operator logs in with as the service accounts of the jobs.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -66,48 +155,33 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme: scheme,
-		Metrics: metricsserver.Options{
-			BindAddress: metricsAddr,
-		},
-		// Port:                   9443,
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "b86e0f00.raczylo.com",
-		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
-		// when the Manager ends. This requires the binary to immediately end when the
-		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
-		// speeds up voluntary leader transitions as the new leader don't have to wait
-		// LeaseDuration time first.
-		//
-		// In the default scaffold provided, the program ends immediately after
-		// the manager stops, so would be fine to enable this option. However,
-		// if you are doing or is intended to do any operation such as perform cleanups
-		// after the manager stops then its usage might be unsafe.
-		// LeaderElectionReleaseOnCancel: true,
-	})
-	if err != nil {
-		setupLog.Error(err, "unable to start manager")
-		os.Exit(1)
+	options.QPS = float32(*kubeAPIQPS)
+	if *notificationAllowedHosts != "" {
+		options.NotificationAllowedHosts = strings.Split(*notificationAllowedHosts, ",")
 	}
 
-	if err = (&controllers.ManagedJobReconciler{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorderFor("managedjob-controller"),
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "ManagedJob")
-		os.Exit(1)
+	if *imagePullRefreshCronJob != "" || *imagePullRefreshWebhook != "" {
+		options.ImagePullRefresh = &controllers.ImagePullRefresh{WebhookURL: *imagePullRefreshWebhook, RetryAfter: *imagePullRetryAfter}
+		if *imagePullRefreshCronJob != "" {
+			namespace, name, found := strings.Cut(*imagePullRefreshCronJob, "/")
+			if !found || namespace == "" || name == "" {
+				setupLog.Error(nil, "--image-pull-refresh-cronjob must be namespace/name", "value", *imagePullRefreshCronJob)
+				os.Exit(1)
+			}
+			options.ImagePullRefresh.CronJob = types.NamespacedName{Namespace: namespace, Name: name}
+		}
 	}
-	//+kubebuilder:scaffold:builder
 
-	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
-		setupLog.Error(err, "unable to set up health check")
-		os.Exit(1)
+	if *vaultAddress != "" {
+		options.Vault = &controllers.VaultSecrets{Address: *vaultAddress, AuthMount: *vaultAuthMount}
 	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
-		setupLog.Error(err, "unable to set up ready check")
+
+	// the webhook needs the serving certificate, see config/webhook and config/certmanager
+	options.EnableWebhooks = os.Getenv("ENABLE_WEBHOOKS") == "true"
+
+	mgr, err := operator.New(options)
+	if err != nil {
+		setupLog.Error(err, "unable to set up the operator")
 		os.Exit(1)
 	}
 
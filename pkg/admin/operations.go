@@ -0,0 +1,178 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/controllers"
+	"raczylo.com/jobs-manager-operator/pkg/visualization"
+)
+
+// WorkflowSummary is the workflow in the list, the way `kubectl get managedjobs` shows it
+type WorkflowSummary struct {
+	Namespace   string     `json:"namespace"`
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	Progress    string     `json:"progress,omitempty"`
+	FailedGroup string     `json:"failedGroup,omitempty"`
+	RunID       string     `json:"runId,omitempty"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+// ActionResult is the outcome of the retry, abort and approve operations
+type ActionResult struct {
+	Result string `json:"result"`
+}
+
+// operationError is the operation refused with the HTTP status, e.g. the one which does not apply to
+// the current state of the workflow
+type operationError struct {
+	status  int
+	message string
+}
+
+func (e operationError) Error() string {
+	return e.message
+}
+
+// list returns the workflows of the namespace, filtered with the labelSelector query parameter
+func (s *Server) list(r *request) (interface{}, error) {
+	selector, err := labels.Parse(r.URL.Query().Get("labelSelector"))
+	if err != nil {
+		return nil, operationError{status: http.StatusBadRequest, message: err.Error()}
+	}
+	var workflows jobsmanagerv1beta1.ManagedJobList
+	if err := s.reader().List(r.Context(), &workflows, client.InNamespace(r.namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	summaries := []WorkflowSummary{}
+	for _, mj := range workflows.Items {
+		summary := WorkflowSummary{Namespace: mj.Namespace, Name: mj.Name, Status: mj.Status.Phase, Progress: mj.Status.Progress, FailedGroup: mj.Status.FailedGroup}
+		if runs := mj.Status.RunHistory; len(runs) > 0 {
+			run := runs[len(runs)-1]
+			summary.RunID = run.ID
+			summary.StartedAt = &run.StartedAt.Time
+			if run.CompletedAt != nil {
+				summary.CompletedAt = &run.CompletedAt.Time
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// get returns the workflow with its status merged in and the details of its status pages
+func (s *Server) get(r *request) (interface{}, error) {
+	mj := &jobsmanagerv1beta1.ManagedJob{}
+	if err := s.reader().Get(r.Context(), client.ObjectKey{Namespace: r.namespace, Name: r.name}, mj); err != nil {
+		return nil, err
+	}
+	if err := controllers.LoadStatus(r.Context(), s.reader(), mj); err != nil {
+		return nil, err
+	}
+	return mj, nil
+}
+
+// tree returns the tree of the workflow like `kubectl managedjob visualize -o json`
+func (s *Server) tree(r *request) (interface{}, error) {
+	workflow, err := s.get(r)
+	if err != nil {
+		return nil, err
+	}
+	mj := workflow.(*jobsmanagerv1beta1.ManagedJob)
+	var childJobs kbatch.JobList
+	if err := s.reader().List(r.Context(), &childJobs, client.InNamespace(r.namespace), client.MatchingLabels{"jobmanager.raczylo.com/workflow-name": mj.Name}); err != nil {
+		return nil, err
+	}
+	root := visualization.FromManagedJob(mj)
+	visualization.AddDurations(root, childJobs.Items, time.Now())
+	return visualization.Export(root), nil
+}
+
+// retry runs the workflow again, only its failed and aborted jobs with the failed=true query parameter
+func (s *Server) retry(r *request) (interface{}, error) {
+	action := controllers.ActionRetry
+	if r.URL.Query().Get("failed") == "true" {
+		action = controllers.ActionRetryFailed
+	}
+	return s.requestAction(r, action)
+}
+
+func (s *Server) abort(r *request) (interface{}, error) {
+	return s.requestAction(r, controllers.ActionAbort)
+}
+
+// requestAction sets the action annotation like `kubectl managedjob retry` and `abort`, the controller
+// takes the action and removes it
+func (s *Server) requestAction(r *request, action string) (interface{}, error) {
+	mj := &jobsmanagerv1beta1.ManagedJob{}
+	if err := s.reader().Get(r.Context(), client.ObjectKey{Namespace: r.namespace, Name: r.name}, mj); err != nil {
+		return nil, err
+	}
+	if !controllers.ActionApplies(action, mj.Status.Phase) {
+		return nil, operationError{status: http.StatusConflict, message: fmt.Sprintf("%s does not apply to the %s workflow %s", action, mj.Status.Phase, mj.Name)}
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": map[string]string{controllers.AnnotationAction: action}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Client.Patch(r.Context(), mj, client.RawPatch(types.MergePatchType, patch)); err != nil {
+		return nil, err
+	}
+	return ActionResult{Result: action + " requested"}, nil
+}
+
+// approve approves the start of the paused group like `kubectl managedjob approve`
+func (s *Server) approve(r *request) (interface{}, error) {
+	mj := &jobsmanagerv1beta1.ManagedJob{}
+	if err := s.reader().Get(r.Context(), client.ObjectKey{Namespace: r.namespace, Name: r.name}, mj); err != nil {
+		return nil, err
+	}
+	for i, group := range mj.Spec.Groups {
+		if group.Name != r.group {
+			continue
+		}
+		if !group.PauseBefore {
+			return nil, operationError{status: http.StatusConflict, message: fmt.Sprintf("group %s does not wait for the approval", r.group)}
+		}
+		// the approval used by the previous run counts once the operator forgot it
+		if group.Approved && mj.ApprovalConsumed(r.group) {
+			withdraw, err := json.Marshal([]map[string]interface{}{
+				{"op": "test", "path": fmt.Sprintf("/spec/groups/%d/name", i), "value": r.group},
+				{"op": "remove", "path": fmt.Sprintf("/spec/groups/%d/approved", i)},
+			})
+			if err != nil {
+				return nil, err
+			}
+			if err := controllers.WithdrawConsumed(r.Context(), s.Client, mj, withdraw, func(mj *jobsmanagerv1beta1.ManagedJob) bool {
+				return mj.ApprovalConsumed(r.group)
+			}); err != nil {
+				return nil, err
+			}
+		}
+		// the test guards against the groups being reordered in the meantime
+		patch, err := json.Marshal([]map[string]interface{}{
+			{"op": "test", "path": fmt.Sprintf("/spec/groups/%d/name", i), "value": r.group},
+			{"op": "add", "path": fmt.Sprintf("/spec/groups/%d/approved", i), "value": true},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := s.Client.Patch(r.Context(), mj, client.RawPatch(types.JSONPatchType, patch)); err != nil {
+			return nil, err
+		}
+		return ActionResult{Result: fmt.Sprintf("group %s approved", r.group)}, nil
+	}
+	return nil, operationError{status: http.StatusNotFound, message: fmt.Sprintf("workflow %s has no group %s", r.name, r.group)}
+}
@@ -0,0 +1,257 @@
+// Package admin serves the operations of the kubectl plugin over HTTP, so the internal portals can list,
+// inspect, retry, abort and approve the workflows without shelling out to kubectl. Callers authenticate
+// with their Kubernetes bearer token, reviewed with the TokenReview, and every operation is authorized with
+// the SubjectAccessReview against the same RBAC rules kubectl would be checked against.
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+// PathPrefix of the API, the workflows are served under namespaces/<namespace>/workflows
+const PathPrefix = "/api/v1/"
+
+const shutdownTimeout = 5 * time.Second
+
+// Server is the admin API, a manager runnable served by every replica of the operator
+type Server struct {
+	// Client patches the workflows once the caller was authorized
+	Client client.Client
+	// Reader reads the workflows and their Jobs, the Client when nil. The cache of the sharded operator
+	// holds the workflows of its shard only, the API reader serves all of them.
+	Reader client.Reader
+	// Clientset reviews the tokens and the access of the callers
+	Clientset kubernetes.Interface
+	// BindAddress of the API
+	BindAddress string
+	// CertFile and KeyFile serve the API over TLS
+	CertFile string
+	KeyFile  string
+	// Insecure serves the API over plain HTTP when there is no certificate, the bearer tokens of the callers
+	// travel in clear text then
+	Insecure bool
+}
+
+var errInsecure = errors.New("admin API is served over TLS only, set the certificate and the key or allow plain HTTP")
+
+//+kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+//+kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// NeedLeaderElection serves the API on all the replicas, the operations only request the actions
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves the API until the manager stops
+func (s *Server) Start(ctx context.Context) error {
+	if s.CertFile == "" && !s.Insecure {
+		return errInsecure
+	}
+	server := &http.Server{Addr: s.BindAddress, Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	served := make(chan error, 1)
+	go func() {
+		if s.CertFile != "" {
+			served <- server.ListenAndServeTLS(s.CertFile, s.KeyFile)
+		} else {
+			served <- server.ListenAndServe()
+		}
+	}()
+	log.Log.Info("Serving the admin API", "address", s.BindAddress, "tls", s.CertFile != "")
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+func (s *Server) reader() client.Reader {
+	if s.Reader == nil {
+		return s.Client
+	}
+	return s.Reader
+}
+
+// request is the authenticated call of the API
+type request struct {
+	*http.Request
+	user      authenticationv1.UserInfo
+	namespace string
+	name      string
+	// group of the approve operation
+	group string
+}
+
+// route is the operation matched by the method and the path segments after the workflow name
+type route struct {
+	method string
+	// verb of the managedjobs, or of their subresource, the caller needs
+	verb        string
+	subresource string
+	handle      func(s *Server, r *request) (interface{}, error)
+}
+
+// the actions are authorized like the ones taken through the webhook, on the virtual subresources
+var routes = map[string]route{
+	"":        {method: http.MethodGet, verb: "list", handle: (*Server).list},
+	"get":     {method: http.MethodGet, verb: "get", handle: (*Server).get},
+	"tree":    {method: http.MethodGet, verb: "get", handle: (*Server).tree},
+	"retry":   {method: http.MethodPost, verb: jobsmanagerv1beta1.RuntimeChangeVerb, subresource: jobsmanagerv1beta1.ActionSubresource, handle: (*Server).retry},
+	"abort":   {method: http.MethodPost, verb: jobsmanagerv1beta1.RuntimeChangeVerb, subresource: jobsmanagerv1beta1.ActionSubresource, handle: (*Server).abort},
+	"approve": {method: http.MethodPost, verb: jobsmanagerv1beta1.RuntimeChangeVerb, subresource: jobsmanagerv1beta1.ApprovalSubresource, handle: (*Server).approve},
+}
+
+// ServeHTTP routes namespaces/<namespace>/workflows, .../<name>, .../<name>/tree, .../<name>/retry,
+// .../<name>/abort and .../<name>/groups/<group>/approve
+func (s *Server) ServeHTTP(w http.ResponseWriter, httpRequest *http.Request) {
+	token := strings.TrimPrefix(httpRequest.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == httpRequest.Header.Get("Authorization") {
+		writeError(w, http.StatusUnauthorized, "bearer token required")
+		return
+	}
+	user, err := s.authenticate(httpRequest.Context(), token)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	r := &request{Request: httpRequest, user: user}
+	operation, found := r.parse()
+	if !found {
+		writeError(w, http.StatusNotFound, "unknown path "+httpRequest.URL.Path)
+		return
+	}
+	route := routes[operation]
+	if httpRequest.Method != route.method {
+		writeError(w, http.StatusMethodNotAllowed, route.method+" expected")
+		return
+	}
+	if err := s.authorize(httpRequest.Context(), r, route.verb, route.subresource); err != nil {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	response, err := route.handle(s, r)
+	log.Log.V(1).Info("Admin API request", "user", user.Username, "operation", operation, "namespace", r.namespace, "workflow", r.name, "error", err)
+	if err != nil {
+		status := http.StatusInternalServerError
+		var apiStatus apierrors.APIStatus
+		if errors.As(err, &apiStatus) {
+			status = int(apiStatus.Status().Code)
+		}
+		var refused operationError
+		if errors.As(err, &refused) {
+			status = refused.status
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// parse reads the namespace and the workflow name of the path, it returns the operation
+func (r *request) parse() (string, bool) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, PathPrefix), "/")
+	segments := strings.Split(path, "/")
+	if len(segments) < 3 || segments[0] != "namespaces" || segments[1] == "" || segments[2] != "workflows" {
+		return "", false
+	}
+	r.namespace = segments[1]
+	switch len(segments) {
+	case 3:
+		return "", true
+	case 4:
+		r.name = segments[3]
+		return "get", true
+	case 5:
+		r.name = segments[3]
+		switch segments[4] {
+		case "tree", "retry", "abort":
+			return segments[4], true
+		}
+	case 7:
+		r.name, r.group = segments[3], segments[5]
+		return "approve", segments[4] == "groups" && segments[5] != "" && segments[6] == "approve"
+	}
+	return "", false
+}
+
+// authenticate reviews the bearer token of the caller
+func (s *Server) authenticate(ctx context.Context, token string) (authenticationv1.UserInfo, error) {
+	review, err := s.Clientset.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return authenticationv1.UserInfo{}, fmt.Errorf("unable to review the token: %w", err)
+	}
+	if !review.Status.Authenticated {
+		if review.Status.Error != "" {
+			return authenticationv1.UserInfo{}, fmt.Errorf("token not authenticated: %s", review.Status.Error)
+		}
+		return authenticationv1.UserInfo{}, errors.New("token not authenticated")
+	}
+	return review.Status.User, nil
+}
+
+// authorize checks the caller may take the verb on the workflows of the request or on their subresource
+func (s *Server) authorize(ctx context.Context, r *request, verb, subresource string) error {
+	extra := map[string]authorizationv1.ExtraValue{}
+	for k, v := range r.user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	review, err := s.Clientset.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   r.user.Username,
+			UID:    r.user.UID,
+			Groups: r.user.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   r.namespace,
+				Verb:        verb,
+				Group:       jobsmanagerv1beta1.GroupVersion.Group,
+				Resource:    "managedjobs",
+				Subresource: subresource,
+				Name:        r.name,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to review the access: %w", err)
+	}
+	resource := "managedjobs"
+	if subresource != "" {
+		resource += "/" + subresource
+	}
+	if !review.Status.Allowed {
+		return fmt.Errorf("%s may not %s the %s in the namespace %s", r.user.Username, verb, resource, r.namespace)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
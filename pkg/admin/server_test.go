@@ -0,0 +1,130 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/controllers"
+)
+
+func TestServer(t *testing.T) {
+	clientset := kubefake.NewSimpleClientset()
+	clientset.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if review.Spec.Token != "invalid" {
+			review.Status.Authenticated = true
+			review.Status.User = authenticationv1.UserInfo{Username: review.Spec.Token}
+		}
+		return true, review, nil
+	})
+	// the viewer may only read the workflows, the operator may take the actions
+	clientset.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		review.Status.Allowed = review.Spec.User == "operator" || review.Spec.ResourceAttributes.Subresource == ""
+		return true, review, nil
+	})
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = jobsmanagerv1beta1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&jobsmanagerv1beta1.ManagedJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "apps"},
+			Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+				{Name: "extract"}, {Name: "publish", PauseBefore: true},
+			}},
+			Status: jobsmanagerv1beta1.ManagedJobStatus{Phase: controllers.ExecutionStatusRunning},
+		},
+		&jobsmanagerv1beta1.ManagedJob{ObjectMeta: metav1.ObjectMeta{Name: "hourly", Namespace: "apps"}, Status: jobsmanagerv1beta1.ManagedJobStatus{Phase: controllers.ExecutionStatusFailed}},
+	).Build()
+	server := httptest.NewServer(&Server{Client: c, Clientset: clientset})
+	defer server.Close()
+
+	call := func(method, path, token string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+PathPrefix+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body map[string]interface{}
+		var list []WorkflowSummary
+		raw := json.NewDecoder(resp.Body)
+		if strings.HasSuffix(path, "/workflows") && resp.StatusCode == http.StatusOK {
+			_ = raw.Decode(&list)
+			names := []string{}
+			for _, summary := range list {
+				names = append(names, summary.Name)
+			}
+			return resp.StatusCode, strings.Join(names, ",")
+		}
+		_ = raw.Decode(&body)
+		if message, found := body["error"]; found {
+			return resp.StatusCode, message.(string)
+		}
+		return resp.StatusCode, body["result"].(string)
+	}
+
+	for _, tc := range []struct {
+		method, path, token string
+		status              int
+		body                string
+	}{
+		{http.MethodGet, "namespaces/apps/workflows", "", http.StatusUnauthorized, "bearer token required"},
+		{http.MethodGet, "namespaces/apps/workflows", "invalid", http.StatusUnauthorized, "token not authenticated"},
+		{http.MethodGet, "namespaces/apps/workflows", "viewer", http.StatusOK, "hourly,nightly"},
+		{http.MethodGet, "namespaces/apps/workflows?labelSelector=team%3D%3D%3D", "viewer", http.StatusBadRequest, ""},
+		{http.MethodGet, "namespaces/apps/workflows/nightly/retry", "viewer", http.StatusMethodNotAllowed, "POST expected"},
+		{http.MethodPost, "namespaces/apps/workflows/hourly/retry", "viewer", http.StatusForbidden, "viewer may not update the managedjobs/action in the namespace apps"},
+		{http.MethodPost, "namespaces/apps/workflows/hourly/abort", "operator", http.StatusConflict, "abort does not apply to the failed workflow hourly"},
+		{http.MethodPost, "namespaces/apps/workflows/hourly/retry?failed=true", "operator", http.StatusOK, "retry-failed requested"},
+		{http.MethodPost, "namespaces/apps/workflows/nightly/groups/extract/approve", "operator", http.StatusConflict, "group extract does not wait for the approval"},
+		{http.MethodPost, "namespaces/apps/workflows/nightly/groups/publish/approve", "operator", http.StatusOK, "group publish approved"},
+		{http.MethodPost, "namespaces/apps/workflows/missing/abort", "operator", http.StatusNotFound, ""},
+		{http.MethodGet, "namespaces/apps/jobs", "viewer", http.StatusNotFound, "unknown path /api/v1/namespaces/apps/jobs"},
+	} {
+		status, body := call(tc.method, tc.path, tc.token)
+		if status != tc.status || (tc.body != "" && body != tc.body) {
+			t.Errorf("%s %s: expected %d %q, got %d %q", tc.method, tc.path, tc.status, tc.body, status, body)
+		}
+	}
+
+	hourly, nightly := &jobsmanagerv1beta1.ManagedJob{}, &jobsmanagerv1beta1.ManagedJob{}
+	_ = c.Get(context.Background(), client.ObjectKey{Namespace: "apps", Name: "hourly"}, hourly)
+	_ = c.Get(context.Background(), client.ObjectKey{Namespace: "apps", Name: "nightly"}, nightly)
+	if hourly.Annotations[controllers.AnnotationAction] != controllers.ActionRetryFailed || !nightly.Spec.Groups[1].Approved {
+		t.Errorf("actions not requested, annotations %v, groups %+v", hourly.Annotations, nightly.Spec.Groups)
+	}
+}
+
+func TestServerRequiresTLS(t *testing.T) {
+	server := &Server{BindAddress: "127.0.0.1:0"}
+	if err := server.Start(context.Background()); err != errInsecure {
+		t.Errorf("expected the API without the certificate refused, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	server.Insecure = true
+	if err := server.Start(ctx); err != nil {
+		t.Errorf("expected plain HTTP served when allowed, got %v", err)
+	}
+}
@@ -0,0 +1,460 @@
+// Package builder constructs ManagedJob workflows from code. The builders validate as they go
+// and Build returns the ready-to-apply object together with all the problems found.
+//
+//	mj, err := builder.Workflow("release").Namespace("ci").
+//		Group("build").Job("compile").Image("golang:1.21").Args("make", "build").
+//		Group("test").DependsOn("build").Job("unit").Image("golang:1.21").Args("make", "test").
+//		Build()
+package builder
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/controllers"
+	"raczylo.com/jobs-manager-operator/pkg/dependencies"
+)
+
+// maxNameLength mirrors the limit of the group and job names in the CRD
+const maxNameLength = 40
+
+// WorkflowBuilder builds a single ManagedJob, its groups and jobs are added with Group
+type WorkflowBuilder struct {
+	mj   *jobsmanagerv1beta1.ManagedJob
+	errs field.ErrorList
+	// explicit dependencies are resolved in Build, once all the groups and jobs are known
+	groupDependencies map[*jobsmanagerv1beta1.ManagedJobGroup][]string
+	jobDependencies   map[*jobsmanagerv1beta1.ManagedJobDefinition][]string
+}
+
+// GroupBuilder adds the settings and the jobs of a single group
+type GroupBuilder struct {
+	workflow *WorkflowBuilder
+	group    *jobsmanagerv1beta1.ManagedJobGroup
+	path     *field.Path
+}
+
+// JobBuilder adds the settings of a single job
+type JobBuilder struct {
+	group *GroupBuilder
+	job   *jobsmanagerv1beta1.ManagedJobDefinition
+	path  *field.Path
+}
+
+// Workflow starts the ManagedJob of the given name, retried once by default
+func Workflow(name string) *WorkflowBuilder {
+	w := &WorkflowBuilder{
+		mj: &jobsmanagerv1beta1.ManagedJob{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: jobsmanagerv1beta1.GroupVersion.String(),
+				Kind:       "ManagedJob",
+			},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       jobsmanagerv1beta1.ManagedJobSpec{Retries: 1},
+		},
+		groupDependencies: map[*jobsmanagerv1beta1.ManagedJobGroup][]string{},
+		jobDependencies:   map[*jobsmanagerv1beta1.ManagedJobDefinition][]string{},
+	}
+	for _, msg := range validation.IsDNS1123Subdomain(name) {
+		w.errs = append(w.errs, field.Invalid(field.NewPath("metadata", "name"), name, msg))
+	}
+	return w
+}
+
+// Namespace of the workflow
+func (w *WorkflowBuilder) Namespace(namespace string) *WorkflowBuilder {
+	w.mj.Namespace = namespace
+	return w
+}
+
+// Image of the jobs which set none themselves or through their group
+func (w *WorkflowBuilder) Image(image string) *WorkflowBuilder {
+	w.mj.Spec.Image = image
+	return w
+}
+
+// Description of the workflow, shown by the kubectl plugin
+func (w *WorkflowBuilder) Description(description string) *WorkflowBuilder {
+	w.mj.Spec.Description = description
+	return w
+}
+
+// Retries of the workflow, at least one
+func (w *WorkflowBuilder) Retries(retries int) *WorkflowBuilder {
+	if retries < 1 {
+		w.errs = append(w.errs, field.Invalid(field.NewPath("spec", "retries"), retries, "must be at least 1"))
+	}
+	w.mj.Spec.Retries = retries
+	return w
+}
+
+// Labels of the ManagedJob object, merged with the ones set before
+func (w *WorkflowBuilder) Labels(labels map[string]string) *WorkflowBuilder {
+	w.mj.Labels = mergeMap(w.mj.Labels, labels)
+	return w
+}
+
+// Annotations of the ManagedJob object, merged with the ones set before
+func (w *WorkflowBuilder) Annotations(annotations map[string]string) *WorkflowBuilder {
+	w.mj.Annotations = mergeMap(w.mj.Annotations, annotations)
+	return w
+}
+
+// Env adds the environment variable inherited by all the jobs
+func (w *WorkflowBuilder) Env(name, value string) *WorkflowBuilder {
+	w.mj.Spec.Params.Env = append(w.mj.Spec.Params.Env, corev1.EnvVar{Name: name, Value: value})
+	return w
+}
+
+// Params changes the parameters inherited by all the jobs
+func (w *WorkflowBuilder) Params(change func(*jobsmanagerv1beta1.ManagedJobParameters)) *WorkflowBuilder {
+	change(&w.mj.Spec.Params)
+	return w
+}
+
+// Group adds the next group of the workflow
+func (w *WorkflowBuilder) Group(name string) *GroupBuilder {
+	path := field.NewPath("spec", "groups").Index(len(w.mj.Spec.Groups))
+	w.checkName(path.Child("name"), name, "")
+	for _, group := range w.mj.Spec.Groups {
+		if group.Name == name {
+			w.errs = append(w.errs, field.Duplicate(path.Child("name"), name))
+		}
+	}
+	group := &jobsmanagerv1beta1.ManagedJobGroup{Name: name}
+	w.mj.Spec.Groups = append(w.mj.Spec.Groups, group)
+	return &GroupBuilder{workflow: w, group: group, path: path}
+}
+
+// Build resolves the dependencies and validates the whole workflow the same way as the webhook does
+func (w *WorkflowBuilder) Build() (*jobsmanagerv1beta1.ManagedJob, error) {
+	errs := append(field.ErrorList{}, w.errs...)
+	if len(w.mj.Spec.Groups) == 0 {
+		errs = append(errs, field.Required(field.NewPath("spec", "groups"), "at least one group is needed"))
+	}
+	for i, group := range w.mj.Spec.Groups {
+		groupPath := field.NewPath("spec", "groups").Index(i)
+		group.Dependencies = nil
+		for _, name := range w.groupDependencies[group] {
+			if w.group(name) == nil {
+				errs = append(errs, field.NotFound(groupPath.Child("dependencies"), name))
+				continue
+			}
+			group.Dependencies = append(group.Dependencies, dependency(name))
+		}
+		if len(group.Jobs) == 0 {
+			errs = append(errs, field.Required(groupPath.Child("jobs"), "at least one job is needed"))
+		}
+		for j, job := range group.Jobs {
+			jobPath := groupPath.Child("jobs").Index(j)
+			job.Dependencies = nil
+			for _, name := range w.jobDependencies[job] {
+				if !hasJob(group, name) {
+					errs = append(errs, field.NotFound(jobPath.Child("dependencies"), name))
+					continue
+				}
+				// jobs depend on the generated names of their siblings
+				job.Dependencies = append(job.Dependencies, dependency(dependencies.JobName(w.mj.Name, group.Name, name)))
+			}
+		}
+	}
+
+	mj := w.mj.DeepCopy()
+	all := []error{}
+	for _, err := range errs {
+		all = append(all, err)
+	}
+	if _, err := mj.ValidateCreate(); err != nil {
+		all = append(all, err)
+	}
+	// the cycles closed by the implicit dependencies are rejected by the webhook as well
+	for _, err := range dependencies.Validate(mj) {
+		all = append(all, err)
+	}
+	if len(all) > 0 {
+		return nil, utilerrors.NewAggregate(all)
+	}
+	return mj, nil
+}
+
+func (w *WorkflowBuilder) group(name string) *jobsmanagerv1beta1.ManagedJobGroup {
+	for _, group := range w.mj.Spec.Groups {
+		if group.Name == name {
+			return group
+		}
+	}
+	return nil
+}
+
+// checkName validates the name against the CRD pattern and the generated name of the child Job, if any
+func (w *WorkflowBuilder) checkName(path *field.Path, name string, generated string) {
+	if len(name) > maxNameLength {
+		w.errs = append(w.errs, field.TooLong(path, name, maxNameLength))
+		return
+	}
+	if msgs := validation.IsDNS1123Label(name); len(msgs) > 0 {
+		for _, msg := range msgs {
+			w.errs = append(w.errs, field.Invalid(path, name, msg))
+		}
+		return
+	}
+	if generated == "" {
+		return
+	}
+	for _, msg := range validation.IsDNS1123Label(generated) {
+		w.errs = append(w.errs, field.Invalid(path, name, fmt.Sprintf("generated job name %s: %s", generated, msg)))
+	}
+}
+
+func (w *WorkflowBuilder) synchronization(path *field.Path, name string, scope string) *jobsmanagerv1beta1.ManagedJobSynchronization {
+	for _, msg := range validation.IsDNS1123Label(name) {
+		w.errs = append(w.errs, field.Invalid(path.Child("mutex"), name, msg))
+	}
+	switch scope {
+	case jobsmanagerv1beta1.MutexScopeNamespace, jobsmanagerv1beta1.MutexScopeCluster:
+	default:
+		w.errs = append(w.errs, field.NotSupported(path.Child("scope"), scope,
+			[]string{jobsmanagerv1beta1.MutexScopeNamespace, jobsmanagerv1beta1.MutexScopeCluster}))
+	}
+	return &jobsmanagerv1beta1.ManagedJobSynchronization{Mutex: name, Scope: scope}
+}
+
+// Parallel lets the group run together with the previous one
+func (g *GroupBuilder) Parallel(parallel bool) *GroupBuilder {
+	g.group.Parallel = parallel
+	return g
+}
+
+// Ordering of the group, one of Serial, Parallel and ExplicitOnly
+func (g *GroupBuilder) Ordering(ordering string) *GroupBuilder {
+	switch ordering {
+	case controllers.GroupOrderingSerial, controllers.GroupOrderingParallel, controllers.GroupOrderingExplicitOnly:
+	default:
+		g.workflow.errs = append(g.workflow.errs, field.NotSupported(g.path.Child("ordering"), ordering,
+			[]string{controllers.GroupOrderingSerial, controllers.GroupOrderingParallel, controllers.GroupOrderingExplicitOnly}))
+	}
+	g.group.Ordering = ordering
+	return g
+}
+
+// Weight of the group, the heavier of the groups ready at the same time start first
+func (g *GroupBuilder) Weight(weight int32) *GroupBuilder {
+	g.group.Weight = weight
+	return g
+}
+
+// Retries of the group jobs which do not set their own, overrides the retries of the workflow
+func (g *GroupBuilder) Retries(retries int32) *GroupBuilder {
+	if retries < 0 {
+		g.workflow.errs = append(g.workflow.errs, field.Invalid(g.path.Child("retries"), retries, "must be at least 0"))
+	}
+	g.group.Retries = &retries
+	return g
+}
+
+// Mutex makes the group hold the mutex while it runs, the scope is Namespace or Cluster
+func (g *GroupBuilder) Mutex(name string, scope string) *GroupBuilder {
+	g.group.Synchronization = g.workflow.synchronization(g.path.Child("synchronization"), name, scope)
+	return g
+}
+
+// DependsOn adds the groups which have to succeed first, they may be added later
+func (g *GroupBuilder) DependsOn(groups ...string) *GroupBuilder {
+	g.workflow.groupDependencies[g.group] = append(g.workflow.groupDependencies[g.group], groups...)
+	return g
+}
+
+// Image of the group jobs which set none themselves
+func (g *GroupBuilder) Image(image string) *GroupBuilder {
+	g.group.Image = image
+	return g
+}
+
+// Description of the group, shown by the kubectl plugin
+func (g *GroupBuilder) Description(description string) *GroupBuilder {
+	g.group.Description = description
+	return g
+}
+
+// Env adds the environment variable inherited by the jobs of the group
+func (g *GroupBuilder) Env(name, value string) *GroupBuilder {
+	g.group.Params.Env = append(g.group.Params.Env, corev1.EnvVar{Name: name, Value: value})
+	return g
+}
+
+// Params changes the parameters inherited by the jobs of the group
+func (g *GroupBuilder) Params(change func(*jobsmanagerv1beta1.ManagedJobParameters)) *GroupBuilder {
+	change(&g.group.Params)
+	return g
+}
+
+// Job adds the next job of the group
+func (g *GroupBuilder) Job(name string) *JobBuilder {
+	path := g.path.Child("jobs").Index(len(g.group.Jobs))
+	g.workflow.checkName(path.Child("name"), name, dependencies.JobName(g.workflow.mj.Name, g.group.Name, name))
+	if hasJob(g.group, name) {
+		g.workflow.errs = append(g.workflow.errs, field.Duplicate(path.Child("name"), name))
+	}
+	job := &jobsmanagerv1beta1.ManagedJobDefinition{Name: name, Type: controllers.JobTypeContainer}
+	g.group.Jobs = append(g.group.Jobs, job)
+	return &JobBuilder{group: g, job: job, path: path}
+}
+
+// Group finishes the group and adds the next one
+func (g *GroupBuilder) Group(name string) *GroupBuilder {
+	return g.workflow.Group(name)
+}
+
+// Build finishes the group and builds the workflow
+func (g *GroupBuilder) Build() (*jobsmanagerv1beta1.ManagedJob, error) {
+	return g.workflow.Build()
+}
+
+// Image of the job container
+func (j *JobBuilder) Image(image string) *JobBuilder {
+	if strings.TrimSpace(image) == "" {
+		j.group.workflow.errs = append(j.group.workflow.errs, field.Required(j.path.Child("image"), ""))
+	}
+	j.job.Image = image
+	return j
+}
+
+// Args of the job container
+func (j *JobBuilder) Args(args ...string) *JobBuilder {
+	j.job.Args = append(j.job.Args, args...)
+	return j
+}
+
+// Script makes it the script job running the source with the interpreter, /bin/sh when empty
+func (j *JobBuilder) Script(source string, interpreter ...string) *JobBuilder {
+	if source == "" {
+		j.group.workflow.errs = append(j.group.workflow.errs, field.Required(j.path.Child("script", "source"), ""))
+	}
+	j.job.Type = controllers.JobTypeScript
+	j.job.Script = &jobsmanagerv1beta1.ManagedJobScript{Interpreter: interpreter, Source: source}
+	return j
+}
+
+// Manual makes it the step done by hand, the operator waits for its outcome
+func (j *JobBuilder) Manual() *JobBuilder {
+	j.job.Type = controllers.JobTypeManual
+	return j
+}
+
+// Parallel lets the job run together with the previous one
+func (j *JobBuilder) Parallel(parallel bool) *JobBuilder {
+	j.job.Parallel = parallel
+	return j
+}
+
+// Retries of the job, overrides the retries of its group and the workflow
+func (j *JobBuilder) Retries(retries int32) *JobBuilder {
+	if retries < 0 {
+		j.group.workflow.errs = append(j.group.workflow.errs, field.Invalid(j.path.Child("retries"), retries, "must be at least 0"))
+	}
+	j.job.Retries = &retries
+	return j
+}
+
+// Mutex makes the job hold the mutex while it runs, the scope is Namespace or Cluster
+func (j *JobBuilder) Mutex(name string, scope string) *JobBuilder {
+	j.job.Synchronization = j.group.workflow.synchronization(j.path.Child("synchronization"), name, scope)
+	return j
+}
+
+// DependsOn adds the jobs of the same group which have to succeed first, they may be added later
+func (j *JobBuilder) DependsOn(jobs ...string) *JobBuilder {
+	j.group.workflow.jobDependencies[j.job] = append(j.group.workflow.jobDependencies[j.job], jobs...)
+	return j
+}
+
+// Description of the job, shown by the kubectl plugin
+func (j *JobBuilder) Description(description string) *JobBuilder {
+	j.job.Description = description
+	return j
+}
+
+// Env adds the environment variable of the job
+func (j *JobBuilder) Env(name, value string) *JobBuilder {
+	j.job.Params.Env = append(j.job.Params.Env, corev1.EnvVar{Name: name, Value: value})
+	return j
+}
+
+// Requests sets the cpu and memory requests of the job, empty values are left out
+func (j *JobBuilder) Requests(cpu, memory string) *JobBuilder {
+	requests := corev1.ResourceList{}
+	values := []struct {
+		name  corev1.ResourceName
+		value string
+	}{{corev1.ResourceCPU, cpu}, {corev1.ResourceMemory, memory}}
+	for _, request := range values {
+		name, value := request.name, request.value
+		if value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			j.group.workflow.errs = append(j.group.workflow.errs,
+				field.Invalid(j.path.Child("params", "resources", "requests").Key(string(name)), value, err.Error()))
+			continue
+		}
+		requests[name] = quantity
+	}
+	if j.job.Params.Resources == nil {
+		j.job.Params.Resources = &corev1.ResourceRequirements{}
+	}
+	j.job.Params.Resources.Requests = requests
+	return j
+}
+
+// Params changes the parameters of the job
+func (j *JobBuilder) Params(change func(*jobsmanagerv1beta1.ManagedJobParameters)) *JobBuilder {
+	change(&j.job.Params)
+	return j
+}
+
+// Job finishes the job and adds the next one to the same group
+func (j *JobBuilder) Job(name string) *JobBuilder {
+	return j.group.Job(name)
+}
+
+// Group finishes the job and its group and adds the next group
+func (j *JobBuilder) Group(name string) *GroupBuilder {
+	return j.group.workflow.Group(name)
+}
+
+// Build finishes the job and builds the workflow
+func (j *JobBuilder) Build() (*jobsmanagerv1beta1.ManagedJob, error) {
+	return j.group.workflow.Build()
+}
+
+func hasJob(group *jobsmanagerv1beta1.ManagedJobGroup, name string) bool {
+	for _, job := range group.Jobs {
+		if job.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func dependency(name string) *jobsmanagerv1beta1.ManagedJobDependencies {
+	return &jobsmanagerv1beta1.ManagedJobDependencies{Name: name, Status: controllers.ExecutionStatusPending}
+}
+
+func mergeMap(dst, src map[string]string) map[string]string {
+	if dst == nil {
+		dst = map[string]string{}
+	}
+	for key, value := range src {
+		dst[key] = value
+	}
+	return dst
+}
@@ -0,0 +1,114 @@
+package builder
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestBuild(t *testing.T) {
+	mj, err := Workflow("release").Namespace("ci").Env("STAGE", "release").
+		Group("build").Image("golang:1.21").Retries(3).Job("compile").Args("make", "build").Requests("500m", "1Gi").Retries(0).
+		Job("lint").Parallel(true).
+		Job("package").Image("busybox").DependsOn("compile", "lint").
+		Group("test").DependsOn("build").Ordering("ExplicitOnly").Job("unit").Script("make test").Image("golang:1.21").
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if mj.Kind != "ManagedJob" || mj.APIVersion != "jobsmanager.raczylo.com/v1beta1" {
+		t.Errorf("type = %s %s", mj.APIVersion, mj.Kind)
+	}
+	if mj.Namespace != "ci" || mj.Spec.Retries != 1 || len(mj.Spec.Params.Env) != 1 {
+		t.Errorf("workflow = %s retries %d env %v", mj.Namespace, mj.Spec.Retries, mj.Spec.Params.Env)
+	}
+	if len(mj.Spec.Groups) != 2 || len(mj.Spec.Groups[0].Jobs) != 3 {
+		t.Fatalf("groups = %+v", mj.Spec.Groups)
+	}
+	build, test := mj.Spec.Groups[0], mj.Spec.Groups[1]
+	if *build.Retries != 3 || *build.Jobs[0].Retries != 0 || build.Jobs[1].Retries != nil {
+		t.Errorf("retries = %d %d %v", *build.Retries, *build.Jobs[0].Retries, build.Jobs[1].Retries)
+	}
+	if cpu := build.Jobs[0].Params.Resources.Requests.Cpu(); cpu.Cmp(resource.MustParse("500m")) != 0 {
+		t.Errorf("cpu request = %s", cpu)
+	}
+	deps := build.Jobs[2].Dependencies
+	if len(deps) != 2 || deps[0].Name != "release-build-compile" || deps[1].Name != "release-build-lint" {
+		t.Errorf("job dependencies = %+v", deps)
+	}
+	if len(test.Dependencies) != 1 || test.Dependencies[0].Name != "build" || test.Ordering != "ExplicitOnly" {
+		t.Errorf("group = %+v", test)
+	}
+	if unit := test.Jobs[0]; unit.Type != "script" || unit.Script.Source != "make test" {
+		t.Errorf("script job = %+v", unit)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func() error
+		want  []string
+	}{
+		{
+			name: "no groups",
+			build: func() error {
+				_, err := Workflow("empty").Build()
+				return err
+			},
+			want: []string{"spec.groups: Required value"},
+		},
+		{
+			name: "invalid names and duplicates",
+			build: func() error {
+				_, err := Workflow("wf").Group("Build").Job("a").Image("busybox").
+					Group("test").Job("a_b").Image("busybox").Job("c").Image("busybox").Job("c").Image("busybox").
+					Build()
+				return err
+			},
+			want: []string{"spec.groups[0].name: Invalid value: \"Build\"", "spec.groups[1].jobs[0].name: Invalid value: \"a_b\"", "spec.groups[1].jobs[2].name: Duplicate value: \"c\""},
+		},
+		{
+			name: "unknown dependencies",
+			build: func() error {
+				_, err := Workflow("wf").Group("build").DependsOn("missing").Job("a").Image("busybox").DependsOn("b").Build()
+				return err
+			},
+			want: []string{"spec.groups[0].dependencies: Not found: \"missing\"", "spec.groups[0].jobs[0].dependencies: Not found: \"b\""},
+		},
+		{
+			name: "missing image and bad requests",
+			build: func() error {
+				_, err := Workflow("wf").Group("build").Job("a").Requests("lots", "").Build()
+				return err
+			},
+			want: []string{"spec.groups[0].jobs[0].image: Required value", "spec.groups[0].jobs[0].params.resources.requests[cpu]: Invalid value: \"lots\""},
+		},
+		{
+			name: "webhook validation",
+			build: func() error {
+				_, err := Workflow("wf").Group("build").Job("a").Image("busybox").
+					Params(func(params *jobsmanagerv1beta1.ManagedJobParameters) { params.RestartPolicy = "Always" }).Build()
+				return err
+			},
+			want: []string{"spec.groups[0].jobs[0].params.restartPolicy: Unsupported value: \"Always\""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.build()
+			if err == nil {
+				t.Fatal("Build() expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Build() error = %v, want %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dashboard generates the Grafana dashboard of the operator metrics. The queries are built from
+// the metric names and labels the controller registers, a query using a label the metric doesn't have
+// fails the generation, so the dashboard can't drift from the metrics.
+package dashboard
+
+import (
+	"fmt"
+	"strings"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"raczylo.com/jobs-manager-operator/controllers"
+)
+
+const (
+	UID   = "jobs-manager-operator"
+	Title = "Jobs Manager Operator"
+
+	schemaVersion  = 38
+	panelWidth     = 12
+	panelHeight    = 8
+	namespaceMatch = `namespace=~"$namespace"`
+	// runs are rare, their rates are taken over a day
+	runsWindow = "1d"
+)
+
+type Dashboard struct {
+	UID           string     `json:"uid"`
+	Title         string     `json:"title"`
+	Tags          []string   `json:"tags"`
+	Editable      bool       `json:"editable"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Refresh       string     `json:"refresh"`
+	Time          TimeRange  `json:"time"`
+	Templating    Templating `json:"templating"`
+	Panels        []Panel    `json:"panels"`
+}
+
+type TimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type Templating struct {
+	List []Variable `json:"list"`
+}
+
+type Variable struct {
+	Name       string      `json:"name"`
+	Label      string      `json:"label"`
+	Type       string      `json:"type"`
+	Query      string      `json:"query"`
+	Datasource *Datasource `json:"datasource,omitempty"`
+	Refresh    int         `json:"refresh,omitempty"`
+	IncludeAll bool        `json:"includeAll,omitempty"`
+	Multi      bool        `json:"multi,omitempty"`
+	AllValue   string      `json:"allValue,omitempty"`
+}
+
+type Datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type Panel struct {
+	ID          int         `json:"id"`
+	Type        string      `json:"type"`
+	Title       string      `json:"title"`
+	Description string      `json:"description,omitempty"`
+	GridPos     GridPos     `json:"gridPos"`
+	Datasource  Datasource  `json:"datasource"`
+	FieldConfig FieldConfig `json:"fieldConfig"`
+	Targets     []Target    `json:"targets"`
+}
+
+type GridPos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type FieldConfig struct {
+	Defaults  FieldDefaults `json:"defaults"`
+	Overrides []interface{} `json:"overrides"`
+}
+
+type FieldDefaults struct {
+	Unit string `json:"unit,omitempty"`
+}
+
+type Target struct {
+	RefID        string     `json:"refId"`
+	Expr         string     `json:"expr"`
+	LegendFormat string     `json:"legendFormat,omitempty"`
+	Datasource   Datasource `json:"datasource"`
+}
+
+// prometheus is the datasource picked by the variable of the dashboard
+var prometheus = Datasource{Type: "prometheus", UID: "${datasource}"}
+
+// builder lays out the panels and checks the metrics and labels of their queries
+type builder struct {
+	panels []Panel
+	errs   []error
+}
+
+// series returns the selector of the metric, the suffix selects the series of the histograms
+func (b *builder) series(metric string, suffix string, matchers ...string) string {
+	for _, matcher := range matchers {
+		b.labels(metric, strings.SplitN(matcher, "=", 2)[0])
+	}
+	if len(matchers) == 0 {
+		return metric + suffix
+	}
+	return metric + suffix + "{" + strings.Join(matchers, ",") + "}"
+}
+
+// labels returns the labels joined for the by clause, the histograms are aggregated by le as well
+func (b *builder) labels(metric string, labels ...string) string {
+	known, found := controllers.MetricLabels[metric]
+	if !found {
+		b.errs = append(b.errs, fmt.Errorf("metric %s is not exported by the operator", metric))
+		return strings.Join(labels, ", ")
+	}
+	for _, label := range labels {
+		if label == "le" {
+			continue
+		}
+		exists := false
+		for _, knownLabel := range known {
+			exists = exists || knownLabel == label
+		}
+		if !exists {
+			b.errs = append(b.errs, fmt.Errorf("metric %s has no label %s", metric, label))
+		}
+	}
+	return strings.Join(labels, ", ")
+}
+
+func (b *builder) panel(panelType string, title string, description string, unit string, targets ...Target) {
+	i := len(b.panels)
+	for j := range targets {
+		targets[j].RefID = string(rune('A' + j))
+		targets[j].Datasource = prometheus
+	}
+	b.panels = append(b.panels, Panel{
+		ID:          i + 1,
+		Type:        panelType,
+		Title:       title,
+		Description: description,
+		GridPos:     GridPos{X: (i % 2) * panelWidth, Y: (i / 2) * panelHeight, W: panelWidth, H: panelHeight},
+		Datasource:  prometheus,
+		FieldConfig: FieldConfig{Defaults: FieldDefaults{Unit: unit}, Overrides: []interface{}{}},
+		Targets:     targets,
+	})
+}
+
+// Grafana returns the dashboard of the operator metrics filtered by the namespace variable
+func Grafana() (Dashboard, error) {
+	b := &builder{}
+	workflows, activeJobs, queueDepth := controllers.MetricWorkflows, controllers.MetricActiveJobs, controllers.MetricQueueDepth
+	runs, durations := controllers.MetricWorkflowRuns, controllers.MetricWorkflowRunDuration
+	cost, resources := controllers.MetricEstimatedCost, controllers.MetricRequestedResources
+	reconcileErrors, apiCalls := controllers.MetricReconcileErrors, controllers.MetricReconcileAPICalls
+	scheduling, throttled := controllers.MetricPodSchedulingLatency, controllers.MetricAPIThrottled
+
+	b.panel("timeseries", "Workflows by phase", "ManagedJobs per phase", "short", Target{
+		Expr:         fmt.Sprintf("sum by (%s) (%s)", b.labels(workflows, "phase"), b.series(workflows, "", namespaceMatch)),
+		LegendFormat: "{{phase}}",
+	})
+	b.panel("timeseries", "Active jobs", "Jobs of the workflows with running pods", "short", Target{
+		Expr:         fmt.Sprintf("sum by (%s) (%s)", b.labels(activeJobs, "namespace"), b.series(activeJobs, "", namespaceMatch)),
+		LegendFormat: "{{namespace}}",
+	})
+	b.panel("timeseries", "Failure rate", "Share of the failed runs of the workflow over the last day", "percentunit", Target{
+		Expr: fmt.Sprintf("sum by (%s) (increase(%s[%s])) / sum by (%s) (increase(%s[%s]))",
+			b.labels(runs, "workflow"), b.series(runs, "", namespaceMatch, `status="failed"`), runsWindow,
+			b.labels(runs, "workflow"), b.series(runs, "", namespaceMatch), runsWindow),
+		LegendFormat: "{{workflow}}",
+	})
+	b.panel("timeseries", "Run duration (p95)", "95th percentile of the duration of the succeeded runs over the last day", "s", Target{
+		Expr: fmt.Sprintf("histogram_quantile(0.95, sum by (%s) (rate(%s[%s])))",
+			b.labels(durations, "workflow", "le"), b.series(durations, "_bucket", namespaceMatch, `status="succeeded"`), runsWindow),
+		LegendFormat: "{{workflow}}",
+	})
+	b.panel("timeseries", "Finished runs", "Runs finished in the last hour per status", "short", Target{
+		Expr:         fmt.Sprintf("sum by (%s) (increase(%s[1h]))", b.labels(runs, "status"), b.series(runs, "", namespaceMatch)),
+		LegendFormat: "{{status}}",
+	})
+	b.panel("timeseries", "Queue depth", "Workflows queued by --max-active-workflows-per-namespace", "short", Target{
+		Expr:         fmt.Sprintf("sum by (%s) (%s)", b.labels(queueDepth, "namespace"), b.series(queueDepth, "", namespaceMatch)),
+		LegendFormat: "{{namespace}}",
+	})
+	b.panel("timeseries", "Pod scheduling latency (p95)", "95th percentile of the time from the creation of the Job to its first running pod", "s", Target{
+		Expr: fmt.Sprintf("histogram_quantile(0.95, sum by (%s) (rate(%s[1h])))",
+			b.labels(scheduling, "workflow", "le"), b.series(scheduling, "_bucket", namespaceMatch)),
+		LegendFormat: "{{workflow}}",
+	})
+	b.panel("timeseries", "Requested CPU of the running jobs", "CPU requests of the running jobs per workflow", "short", Target{
+		Expr: fmt.Sprintf("sum by (%s) (%s)", b.labels(resources, "workflow"),
+			b.series(resources, "", namespaceMatch, `resource="cpu"`, `scope="active"`)),
+		LegendFormat: "{{workflow}}",
+	})
+	b.panel("timeseries", "Estimated cost by cost center", "Approximate cost of the last runs of the jobs", "short", Target{
+		Expr:         fmt.Sprintf("sum by (%s) (%s)", b.labels(cost, "cost_center"), b.series(cost, "", namespaceMatch)),
+		LegendFormat: "{{cost_center}}",
+	})
+	b.panel("timeseries", "Reconcile errors", "Consecutive failed reconciles of the workflows", "short", Target{
+		Expr:         fmt.Sprintf("max by (%s) (%s) > 0", b.labels(reconcileErrors, "workflow"), b.series(reconcileErrors, "", namespaceMatch)),
+		LegendFormat: "{{workflow}}",
+	})
+	b.panel("timeseries", "API calls per reconcile (p99)", "Calls made by a single reconcile of the operator, all namespaces", "short", Target{
+		Expr:         fmt.Sprintf("histogram_quantile(0.99, sum by (%s) (rate(%s[5m])))", b.labels(apiCalls, "verb", "le"), b.series(apiCalls, "_bucket")),
+		LegendFormat: "{{verb}}",
+	})
+	b.panel("timeseries", "API server backoffs", "Responses of the API server which made the operator back off per minute, all namespaces", "short", Target{
+		Expr:         fmt.Sprintf("sum by (%s) (rate(%s[5m])) * 60", b.labels(throttled, "kind"), b.series(throttled, "")),
+		LegendFormat: "{{kind}}",
+	})
+	namespaces := fmt.Sprintf("label_values(%s, %s)", workflows, b.labels(workflows, "namespace"))
+
+	if len(b.errs) > 0 {
+		return Dashboard{}, utilerrors.NewAggregate(b.errs)
+	}
+	return Dashboard{
+		UID:           UID,
+		Title:         Title,
+		Tags:          []string{"kubernetes", "jobs-manager-operator"},
+		Editable:      true,
+		SchemaVersion: schemaVersion,
+		Refresh:       "1m",
+		Time:          TimeRange{From: "now-24h", To: "now"},
+		Templating: Templating{List: []Variable{
+			{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+			{
+				Name: "namespace", Label: "Namespace", Type: "query", Datasource: &prometheus, Refresh: 2,
+				Query:      namespaces,
+				IncludeAll: true, Multi: true, AllValue: ".*",
+			},
+		}},
+		Panels: b.panels,
+	}, nil
+}
@@ -0,0 +1,46 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"raczylo.com/jobs-manager-operator/controllers"
+)
+
+func TestGrafana(t *testing.T) {
+	dashboard, err := Grafana()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := json.Marshal(dashboard); err != nil {
+		t.Fatal(err)
+	}
+	queried := map[string]bool{}
+	for i, panel := range dashboard.Panels {
+		if panel.ID != i+1 || panel.GridPos.W != panelWidth || len(panel.Targets) == 0 {
+			t.Errorf("unexpected panel %+v", panel)
+		}
+		for _, target := range panel.Targets {
+			for metric := range controllers.MetricLabels {
+				if strings.Contains(target.Expr, metric) {
+					queried[metric] = true
+				}
+			}
+		}
+	}
+	for _, metric := range []string{controllers.MetricWorkflowRuns, controllers.MetricWorkflowRunDuration, controllers.MetricActiveJobs, controllers.MetricQueueDepth} {
+		if !queried[metric] {
+			t.Errorf("expected %s on the dashboard", metric)
+		}
+	}
+}
+
+func TestBuilderRejectsUnknownLabels(t *testing.T) {
+	b := &builder{}
+	b.series(controllers.MetricQueueDepth, "", `workflow="nightly"`)
+	b.labels("managedjob_unknown", "namespace")
+	if len(b.errs) != 2 {
+		t.Errorf("expected the unknown label and metric rejected, got %v", b.errs)
+	}
+}
@@ -0,0 +1,294 @@
+// Package dependencies builds the dependency graph of a workflow exactly like the controller does,
+// so the controller, the admission webhook, the lint command and the visualization agree on it.
+package dependencies
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+// Orderings of the group, see ManagedJobGroup.Ordering
+const (
+	OrderingSerial       = "Serial"
+	OrderingParallel     = "Parallel"
+	OrderingExplicitOnly = "ExplicitOnly"
+)
+
+// Kinds of the nodes of the graph, see Graph
+const (
+	NodeKindGroup = "Group"
+	NodeKindJob   = "Job"
+)
+
+// statusPending is the status of the added dependencies, the controller refreshes it on the next propagation
+const statusPending = "pending"
+
+// JobName is the name of the Job created by the controller for the job, the job dependencies point to it
+func JobName(workflowName string, groupName string, jobName string) string {
+	return strings.ToLower(strings.Join([]string{workflowName, groupName, jobName}, "-"))
+}
+
+// groupWaitsForPreviousGroups decides on the implicit dependencies of the group, ordering supersedes the parallel flag
+func groupWaitsForPreviousGroups(group *jobsmanagerv1beta1.ManagedJobGroup) bool {
+	switch group.Ordering {
+	case OrderingSerial, OrderingParallel:
+		return true
+	case OrderingExplicitOnly:
+		return false
+	}
+	return !group.Parallel
+}
+
+// JobWaitsForPreviousJobs decides on the implicit dependencies of the job, ordering of the group supersedes the parallel flag of the job
+func JobWaitsForPreviousJobs(group *jobsmanagerv1beta1.ManagedJobGroup, job *jobsmanagerv1beta1.ManagedJobDefinition) bool {
+	switch group.Ordering {
+	case OrderingSerial:
+		return true
+	case OrderingParallel, OrderingExplicitOnly:
+		return false
+	}
+	return !job.Parallel
+}
+
+// addDependency appends the dependency unless it's already there, reporting if it was added
+func addDependency(dependencies *[]*jobsmanagerv1beta1.ManagedJobDependencies, name string) bool {
+	for _, dependency := range *dependencies {
+		if dependency != nil && dependency.Name == name {
+			return false
+		}
+	}
+	*dependencies = append(*dependencies, &jobsmanagerv1beta1.ManagedJobDependencies{Name: name, Status: statusPending})
+	return true
+}
+
+// Resolve adds the implicit dependencies of the partitioned, sequential groups and jobs to the spec,
+// reporting if any was added. Dependencies already there are kept, resolving again changes nothing.
+func Resolve(workflowName string, spec *jobsmanagerv1beta1.ManagedJobSpec) bool {
+	changed := false
+	for groupIndex, group := range spec.Groups {
+		for jobIndex, job := range group.Jobs {
+			if group.PartitionSize > 0 {
+				// jobs of the partition depend on all the jobs of the previous partition
+				partitionStart := jobIndex - jobIndex%group.PartitionSize
+				previousPartitionStart := partitionStart - group.PartitionSize
+				if previousPartitionStart < 0 {
+					previousPartitionStart = 0
+				}
+				for _, previousJob := range group.Jobs[previousPartitionStart:partitionStart] {
+					changed = addDependency(&job.Dependencies, JobName(workflowName, group.Name, previousJob.Name)) || changed
+				}
+				continue
+			}
+			if !JobWaitsForPreviousJobs(group, job) {
+				continue
+			}
+			// the jobs declared before, up to the first one of the same name
+			for _, previousJob := range group.Jobs[:jobIndex] {
+				if previousJob.Name == job.Name {
+					break
+				}
+				changed = addDependency(&job.Dependencies, JobName(workflowName, group.Name, previousJob.Name)) || changed
+			}
+		}
+		if !groupWaitsForPreviousGroups(group) {
+			continue
+		}
+		for _, previousGroup := range spec.Groups[:groupIndex] {
+			if previousGroup.Name == group.Name {
+				break
+			}
+			changed = addDependency(&group.Dependencies, previousGroup.Name) || changed
+		}
+	}
+	return changed
+}
+
+// topologicalOrder sorts the nodes so every node comes after its dependencies, of the nodes which
+// are ready at the same time the heavier come first and the ones of the same weight keep the declaration order.
+// Nodes being part of a cycle are appended in the declaration order and reported with the error.
+func topologicalOrder(names []string, dependencies map[string][]string, weights map[string]int32) ([]string, error) {
+	known := map[string]bool{}
+	for _, name := range names {
+		known[name] = true
+	}
+	remaining := map[string]int{}
+	dependents := map[string][]string{}
+	for _, name := range names {
+		for _, dependency := range dependencies[name] {
+			if !known[dependency] {
+				continue // dependencies outside of the sorted set do not block
+			}
+			remaining[name]++
+			dependents[dependency] = append(dependents[dependency], name)
+		}
+	}
+
+	order := []string{}
+	done := map[string]bool{}
+	for len(order) < len(names) {
+		next, ready := "", false
+		for _, name := range names {
+			if done[name] || remaining[name] > 0 {
+				continue
+			}
+			// the first of the heaviest keeps the declaration order among the ready nodes
+			if !ready || weights[name] > weights[next] {
+				next, ready = name, true
+			}
+		}
+		if !ready {
+			cycle := []string{}
+			for _, name := range names {
+				if !done[name] {
+					cycle = append(cycle, name)
+					order = append(order, name)
+				}
+			}
+			return order, fmt.Errorf("dependency cycle between %v", cycle)
+		}
+		done[next] = true
+		order = append(order, next)
+		for _, dependent := range dependents[next] {
+			remaining[dependent]--
+		}
+	}
+	return order, nil
+}
+
+func dependencyNames(dependencies []*jobsmanagerv1beta1.ManagedJobDependencies) []string {
+	names := []string{}
+	for _, dependency := range dependencies {
+		names = append(names, dependency.Name)
+	}
+	return names
+}
+
+// OrderedGroups returns the groups of the workflow in the topological order, the heavier of the groups
+// ready at the same time first. The groups of a cycle come last in the declaration order.
+func OrderedGroups(spec *jobsmanagerv1beta1.ManagedJobSpec) ([]*jobsmanagerv1beta1.ManagedJobGroup, error) {
+	names := []string{}
+	dependencies := map[string][]string{}
+	weights := map[string]int32{}
+	groupsByName := map[string]*jobsmanagerv1beta1.ManagedJobGroup{}
+	for _, group := range spec.Groups {
+		names = append(names, group.Name)
+		dependencies[group.Name] = dependencyNames(group.Dependencies)
+		weights[group.Name] = group.Weight
+		groupsByName[group.Name] = group
+	}
+	order, err := topologicalOrder(names, dependencies, weights)
+	groups := []*jobsmanagerv1beta1.ManagedJobGroup{}
+	for _, name := range order {
+		groups = append(groups, groupsByName[name])
+	}
+	return groups, err
+}
+
+// OrderedJobs returns the jobs of the group in the topological order, the jobs of a cycle
+// come last in the declaration order
+func OrderedJobs(workflowName string, group *jobsmanagerv1beta1.ManagedJobGroup) ([]*jobsmanagerv1beta1.ManagedJobDefinition, error) {
+	names := []string{}
+	dependencies := map[string][]string{}
+	jobsByName := map[string]*jobsmanagerv1beta1.ManagedJobDefinition{}
+	for _, job := range group.Jobs {
+		generatedJobName := JobName(workflowName, group.Name, job.Name)
+		names = append(names, generatedJobName)
+		dependencies[generatedJobName] = dependencyNames(job.Dependencies)
+		jobsByName[generatedJobName] = job
+	}
+	order, err := topologicalOrder(names, dependencies, nil)
+	jobs := []*jobsmanagerv1beta1.ManagedJobDefinition{}
+	for _, name := range order {
+		jobs = append(jobs, jobsByName[name])
+	}
+	return jobs, err
+}
+
+// Cycles reports the dependencies which can never be met, between the groups of the workflow
+// and between the jobs of every group
+func Cycles(workflowName string, spec *jobsmanagerv1beta1.ManagedJobSpec) field.ErrorList {
+	errs := field.ErrorList{}
+	if _, err := OrderedGroups(spec); err != nil {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "groups"), err.Error()))
+	}
+	for i, group := range spec.Groups {
+		if _, err := OrderedJobs(workflowName, group); err != nil {
+			errs = append(errs, field.Forbidden(field.NewPath("spec", "groups").Index(i).Child("jobs"), err.Error()))
+		}
+	}
+	return errs
+}
+
+// Validate reports the dependencies pointing nowhere, they would never be met, and the cycles of the
+// workflow once its implicit dependencies are resolved. The workflow itself is left untouched.
+func Validate(mj *jobsmanagerv1beta1.ManagedJob) field.ErrorList {
+	resolved := mj.DeepCopy()
+	Resolve(resolved.Name, &resolved.Spec)
+
+	known := map[string]bool{}
+	nodes := 0
+	for _, group := range resolved.Spec.Groups {
+		known[group.Name] = true
+		nodes++
+		for _, job := range group.Jobs {
+			known[JobName(resolved.Name, group.Name, job.Name)] = true
+			nodes++
+		}
+	}
+	errs := field.ErrorList{}
+	unknown := func(path *field.Path, dependencies []*jobsmanagerv1beta1.ManagedJobDependencies) {
+		for k, dependency := range dependencies {
+			if dependency != nil && !known[dependency.Name] {
+				errs = append(errs, field.NotFound(path.Index(k).Child("name"), dependency.Name))
+			}
+		}
+	}
+	for i, group := range resolved.Spec.Groups {
+		groupPath := field.NewPath("spec", "groups").Index(i)
+		unknown(groupPath.Child("dependencies"), group.Dependencies)
+		for j, job := range group.Jobs {
+			unknown(groupPath.Child("jobs").Index(j).Child("dependencies"), job.Dependencies)
+		}
+	}
+	// the order of the duplicates is ambiguous and cycles through the unknown dependencies are not reported twice
+	if len(known) < nodes || len(errs) > 0 {
+		return errs
+	}
+	return Cycles(resolved.Name, &resolved.Spec)
+}
+
+// Graph returns the resolved dependency graph of the workflow as an adjacency list - the groups and their jobs
+// in the topological order, every node with the nodes it depends on. The spec itself is left untouched.
+func Graph(workflowName string, spec *jobsmanagerv1beta1.ManagedJobSpec) []jobsmanagerv1beta1.ManagedJobGraphNode {
+	resolved := spec.DeepCopy()
+	Resolve(workflowName, resolved)
+
+	nodes := []jobsmanagerv1beta1.ManagedJobGraphNode{}
+	groups, _ := OrderedGroups(resolved)
+	for _, group := range groups {
+		nodes = append(nodes, jobsmanagerv1beta1.ManagedJobGraphNode{Name: group.Name, Kind: NodeKindGroup, DependsOn: graphEdges(group.Dependencies)})
+		jobs, _ := OrderedJobs(workflowName, group)
+		for _, job := range jobs {
+			nodes = append(nodes, jobsmanagerv1beta1.ManagedJobGraphNode{
+				Name:      JobName(workflowName, group.Name, job.Name),
+				Kind:      NodeKindJob,
+				Group:     group.Name,
+				Job:       job.Name,
+				DependsOn: graphEdges(job.Dependencies),
+			})
+		}
+	}
+	return nodes
+}
+
+// graphEdges are the names of the dependencies, nil without any to keep them out of the serialized graph
+func graphEdges(dependencies []*jobsmanagerv1beta1.ManagedJobDependencies) []string {
+	if len(dependencies) == 0 {
+		return nil
+	}
+	return dependencyNames(dependencies)
+}
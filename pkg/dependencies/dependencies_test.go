@@ -0,0 +1,140 @@
+package dependencies
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func names(dependencies []*jobsmanagerv1beta1.ManagedJobDependencies) []string {
+	result := []string{}
+	for _, dependency := range dependencies {
+		result = append(result, dependency.Name)
+	}
+	return result
+}
+
+func TestResolve(t *testing.T) {
+	spec := &jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+		{Name: "extract", Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "a"}, {Name: "b", Parallel: true}, {Name: "c"}}},
+		{Name: "load", Ordering: OrderingParallel, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "a"}, {Name: "b"}}},
+		{Name: "notify", Ordering: OrderingExplicitOnly, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "a"}}},
+		{Name: "report", Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "a", Dependencies: []*jobsmanagerv1beta1.ManagedJobDependencies{{Name: "nightly-load-b"}}}}},
+	}}
+	if !Resolve("Nightly", spec) {
+		t.Fatal("expected the implicit dependencies added")
+	}
+	resolved := spec.DeepCopy()
+	if Resolve("Nightly", spec) || !reflect.DeepEqual(resolved, spec) {
+		t.Fatal("expected the second resolve to change nothing")
+	}
+
+	expected := map[string][]string{
+		"extract/b": {},
+		"extract/c": {"nightly-extract-a", "nightly-extract-b"},
+		"load/b":    {},
+		"report/a":  {"nightly-load-b"},
+	}
+	for _, group := range spec.Groups {
+		for _, job := range group.Jobs {
+			if want, found := expected[group.Name+"/"+job.Name]; found && !reflect.DeepEqual(names(job.Dependencies), want) {
+				t.Errorf("%s/%s depends on %v, expected %v", group.Name, job.Name, names(job.Dependencies), want)
+			}
+		}
+	}
+	groups := map[string][]string{
+		"extract": {},
+		"load":    {"extract"},
+		"notify":  {},
+		"report":  {"extract", "load", "notify"},
+	}
+	for _, group := range spec.Groups {
+		if got := names(group.Dependencies); !reflect.DeepEqual(got, groups[group.Name]) {
+			t.Errorf("group %s depends on %v, expected %v", group.Name, got, groups[group.Name])
+		}
+	}
+}
+
+func TestTopologicalOrderCycle(t *testing.T) {
+	order, err := topologicalOrder([]string{"a", "b", "c"}, map[string][]string{"a": {"b"}, "b": {"a"}}, nil)
+	if err == nil {
+		t.Fatal("expected the cycle to be reported")
+	}
+	if len(order) != 3 || order[0] != "c" {
+		t.Errorf("expected independent node first and all nodes returned, got %v", order)
+	}
+}
+
+func TestOrderedGroupsWeight(t *testing.T) {
+	spec := &jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+		{Name: "reports"},
+		{Name: "invoices"},
+		{Name: "deploy", Weight: 10},
+		{Name: "verify", Weight: 20, Dependencies: []*jobsmanagerv1beta1.ManagedJobDependencies{{Name: "deploy"}}},
+		{Name: "cleanup", Weight: -1},
+		{Name: "notify", Weight: 10},
+	}}
+	groups, err := OrderedGroups(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, group := range groups {
+		got = append(got, group.Name)
+	}
+	// the dependents come after their dependencies whatever their weight
+	expected := []string{"deploy", "verify", "notify", "reports", "invoices", "cleanup"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("order = %v, expected %v", got, expected)
+	}
+}
+
+// the explicit dependency on a later group closes a cycle with the implicit one of the sequential group
+func TestValidateImplicitCycle(t *testing.T) {
+	mj := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly"},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+			{Name: "extract", Dependencies: []*jobsmanagerv1beta1.ManagedJobDependencies{{Name: "load"}}, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "a"}}},
+			{Name: "load", Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "a"}}},
+		}},
+	}
+	original := mj.DeepCopy()
+	errs := Validate(mj)
+	if len(errs) != 1 || errs[0].Field != "spec.groups" {
+		t.Errorf("expected the cycle of the groups reported, got %v", errs)
+	}
+	if !reflect.DeepEqual(original, mj) {
+		t.Error("expected the workflow left untouched")
+	}
+
+	mj.Spec.Groups[0].Dependencies[0].Name = "transform"
+	if errs := Validate(mj); len(errs) != 1 || errs[0].Field != "spec.groups[0].dependencies[0].name" {
+		t.Errorf("expected the unknown dependency reported, got %v", errs)
+	}
+}
+
+func TestGraph(t *testing.T) {
+	spec := &jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+		{Name: "load", Dependencies: []*jobsmanagerv1beta1.ManagedJobDependencies{{Name: "extract"}}, Ordering: OrderingExplicitOnly, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "a"}}},
+		{Name: "extract", Ordering: OrderingExplicitOnly, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "a"}, {Name: "b"}}},
+		{Name: "report", Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "a"}}},
+	}}
+	original := spec.DeepCopy()
+	expected := []jobsmanagerv1beta1.ManagedJobGraphNode{
+		{Name: "extract", Kind: NodeKindGroup},
+		{Name: "nightly-extract-a", Kind: NodeKindJob, Group: "extract", Job: "a"},
+		{Name: "nightly-extract-b", Kind: NodeKindJob, Group: "extract", Job: "b"},
+		{Name: "load", Kind: NodeKindGroup, DependsOn: []string{"extract"}},
+		{Name: "nightly-load-a", Kind: NodeKindJob, Group: "load", Job: "a"},
+		{Name: "report", Kind: NodeKindGroup, DependsOn: []string{"load", "extract"}},
+		{Name: "nightly-report-a", Kind: NodeKindJob, Group: "report", Job: "a"},
+	}
+	if graph := Graph("nightly", spec); !reflect.DeepEqual(graph, expected) {
+		t.Errorf("unexpected graph %+v", graph)
+	}
+	if !reflect.DeepEqual(original, spec) {
+		t.Error("expected the spec left untouched")
+	}
+}
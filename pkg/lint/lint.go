@@ -0,0 +1,242 @@
+// Package lint validates the ManagedJob manifests offline - the CRD schema, everything the admission
+// webhook checks and the problems the controller would only find after the workflow started.
+package lint
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+	"sigs.k8s.io/yaml"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/pkg/dependencies"
+)
+
+// crdManifest is the copy of config/crd/bases, kept in sync by `make manifests`
+//
+//go:embed managedjobs.yaml
+var crdManifest []byte
+
+var (
+	// imageReference follows the grammar of the container image references: [registry/]path[:tag][@digest]
+	imageReference = regexp.MustCompile(`^` +
+		`(?:(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*(?::[0-9]+)?|\[[a-fA-F0-9:]+\](?::[0-9]+)?)/)?` +
+		`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*` +
+		`(?::[\w][\w.-]{0,127})?` +
+		`(?:@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,})?$`)
+	// envReference matches the $(NAME) expanded by Kubernetes in the args, $$(NAME) is the escaped one
+	envReference = regexp.MustCompile(`\$+\(([A-Za-z_][A-Za-z0-9_]*)\)`)
+)
+
+// Result lists the problems of a single workflow, the workflow is valid when there are no errors
+type Result struct {
+	Name     string
+	Errors   []error
+	Warnings []string
+}
+
+// Workflow lints the ManagedJob object decoded from the manifest
+func Workflow(object map[string]interface{}) Result {
+	result := Result{}
+	if metadata, ok := object["metadata"].(map[string]interface{}); ok {
+		result.Name, _ = metadata["name"].(string)
+	}
+
+	schema, err := managedJobSchema()
+	if err != nil {
+		result.Errors = append(result.Errors, err)
+		return result
+	}
+	// the API server defaults the object before validating it
+	data, err := json.Marshal(object)
+	if err != nil {
+		result.Errors = append(result.Errors, err)
+		return result
+	}
+	defaulted := map[string]interface{}{}
+	if err := json.Unmarshal(data, &defaulted); err != nil {
+		result.Errors = append(result.Errors, err)
+		return result
+	}
+	applyDefaults(schema, defaulted)
+	schemaErrors := validate.NewSchemaValidator(schema, nil, "", strfmt.Default).Validate(defaulted).Errors
+	sort.Slice(schemaErrors, func(i, j int) bool { return schemaErrors[i].Error() < schemaErrors[j].Error() })
+	result.Errors = append(result.Errors, schemaErrors...)
+
+	// unknown fields are dropped by the API server, they are most likely typos
+	mj := &jobsmanagerv1beta1.ManagedJob{}
+	if err := yaml.UnmarshalStrict(data, mj); err != nil {
+		result.Errors = append(result.Errors, err)
+		if err := yaml.Unmarshal(data, mj); err != nil {
+			return result
+		}
+	}
+
+	errs := append(mj.ValidateSize(), mj.Validate()...)
+	errs = append(errs, checkNames(mj)...)
+	errs = append(errs, checkImages(mj)...)
+	// the dependency graph is checked by the webhook as well, see pkg/dependencies
+	errs = append(errs, dependencies.Validate(mj)...)
+	for _, err := range errs {
+		result.Errors = append(result.Errors, err)
+	}
+	warnings, _ := mj.ValidateCreate()
+	result.Warnings = append(result.Warnings, warnings...)
+	result.Warnings = append(result.Warnings, checkEnvReferences(mj)...)
+	return result
+}
+
+func managedJobSchema() (*spec.Schema, error) {
+	crd := struct {
+		Spec struct {
+			Versions []struct {
+				Name   string `json:"name"`
+				Schema struct {
+					OpenAPIV3Schema *spec.Schema `json:"openAPIV3Schema"`
+				} `json:"schema"`
+			} `json:"versions"`
+		} `json:"spec"`
+	}{}
+	if err := yaml.Unmarshal(crdManifest, &crd); err != nil {
+		return nil, fmt.Errorf("unable to parse the CRD: %w", err)
+	}
+	for _, version := range crd.Spec.Versions {
+		if version.Name == jobsmanagerv1beta1.GroupVersion.Version && version.Schema.OpenAPIV3Schema != nil {
+			return version.Schema.OpenAPIV3Schema, nil
+		}
+	}
+	return nil, fmt.Errorf("CRD has no schema of %s", jobsmanagerv1beta1.GroupVersion.Version)
+}
+
+// applyDefaults sets the defaults of the missing properties, the way the API server does
+func applyDefaults(schema *spec.Schema, data interface{}) {
+	switch value := data.(type) {
+	case map[string]interface{}:
+		for name, property := range schema.Properties {
+			property := property
+			if _, found := value[name]; !found && property.Default != nil {
+				value[name] = property.Default
+			}
+			if nested, found := value[name]; found {
+				applyDefaults(&property, nested)
+			}
+		}
+	case []interface{}:
+		if schema.Items == nil || schema.Items.Schema == nil {
+			return
+		}
+		for _, item := range value {
+			applyDefaults(schema.Items.Schema, item)
+		}
+	}
+}
+
+// checkNames reports the duplicates and the names making the generated Job names invalid,
+// the pattern of the CRD matches any name containing a valid character
+func checkNames(mj *jobsmanagerv1beta1.ManagedJob) field.ErrorList {
+	errs := field.ErrorList{}
+	label := func(path *field.Path, name string) bool {
+		for _, msg := range validation.IsDNS1123Label(name) {
+			errs = append(errs, field.Invalid(path, name, msg))
+		}
+		return len(validation.IsDNS1123Label(name)) == 0
+	}
+	groups := map[string]bool{}
+	for i, group := range mj.Spec.Groups {
+		groupPath := field.NewPath("spec", "groups").Index(i)
+		if groups[group.Name] {
+			errs = append(errs, field.Duplicate(groupPath.Child("name"), group.Name))
+		}
+		groups[group.Name] = true
+		validGroup := label(groupPath.Child("name"), group.Name)
+		jobs := map[string]bool{}
+		for j, job := range group.Jobs {
+			namePath := groupPath.Child("jobs").Index(j).Child("name")
+			if jobs[job.Name] {
+				errs = append(errs, field.Duplicate(namePath, job.Name))
+			}
+			jobs[job.Name] = true
+			if !label(namePath, job.Name) || !validGroup {
+				continue
+			}
+			generated := dependencies.JobName(mj.Name, group.Name, job.Name)
+			for _, msg := range validation.IsDNS1123Label(generated) {
+				errs = append(errs, field.Invalid(namePath, job.Name, "generated job name "+generated+": "+msg))
+			}
+		}
+	}
+	return errs
+}
+
+// checkImages reports the malformed images of the workflow, its groups and jobs
+func checkImages(mj *jobsmanagerv1beta1.ManagedJob) field.ErrorList {
+	errs := field.ErrorList{}
+	check := func(path *field.Path, image string) {
+		if image != "" && !imageReference.MatchString(image) {
+			errs = append(errs, field.Invalid(path, image, "not a valid image reference"))
+		}
+	}
+	// the jobs without any image in the chain are reported by the webhook validation
+	check(field.NewPath("spec", "image"), mj.Spec.Image)
+	for i, group := range mj.Spec.Groups {
+		groupPath := field.NewPath("spec", "groups").Index(i)
+		check(groupPath.Child("image"), group.Image)
+		for j, job := range group.Jobs {
+			check(groupPath.Child("jobs").Index(j).Child("image"), job.Image)
+		}
+	}
+	return errs
+}
+
+// checkEnvReferences warns about the $(NAME) in the args not matching any env variable of the job,
+// Kubernetes passes them as they are. Variables coming from fromEnv or the patches are not known offline.
+func checkEnvReferences(mj *jobsmanagerv1beta1.ManagedJob) []string {
+	warnings := []string{}
+	for i, group := range mj.Spec.Groups {
+		for j, job := range group.Jobs {
+			levels := []jobsmanagerv1beta1.ManagedJobParameters{mj.Spec.Params, group.Params, job.Params}
+			defined := map[string]bool{"PUSHGATEWAY_URL": true}
+			if job.FanOut != nil {
+				defined["JOB_COMPLETION_INDEX"] = true
+			}
+			opaque := len(job.ParamsPatches) > 0
+			for _, params := range levels {
+				opaque = opaque || len(params.FromEnv) > 0
+				for _, env := range params.Env {
+					defined[env.Name] = true
+				}
+			}
+			if opaque {
+				continue
+			}
+			missing := map[string]bool{}
+			for _, arg := range job.Args {
+				for _, match := range envReference.FindAllStringSubmatch(arg, -1) {
+					// odd number of dollars is the reference, even is the escaped text
+					if dollars := strings.Index(match[0], "("); dollars%2 == 1 && !defined[match[1]] {
+						missing[match[1]] = true
+					}
+				}
+			}
+			names := []string{}
+			for name := range missing {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				path := field.NewPath("spec", "groups").Index(i).Child("jobs").Index(j).Child("args")
+				warnings = append(warnings, fmt.Sprintf("%s: $(%s) does not reference any env variable of the job, it is passed as is", path, name))
+			}
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,188 @@
+package lint
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+func parse(t *testing.T, manifest string) map[string]interface{} {
+	t.Helper()
+	object := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(manifest), &object); err != nil {
+		t.Fatal(err)
+	}
+	return object
+}
+
+func TestEmbeddedCRDInSync(t *testing.T) {
+	base, err := os.ReadFile("../../config/crd/bases/jobsmanager.raczylo.com_managedjobs.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(base, crdManifest) {
+		t.Error("pkg/lint/managedjobs.yaml differs from config/crd/bases, run make manifests")
+	}
+}
+
+func TestWorkflowValid(t *testing.T) {
+	result := Workflow(parse(t, `
+apiVersion: jobsmanager.raczylo.com/v1beta1
+kind: ManagedJob
+metadata:
+  name: release
+spec:
+  params:
+    env:
+      - name: STAGE
+        value: prod
+  groups:
+    - name: build
+      jobs:
+        - name: compile
+          image: ghcr.io/example/builder:1.2@sha256:0123456789abcdef0123456789abcdef
+          args: ["make", "$(STAGE)", "$$(ESCAPED)"]
+    - name: test
+      dependencies:
+        - name: build
+      jobs:
+        - name: unit
+          image: localhost:5000/busybox
+`))
+	if result.Name != "release" || len(result.Errors) != 0 || len(result.Warnings) != 0 {
+		t.Errorf("Workflow() = %+v", result)
+	}
+}
+
+func TestWorkflowProblems(t *testing.T) {
+	result := Workflow(parse(t, `
+apiVersion: jobsmanager.raczylo.com/v1beta1
+kind: ManagedJob
+metadata:
+  name: release
+spec:
+  retries: 0
+  groups:
+    - name: build
+      ordering: Sometimes
+      dependencies:
+        - name: test
+      jobs:
+        - name: compile
+          image: "Busybox:"
+          imagePolicy: Always
+          args: ["$(MISSING)"]
+        - name: compile
+          image: busybox
+          params:
+            restartPolicy: Always
+        - name: Lint_it
+          image: busybox
+    - name: test
+      dependencies:
+        - name: build
+          status: failed
+        - name: deploy
+      jobs:
+        - name: unit
+    - name: longer-group-name-to-overflow-the-job
+      jobs:
+        - name: with-even-longer-job-name-than-before
+          image: busybox
+`))
+
+	want := []string{
+		"spec.retries in body should be greater than or equal to 1",
+		"spec.groups[0].ordering in body should be one of",
+		`unknown field "imagePolicy"`,
+		`spec.groups[0].jobs[1].params.restartPolicy: Unsupported value: "Always"`,
+		`spec.groups[0].jobs[1].name: Duplicate value: "compile"`,
+		`spec.groups[0].jobs[2].name: Invalid value: "Lint_it"`,
+		`spec.groups[2].jobs[0].name: Invalid value: "with-even-longer-job-name-than-before": generated job name`,
+		`spec.groups[0].jobs[0].image: Invalid value: "Busybox:": not a valid image reference`,
+		`spec.groups[1].jobs[0].image: Required value`,
+		`spec.groups[1].dependencies[1].name: Not found: "deploy"`,
+	}
+	errs := []string{}
+	for _, err := range result.Errors {
+		errs = append(errs, err.Error())
+	}
+	all := strings.Join(errs, "\n")
+	for _, w := range want {
+		if !strings.Contains(all, w) {
+			t.Errorf("Workflow() errors missing %q:\n%s", w, all)
+		}
+	}
+	if len(errs) != len(want) {
+		t.Errorf("Workflow() got %d errors, want %d:\n%s", len(errs), len(want), all)
+	}
+	if len(result.Warnings) != 2 || !strings.Contains(result.Warnings[0], "spec.groups[1].dependencies[0].status is set by the operator") ||
+		!strings.Contains(result.Warnings[1], "$(MISSING)") {
+		t.Errorf("Workflow() warnings = %v", result.Warnings)
+	}
+}
+
+func TestWorkflowCycles(t *testing.T) {
+	result := Workflow(parse(t, `
+apiVersion: jobsmanager.raczylo.com/v1beta1
+kind: ManagedJob
+metadata:
+  name: release
+spec:
+  groups:
+    - name: build
+      dependencies:
+        - name: test
+      jobs:
+        - name: compile
+          image: busybox
+          dependencies:
+            - name: release-build-package
+        - name: package
+          image: busybox
+          dependencies:
+            - name: release-build-compile
+    - name: test
+      dependencies:
+        - name: build
+      jobs:
+        - name: unit
+          image: busybox
+`))
+	want := []string{
+		"spec.groups: Forbidden: dependency cycle between [build test]",
+		"spec.groups[0].jobs: Forbidden: dependency cycle between [release-build-compile release-build-package]",
+	}
+	if len(result.Errors) != len(want) {
+		t.Fatalf("Workflow() errors = %v", result.Errors)
+	}
+	for i, err := range result.Errors {
+		if err.Error() != want[i] {
+			t.Errorf("Workflow() error %d = %v, want %s", i, err, want[i])
+		}
+	}
+}
+
+func TestImageReference(t *testing.T) {
+	tests := map[string]bool{
+		"busybox":                         true,
+		"busybox:1.36":                    true,
+		"library/busybox:latest":          true,
+		"registry.example.com:5000/a/b:c": true,
+		"ghcr.io/org/image@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef": true,
+		"Busybox":           false,
+		"busybox:":          false,
+		"busybox::1":        false,
+		"http://busybox":    false,
+		"image with spaces": false,
+		"registry/-leading": false,
+	}
+	for image, valid := range tests {
+		if got := imageReference.MatchString(image); got != valid {
+			t.Errorf("imageReference(%q) = %v, want %v", image, got, valid)
+		}
+	}
+}
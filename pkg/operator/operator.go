@@ -0,0 +1,440 @@
+// Package operator runs the ManagedJob controller, either as the standalone manager
+// or embedded into the manager of another operator binary.
+package operator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/utils/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/controllers"
+	"raczylo.com/jobs-manager-operator/pkg/admin"
+	"raczylo.com/jobs-manager-operator/pkg/dependencies"
+	//+kubebuilder:scaffold:imports
+)
+
+// Options configure the operator, DefaultOptions returns the defaults of the standalone manager
+type Options struct {
+	// Config of the cluster connection, loaded the same way as by kubectl when nil
+	Config *rest.Config
+	// QPS and Burst throttle the requests of the manager's API client on the client side, the values of
+	// Config are kept when 0
+	QPS   float32
+	Burst int
+
+	// MetricsBindAddress of the metrics endpoint, "0" disables it
+	MetricsBindAddress string
+	// HealthProbeBindAddress of the healthz and readyz endpoints, "0" disables them
+	HealthProbeBindAddress string
+	// LeaderElection ensures there is only one active manager, LeaderElectionID names its lease
+	LeaderElection   bool
+	LeaderElectionID string
+	// EnableWebhooks serves the validating webhook, it needs the serving certificate
+	EnableWebhooks bool
+	// OperatorUsername the operator is authenticated as, the webhook lets only it create the sub-workflows.
+	// It's the ServiceAccount named by the OPERATOR_SERVICE_ACCOUNT environment variable in the namespace
+	// of the operator pod when empty.
+	OperatorUsername string
+	// NamespaceDeletionProtection of the namespaces with running workflows, off, warn or deny, served with the webhooks
+	NamespaceDeletionProtection string
+
+	// LogArchiveURL is the object storage location for the logs of completed jobs, archiving is disabled when empty
+	LogArchiveURL string
+	// PushgatewayURL is injected into the jobs of workflows opted in for pushing metrics
+	PushgatewayURL string
+	// CapacityCheck delays groups which requests do not fit into the namespace quota
+	CapacityCheck bool
+	// RecordResolvedSpec stores the full resolved pod spec in the annotation of the created Job
+	RecordResolvedSpec bool
+	// ResyncInterval enqueues all the non-terminal workflows periodically, 0 sweeps only once after the start
+	ResyncInterval time.Duration
+	// ShutdownTimeout bounds how long the in-flight reconciles may run after the shutdown started
+	ShutdownTimeout time.Duration
+	// NotificationWorkers deliver the status notifications of the workflows at the same time
+	NotificationWorkers int
+	// NotificationAllowedHosts the webhooks may be sent to though they are local to the cluster, e.g. the
+	// services of the cluster, *.example.com allows the subdomains and the CIDRs allow the addresses
+	NotificationAllowedHosts []string
+	// MaintenanceWindows are the periods during which no new jobs of any workflow are started
+	MaintenanceWindows []jobsmanagerv1beta1.ManagedJobMaintenanceWindow
+	// DecisionEvents records the scheduling decisions as events of the workflow
+	DecisionEvents bool
+	// MaxActiveWorkflowsPerNamespace queues the workflows which would start above the cap, 0 is unlimited
+	MaxActiveWorkflowsPerNamespace int
+	// MaxActiveJobsPerNamespace stops starting new jobs while as many jobs of the namespace run, 0 is unlimited
+	MaxActiveJobsPerNamespace int
+	// ImagePullRefresh refreshes the registry credentials when the image pulls are denied, nil disables it
+	ImagePullRefresh *controllers.ImagePullRefresh
+	// SlowSchedulingThreshold after which the pods of the job which are not running are reported, 0 disables the reports
+	SlowSchedulingThreshold time.Duration
+	// CostPrices estimate the costs of the finished jobs, nothing is estimated when they are not set
+	CostPrices controllers.CostPrices
+	// ReconcileErrorBudget of the consecutive failed reconciles, the workflow is then degraded and reconciled
+	// every DegradedRequeue only, 0 never degrades the workflows
+	ReconcileErrorBudget int
+	DegradedRequeue      time.Duration
+	// FullSyncInterval bounds how long the quiescent workflows skip the sync, 0 always syncs them
+	FullSyncInterval time.Duration
+	// WatchLabelSelector limits the reconciled ManagedJobs to the matching ones, all are reconciled when empty
+	WatchLabelSelector string
+	// Executors start the jobs of custom types and override the built-in ones
+	Executors map[string]controllers.JobExecutor
+	// Clock of the controller, the real clock when nil
+	Clock clock.Clock
+	// AuthorizePrivilegedJobs checks the creators of the workflows with the SubjectAccessReview before
+	// starting their privileged jobs, Authorizer replaces the check
+	AuthorizePrivilegedJobs bool
+	Authorizer              controllers.JobAuthorizer
+	// Vault issues the per run secrets of the jobs with secretsFrom.vault, nil fails such jobs
+	Vault *controllers.VaultSecrets
+	// ImpersonateJobCreators creates the jobs of the groups with impersonate as the creator of the workflow or
+	// the ServiceAccount, JobClients replaces the impersonating clients. Such jobs fail when it's disabled.
+	ImpersonateJobCreators bool
+	JobClients             controllers.JobClients
+	// RevisionHistoryLimit of the definitions of every workflow kept for the rollbacks, 0 keeps none
+	RevisionHistoryLimit int
+	// Migrations of the stored workflows run once by the leader before the workflows are reconciled, the
+	// embedding operators may append their own to controllers.Migrations
+	Migrations []controllers.Migration
+	// MigrationNamespace of the ConfigMap recording the migrations, the namespace of the operator pod when empty
+	MigrationNamespace string
+	// RunIDFormat of the IDs of the runs, ulid or uuid, RunIDs replaces the generator of the format
+	RunIDFormat string
+	RunIDs      controllers.RunIDGenerator
+	// AdminBindAddress of the admin API serving the plugin operations, "0" disables it. It's served over TLS
+	// with AdminCertFile and AdminKeyFile, the callers send their bearer tokens with every request so it's
+	// served over plain HTTP only with AdminInsecure, e.g. behind a TLS terminating proxy in the same pod.
+	AdminBindAddress string
+	AdminCertFile    string
+	AdminKeyFile     string
+	AdminInsecure    bool
+}
+
+// DefaultOptions returns the options the standalone manager starts with
+func DefaultOptions() Options {
+	return Options{
+		MetricsBindAddress:          ":8080",
+		HealthProbeBindAddress:      ":8081",
+		QPS:                         20,
+		Burst:                       30,
+		LeaderElectionID:            "b86e0f00.raczylo.com",
+		ResyncInterval:              10 * time.Minute,
+		ShutdownTimeout:             20 * time.Second,
+		NotificationWorkers:         controllers.DefaultNotificationWorkers,
+		ReconcileErrorBudget:        10,
+		DegradedRequeue:             controllers.DefaultDegradedRequeue,
+		FullSyncInterval:            controllers.DefaultFullSyncInterval,
+		SlowSchedulingThreshold:     controllers.DefaultSlowSchedulingThreshold,
+		NamespaceDeletionProtection: controllers.NamespaceProtectionWarn,
+		RevisionHistoryLimit:        controllers.DefaultRevisionHistoryLimit,
+		Migrations:                  controllers.Migrations,
+		RunIDFormat:                 controllers.RunIDFormatULID,
+		AdminBindAddress:            "0",
+	}
+}
+
+// AddToScheme registers the types used by the controller
+func AddToScheme(scheme *runtime.Scheme) error {
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return err
+	}
+	//+kubebuilder:scaffold:scheme
+	return jobsmanagerv1beta1.AddToScheme(scheme)
+}
+
+// Operator is the manager running the ManagedJob controller
+type Operator struct {
+	Manager ctrl.Manager
+}
+
+// operatorServiceAccountEnv names the ServiceAccount of the operator pod, set from the downward API
+const operatorServiceAccountEnv = "OPERATOR_SERVICE_ACCOUNT"
+
+// operatorUsername returns the username the operator is authenticated as, empty when it's not known
+func (o Options) operatorUsername() string {
+	if o.OperatorUsername != "" {
+		return o.OperatorUsername
+	}
+	if serviceAccount := os.Getenv(operatorServiceAccountEnv); serviceAccount != "" {
+		return fmt.Sprintf("system:serviceaccount:%s:%s", operatorNamespace(), serviceAccount)
+	}
+	return ""
+}
+
+// notificationTargets of the webhooks of the workflows
+func (o Options) notificationTargets() jobsmanagerv1beta1.NotificationTargets {
+	return jobsmanagerv1beta1.NotificationTargets{Allowed: o.NotificationAllowedHosts}
+}
+
+// validate rejects the options which can not work together
+func (o Options) validate() error {
+	// the creators and the sub-workflows are protected by the webhook, without it the clients could name
+	// any user as the creator or claim to be the sub-workflow of another user's workflow
+	if o.ImpersonateJobCreators && !o.EnableWebhooks {
+		return fmt.Errorf("impersonating the job creators needs the webhooks recording the creators of the workflows")
+	}
+	if o.AuthorizePrivilegedJobs && !o.EnableWebhooks {
+		return fmt.Errorf("authorizing the privileged jobs needs the webhooks recording the creators of the workflows")
+	}
+	if o.EnableWebhooks && o.operatorUsername() == "" {
+		return fmt.Errorf("the webhooks need the username of the operator, set %s or the operator username", operatorServiceAccountEnv)
+	}
+	// the bearer tokens of the callers would travel in clear text
+	if o.AdminBindAddress != "" && o.AdminBindAddress != "0" && o.AdminCertFile == "" && !o.AdminInsecure {
+		return fmt.Errorf("the admin API needs the certificate and the key to serve it over TLS, or explicitly allowed plain HTTP")
+	}
+	if o.AdminCertFile != "" && o.AdminKeyFile == "" {
+		return fmt.Errorf("the certificate of the admin API needs its private key")
+	}
+	return nil
+}
+
+// New creates the manager with the controller, the webhook and the health checks set up
+func New(options Options) (*Operator, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	// workflows of the other shards never make it into the cache, so they are not reconciled at all
+	shardSelector, err := labels.Parse(options.WatchLabelSelector)
+	if err != nil {
+		return nil, err
+	}
+	cacheOptions := cache.Options{}
+	if !shardSelector.Empty() {
+		cacheOptions.ByObject = map[client.Object]cache.ByObject{
+			&jobsmanagerv1beta1.ManagedJob{}: {Label: shardSelector},
+		}
+	}
+
+	config := options.Config
+	if config == nil {
+		if config, err = ctrl.GetConfig(); err != nil {
+			return nil, err
+		}
+	}
+	config = throttledConfig(config, options.QPS, options.Burst)
+
+	scheme := runtime.NewScheme()
+	utilruntime.Must(AddToScheme(scheme))
+
+	// in-flight reconciles are given ShutdownTimeout, the manager waits a bit longer for them
+	gracefulShutdownTimeout := options.ShutdownTimeout + 5*time.Second
+	mgr, err := ctrl.NewManager(config, ctrl.Options{
+		Scheme: scheme,
+		Metrics: metricsserver.Options{
+			BindAddress: options.MetricsBindAddress,
+			// the exemplars, like the IDs of the runs, are served in the OpenMetrics format only
+			ExtraHandlers: map[string]http.Handler{
+				"/openmetrics": promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{EnableOpenMetrics: true}),
+			},
+		},
+		HealthProbeBindAddress:  options.HealthProbeBindAddress,
+		LeaderElection:          options.LeaderElection,
+		LeaderElectionID:        options.LeaderElectionID,
+		Cache:                   cacheOptions,
+		GracefulShutdownTimeout: &gracefulShutdownTimeout,
+		// the controller watches ConfigMaps and Secrets by their metadata, their content is read from the API
+		// server instead of caching every ConfigMap and Secret of the cluster
+		Client: client.Options{Cache: &client.CacheOptions{
+			DisableFor: []client.Object{&corev1.ConfigMap{}, &corev1.Secret{}},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := SetupWithManager(mgr, options); err != nil {
+		return nil, err
+	}
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		return nil, err
+	}
+	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+		return nil, err
+	}
+	return &Operator{Manager: mgr}, nil
+}
+
+// throttledConfig returns the copy of the config with the client-side rate limits set, the Jobs of
+// large workflows are created in bursts which the low defaults of client-go slow down
+func throttledConfig(config *rest.Config, qps float32, burst int) *rest.Config {
+	config = rest.CopyConfig(config)
+	if qps > 0 {
+		config.QPS = qps
+	}
+	if burst > 0 {
+		config.Burst = burst
+	}
+	return config
+}
+
+// serviceAccountNamespaceFile holds the namespace of the pod running the operator
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// operatorNamespace returns the namespace of the operator pod, default when it runs outside of the cluster
+func operatorNamespace() string {
+	if namespace, err := os.ReadFile(serviceAccountNamespaceFile); err == nil && len(namespace) > 0 {
+		return strings.TrimSpace(string(namespace))
+	}
+	return "default"
+}
+
+// SetupWithManager adds the ManagedJob and ManagedJobConformance controllers and the webhook to the existing
+// manager, its scheme needs AddToScheme. Manager options like the sharding cache and the graceful shutdown
+// are left to the caller.
+func SetupWithManager(mgr ctrl.Manager, options Options) error {
+	if err := options.validate(); err != nil {
+		return err
+	}
+	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+
+	runIDs := options.RunIDs
+	if runIDs == nil {
+		generator, known := controllers.RunIDGenerators[options.RunIDFormat]
+		if !known && options.RunIDFormat != "" {
+			return fmt.Errorf("unknown run ID format %q, expected %s or %s", options.RunIDFormat, controllers.RunIDFormatULID, controllers.RunIDFormatUUID)
+		}
+		runIDs = generator
+	}
+
+	var logArchiver controllers.LogArchiver
+	if options.LogArchiveURL != "" {
+		if logArchiver, err = controllers.NewLogArchiver(options.LogArchiveURL); err != nil {
+			return err
+		}
+	}
+
+	reconciler := &controllers.ManagedJobReconciler{
+		Client:                         mgr.GetClient(),
+		Scheme:                         mgr.GetScheme(),
+		Recorder:                       mgr.GetEventRecorderFor("managedjob-controller"),
+		Clientset:                      clientset,
+		LogArchiver:                    logArchiver,
+		PushgatewayURL:                 options.PushgatewayURL,
+		CapacityCheck:                  options.CapacityCheck,
+		RecordResolvedSpec:             options.RecordResolvedSpec,
+		ResyncInterval:                 options.ResyncInterval,
+		ShutdownTimeout:                options.ShutdownTimeout,
+		DecisionEvents:                 options.DecisionEvents,
+		MaintenanceWindows:             options.MaintenanceWindows,
+		CostPrices:                     options.CostPrices,
+		ImagePullRefresh:               options.ImagePullRefresh,
+		SlowSchedulingThreshold:        options.SlowSchedulingThreshold,
+		MaxActiveWorkflowsPerNamespace: options.MaxActiveWorkflowsPerNamespace,
+		MaxActiveJobsPerNamespace:      options.MaxActiveJobsPerNamespace,
+		ReconcileErrorBudget:           options.ReconcileErrorBudget,
+		DegradedRequeue:                options.DegradedRequeue,
+		FullSyncInterval:               options.FullSyncInterval,
+		Clock:                          options.Clock,
+		Authorizer:                     options.Authorizer,
+		Vault:                          options.Vault,
+		JobClients:                     options.JobClients,
+		RevisionHistoryLimit:           options.RevisionHistoryLimit,
+		RunIDs:                         runIDs,
+	}
+	// the shard migrates and notifies its own workflows, the selector was validated by New
+	shardSelector, err := labels.Parse(options.WatchLabelSelector)
+	if err != nil {
+		return err
+	}
+	reconciler.Notifier = &controllers.Notifier{
+		Client:   mgr.GetClient(),
+		Reader:   mgr.GetAPIReader(),
+		Recorder: reconciler.Recorder,
+		Clock:    options.Clock,
+		Workers:  options.NotificationWorkers,
+		Selector: shardSelector,
+		Targets:  options.notificationTargets(),
+	}
+	if len(options.Migrations) > 0 {
+		namespace := options.MigrationNamespace
+		if namespace == "" {
+			namespace = operatorNamespace()
+		}
+		reconciler.Migrator = &controllers.Migrator{
+			Client:     mgr.GetClient(),
+			Reader:     mgr.GetAPIReader(),
+			Namespace:  namespace,
+			Name:       options.LeaderElectionID + controllers.MigrationsConfigMapSuffix,
+			Selector:   shardSelector,
+			Migrations: options.Migrations,
+		}
+		if err := mgr.Add(reconciler.Migrator); err != nil {
+			return err
+		}
+	}
+	if reconciler.Authorizer == nil && options.AuthorizePrivilegedJobs {
+		reconciler.Authorizer = controllers.SubjectAccessReviewAuthorizer{Client: clientset}
+	}
+	if reconciler.JobClients == nil && options.ImpersonateJobCreators {
+		reconciler.JobClients = &controllers.ImpersonatingClients{Config: mgr.GetConfig(), Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()}
+	}
+	for jobType, executor := range options.Executors {
+		reconciler.RegisterExecutor(jobType, executor)
+	}
+	if err := reconciler.SetupWithManager(mgr); err != nil {
+		return fmt.Errorf("unable to create the ManagedJob controller: %w", err)
+	}
+	conformance := &controllers.ManagedJobConformanceReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("managedjobconformance-controller"),
+		Clock:    options.Clock,
+	}
+	if err := conformance.SetupWithManager(mgr); err != nil {
+		return fmt.Errorf("unable to create the ManagedJobConformance controller: %w", err)
+	}
+	if options.AdminBindAddress != "" && options.AdminBindAddress != "0" {
+		if err := mgr.Add(&admin.Server{
+			Client:      mgr.GetClient(),
+			Reader:      mgr.GetAPIReader(),
+			Clientset:   clientset,
+			BindAddress: options.AdminBindAddress,
+			CertFile:    options.AdminCertFile,
+			KeyFile:     options.AdminKeyFile,
+			Insecure:    options.AdminInsecure,
+		}); err != nil {
+			return err
+		}
+	}
+	// the webhook needs the serving certificate, see config/webhook and config/certmanager
+	if options.EnableWebhooks {
+		authorizer := controllers.SubjectAccessReviewAuthorizer{Client: clientset}
+		if err := (&jobsmanagerv1beta1.ManagedJob{}).SetupWebhookWithManager(mgr, options.operatorUsername(), authorizer.AuthorizeRuntimeChange, options.notificationTargets(), dependencies.Validate); err != nil {
+			return fmt.Errorf("unable to create the ManagedJob webhook: %w", err)
+		}
+		mgr.GetWebhookServer().Register(controllers.NamespaceProtectionWebhookPath, &webhook.Admission{
+			Handler: &controllers.NamespaceDeletionGuard{Client: mgr.GetClient(), Mode: options.NamespaceDeletionProtection},
+		})
+	}
+	//+kubebuilder:scaffold:builder
+	return nil
+}
+
+// Start runs the manager until the context is cancelled
+func (o *Operator) Start(ctx context.Context) error {
+	return o.Manager.Start(ctx)
+}
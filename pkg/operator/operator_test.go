@@ -0,0 +1,82 @@
+package operator
+
+import (
+	"strings"
+	"testing"
+
+	kbatch "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func TestAddToScheme(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, object := range []runtime.Object{&jobsmanagerv1beta1.ManagedJob{}, &kbatch.Job{}} {
+		if _, _, err := scheme.ObjectKinds(object); err != nil {
+			t.Errorf("%T not registered: %v", object, err)
+		}
+	}
+}
+
+// the options are validated before the manager connects to the cluster
+func TestNewInvalidSelector(t *testing.T) {
+	options := DefaultOptions()
+	options.Config = &rest.Config{Host: "https://127.0.0.1:1"}
+	options.WatchLabelSelector = "team in ("
+	if _, err := New(options); err == nil {
+		t.Error("expected the invalid selector to be rejected")
+	}
+}
+
+func TestNewRequiresWebhooks(t *testing.T) {
+	options := DefaultOptions()
+	options.Config = &rest.Config{Host: "https://127.0.0.1:1"}
+	options.ImpersonateJobCreators = true
+	if _, err := New(options); err == nil {
+		t.Error("expected the impersonation without the webhooks to be rejected")
+	}
+	options.ImpersonateJobCreators, options.AuthorizePrivilegedJobs = false, true
+	if _, err := New(options); err == nil {
+		t.Error("expected the authorization without the webhooks to be rejected")
+	}
+	// the webhooks let only the operator create the sub-workflows, it has to know who it is
+	options.EnableWebhooks = true
+	if _, err := New(options); err == nil {
+		t.Error("expected the webhooks without the operator username to be rejected")
+	}
+}
+
+func TestNewRequiresAdminTLS(t *testing.T) {
+	options := DefaultOptions()
+	options.Config = &rest.Config{Host: "https://127.0.0.1:1"}
+	options.AdminBindAddress = ":8443"
+	if _, err := New(options); err == nil || !strings.Contains(err.Error(), "admin API") {
+		t.Errorf("expected the admin API without the certificate to be rejected, got %v", err)
+	}
+	options.AdminCertFile = "/certs/tls.crt"
+	if _, err := New(options); err == nil || !strings.Contains(err.Error(), "private key") {
+		t.Errorf("expected the certificate without the key to be rejected, got %v", err)
+	}
+	options.AdminCertFile, options.AdminInsecure = "", true
+	if err := options.validate(); err != nil {
+		t.Errorf("expected plain HTTP allowed explicitly, got %v", err)
+	}
+}
+
+func TestThrottledConfig(t *testing.T) {
+	config := &rest.Config{Host: "https://127.0.0.1:1", QPS: 20, Burst: 30}
+	throttled := throttledConfig(config, 100, 200)
+	if throttled.QPS != 100 || throttled.Burst != 200 {
+		t.Errorf("throttled config = %v/%d, expected 100/200", throttled.QPS, throttled.Burst)
+	}
+	if config.QPS != 20 || config.Burst != 30 {
+		t.Errorf("config of the caller changed to %v/%d", config.QPS, config.Burst)
+	}
+	if kept := throttledConfig(config, 0, 0); kept.QPS != 20 || kept.Burst != 30 {
+		t.Errorf("unset limits changed the config to %v/%d", kept.QPS, kept.Burst)
+	}
+}
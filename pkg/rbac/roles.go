@@ -0,0 +1,97 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rbac defines the user facing roles for the ManagedJob API.
+// None of the roles grants access to the batch Jobs created by the operator.
+package rbac
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+const (
+	EditorRoleName   = "managedjobs-editor"
+	ViewerRoleName   = "managedjobs-viewer"
+	OperatorRoleName = "managedjobs-operator"
+	// PrivilegedRoleName lets the workflows of its subjects run the privileged jobs when the operator
+	// authorizes them, it is not aggregated and has to be bound explicitly
+	PrivilegedRoleName = "managedjobs-privileged"
+)
+
+var (
+	readVerbs  = []string{"get", "list", "watch"}
+	writeVerbs = []string{"create", "delete", "get", "list", "patch", "update", "watch"}
+)
+
+func clusterRole(name string, aggregateTo []string, rules ...rbacv1.PolicyRule) rbacv1.ClusterRole {
+	labels := map[string]string{
+		"app.kubernetes.io/name":       "clusterrole",
+		"app.kubernetes.io/instance":   name,
+		"app.kubernetes.io/component":  "rbac",
+		"app.kubernetes.io/created-by": "jobs-manager-operator",
+		"app.kubernetes.io/part-of":    "jobs-manager-operator",
+	}
+	for _, aggregate := range aggregateTo {
+		labels["rbac.authorization.k8s.io/aggregate-to-"+aggregate] = "true"
+	}
+	return rbacv1.ClusterRole{
+		TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Rules:      rules,
+	}
+}
+
+func managedJobsRule(verbs []string, resources ...string) rbacv1.PolicyRule {
+	return rbacv1.PolicyRule{
+		APIGroups: []string{jobsmanagerv1beta1.GroupVersion.Group},
+		Resources: resources,
+		Verbs:     verbs,
+	}
+}
+
+// ClusterRoles returns the roles for workflow authors (editor), workflow operators, read-only users (viewer)
+// and the authors of the privileged workflows
+func ClusterRoles() []rbacv1.ClusterRole {
+	return []rbacv1.ClusterRole{
+		// authors define workflows but cannot interfere with their runtime state, the webhook refuses them
+		// the approvals and the actions
+		clusterRole(EditorRoleName, []string{"admin", "edit"},
+			managedJobsRule(writeVerbs, "managedjobs"),
+			managedJobsRule(readVerbs, "managedjobs/status"),
+		),
+		// operators run existing workflows - retry, abort and resume them through the status and annotations
+		clusterRole(OperatorRoleName, []string{"admin"},
+			managedJobsRule([]string{"get", "list", "watch", "patch", "update"}, "managedjobs"),
+			managedJobsRule([]string{"get", "patch", "update"}, "managedjobs/status"),
+			// virtual subresources checked by the webhook, approval guards the approvals of the groups and
+			// the outcomes of the manual jobs, action the retry and abort annotation
+			managedJobsRule([]string{jobsmanagerv1beta1.RuntimeChangeVerb}, "managedjobs/"+jobsmanagerv1beta1.ApprovalSubresource, "managedjobs/"+jobsmanagerv1beta1.ActionSubresource),
+			// deleting the mutex releases the one held by a stuck workflow
+			managedJobsRule([]string{"get", "list", "watch", "delete"}, "managedjobmutexes"),
+			// conformance runs validate the operator in the namespace, e.g. after its upgrade
+			managedJobsRule(writeVerbs, "managedjobconformances"),
+		),
+		clusterRole(ViewerRoleName, []string{"admin", "edit", "view"},
+			managedJobsRule(readVerbs, "managedjobs", "managedjobs/status", "managedjobmutexes", "managedjobconformances", "managedjobconformances/status"),
+		),
+		clusterRole(PrivilegedRoleName, nil,
+			managedJobsRule([]string{"use"}, "managedjobs/privileged"),
+		),
+	}
+}
@@ -0,0 +1,155 @@
+// Package simulator replays the scheduling and status propagation of the controller in memory,
+// so the dependency logic of a workflow can be checked without a cluster.
+package simulator
+
+import (
+	"fmt"
+	"strings"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/controllers"
+)
+
+// Outcomes maps the `group/job` names to the status the job finishes with, jobs not listed succeed
+type Outcomes map[string]string
+
+// Event is a single status change of a group or a job, Job is empty for the group events
+type Event struct {
+	Step   int
+	Group  string
+	Job    string
+	Status string
+}
+
+// Result is the ordered timeline of the simulated run and the final status of the workflow
+type Result struct {
+	Timeline []Event
+	Status   string
+}
+
+// JobKey returns the name used to refer to the job in Outcomes
+func JobKey(group, job string) string {
+	return group + "/" + job
+}
+
+// Simulate runs the workflow from the beginning, every step starts all the runnable jobs which
+// finish before the next step begins. The passed workflow is not modified.
+func Simulate(workflow *jobsmanagerv1beta1.ManagedJob, outcomes Outcomes) (*Result, error) {
+	mj := workflow.DeepCopy()
+	if err := validateOutcomes(mj, outcomes); err != nil {
+		return nil, err
+	}
+	if err := controllers.ResolveDependencies(mj); err != nil {
+		return nil, err
+	}
+	resetStatuses(mj)
+
+	s := &simulation{mj: mj, result: &Result{}, last: snapshot(mj)}
+	controllers.PropagateStatuses(mj)
+	s.record()
+
+	for s.step = 1; ; s.step++ {
+		started := []*jobsmanagerv1beta1.ManagedJobDefinition{}
+		startedGroups := map[*jobsmanagerv1beta1.ManagedJobDefinition]string{}
+		controllers.ScheduleRunnableJobs(mj, func(group *jobsmanagerv1beta1.ManagedJobGroup, job *jobsmanagerv1beta1.ManagedJobDefinition) error {
+			started = append(started, job)
+			startedGroups[job] = group.Name
+			return nil
+		})
+		if len(started) == 0 {
+			break
+		}
+		s.record()
+
+		for _, job := range started {
+			job.Status = controllers.ExecutionStatusSucceeded
+			if status, found := outcomes[JobKey(startedGroups[job], job.Name)]; found {
+				job.Status = status
+			}
+		}
+		controllers.PropagateStatuses(mj)
+		s.record()
+	}
+
+	s.result.Status = controllers.WorkflowStatus(mj)
+	if s.result.Status == controllers.ExecutionStatusRunning {
+		return s.result, fmt.Errorf("workflow stalled, jobs never started: %s", strings.Join(pendingJobs(mj), ", "))
+	}
+	return s.result, nil
+}
+
+type simulation struct {
+	mj     *jobsmanagerv1beta1.ManagedJob
+	step   int
+	last   map[string]string
+	result *Result
+}
+
+// record appends the status changes since the last call to the timeline, in the declaration order
+func (s *simulation) record() {
+	current := snapshot(s.mj)
+	for _, group := range s.mj.Spec.Groups {
+		if current[group.Name] != s.last[group.Name] {
+			s.result.Timeline = append(s.result.Timeline, Event{Step: s.step, Group: group.Name, Status: group.Status})
+		}
+		for _, job := range group.Jobs {
+			key := JobKey(group.Name, job.Name)
+			if current[key] != s.last[key] {
+				s.result.Timeline = append(s.result.Timeline, Event{Step: s.step, Group: group.Name, Job: job.Name, Status: job.Status})
+			}
+		}
+	}
+	s.last = current
+}
+
+func snapshot(mj *jobsmanagerv1beta1.ManagedJob) map[string]string {
+	statuses := map[string]string{}
+	for _, group := range mj.Spec.Groups {
+		statuses[group.Name] = group.Status
+		for _, job := range group.Jobs {
+			statuses[JobKey(group.Name, job.Name)] = job.Status
+		}
+	}
+	return statuses
+}
+
+func validateOutcomes(mj *jobsmanagerv1beta1.ManagedJob, outcomes Outcomes) error {
+	known := snapshot(mj)
+	for key, status := range outcomes {
+		if _, found := known[key]; !found || !strings.Contains(key, "/") {
+			return fmt.Errorf("unknown job %s, expected group/job", key)
+		}
+		if status != controllers.ExecutionStatusSucceeded && status != controllers.ExecutionStatusFailed {
+			return fmt.Errorf("job %s can only finish as %s or %s", key, controllers.ExecutionStatusSucceeded, controllers.ExecutionStatusFailed)
+		}
+	}
+	return nil
+}
+
+func resetStatuses(mj *jobsmanagerv1beta1.ManagedJob) {
+	mj.Status.Phase = controllers.ExecutionStatusPending
+	for _, group := range mj.Spec.Groups {
+		group.Status = controllers.ExecutionStatusPending
+		for _, dependency := range group.Dependencies {
+			dependency.Status = controllers.ExecutionStatusPending
+		}
+		for _, job := range group.Jobs {
+			job.Status = controllers.ExecutionStatusPending
+			for _, dependency := range job.Dependencies {
+				dependency.Status = controllers.ExecutionStatusPending
+			}
+		}
+	}
+}
+
+func pendingJobs(mj *jobsmanagerv1beta1.ManagedJob) []string {
+	pending := []string{}
+	for _, group := range mj.Spec.Groups {
+		for _, job := range group.Jobs {
+			if job.Status == controllers.ExecutionStatusPending {
+				pending = append(pending, JobKey(group.Name, job.Name))
+			}
+		}
+	}
+	return pending
+}
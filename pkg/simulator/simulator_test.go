@@ -0,0 +1,165 @@
+package simulator
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/controllers"
+)
+
+func job(name string, parallel bool) *jobsmanagerv1beta1.ManagedJobDefinition {
+	return &jobsmanagerv1beta1.ManagedJobDefinition{Name: name, Image: "busybox", Parallel: parallel}
+}
+
+// sampleWorkflow is the workflow from the README
+func sampleWorkflow() *jobsmanagerv1beta1.ManagedJob {
+	return &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "managedjob-sample"},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{
+			Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+				{Name: "first-group", Parallel: true, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{job("first-job", false), job("second-job", false), job("second-half-job", false)}},
+				{Name: "second-group", Parallel: true, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{job("third-job", true), job("fourth-job", false)}},
+				{Name: "third-group", Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{job("fifth-job", true)}},
+			},
+		},
+	}
+}
+
+// startedAt returns the step in which every job has been started
+func startedAt(result *Result) map[string]int {
+	steps := map[string]int{}
+	for _, event := range result.Timeline {
+		if event.Job != "" && event.Status == controllers.ExecutionStatusRunning {
+			steps[JobKey(event.Group, event.Job)] = event.Step
+		}
+	}
+	return steps
+}
+
+func TestSimulateSucceeded(t *testing.T) {
+	result, err := Simulate(sampleWorkflow(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != controllers.ExecutionStatusSucceeded {
+		t.Errorf("expected %s, got %s", controllers.ExecutionStatusSucceeded, result.Status)
+	}
+
+	expected := map[string]int{
+		"first-group/first-job":       1,
+		"second-group/third-job":      1,
+		"first-group/second-job":      2,
+		"second-group/fourth-job":     2,
+		"first-group/second-half-job": 3,
+		"third-group/fifth-job":       4,
+	}
+	steps := startedAt(result)
+	for key, step := range expected {
+		if steps[key] != step {
+			t.Errorf("%s: expected to start in step %d, got %d", key, step, steps[key])
+		}
+	}
+}
+
+func TestSimulateFailure(t *testing.T) {
+	workflow := sampleWorkflow()
+	result, err := Simulate(workflow, Outcomes{"first-group/second-job": controllers.ExecutionStatusFailed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != controllers.ExecutionStatusFailed {
+		t.Errorf("expected %s, got %s", controllers.ExecutionStatusFailed, result.Status)
+	}
+
+	steps := startedAt(result)
+	for _, key := range []string{"first-group/second-half-job", "third-group/fifth-job"} {
+		if _, found := steps[key]; found {
+			t.Errorf("%s should not have been started", key)
+		}
+	}
+	if _, found := steps["second-group/fourth-job"]; !found {
+		t.Error("independent group should keep running")
+	}
+
+	if workflow.Spec.Groups[0].Jobs[1].Status != "" {
+		t.Error("simulation must not modify the passed workflow")
+	}
+}
+
+func TestSimulateUnknownJob(t *testing.T) {
+	if _, err := Simulate(sampleWorkflow(), Outcomes{"first-group/missing": controllers.ExecutionStatusFailed}); err == nil {
+		t.Error("expected an error for the unknown job")
+	}
+}
+
+func TestSimulateStalled(t *testing.T) {
+	workflow := sampleWorkflow()
+	workflow.Spec.Groups[0].Dependencies = []*jobsmanagerv1beta1.ManagedJobDependencies{{Name: "third-group"}}
+	result, err := Simulate(workflow, nil)
+	if err == nil {
+		t.Fatal("expected the dependency cycle to stall the workflow")
+	}
+	if result.Status != controllers.ExecutionStatusRunning {
+		t.Errorf("expected %s, got %s", controllers.ExecutionStatusRunning, result.Status)
+	}
+}
+
+func TestSimulatePartitions(t *testing.T) {
+	group := &jobsmanagerv1beta1.ManagedJobGroup{Name: "tenants", PartitionSize: 2}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		group.Jobs = append(group.Jobs, job(name, false))
+	}
+	workflow := &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "rolling"},
+		Spec:       jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{group}},
+	}
+	result, err := Simulate(workflow, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]int{"tenants/a": 1, "tenants/b": 1, "tenants/c": 2, "tenants/d": 2, "tenants/e": 3}
+	steps := startedAt(result)
+	for key, step := range expected {
+		if steps[key] != step {
+			t.Errorf("%s: expected to start in step %d, got %d", key, step, steps[key])
+		}
+	}
+}
+
+func TestSimulateOrdering(t *testing.T) {
+	workflow := func(ordering string) *jobsmanagerv1beta1.ManagedJob {
+		return &jobsmanagerv1beta1.ManagedJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "ordered"},
+			Spec: jobsmanagerv1beta1.ManagedJobSpec{Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+				{Name: "first", Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{job("a", false)}},
+				// parallel flags are superseded by the ordering
+				{Name: "second", Parallel: true, Ordering: ordering, Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{job("b", false), job("c", false)}},
+			}},
+		}
+	}
+	tests := []struct {
+		ordering string
+		expected map[string]int
+	}{
+		{ordering: "", expected: map[string]int{"first/a": 1, "second/b": 1, "second/c": 2}},
+		{ordering: controllers.GroupOrderingSerial, expected: map[string]int{"first/a": 1, "second/b": 2, "second/c": 3}},
+		{ordering: controllers.GroupOrderingParallel, expected: map[string]int{"first/a": 1, "second/b": 2, "second/c": 2}},
+		{ordering: controllers.GroupOrderingExplicitOnly, expected: map[string]int{"first/a": 1, "second/b": 1, "second/c": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ordering, func(t *testing.T) {
+			result, err := Simulate(workflow(tt.ordering), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			steps := startedAt(result)
+			for key, step := range tt.expected {
+				if steps[key] != step {
+					t.Errorf("%s: expected to start in step %d, got %d", key, step, steps[key])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package visualization
+
+import (
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+type span struct {
+	start time.Time
+	end   time.Time
+}
+
+// AddDurations fills the durations of the jobs, groups and the workflow from the Jobs created by the operator,
+// running Jobs count until now
+func AddDurations(root *Node, childJobs []kbatch.Job, now time.Time) {
+	spans := map[string]map[string]span{}
+	for _, childJob := range childJobs {
+		if childJob.Status.StartTime == nil {
+			continue
+		}
+		end := now
+		if childJob.Status.CompletionTime != nil {
+			end = childJob.Status.CompletionTime.Time
+		}
+		for _, condition := range childJob.Status.Conditions {
+			if condition.Type == kbatch.JobFailed && condition.Status == corev1.ConditionTrue {
+				end = condition.LastTransitionTime.Time
+			}
+		}
+		groupName := childJob.Labels["jobmanager.raczylo.com/group-name"]
+		if spans[groupName] == nil {
+			spans[groupName] = map[string]span{}
+		}
+		spans[groupName][childJob.Labels["jobmanager.raczylo.com/job-id"]] = span{start: childJob.Status.StartTime.Time, end: end}
+	}
+
+	workflowSpan := span{}
+	for _, group := range root.Children {
+		groupSpan := span{}
+		for _, job := range group.Children {
+			jobSpan, found := spans[group.Name][job.Name]
+			if !found {
+				continue
+			}
+			job.Duration = formatDuration(jobSpan)
+			groupSpan = groupSpan.extend(jobSpan)
+		}
+		if !groupSpan.start.IsZero() {
+			group.Duration = formatDuration(groupSpan)
+			workflowSpan = workflowSpan.extend(groupSpan)
+		}
+	}
+	if !workflowSpan.start.IsZero() {
+		root.Duration = formatDuration(workflowSpan)
+	}
+}
+
+func (s span) extend(other span) span {
+	if s.start.IsZero() || other.start.Before(s.start) {
+		s.start = other.start
+	}
+	if other.end.After(s.end) {
+		s.end = other.end
+	}
+	return s
+}
+
+func formatDuration(s span) string {
+	return s.end.Sub(s.start).Truncate(time.Second).String()
+}
@@ -0,0 +1,38 @@
+package visualization
+
+import (
+	"raczylo.com/jobs-manager-operator/pkg/visualization/v1alpha1"
+)
+
+// Export converts the tree into the versioned machine readable document
+func Export(root *Node) v1alpha1.Tree {
+	tree := v1alpha1.Tree{
+		SchemaVersion: v1alpha1.SchemaVersion,
+		Workflow:      exportNode(root),
+		Summary:       v1alpha1.Summary{Status: map[string]int{}},
+	}
+	for _, group := range root.Children {
+		tree.Summary.Groups++
+		for _, job := range group.Children {
+			tree.Summary.Jobs++
+			tree.Summary.Status[status(job)]++
+		}
+	}
+	return tree
+}
+
+func exportNode(node *Node) v1alpha1.Node {
+	exported := v1alpha1.Node{
+		Kind:         node.Kind,
+		Name:         node.Name,
+		Status:       status(node),
+		Reason:       node.Reason,
+		Description:  node.Description,
+		Duration:     node.Duration,
+		Dependencies: node.Dependencies,
+	}
+	for _, child := range node.Children {
+		exported.Children = append(exported.Children, exportNode(child))
+	}
+	return exported
+}
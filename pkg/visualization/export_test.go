@@ -0,0 +1,75 @@
+package visualization
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+
+	"raczylo.com/jobs-manager-operator/pkg/visualization/v1alpha1"
+)
+
+func TestExport(t *testing.T) {
+	tree := Export(FromManagedJob(testWorkflow()))
+	if tree.SchemaVersion != v1alpha1.SchemaVersion {
+		t.Errorf("schemaVersion = %q", tree.SchemaVersion)
+	}
+	expectedSummary := v1alpha1.Summary{Groups: 2, Jobs: 3, Status: map[string]int{"succeeded": 2, "running": 1}}
+	if !reflect.DeepEqual(tree.Summary, expectedSummary) {
+		t.Errorf("summary = %+v, expected %+v", tree.Summary, expectedSummary)
+	}
+	parse := tree.Workflow.Children[0].Children[1]
+	if parse.Kind != KindJob || parse.Name != "parse" || !reflect.DeepEqual(parse.Dependencies, []string{"download"}) {
+		t.Errorf("unexpected job node %+v", parse)
+	}
+}
+
+// the published schema has to describe every field of the exported document
+func TestSchemaMatchesTypes(t *testing.T) {
+	var schema struct {
+		Required   []string                   `json:"required"`
+		Properties map[string]json.RawMessage `json:"properties"`
+		Defs       struct {
+			Node struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"node"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(v1alpha1.Schema, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := json.Marshal(Export(FromManagedJob(testWorkflow())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var document struct {
+		Workflow map[string]json.RawMessage `json:"workflow"`
+	}
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &top); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(encoded, &document); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys(top) != keys(schema.Properties) {
+		t.Errorf("document fields %s, schema properties %s", keys(top), keys(schema.Properties))
+	}
+	for field := range document.Workflow {
+		if _, ok := schema.Defs.Node.Properties[field]; !ok {
+			t.Errorf("node field %q missing from the schema", field)
+		}
+	}
+}
+
+func keys(m map[string]json.RawMessage) string {
+	list := []string{}
+	for key := range m {
+		list = append(list, key)
+	}
+	sort.Strings(list)
+	b, _ := json.Marshal(list)
+	return string(b)
+}
@@ -0,0 +1,143 @@
+package visualization
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	middleItem   = "├── "
+	lastItem     = "└── "
+	continueItem = "│   "
+	emptySpace   = "    "
+)
+
+// Renderer draws the tree as text
+type Renderer struct {
+	// Verbose adds the descriptions and dependencies under the nodes
+	Verbose bool
+	// Columns aligns the statuses and durations in the right-hand gutter instead of following the names
+	Columns bool
+	// Theme of the tree characters and the status colors
+	Theme Theme
+}
+
+// line is a single line of the drawn tree, node is nil for the detail lines
+type line struct {
+	tree string
+	node *Node
+}
+
+// Render writes the tree to w
+func (r Renderer) Render(w io.Writer, root *Node) error {
+	lines := r.lines(nil, root, "", "")
+
+	// names can contain wide characters, so the gutter is aligned on the display width
+	treeWidth := 0
+	statusWidth := 0
+	for _, l := range lines {
+		if l.node == nil {
+			continue
+		}
+		treeWidth = max(treeWidth, runewidth.StringWidth(l.tree))
+		statusWidth = max(statusWidth, runewidth.StringWidth(displayStatus(l.node)))
+	}
+
+	var out strings.Builder
+	for _, l := range lines {
+		if l.node == nil {
+			out.WriteString(l.tree + "\n")
+			continue
+		}
+		// the escape sequences take no room, the gutter is padded on the plain status
+		status := displayStatus(l.node)
+		painted := r.Theme.paint(status)
+		switch {
+		case r.Columns:
+			text := runewidth.FillRight(l.tree, treeWidth) + "  " + painted
+			if l.node.Duration != "" {
+				text += strings.Repeat(" ", statusWidth-runewidth.StringWidth(status)) + "  " + l.node.Duration
+			}
+			out.WriteString(text)
+		case l.node.Duration != "":
+			out.WriteString(fmt.Sprintf("%s [%s, %s]", l.tree, painted, l.node.Duration))
+		default:
+			out.WriteString(fmt.Sprintf("%s [%s]", l.tree, painted))
+		}
+		out.WriteString("\n")
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+func (r Renderer) lines(lines []line, node *Node, prefix string, childPrefix string) []line {
+	lines = append(lines, line{tree: prefix + node.Name, node: node})
+	middle, last, continued, empty := r.Theme.branches()
+
+	// details and logs belong to the node, so they are drawn inside its branch
+	gutter := childPrefix + empty
+	if len(node.Children) > 0 {
+		gutter = childPrefix + continued
+	}
+	if r.Verbose {
+		for _, detail := range details(node) {
+			lines = append(lines, line{tree: strings.TrimRight(gutter+detail, " ")})
+		}
+	}
+	for _, logLine := range node.Logs {
+		lines = append(lines, line{tree: strings.TrimRight(gutter+"> "+logLine, " ")})
+	}
+
+	for i, child := range node.Children {
+		if i == len(node.Children)-1 {
+			lines = r.lines(lines, child, childPrefix+last, childPrefix+empty)
+		} else {
+			lines = r.lines(lines, child, childPrefix+middle, childPrefix+continued)
+		}
+	}
+	return lines
+}
+
+func status(node *Node) string {
+	if node.Status == "" {
+		return "pending"
+	}
+	return node.Status
+}
+
+func displayStatus(node *Node) string {
+	return DisplayStatus(status(node), node.Reason)
+}
+
+// DisplayStatus tells apart the pending groups and jobs blocked by their dependencies from the ones
+// waiting for the capacity, quota, approval, delay or the maintenance window
+func DisplayStatus(status string, reason string) string {
+	if status != "pending" || reason == "" {
+		return status
+	}
+	if reason == "Blocked" {
+		return "blocked"
+	}
+	return "queued"
+}
+
+func details(node *Node) []string {
+	lines := []string{}
+	if description := strings.TrimSpace(node.Description); description != "" {
+		lines = append(lines, strings.Split(description, "\n")...)
+	}
+	if len(node.Dependencies) > 0 {
+		lines = append(lines, "depends on: "+strings.Join(node.Dependencies, ", "))
+	}
+	return lines
+}
+
+func max(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,218 @@
+package visualization
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	kbatch "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+)
+
+func testWorkflow() *jobsmanagerv1beta1.ManagedJob {
+	return &jobsmanagerv1beta1.ManagedJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly"},
+		Status:     jobsmanagerv1beta1.ManagedJobStatus{Phase: "running"},
+		Spec: jobsmanagerv1beta1.ManagedJobSpec{
+			Description: "Nightly ETL",
+			Groups: []*jobsmanagerv1beta1.ManagedJobGroup{
+				{
+					Name:        "extract",
+					Status:      "succeeded",
+					Description: "Pulls the raw data\nfrom the vendors",
+					Jobs: []*jobsmanagerv1beta1.ManagedJobDefinition{
+						{Name: "download", Status: "succeeded", Description: "Downloads the dumps"},
+						{Name: "parse", Status: "succeeded", Dependencies: []*jobsmanagerv1beta1.ManagedJobDependencies{{Name: "nightly-extract-download"}}},
+					},
+				},
+				{
+					Name:         "load",
+					Status:       "running",
+					Dependencies: []*jobsmanagerv1beta1.ManagedJobDependencies{{Name: "extract"}},
+					Jobs:         []*jobsmanagerv1beta1.ManagedJobDefinition{{Name: "upload", Status: "running"}},
+				},
+			},
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		renderer Renderer
+		expected string
+	}{
+		{
+			name:     "compact",
+			renderer: Renderer{},
+			expected: `nightly [running]
+├── extract [succeeded]
+│   ├── download [succeeded]
+│   └── parse [succeeded]
+└── load [running]
+    └── upload [running]
+`,
+		},
+		{
+			name:     "verbose",
+			renderer: Renderer{Verbose: true},
+			expected: `nightly [running]
+│   Nightly ETL
+├── extract [succeeded]
+│   │   Pulls the raw data
+│   │   from the vendors
+│   ├── download [succeeded]
+│   │       Downloads the dumps
+│   └── parse [succeeded]
+│           depends on: download
+└── load [running]
+    │   depends on: extract
+    └── upload [running]
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := tt.renderer.Render(&out, FromManagedJob(testWorkflow())); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestRenderColumns(t *testing.T) {
+	workflow := testWorkflow()
+	workflow.Spec.Groups[0].Jobs[0].Name = "下载"
+	workflow.Spec.Groups[1].Jobs[0].Name = "upload-🚀"
+	root := FromManagedJob(workflow)
+	root.Children[0].Children[0].Duration = "10m0s"
+	root.Children[0].Children[1].Duration = "1m0s"
+
+	var out strings.Builder
+	if err := (Renderer{Columns: true}).Render(&out, root); err != nil {
+		t.Fatal(err)
+	}
+	expected := `nightly            running
+├── extract        succeeded
+│   ├── 下载       succeeded  10m0s
+│   └── parse      succeeded  1m0s
+└── load           running
+    └── upload-🚀  running
+`
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestRenderLogs(t *testing.T) {
+	workflow := testWorkflow()
+	workflow.Spec.Groups[0].Jobs[0].Status = "failed"
+	root := FromManagedJob(workflow)
+	root.Children[0].Children[0].Logs = []string{"fetching dump.csv", "error: connection refused"}
+
+	var out strings.Builder
+	if err := (Renderer{}).Render(&out, root); err != nil {
+		t.Fatal(err)
+	}
+	expected := `nightly [running]
+├── extract [succeeded]
+│   ├── download [failed]
+│   │       > fetching dump.csv
+│   │       > error: connection refused
+│   └── parse [succeeded]
+└── load [running]
+    └── upload [running]
+`
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestRenderTheme(t *testing.T) {
+	workflow := testWorkflow()
+	workflow.Spec.Groups[1].Jobs[0].Status = "errored"
+	root := FromManagedJob(workflow)
+	root.Children[0].Children[0].Duration = "10m0s"
+
+	theme := Theme{ASCII: true, Colors: DefaultColors()}
+	theme.Colors["errored"] = "magenta"
+	if err := theme.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := (Renderer{Columns: true, Theme: theme}).Render(&out, root); err != nil {
+		t.Fatal(err)
+	}
+	expected := "nightly           \033[36mrunning\033[0m\n" +
+		"|-- extract       \033[32msucceeded\033[0m\n" +
+		"|   |-- download  \033[32msucceeded\033[0m  10m0s\n" +
+		"|   `-- parse     \033[32msucceeded\033[0m\n" +
+		"`-- load          \033[36mrunning\033[0m\n" +
+		"    `-- upload    \033[35merrored\033[0m\n"
+	if out.String() != expected {
+		t.Errorf("expected:\n%q\ngot:\n%q", expected, out.String())
+	}
+
+	if err := (Theme{Colors: map[string]string{"skipped": "grey"}}).Validate(); err == nil {
+		t.Error("expected the unknown color rejected")
+	}
+}
+
+func TestAddDurations(t *testing.T) {
+	start := time.Date(2023, 9, 1, 2, 0, 0, 0, time.UTC)
+	childJob := func(group string, job string, started time.Duration, completed time.Duration) kbatch.Job {
+		childJob := kbatch.Job{}
+		childJob.Labels = map[string]string{"jobmanager.raczylo.com/group-name": group, "jobmanager.raczylo.com/job-id": job}
+		startTime := metav1.NewTime(start.Add(started))
+		childJob.Status.StartTime = &startTime
+		if completed > 0 {
+			completionTime := metav1.NewTime(start.Add(completed))
+			childJob.Status.CompletionTime = &completionTime
+		}
+		return childJob
+	}
+
+	root := FromManagedJob(testWorkflow())
+	AddDurations(root, []kbatch.Job{
+		childJob("extract", "download", 0, 10*time.Minute),
+		childJob("extract", "parse", 10*time.Minute, 12*time.Minute),
+		childJob("load", "upload", 12*time.Minute, 0),
+	}, start.Add(20*time.Minute))
+
+	expected := map[string]string{"nightly": "20m0s", "extract": "12m0s", "download": "10m0s", "parse": "2m0s", "load": "8m0s", "upload": "8m0s"}
+	var check func(node *Node)
+	check = func(node *Node) {
+		if node.Duration != expected[node.Name] {
+			t.Errorf("%s: expected %s, got %s", node.Name, expected[node.Name], node.Duration)
+		}
+		for _, child := range node.Children {
+			check(child)
+		}
+	}
+	check(root)
+}
+
+func TestDisplayStatus(t *testing.T) {
+	tests := []struct {
+		status   string
+		reason   string
+		expected string
+	}{
+		{"pending", "", "pending"},
+		{"pending", "Blocked", "blocked"},
+		{"pending", "Queued", "queued"},
+		{"pending", "AwaitingApproval", "queued"},
+		{"running", "", "running"},
+		{"succeeded", "WithinFailureBudget", "succeeded"},
+	}
+	for _, tt := range tests {
+		if status := DisplayStatus(tt.status, tt.reason); status != tt.expected {
+			t.Errorf("%s/%s: expected %s, got %s", tt.status, tt.reason, tt.expected, status)
+		}
+	}
+}
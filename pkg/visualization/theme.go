@@ -0,0 +1,83 @@
+package visualization
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Theme sets the characters of the tree and the colors of the statuses, the zero value draws the
+// Unicode tree without colors
+type Theme struct {
+	// ASCII draws the tree with the ASCII characters, for the terminals without Unicode
+	ASCII bool `json:"ascii,omitempty"`
+	// Colors of the displayed statuses by the color name, e.g. failed: red. Statuses without a color,
+	// the unknown ones included, are not colored.
+	Colors map[string]string `json:"colors,omitempty"`
+}
+
+// colorCodes are the ANSI codes of the color names
+var colorCodes = map[string]string{
+	"none":    "",
+	"black":   "30",
+	"red":     "31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+	"white":   "37",
+	"gray":    "90",
+}
+
+// DefaultColors returns the colors of the statuses set by the operator
+func DefaultColors() map[string]string {
+	return map[string]string{
+		"succeeded": "green",
+		"failed":    "red",
+		"aborted":   "red",
+		"running":   "cyan",
+		"blocked":   "yellow",
+		"queued":    "yellow",
+		"suspended": "yellow",
+		"skipped":   "gray",
+		"invalid":   "magenta",
+	}
+}
+
+// ColorNames returns the color names a theme may use
+func ColorNames() []string {
+	names := []string{}
+	for name := range colorCodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate rejects the colors which are not known
+func (t Theme) Validate() error {
+	for status, color := range t.Colors {
+		if _, found := colorCodes[color]; !found {
+			return fmt.Errorf("unknown color %q of the status %s, expected one of %s", color, status, strings.Join(ColorNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// paint wraps the status in the escape sequence of its color
+func (t Theme) paint(status string) string {
+	code := colorCodes[t.Colors[status]]
+	if code == "" {
+		return status
+	}
+	return "\033[" + code + "m" + status + "\033[0m"
+}
+
+// branches returns the characters of the middle and the last item, of the continued branch and the empty space
+func (t Theme) branches() (string, string, string, string) {
+	if t.ASCII {
+		return "|-- ", "`-- ", "|   ", "    "
+	}
+	return middleItem, lastItem, continueItem, emptySpace
+}
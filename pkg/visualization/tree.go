@@ -0,0 +1,52 @@
+// Package visualization builds the tree of the workflow groups and jobs and renders it for the humans and tools.
+package visualization
+
+import (
+	"strings"
+
+	jobsmanagerv1beta1 "raczylo.com/jobs-manager-operator/api/v1beta1"
+	"raczylo.com/jobs-manager-operator/pkg/dependencies"
+)
+
+const (
+	KindWorkflow = "workflow"
+	KindGroup    = "group"
+	KindJob      = "job"
+)
+
+// Node is a single workflow, group or job of the tree
+type Node struct {
+	Kind   string
+	Name   string
+	Status string
+	// Reason of the pending status, e.g. Blocked or Queued
+	Reason      string
+	Description string
+	// Duration of the run, filled in when known
+	Duration     string
+	Dependencies []string
+	// Logs are the last lines of the failed job, drawn under its node
+	Logs     []string
+	Children []*Node
+}
+
+// FromManagedJob builds the tree of the workflow, job dependencies are shortened to the job names
+func FromManagedJob(mj *jobsmanagerv1beta1.ManagedJob) *Node {
+	root := &Node{Kind: KindWorkflow, Name: mj.Name, Status: mj.Status.Phase, Description: mj.Spec.Description}
+	for _, group := range mj.Spec.Groups {
+		groupNode := &Node{Kind: KindGroup, Name: group.Name, Status: group.Status, Reason: group.Reason, Description: group.Description}
+		for _, dependency := range group.Dependencies {
+			groupNode.Dependencies = append(groupNode.Dependencies, dependency.Name)
+		}
+		jobPrefix := dependencies.JobName(mj.Name, group.Name, "")
+		for _, job := range group.Jobs {
+			jobNode := &Node{Kind: KindJob, Name: job.Name, Status: job.Status, Reason: job.Reason, Description: job.Description}
+			for _, dependency := range job.Dependencies {
+				jobNode.Dependencies = append(jobNode.Dependencies, strings.TrimPrefix(dependency.Name, jobPrefix))
+			}
+			groupNode.Children = append(groupNode.Children, jobNode)
+		}
+		root.Children = append(root.Children, groupNode)
+	}
+	return root
+}
@@ -0,0 +1,41 @@
+// Package v1alpha1 is the machine readable form of the workflow tree exported with `-o json`.
+// Fields are only ever added within the schema version, removals and renames require a new version.
+package v1alpha1
+
+import (
+	_ "embed"
+)
+
+// SchemaVersion identifies the format of the exported document
+const SchemaVersion = "visualization/v1alpha1"
+
+// Schema is the JSON schema of the Tree document
+//
+//go:embed schema.json
+var Schema []byte
+
+// Tree is the exported workflow tree
+type Tree struct {
+	SchemaVersion string  `json:"schemaVersion"`
+	Workflow      Node    `json:"workflow"`
+	Summary       Summary `json:"summary"`
+}
+
+// Node is the workflow, a group or a job
+type Node struct {
+	Kind         string   `json:"kind"`
+	Name         string   `json:"name"`
+	Status       string   `json:"status"`
+	Reason       string   `json:"reason,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	Duration     string   `json:"duration,omitempty"`
+	Dependencies []string `json:"dependencies,omitempty"`
+	Children     []Node   `json:"children,omitempty"`
+}
+
+// Summary counts the jobs of the workflow by their status
+type Summary struct {
+	Groups int            `json:"groups"`
+	Jobs   int            `json:"jobs"`
+	Status map[string]int `json:"status"`
+}